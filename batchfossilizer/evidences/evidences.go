@@ -16,6 +16,8 @@
 package evidences
 
 import (
+	"bytes"
+
 	json "github.com/gibson042/canonicaljson-go"
 	"github.com/pkg/errors"
 	"github.com/stratumn/go-chainscript"
@@ -59,10 +61,42 @@ func (p *BatchProof) Time() uint64 {
 	return uint64(p.Timestamp)
 }
 
-// Verify returns true if the proof of a given linkHash is correct.
-func (p *BatchProof) Verify(_ interface{}) bool {
-	err := p.Path.Validate()
-	return err == nil
+// Verify returns true if the merkle path links the given data (usually a
+// link hash) to the merkle root.
+// It doesn't verify the proof of the merkle root, whose format depends on
+// the fossilizer wrapped by the batch fossilizer.
+func (p *BatchProof) Verify(data interface{}) bool {
+	var leaf []byte
+	switch d := data.(type) {
+	case []byte:
+		leaf = d
+	case chainscript.LinkHash:
+		leaf = d
+	default:
+		return false
+	}
+
+	// If the tree contains a single element, it's valid only if it's the
+	// root.
+	if len(p.Path) == 0 {
+		return bytes.Equal(leaf, p.Root)
+	}
+
+	if err := p.Path.Validate(); err != nil {
+		return false
+	}
+
+	// Each node of the path must contain the hash of the previous level,
+	// starting at the leaf and ending at the root.
+	for _, node := range p.Path {
+		if !bytes.Equal(leaf, node.Left) && !bytes.Equal(leaf, node.Right) {
+			return false
+		}
+
+		leaf = node.Parent
+	}
+
+	return bytes.Equal(leaf, p.Root)
 }
 
 // Evidence wraps the proof in a versioned evidence.
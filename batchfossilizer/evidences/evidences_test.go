@@ -18,15 +18,48 @@ import (
 	"testing"
 
 	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-chainscript/chainscripttest"
 	"github.com/stratumn/go-core/batchfossilizer/evidences"
 	"github.com/stratumn/go-core/monitoring/errorcode"
 	"github.com/stratumn/go-core/testutil"
 	"github.com/stratumn/go-core/types"
+	"github.com/stratumn/merkle"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func TestBatchFossilizerEvidence(t *testing.T) {
+	t.Run("verify", func(t *testing.T) {
+		leaves := [][]byte{
+			chainscripttest.RandomHash(),
+			chainscripttest.RandomHash(),
+			chainscripttest.RandomHash(),
+		}
+		tree, err := merkle.NewStaticTree(leaves)
+		require.NoError(t, err)
+
+		for i, leaf := range leaves {
+			proof := &evidences.BatchProof{Root: tree.Root(), Path: tree.Path(i)}
+			assert.True(t, proof.Verify(leaf), "leaf %d", i)
+			assert.True(t, proof.Verify(chainscript.LinkHash(leaf)), "leaf %d", i)
+		}
+
+		proof := &evidences.BatchProof{Root: tree.Root(), Path: tree.Path(0)}
+		assert.False(t, proof.Verify(leaves[2]), "other leaf")
+		assert.False(t, proof.Verify(chainscripttest.RandomHash()), "unknown leaf")
+		assert.False(t, proof.Verify("leaf"), "invalid data")
+
+		proof.Root = chainscripttest.RandomHash()
+		assert.False(t, proof.Verify(leaves[0]), "other root")
+	})
+
+	t.Run("verify-single-leaf", func(t *testing.T) {
+		leaf := chainscripttest.RandomHash()
+		proof := &evidences.BatchProof{Root: leaf}
+		assert.True(t, proof.Verify(leaf))
+		assert.False(t, proof.Verify(chainscripttest.RandomHash()))
+	})
+
 	t.Run("unmarshal-invalid-backend", func(t *testing.T) {
 		proof := &evidences.BatchProof{Timestamp: 42}
 		e, err := proof.Evidence("btc")
@@ -74,8 +74,13 @@ func (p *BlockchainProof) Verify(data interface{}) bool {
 		return false
 	}
 
-	dataBytes, ok := data.([]byte)
-	if !ok {
+	var dataBytes []byte
+	switch d := data.(type) {
+	case []byte:
+		dataBytes = d
+	case chainscript.LinkHash:
+		dataBytes = d
+	default:
 		return false
 	}
 
@@ -42,6 +42,11 @@ func TestBlockchainFossilizerEvidence(t *testing.T) {
 			proof := evidences.New([]byte{42}, []byte{43})
 			assert.True(t, proof.Verify([]byte{42}))
 		})
+
+		t.Run("link hash", func(t *testing.T) {
+			proof := evidences.New([]byte{42}, []byte{43})
+			assert.True(t, proof.Verify(chainscript.LinkHash([]byte{42})))
+		})
 	})
 
 	t.Run("Unmarshal", func(t *testing.T) {
@@ -0,0 +1,54 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boltstore
+
+import (
+	"context"
+
+	"github.com/stratumn/go-core/bufferedbatch"
+	"github.com/stratumn/go-core/monitoring"
+)
+
+// Batch is the type that implements github.com/stratumn/go-core/store.Batch.
+type Batch struct {
+	*bufferedbatch.Batch
+
+	originalBoltStore *BoltStore
+}
+
+// NewBatch creates a new Batch
+func NewBatch(ctx context.Context, a *BoltStore) *Batch {
+	return &Batch{
+		Batch:             bufferedbatch.NewBatch(ctx, a),
+		originalBoltStore: a,
+	}
+}
+
+// Write implements github.com/stratumn/go-core/store.Batch.Write
+// All the links are written in a single transaction: if one of them can't be
+// created, none of them are.
+func (b *Batch) Write(ctx context.Context) (err error) {
+	span, _ := monitoring.StartSpanProcessing(ctx, "boltstore/batch/Write")
+	defer func() {
+		monitoring.SetSpanStatusAndEnd(span, err)
+	}()
+
+	if len(b.Links) == 0 {
+		return nil
+	}
+
+	_, err = b.originalBoltStore.createLinks(b.Links...)
+	return err
+}
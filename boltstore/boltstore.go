@@ -0,0 +1,497 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package boltstore implements a store that saves all the segments in an
+// embedded bbolt database.
+//
+// Links are indexed by map ID, process, step, previous link hash, tags and
+// references, so most queries don't need to read all the links. Writes are
+// transactional, which makes batches atomic.
+// It doesn't need an external database, but a database file can only be
+// opened by one process at a time.
+package boltstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// Name is the name set in the store's information.
+	Name = "bolt"
+
+	// Description is the description set in the store's information.
+	Description = "Stratumn's Bolt Store"
+
+	// DefaultPath is the default path of the database file.
+	DefaultPath = "/var/stratumn/boltstore/store.db"
+
+	// openTimeout is the time to wait for another process to release the
+	// database file.
+	openTimeout = 5 * time.Second
+
+	// valuesChunkSize is the number of key-value pairs read at once when
+	// iterating over a range of keys.
+	valuesChunkSize = 256
+)
+
+// Config contains configuration options for the store.
+type Config struct {
+	// A version string that will be set in the store's information.
+	Version string
+
+	// A git commit hash that will be set in the store's information.
+	Commit string
+
+	// Path of the database file. It is created if it doesn't exist.
+	Path string
+}
+
+// Info is the info returned by GetInfo.
+type Info struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+	Commit      string `json:"commit"`
+}
+
+// BoltStore is the type that implements github.com/stratumn/go-core/store.Adapter.
+type BoltStore struct {
+	config     *Config
+	eventChans []chan *store.Event
+	db         *bolt.DB
+
+	// Written in write transactions, which are serialized.
+	enforceUniqueMapEntry bool
+
+	eventsMutex sync.RWMutex
+}
+
+// New creates an instance of a BoltStore.
+func New(config *Config) (*BoltStore, error) {
+	if err := os.MkdirAll(filepath.Dir(config.Path), 0755); err != nil {
+		return nil, types.WrapError(err, errorcode.Unavailable, store.Component, "could not create database directory")
+	}
+
+	db, err := bolt.Open(config.Path, 0644, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, types.WrapError(err, errorcode.Unavailable, store.Component, "could not open database")
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range buckets() {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, types.WrapError(err, errorcode.Internal, store.Component, "could not create buckets")
+	}
+
+	return &BoltStore{config: config, db: db}, nil
+}
+
+// Close closes the database.
+func (a *BoltStore) Close() error {
+	if err := a.db.Close(); err != nil {
+		return types.WrapError(err, errorcode.Internal, store.Component, "could not close database")
+	}
+
+	return nil
+}
+
+/********** Store adapter implementation **********/
+
+// GetInfo implements github.com/stratumn/go-core/store.Adapter.GetInfo.
+func (a *BoltStore) GetInfo(ctx context.Context) (interface{}, error) {
+	return &Info{
+		Name:        Name,
+		Description: Description,
+		Version:     a.config.Version,
+		Commit:      a.config.Commit,
+	}, nil
+}
+
+// AddStoreEventChannel implements github.com/stratumn/go-core/store.Adapter.AddStoreEventChannel
+func (a *BoltStore) AddStoreEventChannel(eventChan chan *store.Event) {
+	a.eventsMutex.Lock()
+	defer a.eventsMutex.Unlock()
+
+	a.eventChans = append(a.eventChans, eventChan)
+}
+
+// NewBatch implements github.com/stratumn/go-core/store.Adapter.NewBatch.
+func (a *BoltStore) NewBatch(ctx context.Context) (store.Batch, error) {
+	return NewBatch(ctx, a), nil
+}
+
+// EnforceUniqueMapEntry implements
+// github.com/stratumn/go-core/store.AdapterConfig.EnforceUniqueMapEntry.
+func (a *BoltStore) EnforceUniqueMapEntry() error {
+	return a.db.Update(func(*bolt.Tx) error {
+		a.enforceUniqueMapEntry = true
+		return nil
+	})
+}
+
+func (a *BoltStore) emit(event *store.Event) {
+	a.eventsMutex.RLock()
+	defer a.eventsMutex.RUnlock()
+
+	for _, c := range a.eventChans {
+		c <- event
+	}
+}
+
+/********** Store writer implementation **********/
+
+// CreateLink implements github.com/stratumn/go-core/store.LinkWriter.CreateLink.
+func (a *BoltStore) CreateLink(ctx context.Context, link *chainscript.Link) (chainscript.LinkHash, error) {
+	linkHashes, err := a.createLinks(link)
+	if err != nil {
+		return nil, err
+	}
+
+	return linkHashes[0], nil
+}
+
+// createLinks saves links in a single transaction: if one of them can't be
+// saved, none of them is. Links that already exist are ignored.
+func (a *BoltStore) createLinks(links ...*chainscript.Link) ([]chainscript.LinkHash, error) {
+	linkHashes := make([]chainscript.LinkHash, len(links))
+	var created []*chainscript.Link
+
+	err := a.db.Update(func(tx *bolt.Tx) error {
+		created = nil
+		now := time.Now()
+
+		for i, link := range links {
+			linkHash, ok, err := a.createLink(tx, link, now)
+			if err != nil {
+				return err
+			}
+
+			linkHashes[i] = linkHash
+			if ok {
+				created = append(created, link)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(created) > 0 {
+		a.emit(store.NewSavedLinks(created...))
+	}
+
+	return linkHashes, nil
+}
+
+// createLink saves a link and indexes it in a write transaction.
+// It returns false if the link already exists.
+func (a *BoltStore) createLink(tx *bolt.Tx, link *chainscript.Link, createdAt time.Time) (chainscript.LinkHash, bool, error) {
+	linkHash, err := link.Hash()
+	if err != nil {
+		return nil, false, types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not hash link")
+	}
+
+	links := tx.Bucket(linksBucket)
+	if links.Get(linkHash) != nil {
+		return linkHash, false, nil
+	}
+
+	if err = addChild(tx, link.PrevLinkHash()); err != nil {
+		return linkHash, false, err
+	}
+
+	if len(link.PrevLinkHash()) == 0 {
+		if err = a.addMapEntry(tx, link, linkHash); err != nil {
+			return linkHash, false, err
+		}
+	}
+
+	js, err := json.Marshal(link)
+	if err != nil {
+		return linkHash, false, types.WrapError(err, errorcode.InvalidArgument, store.Component, "json.Marshal")
+	}
+
+	created := make([]byte, 8)
+	binary.BigEndian.PutUint64(created, uint64(createdAt.UnixNano()))
+
+	mapID := []byte(link.Meta.MapId)
+	process := []byte(link.Meta.Process.Name)
+
+	for _, put := range []struct {
+		bucket []byte
+		key    []byte
+		value  []byte
+	}{
+		{linksBucket, linkHash, js},
+		{createdAtBucket, linkHash, created},
+		{mapsBucket, mapID, []byte{}},
+		{processMapsBucket, append(indexPrefix(process), mapID...), []byte{}},
+	} {
+		if err := tx.Bucket(put.bucket).Put(put.key, put.value); err != nil {
+			return linkHash, false, types.WrapError(err, errorcode.Internal, store.Component, "could not save link")
+		}
+	}
+
+	if err = addToIndexes(tx, link, linkHash); err != nil {
+		return linkHash, false, err
+	}
+
+	return linkHash, true, nil
+}
+
+// addChild checks that a link can have one more child and increments its
+// number of children.
+// If the link isn't in the store its out degree can't be enforced.
+func addChild(tx *bolt.Tx, linkHash chainscript.LinkHash) error {
+	if len(linkHash) == 0 {
+		return nil
+	}
+
+	parent, err := getLink(tx, linkHash)
+	if err != nil || parent == nil {
+		return err
+	}
+
+	if parent.Meta.OutDegree < 0 {
+		return nil
+	}
+
+	degrees := tx.Bucket(degreesBucket)
+
+	childCount := uint32(0)
+	if v := degrees.Get(linkHash); v != nil {
+		childCount = binary.BigEndian.Uint32(v)
+	}
+
+	if childCount >= uint32(parent.Meta.OutDegree) {
+		return types.WrapError(chainscript.ErrOutDegree, errorcode.FailedPrecondition, store.Component, "could not create link")
+	}
+
+	v := make([]byte, 4)
+	binary.BigEndian.PutUint32(v, childCount+1)
+	if err := degrees.Put(linkHash, v); err != nil {
+		return types.WrapError(err, errorcode.Internal, store.Component, "could not increment child count")
+	}
+
+	return nil
+}
+
+// addMapEntry records the first link of a map, and rejects other links
+// without parent when unique map entries are enforced.
+// Entries are always recorded so they can be enforced on existing maps.
+func (a *BoltStore) addMapEntry(tx *bolt.Tx, link *chainscript.Link, linkHash chainscript.LinkHash) error {
+	entries := tx.Bucket(mapEntriesBucket)
+	key := append(indexPrefix([]byte(link.Meta.Process.Name)), link.Meta.MapId...)
+
+	if entries.Get(key) != nil {
+		if a.enforceUniqueMapEntry {
+			return types.WrapError(store.ErrUniqueMapEntry, errorcode.FailedPrecondition, store.Component, "could not initialize map")
+		}
+
+		return nil
+	}
+
+	if err := entries.Put(key, linkHash); err != nil {
+		return types.WrapError(err, errorcode.Internal, store.Component, "could not save map entry")
+	}
+
+	return nil
+}
+
+// AddEvidence implements github.com/stratumn/go-core/store.EvidenceWriter.AddEvidence.
+func (a *BoltStore) AddEvidence(ctx context.Context, linkHash chainscript.LinkHash, evidence *chainscript.Evidence) error {
+	err := a.db.Update(func(tx *bolt.Tx) error {
+		evidences, err := getEvidences(tx, linkHash)
+		if err != nil {
+			return err
+		}
+
+		if err = evidences.AddEvidence(evidence); err != nil {
+			return err
+		}
+
+		js, err := json.Marshal(evidences)
+		if err != nil {
+			return types.WrapError(err, errorcode.InvalidArgument, store.Component, "json.Marshal")
+		}
+
+		if err = tx.Bucket(evidencesBucket).Put(linkHash, js); err != nil {
+			return types.WrapError(err, errorcode.Internal, store.Component, "could not save evidence")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	evidenceEvent := store.NewSavedEvidences()
+	evidenceEvent.AddSavedEvidence(linkHash, evidence)
+	a.emit(evidenceEvent)
+
+	return nil
+}
+
+/********** github.com/stratumn/go-core/store.KeyValueStore implementation **********/
+
+// SetValue implements github.com/stratumn/go-core/store.KeyValueStore.SetValue.
+func (a *BoltStore) SetValue(ctx context.Context, key []byte, value []byte) error {
+	err := a.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(valuesBucket).Put(key, value)
+	})
+	if err != nil {
+		return types.WrapError(err, errorcode.Internal, store.Component, "could not set value")
+	}
+
+	return nil
+}
+
+// GetValue implements github.com/stratumn/go-core/store.KeyValueStore.GetValue.
+func (a *BoltStore) GetValue(ctx context.Context, key []byte) ([]byte, error) {
+	var value []byte
+	err := a.db.View(func(tx *bolt.Tx) error {
+		value = copyBytes(tx.Bucket(valuesBucket).Get(key))
+		return nil
+	})
+
+	return value, err
+}
+
+// DeleteValue implements github.com/stratumn/go-core/store.KeyValueStore.DeleteValue.
+func (a *BoltStore) DeleteValue(ctx context.Context, key []byte) ([]byte, error) {
+	var value []byte
+	err := a.db.Update(func(tx *bolt.Tx) error {
+		values := tx.Bucket(valuesBucket)
+		value = copyBytes(values.Get(key))
+		if value == nil {
+			return nil
+		}
+
+		return values.Delete(key)
+	})
+	if err != nil {
+		return nil, types.WrapError(err, errorcode.Internal, store.Component, "could not delete value")
+	}
+
+	return value, nil
+}
+
+// IterateValues implements github.com/stratumn/go-core/store.KeyValueIterator.IterateValues.
+// Pairs are read in chunks, and fn is only called once the read transaction
+// of a chunk is closed.
+func (a *BoltStore) IterateValues(ctx context.Context, start, end []byte, fn func(key, value []byte) error) error {
+	for {
+		keys, values, err := a.getValues(start, end)
+		if err != nil {
+			return err
+		}
+
+		for i, key := range keys {
+			if err := fn(key, values[i]); err != nil {
+				return err
+			}
+		}
+
+		if len(keys) < valuesChunkSize {
+			return nil
+		}
+
+		// The smallest key after the last one read.
+		last := keys[len(keys)-1]
+		start = append(append(make([]byte, 0, len(last)+1), last...), 0)
+	}
+}
+
+func (a *BoltStore) getValues(start, end []byte) ([][]byte, [][]byte, error) {
+	var keys, values [][]byte
+	err := a.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(valuesBucket).Cursor()
+		for k, v := c.Seek(start); k != nil && len(keys) < valuesChunkSize; k, v = c.Next() {
+			if end != nil && bytes.Compare(k, end) >= 0 {
+				break
+			}
+
+			keys = append(keys, copyBytes(k))
+			values = append(values, copyBytes(v))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, types.WrapError(err, errorcode.Internal, store.Component, "could not iterate values")
+	}
+
+	return keys, values, nil
+}
+
+// WriteValues implements github.com/stratumn/go-core/store.KeyValueBatch.WriteValues.
+func (a *BoltStore) WriteValues(ctx context.Context, writes []store.KeyValueWrite) error {
+	err := a.db.Update(func(tx *bolt.Tx) error {
+		values := tx.Bucket(valuesBucket)
+		for _, w := range writes {
+			var err error
+			if w.Delete {
+				err = values.Delete(w.Key)
+			} else {
+				err = values.Put(w.Key, w.Value)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return types.WrapError(err, errorcode.Internal, store.Component, "could not write values")
+	}
+
+	return nil
+}
+
+// copyBytes copies a value read from the database, since it is only valid
+// during its transaction.
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
@@ -0,0 +1,233 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boltstore
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-chainscript/chainscripttest"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/store/storetestcases"
+	"github.com/stratumn/go-core/testutil"
+	"github.com/stratumn/go-core/tmpop/tmpoptestcases"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoltStore(t *testing.T) {
+	factory := storetestcases.Factory{
+		New:               createAdapter,
+		NewKeyValueStore:  createKeyValueStore,
+		Free:              freeAdapter,
+		FreeKeyValueStore: freeKeyValueStore,
+	}
+
+	factory.RunStoreTests(t)
+	factory.RunKeyValueStoreTests(t)
+}
+
+func TestBoltTMPop(t *testing.T) {
+	tmpoptestcases.Factory{
+		New:  createAdapterTMPop,
+		Free: freeAdapterTMPop,
+	}.RunTests(t)
+}
+
+// createBoltStore creates a store in its own temporary directory.
+func createBoltStore() (*BoltStore, error) {
+	dir, err := ioutil.TempDir("", "boltstore")
+	if err != nil {
+		return nil, err
+	}
+
+	return New(&Config{Path: filepath.Join(dir, "store.db")})
+}
+
+func createAdapter() (store.Adapter, error) {
+	return createBoltStore()
+}
+
+func createKeyValueStore() (store.KeyValueStore, error) {
+	return createBoltStore()
+}
+
+func createAdapterTMPop() (store.Adapter, store.KeyValueStore, error) {
+	a, err := createBoltStore()
+	return a, a, err
+}
+
+func freeBoltStore(s *BoltStore) {
+	if err := s.Close(); err != nil {
+		panic(err)
+	}
+
+	os.RemoveAll(filepath.Dir(s.config.Path))
+}
+
+func freeAdapter(s store.Adapter) {
+	freeBoltStore(s.(*BoltStore))
+}
+
+func freeKeyValueStore(s store.KeyValueStore) {
+	freeBoltStore(s.(*BoltStore))
+}
+
+func freeAdapterTMPop(a store.Adapter, _ store.KeyValueStore) {
+	freeAdapter(a)
+}
+
+func TestReopen(t *testing.T) {
+	ctx := context.Background()
+	a, err := createBoltStore()
+	require.NoError(t, err)
+	defer freeBoltStore(a)
+
+	link := chainscripttest.NewLinkBuilder(t).WithRandomData().WithTags("tag").Build()
+	lh, err := a.CreateLink(ctx, link)
+	require.NoError(t, err)
+	require.NoError(t, a.Close())
+
+	a, err = New(a.config)
+	require.NoError(t, err)
+
+	segments, err := a.FindSegments(ctx, &store.SegmentFilter{
+		Pagination: store.Pagination{Limit: store.DefaultLimit},
+		Tags:       []string{"tag"},
+	})
+	require.NoError(t, err)
+	require.Len(t, segments.Segments, 1)
+	assert.Equal(t, lh, segments.Segments[0].LinkHash())
+}
+
+func TestBatch_Write(t *testing.T) {
+	ctx := context.Background()
+	a, err := createBoltStore()
+	require.NoError(t, err)
+	defer freeBoltStore(a)
+
+	require.NoError(t, a.EnforceUniqueMapEntry())
+
+	t.Run("writes all links", func(t *testing.T) {
+		b, err := a.NewBatch(ctx)
+		require.NoError(t, err)
+
+		parent := chainscripttest.NewLinkBuilder(t).WithRandomData().Build()
+		child := chainscripttest.NewLinkBuilder(t).
+			WithRandomData().
+			WithParent(t, parent).
+			WithProcess(parent.Meta.Process.Name).
+			WithMapID(parent.Meta.MapId).
+			Build()
+
+		_, err = b.CreateLink(ctx, parent)
+		require.NoError(t, err)
+		childHash, err := b.CreateLink(ctx, child)
+		require.NoError(t, err)
+
+		require.NoError(t, b.Write(ctx))
+
+		found, err := a.FindSegments(ctx, &store.SegmentFilter{
+			Pagination:   store.Pagination{Limit: store.DefaultLimit},
+			PrevLinkHash: child.PrevLinkHash(),
+		})
+		require.NoError(t, err)
+		require.Len(t, found.Segments, 1)
+		assert.Equal(t, childHash, found.Segments[0].LinkHash())
+	})
+
+	t.Run("failed link rolls back the batch", func(t *testing.T) {
+		b, err := a.NewBatch(ctx)
+		require.NoError(t, err)
+
+		l1 := chainscripttest.NewLinkBuilder(t).WithRandomData().Build()
+		l2 := chainscripttest.NewLinkBuilder(t).
+			WithRandomData().
+			WithProcess(l1.Meta.Process.Name).
+			WithMapID(l1.Meta.MapId).
+			Build()
+
+		lh1, err := b.CreateLink(ctx, l1)
+		require.NoError(t, err)
+		_, err = b.CreateLink(ctx, l2)
+		require.NoError(t, err)
+
+		err = b.Write(ctx)
+		testutil.AssertWrappedErrorEqual(t, err, store.ErrUniqueMapEntry)
+
+		found, err := a.GetSegment(ctx, lh1)
+		require.NoError(t, err)
+		assert.Nil(t, found)
+
+		mapIDs, err := a.GetMapIDs(ctx, &store.MapFilter{
+			Pagination: store.Pagination{Limit: store.DefaultLimit},
+			Prefix:     l1.Meta.MapId,
+		})
+		require.NoError(t, err)
+		assert.Empty(t, mapIDs)
+	})
+}
+
+func TestCreateLink_OutDegree(t *testing.T) {
+	ctx := context.Background()
+	a, err := createBoltStore()
+	require.NoError(t, err)
+	defer freeBoltStore(a)
+
+	parent := chainscripttest.NewLinkBuilder(t).WithRandomData().WithDegree(1).Build()
+	_, err = a.CreateLink(ctx, parent)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		child := chainscripttest.NewLinkBuilder(t).
+			WithRandomData().
+			WithParent(t, parent).
+			WithProcess(parent.Meta.Process.Name).
+			WithMapID(parent.Meta.MapId).
+			Build()
+		_, err = a.CreateLink(ctx, child)
+	}
+
+	testutil.AssertWrappedErrorEqual(t, err, chainscript.ErrOutDegree)
+}
+
+func TestWriteValues_Atomic(t *testing.T) {
+	ctx := context.Background()
+	a, err := createBoltStore()
+	require.NoError(t, err)
+	defer freeBoltStore(a)
+
+	require.NoError(t, a.SetValue(ctx, []byte("deleted"), []byte("value")))
+
+	// Bolt rejects empty keys, which makes the last write fail.
+	err = a.WriteValues(ctx, []store.KeyValueWrite{
+		{Key: []byte("set"), Value: []byte("value")},
+		{Key: []byte("deleted"), Delete: true},
+		{Key: []byte{}, Value: []byte("value")},
+	})
+	assert.Error(t, err)
+
+	value, err := a.GetValue(ctx, []byte("set"))
+	require.NoError(t, err)
+	assert.Nil(t, value)
+
+	value, err = a.GetValue(ctx, []byte("deleted"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), value)
+}
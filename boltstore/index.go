@@ -0,0 +1,432 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boltstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// Link hash -> link JSON.
+	linksBucket = []byte("links")
+
+	// Link hash -> creation time in nanoseconds (big endian).
+	createdAtBucket = []byte("created_at")
+
+	// Link hash -> evidences JSON.
+	evidencesBucket = []byte("evidences")
+
+	// Link hash -> number of children (big endian).
+	degreesBucket = []byte("degrees")
+
+	// Key -> value, for the KeyValueStore implementation.
+	valuesBucket = []byte("values")
+
+	// Map ID -> nothing.
+	mapsBucket = []byte("maps")
+
+	// Process + map ID -> nothing.
+	processMapsBucket = []byte("process_maps")
+
+	// Process + map ID -> hash of the first link without parent.
+	mapEntriesBucket = []byte("map_entries")
+)
+
+// index maps some values of a link to its hash.
+// Keys are the indexed value prefixed by its length followed by the link
+// hash, so all the links with a given value can be found by seeking the
+// value's prefix. Values are empty.
+type index struct {
+	bucket []byte
+	values func(*chainscript.Link) [][]byte
+}
+
+var (
+	mapIDIndex = &index{
+		bucket: []byte("index_map_id"),
+		values: func(link *chainscript.Link) [][]byte {
+			return [][]byte{[]byte(link.Meta.MapId)}
+		},
+	}
+
+	processIndex = &index{
+		bucket: []byte("index_process"),
+		values: func(link *chainscript.Link) [][]byte {
+			return [][]byte{[]byte(link.Meta.Process.Name)}
+		},
+	}
+
+	stepIndex = &index{
+		bucket: []byte("index_step"),
+		values: func(link *chainscript.Link) [][]byte {
+			return [][]byte{[]byte(link.Meta.Step)}
+		},
+	}
+
+	prevLinkHashIndex = &index{
+		bucket: []byte("index_prev_link_hash"),
+		values: func(link *chainscript.Link) [][]byte {
+			if len(link.PrevLinkHash()) == 0 {
+				return nil
+			}
+			return [][]byte{link.PrevLinkHash()}
+		},
+	}
+
+	tagIndex = &index{
+		bucket: []byte("index_tag"),
+		values: func(link *chainscript.Link) [][]byte {
+			var values [][]byte
+			for tag := range link.TagMap() {
+				values = append(values, []byte(tag))
+			}
+			return values
+		},
+	}
+
+	refIndex = &index{
+		bucket: []byte("index_ref"),
+		values: func(link *chainscript.Link) [][]byte {
+			var values [][]byte
+			for _, ref := range link.Meta.Refs {
+				values = append(values, ref.LinkHash)
+			}
+			return values
+		},
+	}
+
+	indexes = []*index{
+		mapIDIndex,
+		processIndex,
+		stepIndex,
+		prevLinkHashIndex,
+		tagIndex,
+		refIndex,
+	}
+)
+
+// buckets returns the names of all the buckets used by the store.
+func buckets() [][]byte {
+	names := [][]byte{
+		linksBucket,
+		createdAtBucket,
+		evidencesBucket,
+		degreesBucket,
+		valuesBucket,
+		mapsBucket,
+		processMapsBucket,
+		mapEntriesBucket,
+	}
+
+	for _, idx := range indexes {
+		names = append(names, idx.bucket)
+	}
+
+	for _, idx := range orderedIndexes {
+		names = append(names, idx.bucket)
+	}
+
+	return names
+}
+
+// indexPrefix prefixes a value with its length so that a value is never the
+// prefix of another one.
+func indexPrefix(value []byte) []byte {
+	prefix := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(value))
+	n := binary.PutUvarint(prefix, uint64(len(value)))
+	return append(prefix[:n], value...)
+}
+
+// addToIndexes indexes a link in all the indexes.
+func addToIndexes(tx *bolt.Tx, link *chainscript.Link, linkHash chainscript.LinkHash) error {
+	for _, idx := range indexes {
+		bucket := tx.Bucket(idx.bucket)
+		for _, value := range idx.values(link) {
+			if err := bucket.Put(append(indexPrefix(value), linkHash...), []byte{}); err != nil {
+				return types.WrapError(err, errorcode.Internal, store.Component, "could not index link")
+			}
+		}
+	}
+
+	for _, idx := range orderedIndexes {
+		if err := tx.Bucket(idx.bucket).Put(idx.key(link, linkHash), []byte{}); err != nil {
+			return types.WrapError(err, errorcode.Internal, store.Component, "could not index link")
+		}
+	}
+
+	return nil
+}
+
+// lookup returns the hashes of the links indexed with the given value.
+func (idx *index) lookup(tx *bolt.Tx, value []byte) []chainscript.LinkHash {
+	var linkHashes []chainscript.LinkHash
+
+	prefix := indexPrefix(value)
+	c := tx.Bucket(idx.bucket).Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		linkHashes = append(linkHashes, copyBytes(k[len(prefix):]))
+	}
+
+	return linkHashes
+}
+
+// lookupAny returns the hashes of the links indexed with any of the given
+// values, without duplicates.
+func (idx *index) lookupAny(tx *bolt.Tx, values []string) []chainscript.LinkHash {
+	var linkHashes []chainscript.LinkHash
+	seen := make(map[string]struct{})
+
+	for _, value := range values {
+		for _, linkHash := range idx.lookup(tx, []byte(value)) {
+			if _, ok := seen[string(linkHash)]; ok {
+				continue
+			}
+
+			seen[string(linkHash)] = struct{}{}
+			linkHashes = append(linkHashes, linkHash)
+		}
+	}
+
+	return linkHashes
+}
+
+// candidates returns the hashes of the links that may match the filter,
+// using the most selective index available.
+// It returns false if no index can be used and all the links need to be
+// read.
+func candidates(tx *bolt.Tx, filter *store.SegmentFilter) ([]chainscript.LinkHash, bool) {
+	switch {
+	case len(filter.LinkHashes) > 0:
+		return filter.LinkHashes, true
+	case len(filter.PrevLinkHash) > 0 && !filter.WithoutParent:
+		return prevLinkHashIndex.lookup(tx, filter.PrevLinkHash), true
+	case len(filter.Referencing) > 0:
+		return refIndex.lookup(tx, filter.Referencing), true
+	case len(filter.MapIDs) > 0:
+		return mapIDIndex.lookupAny(tx, filter.MapIDs), true
+	case len(filter.Tags) > 0:
+		return tagIndex.lookup(tx, []byte(filter.Tags[0])), true
+	case filter.Step != "":
+		return stepIndex.lookup(tx, []byte(filter.Step)), true
+	case len(filter.Steps) > 0:
+		return stepIndex.lookupAny(tx, filter.Steps), true
+	case filter.Process != "":
+		return processIndex.lookup(tx, []byte(filter.Process)), true
+	case len(filter.Processes) > 0:
+		return processIndex.lookupAny(tx, filter.Processes), true
+	case len(filter.TagsAny) > 0:
+		return tagIndex.lookupAny(tx, filter.TagsAny), true
+	default:
+		return nil, false
+	}
+}
+
+// orderedIndex maps some value of a link to its hash, sorted like segments
+// are: by decreasing priority then by link hash.
+// Keys are the indexed value prefixed by its length (if any) followed by the
+// order key of the link, so a page of the links with a given value can be
+// read by seeking a cursor without reading the other links.
+type orderedIndex struct {
+	bucket []byte
+	value  func(*chainscript.Link) []byte
+}
+
+var (
+	// Order key -> nothing, for all the links.
+	linksOrder = &orderedIndex{
+		bucket: []byte("order"),
+	}
+
+	mapIDOrder = &orderedIndex{
+		bucket: []byte("order_map_id"),
+		value: func(link *chainscript.Link) []byte {
+			return []byte(link.Meta.MapId)
+		},
+	}
+
+	processOrder = &orderedIndex{
+		bucket: []byte("order_process"),
+		value: func(link *chainscript.Link) []byte {
+			return []byte(link.Meta.Process.Name)
+		},
+	}
+
+	orderedIndexes = []*orderedIndex{
+		linksOrder,
+		mapIDOrder,
+		processOrder,
+	}
+)
+
+// orderKeyLen is the length of the order key without the link hash.
+const orderKeyLen = 8
+
+// orderKey returns a key that sorts bytewise like segments: by decreasing
+// priority then by link hash.
+func orderKey(priority float64, linkHash chainscript.LinkHash) []byte {
+	// Flip the bits of the float so that it sorts bytewise in increasing
+	// order, then invert them all to sort in decreasing order.
+	bits := math.Float64bits(priority)
+	if bits&(1<<63) == 0 {
+		bits |= 1 << 63
+	} else {
+		bits = ^bits
+	}
+
+	key := make([]byte, orderKeyLen, orderKeyLen+len(linkHash))
+	binary.BigEndian.PutUint64(key, ^bits)
+	return append(key, linkHash...)
+}
+
+// prefix returns the prefix of the keys of the links indexed with the given
+// value.
+func (idx *orderedIndex) prefix(value []byte) []byte {
+	if idx.value == nil {
+		return nil
+	}
+
+	return indexPrefix(value)
+}
+
+// key returns the key of a link in the index.
+func (idx *orderedIndex) key(link *chainscript.Link, linkHash chainscript.LinkHash) []byte {
+	key := orderKey(link.Meta.Priority, linkHash)
+	if idx.value == nil {
+		return key
+	}
+
+	return append(indexPrefix(idx.value(link)), key...)
+}
+
+// walk calls fn with the hashes of the links indexed with the given value
+// in the order of segments (or the reverse order), starting right after the
+// given cursor if any, until fn returns false.
+func (idx *orderedIndex) walk(tx *bolt.Tx, value []byte, start *store.SegmentCursor, reverse bool, fn func(chainscript.LinkHash) (bool, error)) error {
+	prefix := idx.prefix(value)
+	c := tx.Bucket(idx.bucket).Cursor()
+
+	var from []byte
+	if start != nil {
+		from = append(copyBytes(prefix), orderKey(start.Priority, start.LinkHash)...)
+	}
+
+	var k []byte
+	switch {
+	case !reverse && from == nil:
+		k, _ = c.Seek(prefix)
+	case !reverse:
+		if k, _ = c.Seek(from); bytes.Equal(k, from) {
+			k, _ = c.Next()
+		}
+	default:
+		if from == nil {
+			from = store.PrefixEnd(prefix)
+		}
+
+		// Find the last key strictly lower than from.
+		if from == nil {
+			k, _ = c.Last()
+		} else if k, _ = c.Seek(from); k == nil {
+			k, _ = c.Last()
+		} else {
+			k, _ = c.Prev()
+		}
+	}
+
+	for ; k != nil && bytes.HasPrefix(k, prefix); k = next(c, reverse) {
+		more, err := fn(copyBytes(k[len(prefix)+orderKeyLen:]))
+		if err != nil || !more {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// count returns the number of links indexed with the given value.
+// It only reads the keys of the index.
+func (idx *orderedIndex) count(tx *bolt.Tx, value []byte) int {
+	n := 0
+	prefix := idx.prefix(value)
+	c := tx.Bucket(idx.bucket).Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		n++
+	}
+
+	return n
+}
+
+func next(c *bolt.Cursor, reverse bool) []byte {
+	if reverse {
+		k, _ := c.Prev()
+		return k
+	}
+
+	k, _ := c.Next()
+	return k
+}
+
+// ordering returns the ordered index to use to find the segments matching
+// the filter and the value to look up.
+// It returns false when an unordered index is more selective: the matching
+// links then need to be sorted.
+func ordering(filter *store.SegmentFilter) (*orderedIndex, []byte, bool) {
+	switch {
+	case len(filter.LinkHashes) > 0,
+		len(filter.PrevLinkHash) > 0 && !filter.WithoutParent,
+		len(filter.Referencing) > 0:
+		return nil, nil, false
+	case len(filter.MapIDs) == 1:
+		return mapIDOrder, []byte(filter.MapIDs[0]), true
+	case filter.Process != "":
+		return processOrder, []byte(filter.Process), true
+	case len(filter.MapIDs) > 0,
+		len(filter.Tags) > 0,
+		filter.Step != "",
+		len(filter.Steps) > 0,
+		len(filter.Processes) > 0,
+		len(filter.TagsAny) > 0:
+		return nil, nil, false
+	default:
+		return linksOrder, nil, true
+	}
+}
+
+// covers returns true if all the links indexed with the looked up value
+// match the filter, in which case links don't need to be read to be
+// counted.
+func (idx *orderedIndex) covers(filter *store.SegmentFilter) bool {
+	f := *filter
+	f.Pagination = store.Pagination{}
+	f.Reverse = false
+	switch idx {
+	case mapIDOrder:
+		f.MapIDs = nil
+	case processOrder:
+		f.Process = ""
+	}
+
+	return reflect.DeepEqual(f, store.SegmentFilter{})
+}
@@ -0,0 +1,191 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boltstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-chainscript/chainscripttest"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestCandidates(t *testing.T) {
+	ctx := context.Background()
+	a, err := createBoltStore()
+	require.NoError(t, err)
+	defer freeBoltStore(a)
+
+	parent := chainscripttest.NewLinkBuilder(t).
+		WithProcess("p").
+		WithMapID("m").
+		WithStep("s1").
+		WithTags("a", "ab").
+		Build()
+	parentHash, err := a.CreateLink(ctx, parent)
+	require.NoError(t, err)
+
+	child := chainscripttest.NewLinkBuilder(t).
+		WithParent(t, parent).
+		WithProcess("p").
+		WithMapID("m").
+		WithStep("s2").
+		WithTags("ab").
+		WithRef(t, parent).
+		Build()
+	childHash, err := a.CreateLink(ctx, child)
+	require.NoError(t, err)
+
+	other := chainscripttest.NewLinkBuilder(t).
+		WithProcess("p2").
+		WithMapID("m2").
+		WithStep("s1").
+		Build()
+	otherHash, err := a.CreateLink(ctx, other)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		filter   *store.SegmentFilter
+		expected []chainscript.LinkHash
+	}{{
+		"link hashes",
+		&store.SegmentFilter{LinkHashes: []chainscript.LinkHash{otherHash}},
+		[]chainscript.LinkHash{otherHash},
+	}, {
+		"previous link hash",
+		&store.SegmentFilter{PrevLinkHash: parentHash},
+		[]chainscript.LinkHash{childHash},
+	}, {
+		"referencing",
+		&store.SegmentFilter{Referencing: parentHash},
+		[]chainscript.LinkHash{childHash},
+	}, {
+		"map IDs",
+		&store.SegmentFilter{MapIDs: []string{"m", "m2"}},
+		[]chainscript.LinkHash{parentHash, childHash, otherHash},
+	}, {
+		// Links tagged "ab" must not be found when looking up "a".
+		"tag",
+		&store.SegmentFilter{Tags: []string{"a"}},
+		[]chainscript.LinkHash{parentHash},
+	}, {
+		"step",
+		&store.SegmentFilter{Step: "s1"},
+		[]chainscript.LinkHash{parentHash, otherHash},
+	}, {
+		"process",
+		&store.SegmentFilter{Process: "p"},
+		[]chainscript.LinkHash{parentHash, childHash},
+	}, {
+		"any tag without duplicates",
+		&store.SegmentFilter{TagsAny: []string{"a", "ab"}},
+		[]chainscript.LinkHash{parentHash, childHash},
+	}}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := a.db.View(func(tx *bolt.Tx) error {
+				linkHashes, indexed := candidates(tx, tt.filter)
+				assert.True(t, indexed)
+				assert.ElementsMatch(t, tt.expected, linkHashes)
+				return nil
+			})
+			require.NoError(t, err)
+		})
+	}
+
+	t.Run("without index", func(t *testing.T) {
+		err := a.db.View(func(tx *bolt.Tx) error {
+			_, indexed := candidates(tx, &store.SegmentFilter{WithoutParent: true})
+			assert.False(t, indexed)
+			return nil
+		})
+		require.NoError(t, err)
+	})
+}
+
+func TestOrderedIndexes(t *testing.T) {
+	ctx := context.Background()
+	a, err := createBoltStore()
+	require.NoError(t, err)
+	defer freeBoltStore(a)
+
+	var segments types.SegmentSlice
+	for _, priority := range []float64{-2.5, -1, 0, 0, 0.5, 1, 3, 3, 42} {
+		for _, mapID := range []string{"m1", "m2"} {
+			link := chainscripttest.NewLinkBuilder(t).
+				WithProcess("p").
+				WithMapID(mapID).
+				WithPriority(priority).
+				Build()
+			_, err := a.CreateLink(ctx, link)
+			require.NoError(t, err)
+
+			if mapID == "m1" {
+				segment, err := link.Segmentify()
+				require.NoError(t, err)
+				segments = append(segments, segment)
+			}
+		}
+	}
+
+	// readPages reads all the segments of map m1 a few at a time.
+	readPages := func(t *testing.T, reverse bool) types.SegmentSlice {
+		var found types.SegmentSlice
+		filter := &store.SegmentFilter{
+			Pagination: store.Pagination{Limit: 4},
+			MapIDs:     []string{"m1"},
+			Reverse:    reverse,
+		}
+
+		for {
+			page, err := a.FindSegments(ctx, filter)
+			require.NoError(t, err)
+			assert.Equal(t, len(segments), page.TotalCount)
+
+			found = append(found, page.Segments...)
+			if page.NextCursor == "" {
+				return found
+			}
+
+			filter.Cursor = page.NextCursor
+		}
+	}
+
+	linkHashes := func(segments types.SegmentSlice) []chainscript.LinkHash {
+		var linkHashes []chainscript.LinkHash
+		for _, s := range segments {
+			linkHashes = append(linkHashes, s.LinkHash())
+		}
+		return linkHashes
+	}
+
+	t.Run("pages in order", func(t *testing.T) {
+		segments.Sort(false)
+		assert.Equal(t, linkHashes(segments), linkHashes(readPages(t, false)))
+	})
+
+	t.Run("pages in reverse order", func(t *testing.T) {
+		segments.Sort(true)
+		assert.Equal(t, linkHashes(segments), linkHashes(readPages(t, true)))
+	})
+}
@@ -0,0 +1,400 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boltstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+/********** Store reader implementation **********/
+
+// GetSegment implements github.com/stratumn/go-core/store.SegmentReader.GetSegment.
+func (a *BoltStore) GetSegment(ctx context.Context, linkHash chainscript.LinkHash) (*chainscript.Segment, error) {
+	var segment *chainscript.Segment
+	err := a.db.View(func(tx *bolt.Tx) error {
+		link, err := getLink(tx, linkHash)
+		if err != nil || link == nil {
+			return err
+		}
+
+		segment, err = newSegment(tx, link, linkHash)
+		return err
+	})
+
+	return segment, err
+}
+
+// FindSegments implements github.com/stratumn/go-core/store.SegmentReader.FindSegments.
+// Evidences are only loaded for the segments of the returned page.
+// When the filter allows it, links are read in order from an ordered index
+// starting at the cursor, so only the links of the page need to be read.
+func (a *BoltStore) FindSegments(ctx context.Context, filter *store.SegmentFilter) (*types.PaginatedSegments, error) {
+	var page *types.PaginatedSegments
+	err := a.db.View(func(tx *bolt.Tx) error {
+		var err error
+		if idx, value, ok := ordering(filter); ok {
+			page, err = findOrderedSegments(tx, idx, value, filter)
+		} else {
+			page, err = findSortedSegments(tx, filter)
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, segment := range page.Segments {
+			if segment.Meta.Evidences, err = getEvidences(tx, segment.LinkHash()); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// findSortedSegments reads all the links matching the filter and sorts them
+// to find the requested page.
+func findSortedSegments(tx *bolt.Tx, filter *store.SegmentFilter) (*types.PaginatedSegments, error) {
+	segments := &types.PaginatedSegments{}
+	err := forEachMatch(tx, filter, func(link *chainscript.Link, linkHash chainscript.LinkHash) error {
+		segments.Segments = append(segments.Segments, &chainscript.Segment{
+			Link: link,
+			Meta: &chainscript.SegmentMeta{LinkHash: linkHash},
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	segments.TotalCount = len(segments.Segments)
+	segments.Segments.Sort(filter.Reverse)
+
+	return filter.PaginateSegments(segments)
+}
+
+// findOrderedSegments reads the requested page from an ordered index.
+// When the index covers the filter, only the links of the page are read and
+// the total count is computed from the keys of the index. Otherwise all the
+// indexed links need to be read to be counted, but they don't need to be
+// sorted.
+func findOrderedSegments(tx *bolt.Tx, idx *orderedIndex, value []byte, filter *store.SegmentFilter) (*types.PaginatedSegments, error) {
+	var start *store.SegmentCursor
+	skip := filter.Offset
+	if filter.Cursor != "" {
+		cursor, err := store.ParseSegmentCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		start, skip = cursor, 0
+	}
+
+	page := &types.PaginatedSegments{Segments: types.SegmentSlice{}}
+	covered := idx.covers(filter)
+
+	// Uncovered filters need to count the links before the cursor too.
+	from := start
+	if !covered {
+		from = nil
+	}
+
+	err := idx.walk(tx, value, from, filter.Reverse, func(linkHash chainscript.LinkHash) (bool, error) {
+		if covered && skip > 0 {
+			skip--
+			return true, nil
+		}
+
+		full := len(page.Segments) >= filter.Limit
+		if covered && full {
+			return false, nil
+		}
+
+		link, err := getLink(tx, linkHash)
+		if err != nil || link == nil {
+			return err == nil, err
+		}
+
+		if !filter.MatchLink(link) || !filter.MatchCreatedAt(getCreatedAt(tx, linkHash)) {
+			return true, nil
+		}
+
+		page.TotalCount++
+		segment := &chainscript.Segment{
+			Link: link,
+			Meta: &chainscript.SegmentMeta{LinkHash: linkHash},
+		}
+
+		switch {
+		case full:
+		case start != nil && !start.After(segment, filter.Reverse):
+		case skip > 0:
+			skip--
+		default:
+			page.Segments = append(page.Segments, segment)
+		}
+
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if covered {
+		page.TotalCount = idx.count(tx, value)
+	}
+
+	page.NextCursor = store.NextSegmentCursor(page.Segments, filter.Limit)
+	return page, nil
+}
+
+// IterateSegments implements github.com/stratumn/go-core/store.SegmentIterable.IterateSegments.
+// Only the position of the matching segments is kept in memory: segments are
+// read from the database again when the iterator reaches them.
+func (a *BoltStore) IterateSegments(ctx context.Context, filter *store.SegmentFilter) (store.SegmentIterator, error) {
+	var start *store.SegmentCursor
+	if filter.Cursor != "" {
+		cursor, err := store.ParseSegmentCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		start = cursor
+	}
+
+	var positions []*store.SegmentCursor
+	err := a.db.View(func(tx *bolt.Tx) error {
+		return forEachMatch(tx, filter, func(link *chainscript.Link, linkHash chainscript.LinkHash) error {
+			segment := &chainscript.Segment{
+				Link: link,
+				Meta: &chainscript.SegmentMeta{LinkHash: linkHash},
+			}
+
+			if start == nil || start.After(segment, filter.Reverse) {
+				positions = append(positions, store.NewSegmentCursor(segment))
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(positions, func(i, j int) bool {
+		if filter.Reverse {
+			return positions[i].Compare(positions[j]) > 0
+		}
+		return positions[i].Compare(positions[j]) < 0
+	})
+
+	return &segmentIterator{ctx: ctx, adapter: a, positions: positions, current: -1}, nil
+}
+
+// GetMapIDs implements github.com/stratumn/go-core/store.SegmentReader.GetMapIDs.
+func (a *BoltStore) GetMapIDs(ctx context.Context, filter *store.MapFilter) ([]string, error) {
+	mapIDs := []string{}
+	err := a.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(mapsBucket).Cursor()
+		var prefix []byte
+		if filter.Process != "" {
+			c = tx.Bucket(processMapsBucket).Cursor()
+			prefix = indexPrefix([]byte(filter.Process))
+		}
+
+		start := append(prefix, filter.Prefix...)
+		for k, _ := c.Seek(start); k != nil && bytes.HasPrefix(k, start); k, _ = c.Next() {
+			mapID := string(k[len(prefix):])
+			if strings.HasSuffix(mapID, filter.Suffix) {
+				mapIDs = append(mapIDs, mapID)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Keys are sorted bytewise, which is also how sort.Strings sorts.
+	return filter.Pagination.PaginateStrings(mapIDs), nil
+}
+
+// GetEvidences implements github.com/stratumn/go-core/store.EvidenceReader.GetEvidences.
+func (a *BoltStore) GetEvidences(ctx context.Context, linkHash chainscript.LinkHash) (types.EvidenceSlice, error) {
+	var evidences types.EvidenceSlice
+	err := a.db.View(func(tx *bolt.Tx) (err error) {
+		evidences, err = getEvidences(tx, linkHash)
+		return
+	})
+
+	return evidences, err
+}
+
+/********** Utilities **********/
+
+// forEachMatch calls fn with every link that matches the filter.
+// Candidate links are found with an index when the filter allows it.
+func forEachMatch(tx *bolt.Tx, filter *store.SegmentFilter, fn func(*chainscript.Link, chainscript.LinkHash) error) error {
+	match := func(linkHash chainscript.LinkHash, js []byte) error {
+		var link chainscript.Link
+		if err := json.Unmarshal(js, &link); err != nil {
+			return types.WrapError(err, errorcode.InvalidArgument, store.Component, "json.Unmarshal")
+		}
+
+		if !filter.MatchLink(&link) || !filter.MatchCreatedAt(getCreatedAt(tx, linkHash)) {
+			return nil
+		}
+
+		return fn(&link, linkHash)
+	}
+
+	linkHashes, indexed := candidates(tx, filter)
+	if !indexed {
+		return tx.Bucket(linksBucket).ForEach(func(k, v []byte) error {
+			return match(copyBytes(k), v)
+		})
+	}
+
+	links := tx.Bucket(linksBucket)
+	seen := make(map[string]struct{}, len(linkHashes))
+	for _, linkHash := range linkHashes {
+		if _, ok := seen[string(linkHash)]; ok {
+			continue
+		}
+		seen[string(linkHash)] = struct{}{}
+
+		js := links.Get(linkHash)
+		if js == nil {
+			continue
+		}
+
+		if err := match(linkHash, js); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getLink(tx *bolt.Tx, linkHash chainscript.LinkHash) (*chainscript.Link, error) {
+	js := tx.Bucket(linksBucket).Get(linkHash)
+	if js == nil {
+		return nil, nil
+	}
+
+	var link chainscript.Link
+	if err := json.Unmarshal(js, &link); err != nil {
+		return nil, types.WrapError(err, errorcode.InvalidArgument, store.Component, "json.Unmarshal")
+	}
+
+	return &link, nil
+}
+
+func getEvidences(tx *bolt.Tx, linkHash chainscript.LinkHash) (types.EvidenceSlice, error) {
+	evidences := types.EvidenceSlice{}
+
+	js := tx.Bucket(evidencesBucket).Get(linkHash)
+	if len(js) > 0 {
+		if err := json.Unmarshal(js, &evidences); err != nil {
+			return nil, types.WrapError(err, errorcode.InvalidArgument, store.Component, "json.Unmarshal")
+		}
+	}
+
+	return evidences, nil
+}
+
+func getCreatedAt(tx *bolt.Tx, linkHash chainscript.LinkHash) time.Time {
+	v := tx.Bucket(createdAtBucket).Get(linkHash)
+	if len(v) != 8 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, int64(binary.BigEndian.Uint64(v)))
+}
+
+func newSegment(tx *bolt.Tx, link *chainscript.Link, linkHash chainscript.LinkHash) (*chainscript.Segment, error) {
+	evidences, err := getEvidences(tx, linkHash)
+	if err != nil {
+		return nil, err
+	}
+
+	segment, err := link.Segmentify()
+	if err != nil {
+		return nil, types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not segmentify")
+	}
+
+	segment.Meta.Evidences = evidences
+	return segment, nil
+}
+
+// segmentIterator lazily loads the segments at the given positions.
+type segmentIterator struct {
+	ctx       context.Context
+	adapter   *BoltStore
+	positions []*store.SegmentCursor
+	current   int
+	segment   *chainscript.Segment
+	err       error
+}
+
+func (it *segmentIterator) Next() bool {
+	it.segment = nil
+	for it.err == nil && it.current+1 < len(it.positions) {
+		it.current++
+		segment, err := it.adapter.GetSegment(it.ctx, it.positions[it.current].LinkHash)
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		if segment != nil {
+			it.segment = segment
+			return true
+		}
+	}
+
+	return false
+}
+
+func (it *segmentIterator) Segment() *chainscript.Segment {
+	return it.segment
+}
+
+func (it *segmentIterator) Err() error {
+	return it.err
+}
+
+func (it *segmentIterator) Close() error {
+	it.positions, it.segment = nil, nil
+	return nil
+}
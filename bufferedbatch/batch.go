@@ -17,6 +17,8 @@ package bufferedbatch
 import (
 	"bytes"
 	"context"
+	"sort"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stratumn/go-chainscript"
@@ -98,8 +100,11 @@ func (b *Batch) FindSegments(ctx context.Context, filter *store.SegmentFilter) (
 		return nil, err
 	}
 
+	// Links of the batch will be created when it's written, so they can only
+	// match time ranges that include the present.
+	now := time.Now()
 	for _, link := range b.Links {
-		if filter.MatchLink(link) {
+		if filter.MatchLink(link) && filter.MatchCreatedAt(now) {
 			segment, err := link.Segmentify()
 			if err != nil {
 				return nil, types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not segmentify")
@@ -110,7 +115,9 @@ func (b *Batch) FindSegments(ctx context.Context, filter *store.SegmentFilter) (
 		}
 	}
 
-	return filter.Pagination.PaginateSegments(segments), nil
+	segments.Segments.Sort(filter.Reverse)
+
+	return filter.PaginateSegments(segments)
 }
 
 // GetMapIDs returns the union of mapIds in the store and not committed yet.
@@ -141,6 +148,8 @@ func (b *Batch) GetMapIDs(ctx context.Context, filter *store.MapFilter) (_ []str
 		ids = append(ids, k)
 	}
 
+	sort.Strings(ids)
+
 	return filter.Pagination.PaginateStrings(ids), err
 }
 
@@ -0,0 +1,239 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cachedstore implements a read-through cache in front of a store.
+//
+// Links are immutable, so they are kept in an LRU cache until they are
+// evicted. Evidences can be added at any time, so they are only cached for a
+// short time. They are also invalidated when the underlying store notifies
+// that new evidences were saved.
+package cachedstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+)
+
+const (
+	// DefaultLinksCacheSize is the default maximum number of cached links.
+	DefaultLinksCacheSize = 10000
+
+	// DefaultEvidencesCacheSize is the default maximum number of links whose
+	// evidences are cached.
+	DefaultEvidencesCacheSize = 10000
+
+	// DefaultEvidencesTTL is the default duration evidences are cached for.
+	DefaultEvidencesTTL = 5 * time.Second
+
+	// DefaultEventsChanSize is the default size of the channel receiving the
+	// events of the underlying store.
+	DefaultEventsChanSize = 256
+)
+
+// Config contains configuration options for the cache.
+type Config struct {
+	// LinksCacheSize is the maximum number of cached links.
+	// Defaults to DefaultLinksCacheSize.
+	LinksCacheSize int
+
+	// EvidencesCacheSize is the maximum number of links whose evidences are
+	// cached. Defaults to DefaultEvidencesCacheSize.
+	EvidencesCacheSize int
+
+	// EvidencesTTL is the duration evidences are cached for.
+	// Defaults to DefaultEvidencesTTL.
+	EvidencesTTL time.Duration
+
+	// EventsChanSize is the size of the channel receiving the events of the
+	// underlying store. Defaults to DefaultEventsChanSize.
+	EventsChanSize int
+}
+
+// cachedEvidences are the evidences of a link and their expiration time.
+type cachedEvidences struct {
+	evidences types.EvidenceSlice
+	expiresAt time.Time
+}
+
+// CachedStore is a decorator for the store.Adapter interface.
+// It wraps a real store.Adapter implementation and caches GetSegment and
+// GetEvidences results.
+// Cached links are shared between callers and must not be modified.
+type CachedStore struct {
+	store.Adapter
+
+	config    *Config
+	links     *lru
+	evidences *lru
+}
+
+// Wrap wraps an existing store adapter to add caching.
+func Wrap(a store.Adapter, config *Config) *CachedStore {
+	c := *config
+	if c.LinksCacheSize <= 0 {
+		c.LinksCacheSize = DefaultLinksCacheSize
+	}
+	if c.EvidencesCacheSize <= 0 {
+		c.EvidencesCacheSize = DefaultEvidencesCacheSize
+	}
+	if c.EvidencesTTL <= 0 {
+		c.EvidencesTTL = DefaultEvidencesTTL
+	}
+	if c.EventsChanSize <= 0 {
+		c.EventsChanSize = DefaultEventsChanSize
+	}
+
+	cs := &CachedStore{
+		Adapter:   a,
+		config:    &c,
+		links:     newLRU(c.LinksCacheSize),
+		evidences: newLRU(c.EvidencesCacheSize),
+	}
+
+	events := make(chan *store.Event, c.EventsChanSize)
+	a.AddStoreEventChannel(events)
+	go cs.invalidate(events)
+
+	return cs
+}
+
+// invalidate removes the cached evidences of links that received new
+// evidences.
+func (a *CachedStore) invalidate(events <-chan *store.Event) {
+	for e := range events {
+		if e.EventType != store.SavedEvidences {
+			continue
+		}
+
+		for linkHash := range e.Data.(map[string]*chainscript.Evidence) {
+			a.evidences.remove(linkHash)
+		}
+	}
+}
+
+// getLink returns a link from the cache.
+func (a *CachedStore) getLink(linkHash string) *chainscript.Link {
+	if link, ok := a.links.get(linkHash); ok {
+		cacheHit.With(prometheus.Labels{cacheLabel: linksCache}).Inc()
+		return link.(*chainscript.Link)
+	}
+
+	cacheMiss.With(prometheus.Labels{cacheLabel: linksCache}).Inc()
+	return nil
+}
+
+// getEvidences returns the evidences of a link from the cache.
+// The second return value is false if they aren't cached or expired.
+func (a *CachedStore) getEvidences(linkHash string) (types.EvidenceSlice, bool) {
+	if cached, ok := a.evidences.get(linkHash); ok {
+		if e := cached.(*cachedEvidences); time.Now().Before(e.expiresAt) {
+			cacheHit.With(prometheus.Labels{cacheLabel: evidencesCache}).Inc()
+			return e.evidences, true
+		}
+	}
+
+	cacheMiss.With(prometheus.Labels{cacheLabel: evidencesCache}).Inc()
+	return nil, false
+}
+
+func (a *CachedStore) setEvidences(linkHash string, evidences types.EvidenceSlice) {
+	a.evidences.add(linkHash, &cachedEvidences{
+		evidences: evidences,
+		expiresAt: time.Now().Add(a.config.EvidencesTTL),
+	})
+}
+
+// GetSegment returns a segment from the cache or delegates the call to the
+// underlying store.
+func (a *CachedStore) GetSegment(ctx context.Context, linkHash chainscript.LinkHash) (*chainscript.Segment, error) {
+	key := linkHash.String()
+
+	link := a.getLink(key)
+	if link == nil {
+		segment, err := a.Adapter.GetSegment(ctx, linkHash)
+		if err != nil || segment == nil {
+			return segment, err
+		}
+
+		a.links.add(key, segment.Link)
+		a.setEvidences(key, segment.Meta.Evidences)
+
+		return segment, nil
+	}
+
+	evidences, err := a.GetEvidences(ctx, linkHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &chainscript.Segment{
+		Link: link,
+		Meta: &chainscript.SegmentMeta{
+			LinkHash:  linkHash,
+			Evidences: evidences,
+		},
+	}, nil
+}
+
+// GetEvidences returns the evidences of a link from the cache or delegates
+// the call to the underlying store.
+func (a *CachedStore) GetEvidences(ctx context.Context, linkHash chainscript.LinkHash) (types.EvidenceSlice, error) {
+	key := linkHash.String()
+
+	if evidences, ok := a.getEvidences(key); ok {
+		return evidences, nil
+	}
+
+	evidences, err := a.Adapter.GetEvidences(ctx, linkHash)
+	if err != nil {
+		return nil, err
+	}
+
+	a.setEvidences(key, evidences)
+
+	return evidences, nil
+}
+
+// AddEvidence delegates the call to the underlying store and invalidates the
+// cached evidences of the link.
+func (a *CachedStore) AddEvidence(ctx context.Context, linkHash chainscript.LinkHash, evidence *chainscript.Evidence) error {
+	defer a.evidences.remove(linkHash.String())
+
+	return a.Adapter.AddEvidence(ctx, linkHash, evidence)
+}
+
+// IterateSegments delegates the call to the underlying store.
+func (a *CachedStore) IterateSegments(ctx context.Context, filter *store.SegmentFilter) (store.SegmentIterator, error) {
+	return store.IterateSegments(ctx, a.Adapter, filter)
+}
+
+// GetAncestors delegates the call to the underlying store.
+func (a *CachedStore) GetAncestors(ctx context.Context, linkHash chainscript.LinkHash, depth int) (types.SegmentSlice, error) {
+	return store.GetAncestors(ctx, a.Adapter, linkHash, depth)
+}
+
+// GetDescendants delegates the call to the underlying store.
+func (a *CachedStore) GetDescendants(ctx context.Context, linkHash chainscript.LinkHash, depth int) (types.SegmentSlice, error) {
+	return store.GetDescendants(ctx, a.Adapter, linkHash, depth)
+}
+
+// GetMapHeads delegates the call to the underlying store.
+func (a *CachedStore) GetMapHeads(ctx context.Context, process, mapID string) (types.SegmentSlice, error) {
+	return store.GetMapHeads(ctx, a.Adapter, process, mapID)
+}
@@ -0,0 +1,159 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cachedstore
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-chainscript/chainscripttest"
+	"github.com/stratumn/go-core/dummystore"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/store/storetestcases"
+	"github.com/stratumn/go-core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingStore counts the reads that reach the underlying store.
+type countingStore struct {
+	*dummystore.DummyStore
+	segmentReads  int32
+	evidenceReads int32
+}
+
+func (s *countingStore) GetSegment(ctx context.Context, linkHash chainscript.LinkHash) (*chainscript.Segment, error) {
+	atomic.AddInt32(&s.segmentReads, 1)
+	return s.DummyStore.GetSegment(ctx, linkHash)
+}
+
+func (s *countingStore) GetEvidences(ctx context.Context, linkHash chainscript.LinkHash) (types.EvidenceSlice, error) {
+	atomic.AddInt32(&s.evidenceReads, 1)
+	return s.DummyStore.GetEvidences(ctx, linkHash)
+}
+
+func (s *countingStore) reads() (int, int) {
+	return int(atomic.LoadInt32(&s.segmentReads)), int(atomic.LoadInt32(&s.evidenceReads))
+}
+
+func TestCachedStore(t *testing.T) {
+	storetestcases.Factory{
+		New: func() (store.Adapter, error) {
+			return Wrap(dummystore.New(&dummystore.Config{}), &Config{}), nil
+		},
+	}.RunStoreTests(t)
+}
+
+func TestCachedStore_GetSegment(t *testing.T) {
+	ctx := context.Background()
+
+	underlying := &countingStore{DummyStore: dummystore.New(&dummystore.Config{})}
+	a := Wrap(underlying, &Config{EvidencesTTL: time.Hour})
+
+	link := chainscripttest.RandomLink(t)
+	linkHash, err := a.CreateLink(ctx, link)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		s, err := a.GetSegment(ctx, linkHash)
+		require.NoError(t, err)
+		require.NotNil(t, s)
+		chainscripttest.LinksEqual(t, link, s.Link)
+		assert.Equal(t, linkHash, s.LinkHash())
+	}
+
+	segmentReads, evidenceReads := underlying.reads()
+	assert.Equal(t, 1, segmentReads)
+	assert.Equal(t, 0, evidenceReads)
+
+	t.Run("missing segments aren't cached", func(t *testing.T) {
+		missing := chainscripttest.RandomHash()
+		for i := 0; i < 2; i++ {
+			s, err := a.GetSegment(ctx, missing)
+			require.NoError(t, err)
+			assert.Nil(t, s)
+		}
+
+		segmentReads, _ := underlying.reads()
+		assert.Equal(t, 3, segmentReads)
+	})
+}
+
+func TestCachedStore_evidences(t *testing.T) {
+	ctx := context.Background()
+
+	underlying := &countingStore{DummyStore: dummystore.New(&dummystore.Config{})}
+	a := Wrap(underlying, &Config{EvidencesTTL: time.Hour})
+
+	linkHash, err := a.CreateLink(ctx, chainscripttest.RandomLink(t))
+	require.NoError(t, err)
+
+	_, err = a.GetSegment(ctx, linkHash)
+	require.NoError(t, err)
+
+	t.Run("invalidated by AddEvidence", func(t *testing.T) {
+		require.NoError(t, a.AddEvidence(ctx, linkHash, chainscripttest.RandomEvidence(t)))
+
+		s, err := a.GetSegment(ctx, linkHash)
+		require.NoError(t, err)
+		assert.Len(t, s.Meta.Evidences, 1)
+	})
+
+	t.Run("invalidated by store events", func(t *testing.T) {
+		// Evidences added directly to the underlying store are only seen
+		// through its events.
+		require.NoError(t, underlying.AddEvidence(ctx, linkHash, chainscripttest.RandomEvidence(t)))
+
+		for i := 0; ; i++ {
+			evidences, err := a.GetEvidences(ctx, linkHash)
+			require.NoError(t, err)
+			if len(evidences) == 2 {
+				break
+			}
+
+			require.True(t, i < 100, "evidences should be invalidated")
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		a := Wrap(underlying, &Config{EvidencesTTL: time.Millisecond})
+
+		_, err := a.GetEvidences(ctx, linkHash)
+		require.NoError(t, err)
+		_, before := underlying.reads()
+
+		time.Sleep(5 * time.Millisecond)
+
+		_, err = a.GetEvidences(ctx, linkHash)
+		require.NoError(t, err)
+		_, after := underlying.reads()
+		assert.Equal(t, before+1, after)
+	})
+}
+
+func TestWrapWithFlags(t *testing.T) {
+	a := dummystore.New(&dummystore.Config{})
+
+	linksCacheSize = 0
+	assert.Equal(t, store.Adapter(a), WrapWithFlags(a))
+
+	linksCacheSize = 10
+	defer func() { linksCacheSize = 0 }()
+	assert.IsType(t, &CachedStore{}, WrapWithFlags(a))
+}
@@ -0,0 +1,48 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cachedstore
+
+import (
+	"flag"
+	"time"
+
+	"github.com/stratumn/go-core/store"
+)
+
+var (
+	linksCacheSize int
+	evidencesTTL   time.Duration
+)
+
+// RegisterFlags registers the flags used by WrapWithFlags.
+func RegisterFlags() {
+	flag.IntVar(&linksCacheSize, "cache_links", 0, "Maximum number of cached links (0 disables the cache)")
+	flag.DurationVar(&evidencesTTL, "cache_evidences_ttl", DefaultEvidencesTTL, "Duration evidences are cached for")
+}
+
+// WrapWithFlags should be called after RegisterFlags and flag.Parse to wrap a
+// store with a cache configured using flag values.
+// The store is returned unchanged if the cache is disabled.
+func WrapWithFlags(a store.Adapter) store.Adapter {
+	if linksCacheSize <= 0 {
+		return a
+	}
+
+	return Wrap(a, &Config{
+		LinksCacheSize:     linksCacheSize,
+		EvidencesCacheSize: linksCacheSize,
+		EvidencesTTL:       evidencesTTL,
+	})
+}
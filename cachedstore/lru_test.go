@@ -0,0 +1,48 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cachedstore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRU(t *testing.T) {
+	c := newLRU(2)
+
+	c.add("a", 1)
+	c.add("b", 2)
+
+	// Reading "a" makes "b" the least recently used entry.
+	v, ok := c.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	c.add("c", 3)
+	assert.Equal(t, 2, c.len())
+
+	_, ok = c.get("b")
+	assert.False(t, ok, "b should be evicted")
+
+	c.add("a", 4)
+	v, _ = c.get("a")
+	assert.Equal(t, 4, v)
+
+	c.remove("a")
+	_, ok = c.get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 1, c.len())
+}
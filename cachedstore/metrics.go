@@ -0,0 +1,55 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cachedstore
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/stratumn/go-core/monitoring"
+)
+
+const (
+	cacheLabel = "cache"
+
+	linksCache     = "links"
+	evidencesCache = "evidences"
+)
+
+var (
+	cacheHit  *prometheus.CounterVec
+	cacheMiss *prometheus.CounterVec
+)
+
+func init() {
+	cacheHit = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: monitoring.Stratumn,
+			Subsystem: "store",
+			Name:      "cache_hit",
+			Help:      "number of store reads served from the cache",
+		},
+		[]string{cacheLabel},
+	)
+
+	cacheMiss = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: monitoring.Stratumn,
+			Subsystem: "store",
+			Name:      "cache_miss",
+			Help:      "number of store reads that missed the cache",
+		},
+		[]string{cacheLabel},
+	)
+}
@@ -0,0 +1,89 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The command boltstore starts a storehttp server with a boltstore.
+package main
+
+import (
+	"flag"
+
+	"github.com/stratumn/go-core/boltstore"
+	"github.com/stratumn/go-core/cachedstore"
+	"github.com/stratumn/go-core/eventlog"
+	"github.com/stratumn/go-core/monitoring"
+	"github.com/stratumn/go-core/store/storearchive"
+	"github.com/stratumn/go-core/store/storegrpc"
+	"github.com/stratumn/go-core/store/storehttp"
+	"github.com/stratumn/go-core/store/storewebhook"
+	// Registers the verifier of Tendermint evidences.
+	_ "github.com/stratumn/go-core/tmpop/evidences"
+	"github.com/stratumn/go-core/validation"
+)
+
+var (
+	path           = flag.String("path", boltstore.DefaultPath, "Path of the database file")
+	uniqueMapEntry = flag.Bool("uniquemapentry", false, "enforce unicity of the first link in each process map")
+	version        = "x.x.x"
+	commit         = "00000000000000000000000000000000"
+)
+
+func init() {
+	storehttp.RegisterFlags()
+	storegrpc.RegisterFlags()
+	storewebhook.RegisterFlags()
+	storearchive.RegisterFlags()
+	cachedstore.RegisterFlags()
+	eventlog.RegisterFlags()
+	monitoring.RegisterFlags()
+	validation.RegisterFlags()
+
+	monitoring.SetVersion(version, commit)
+}
+
+func main() {
+	flag.Parse()
+
+	monitoring.LogEntry().Infof("%s v%s@%s", boltstore.Description, version, commit[:7])
+
+	s, err := boltstore.New(&boltstore.Config{
+		Path:    *path,
+		Version: version,
+		Commit:  commit,
+	})
+	if err != nil {
+		monitoring.LogEntry().Fatal(err)
+	}
+
+	if *uniqueMapEntry {
+		if err := s.EnforceUniqueMapEntry(); err != nil {
+			monitoring.LogEntry().Fatal(err)
+		}
+	}
+
+	storearchive.RunWithFlags(s)
+
+	a, err := validation.WrapStoreWithConfigFile(s, validation.ConfigurationFromFlags())
+	if err != nil {
+		monitoring.LogEntry().Fatal(err)
+	}
+
+	adapter, err := eventlog.WrapWithFlags(monitoring.WrapStore(cachedstore.WrapWithFlags(a), "boltstore"), s)
+	if err != nil {
+		monitoring.LogEntry().Fatal(err)
+	}
+
+	storewebhook.RunWithFlags(adapter)
+	storegrpc.RunWithFlags(adapter)
+	storehttp.RunWithFlags(adapter)
+}
@@ -20,11 +20,18 @@ import (
 	"flag"
 	"time"
 
+	"github.com/stratumn/go-core/cachedstore"
 	"github.com/stratumn/go-core/couchstore"
+	"github.com/stratumn/go-core/eventlog"
 	"github.com/stratumn/go-core/monitoring"
 	"github.com/stratumn/go-core/monitoring/errorcode"
 	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/store/storearchive"
+	"github.com/stratumn/go-core/store/storegrpc"
 	"github.com/stratumn/go-core/store/storehttp"
+	"github.com/stratumn/go-core/store/storewebhook"
+	// Registers the verifier of Tendermint evidences.
+	_ "github.com/stratumn/go-core/tmpop/evidences"
 	"github.com/stratumn/go-core/types"
 	"github.com/stratumn/go-core/util"
 	"github.com/stratumn/go-core/validation"
@@ -38,6 +45,11 @@ var (
 
 func init() {
 	storehttp.RegisterFlags()
+	storegrpc.RegisterFlags()
+	storewebhook.RegisterFlags()
+	storearchive.RegisterFlags()
+	cachedstore.RegisterFlags()
+	eventlog.RegisterFlags()
 	monitoring.RegisterFlags()
 	validation.RegisterFlags()
 
@@ -77,10 +89,20 @@ func main() {
 		monitoring.LogEntry().Fatal(storeErr)
 	}
 
+	storearchive.RunWithFlags(a)
+	kv := a.(store.KeyValueStore)
+
 	a, err = validation.WrapStoreWithConfigFile(a, validation.ConfigurationFromFlags())
 	if err != nil {
 		monitoring.LogEntry().Fatal(err)
 	}
 
-	storehttp.RunWithFlags(monitoring.WrapStore(a, "couchstore"))
+	adapter, err := eventlog.WrapWithFlags(monitoring.WrapStore(cachedstore.WrapWithFlags(a), "couchstore"), kv)
+	if err != nil {
+		monitoring.LogEntry().Fatal(err)
+	}
+
+	storewebhook.RunWithFlags(adapter)
+	storegrpc.RunWithFlags(adapter)
+	storehttp.RunWithFlags(adapter)
 }
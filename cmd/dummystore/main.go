@@ -16,21 +16,36 @@
 package main
 
 import (
+	"context"
 	"flag"
 
+	"github.com/stratumn/go-core/cachedstore"
 	"github.com/stratumn/go-core/dummystore"
+	"github.com/stratumn/go-core/eventlog"
 	"github.com/stratumn/go-core/monitoring"
+	"github.com/stratumn/go-core/store/storearchive"
+	"github.com/stratumn/go-core/store/storegrpc"
 	"github.com/stratumn/go-core/store/storehttp"
+	"github.com/stratumn/go-core/store/storewebhook"
+	// Registers the verifier of Tendermint evidences.
+	_ "github.com/stratumn/go-core/tmpop/evidences"
 	"github.com/stratumn/go-core/validation"
 )
 
 var (
-	version = "x.x.x"
-	commit  = "00000000000000000000000000000000"
+	path             = flag.String("path", "", "Directory where snapshots are saved (the store is only kept in memory if empty)")
+	snapshotInterval = flag.Duration("snapshotinterval", 0, "Interval between snapshots (if zero, only on startup and shutdown)")
+	version          = "x.x.x"
+	commit           = "00000000000000000000000000000000"
 )
 
 func init() {
 	storehttp.RegisterFlags()
+	storegrpc.RegisterFlags()
+	storewebhook.RegisterFlags()
+	storearchive.RegisterFlags()
+	cachedstore.RegisterFlags()
+	eventlog.RegisterFlags()
 	monitoring.RegisterFlags()
 	validation.RegisterFlags()
 
@@ -41,13 +56,30 @@ func main() {
 	flag.Parse()
 	monitoring.LogEntry().Infof("%s v%s@%s", dummystore.Description, version, commit[:7])
 
-	a, err := validation.WrapStoreWithConfigFile(
-		dummystore.New(&dummystore.Config{Version: version, Commit: commit}),
-		validation.ConfigurationFromFlags(),
-	)
+	s, err := dummystore.Open(&dummystore.Config{
+		Version:          version,
+		Commit:           commit,
+		Path:             *path,
+		SnapshotInterval: *snapshotInterval,
+	})
 	if err != nil {
 		monitoring.LogEntry().Fatal(err)
 	}
 
-	storehttp.RunWithFlags(monitoring.WrapStore(a, "dummystore"))
+	storearchive.RunWithFlags(s)
+
+	a, err := validation.WrapStoreWithConfigFile(s, validation.ConfigurationFromFlags())
+	if err != nil {
+		monitoring.LogEntry().Fatal(err)
+	}
+
+	adapter, err := eventlog.WrapWithFlags(monitoring.WrapStore(cachedstore.WrapWithFlags(a), "dummystore"), s)
+	if err != nil {
+		monitoring.LogEntry().Fatal(err)
+	}
+
+	storewebhook.RunWithFlags(adapter)
+	storegrpc.RunWithFlags(adapter)
+	storehttp.OnShutdown(func(context.Context) error { return s.Close() })
+	storehttp.RunWithFlags(adapter)
 }
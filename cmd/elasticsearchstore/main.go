@@ -19,9 +19,16 @@ package main
 import (
 	"flag"
 
+	"github.com/stratumn/go-core/cachedstore"
 	"github.com/stratumn/go-core/elasticsearchstore"
+	"github.com/stratumn/go-core/eventlog"
 	"github.com/stratumn/go-core/monitoring"
+	"github.com/stratumn/go-core/store/storearchive"
+	"github.com/stratumn/go-core/store/storegrpc"
 	"github.com/stratumn/go-core/store/storehttp"
+	"github.com/stratumn/go-core/store/storewebhook"
+	// Registers the verifier of Tendermint evidences.
+	_ "github.com/stratumn/go-core/tmpop/evidences"
 	"github.com/stratumn/go-core/validation"
 )
 
@@ -32,6 +39,11 @@ var (
 
 func init() {
 	storehttp.RegisterFlags()
+	storegrpc.RegisterFlags()
+	storewebhook.RegisterFlags()
+	storearchive.RegisterFlags()
+	cachedstore.RegisterFlags()
+	eventlog.RegisterFlags()
 	elasticsearchstore.RegisterFlags()
 	monitoring.RegisterFlags()
 	validation.RegisterFlags()
@@ -43,13 +55,20 @@ func main() {
 	flag.Parse()
 	monitoring.LogEntry().Infof("%s v%s@%s", elasticsearchstore.Description, version, commit[:7])
 
-	a, err := validation.WrapStoreWithConfigFile(
-		elasticsearchstore.InitializeWithFlags(version, commit),
-		validation.ConfigurationFromFlags(),
-	)
+	s := elasticsearchstore.InitializeWithFlags(version, commit)
+	storearchive.RunWithFlags(s)
+
+	a, err := validation.WrapStoreWithConfigFile(s, validation.ConfigurationFromFlags())
+	if err != nil {
+		monitoring.LogEntry().Fatal(err)
+	}
+
+	adapter, err := eventlog.WrapWithFlags(monitoring.WrapStore(cachedstore.WrapWithFlags(a), "elasticsearchstore"), s)
 	if err != nil {
 		monitoring.LogEntry().Fatal(err)
 	}
 
-	storehttp.RunWithFlags(monitoring.WrapStore(a, "elasticsearchstore"))
+	storewebhook.RunWithFlags(adapter)
+	storegrpc.RunWithFlags(adapter)
+	storehttp.RunWithFlags(adapter)
 }
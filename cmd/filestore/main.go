@@ -18,10 +18,17 @@ package main
 import (
 	"flag"
 
+	"github.com/stratumn/go-core/cachedstore"
+	"github.com/stratumn/go-core/eventlog"
 	"github.com/stratumn/go-core/filestore"
 	"github.com/stratumn/go-core/monitoring"
 	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/store/storearchive"
+	"github.com/stratumn/go-core/store/storegrpc"
 	"github.com/stratumn/go-core/store/storehttp"
+	"github.com/stratumn/go-core/store/storewebhook"
+	// Registers the verifier of Tendermint evidences.
+	_ "github.com/stratumn/go-core/tmpop/evidences"
 	"github.com/stratumn/go-core/validation"
 )
 
@@ -33,6 +40,11 @@ var (
 
 func init() {
 	storehttp.RegisterFlags()
+	storegrpc.RegisterFlags()
+	storewebhook.RegisterFlags()
+	storearchive.RegisterFlags()
+	cachedstore.RegisterFlags()
+	eventlog.RegisterFlags()
 	monitoring.RegisterFlags()
 	validation.RegisterFlags()
 
@@ -56,10 +68,20 @@ func main() {
 		monitoring.LogEntry().Fatal(err)
 	}
 
+	storearchive.RunWithFlags(a)
+	kv := a.(store.KeyValueStore)
+
 	a, err = validation.WrapStoreWithConfigFile(a, validation.ConfigurationFromFlags())
 	if err != nil {
 		monitoring.LogEntry().Fatal(err)
 	}
 
-	storehttp.RunWithFlags(monitoring.WrapStore(a, "filestore"))
+	adapter, err := eventlog.WrapWithFlags(monitoring.WrapStore(cachedstore.WrapWithFlags(a), "filestore"), kv)
+	if err != nil {
+		monitoring.LogEntry().Fatal(err)
+	}
+
+	storewebhook.RunWithFlags(adapter)
+	storegrpc.RunWithFlags(adapter)
+	storehttp.RunWithFlags(adapter)
 }
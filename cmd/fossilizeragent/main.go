@@ -0,0 +1,130 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The command fossilizeragent fossilizes the links of a remote store with a
+// remote fossilizer and adds the resulting evidences to the store.
+//
+//	fossilizeragent -store_url http://localhost:5000 -fossilizer_url http://localhost:6000 -checkpoint /var/fossilizeragent/checkpoint.json
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+
+	"github.com/stratumn/go-core/fossilizer/fossilizeragent"
+	"github.com/stratumn/go-core/fossilizer/fossilizerhttp/fossilizerhttpclient"
+	"github.com/stratumn/go-core/monitoring"
+	"github.com/stratumn/go-core/store/storehttp/storehttpclient"
+	"github.com/stratumn/go-core/util"
+)
+
+const (
+	// DefaultMetricsPort is the default port used to expose metrics.
+	DefaultMetricsPort = 5091
+)
+
+var (
+	storeURL         = flag.String("store_url", "http://localhost:5000", "URL of the store server")
+	fossilizerURL    = flag.String("fossilizer_url", "http://localhost:6000", "URL of the fossilizer server")
+	checkpointPath   = flag.String("checkpoint", "", "Path of the file where the progress of the agent is saved (kept in memory if empty)")
+	pollInterval     = flag.Duration("poll_interval", fossilizeragent.DefaultPollInterval, "Interval between two reads of the store event log")
+	resubmitInterval = flag.Duration("resubmit_interval", fossilizeragent.DefaultResubmitInterval, "Delay after which a link is fossilized again if its evidence hasn't been received")
+	version          = "x.x.x"
+	commit           = "00000000000000000000000000000000"
+)
+
+func init() {
+	monitoring.RegisterFlags()
+
+	monitoring.SetVersion(version, commit)
+}
+
+// exposeMetrics configures metrics and traces exporters and
+// exposes them to collectors.
+func exposeMetrics(config *monitoring.Config) error {
+	if !config.Monitor {
+		return nil
+	}
+
+	if config.MetricsPort == 0 {
+		config.MetricsPort = DefaultMetricsPort
+	}
+
+	metricsHandler, err := monitoring.Configure(config, "fossilizeragent")
+	if err != nil {
+		return err
+	}
+
+	if metricsHandler != nil {
+		metricsAddr := fmt.Sprintf(":%d", config.MetricsPort)
+
+		monitoring.LogEntry().Infof("Exposing metrics on %s", metricsAddr)
+		http.Handle("/metrics", metricsHandler)
+		go func() {
+			if err := http.ListenAndServe(metricsAddr, nil); err != nil {
+				monitoring.LogEntry().WithField("error", err).Fatal("Failed to expose metrics")
+			}
+		}()
+	}
+
+	return nil
+}
+
+func main() {
+	flag.Parse()
+
+	ctx := context.Background()
+	ctx = util.CancelOnInterrupt(ctx)
+
+	monitoring.LogEntry().Infof("Stratumn's fossilizer agent v%s@%s", version, commit[:7])
+
+	if err := exposeMetrics(monitoring.ConfigurationFromFlags()); err != nil {
+		monitoring.LogEntry().WithField("error", err).Fatal("Failed to configure monitoring")
+	}
+
+	s, err := storehttpclient.New(&storehttpclient.Config{URL: *storeURL})
+	if err != nil {
+		monitoring.LogEntry().WithField("error", err).Fatal("Failed to create store client")
+	}
+	defer s.Close()
+
+	f, err := fossilizerhttpclient.New(&fossilizerhttpclient.Config{URL: *fossilizerURL})
+	if err != nil {
+		monitoring.LogEntry().WithField("error", err).Fatal("Failed to create fossilizer client")
+	}
+	defer f.Close()
+
+	// The store isn't wrapped with monitoring since it would hide its event
+	// log.
+	agent, err := fossilizeragent.New(
+		s,
+		monitoring.NewFossilizerAdapter(f, "fossilizerhttpclient"),
+		&fossilizeragent.Config{
+			CheckpointPath:   *checkpointPath,
+			PollInterval:     *pollInterval,
+			ResubmitInterval: *resubmitInterval,
+		},
+	)
+	if err != nil {
+		monitoring.LogEntry().WithField("error", err).Fatal("Failed to create fossilizer agent")
+	}
+
+	if err := agent.Run(ctx); err != nil {
+		monitoring.LogEntry().WithField("error", err).Fatal("Fossilizer agent failed")
+	}
+
+	monitoring.LogEntry().Info("Fossilizer agent stopped")
+}
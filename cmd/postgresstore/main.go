@@ -13,14 +13,26 @@
 // limitations under the License.
 
 // The command postgresstore starts an HTTP server with a postgresstore.
+//
+// It can also manage the database schema:
+//
+//	postgresstore [flags] migrate [-dryrun]
+//	postgresstore [flags] status
 package main
 
 import (
 	"flag"
 
+	"github.com/stratumn/go-core/cachedstore"
+	"github.com/stratumn/go-core/eventlog"
 	"github.com/stratumn/go-core/monitoring"
 	"github.com/stratumn/go-core/postgresstore"
+	"github.com/stratumn/go-core/store/storearchive"
+	"github.com/stratumn/go-core/store/storegrpc"
 	"github.com/stratumn/go-core/store/storehttp"
+	"github.com/stratumn/go-core/store/storewebhook"
+	// Registers the verifier of Tendermint evidences.
+	_ "github.com/stratumn/go-core/tmpop/evidences"
 	"github.com/stratumn/go-core/validation"
 )
 
@@ -31,6 +43,11 @@ var (
 
 func init() {
 	storehttp.RegisterFlags()
+	storegrpc.RegisterFlags()
+	storewebhook.RegisterFlags()
+	storearchive.RegisterFlags()
+	cachedstore.RegisterFlags()
+	eventlog.RegisterFlags()
 	postgresstore.RegisterFlags()
 	monitoring.RegisterFlags()
 	validation.RegisterFlags()
@@ -43,13 +60,22 @@ func main() {
 
 	monitoring.LogEntry().Infof("%s v%s@%s", postgresstore.Description, version, commit[:7])
 
-	a, err := validation.WrapStoreWithConfigFile(
-		postgresstore.InitializeWithFlags(version, commit),
-		validation.ConfigurationFromFlags(),
-	)
+	postgresstore.RunCommandWithFlags(version, commit)
+
+	s := postgresstore.InitializeWithFlags(version, commit)
+	storearchive.RunWithFlags(s)
+
+	a, err := validation.WrapStoreWithConfigFile(s, validation.ConfigurationFromFlags())
+	if err != nil {
+		monitoring.LogEntry().Fatal(err)
+	}
+
+	adapter, err := eventlog.WrapWithFlags(monitoring.WrapStore(cachedstore.WrapWithFlags(a), "postgresstore"), s)
 	if err != nil {
 		monitoring.LogEntry().Fatal(err)
 	}
 
-	storehttp.RunWithFlags(monitoring.WrapStore(a, "postgresstore"))
+	storewebhook.RunWithFlags(adapter)
+	storegrpc.RunWithFlags(adapter)
+	storehttp.RunWithFlags(adapter)
 }
@@ -18,9 +18,16 @@ package main
 import (
 	"flag"
 
+	"github.com/stratumn/go-core/cachedstore"
+	"github.com/stratumn/go-core/eventlog"
 	"github.com/stratumn/go-core/monitoring"
 	"github.com/stratumn/go-core/rethinkstore"
+	"github.com/stratumn/go-core/store/storearchive"
+	"github.com/stratumn/go-core/store/storegrpc"
 	"github.com/stratumn/go-core/store/storehttp"
+	"github.com/stratumn/go-core/store/storewebhook"
+	// Registers the verifier of Tendermint evidences.
+	_ "github.com/stratumn/go-core/tmpop/evidences"
 	"github.com/stratumn/go-core/validation"
 )
 
@@ -31,6 +38,11 @@ var (
 
 func init() {
 	storehttp.RegisterFlags()
+	storegrpc.RegisterFlags()
+	storewebhook.RegisterFlags()
+	storearchive.RegisterFlags()
+	cachedstore.RegisterFlags()
+	eventlog.RegisterFlags()
 	rethinkstore.RegisterFlags()
 	monitoring.RegisterFlags()
 	validation.RegisterFlags()
@@ -43,13 +55,20 @@ func main() {
 
 	monitoring.LogEntry().Infof("%s v%s@%s", rethinkstore.Description, version, commit[:7])
 
-	a, err := validation.WrapStoreWithConfigFile(
-		rethinkstore.InitializeWithFlags(version, commit),
-		validation.ConfigurationFromFlags(),
-	)
+	s := rethinkstore.InitializeWithFlags(version, commit)
+	storearchive.RunWithFlags(s)
+
+	a, err := validation.WrapStoreWithConfigFile(s, validation.ConfigurationFromFlags())
+	if err != nil {
+		monitoring.LogEntry().Fatal(err)
+	}
+
+	adapter, err := eventlog.WrapWithFlags(monitoring.WrapStore(cachedstore.WrapWithFlags(a), "rethinkstore"), s)
 	if err != nil {
 		monitoring.LogEntry().Fatal(err)
 	}
 
-	storehttp.RunWithFlags(monitoring.WrapStore(a, "rethinkstore"))
+	storewebhook.RunWithFlags(adapter)
+	storegrpc.RunWithFlags(adapter)
+	storehttp.RunWithFlags(adapter)
 }
@@ -0,0 +1,74 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The command sqlitestore starts an HTTP server with a sqlitestore.
+package main
+
+import (
+	"flag"
+
+	"github.com/stratumn/go-core/cachedstore"
+	"github.com/stratumn/go-core/eventlog"
+	"github.com/stratumn/go-core/monitoring"
+	"github.com/stratumn/go-core/sqlitestore"
+	"github.com/stratumn/go-core/store/storearchive"
+	"github.com/stratumn/go-core/store/storegrpc"
+	"github.com/stratumn/go-core/store/storehttp"
+	"github.com/stratumn/go-core/store/storewebhook"
+	// Registers the verifier of Tendermint evidences.
+	_ "github.com/stratumn/go-core/tmpop/evidences"
+	"github.com/stratumn/go-core/validation"
+)
+
+var (
+	version = "x.x.x"
+	commit  = "00000000000000000000000000000000"
+)
+
+func init() {
+	storehttp.RegisterFlags()
+	storegrpc.RegisterFlags()
+	storewebhook.RegisterFlags()
+	storearchive.RegisterFlags()
+	cachedstore.RegisterFlags()
+	eventlog.RegisterFlags()
+	sqlitestore.RegisterFlags()
+	monitoring.RegisterFlags()
+	validation.RegisterFlags()
+
+	monitoring.SetVersion(version, commit)
+}
+
+func main() {
+	flag.Parse()
+
+	monitoring.LogEntry().Infof("%s v%s@%s", sqlitestore.Description, version, commit[:7])
+
+	s := sqlitestore.InitializeWithFlags(version, commit)
+	storearchive.RunWithFlags(s)
+
+	a, err := validation.WrapStoreWithConfigFile(s, validation.ConfigurationFromFlags())
+	if err != nil {
+		monitoring.LogEntry().Fatal(err)
+	}
+
+	adapter, err := eventlog.WrapWithFlags(monitoring.WrapStore(cachedstore.WrapWithFlags(a), "sqlitestore"), s)
+	if err != nil {
+		monitoring.LogEntry().Fatal(err)
+	}
+
+	storewebhook.RunWithFlags(adapter)
+	storegrpc.RunWithFlags(adapter)
+	storehttp.RunWithFlags(adapter)
+}
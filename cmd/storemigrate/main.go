@@ -0,0 +1,150 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The command storemigrate copies the content of a store to another store.
+//
+// Stores are given as <type>:<address>, for instance:
+//
+//	storemigrate -source rethinkstore:localhost:28015/sdk -target postgresstore:postgres://postgres@localhost/sdk?sslmode=disable
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/stratumn/go-core/couchstore"
+	"github.com/stratumn/go-core/dummystore"
+	"github.com/stratumn/go-core/elasticsearchstore"
+	"github.com/stratumn/go-core/filestore"
+	"github.com/stratumn/go-core/monitoring"
+	"github.com/stratumn/go-core/postgresstore"
+	"github.com/stratumn/go-core/rethinkstore"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/store/storemigration"
+)
+
+var (
+	source        = flag.String("source", "", "Source store (<type>:<address>)")
+	target        = flag.String("target", "", "Target store (<type>:<address>)")
+	tail          = flag.Bool("tail", false, "Keep applying the source events to the target after the copy until interrupted")
+	checkpointKey = flag.String("checkpoint_key", storemigration.DefaultCheckpointKey, "Key of the migration checkpoint in the target store")
+	version       = "x.x.x"
+	commit        = "00000000000000000000000000000000"
+)
+
+func init() {
+	monitoring.RegisterFlags()
+
+	monitoring.SetVersion(version, commit)
+}
+
+// openStore creates a store from a <type>:<address> string.
+// Supported types are couchstore, dummystore, elasticsearchstore, filestore,
+// postgresstore and rethinkstore (whose address is <url>/<db>).
+func openStore(spec string) (store.Adapter, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid store %q, expected <type>:<address>", spec)
+	}
+
+	address := parts[1]
+
+	switch parts[0] {
+	case "couchstore":
+		a, err := couchstore.New(&couchstore.Config{Address: address, Version: version, Commit: commit})
+		if err != nil {
+			return nil, err
+		}
+
+		return a, nil
+	case "dummystore":
+		return dummystore.New(&dummystore.Config{Version: version, Commit: commit}), nil
+	case "elasticsearchstore":
+		return elasticsearchstore.Initialize(&elasticsearchstore.Config{URL: address, Version: version, Commit: commit}), nil
+	case "filestore":
+		a, err := filestore.New(&filestore.Config{Path: address, Version: version, Commit: commit})
+		if err != nil {
+			return nil, err
+		}
+
+		return a, nil
+	case "postgresstore":
+		return postgresstore.Initialize(&postgresstore.Config{URL: address, Version: version, Commit: commit}, false, false, false), nil
+	case "rethinkstore":
+		db := rethinkstore.DefaultDB
+		if i := strings.LastIndex(address, "/"); i >= 0 {
+			address, db = address[:i], address[i+1:]
+		}
+
+		return rethinkstore.Initialize(&rethinkstore.Config{URL: address, DB: db, Version: version, Commit: commit}, false, false), nil
+	default:
+		return nil, fmt.Errorf("unknown store type %q", parts[0])
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	monitoring.LogEntry().Infof("Stratumn's store migration tool v%s@%s", version, commit[:7])
+
+	src, err := openStore(*source)
+	if err != nil {
+		monitoring.LogEntry().WithField("error", err).Fatal("Failed to open source store")
+	}
+
+	dst, err := openStore(*target)
+	if err != nil {
+		monitoring.LogEntry().WithField("error", err).Fatal("Failed to open target store")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		sigc := make(chan os.Signal, 1)
+		signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+		sig := <-sigc
+		monitoring.LogEntry().WithField("signal", sig).Info("Got exit signal")
+		cancel()
+	}()
+
+	m := storemigration.New(src, dst, &storemigration.Config{
+		CheckpointKey: *checkpointKey,
+		Tail:          *tail,
+	})
+
+	if err := m.Run(ctx); err != nil {
+		monitoring.LogEntry().WithField("error", err).WithField("stats", m.Stats()).Fatal("Migration failed")
+	}
+
+	monitoring.LogEntry().WithField("stats", m.Stats()).Info("Migration done")
+
+	diff, err := m.Diff(context.Background())
+	if err != nil {
+		monitoring.LogEntry().WithField("error", err).Fatal("Failed to compare stores")
+	}
+
+	js, _ := json.MarshalIndent(diff, "", "  ")
+	fmt.Println(string(js))
+
+	if !diff.Consistent() {
+		monitoring.LogEntry().Error("Target store differs from source store")
+		os.Exit(1)
+	}
+}
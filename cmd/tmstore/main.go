@@ -19,8 +19,14 @@ import (
 	"context"
 	"flag"
 
+	"github.com/stratumn/go-core/cachedstore"
 	"github.com/stratumn/go-core/monitoring"
+	"github.com/stratumn/go-core/store/storearchive"
+	"github.com/stratumn/go-core/store/storegrpc"
 	"github.com/stratumn/go-core/store/storehttp"
+	"github.com/stratumn/go-core/store/storewebhook"
+	// Registers the verifier of Tendermint evidences.
+	_ "github.com/stratumn/go-core/tmpop/evidences"
 	"github.com/stratumn/go-core/tmstore"
 	"github.com/tendermint/tendermint/rpc/client"
 )
@@ -34,6 +40,10 @@ var (
 
 func init() {
 	storehttp.RegisterFlags()
+	storegrpc.RegisterFlags()
+	storewebhook.RegisterFlags()
+	storearchive.RegisterFlags()
+	cachedstore.RegisterFlags()
 	monitoring.RegisterFlags()
 
 	monitoring.SetVersion(version, commit)
@@ -59,5 +69,10 @@ func main() {
 		}
 	}()
 
-	storehttp.RunWithFlags(monitoring.WrapStore(a, "tmstore"))
+	storearchive.RunWithFlags(a)
+
+	adapter := monitoring.WrapStore(cachedstore.WrapWithFlags(a), "tmstore")
+	storewebhook.RunWithFlags(adapter)
+	storegrpc.RunWithFlags(adapter)
+	storehttp.RunWithFlags(adapter)
 }
@@ -21,6 +21,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/pkg/errors"
@@ -41,8 +43,15 @@ const (
 	dbEvidences = "pop_evidences"
 	dbValue     = "kv"
 
+	// valuesPageSize is the number of values read at once when iterating.
+	valuesPageSize = 1000
+
 	objectTypeLink = "link"
 	objectTypeMap  = "map"
+
+	// createdAtFormat is a fixed-width UTC format so that creation times can
+	// be compared as strings by CouchDB.
+	createdAtFormat = "2006-01-02T15:04:05.000000000Z"
 )
 
 // CouchResponseStatus contains couch specific response when querying the API.
@@ -76,14 +85,18 @@ func (c *CouchResponseStatus) error() error {
 type LinkWrapper struct {
 	Link         *chainscript.Link `json:"link"`
 	Priority     float64           `json:"priority"`
+	Order        float64           `json:"order"`
 	PrevLinkHash string            `json:"prevLinkHash"`
+	CreatedAt    string            `json:"createdAt,omitempty"`
 }
 
 // WrapLink wraps a link.
 func WrapLink(link *chainscript.Link) *LinkWrapper {
 	wrapper := &LinkWrapper{
-		Link:     link,
-		Priority: link.Meta.Priority,
+		Link:      link,
+		Priority:  link.Meta.Priority,
+		Order:     segmentOrder(link.Meta.Priority),
+		CreatedAt: formatCreatedAt(time.Now()),
 	}
 
 	if len(link.PrevLinkHash()) > 0 {
@@ -93,6 +106,22 @@ func WrapLink(link *chainscript.Link) *LinkWrapper {
 	return wrapper
 }
 
+// segmentOrder returns the value sorting links like segments (by decreasing
+// priority then by increasing link hash) when sorted along with the link hash
+// in increasing order, since CouchDB can't sort fields in different
+// directions.
+func segmentOrder(priority float64) float64 {
+	if priority == 0 {
+		return 0
+	}
+
+	return -priority
+}
+
+func formatCreatedAt(t time.Time) string {
+	return t.UTC().Format(createdAtFormat)
+}
+
 // Document is the object stored in CouchDB.
 type Document struct {
 	ID         string `json:"_id,omitempty"`
@@ -312,6 +341,50 @@ func (c *CouchStore) saveDocuments(dbName string, docs []*Document) error {
 	return err
 }
 
+// findDocuments returns the documents matching a query of the _find api.
+func (c *CouchStore) findDocuments(dbName string, query []byte) ([]*Document, error) {
+	body, couchResponseStatus, err := c.post("/"+dbName+"/_find", query)
+	if err != nil {
+		return nil, err
+	}
+
+	if !couchResponseStatus.Ok {
+		return nil, couchResponseStatus.error()
+	}
+
+	couchFindResponse := &CouchFindResponse{}
+	if err := json.Unmarshal(body, couchFindResponse); err != nil {
+		return nil, types.WrapError(err, errorcode.InvalidArgument, store.Component, "json.Unmarshal")
+	}
+
+	return couchFindResponse.Docs, nil
+}
+
+// backfillOrder sets the order of the link documents created before it was
+// stored, so that they can be found with the order index.
+func (c *CouchStore) backfillOrder() error {
+	query := fmt.Sprintf(
+		`{"selector":{"docType":%q,"linkWrapper.order":{"$exists":false}},"limit":%d}`,
+		objectTypeLink,
+		store.DefaultLimit*10,
+	)
+
+	for {
+		docs, err := c.findDocuments(dbLink, []byte(query))
+		if err != nil || len(docs) == 0 {
+			return err
+		}
+
+		for _, doc := range docs {
+			doc.LinkWrapper.Order = segmentOrder(doc.LinkWrapper.Priority)
+		}
+
+		if err := c.saveDocuments(dbLink, docs); err != nil {
+			return err
+		}
+	}
+}
+
 func (c *CouchStore) getDocument(dbName string, key string) (*Document, error) {
 	doc := &Document{}
 	path := fmt.Sprintf("/%v/%v", dbName, key)
@@ -358,6 +431,46 @@ func (c *CouchStore) deleteDocument(dbName string, key string) (*Document, error
 	return doc, nil
 }
 
+// getDocumentsRange returns at most limit documents whose IDs are in
+// [startKey, endKey), sorted by ID. An empty endKey means there is no upper
+// bound. Documents are sorted bytewise since _all_docs uses raw collation.
+func (c *CouchStore) getDocumentsRange(dbName string, startKey, endKey string, skip, limit int) ([]*Document, error) {
+	query := url.Values{}
+	query.Set("include_docs", "true")
+	query.Set("skip", strconv.Itoa(skip))
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("startkey", strconv.Quote(startKey))
+	if endKey != "" {
+		query.Set("endkey", strconv.Quote(endKey))
+		query.Set("inclusive_end", "false")
+	}
+
+	path := fmt.Sprintf("/%v/_all_docs?%v", dbName, query.Encode())
+	body, couchResponseStatus, err := c.get(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !couchResponseStatus.Ok {
+		return nil, couchResponseStatus.error()
+	}
+
+	res := &CouchAllDocsResponse{}
+	if err := json.Unmarshal(body, res); err != nil {
+		return nil, types.WrapError(err, errorcode.InvalidArgument, store.Component, "json.Unmarshal")
+	}
+
+	docs := make([]*Document, 0, len(res.Rows))
+	for _, row := range res.Rows {
+		if row.Doc != nil {
+			row.Doc.ID = row.ID
+			docs = append(docs, row.Doc)
+		}
+	}
+
+	return docs, nil
+}
+
 func (c *CouchStore) get(path string) ([]byte, *CouchResponseStatus, error) {
 	return c.doHTTPRequest(http.MethodGet, path, nil)
 }
@@ -103,6 +103,14 @@ func New(config *Config) (*CouchStore, error) {
 		return nil, err
 	}
 
+	if err := couchstore.CreateIndex(dbLink, "order", []string{"linkWrapper.order", "_id"}); err != nil {
+		return nil, err
+	}
+
+	if err := couchstore.backfillOrder(); err != nil {
+		return nil, err
+	}
+
 	return couchstore, nil
 }
 
@@ -179,22 +187,13 @@ func (c *CouchStore) findSegmentsSlice(ctx context.Context, filter *store.Segmen
 		return nil, err
 	}
 
-	body, couchResponseStatus, err := c.post("/"+dbLink+"/_find", queryBytes)
+	docs, err := c.findDocuments(dbLink, queryBytes)
 	if err != nil {
 		return nil, err
 	}
 
-	if !couchResponseStatus.Ok {
-		return nil, couchResponseStatus.error()
-	}
-
-	couchFindResponse := &CouchFindResponse{}
-	if err := json.Unmarshal(body, couchFindResponse); err != nil {
-		return nil, types.WrapError(err, errorcode.InvalidArgument, store.Component, "json.Unmarshal")
-	}
-
 	segments := types.SegmentSlice{}
-	for _, doc := range couchFindResponse.Docs {
+	for _, doc := range docs {
 		segments = append(segments, c.segmentify(ctx, doc.LinkWrapper.Link))
 	}
 	return segments, nil
@@ -206,33 +205,106 @@ func (c *CouchStore) FindSegments(ctx context.Context, filter *store.SegmentFilt
 		return nil, types.WrapError(store.ErrReferencingNotSupported, errorcode.Unimplemented, store.Component, "could not find segments")
 	}
 
+	// CouchDB only sees the link data as base64, so link data predicates
+	// are evaluated here.
+	if len(filter.Data) > 0 {
+		return c.findDataSegments(ctx, filter)
+	}
+
 	segments, err := c.findSegmentsSlice(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
-	segments.Sort(filter.Reverse)
 
-	// TODO Dig into map/reduce to count documents
+	totalCount, err := c.countSegments(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.PaginatedSegments{
+		Segments:   segments,
+		TotalCount: totalCount,
+		NextCursor: store.NextSegmentCursor(segments, filter.Limit),
+	}, nil
+}
+
+// countSegments counts the links matching the filter, ignoring pagination.
+// Only the link hashes and orders are read, a batch at a time.
+// TODO Dig into map/reduce to count documents
+func (c *CouchStore) countSegments(filter *store.SegmentFilter) (int, error) {
+	query := *filter
+	query.Limit = store.DefaultLimit * 10
+	query.Offset = 0
+	query.Cursor = ""
+
 	totalCount := 0
-	filter.Limit = store.DefaultLimit * 10
-	filter.Offset = 0
 	for {
-		allSegments, err := c.findSegmentsSlice(ctx, filter)
+		linkQuery, err := newLinkQuery(&query)
+		if err != nil {
+			return 0, err
+		}
+
+		linkQuery.Fields = []string{"_id", "linkWrapper.order"}
+		queryBytes, err := json.Marshal(linkQuery)
+		if err != nil {
+			return 0, types.WrapError(err, errorcode.InvalidArgument, store.Component, "json.Marshal")
+		}
+
+		docs, err := c.findDocuments(dbLink, queryBytes)
+		if err != nil {
+			return 0, err
+		}
+
+		totalCount += len(docs)
+		if len(docs) < query.Limit {
+			return totalCount, nil
+		}
+
+		last := docs[len(docs)-1]
+		linkHash, err := chainscript.NewLinkHashFromString(last.ID)
+		if err != nil {
+			return 0, types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not count segments")
+		}
+
+		cursor := &store.SegmentCursor{Priority: -last.LinkWrapper.Order, LinkHash: linkHash}
+		query.Cursor = cursor.String()
+	}
+}
+
+// findDataSegments finds segments matching link data predicates.
+// All the segments matching the other predicates are read to evaluate them.
+func (c *CouchStore) findDataSegments(ctx context.Context, filter *store.SegmentFilter) (*types.PaginatedSegments, error) {
+	query := *filter
+	query.Limit = store.DefaultLimit * 10
+	query.Offset = 0
+	query.Cursor = ""
+
+	segments := &types.PaginatedSegments{}
+	for {
+		page, err := c.findSegmentsSlice(ctx, &query)
 		if err != nil {
 			return nil, err
 		}
-		segmentsLen := len(allSegments)
-		totalCount += segmentsLen
-		if segmentsLen < filter.Limit {
+
+		for _, s := range page {
+			if filter.Match(s) {
+				segments.Segments = append(segments.Segments, s)
+			}
+		}
+
+		if len(page) < query.Limit {
 			break
 		}
-		filter.Offset += filter.Limit
+
+		// Walking with a cursor doesn't skip nor repeat segments when links
+		// are added concurrently.
+		query.Cursor = store.NextSegmentCursor(page, query.Limit)
 	}
 
-	return &types.PaginatedSegments{
-		Segments:   segments,
-		TotalCount: totalCount,
-	}, nil
+	segments.TotalCount = len(segments.Segments)
+	segments.Segments.Sort(filter.Reverse)
+
+	return filter.PaginateSegments(segments)
 }
 
 // GetMapIDs implements github.com/stratumn/go-core/store.Adapter.GetMapIDs.
@@ -327,6 +399,41 @@ func (c *CouchStore) DeleteValue(ctx context.Context, key []byte) ([]byte, error
 	return valueDoc.Value, nil
 }
 
+// IterateValues implements github.com/stratumn/go-core/store.KeyValueIterator.IterateValues.
+// Keys are saved as lowercase hex document IDs, which preserves their order.
+func (c *CouchStore) IterateValues(ctx context.Context, start, end []byte, fn func(key, value []byte) error) error {
+	startKey, endKey := hex.EncodeToString(start), ""
+	if end != nil {
+		endKey = hex.EncodeToString(end)
+	}
+
+	skip := 0
+	for {
+		docs, err := c.getDocumentsRange(dbValue, startKey, endKey, skip, valuesPageSize)
+		if err != nil {
+			return err
+		}
+
+		for _, doc := range docs {
+			key, err := hex.DecodeString(doc.ID)
+			if err != nil {
+				return types.WrapError(err, errorcode.Internal, store.Component, "could not decode key")
+			}
+
+			if err := fn(key, doc.Value); err != nil {
+				return err
+			}
+		}
+
+		if len(docs) < valuesPageSize {
+			return nil
+		}
+
+		// The next page starts after the last document.
+		startKey, skip = docs[len(docs)-1].ID, 1
+	}
+}
+
 /********** github.com/stratumn/go-core/store.Batch implementation **********/
 
 // NewBatch implements github.com/stratumn/go-core/store.Adapter.NewBatch.
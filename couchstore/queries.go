@@ -27,11 +27,48 @@ import (
 type LinkSelector struct {
 	ObjectType   string        `json:"docType"`
 	PrevLinkHash *PrevLinkHash `json:"linkWrapper.prevLinkHash,omitempty"`
-	Process      string        `json:"linkWrapper.link.meta.process.name,omitempty"`
+	Process      *StringIn     `json:"linkWrapper.link.meta.process.name,omitempty"`
 	MapIds       *MapIdsIn     `json:"linkWrapper.link.meta.mapId,omitempty"`
-	Step         string        `json:"linkWrapper.link.meta.step,omitempty"`
+	Step         *StringIn     `json:"linkWrapper.link.meta.step,omitempty"`
 	Tags         *TagsAll      `json:"linkWrapper.link.meta.tags,omitempty"`
 	LinkHash     *LinkHashIn   `json:"_id,omitempty"`
+	CreatedAt    *TimeRange    `json:"linkWrapper.createdAt,omitempty"`
+	Order        *OrderRange   `json:"linkWrapper.order,omitempty"`
+	After        []OrderAfter  `json:"$or,omitempty"`
+}
+
+// OrderRange specifies that the segment order should be in the given range.
+type OrderRange struct {
+	From *float64 `json:"$gte,omitempty"`
+	To   *float64 `json:"$lte,omitempty"`
+}
+
+// OrderAfter is one of the conditions a segment should match to come after
+// a cursor: either its order or its link hash is strictly after the cursor's.
+type OrderAfter struct {
+	Order    *OrderBound `json:"linkWrapper.order,omitempty"`
+	LinkHash *OrderBound `json:"_id,omitempty"`
+}
+
+// OrderBound specifies that a field should be strictly greater or lower than
+// a value.
+type OrderBound struct {
+	Greater interface{} `json:"$gt,omitempty"`
+	Lower   interface{} `json:"$lt,omitempty"`
+}
+
+// TimeRange specifies that segment creation time should be in the given
+// range.
+type TimeRange struct {
+	From string `json:"$gte,omitempty"`
+	To   string `json:"$lt,omitempty"`
+}
+
+// StringIn specifies that a field should be equal to a value and/or be in
+// specified list.
+type StringIn struct {
+	Equals string   `json:"$eq,omitempty"`
+	Values []string `json:"$in,omitempty"`
 }
 
 // LinkHashIn specifies the list of link hashes to search for
@@ -44,9 +81,16 @@ type MapIdsIn struct {
 	MapIds []string `json:"$in,omitempty"`
 }
 
-// TagsAll specifies all tags in specified list should be in segment tags
+// TagsAll specifies all tags in specified list should be in segment tags.
+// If Any is set, at least one of its tags should also be in segment tags.
 type TagsAll struct {
 	Tags []string `json:"$all,omitempty"`
+	Any  *TagsAny `json:"$elemMatch,omitempty"`
+}
+
+// TagsAny specifies a segment tag should be in specified list.
+type TagsAny struct {
+	Tags []string `json:"$in,omitempty"`
 }
 
 // PrevLinkHash is used to specify PrevLinkHash in selector.
@@ -61,6 +105,7 @@ type LinkQuery struct {
 	Limit    int                 `json:"limit,omitempty"`
 	Skip     int                 `json:"skip,omitempty"`
 	Sort     []map[string]string `json:"sort,omitempty"`
+	Fields   []string            `json:"fields,omitempty"`
 }
 
 // CouchFindResponse is couchdb response type when posting to /db/_find
@@ -68,20 +113,49 @@ type CouchFindResponse struct {
 	Docs []*Document `json:"docs"`
 }
 
+// CouchAllDocsResponse is couchdb response type when getting /db/_all_docs
+// with the documents included.
+type CouchAllDocsResponse struct {
+	Rows []struct {
+		ID  string    `json:"id"`
+		Doc *Document `json:"doc"`
+	} `json:"rows"`
+}
+
 func buildSortArgs(reverse bool) []map[string]string {
-	order := "desc"
+	order := "asc"
 	if reverse {
-		order = "asc"
+		order = "desc"
 	}
 	return []map[string]string{
 		map[string]string{
-			"linkWrapper.priority": order,
+			"linkWrapper.order": order,
+		},
+		map[string]string{
+			"_id": order,
 		},
 	}
 }
 
 // NewSegmentQuery generates json data used to filter queries using couchdb _find api.
 func NewSegmentQuery(filter *store.SegmentFilter) ([]byte, error) {
+	linkQuery, err := newLinkQuery(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(linkQuery)
+	if err != nil {
+		return b, types.WrapError(err, errorcode.InvalidArgument, store.Component, "json.Marshal")
+	}
+
+	return b, nil
+}
+
+// newLinkQuery creates the query finding the links matching a filter.
+// The cursor is part of the selector so that CouchDB can start reading the
+// order index from the cursor's position.
+func newLinkQuery(filter *store.SegmentFilter) (*LinkQuery, error) {
 	linkSelector := LinkSelector{}
 	linkSelector.ObjectType = objectTypeLink
 
@@ -94,17 +168,20 @@ func NewSegmentQuery(filter *store.SegmentFilter) ([]byte, error) {
 			Equals: filter.PrevLinkHash.String(),
 		}
 	}
-	if filter.Process != "" {
-		linkSelector.Process = filter.Process
+	if filter.Process != "" || len(filter.Processes) > 0 {
+		linkSelector.Process = &StringIn{Equals: filter.Process, Values: filter.Processes}
 	}
-	if filter.Step != "" {
-		linkSelector.Step = filter.Step
+	if filter.Step != "" || len(filter.Steps) > 0 {
+		linkSelector.Step = &StringIn{Equals: filter.Step, Values: filter.Steps}
 	}
 	if len(filter.MapIDs) > 0 {
 		linkSelector.MapIds = &MapIdsIn{MapIds: filter.MapIDs}
 	}
-	if len(filter.Tags) > 0 {
+	if len(filter.Tags) > 0 || len(filter.TagsAny) > 0 {
 		linkSelector.Tags = &TagsAll{Tags: filter.Tags}
+		if len(filter.TagsAny) > 0 {
+			linkSelector.Tags.Any = &TagsAny{Tags: filter.TagsAny}
+		}
 	}
 	if len(filter.LinkHashes) > 0 {
 		linkSelector.LinkHash = &LinkHashIn{}
@@ -112,20 +189,47 @@ func NewSegmentQuery(filter *store.SegmentFilter) ([]byte, error) {
 			linkSelector.LinkHash.LinkHashes = append(linkSelector.LinkHash.LinkHashes, lh.String())
 		}
 	}
-
-	linkQuery := LinkQuery{
-		Selector: linkSelector,
-		Limit:    filter.Pagination.Limit,
-		Skip:     filter.Pagination.Offset,
-		Sort:     buildSortArgs(filter.Reverse),
+	if filter.CreatedAfter != nil || filter.CreatedBefore != nil {
+		linkSelector.CreatedAt = &TimeRange{}
+		if filter.CreatedAfter != nil {
+			linkSelector.CreatedAt.From = formatCreatedAt(*filter.CreatedAfter)
+		}
+		if filter.CreatedBefore != nil {
+			linkSelector.CreatedAt.To = formatCreatedAt(*filter.CreatedBefore)
+		}
 	}
 
-	b, err := json.Marshal(linkQuery)
-	if err != nil {
-		return b, types.WrapError(err, errorcode.InvalidArgument, store.Component, "json.Marshal")
+	skip := filter.Pagination.Offset
+	if filter.Cursor != "" {
+		cursor, err := store.ParseSegmentCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		order, linkHash := segmentOrder(cursor.Priority), cursor.LinkHash.String()
+		if filter.Reverse {
+			linkSelector.Order = &OrderRange{To: &order}
+			linkSelector.After = []OrderAfter{
+				{Order: &OrderBound{Lower: order}},
+				{LinkHash: &OrderBound{Lower: linkHash}},
+			}
+		} else {
+			linkSelector.Order = &OrderRange{From: &order}
+			linkSelector.After = []OrderAfter{
+				{Order: &OrderBound{Greater: order}},
+				{LinkHash: &OrderBound{Greater: linkHash}},
+			}
+		}
+
+		skip = 0
 	}
 
-	return b, nil
+	return &LinkQuery{
+		Selector: linkSelector,
+		Limit:    filter.Pagination.Limit,
+		Skip:     skip,
+		Sort:     buildSortArgs(filter.Reverse),
+	}, nil
 }
 
 // MapSelector used in MapQuery.
@@ -141,16 +245,19 @@ type MapIdsFilters struct {
 	Filters []MapIdsFilter `json:"$and,omitempty"`
 }
 
-// MapIdsFilter specifies that segment mapId should match a given regex.
+// MapIdsFilter specifies that segment mapId should match a given regex or
+// come after a given map ID.
 type MapIdsFilter struct {
 	MapIdsMatch string `json:"$regex,omitempty"`
+	MapIdsAfter string `json:"$gt,omitempty"`
 }
 
 // MapQuery used in CouchDB rich queries.
 type MapQuery struct {
-	Selector MapSelector `json:"selector,omitempty"`
-	Limit    int         `json:"limit,omitempty"`
-	Skip     int         `json:"skip,omitempty"`
+	Selector MapSelector         `json:"selector,omitempty"`
+	Limit    int                 `json:"limit,omitempty"`
+	Skip     int                 `json:"skip,omitempty"`
+	Sort     []map[string]string `json:"sort,omitempty"`
 }
 
 // NewMapQuery generates json data used to filter queries using couchdb _find api.
@@ -174,6 +281,15 @@ func NewMapQuery(filter *store.MapFilter) ([]byte, error) {
 		)
 	}
 
+	skip := filter.Pagination.Offset
+	if filter.Cursor != "" {
+		mapIdsFilters.Filters = append(
+			mapIdsFilters.Filters,
+			MapIdsFilter{MapIdsAfter: filter.Cursor},
+		)
+		skip = 0
+	}
+
 	if len(mapIdsFilters.Filters) > 0 {
 		mapSelector.MapIds = mapIdsFilters
 	}
@@ -181,7 +297,8 @@ func NewMapQuery(filter *store.MapFilter) ([]byte, error) {
 	mapQuery := MapQuery{
 		Selector: mapSelector,
 		Limit:    filter.Pagination.Limit,
-		Skip:     filter.Pagination.Offset,
+		Skip:     skip,
+		Sort:     []map[string]string{{"_id": "asc"}},
 	}
 
 	b, err := json.Marshal(mapQuery)
@@ -16,13 +16,18 @@
 //
 // It can be used for testing, but it's unoptimized and not designed for
 // production.
+// A store created with Open is also saved to disk with periodic snapshots and
+// a write-ahead log, so that it survives restarts.
 package dummystore
 
 import (
 	"context"
+	"encoding/hex"
 	"fmt"
+	"os"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/stratumn/go-chainscript"
 	"github.com/stratumn/go-core/bufferedbatch"
@@ -46,6 +51,14 @@ type Config struct {
 
 	// A git commit hash that will be set in the store's information.
 	Commit string
+
+	// Directory where the snapshot and write-ahead log are saved by Open.
+	// The store is only kept in memory if empty.
+	Path string
+
+	// Interval between snapshots. If zero, a snapshot is only taken when the
+	// store is opened and closed.
+	SnapshotInterval time.Duration
 }
 
 // Info is the info returned by GetInfo.
@@ -62,26 +75,34 @@ type DummyStore struct {
 	eventChans      []chan *store.Event
 	links           linkMap           // maps link hashes to segments
 	linksChildCount linkChildCountMap // maps link hashes to their children count
+	linksCreatedAt  timeMap           // maps link hashes to their creation time
 	evidences       evidenceMap       // maps link hashes to evidences
 	values          valueMap          // maps keys to values
 	maps            hashSetMap        // maps chains IDs to sets of link hashes
 	mutex           sync.RWMutex      // simple global mutex
+
+	wal     *os.File      // write-ahead log, nil if the store isn't saved
+	done    chan struct{} // closed to stop periodic snapshots
+	stopped chan struct{} // closed when periodic snapshots are stopped
 }
 
 type linkMap map[string]*chainscript.Link
 type linkChildCountMap map[string]int
+type timeMap map[string]time.Time
 type evidenceMap map[string]types.EvidenceSlice
 type hashSet map[string]struct{}
 type hashSetMap map[string]hashSet
 type valueMap map[string][]byte
 
-// New creates an instance of a DummyStore.
+// New creates an instance of a DummyStore that is only kept in memory.
+// Use Open to create a store that is saved to disk.
 func New(config *Config) *DummyStore {
 	return &DummyStore{
 		config:          config,
 		eventChans:      nil,
 		links:           linkMap{},
 		linksChildCount: linkChildCountMap{},
+		linksCreatedAt:  timeMap{},
 		evidences:       evidenceMap{},
 		values:          valueMap{},
 		maps:            hashSetMap{},
@@ -131,16 +152,19 @@ func (a *DummyStore) createLink(link *chainscript.Link) (chainscript.LinkHash, e
 		return linkHash, types.WrapError(chainscript.ErrOutDegree, errorcode.FailedPrecondition, store.Component, "could not create link")
 	}
 
-	a.links[linkHashStr] = link
-	a.incrementChildCount(link.PrevLinkHash())
+	// Callers may modify the link afterwards while it is still referenced by
+	// the store and by the consumers of its events.
+	link, err = link.Clone()
+	if err != nil {
+		return linkHash, types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not clone link")
+	}
 
-	mapID := link.Meta.MapId
-	_, exists := a.maps[mapID]
-	if !exists {
-		a.maps[mapID] = hashSet{}
+	createdAt := time.Now()
+	if err := a.log(&walEntry{Type: linkEntry, Link: link, CreatedAt: createdAt}); err != nil {
+		return linkHash, err
 	}
 
-	a.maps[mapID][linkHashStr] = struct{}{}
+	a.applyLink(link, linkHashStr, createdAt)
 
 	linkEvent := store.NewSavedLinks(link)
 
@@ -151,13 +175,34 @@ func (a *DummyStore) createLink(link *chainscript.Link) (chainscript.LinkHash, e
 	return linkHash, nil
 }
 
+// applyLink saves a link that passed all the checks.
+func (a *DummyStore) applyLink(link *chainscript.Link, linkHash string, createdAt time.Time) {
+	a.links[linkHash] = link
+	a.linksCreatedAt[linkHash] = createdAt
+	a.incrementChildCount(link.PrevLinkHash())
+	a.indexMap(link.Meta.MapId, linkHash)
+}
+
+func (a *DummyStore) indexMap(mapID string, linkHash string) {
+	_, exists := a.maps[mapID]
+	if !exists {
+		a.maps[mapID] = hashSet{}
+	}
+
+	a.maps[mapID][linkHash] = struct{}{}
+}
+
 func (a *DummyStore) canHaveNewChild(linkHash chainscript.LinkHash) bool {
 	if len(linkHash) == 0 {
 		return true
 	}
 
 	linkHashStr := linkHash.String()
-	link := a.links[linkHashStr]
+	link, ok := a.links[linkHashStr]
+	if !ok {
+		// The parent isn't in this store, so we can't enforce its out degree.
+		return true
+	}
 
 	if link.Meta.OutDegree < 0 {
 		return true
@@ -214,6 +259,10 @@ func (a *DummyStore) addEvidence(linkHash string, evidence *chainscript.Evidence
 		return err
 	}
 
+	if err := a.log(&walEntry{Type: evidenceEntry, LinkHash: linkHash, Evidence: evidence}); err != nil {
+		return err
+	}
+
 	a.evidences[linkHash] = currentEvidences
 
 	return nil
@@ -254,24 +303,72 @@ func (a *DummyStore) FindSegments(ctx context.Context, filter *store.SegmentFilt
 	a.mutex.RLock()
 	defer a.mutex.RUnlock()
 
-	var linkHashes = hashSet{}
+	segments, err := a.findSegments(filter)
+	if err != nil {
+		return nil, err
+	}
 
-	if len(filter.MapIDs) == 0 {
-		for linkHash := range a.links {
-			linkHashes[linkHash] = struct{}{}
-		}
-	} else {
-		for _, mapID := range filter.MapIDs {
-			l, e := a.maps[mapID]
-			if e {
-				for k, v := range l {
-					linkHashes[k] = v
-				}
-			}
-		}
+	return filter.PaginateSegments(&types.PaginatedSegments{
+		Segments:   segments,
+		TotalCount: len(segments),
+	})
+}
+
+// IterateSegments implements github.com/stratumn/go-core/store.SegmentIterable.IterateSegments.
+func (a *DummyStore) IterateSegments(ctx context.Context, filter *store.SegmentFilter) (store.SegmentIterator, error) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	segments, err := a.findSegments(filter)
+	if err != nil {
+		return nil, err
 	}
 
-	return a.findHashesSegments(linkHashes, filter)
+	segments, err = filter.SkipToCursor(segments)
+	if err != nil {
+		return nil, err
+	}
+
+	return &segmentIterator{segments: segments, current: -1}, nil
+}
+
+// GetAncestors implements github.com/stratumn/go-core/store.GraphReader.GetAncestors.
+func (a *DummyStore) GetAncestors(ctx context.Context, linkHash chainscript.LinkHash, depth int) (types.SegmentSlice, error) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	graph, err := a.linkMapGraph(linkHash)
+	if err != nil || graph == nil {
+		return nil, err
+	}
+
+	return graph.Ancestors(linkHash, depth), nil
+}
+
+// GetDescendants implements github.com/stratumn/go-core/store.GraphReader.GetDescendants.
+func (a *DummyStore) GetDescendants(ctx context.Context, linkHash chainscript.LinkHash, depth int) (types.SegmentSlice, error) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	graph, err := a.linkMapGraph(linkHash)
+	if err != nil || graph == nil {
+		return nil, err
+	}
+
+	return graph.Descendants(linkHash, depth), nil
+}
+
+// GetMapHeads implements github.com/stratumn/go-core/store.GraphReader.GetMapHeads.
+func (a *DummyStore) GetMapHeads(ctx context.Context, process, mapID string) (types.SegmentSlice, error) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	graph, err := a.mapGraph(process, mapID)
+	if err != nil {
+		return nil, err
+	}
+
+	return graph.Heads(), nil
 }
 
 // GetMapIDs implements github.com/stratumn/go-core/store.Adapter.GetMapIDs.
@@ -322,6 +419,10 @@ func (a *DummyStore) SetValue(ctx context.Context, key, value []byte) error {
 
 func (a *DummyStore) setValue(key, value []byte) error {
 	k := createKey(key)
+	if err := a.log(&walEntry{Type: setValueEntry, Key: k, Value: value}); err != nil {
+		return err
+	}
+
 	a.values[k] = value
 
 	return nil
@@ -343,11 +444,87 @@ func (a *DummyStore) deleteValue(key []byte) ([]byte, error) {
 		return nil, nil
 	}
 
+	if err := a.log(&walEntry{Type: deleteValueEntry, Key: k}); err != nil {
+		return nil, err
+	}
+
 	delete(a.values, k)
 
 	return value, nil
 }
 
+// IterateValues implements github.com/stratumn/go-core/store.KeyValueIterator.IterateValues.
+func (a *DummyStore) IterateValues(ctx context.Context, start, end []byte, fn func(key, value []byte) error) error {
+	// Hex encoding preserves the order of the keys, and fn is called once the
+	// lock is released so that it can read from the store.
+	a.mutex.RLock()
+	startKey, endKey := createKey(start), createKey(end)
+	var keys []string
+	for k := range a.values {
+		if k >= startKey && (end == nil || k < endKey) {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	values := make([][]byte, len(keys))
+	for i, k := range keys {
+		values[i] = a.values[k]
+	}
+	a.mutex.RUnlock()
+
+	for i, k := range keys {
+		key, err := hex.DecodeString(k)
+		if err != nil {
+			return types.WrapError(err, errorcode.Internal, store.Component, "could not decode key")
+		}
+
+		if err := fn(key, append([]byte(nil), values[i]...)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// valueWrite is a write of a KeyValueBatch, with the key encoded like in the
+// values map.
+type valueWrite struct {
+	Key    string `json:"key"`
+	Value  []byte `json:"value"`
+	Delete bool   `json:"delete,omitempty"`
+}
+
+// WriteValues implements github.com/stratumn/go-core/store.KeyValueBatch.WriteValues.
+func (a *DummyStore) WriteValues(ctx context.Context, writes []store.KeyValueWrite) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	valueWrites := make([]valueWrite, len(writes))
+	for i, w := range writes {
+		valueWrites[i] = valueWrite{Key: createKey(w.Key), Value: w.Value, Delete: w.Delete}
+	}
+
+	if err := a.log(&walEntry{Type: writeValuesEntry, Writes: valueWrites}); err != nil {
+		return err
+	}
+
+	a.applyValueWrites(valueWrites)
+
+	return nil
+}
+
+func (a *DummyStore) applyValueWrites(writes []valueWrite) {
+	for _, w := range writes {
+		if w.Delete {
+			delete(a.values, w.Key)
+		} else {
+			a.values[w.Key] = w.Value
+		}
+	}
+}
+
 /********** github.com/stratumn/go-core/store.Batch implementation **********/
 
 // NewBatch implements github.com/stratumn/go-core/store.Adapter.NewBatch.
@@ -357,8 +534,29 @@ func (a *DummyStore) NewBatch(ctx context.Context) (store.Batch, error) {
 
 /********** Utilities **********/
 
-func (a *DummyStore) findHashesSegments(linkHashes hashSet, filter *store.SegmentFilter) (*types.PaginatedSegments, error) {
-	segments := &types.PaginatedSegments{}
+func (a *DummyStore) findSegments(filter *store.SegmentFilter) (types.SegmentSlice, error) {
+	var linkHashes = hashSet{}
+
+	if len(filter.MapIDs) == 0 {
+		for linkHash := range a.links {
+			linkHashes[linkHash] = struct{}{}
+		}
+	} else {
+		for _, mapID := range filter.MapIDs {
+			l, e := a.maps[mapID]
+			if e {
+				for k, v := range l {
+					linkHashes[k] = v
+				}
+			}
+		}
+	}
+
+	return a.findHashesSegments(linkHashes, filter)
+}
+
+func (a *DummyStore) findHashesSegments(linkHashes hashSet, filter *store.SegmentFilter) (types.SegmentSlice, error) {
+	var segments types.SegmentSlice
 
 	for linkHash := range linkHashes {
 		segment, err := a.getSegment(linkHash)
@@ -366,15 +564,73 @@ func (a *DummyStore) findHashesSegments(linkHashes hashSet, filter *store.Segmen
 			return nil, err
 		}
 
-		if filter.Match(segment) {
-			segments.Segments = append(segments.Segments, segment)
+		if filter.Match(segment) && filter.MatchCreatedAt(a.linksCreatedAt[linkHash]) {
+			segments = append(segments, segment)
 		}
 	}
-	segments.TotalCount = len(segments.Segments)
 
-	segments.Segments.Sort(filter.Reverse)
+	segments.Sort(filter.Reverse)
+
+	return segments, nil
+}
+
+// mapGraph builds the graph of a map from the links of the map index.
+func (a *DummyStore) mapGraph(process, mapID string) (*store.MapGraph, error) {
+	segments, err := a.findHashesSegments(a.maps[mapID], &store.SegmentFilter{
+		Process: process,
+		MapIDs:  []string{mapID},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return store.NewMapGraph(segments), nil
+}
+
+// linkMapGraph builds the graph of the map containing the given link.
+// It returns nil if the link doesn't exist.
+func (a *DummyStore) linkMapGraph(linkHash chainscript.LinkHash) (*store.MapGraph, error) {
+	link, exists := a.links[linkHash.String()]
+	if !exists {
+		return nil, nil
+	}
+
+	return a.mapGraph(link.Meta.Process.Name, link.Meta.MapId)
+}
+
+// segmentIterator iterates over segments that were already loaded.
+// Since all the segments are kept in memory by the store, iterating doesn't
+// use more memory than finding segments.
+type segmentIterator struct {
+	segments types.SegmentSlice
+	current  int
+}
+
+func (it *segmentIterator) Next() bool {
+	if it.current+1 >= len(it.segments) {
+		it.current = len(it.segments)
+		return false
+	}
+
+	it.current++
+	return true
+}
+
+func (it *segmentIterator) Segment() *chainscript.Segment {
+	if it.current < 0 || it.current >= len(it.segments) {
+		return nil
+	}
+
+	return it.segments[it.current]
+}
 
-	return filter.Pagination.PaginateSegments(segments), nil
+func (it *segmentIterator) Err() error {
+	return nil
+}
+
+func (it *segmentIterator) Close() error {
+	it.segments = nil
+	return nil
 }
 
 func createKey(k []byte) string {
@@ -0,0 +1,352 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dummystore
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-core/monitoring"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+)
+
+// Names of the files saved in Config.Path.
+const (
+	snapshotFile = "snapshot.json"
+	walFile      = "wal.log"
+)
+
+// Types of write-ahead log entries.
+const (
+	linkEntry        = "link"
+	evidenceEntry    = "evidence"
+	setValueEntry    = "set_value"
+	deleteValueEntry = "delete_value"
+	writeValuesEntry = "write_values"
+)
+
+// snapshot is the state of the store saved to disk.
+// The map index is rebuilt from the links when it is restored.
+type snapshot struct {
+	Links           linkMap           `json:"links"`
+	LinksChildCount linkChildCountMap `json:"linksChildCount"`
+	LinksCreatedAt  timeMap           `json:"linksCreatedAt"`
+	Evidences       evidenceMap       `json:"evidences"`
+	Values          valueMap          `json:"values"`
+}
+
+// walEntry is a write saved to the write-ahead log before being applied.
+type walEntry struct {
+	Type      string                `json:"type"`
+	Link      *chainscript.Link     `json:"link,omitempty"`
+	LinkHash  string                `json:"linkHash,omitempty"`
+	Evidence  *chainscript.Evidence `json:"evidence,omitempty"`
+	CreatedAt time.Time             `json:"createdAt,omitempty"`
+	Key       string                `json:"key,omitempty"`
+	Value     []byte                `json:"value"`
+	Writes    []valueWrite          `json:"writes,omitempty"`
+}
+
+// Open creates an instance of a DummyStore that is saved to disk.
+//
+// The state of the store is restored from the snapshot and write-ahead log
+// found in Config.Path, if any. Every write is appended to the write-ahead
+// log before being applied, and a snapshot replaces the log every
+// Config.SnapshotInterval and when the store is closed.
+func Open(config *Config) (*DummyStore, error) {
+	a := New(config)
+	if config.Path == "" {
+		return a, nil
+	}
+
+	if err := os.MkdirAll(config.Path, 0755); err != nil {
+		return nil, types.WrapError(err, errorcode.Unavailable, store.Component, "could not create snapshot directory")
+	}
+
+	if err := a.restore(); err != nil {
+		return nil, err
+	}
+
+	// A snapshot is taken right away so that new entries aren't appended
+	// after an incomplete entry left by a crash.
+	wal, err := os.OpenFile(a.walPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, types.WrapError(err, errorcode.Unavailable, store.Component, "could not open write-ahead log")
+	}
+
+	a.wal = wal
+	if err := a.Snapshot(); err != nil {
+		wal.Close()
+		return nil, err
+	}
+
+	if config.SnapshotInterval > 0 {
+		a.done = make(chan struct{})
+		a.stopped = make(chan struct{})
+		go a.snapshotPeriodically(config.SnapshotInterval)
+	}
+
+	return a, nil
+}
+
+// Snapshot saves the state of the store and truncates the write-ahead log.
+// It does nothing if the store isn't saved to disk.
+func (a *DummyStore) Snapshot() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return a.snapshot()
+}
+
+// Close takes a last snapshot and closes the write-ahead log.
+// Writes made after Close are kept in memory but aren't saved.
+func (a *DummyStore) Close() error {
+	if a.done != nil {
+		close(a.done)
+		<-a.stopped
+		a.done = nil
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.wal == nil {
+		return nil
+	}
+
+	if err := a.snapshot(); err != nil {
+		return err
+	}
+
+	err := a.wal.Close()
+	a.wal = nil
+	if err != nil {
+		return types.WrapError(err, errorcode.Unavailable, store.Component, "could not close write-ahead log")
+	}
+
+	return nil
+}
+
+func (a *DummyStore) snapshotPeriodically(interval time.Duration) {
+	defer close(a.stopped)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := a.Snapshot(); err != nil {
+				monitoring.LogEntry().WithField("error", err).Warn("Could not snapshot store")
+			}
+		case <-a.done:
+			return
+		}
+	}
+}
+
+func (a *DummyStore) snapshotPath() string {
+	return filepath.Join(a.config.Path, snapshotFile)
+}
+
+func (a *DummyStore) walPath() string {
+	return filepath.Join(a.config.Path, walFile)
+}
+
+// snapshot atomically replaces the snapshot file then truncates the
+// write-ahead log. The mutex must be held.
+func (a *DummyStore) snapshot() error {
+	if a.wal == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(&snapshot{
+		Links:           a.links,
+		LinksChildCount: a.linksChildCount,
+		LinksCreatedAt:  a.linksCreatedAt,
+		Evidences:       a.evidences,
+		Values:          a.values,
+	})
+	if err != nil {
+		return types.WrapError(err, errorcode.Internal, store.Component, "could not save snapshot")
+	}
+
+	// The temporary file is synced before being renamed, otherwise a crash
+	// could leave an empty snapshot and a truncated write-ahead log.
+	tmp := a.snapshotPath() + ".tmp"
+	if err := writeFileSync(tmp, data); err != nil {
+		return types.WrapError(err, errorcode.Unavailable, store.Component, "could not save snapshot")
+	}
+
+	if err := os.Rename(tmp, a.snapshotPath()); err != nil {
+		return types.WrapError(err, errorcode.Unavailable, store.Component, "could not save snapshot")
+	}
+
+	if err := a.wal.Truncate(0); err != nil {
+		return types.WrapError(err, errorcode.Unavailable, store.Component, "could not truncate write-ahead log")
+	}
+
+	return nil
+}
+
+// writeFileSync writes data to a file and syncs it to disk.
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// restore loads the snapshot then replays the write-ahead log.
+func (a *DummyStore) restore() error {
+	data, err := ioutil.ReadFile(a.snapshotPath())
+	if err != nil && !os.IsNotExist(err) {
+		return types.WrapError(err, errorcode.Unavailable, store.Component, "could not read snapshot")
+	}
+
+	if err == nil {
+		s := snapshot{
+			Links:           a.links,
+			LinksChildCount: a.linksChildCount,
+			LinksCreatedAt:  a.linksCreatedAt,
+			Evidences:       a.evidences,
+			Values:          a.values,
+		}
+		if err := json.Unmarshal(data, &s); err != nil {
+			return types.WrapError(err, errorcode.DataLoss, store.Component, "could not read snapshot")
+		}
+
+		a.links, a.linksChildCount, a.linksCreatedAt, a.evidences, a.values =
+			s.Links, s.LinksChildCount, s.LinksCreatedAt, s.Evidences, s.Values
+
+		for linkHash, link := range a.links {
+			a.indexMap(link.Meta.MapId, linkHash)
+		}
+	}
+
+	f, err := os.Open(a.walPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return types.WrapError(err, errorcode.Unavailable, store.Component, "could not read write-ahead log")
+	}
+
+	defer f.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for {
+		var e walEntry
+		err := dec.Decode(&e)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			// An incomplete last entry was being written when the store
+			// stopped, so it was never applied.
+			return nil
+		}
+		if err != nil {
+			return types.WrapError(err, errorcode.DataLoss, store.Component, "could not read write-ahead log")
+		}
+
+		if err := a.replay(&e); err != nil {
+			return err
+		}
+	}
+}
+
+// replay applies a write-ahead log entry.
+// Entries may already be in the snapshot if the store stopped after saving
+// it but before truncating the log, so links and evidences that are already
+// present are skipped. Value writes can safely be applied again since the
+// log contains all the writes made after the previous snapshot.
+func (a *DummyStore) replay(e *walEntry) error {
+	switch e.Type {
+	case linkEntry:
+		linkHash, err := e.Link.Hash()
+		if err != nil {
+			return types.WrapError(err, errorcode.DataLoss, store.Component, "could not hash link")
+		}
+
+		if _, exists := a.links[linkHash.String()]; exists {
+			return nil
+		}
+
+		a.applyLink(e.Link, linkHash.String(), e.CreatedAt)
+	case evidenceEntry:
+		if a.evidences[e.LinkHash].GetEvidence(e.Evidence.Backend, e.Evidence.Provider) != nil {
+			return nil
+		}
+
+		evidences := append(types.EvidenceSlice{}, a.evidences[e.LinkHash]...)
+		if err := evidences.AddEvidence(e.Evidence); err != nil {
+			return err
+		}
+
+		a.evidences[e.LinkHash] = evidences
+	case setValueEntry:
+		a.values[e.Key] = e.Value
+	case deleteValueEntry:
+		delete(a.values, e.Key)
+	case writeValuesEntry:
+		a.applyValueWrites(e.Writes)
+	default:
+		return types.NewErrorf(errorcode.DataLoss, store.Component, "unknown write-ahead log entry %q", e.Type)
+	}
+
+	return nil
+}
+
+// log appends an entry to the write-ahead log. The entry is on disk when log
+// returns. It does nothing if the store isn't saved to disk.
+// The mutex must be held.
+func (a *DummyStore) log(e *walEntry) error {
+	if a.wal == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not log write")
+	}
+
+	if _, err := a.wal.Write(append(data, '\n')); err != nil {
+		return types.WrapError(err, errorcode.Unavailable, store.Component, "could not log write")
+	}
+
+	if err := a.wal.Sync(); err != nil {
+		return types.WrapError(err, errorcode.Unavailable, store.Component, "could not log write")
+	}
+
+	return nil
+}
@@ -0,0 +1,284 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dummystore
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-chainscript/chainscripttest"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/store/storetestcases"
+	"github.com/stratumn/go-core/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDummyStore_Open(t *testing.T) {
+	factory := storetestcases.Factory{
+		New: func() (store.Adapter, error) {
+			return openTempStore()
+		},
+		Free: func(a store.Adapter) {
+			closeTempStore(a.(*DummyStore))
+		},
+		NewKeyValueStore: func() (store.KeyValueStore, error) {
+			return openTempStore()
+		},
+		FreeKeyValueStore: func(a store.KeyValueStore) {
+			closeTempStore(a.(*DummyStore))
+		},
+	}
+
+	factory.RunStoreTests(t)
+	factory.RunKeyValueStoreTests(t)
+}
+
+func openTempStore() (*DummyStore, error) {
+	path, err := ioutil.TempDir("", "dummystore")
+	if err != nil {
+		return nil, err
+	}
+
+	return Open(&Config{Path: path})
+}
+
+func closeTempStore(a *DummyStore) {
+	if err := a.Close(); err != nil {
+		panic(err)
+	}
+
+	os.RemoveAll(a.config.Path)
+}
+
+// testState writes some data to a store and checks that another store has
+// the same data.
+type testState struct {
+	parent   *chainscript.Link
+	child    *chainscript.Link
+	evidence *chainscript.Evidence
+}
+
+func writeTestState(t *testing.T, a *DummyStore) *testState {
+	ctx := context.Background()
+	s := &testState{}
+
+	s.parent = chainscripttest.NewLinkBuilder(t).WithRandomData().WithDegree(1).Build()
+	parentHash, err := a.CreateLink(ctx, s.parent)
+	require.NoError(t, err)
+
+	s.child = chainscripttest.NewLinkBuilder(t).
+		WithRandomData().
+		WithParent(t, s.parent).
+		WithProcess(s.parent.Meta.Process.Name).
+		WithMapID(s.parent.Meta.MapId).
+		Build()
+	_, err = a.CreateLink(ctx, s.child)
+	require.NoError(t, err)
+
+	s.evidence = chainscripttest.RandomEvidence(t)
+	require.NoError(t, a.AddEvidence(ctx, parentHash, s.evidence))
+
+	require.NoError(t, a.SetValue(ctx, []byte("kept"), []byte("value")))
+	require.NoError(t, a.SetValue(ctx, []byte("deleted"), []byte("value")))
+	_, err = a.DeleteValue(ctx, []byte("deleted"))
+	require.NoError(t, err)
+
+	require.NoError(t, a.SetValue(ctx, []byte("batch-deleted"), []byte("value")))
+	require.NoError(t, a.WriteValues(ctx, []store.KeyValueWrite{
+		{Key: []byte("batched"), Value: []byte("value")},
+		{Key: []byte("batch-deleted"), Delete: true},
+	}))
+
+	return s
+}
+
+func (s *testState) check(t *testing.T, a *DummyStore) {
+	ctx := context.Background()
+
+	parentHash, _ := s.parent.Hash()
+	parent, err := a.GetSegment(ctx, parentHash)
+	require.NoError(t, err)
+	require.NotNil(t, parent)
+	chainscripttest.LinksEqual(t, s.parent, parent.Link)
+	require.Len(t, parent.Meta.Evidences, 1)
+	assert.Equal(t, s.evidence.Provider, parent.Meta.Evidences[0].Provider)
+
+	segments, err := a.FindSegments(ctx, &store.SegmentFilter{
+		Pagination: store.Pagination{Limit: store.DefaultLimit},
+		MapIDs:     []string{s.parent.Meta.MapId},
+	})
+	require.NoError(t, err)
+	assert.Len(t, segments.Segments, 2)
+
+	// Child counts are restored so the out degree is still enforced.
+	sibling := chainscripttest.NewLinkBuilder(t).
+		WithRandomData().
+		WithParent(t, s.parent).
+		WithProcess(s.parent.Meta.Process.Name).
+		WithMapID(s.parent.Meta.MapId).
+		Build()
+	_, err = a.CreateLink(ctx, sibling)
+	testutil.AssertWrappedErrorEqual(t, err, chainscript.ErrOutDegree)
+
+	value, err := a.GetValue(ctx, []byte("kept"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), value)
+
+	value, err = a.GetValue(ctx, []byte("deleted"))
+	require.NoError(t, err)
+	assert.Nil(t, value)
+
+	value, err = a.GetValue(ctx, []byte("batched"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), value)
+
+	value, err = a.GetValue(ctx, []byte("batch-deleted"))
+	require.NoError(t, err)
+	assert.Nil(t, value)
+}
+
+func TestOpen(t *testing.T) {
+	t.Run("restores snapshot", func(t *testing.T) {
+		a, err := openTempStore()
+		require.NoError(t, err)
+		defer os.RemoveAll(a.config.Path)
+
+		s := writeTestState(t, a)
+		require.NoError(t, a.Close())
+
+		wal, err := ioutil.ReadFile(a.walPath())
+		require.NoError(t, err)
+		assert.Empty(t, wal, "the snapshot should truncate the write-ahead log")
+
+		b, err := Open(a.config)
+		require.NoError(t, err)
+		defer b.Close()
+
+		s.check(t, b)
+	})
+
+	t.Run("replays write-ahead log", func(t *testing.T) {
+		a, err := openTempStore()
+		require.NoError(t, err)
+		defer os.RemoveAll(a.config.Path)
+
+		// The store isn't closed, as if the process crashed.
+		s := writeTestState(t, a)
+
+		b, err := Open(a.config)
+		require.NoError(t, err)
+		defer b.Close()
+
+		s.check(t, b)
+	})
+
+	t.Run("replays write-ahead log already in snapshot", func(t *testing.T) {
+		a, err := openTempStore()
+		require.NoError(t, err)
+		defer os.RemoveAll(a.config.Path)
+
+		s := writeTestState(t, a)
+		wal, err := ioutil.ReadFile(a.walPath())
+		require.NoError(t, err)
+
+		// The store stopped after saving the snapshot but before truncating
+		// the write-ahead log.
+		require.NoError(t, a.Snapshot())
+		require.NoError(t, ioutil.WriteFile(a.walPath(), wal, 0600))
+
+		b, err := Open(a.config)
+		require.NoError(t, err)
+		defer b.Close()
+
+		s.check(t, b)
+		assert.Equal(t, 1, b.linksChildCount[s.child.PrevLinkHash().String()])
+	})
+
+	t.Run("ignores incomplete entry", func(t *testing.T) {
+		a, err := openTempStore()
+		require.NoError(t, err)
+		defer os.RemoveAll(a.config.Path)
+
+		s := writeTestState(t, a)
+
+		f, err := os.OpenFile(a.walPath(), os.O_WRONLY|os.O_APPEND, 0600)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(`{"type":"set_value","key":"6b6579","val`))
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		b, err := Open(a.config)
+		require.NoError(t, err)
+		defer b.Close()
+
+		s.check(t, b)
+
+		value, err := b.GetValue(context.Background(), []byte("key"))
+		require.NoError(t, err)
+		assert.Nil(t, value)
+	})
+
+	t.Run("rejects corrupted write-ahead log", func(t *testing.T) {
+		path, err := ioutil.TempDir("", "dummystore")
+		require.NoError(t, err)
+		defer os.RemoveAll(path)
+
+		err = ioutil.WriteFile(filepath.Join(path, walFile), []byte("{\"type\":\"unknown\"}\n"), 0600)
+		require.NoError(t, err)
+
+		_, err = Open(&Config{Path: path})
+		assert.Error(t, err)
+	})
+
+	t.Run("snapshots periodically", func(t *testing.T) {
+		path, err := ioutil.TempDir("", "dummystore")
+		require.NoError(t, err)
+		defer os.RemoveAll(path)
+
+		a, err := Open(&Config{Path: path, SnapshotInterval: 10 * time.Millisecond})
+		require.NoError(t, err)
+		defer a.Close()
+
+		s := writeTestState(t, a)
+
+		for i := 0; ; i++ {
+			wal, err := ioutil.ReadFile(a.walPath())
+			require.NoError(t, err)
+			if len(wal) == 0 {
+				break
+			}
+
+			require.True(t, i < 100, "write-ahead log should be truncated")
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		b := New(&Config{Path: path})
+		require.NoError(t, b.restore())
+		s.check(t, b)
+	})
+
+	t.Run("without path", func(t *testing.T) {
+		a, err := Open(&Config{})
+		require.NoError(t, err)
+		assert.Nil(t, a.wal)
+		assert.NoError(t, a.Close())
+	})
+}
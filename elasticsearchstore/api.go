@@ -18,6 +18,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/olivere/elastic"
 	"github.com/stratumn/go-chainscript"
@@ -31,6 +34,12 @@ const (
 	evidencesIndex = "evidences"
 	valuesIndex    = "values"
 
+	// valuesPageSize is the number of values read at once when iterating.
+	valuesPageSize = 1000
+
+	// backfillPageSize is the number of links upgraded at once.
+	backfillPageSize = 1000
+
 	// This is the mapping for the links index.
 	// We voluntarily disable indexing of some fields, such as:
 	// meta.refs, meta.data, data, signatures, etc.
@@ -121,6 +130,12 @@ const (
 					"priority": {
 						"type": "double"
 					},
+					"linkHash": {
+						"type": "keyword"
+					},
+					"createdAt": {
+						"type": "date"
+					},
 					"prevLinkHash": {
 						"type": "text",
 						"fields": {
@@ -131,6 +146,26 @@ const (
 					},
 					"dataTokens": {
 						"type": "text"
+					},
+					"dataPaths": {
+						"type": "keyword"
+					},
+					"dataFields": {
+						"type": "nested",
+						"properties": {
+							"path": {
+								"type": "keyword"
+							},
+							"str": {
+								"type": "keyword"
+							},
+							"num": {
+								"type": "double"
+							},
+							"bool": {
+								"type": "boolean"
+							}
+						}
 					}
 				}
 			}
@@ -164,9 +199,23 @@ type Value struct {
 
 type linkDoc struct {
 	chainscript.Link
-	Priority     float64  `json:"priority"`
-	PrevLinkHash string   `json:"prevLinkHash"`
-	DataTokens   []string `json:"dataTokens"`
+	Priority     float64     `json:"priority"`
+	LinkHash     string      `json:"linkHash"`
+	PrevLinkHash string      `json:"prevLinkHash"`
+	DataTokens   []string    `json:"dataTokens"`
+	DataPaths    []string    `json:"dataPaths"`
+	DataFields   []dataField `json:"dataFields"`
+	CreatedAt    time.Time   `json:"createdAt"`
+}
+
+// dataField is a scalar leaf of the link data.
+// The link data is flattened so that documents with different data schemas
+// can be stored in the same index.
+type dataField struct {
+	Path string   `json:"path"`
+	Str  *string  `json:"str,omitempty"`
+	Num  *float64 `json:"num,omitempty"`
+	Bool *bool    `json:"bool,omitempty"`
 }
 
 // SearchQuery contains pagination and query string information.
@@ -197,8 +246,101 @@ func (es *ESStore) createIndex(indexName, mapping string) error {
 	return nil
 }
 
+// createLinksIndex creates the links index.
+// Indexes created by previous versions are upgraded: the mappings of the
+// fields added since are put and existing documents get these fields, except
+// for createdAt which is unknown (such links never match creation time
+// filters).
 func (es *ESStore) createLinksIndex() error {
-	return es.createIndex(linksIndex, linksMapping)
+	if err := es.createIndex(linksIndex, linksMapping); err != nil {
+		return err
+	}
+
+	if err := es.putLinksMapping(); err != nil {
+		return err
+	}
+
+	return es.backfillLinks()
+}
+
+// putLinksMapping adds the missing field mappings to the links index.
+func (es *ESStore) putLinksMapping() error {
+	var mapping struct {
+		Mappings map[string]json.RawMessage `json:"mappings"`
+	}
+	if err := json.Unmarshal([]byte(linksMapping), &mapping); err != nil {
+		return types.WrapError(err, errorcode.Internal, store.Component, "json.Unmarshal")
+	}
+
+	ctx := context.TODO()
+	putMapping, err := es.client.PutMapping().
+		Index(linksIndex).
+		Type(docType).
+		BodyString(string(mapping.Mappings[docType])).
+		Do(ctx)
+	if err != nil {
+		return types.WrapError(err, errorcode.Unavailable, store.Component, "could not put links mapping")
+	}
+	if !putMapping.Acknowledged {
+		return types.NewErrorf(errorcode.Unavailable, store.Component, "error putting mapping of index %s", linksIndex)
+	}
+
+	return nil
+}
+
+// backfillLinks adds the fields of link documents created by previous
+// versions that lack them: link hash (used to sort segments) and data
+// fields (used by data predicates).
+func (es *ESStore) backfillLinks() error {
+	ctx := context.TODO()
+	missing := elastic.NewBoolQuery().MustNot(elastic.NewExistsQuery("linkHash"))
+
+	for {
+		sr, err := es.client.Search(linksIndex).
+			Type(docType).
+			Query(missing).
+			Size(backfillPageSize).
+			Do(ctx)
+		if err != nil {
+			return types.WrapError(err, errorcode.Unavailable, store.Component, "could not backfill links")
+		}
+
+		if sr == nil || len(sr.Hits.Hits) == 0 {
+			return nil
+		}
+
+		bulk := es.client.Bulk().Refresh("true")
+		for _, hit := range sr.Hits.Hits {
+			var link chainscript.Link
+			if err := json.Unmarshal(*hit.Source, &link); err != nil {
+				return types.WrapError(err, errorcode.InvalidArgument, store.Component, "json.Unmarshal")
+			}
+
+			doc, err := fromLink(&link)
+			if err != nil {
+				return err
+			}
+
+			bulk.Add(elastic.NewBulkUpdateRequest().
+				Index(linksIndex).
+				Type(docType).
+				Id(hit.Id).
+				Doc(map[string]interface{}{
+					"linkHash":   doc.LinkHash,
+					"dataTokens": doc.DataTokens,
+					"dataPaths":  doc.DataPaths,
+					"dataFields": doc.DataFields,
+				}))
+		}
+
+		res, err := bulk.Do(ctx)
+		if err != nil {
+			return types.WrapError(err, errorcode.Unavailable, store.Component, "could not backfill links")
+		}
+		if res.Errors {
+			return types.NewErrorf(errorcode.Unavailable, store.Component, "could not backfill links of index %s", linksIndex)
+		}
+	}
 }
 
 func (es *ESStore) createEvidencesIndex() error {
@@ -261,10 +403,46 @@ func (o *linkDoc) extractTokens(obj interface{}) {
 	}
 }
 
+// extract the paths of all the nodes and the values of scalar leaves.
+func (o *linkDoc) extractDataFields(path []string, obj interface{}) {
+	if len(path) > 0 {
+		o.DataPaths = append(o.DataPaths, strings.Join(path, "."))
+	}
+
+	field := dataField{Path: strings.Join(path, ".")}
+	switch value := obj.(type) {
+	case string:
+		field.Str = &value
+		o.DataFields = append(o.DataFields, field)
+	case float64:
+		field.Num = &value
+		o.DataFields = append(o.DataFields, field)
+	case bool:
+		field.Bool = &value
+		o.DataFields = append(o.DataFields, field)
+	case map[string]interface{}:
+		for k, v := range value {
+			o.extractDataFields(append(path[:len(path):len(path)], k), v)
+		}
+	case []interface{}:
+		for i, v := range value {
+			o.extractDataFields(append(path[:len(path):len(path)], strconv.Itoa(i)), v)
+		}
+	default:
+		return
+	}
+}
+
 func fromLink(link *chainscript.Link) (*linkDoc, error) {
+	linkHash, err := link.Hash()
+	if err != nil {
+		return nil, types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not hash link")
+	}
+
 	doc := linkDoc{
 		Link:       *link,
 		Priority:   link.Meta.Priority,
+		LinkHash:   linkHash.String(),
 		DataTokens: []string{},
 	}
 
@@ -284,6 +462,12 @@ func fromLink(link *chainscript.Link) (*linkDoc, error) {
 		if err == nil {
 			doc.extractTokens(objData)
 		}
+
+		var data interface{}
+		err = link.StructurizeData(&data)
+		if err == nil {
+			doc.extractDataFields(nil, data)
+		}
 	}
 
 	return &doc, nil
@@ -310,6 +494,8 @@ func (es *ESStore) createLink(ctx context.Context, link *chainscript.Link) (chai
 		return nil, err
 	}
 
+	linkDoc.CreatedAt = time.Now()
+
 	return linkHash, es.indexDocument(ctx, linksIndex, linkHashStr, linkDoc)
 }
 
@@ -450,6 +636,52 @@ func (es *ESStore) deleteValue(ctx context.Context, key string) ([]byte, error)
 	return value, es.deleteDocument(ctx, valuesIndex, key)
 }
 
+// getValuesAfter returns the next page of values whose (hex) key is greater
+// than after, sorted by key. An empty after starts from the first key.
+// The values index isn't indexed, so documents can only be sorted on their
+// ID, which is the hex key.
+func (es *ESStore) getValuesAfter(ctx context.Context, after string, size int) ([]string, [][]byte, error) {
+	// Refresh to make sure the documents are searchable.
+	_, err := es.client.Refresh(valuesIndex).Do(ctx)
+	if err != nil {
+		return nil, nil, types.WrapError(err, errorcode.Unavailable, store.Component, "could not iterate values")
+	}
+
+	svc := es.client.
+		Search().
+		Index(valuesIndex).
+		Type(docType).
+		Sort("_id", true).
+		Size(size)
+
+	if after != "" {
+		svc = svc.SearchAfter(after)
+	}
+
+	sr, err := svc.Do(ctx)
+	if err != nil {
+		return nil, nil, types.WrapError(err, errorcode.Unavailable, store.Component, "could not iterate values")
+	}
+
+	var keys []string
+	var values [][]byte
+	if sr == nil || sr.Hits == nil {
+		return keys, values, nil
+	}
+
+	for _, hit := range sr.Hits.Hits {
+		var value Value
+		if err := json.Unmarshal(*hit.Source, &value); err != nil {
+			return nil, nil, types.WrapError(err, errorcode.InvalidArgument, store.Component, "json.Unmarshal")
+		}
+
+		keys = append(keys, hit.Id)
+		values = append(values, value.Value)
+	}
+
+	return keys, values, nil
+}
+
 func (es *ESStore) segmentify(ctx context.Context, link *chainscript.Link) *chainscript.Segment {
 	segment, err := link.Segmentify()
 	if err != nil {
@@ -546,6 +778,18 @@ func makeFilterQueries(filter *store.SegmentFilter) []elastic.Query {
 		filterQueries = append(filterQueries, q)
 	}
 
+	// processes filter.
+	if len(filter.Processes) > 0 {
+		q := elastic.NewTermsQuery("meta.process.name.keyword", stringsToInterfaces(filter.Processes)...)
+		filterQueries = append(filterQueries, q)
+	}
+
+	// steps filter.
+	if len(filter.Steps) > 0 {
+		q := elastic.NewTermsQuery("meta.step.keyword", stringsToInterfaces(filter.Steps)...)
+		filterQueries = append(filterQueries, q)
+	}
+
 	// mapIds filter.
 	if len(filter.MapIDs) > 0 {
 		termQueries := []elastic.Query{}
@@ -568,6 +812,12 @@ func makeFilterQueries(filter *store.SegmentFilter) []elastic.Query {
 		filterQueries = append(filterQueries, shouldQuery)
 	}
 
+	// tagsAny filter.
+	if len(filter.TagsAny) > 0 {
+		q := elastic.NewTermsQuery("meta.tags.keyword", stringsToInterfaces(filter.TagsAny)...)
+		filterQueries = append(filterQueries, q)
+	}
+
 	// linkHashes filter.
 	if len(filter.LinkHashes) > 0 {
 		lhs := make([]string, len(filter.LinkHashes))
@@ -579,6 +829,23 @@ func makeFilterQueries(filter *store.SegmentFilter) []elastic.Query {
 		filterQueries = append(filterQueries, q)
 	}
 
+	// creation time filter.
+	if filter.CreatedAfter != nil || filter.CreatedBefore != nil {
+		q := elastic.NewRangeQuery("createdAt")
+		if filter.CreatedAfter != nil {
+			q = q.Gte(*filter.CreatedAfter)
+		}
+		if filter.CreatedBefore != nil {
+			q = q.Lt(*filter.CreatedBefore)
+		}
+		filterQueries = append(filterQueries, q)
+	}
+
+	// link data filter.
+	for i := range filter.Data {
+		filterQueries = append(filterQueries, makeDataQuery(&filter.Data[i]))
+	}
+
 	return filterQueries
 }
 
@@ -595,11 +862,24 @@ func (es *ESStore) genericSearch(ctx context.Context, filter *store.SegmentFilte
 		Type(docType)
 
 	// add pagination.
+	// Segments are sorted by priority then link hash to match
+	// github.com/stratumn/go-core/types.SegmentSlice ordering.
 	svc = svc.
 		Sort("priority", filter.Reverse).
-		From(filter.Pagination.Offset).
+		Sort("linkHash", !filter.Reverse).
 		Size(filter.Pagination.Limit)
 
+	if filter.Cursor != "" {
+		cursor, err := store.ParseSegmentCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		svc = svc.SearchAfter(cursor.Priority, cursor.LinkHash.String())
+	} else {
+		svc = svc.From(filter.Pagination.Offset)
+	}
+
 	// run search.
 	sr, err := svc.Query(q).Do(ctx)
 	if err != nil {
@@ -626,6 +906,7 @@ func (es *ESStore) genericSearch(ctx context.Context, filter *store.SegmentFilte
 	}
 
 	res.Segments.Sort(filter.Reverse)
+	res.NextCursor = store.NextSegmentCursor(res.Segments, filter.Pagination.Limit)
 
 	return res, nil
 }
@@ -668,3 +949,69 @@ func (es *ESStore) multiMatchQuery(ctx context.Context, query *SearchQuery) (*ty
 	// run search.
 	return es.genericSearch(ctx, &query.SegmentFilter, q)
 }
+
+func stringsToInterfaces(values []string) []interface{} {
+	res := make([]interface{}, len(values))
+	for i, v := range values {
+		res[i] = v
+	}
+	return res
+}
+
+// makeDataQuery translates a link data predicate to a query on the flattened
+// data fields.
+// Invalid predicates don't match any document.
+func makeDataQuery(p *store.DataPredicate) elastic.Query {
+	if p.Validate() != nil {
+		return elastic.NewBoolQuery().MustNot(elastic.NewMatchAllQuery())
+	}
+
+	if p.Op == store.DataExists {
+		return elastic.NewTermQuery("dataPaths", p.Path)
+	}
+
+	var valueQuery elastic.Query
+	switch p.Op {
+	case store.DataEqual:
+		valueQuery = makeDataValueQuery(p.Value)
+	case store.DataIn:
+		valueQueries := make([]elastic.Query, len(p.Values))
+		for i, v := range p.Values {
+			valueQueries[i] = makeDataValueQuery(v)
+		}
+		valueQuery = elastic.NewBoolQuery().Should(valueQueries...).MinimumNumberShouldMatch(1)
+	case store.DataRange:
+		q := elastic.NewRangeQuery("dataFields.num")
+		if p.Gt != nil {
+			q = q.Gt(*p.Gt)
+		}
+		if p.Gte != nil {
+			q = q.Gte(*p.Gte)
+		}
+		if p.Lt != nil {
+			q = q.Lt(*p.Lt)
+		}
+		if p.Lte != nil {
+			q = q.Lte(*p.Lte)
+		}
+		valueQuery = q
+	}
+
+	return elastic.NewNestedQuery("dataFields", elastic.NewBoolQuery().Must(
+		elastic.NewTermQuery("dataFields.path", p.Path),
+		valueQuery,
+	))
+}
+
+// makeDataValueQuery matches data fields having the given scalar value.
+func makeDataValueQuery(v interface{}) elastic.Query {
+	normalized, _ := store.NormalizeDataValue(v)
+	switch value := normalized.(type) {
+	case string:
+		return elastic.NewTermQuery("dataFields.str", value)
+	case bool:
+		return elastic.NewTermQuery("dataFields.bool", value)
+	default:
+		return elastic.NewTermQuery("dataFields.num", value)
+	}
+}
@@ -236,6 +236,55 @@ func (es *ESStore) DeleteValue(ctx context.Context, key []byte) ([]byte, error)
 
 }
 
+// IterateValues implements github.com/stratumn/go-core/store.KeyValueIterator.IterateValues.
+// Keys are saved as lowercase hex document IDs, which preserves their order.
+func (es *ESStore) IterateValues(ctx context.Context, start, end []byte, fn func(key, value []byte) error) error {
+	endKey := hex.EncodeToString(end)
+
+	// Pages are read after a given key, so the first key is read separately.
+	after := hex.EncodeToString(start)
+	if len(start) > 0 && (end == nil || after < endKey) {
+		value, err := es.getValue(ctx, after)
+		if err != nil {
+			return err
+		}
+
+		if value != nil {
+			if err := fn(start, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	for {
+		keys, values, err := es.getValuesAfter(ctx, after, valuesPageSize)
+		if err != nil {
+			return err
+		}
+
+		for i, hexKey := range keys {
+			if end != nil && hexKey >= endKey {
+				return nil
+			}
+
+			key, err := hex.DecodeString(hexKey)
+			if err != nil {
+				return types.WrapError(err, errorcode.Internal, store.Component, "could not decode key")
+			}
+
+			if err := fn(key, values[i]); err != nil {
+				return err
+			}
+		}
+
+		if len(keys) < valuesPageSize {
+			return nil
+		}
+
+		after = keys[len(keys)-1]
+	}
+}
+
 /********** Search feature **********/
 
 // SimpleSearchQuery searches through the store for segments matching query criteria
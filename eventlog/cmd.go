@@ -0,0 +1,49 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventlog
+
+import (
+	"flag"
+	"time"
+
+	"github.com/stratumn/go-core/store"
+)
+
+var (
+	enabled   bool
+	maxEvents uint64
+	maxAge    time.Duration
+)
+
+// RegisterFlags registers the flags used by WrapWithFlags.
+func RegisterFlags() {
+	flag.BoolVar(&enabled, "event_log", false, "Save store events in a sequenced log so that clients can resume from the last event they received")
+	flag.Uint64Var(&maxEvents, "event_log_max_events", 0, "Maximum number of events kept in the event log (0 keeps every event)")
+	flag.DurationVar(&maxAge, "event_log_max_age", 0, "Duration events are kept in the event log for (0 keeps events forever)")
+}
+
+// WrapWithFlags should be called after RegisterFlags and flag.Parse to wrap a
+// store with an event log configured using flag values.
+// The store is returned unchanged if the event log is disabled.
+func WrapWithFlags(a store.Adapter, kv store.KeyValueStore) (store.Adapter, error) {
+	if !enabled {
+		return a, nil
+	}
+
+	return Wrap(a, kv, &Config{
+		MaxEvents: maxEvents,
+		MaxAge:    maxAge,
+	})
+}
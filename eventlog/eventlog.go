@@ -0,0 +1,420 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package eventlog implements a durable, sequenced log of the events of a
+// store.
+//
+// Every event of the underlying store is given a monotonically increasing
+// sequence number and saved in a key-value store before being forwarded to
+// the event channels. Consumers that disconnect can read the events they
+// missed from the sequence number of the last event they received.
+// Old events are pruned according to the retention configuration.
+//
+// Events are saved once the underlying store has written the data they
+// describe, so the last events can be missing from the log if the process
+// stops in between. When the key-value store implements store.KeyValueBatch,
+// each event is saved atomically with the log's boundaries.
+//
+// Sequence numbers are assigned by the process wrapping the store: a log must
+// have a single writer, several processes must never share one.
+//
+// If an event can't be saved, the log stops: neither this event nor the
+// following ones are forwarded and ReadEvents fails, since consumers couldn't
+// resume from a consistent sequence anymore. The process must be restarted.
+package eventlog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-core/monitoring"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+)
+
+const (
+	// DefaultEventsChanSize is the default size of the channel receiving the
+	// events of the underlying store.
+	DefaultEventsChanSize = 256
+
+	// keyPrefix is the prefix of the keys used by the event log.
+	keyPrefix = "_eventlog/"
+)
+
+// headKey is the key of the log's boundaries.
+var headKey = []byte(keyPrefix + "head")
+
+// eventKey returns the key of an event.
+// Sequence numbers are zero-padded so that keys sort in sequence order.
+func eventKey(seq uint64) []byte {
+	return []byte(fmt.Sprintf("%sevents/%020d", keyPrefix, seq))
+}
+
+// Config contains configuration options for the event log.
+type Config struct {
+	// MaxEvents is the maximum number of events kept in the log.
+	// Zero keeps every event.
+	MaxEvents uint64
+
+	// MaxAge is the duration events are kept for. Zero keeps events forever.
+	MaxAge time.Duration
+
+	// EventsChanSize is the size of the channel receiving the events of the
+	// underlying store. Defaults to DefaultEventsChanSize.
+	EventsChanSize int
+}
+
+// head contains the boundaries of the log.
+// The log is empty when First is greater than Last.
+type head struct {
+	First uint64 `json:"first"`
+	Last  uint64 `json:"last"`
+}
+
+// record is an event saved in the log.
+type record struct {
+	Event     *store.Event `json:"event"`
+	CreatedAt time.Time    `json:"createdAt"`
+}
+
+// EventLog is a decorator for the store.Adapter interface.
+// It wraps a real store.Adapter implementation, saves its events in a
+// key-value store and implements store.EventReader.
+type EventLog struct {
+	store.Adapter
+
+	config *Config
+	kv     store.KeyValueStore
+
+	mu         sync.RWMutex
+	head       head
+	err        error
+	eventChans []chan *store.Event
+}
+
+// Wrap wraps an existing store adapter to log its events in the given
+// key-value store, which is usually the store itself.
+// The log is resumed if the key-value store already contains one.
+func Wrap(a store.Adapter, kv store.KeyValueStore, config *Config) (*EventLog, error) {
+	c := *config
+	if c.EventsChanSize <= 0 {
+		c.EventsChanSize = DefaultEventsChanSize
+	}
+
+	l := &EventLog{
+		Adapter: a,
+		config:  &c,
+		kv:      kv,
+		head:    head{First: 1},
+	}
+
+	data, err := kv.GetValue(context.Background(), headKey)
+	if err != nil {
+		return nil, types.WrapError(err, errorcode.Unavailable, store.Component, "could not load event log")
+	}
+
+	if data != nil {
+		if err := json.Unmarshal(data, &l.head); err != nil {
+			return nil, types.WrapError(err, errorcode.DataLoss, store.Component, "could not load event log")
+		}
+	}
+
+	if err := l.repair(context.Background()); err != nil {
+		return nil, err
+	}
+
+	events := make(chan *store.Event, c.EventsChanSize)
+	a.AddStoreEventChannel(events)
+	go l.record(events)
+
+	return l, nil
+}
+
+// AddStoreEventChannel implements
+// github.com/stratumn/go-core/store.Adapter.AddStoreEventChannel.
+// Events sent to the channel have a sequence number.
+func (l *EventLog) AddStoreEventChannel(eventChan chan *store.Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.eventChans = append(l.eventChans, eventChan)
+}
+
+// record saves the events of the underlying store and forwards them.
+// Once an event couldn't be saved, events are dropped.
+func (l *EventLog) record(events <-chan *store.Event) {
+	ctx := context.Background()
+
+	for e := range events {
+		// The event is shared with the other channels of the underlying
+		// store so it must not be modified.
+		event := *e
+
+		if err := l.append(ctx, &event); err != nil {
+			if err != errStopped {
+				monitoring.LogEntry().WithField("error", err).Error("Could not save event, the event log is stopped")
+			}
+
+			continue
+		}
+
+		l.mu.RLock()
+		for _, c := range l.eventChans {
+			c <- &event
+		}
+		l.mu.RUnlock()
+	}
+}
+
+// errStopped is returned when appending to a stopped log.
+var errStopped = types.NewError(errorcode.Unavailable, store.Component, "event log stopped")
+
+// append saves an event, assigning its sequence number, and prunes the log.
+// The log is stopped if the event can't be saved.
+func (l *EventLog) append(ctx context.Context, event *store.Event) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.err != nil {
+		return errStopped
+	}
+
+	h := l.head
+	h.Last++
+	event.Sequence = h.Last
+
+	writes, err := l.appendWrites(ctx, event, &h)
+	if err == nil {
+		err = l.write(ctx, writes)
+	}
+	if err != nil {
+		l.err = err
+		return err
+	}
+
+	l.head = h
+
+	return nil
+}
+
+// appendWrites returns the writes saving an event with the given sequence
+// number and updates the boundaries accordingly.
+func (l *EventLog) appendWrites(ctx context.Context, event *store.Event, h *head) ([]store.KeyValueWrite, error) {
+	r, err := encode(&record{Event: event, CreatedAt: time.Now()})
+	if err != nil {
+		return nil, err
+	}
+
+	writes := []store.KeyValueWrite{{Key: eventKey(h.Last), Value: r}}
+
+	pruned, err := l.prune(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, seq := range pruned {
+		writes = append(writes, store.KeyValueWrite{Key: eventKey(seq), Delete: true})
+	}
+
+	encodedHead, err := encode(h)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(writes, store.KeyValueWrite{Key: headKey, Value: encodedHead}), nil
+}
+
+// prune returns the sequence numbers of the events that exceed the retention
+// configuration and updates the boundaries accordingly.
+// The oldest event is always kept if it is the last one.
+func (l *EventLog) prune(ctx context.Context, h *head) ([]uint64, error) {
+	var pruned []uint64
+	for h.First < h.Last {
+		if l.config.MaxEvents == 0 || h.Last-h.First+1 <= l.config.MaxEvents {
+			if l.config.MaxAge <= 0 {
+				return pruned, nil
+			}
+
+			r, err := l.get(ctx, h.First)
+			if err != nil {
+				return nil, err
+			}
+
+			if r != nil && time.Since(r.CreatedAt) <= l.config.MaxAge {
+				return pruned, nil
+			}
+		}
+
+		pruned = append(pruned, h.First)
+		h.First++
+	}
+
+	return pruned, nil
+}
+
+// write applies the writes, atomically if the key-value store allows it.
+func (l *EventLog) write(ctx context.Context, writes []store.KeyValueWrite) error {
+	if b, ok := l.kv.(store.KeyValueBatch); ok {
+		if err := b.WriteValues(ctx, writes); err != nil {
+			return types.WrapError(err, errorcode.Unavailable, store.Component, "could not save event")
+		}
+
+		return nil
+	}
+
+	for _, w := range writes {
+		var err error
+		if w.Delete {
+			_, err = l.kv.DeleteValue(ctx, w.Key)
+		} else {
+			err = l.kv.SetValue(ctx, w.Key, w.Value)
+		}
+		if err != nil {
+			return types.WrapError(err, errorcode.Unavailable, store.Component, "could not save event")
+		}
+	}
+
+	return nil
+}
+
+// repair fixes the boundaries of a log whose last append was interrupted,
+// which can only happen when the key-value store doesn't write atomically:
+// events saved after the last saved boundaries are kept and pruned events
+// are skipped.
+func (l *EventLog) repair(ctx context.Context) error {
+	for {
+		r, err := l.get(ctx, l.head.Last+1)
+		if err != nil {
+			return err
+		}
+		if r == nil {
+			break
+		}
+
+		l.head.Last++
+	}
+
+	for l.head.First < l.head.Last {
+		r, err := l.get(ctx, l.head.First)
+		if err != nil {
+			return err
+		}
+		if r != nil {
+			break
+		}
+
+		l.head.First++
+	}
+
+	return nil
+}
+
+func encode(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not save event")
+	}
+
+	return data, nil
+}
+
+// get loads an event. It returns nil if the event doesn't exist.
+func (l *EventLog) get(ctx context.Context, seq uint64) (*record, error) {
+	data, err := l.kv.GetValue(ctx, eventKey(seq))
+	if err != nil {
+		return nil, types.WrapError(err, errorcode.Unavailable, store.Component, "could not read event")
+	}
+
+	if data == nil {
+		return nil, nil
+	}
+
+	var r record
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, types.WrapError(err, errorcode.DataLoss, store.Component, "could not read event")
+	}
+
+	return &r, nil
+}
+
+// Sequence returns the sequence number of the last event saved in the log.
+func (l *EventLog) Sequence() uint64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.head.Last
+}
+
+// ReadEvents implements github.com/stratumn/go-core/store.EventReader.ReadEvents.
+// Reading from zero returns the oldest events still in the log.
+func (l *EventLog) ReadEvents(ctx context.Context, from uint64, limit int) ([]*store.Event, error) {
+	l.mu.RLock()
+	h, stopped := l.head, l.err
+	l.mu.RUnlock()
+
+	if stopped != nil {
+		return nil, types.WrapError(stopped, errorcode.Unavailable, store.Component, "event log stopped")
+	}
+
+	if from == 0 {
+		from = h.First
+	}
+
+	if from < h.First {
+		return nil, types.NewErrorf(errorcode.OutOfRange, store.Component, "events before %d were pruned", h.First)
+	}
+
+	var events []*store.Event
+	for seq := from; seq <= h.Last && (limit <= 0 || len(events) < limit); seq++ {
+		r, err := l.get(ctx, seq)
+		if err != nil {
+			return nil, err
+		}
+
+		// The event was pruned after the boundaries were read.
+		if r == nil {
+			return nil, types.NewErrorf(errorcode.OutOfRange, store.Component, "event %d was pruned", seq)
+		}
+
+		r.Event.Sequence = seq
+		events = append(events, r.Event)
+	}
+
+	return events, nil
+}
+
+// IterateSegments delegates the call to the underlying store.
+func (l *EventLog) IterateSegments(ctx context.Context, filter *store.SegmentFilter) (store.SegmentIterator, error) {
+	return store.IterateSegments(ctx, l.Adapter, filter)
+}
+
+// GetAncestors delegates the call to the underlying store.
+func (l *EventLog) GetAncestors(ctx context.Context, linkHash chainscript.LinkHash, depth int) (types.SegmentSlice, error) {
+	return store.GetAncestors(ctx, l.Adapter, linkHash, depth)
+}
+
+// GetDescendants delegates the call to the underlying store.
+func (l *EventLog) GetDescendants(ctx context.Context, linkHash chainscript.LinkHash, depth int) (types.SegmentSlice, error) {
+	return store.GetDescendants(ctx, l.Adapter, linkHash, depth)
+}
+
+// GetMapHeads delegates the call to the underlying store.
+func (l *EventLog) GetMapHeads(ctx context.Context, process, mapID string) (types.SegmentSlice, error) {
+	return store.GetMapHeads(ctx, l.Adapter, process, mapID)
+}
@@ -0,0 +1,236 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventlog
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-chainscript/chainscripttest"
+	"github.com/stratumn/go-core/dummystore"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/store/storetestcases"
+	"github.com/stratumn/go-core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newEventLog wraps a new dummystore with an event log saved in kv and
+// returns a channel receiving its events.
+func newEventLog(t *testing.T, kv store.KeyValueStore, config *Config) (*EventLog, chan *store.Event) {
+	l, err := Wrap(dummystore.New(&dummystore.Config{}), kv, config)
+	require.NoError(t, err)
+
+	events := make(chan *store.Event, 16)
+	l.AddStoreEventChannel(events)
+
+	return l, events
+}
+
+// createLinks creates links and waits for their events.
+func createLinks(t *testing.T, l *EventLog, events chan *store.Event, count int) []*chainscript.Link {
+	var links []*chainscript.Link
+	for i := 0; i < count; i++ {
+		link := chainscripttest.RandomLink(t)
+		_, err := l.CreateLink(context.Background(), link)
+		require.NoError(t, err)
+		links = append(links, link)
+
+		select {
+		case <-events:
+		case <-time.After(time.Second):
+			t.Fatal("event not received")
+		}
+	}
+
+	return links
+}
+
+func TestEventLog(t *testing.T) {
+	storetestcases.Factory{
+		New: func() (store.Adapter, error) {
+			a := dummystore.New(&dummystore.Config{})
+			return Wrap(a, a, &Config{})
+		},
+	}.RunStoreTests(t)
+}
+
+func TestEventLog_Sequence(t *testing.T) {
+	ctx := context.Background()
+	l, events := newEventLog(t, dummystore.New(&dummystore.Config{}), &Config{})
+
+	link := chainscripttest.RandomLink(t)
+	linkHash, err := l.CreateLink(ctx, link)
+	require.NoError(t, err)
+	require.NoError(t, l.AddEvidence(ctx, linkHash, chainscripttest.RandomEvidence(t)))
+
+	for i := 1; i <= 2; i++ {
+		select {
+		case e := <-events:
+			assert.Equal(t, uint64(i), e.Sequence)
+		case <-time.After(time.Second):
+			t.Fatal("event not received")
+		}
+	}
+
+	assert.Equal(t, uint64(2), l.Sequence())
+
+	t.Run("reads every event", func(t *testing.T) {
+		logged, err := l.ReadEvents(ctx, 0, 0)
+		require.NoError(t, err)
+		require.Len(t, logged, 2)
+
+		assert.Equal(t, store.SavedLinks, logged[0].EventType)
+		assert.Equal(t, uint64(1), logged[0].Sequence)
+		links := logged[0].Data.([]*chainscript.Link)
+		require.Len(t, links, 1)
+		chainscripttest.LinksEqual(t, link, links[0])
+
+		assert.Equal(t, store.SavedEvidences, logged[1].EventType)
+		assert.Equal(t, uint64(2), logged[1].Sequence)
+		assert.Contains(t, logged[1].Data, linkHash.String())
+	})
+
+	t.Run("reads from a sequence number", func(t *testing.T) {
+		logged, err := l.ReadEvents(ctx, 2, 10)
+		require.NoError(t, err)
+		require.Len(t, logged, 1)
+		assert.Equal(t, uint64(2), logged[0].Sequence)
+	})
+
+	t.Run("limits events", func(t *testing.T) {
+		logged, err := l.ReadEvents(ctx, 1, 1)
+		require.NoError(t, err)
+		require.Len(t, logged, 1)
+		assert.Equal(t, uint64(1), logged[0].Sequence)
+	})
+
+	t.Run("reads past the end", func(t *testing.T) {
+		logged, err := l.ReadEvents(ctx, 3, 0)
+		require.NoError(t, err)
+		assert.Empty(t, logged)
+	})
+}
+
+func TestEventLog_Resume(t *testing.T) {
+	kv := dummystore.New(&dummystore.Config{})
+
+	l, events := newEventLog(t, kv, &Config{})
+	createLinks(t, l, events, 2)
+
+	// A new log using the same key-value store continues the sequence.
+	l, events = newEventLog(t, kv, &Config{})
+	assert.Equal(t, uint64(2), l.Sequence())
+
+	createLinks(t, l, events, 1)
+	assert.Equal(t, uint64(3), l.Sequence())
+
+	logged, err := l.ReadEvents(context.Background(), 0, 0)
+	require.NoError(t, err)
+	assert.Len(t, logged, 3)
+}
+
+func TestEventLog_Retention(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("max events", func(t *testing.T) {
+		l, events := newEventLog(t, dummystore.New(&dummystore.Config{}), &Config{MaxEvents: 2})
+		links := createLinks(t, l, events, 3)
+
+		logged, err := l.ReadEvents(ctx, 0, 0)
+		require.NoError(t, err)
+		require.Len(t, logged, 2)
+		assert.Equal(t, uint64(2), logged[0].Sequence)
+		chainscripttest.LinksEqual(t, links[1], logged[0].Data.([]*chainscript.Link)[0])
+
+		_, err = l.ReadEvents(ctx, 1, 0)
+		require.Error(t, err)
+		assert.Equal(t, errorcode.OutOfRange, err.(*types.Error).Code)
+	})
+
+	t.Run("max age", func(t *testing.T) {
+		l, events := newEventLog(t, dummystore.New(&dummystore.Config{}), &Config{MaxAge: 10 * time.Millisecond})
+		createLinks(t, l, events, 2)
+
+		time.Sleep(20 * time.Millisecond)
+		createLinks(t, l, events, 1)
+
+		logged, err := l.ReadEvents(ctx, 0, 0)
+		require.NoError(t, err)
+		require.Len(t, logged, 1)
+		assert.Equal(t, uint64(3), logged[0].Sequence)
+	})
+}
+
+// failingKeyValueStore fails to set values.
+// It doesn't implement store.KeyValueBatch.
+type failingKeyValueStore struct {
+	store.KeyValueStore
+}
+
+func (kv *failingKeyValueStore) SetValue(ctx context.Context, key, value []byte) error {
+	return types.NewError(errorcode.Unavailable, store.Component, "unavailable")
+}
+
+func TestEventLog_Stop(t *testing.T) {
+	ctx := context.Background()
+	kv := &failingKeyValueStore{KeyValueStore: dummystore.New(&dummystore.Config{})}
+	l, events := newEventLog(t, kv, &Config{})
+
+	for i := 0; i < 2; i++ {
+		_, err := l.CreateLink(ctx, chainscripttest.RandomLink(t))
+		require.NoError(t, err)
+	}
+
+	select {
+	case e := <-events:
+		t.Fatalf("event %d forwarded by a stopped log", e.Sequence)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	assert.Equal(t, uint64(0), l.Sequence())
+
+	_, err := l.ReadEvents(ctx, 0, 0)
+	require.Error(t, err)
+	assert.Equal(t, errorcode.Unavailable, err.(*types.Error).Code)
+}
+
+func TestEventLog_Repair(t *testing.T) {
+	ctx := context.Background()
+	kv := dummystore.New(&dummystore.Config{})
+
+	l, events := newEventLog(t, kv, &Config{MaxEvents: 2})
+	createLinks(t, l, events, 3)
+
+	// Simulate an append interrupted after saving the event and pruning
+	// the oldest one but before saving the boundaries.
+	data, err := kv.GetValue(ctx, eventKey(3))
+	require.NoError(t, err)
+	require.NoError(t, kv.SetValue(ctx, eventKey(4), data))
+	_, err = kv.DeleteValue(ctx, eventKey(2))
+	require.NoError(t, err)
+
+	l, _ = newEventLog(t, kv, &Config{MaxEvents: 2})
+	assert.Equal(t, uint64(4), l.Sequence())
+
+	logged, err := l.ReadEvents(ctx, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, logged, 2)
+	assert.Equal(t, uint64(3), logged[0].Sequence)
+	assert.Equal(t, uint64(4), logged[1].Sequence)
+}
@@ -47,7 +47,7 @@ func (b *Batch) Write(ctx context.Context) (err error) {
 	defer b.originalFileStore.mutex.Unlock()
 
 	for _, link := range b.Links {
-		if _, err := b.originalFileStore.createLink(link); err != nil {
+		if _, err := b.originalFileStore.createLink(ctx, link); err != nil {
 			return err
 		}
 	}
@@ -17,8 +17,9 @@
 //
 // The segments are stored as JSON files named after the link hashes.
 // It's a convenient store to use during the development of an agent.
-// However, because it doesn't use an index, it's very slow, and shouldn't be
-// used for production.
+// Links are indexed by map ID, process, step, previous link hash, tags and
+// references in a LevelDB database, so most queries don't need to read all
+// the files. It still shouldn't be used for production.
 package filestore
 
 import (
@@ -32,6 +33,7 @@ import (
 	"sort"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/stratumn/go-chainscript"
 	"github.com/stratumn/go-core/leveldbstore"
@@ -58,6 +60,8 @@ type FileStore struct {
 	eventChans []chan *store.Event
 	mutex      sync.RWMutex // simple global mutex
 	kvDB       store.KeyValueStore
+	rawKVDB    *leveldbstore.LevelDBStore
+	indexDB    *leveldbstore.LevelDBStore
 }
 
 // Config contains configuration options for the store.
@@ -81,6 +85,8 @@ type Info struct {
 }
 
 // New creates an instance of a FileStore.
+// Links are indexed if the indexes are missing, which can take some time on
+// large stores.
 func New(config *Config) (*FileStore, error) {
 	kvStoreConfig := &leveldbstore.Config{
 		Path: config.Path,
@@ -90,12 +96,29 @@ func New(config *Config) (*FileStore, error) {
 		return nil, err
 	}
 
-	return &FileStore{
+	// The indexes are kept in a separate database so that they can't collide
+	// with the store's key-value pairs.
+	indexDB, err := leveldbstore.New(&leveldbstore.Config{
+		Path: filepath.Join(config.Path, indexDir),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	a := &FileStore{
 		config:     config,
 		eventChans: nil,
 		mutex:      sync.RWMutex{},
 		kvDB:       monitoring.WrapKeyValueStore(db, "leveldbstore"),
-	}, nil
+		rawKVDB:    db,
+		indexDB:    indexDB,
+	}
+
+	if err := a.buildIndexes(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return a, nil
 }
 
 /********** Store adapter implementation **********/
@@ -127,10 +150,10 @@ func (a *FileStore) CreateLink(ctx context.Context, link *chainscript.Link) (cha
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
 
-	return a.createLink(link)
+	return a.createLink(ctx, link)
 }
 
-func (a *FileStore) createLink(link *chainscript.Link) (chainscript.LinkHash, error) {
+func (a *FileStore) createLink(ctx context.Context, link *chainscript.Link) (chainscript.LinkHash, error) {
 	linkHash, err := link.Hash()
 	if err != nil {
 		return nil, types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not hash link")
@@ -155,10 +178,30 @@ func (a *FileStore) createLink(link *chainscript.Link) (chainscript.LinkHash, er
 		return linkHash, types.WrapError(chainscript.ErrOutDegree, errorcode.FailedPrecondition, store.Component, "could not create link")
 	}
 
+	// The indexes are marked as incomplete until the link is indexed, so
+	// that they are rebuilt when the store restarts if the process stops
+	// after the file is written.
+	if err := a.setIndexesComplete(ctx, false); err != nil {
+		return linkHash, err
+	}
+
+	createdAt := time.Now()
 	if err := ioutil.WriteFile(linkPath, js, 0644); err != nil {
+		a.restoreIndexesComplete(ctx)
 		return linkHash, types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not write file")
 	}
 
+	// The link is indexed once its file is written so that the indexes never
+	// reference a missing link. If it can't be indexed the file is removed,
+	// otherwise indexed queries would never return it.
+	if err := a.indexLink(ctx, link, linkHash, createdAt); err != nil {
+		os.Remove(linkPath)
+		a.restoreIndexesComplete(ctx)
+		return linkHash, err
+	}
+
+	a.restoreIndexesComplete(ctx)
+
 	err = a.incrementChildCount(link.PrevLinkHash())
 	if err != nil {
 		return linkHash, err
@@ -301,10 +344,8 @@ func (a *FileStore) getSegment(ctx context.Context, linkHash chainscript.LinkHas
 func (a *FileStore) FindSegments(ctx context.Context, filter *store.SegmentFilter) (*types.PaginatedSegments, error) {
 	segments := &types.PaginatedSegments{}
 
-	err := a.forEach(ctx, func(segment *chainscript.Segment) error {
-		if filter.Match(segment) {
-			segments.Segments = append(segments.Segments, segment)
-		}
+	err := a.forEachMatch(ctx, filter, func(segment *chainscript.Segment, _ time.Time) error {
+		segments.Segments = append(segments.Segments, segment)
 		return nil
 	})
 	if err != nil {
@@ -315,29 +356,56 @@ func (a *FileStore) FindSegments(ctx context.Context, filter *store.SegmentFilte
 
 	segments.Segments.Sort(filter.Reverse)
 
-	return filter.Pagination.PaginateSegments(segments), nil
+	return filter.PaginateSegments(segments)
 }
 
-// GetMapIDs implements github.com/stratumn/go-core/store.SegmentReader.GetMapIDs.
-func (a *FileStore) GetMapIDs(ctx context.Context, filter *store.MapFilter) ([]string, error) {
-	set := map[string]struct{}{}
-	err := a.forEach(ctx, func(segment *chainscript.Segment) error {
-		if filter.Match(segment) {
-			set[segment.Link.Meta.MapId] = struct{}{}
+// IterateSegments implements github.com/stratumn/go-core/store.SegmentIterable.IterateSegments.
+// Only the position of the matching segments is kept in memory: segments are
+// read from disk again when the iterator reaches them.
+func (a *FileStore) IterateSegments(ctx context.Context, filter *store.SegmentFilter) (store.SegmentIterator, error) {
+	var start *store.SegmentCursor
+	if filter.Cursor != "" {
+		cursor, err := store.ParseSegmentCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		start = cursor
+	}
+
+	var positions []*store.SegmentCursor
+	err := a.forEachMatch(ctx, filter, func(segment *chainscript.Segment, _ time.Time) error {
+		if start != nil && !start.After(segment, filter.Reverse) {
+			return nil
 		}
 
+		positions = append(positions, store.NewSegmentCursor(segment))
 		return nil
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	var mapIDs []string
-	for mapID := range set {
-		mapIDs = append(mapIDs, mapID)
+	sort.Slice(positions, func(i, j int) bool {
+		if filter.Reverse {
+			return positions[i].Compare(positions[j]) > 0
+		}
+		return positions[i].Compare(positions[j]) < 0
+	})
+
+	return &segmentIterator{ctx: ctx, adapter: a, positions: positions, current: -1}, nil
+}
+
+// GetMapIDs implements github.com/stratumn/go-core/store.SegmentReader.GetMapIDs.
+func (a *FileStore) GetMapIDs(ctx context.Context, filter *store.MapFilter) ([]string, error) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	mapIDs, err := a.mapIDs(ctx, filter)
+	if err != nil {
+		return nil, err
 	}
 
-	sort.Strings(mapIDs)
 	return filter.Pagination.PaginateStrings(mapIDs), nil
 }
 
@@ -399,6 +467,16 @@ func (a *FileStore) DeleteValue(ctx context.Context, key []byte) ([]byte, error)
 	return a.kvDB.DeleteValue(ctx, key)
 }
 
+// IterateValues implements github.com/stratumn/go-core/store.KeyValueIterator.IterateValues.
+func (a *FileStore) IterateValues(ctx context.Context, start, end []byte, fn func(key, value []byte) error) error {
+	return a.rawKVDB.IterateValues(ctx, start, end, fn)
+}
+
+// WriteValues implements github.com/stratumn/go-core/store.KeyValueBatch.WriteValues.
+func (a *FileStore) WriteValues(ctx context.Context, writes []store.KeyValueWrite) error {
+	return a.rawKVDB.WriteValues(ctx, writes)
+}
+
 /********** Utilities **********/
 
 func (a *FileStore) initDir() error {
@@ -420,10 +498,67 @@ func (a *FileStore) getLinkChildCountPath(linkHash chainscript.LinkHash) string
 
 var linkFileRegex = regexp.MustCompile(`(.*)\.json$`)
 
-func (a *FileStore) forEach(ctx context.Context, fn func(*chainscript.Segment) error) error {
+// forEachMatch calls fn with every segment that matches the filter and the
+// time at which it was created.
+// Candidate segments are found with an index when the filter allows it.
+func (a *FileStore) forEachMatch(ctx context.Context, filter *store.SegmentFilter, fn func(*chainscript.Segment, time.Time) error) error {
 	a.mutex.RLock()
 	defer a.mutex.RUnlock()
 
+	match := func(segment *chainscript.Segment, createdAt time.Time) error {
+		if filter.Match(segment) && filter.MatchCreatedAt(createdAt) {
+			return fn(segment, createdAt)
+		}
+		return nil
+	}
+
+	linkHashes, indexed, err := a.candidates(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if !indexed {
+		return a.forEach(ctx, match)
+	}
+
+	seen := make(map[string]struct{}, len(linkHashes))
+	for _, linkHash := range linkHashes {
+		if _, ok := seen[string(linkHash)]; ok {
+			continue
+		}
+		seen[string(linkHash)] = struct{}{}
+
+		file, err := os.Stat(a.getLinkPath(linkHash))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return types.WrapError(err, errorcode.Unavailable, store.Component, "could not read link file")
+		}
+
+		segment, err := a.getSegment(ctx, linkHash)
+		if err != nil {
+			return err
+		}
+		if segment == nil {
+			continue
+		}
+
+		createdAt, err := a.getCreatedAt(ctx, linkHash, file.ModTime())
+		if err != nil {
+			return err
+		}
+
+		if err = match(segment, createdAt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// forEach calls fn with every segment of the store and the time at which it
+// was created. The caller must hold the mutex.
+func (a *FileStore) forEach(ctx context.Context, fn func(*chainscript.Segment, time.Time) error) error {
 	files, err := ioutil.ReadDir(a.config.Path)
 	if os.IsNotExist(err) {
 		return nil
@@ -448,7 +583,13 @@ func (a *FileStore) forEach(ctx context.Context, fn func(*chainscript.Segment) e
 			if segment == nil {
 				return types.NewErrorf(errorcode.NotFound, store.Component, "could not find segment %q", filepath.Base(name))
 			}
-			if err = fn(segment); err != nil {
+
+			createdAt, err := a.getCreatedAt(ctx, linkHash, file.ModTime())
+			if err != nil {
+				return err
+			}
+
+			if err = fn(segment, createdAt); err != nil {
 				return err
 			}
 		}
@@ -456,3 +597,45 @@ func (a *FileStore) forEach(ctx context.Context, fn func(*chainscript.Segment) e
 
 	return nil
 }
+
+// segmentIterator lazily loads the segments at the given positions.
+type segmentIterator struct {
+	ctx       context.Context
+	adapter   *FileStore
+	positions []*store.SegmentCursor
+	current   int
+	segment   *chainscript.Segment
+	err       error
+}
+
+func (it *segmentIterator) Next() bool {
+	it.segment = nil
+	for it.err == nil && it.current+1 < len(it.positions) {
+		it.current++
+		segment, err := it.adapter.GetSegment(it.ctx, it.positions[it.current].LinkHash)
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		if segment != nil {
+			it.segment = segment
+			return true
+		}
+	}
+
+	return false
+}
+
+func (it *segmentIterator) Segment() *chainscript.Segment {
+	return it.segment
+}
+
+func (it *segmentIterator) Err() error {
+	return it.err
+}
+
+func (it *segmentIterator) Close() error {
+	it.positions, it.segment = nil, nil
+	return nil
+}
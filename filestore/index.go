@@ -0,0 +1,320 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filestore
+
+import (
+	"context"
+	"encoding/binary"
+	"strings"
+	"time"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-core/monitoring"
+	"github.com/stratumn/go-core/store"
+)
+
+// Indexes are saved in their own key-value store, in the indexDir
+// subdirectory of the store's path.
+// Their keys are an index prefix, the indexed value prefixed by its length
+// (so that a value is never the prefix of another one) and the link hash.
+// All the links with a given value can be found by iterating over the keys
+// starting with the index prefix and the value.
+const (
+	indexDir = "index"
+
+	// indexVersionKey is set once all the links have been indexed.
+	// If it is missing the indexes are rebuilt when the store is created.
+	// It is removed while a link is written and indexed.
+	indexVersionKey = "index:version"
+	indexVersion    = "2"
+
+	indexPrefix = "index:"
+
+	// Map ID -> nothing.
+	mapsIndex = indexPrefix + "maps:"
+
+	// Process + map ID -> nothing.
+	processMapsIndex = indexPrefix + "process_maps:"
+
+	// Link hash -> creation time in nanoseconds (big endian).
+	// Creation times can't be rebuilt from the link files so they aren't
+	// removed with the indexes.
+	createdAtPrefix = "created_at:"
+)
+
+// linkIndex maps some values of a link to its hash.
+type linkIndex struct {
+	prefix string
+	values func(*chainscript.Link) []string
+}
+
+var (
+	mapIDIndex = &linkIndex{
+		prefix: indexPrefix + "map_id:",
+		values: func(link *chainscript.Link) []string {
+			return []string{link.Meta.MapId}
+		},
+	}
+
+	processIndex = &linkIndex{
+		prefix: indexPrefix + "process:",
+		values: func(link *chainscript.Link) []string {
+			return []string{link.Meta.Process.Name}
+		},
+	}
+
+	stepIndex = &linkIndex{
+		prefix: indexPrefix + "step:",
+		values: func(link *chainscript.Link) []string {
+			return []string{link.Meta.Step}
+		},
+	}
+
+	prevLinkHashIndex = &linkIndex{
+		prefix: indexPrefix + "prev_link_hash:",
+		values: func(link *chainscript.Link) []string {
+			if len(link.PrevLinkHash()) == 0 {
+				return nil
+			}
+			return []string{string(link.PrevLinkHash())}
+		},
+	}
+
+	tagIndex = &linkIndex{
+		prefix: indexPrefix + "tag:",
+		values: func(link *chainscript.Link) []string {
+			var values []string
+			for tag := range link.TagMap() {
+				values = append(values, tag)
+			}
+			return values
+		},
+	}
+
+	refIndex = &linkIndex{
+		prefix: indexPrefix + "ref:",
+		values: func(link *chainscript.Link) []string {
+			var values []string
+			for _, ref := range link.Meta.Refs {
+				values = append(values, string(ref.LinkHash))
+			}
+			return values
+		},
+	}
+
+	linkIndexes = []*linkIndex{
+		mapIDIndex,
+		processIndex,
+		stepIndex,
+		prevLinkHashIndex,
+		tagIndex,
+		refIndex,
+	}
+)
+
+// lengthPrefixed prefixes a value with its length.
+func lengthPrefixed(value string) string {
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, uint64(len(value)))
+	return string(buf[:n]) + value
+}
+
+// indexEntries returns the index entries of a link.
+func indexEntries(link *chainscript.Link, linkHash chainscript.LinkHash, createdAt time.Time) map[string][]byte {
+	created := make([]byte, 8)
+	binary.BigEndian.PutUint64(created, uint64(createdAt.UnixNano()))
+
+	entries := map[string][]byte{
+		mapsIndex + link.Meta.MapId: {},
+		processMapsIndex + lengthPrefixed(link.Meta.Process.Name) + link.Meta.MapId: {},
+		createdAtPrefix + string(linkHash):                                          created,
+	}
+
+	for _, idx := range linkIndexes {
+		for _, value := range idx.values(link) {
+			entries[idx.prefix+lengthPrefixed(value)+string(linkHash)] = []byte{}
+		}
+	}
+
+	return entries
+}
+
+// indexLink atomically adds a link to all the indexes and saves its creation
+// time.
+func (a *FileStore) indexLink(ctx context.Context, link *chainscript.Link, linkHash chainscript.LinkHash, createdAt time.Time) error {
+	return a.indexDB.SetValues(ctx, indexEntries(link, linkHash, createdAt))
+}
+
+// setIndexesComplete sets or removes the index version.
+func (a *FileStore) setIndexesComplete(ctx context.Context, complete bool) error {
+	if !complete {
+		_, err := a.indexDB.DeleteValue(ctx, []byte(indexVersionKey))
+		return err
+	}
+
+	return a.indexDB.SetValue(ctx, []byte(indexVersionKey), []byte(indexVersion))
+}
+
+// restoreIndexesComplete sets the index version once a write is over.
+// If it can't be set, the indexes are rebuilt when the store restarts.
+func (a *FileStore) restoreIndexesComplete(ctx context.Context) {
+	if err := a.setIndexesComplete(ctx, true); err != nil {
+		monitoring.LogEntry().WithField("error", err).Warn("Could not set index version")
+	}
+}
+
+// getCreatedAt returns the time at which a link was added to the store.
+// Links added by versions that didn't save it fall back to the modification
+// time of their file until the indexes are rebuilt, which then saves it.
+func (a *FileStore) getCreatedAt(ctx context.Context, linkHash chainscript.LinkHash, modTime time.Time) (time.Time, error) {
+	v, err := a.indexDB.GetValue(ctx, []byte(createdAtPrefix+string(linkHash)))
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(v) != 8 {
+		return modTime, nil
+	}
+
+	return time.Unix(0, int64(binary.BigEndian.Uint64(v))), nil
+}
+
+// lookup returns the hashes of the links indexed with the given value.
+func (a *FileStore) lookup(ctx context.Context, idx *linkIndex, value string) ([]chainscript.LinkHash, error) {
+	var linkHashes []chainscript.LinkHash
+
+	prefix := idx.prefix + lengthPrefixed(value)
+	err := a.indexDB.IteratePrefix(ctx, []byte(prefix), func(key, _ []byte) error {
+		linkHashes = append(linkHashes, chainscript.LinkHash(key[len(prefix):]))
+		return nil
+	})
+
+	return linkHashes, err
+}
+
+// lookupAny returns the hashes of the links indexed with any of the given
+// values, without duplicates.
+func (a *FileStore) lookupAny(ctx context.Context, idx *linkIndex, values []string) ([]chainscript.LinkHash, error) {
+	var linkHashes []chainscript.LinkHash
+	seen := make(map[string]struct{})
+
+	for _, value := range values {
+		found, err := a.lookup(ctx, idx, value)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, linkHash := range found {
+			if _, ok := seen[string(linkHash)]; !ok {
+				seen[string(linkHash)] = struct{}{}
+				linkHashes = append(linkHashes, linkHash)
+			}
+		}
+	}
+
+	return linkHashes, nil
+}
+
+// candidates returns the hashes of the links that may match the filter,
+// using the most selective index available.
+// It returns false if no index can be used and all the links need to be
+// read.
+func (a *FileStore) candidates(ctx context.Context, filter *store.SegmentFilter) ([]chainscript.LinkHash, bool, error) {
+	var linkHashes []chainscript.LinkHash
+	var err error
+
+	switch {
+	case len(filter.LinkHashes) > 0:
+		linkHashes = filter.LinkHashes
+	case len(filter.PrevLinkHash) > 0 && !filter.WithoutParent:
+		linkHashes, err = a.lookup(ctx, prevLinkHashIndex, string(filter.PrevLinkHash))
+	case len(filter.Referencing) > 0:
+		linkHashes, err = a.lookup(ctx, refIndex, string(filter.Referencing))
+	case len(filter.MapIDs) > 0:
+		linkHashes, err = a.lookupAny(ctx, mapIDIndex, filter.MapIDs)
+	case len(filter.Tags) > 0:
+		linkHashes, err = a.lookup(ctx, tagIndex, filter.Tags[0])
+	case filter.Step != "":
+		linkHashes, err = a.lookup(ctx, stepIndex, filter.Step)
+	case len(filter.Steps) > 0:
+		linkHashes, err = a.lookupAny(ctx, stepIndex, filter.Steps)
+	case filter.Process != "":
+		linkHashes, err = a.lookup(ctx, processIndex, filter.Process)
+	case len(filter.Processes) > 0:
+		linkHashes, err = a.lookupAny(ctx, processIndex, filter.Processes)
+	case len(filter.TagsAny) > 0:
+		linkHashes, err = a.lookupAny(ctx, tagIndex, filter.TagsAny)
+	default:
+		return nil, false, nil
+	}
+
+	return linkHashes, true, err
+}
+
+// mapIDs returns the IDs of the maps matching the filter, sorted.
+func (a *FileStore) mapIDs(ctx context.Context, filter *store.MapFilter) ([]string, error) {
+	prefix := mapsIndex
+	if filter.Process != "" {
+		prefix = processMapsIndex + lengthPrefixed(filter.Process)
+	}
+
+	mapIDs := []string{}
+	err := a.indexDB.IteratePrefix(ctx, []byte(prefix+filter.Prefix), func(key, _ []byte) error {
+		mapID := string(key[len(prefix):])
+		if strings.HasSuffix(mapID, filter.Suffix) {
+			mapIDs = append(mapIDs, mapID)
+		}
+		return nil
+	})
+
+	return mapIDs, err
+}
+
+// buildIndexes indexes all the links of the store if it hasn't been done
+// yet, for instance if the store was created by a previous version.
+func (a *FileStore) buildIndexes(ctx context.Context) error {
+	version, err := a.indexDB.GetValue(ctx, []byte(indexVersionKey))
+	if err != nil {
+		return err
+	}
+	if string(version) == indexVersion {
+		return nil
+	}
+
+	// Remove entries of links that may have been deleted since the indexes
+	// were last built.
+	var stale [][]byte
+	err = a.indexDB.IteratePrefix(ctx, []byte(indexPrefix), func(key, _ []byte) error {
+		stale = append(stale, key)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range stale {
+		if _, err := a.indexDB.DeleteValue(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	err = a.forEach(ctx, func(segment *chainscript.Segment, createdAt time.Time) error {
+		return a.indexLink(ctx, segment.Link, segment.LinkHash(), createdAt)
+	})
+	if err != nil {
+		return err
+	}
+
+	return a.setIndexesComplete(ctx, true)
+}
@@ -0,0 +1,259 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filestore
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-chainscript/chainscripttest"
+	"github.com/stratumn/go-core/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore_candidates(t *testing.T) {
+	ctx := context.Background()
+	a, err := createFileStore()
+	require.NoError(t, err)
+	defer freeFileStore(a)
+
+	parent := chainscripttest.NewLinkBuilder(t).
+		WithProcess("p").
+		WithMapID("m").
+		WithStep("s1").
+		WithTags("a", "ab").
+		Build()
+	parentHash, err := a.CreateLink(ctx, parent)
+	require.NoError(t, err)
+
+	child := chainscripttest.NewLinkBuilder(t).
+		WithParent(t, parent).
+		WithProcess("p").
+		WithMapID("m").
+		WithStep("s2").
+		WithTags("ab").
+		WithRef(t, parent).
+		Build()
+	childHash, err := a.CreateLink(ctx, child)
+	require.NoError(t, err)
+
+	other := chainscripttest.NewLinkBuilder(t).
+		WithProcess("p2").
+		WithMapID("m2").
+		WithStep("s1").
+		Build()
+	otherHash, err := a.CreateLink(ctx, other)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		filter   *store.SegmentFilter
+		expected []chainscript.LinkHash
+	}{{
+		"previous link hash",
+		&store.SegmentFilter{PrevLinkHash: parentHash},
+		[]chainscript.LinkHash{childHash},
+	}, {
+		"referencing",
+		&store.SegmentFilter{Referencing: parentHash},
+		[]chainscript.LinkHash{childHash},
+	}, {
+		"map IDs",
+		&store.SegmentFilter{MapIDs: []string{"m", "m2"}},
+		[]chainscript.LinkHash{parentHash, childHash, otherHash},
+	}, {
+		// Links tagged "ab" must not be found when looking up "a".
+		"tag",
+		&store.SegmentFilter{Tags: []string{"a"}},
+		[]chainscript.LinkHash{parentHash},
+	}, {
+		"steps",
+		&store.SegmentFilter{Steps: []string{"s1"}},
+		[]chainscript.LinkHash{parentHash, otherHash},
+	}, {
+		"process",
+		&store.SegmentFilter{Process: "p"},
+		[]chainscript.LinkHash{parentHash, childHash},
+	}, {
+		"any tag without duplicates",
+		&store.SegmentFilter{TagsAny: []string{"a", "ab"}},
+		[]chainscript.LinkHash{parentHash, childHash},
+	}}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			linkHashes, indexed, err := a.candidates(ctx, tt.filter)
+			require.NoError(t, err)
+			assert.True(t, indexed)
+			assert.ElementsMatch(t, tt.expected, linkHashes)
+		})
+	}
+
+	t.Run("without index", func(t *testing.T) {
+		_, indexed, err := a.candidates(ctx, &store.SegmentFilter{WithoutParent: true})
+		require.NoError(t, err)
+		assert.False(t, indexed)
+	})
+
+	t.Run("map IDs", func(t *testing.T) {
+		mapIDs, err := a.GetMapIDs(ctx, &store.MapFilter{
+			Pagination: store.Pagination{Limit: store.DefaultLimit},
+			Process:    "p2",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"m2"}, mapIDs)
+	})
+}
+
+func TestNew_BuildIndexes(t *testing.T) {
+	ctx := context.Background()
+	a, err := createFileStore()
+	require.NoError(t, err)
+	defer freeFileStore(a)
+
+	link := chainscripttest.NewLinkBuilder(t).WithRandomData().WithTags("tag").Build()
+	linkHash, err := a.CreateLink(ctx, link)
+	require.NoError(t, err)
+
+	// Copy the link files to a store that has never indexed them.
+	path, err := ioutil.TempDir("", "filestore")
+	require.NoError(t, err)
+	defer os.RemoveAll(path)
+
+	linkPath := a.getLinkPath(linkHash)
+	js, err := ioutil.ReadFile(linkPath)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(path, filepath.Base(linkPath)), js, 0644))
+
+	b, err := New(&Config{Path: path})
+	require.NoError(t, err)
+
+	segments, err := b.FindSegments(ctx, &store.SegmentFilter{
+		Pagination: store.Pagination{Limit: store.DefaultLimit},
+		Tags:       []string{"tag"},
+	})
+	require.NoError(t, err)
+	require.Len(t, segments.Segments, 1)
+	assert.Equal(t, linkHash, segments.Segments[0].LinkHash())
+
+	mapIDs, err := b.GetMapIDs(ctx, &store.MapFilter{
+		Pagination: store.Pagination{Limit: store.DefaultLimit},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{link.Meta.MapId}, mapIDs)
+}
+
+func TestFileStore_IndexesAreIsolated(t *testing.T) {
+	ctx := context.Background()
+	a, err := createFileStore()
+	require.NoError(t, err)
+	defer freeFileStore(a)
+
+	link := chainscripttest.NewLinkBuilder(t).WithMapID("m").Build()
+	_, err = a.CreateLink(ctx, link)
+	require.NoError(t, err)
+
+	// User values must not be mistaken for index entries.
+	require.NoError(t, a.SetValue(ctx, []byte(mapsIndex+"fake"), []byte{}))
+
+	mapIDs, err := a.GetMapIDs(ctx, &store.MapFilter{
+		Pagination: store.Pagination{Limit: store.DefaultLimit},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"m"}, mapIDs)
+
+	// Index entries must not show up in user values.
+	value, err := a.GetValue(ctx, []byte(mapsIndex+"m"))
+	require.NoError(t, err)
+	assert.Nil(t, value)
+}
+
+func TestFileStore_CreatedAt(t *testing.T) {
+	ctx := context.Background()
+	a, err := createFileStore()
+	require.NoError(t, err)
+	defer freeFileStore(a)
+
+	before := time.Now()
+	linkHash, err := a.CreateLink(ctx, chainscripttest.RandomLink(t))
+	require.NoError(t, err)
+
+	// Copying or restoring files changes their modification time.
+	old := before.Add(-24 * time.Hour)
+	require.NoError(t, os.Chtimes(a.getLinkPath(linkHash), old, old))
+
+	assertCreatedAfter := func(t *testing.T) {
+		segments, err := a.FindSegments(ctx, &store.SegmentFilter{
+			Pagination:   store.Pagination{Limit: store.DefaultLimit},
+			CreatedAfter: &before,
+		})
+		require.NoError(t, err)
+		require.Len(t, segments.Segments, 1)
+		assert.Equal(t, linkHash, segments.Segments[0].LinkHash())
+	}
+
+	t.Run("creation time is saved", assertCreatedAfter)
+
+	t.Run("creation time is kept when rebuilding indexes", func(t *testing.T) {
+		_, err := a.indexDB.DeleteValue(ctx, []byte(indexVersionKey))
+		require.NoError(t, err)
+		require.NoError(t, a.buildIndexes(ctx))
+
+		assertCreatedAfter(t)
+	})
+}
+
+func TestFileStore_InterruptedWrite(t *testing.T) {
+	ctx := context.Background()
+	a, err := createFileStore()
+	require.NoError(t, err)
+	defer freeFileStore(a)
+
+	_, err = a.CreateLink(ctx, chainscripttest.RandomLink(t))
+	require.NoError(t, err)
+
+	version, err := a.indexDB.GetValue(ctx, []byte(indexVersionKey))
+	require.NoError(t, err)
+	assert.Equal(t, indexVersion, string(version), "indexes should be complete after a write")
+
+	// The process stops after a link file is written but before it is
+	// indexed.
+	link := chainscripttest.NewLinkBuilder(t).WithMapID("interrupted").Build()
+	linkHash, err := link.Hash()
+	require.NoError(t, err)
+	js, err := json.Marshal(link)
+	require.NoError(t, err)
+
+	require.NoError(t, a.setIndexesComplete(ctx, false))
+	require.NoError(t, ioutil.WriteFile(a.getLinkPath(linkHash), js, 0644))
+
+	// The indexes are rebuilt when the store restarts.
+	require.NoError(t, a.buildIndexes(ctx))
+
+	segments, err := a.FindSegments(ctx, &store.SegmentFilter{
+		Pagination: store.Pagination{Limit: store.DefaultLimit},
+		MapIDs:     []string{"interrupted"},
+	})
+	require.NoError(t, err)
+	require.Len(t, segments.Segments, 1)
+	assert.Equal(t, linkHash, segments.Segments[0].LinkHash())
+}
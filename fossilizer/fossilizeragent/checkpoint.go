@@ -0,0 +1,181 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fossilizeragent
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/types"
+)
+
+// checkpoint is the progress of the agent.
+// It is saved to a file, if one is configured, every time it changes.
+type checkpoint struct {
+	path string
+
+	// saveMu serializes the writes of the checkpoint file.
+	saveMu sync.Mutex
+
+	mu    sync.Mutex
+	state checkpointState
+}
+
+type checkpointState struct {
+	// Sequence is the sequence number of the last store event whose links
+	// were submitted to the fossilizer.
+	Sequence uint64 `json:"sequence"`
+
+	// Pending contains the link hashes submitted to the fossilizer whose
+	// evidence hasn't been saved yet, and when they were submitted.
+	Pending map[string]time.Time `json:"pending"`
+}
+
+// loadCheckpoint loads the checkpoint saved in the given file, if it exists.
+func loadCheckpoint(path string) (*checkpoint, error) {
+	c := &checkpoint{
+		path:  path,
+		state: checkpointState{Pending: map[string]time.Time{}},
+	}
+
+	if path == "" {
+		return c, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, types.WrapError(err, errorcode.Unavailable, Component, "could not read checkpoint")
+	}
+
+	if err := json.Unmarshal(data, &c.state); err != nil {
+		return nil, types.WrapError(err, errorcode.DataLoss, Component, "could not read checkpoint")
+	}
+
+	if c.state.Pending == nil {
+		c.state.Pending = map[string]time.Time{}
+	}
+
+	return c, nil
+}
+
+// save atomically replaces the checkpoint file.
+func (c *checkpoint) save() error {
+	if c.path == "" {
+		return nil
+	}
+
+	// The state is marshalled while holding the lock so that an older state
+	// can't overwrite a newer one.
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+
+	c.mu.Lock()
+	data, err := json.Marshal(&c.state)
+	c.mu.Unlock()
+
+	if err != nil {
+		return types.WrapError(err, errorcode.Internal, Component, "could not save checkpoint")
+	}
+
+	tmp := c.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return types.WrapError(err, errorcode.Unavailable, Component, "could not save checkpoint")
+	}
+
+	if err := os.Rename(tmp, c.path); err != nil {
+		return types.WrapError(err, errorcode.Unavailable, Component, "could not save checkpoint")
+	}
+
+	return nil
+}
+
+func (c *checkpoint) sequence() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.state.Sequence
+}
+
+func (c *checkpoint) setSequence(seq uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.state.Sequence = seq
+}
+
+// addPending marks link hashes as submitted and returns those that weren't
+// already pending.
+func (c *checkpoint) addPending(linkHashes []string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var added []string
+	for _, lh := range linkHashes {
+		if _, ok := c.state.Pending[lh]; ok {
+			continue
+		}
+
+		c.state.Pending[lh] = time.Now()
+		added = append(added, lh)
+	}
+
+	return added
+}
+
+func (c *checkpoint) isPending(linkHash string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.state.Pending[linkHash]
+	return ok
+}
+
+func (c *checkpoint) removePending(linkHash string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.state.Pending, linkHash)
+}
+
+// expired returns the link hashes submitted for longer than the given
+// duration and marks them as submitted again.
+func (c *checkpoint) expired(d time.Duration) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expired []string
+	for lh, submittedAt := range c.state.Pending {
+		if time.Since(submittedAt) >= d {
+			c.state.Pending[lh] = time.Now()
+			expired = append(expired, lh)
+		}
+	}
+
+	return expired
+}
+
+func (c *checkpoint) pendingCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.state.Pending)
+}
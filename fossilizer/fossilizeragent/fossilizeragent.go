@@ -0,0 +1,334 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fossilizeragent fossilizes the links saved in a store and adds the
+// resulting evidences to the store.
+//
+// The agent can bridge any store adapter with any fossilizer adapter, local
+// or remote (see storehttpclient and fossilizerhttpclient).
+// Its progress is saved in a checkpoint so that a restarted agent neither
+// skips links nor fossilizes them twice:
+//
+//   - when the store keeps an event log (see store.EventReader), the agent
+//     reads it from the sequence number of the last event it processed, so
+//     links saved while it was stopped are fossilized too;
+//   - link hashes sent to the fossilizer are remembered until their evidence
+//     is saved, and are sent again if no evidence is received in time (for
+//     instance because the fossilizer restarted).
+//
+// When the store doesn't keep an event log, the agent only fossilizes the
+// links it is notified of while it is running.
+package fossilizeragent
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-core/fossilizer"
+	"github.com/stratumn/go-core/monitoring"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+)
+
+const (
+	// Component name for monitoring.
+	Component = "fossilizeragent"
+
+	// DefaultPollInterval is the default interval between two reads of the
+	// store event log.
+	DefaultPollInterval = 10 * time.Second
+
+	// DefaultResubmitInterval is the default delay after which a link hash
+	// is fossilized again if its evidence hasn't been received.
+	DefaultResubmitInterval = 10 * time.Minute
+
+	// DefaultEventsChanSize is the default size of the channels receiving
+	// store and fossilizer events.
+	DefaultEventsChanSize = 256
+
+	// readEventsLimit is the number of events read from the store event log
+	// at once.
+	readEventsLimit = 100
+)
+
+// Config contains configuration options for the agent.
+type Config struct {
+	// CheckpointPath is the file where the progress of the agent is saved.
+	// If empty, the progress is only kept in memory.
+	CheckpointPath string
+
+	// PollInterval is the interval between two reads of the store event
+	// log, in addition to the reads triggered by store events.
+	// Defaults to DefaultPollInterval.
+	PollInterval time.Duration
+
+	// ResubmitInterval is the delay after which a link hash is fossilized
+	// again if its evidence hasn't been received.
+	// Defaults to DefaultResubmitInterval.
+	ResubmitInterval time.Duration
+
+	// EventsChanSize is the size of the channels receiving store and
+	// fossilizer events. Defaults to DefaultEventsChanSize.
+	EventsChanSize int
+}
+
+// Agent fossilizes the links of a store.
+type Agent struct {
+	store      store.Adapter
+	fossilizer fossilizer.Adapter
+	config     *Config
+
+	checkpoint *checkpoint
+}
+
+// New creates an agent fossilizing the links of the given store with the
+// given fossilizer.
+// It loads the checkpoint saved by a previous run, if any.
+func New(a store.Adapter, f fossilizer.Adapter, config *Config) (*Agent, error) {
+	c := *config
+	if c.PollInterval <= 0 {
+		c.PollInterval = DefaultPollInterval
+	}
+	if c.ResubmitInterval <= 0 {
+		c.ResubmitInterval = DefaultResubmitInterval
+	}
+	if c.EventsChanSize <= 0 {
+		c.EventsChanSize = DefaultEventsChanSize
+	}
+
+	cp, err := loadCheckpoint(c.CheckpointPath)
+	if err != nil {
+		return nil, err
+	}
+
+	pendingGauge.Set(float64(cp.pendingCount()))
+
+	return &Agent{
+		store:      a,
+		fossilizer: f,
+		config:     &c,
+		checkpoint: cp,
+	}, nil
+}
+
+// Run fossilizes the links of the store until the context is cancelled.
+// It only returns an error if the checkpoint can't be saved.
+// Errors of the store and the fossilizer are logged and the corresponding
+// work is retried later.
+func (a *Agent) Run(ctx context.Context) error {
+	results := make(chan *fossilizer.Event, a.config.EventsChanSize)
+	a.fossilizer.AddFossilizerEventChan(results)
+	go a.saveEvidences(ctx, results)
+
+	events := a.listen(ctx)
+
+	reader, _ := a.store.(store.EventReader)
+
+	ticker := time.NewTicker(a.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if reader != nil {
+			var err error
+			if reader, err = a.readEventLog(ctx, reader); err != nil {
+				return err
+			}
+		}
+
+		for _, e := range events.pop() {
+			// When the event log is read, store events only signal that
+			// it should be read again.
+			if reader != nil {
+				continue
+			}
+
+			if err := a.handleEvent(ctx, e); err != nil {
+				return err
+			}
+		}
+
+		if err := a.resubmit(ctx); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-events.ready:
+		case <-ticker.C:
+		}
+	}
+}
+
+// readEventLog submits the links of the events saved after the checkpoint.
+// It returns the reader to use next time, which is nil if the store turns
+// out not to keep an event log (for instance a remote store).
+func (a *Agent) readEventLog(ctx context.Context, reader store.EventReader) (store.EventReader, error) {
+	for ctx.Err() == nil {
+		events, err := reader.ReadEvents(ctx, a.checkpoint.sequence()+1, readEventsLimit)
+		if e, ok := err.(*types.Error); ok && e.Code == errorcode.OutOfRange {
+			monitoring.LogEntry().WithField("sequence", a.checkpoint.sequence()).Warn("Store events were pruned before being fossilized, resuming from the oldest event")
+			events, err = reader.ReadEvents(ctx, 0, readEventsLimit)
+		}
+		if e, ok := err.(*types.Error); ok && e.Code == errorcode.Unimplemented {
+			monitoring.LogEntry().Warn("Store doesn't keep an event log, only links saved while the agent is running will be fossilized")
+			return nil, nil
+		}
+		if err != nil {
+			monitoring.LogEntry().WithField("error", err).Warn("Could not read store events")
+			return reader, nil
+		}
+
+		if len(events) == 0 {
+			return reader, nil
+		}
+
+		for _, e := range events {
+			if err := a.handleEvent(ctx, e); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return reader, nil
+}
+
+// handleEvent submits the links of a store event to the fossilizer.
+func (a *Agent) handleEvent(ctx context.Context, e *store.Event) error {
+	var linkHashes []string
+
+	if e.EventType == store.SavedLinks {
+		links, ok := e.Data.([]*chainscript.Link)
+		if !ok {
+			monitoring.LogEntry().WithField("sequence", e.Sequence).Warn("Could not read saved links")
+		}
+
+		for _, link := range links {
+			lh, err := link.Hash()
+			if err != nil {
+				monitoring.LogEntry().WithField("error", err).Warn("Could not hash saved link")
+				continue
+			}
+
+			linkHashes = append(linkHashes, lh.String())
+		}
+	}
+
+	// Link hashes are marked as pending before being submitted since
+	// fossilizers may send their evidence right away.
+	submitted := a.checkpoint.addPending(linkHashes)
+	if e.Sequence > 0 {
+		a.checkpoint.setSequence(e.Sequence)
+	}
+
+	if len(submitted) == 0 && e.Sequence == 0 {
+		return nil
+	}
+
+	if err := a.checkpoint.save(); err != nil {
+		return err
+	}
+
+	pendingGauge.Set(float64(a.checkpoint.pendingCount()))
+
+	for _, lh := range submitted {
+		a.fossilize(ctx, lh)
+	}
+
+	return nil
+}
+
+// resubmit submits again the link hashes whose evidence wasn't received in
+// time.
+func (a *Agent) resubmit(ctx context.Context) error {
+	expired := a.checkpoint.expired(a.config.ResubmitInterval)
+	if len(expired) == 0 {
+		return nil
+	}
+
+	if err := a.checkpoint.save(); err != nil {
+		return err
+	}
+
+	for _, lh := range expired {
+		monitoring.LogEntry().WithField("linkHash", lh).Info("Fossilizing link again")
+		a.fossilize(ctx, lh)
+	}
+
+	return nil
+}
+
+// fossilize sends a link hash to the fossilizer.
+// The link hash is also given as meta, which makes fossilizer events easy to
+// match with links.
+func (a *Agent) fossilize(ctx context.Context, linkHash string) {
+	lh, err := chainscript.NewLinkHashFromString(linkHash)
+	if err != nil {
+		monitoring.LogEntry().WithField("error", err).WithField("linkHash", linkHash).Warn("Invalid pending link hash")
+		a.checkpoint.removePending(linkHash)
+		return
+	}
+
+	if err := a.fossilizer.Fossilize(ctx, lh, []byte(linkHash)); err != nil {
+		submissions.With(prometheus.Labels{statusLabel: statusFailure}).Inc()
+		monitoring.LogEntry().WithField("error", err).WithField("linkHash", linkHash).Warn("Could not fossilize link")
+		return
+	}
+
+	submissions.With(prometheus.Labels{statusLabel: statusSuccess}).Inc()
+}
+
+// saveEvidences adds the evidences produced by the fossilizer to the store.
+// Fossilizers block when their event channels are full, so events keep being
+// consumed after the context is cancelled.
+func (a *Agent) saveEvidences(ctx context.Context, results <-chan *fossilizer.Event) {
+	for e := range results {
+		if ctx.Err() != nil || e.EventType != fossilizer.DidFossilize {
+			continue
+		}
+
+		r, err := e.Result()
+		if err != nil {
+			monitoring.LogEntry().WithField("error", err).Warn("Could not read fossilizer result")
+			continue
+		}
+
+		lh := chainscript.LinkHash(r.Data)
+		if !a.checkpoint.isPending(lh.String()) {
+			continue
+		}
+
+		err = a.store.AddEvidence(ctx, lh, &r.Evidence)
+		if e, ok := err.(*types.Error); ok && e.Code == errorcode.AlreadyExists {
+			err = nil
+		}
+		if err != nil {
+			savedEvidences.With(prometheus.Labels{statusLabel: statusFailure}).Inc()
+			monitoring.LogEntry().WithField("error", err).WithField("linkHash", lh.String()).Warn("Could not add evidence")
+			continue
+		}
+
+		savedEvidences.With(prometheus.Labels{statusLabel: statusSuccess}).Inc()
+
+		a.checkpoint.removePending(lh.String())
+		if err := a.checkpoint.save(); err != nil {
+			monitoring.LogEntry().WithField("error", err).Warn("Could not save checkpoint")
+		}
+
+		pendingGauge.Set(float64(a.checkpoint.pendingCount()))
+	}
+}
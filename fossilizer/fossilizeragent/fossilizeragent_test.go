@@ -0,0 +1,274 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fossilizeragent
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-chainscript/chainscripttest"
+	"github.com/stratumn/go-core/dummyfossilizer"
+	"github.com/stratumn/go-core/dummyfossilizer/evidences"
+	"github.com/stratumn/go-core/dummystore"
+	"github.com/stratumn/go-core/eventlog"
+	"github.com/stratumn/go-core/fossilizer"
+	"github.com/stratumn/go-core/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingFossilizer counts fossilization requests and drops the first
+// ones.
+type countingFossilizer struct {
+	fossilizer.Adapter
+
+	mu    sync.Mutex
+	drop  int
+	calls map[string]int
+}
+
+func newCountingFossilizer(drop int) *countingFossilizer {
+	return &countingFossilizer{
+		Adapter: dummyfossilizer.New(&dummyfossilizer.Config{}),
+		drop:    drop,
+		calls:   map[string]int{},
+	}
+}
+
+func (f *countingFossilizer) Fossilize(ctx context.Context, data []byte, meta []byte) error {
+	f.mu.Lock()
+	f.calls[chainscript.LinkHash(data).String()]++
+	drop := f.drop > 0
+	if drop {
+		f.drop--
+	}
+	f.mu.Unlock()
+
+	if drop {
+		return nil
+	}
+
+	return f.Adapter.Fossilize(ctx, data, meta)
+}
+
+func (f *countingFossilizer) count(linkHash chainscript.LinkHash) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.calls[linkHash.String()]
+}
+
+// listenedStore signals when the agent has started listening to its events.
+type listenedStore struct {
+	store.Adapter
+	listened chan struct{}
+}
+
+func (s *listenedStore) AddStoreEventChannel(c chan *store.Event) {
+	s.Adapter.AddStoreEventChannel(c)
+	close(s.listened)
+}
+
+// runAgent runs an agent until the returned function is called.
+func runAgent(t *testing.T, a store.Adapter, f fossilizer.Adapter, config *Config) func() {
+	agent, err := New(a, f, config)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- agent.Run(ctx) }()
+
+	return func() {
+		cancel()
+		assert.NoError(t, <-done)
+	}
+}
+
+func createLinks(t *testing.T, a store.Adapter, count int) []chainscript.LinkHash {
+	var linkHashes []chainscript.LinkHash
+	for i := 0; i < count; i++ {
+		lh, err := a.CreateLink(context.Background(), chainscripttest.RandomLink(t))
+		require.NoError(t, err)
+		linkHashes = append(linkHashes, lh)
+	}
+
+	return linkHashes
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	for i := 0; !cond(); i++ {
+		require.True(t, i < 100, "condition should be met")
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func waitForEvidences(t *testing.T, a store.Adapter, linkHashes []chainscript.LinkHash) {
+	for _, lh := range linkHashes {
+		waitFor(t, func() bool {
+			evidences, err := a.GetEvidences(context.Background(), lh)
+			return err == nil && len(evidences) == 1
+		})
+	}
+}
+
+// checkpointPath returns the path of a checkpoint in a new temporary
+// directory.
+func checkpointPath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "fossilizeragent")
+	require.NoError(t, err)
+
+	return filepath.Join(dir, "checkpoint.json"), func() { os.RemoveAll(dir) }
+}
+
+func newEventLog(t *testing.T) *eventlog.EventLog {
+	a := dummystore.New(&dummystore.Config{})
+	l, err := eventlog.Wrap(a, a, &eventlog.Config{})
+	require.NoError(t, err)
+
+	return l
+}
+
+func TestAgent_eventLog(t *testing.T) {
+	l := newEventLog(t)
+	f := newCountingFossilizer(0)
+	path, remove := checkpointPath(t)
+	defer remove()
+
+	before := createLinks(t, l, 3)
+
+	stop := runAgent(t, l, f, &Config{CheckpointPath: path, PollInterval: 10 * time.Millisecond})
+	after := createLinks(t, l, 3)
+
+	linkHashes := append(before, after...)
+	waitForEvidences(t, l, linkHashes)
+
+	// The events of the evidences are handled too.
+	waitFor(t, func() bool {
+		cp, err := loadCheckpoint(path)
+		return err == nil && cp.sequence() == uint64(2*len(linkHashes)) && cp.pendingCount() == 0
+	})
+	stop()
+
+	for _, lh := range linkHashes {
+		assert.Equal(t, 1, f.count(lh), lh.String())
+	}
+}
+
+func TestAgent_restart(t *testing.T) {
+	l := newEventLog(t)
+	path, remove := checkpointPath(t)
+	defer remove()
+	config := &Config{CheckpointPath: path, PollInterval: 10 * time.Millisecond}
+
+	stop := runAgent(t, l, newCountingFossilizer(0), config)
+	fossilized := createLinks(t, l, 3)
+	waitForEvidences(t, l, fossilized)
+	stop()
+
+	// Links saved while the agent is stopped are fossilized when it
+	// restarts, and links already fossilized are left alone.
+	missed := createLinks(t, l, 3)
+
+	f := newCountingFossilizer(0)
+	stop = runAgent(t, l, f, config)
+	waitForEvidences(t, l, missed)
+	stop()
+
+	for _, lh := range fossilized {
+		assert.Zero(t, f.count(lh), lh.String())
+	}
+	for _, lh := range missed {
+		assert.Equal(t, 1, f.count(lh), lh.String())
+	}
+}
+
+func TestAgent_resubmit(t *testing.T) {
+	l := newEventLog(t)
+	path, remove := checkpointPath(t)
+	defer remove()
+	f := newCountingFossilizer(2)
+
+	linkHashes := createLinks(t, l, 2)
+
+	// The fossilizer loses the requests and the agent is stopped before
+	// resubmitting them.
+	stop := runAgent(t, l, f, &Config{CheckpointPath: path})
+	waitFor(t, func() bool {
+		return f.count(linkHashes[0]) == 1 && f.count(linkHashes[1]) == 1
+	})
+	stop()
+
+	cp, err := loadCheckpoint(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, cp.pendingCount())
+
+	stop = runAgent(t, l, f, &Config{
+		CheckpointPath:   path,
+		PollInterval:     10 * time.Millisecond,
+		ResubmitInterval: 10 * time.Millisecond,
+	})
+	waitForEvidences(t, l, linkHashes)
+	stop()
+
+	for _, lh := range linkHashes {
+		assert.Equal(t, 2, f.count(lh), lh.String())
+	}
+}
+
+func TestAgent_storeEvents(t *testing.T) {
+	a := &listenedStore{
+		Adapter:  dummystore.New(&dummystore.Config{}),
+		listened: make(chan struct{}),
+	}
+	f := newCountingFossilizer(0)
+
+	stop := runAgent(t, a, f, &Config{})
+	<-a.listened
+
+	linkHashes := createLinks(t, a, 3)
+	waitForEvidences(t, a, linkHashes)
+	stop()
+
+	for _, lh := range linkHashes {
+		assert.Equal(t, 1, f.count(lh), lh.String())
+	}
+}
+
+func TestAgent_existingEvidence(t *testing.T) {
+	ctx := context.Background()
+	l := newEventLog(t)
+	path, remove := checkpointPath(t)
+	defer remove()
+
+	// The evidence was added by someone else, which must not prevent the
+	// link from being marked as done.
+	linkHashes := createLinks(t, l, 1)
+	evidence, err := (&evidences.DummyProof{Timestamp: 42}).Evidence(evidences.Name)
+	require.NoError(t, err)
+	require.NoError(t, l.AddEvidence(ctx, linkHashes[0], evidence))
+
+	stop := runAgent(t, l, newCountingFossilizer(0), &Config{CheckpointPath: path, PollInterval: 10 * time.Millisecond})
+	waitFor(t, func() bool {
+		cp, err := loadCheckpoint(path)
+		return err == nil && cp.sequence() == 2 && cp.pendingCount() == 0
+	})
+	stop()
+}
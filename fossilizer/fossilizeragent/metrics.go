@@ -0,0 +1,65 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fossilizeragent
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/stratumn/go-core/monitoring"
+)
+
+const (
+	statusLabel = "status"
+
+	statusSuccess = "success"
+	statusFailure = "failure"
+)
+
+var (
+	submissions    *prometheus.CounterVec
+	savedEvidences *prometheus.CounterVec
+	pendingGauge   prometheus.Gauge
+)
+
+func init() {
+	submissions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: monitoring.Stratumn,
+			Subsystem: "fossilizer_agent",
+			Name:      "fossilizations",
+			Help:      "number of link hashes sent to the fossilizer",
+		},
+		[]string{statusLabel},
+	)
+
+	savedEvidences = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: monitoring.Stratumn,
+			Subsystem: "fossilizer_agent",
+			Name:      "evidences",
+			Help:      "number of evidences added to the store",
+		},
+		[]string{statusLabel},
+	)
+
+	pendingGauge = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: monitoring.Stratumn,
+			Subsystem: "fossilizer_agent",
+			Name:      "pending",
+			Help:      "number of link hashes waiting for an evidence",
+		},
+	)
+}
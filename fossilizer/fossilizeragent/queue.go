@@ -0,0 +1,72 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fossilizeragent
+
+import (
+	"context"
+	"sync"
+
+	"github.com/stratumn/go-core/store"
+)
+
+// eventQueue buffers the store events until the agent handles them.
+// Stores block when their event channels are full, so events must be
+// consumed even while links are being fossilized.
+type eventQueue struct {
+	mu     sync.Mutex
+	events []*store.Event
+	ready  chan struct{}
+}
+
+// listen starts recording the events of the store.
+// Stores can't remove event channels, so the events keep being consumed
+// after the context is cancelled, but they are dropped.
+func (a *Agent) listen(ctx context.Context) *eventQueue {
+	q := &eventQueue{ready: make(chan struct{}, 1)}
+
+	c := make(chan *store.Event, a.config.EventsChanSize)
+	a.store.AddStoreEventChannel(c)
+
+	go func() {
+		for e := range c {
+			if ctx.Err() == nil {
+				q.push(e)
+			}
+		}
+	}()
+
+	return q
+}
+
+func (q *eventQueue) push(e *store.Event) {
+	q.mu.Lock()
+	q.events = append(q.events, e)
+	q.mu.Unlock()
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (q *eventQueue) pop() []*store.Event {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	events := q.events
+	q.events = nil
+
+	return events
+}
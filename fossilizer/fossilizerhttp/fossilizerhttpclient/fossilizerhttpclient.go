@@ -0,0 +1,159 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fossilizerhttpclient implements a fossilizer adapter that talks to
+// a remote fossilizer served by
+// github.com/stratumn/go-core/fossilizer/fossilizerhttp.
+//
+// It lets a fossilizer running in another process be wrapped, for instance
+// by a batchfossilizer.
+package fossilizerhttpclient
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/stratumn/go-core/fossilizer"
+	"github.com/stratumn/go-core/fossilizer/fossilizerhttp"
+	"github.com/stratumn/go-core/jsonhttp"
+	"github.com/stratumn/go-core/jsonws"
+	"github.com/stratumn/go-core/monitoring"
+)
+
+// Config contains configuration options for the client.
+type Config struct {
+	// URL of the fossilizer server, for instance http://localhost:6000.
+	URL string
+
+	// HTTPClient is an optional custom HTTP client.
+	HTTPClient *http.Client
+
+	// ReconnectInterval is the interval between two attempts to connect to
+	// the web socket of the server.
+	// Defaults to github.com/stratumn/go-core/jsonws.DefaultReconnectInterval.
+	ReconnectInterval time.Duration
+}
+
+// Client is the type that implements github.com/stratumn/go-core/fossilizer.Adapter.
+type Client struct {
+	client *jsonhttp.Client
+	config *Config
+
+	eventsMutex sync.Mutex
+	eventChans  []chan *fossilizer.Event
+	ws          *jsonws.Client
+}
+
+// New creates a client for the fossilizer served at the configured URL.
+func New(config *Config) (*Client, error) {
+	client, err := jsonhttp.NewClient(config.URL, config.HTTPClient, fossilizerhttp.Component)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{client: client, config: config}, nil
+}
+
+// Close closes the web socket connection used to receive fossilizer events.
+func (c *Client) Close() error {
+	c.eventsMutex.Lock()
+	defer c.eventsMutex.Unlock()
+
+	if c.ws != nil {
+		c.ws.Stop()
+		c.ws = nil
+	}
+
+	return nil
+}
+
+// GetInfo implements github.com/stratumn/go-core/fossilizer.Adapter.GetInfo.
+// It returns the information of the remote fossilizer.
+func (c *Client) GetInfo(ctx context.Context) (interface{}, error) {
+	var info fossilizerhttp.Info
+	if err := c.client.Do(ctx, http.MethodGet, "/", nil, nil, &info); err != nil {
+		return nil, err
+	}
+
+	return info.Adapter, nil
+}
+
+// AddFossilizerEventChan implements
+// github.com/stratumn/go-core/fossilizer.Adapter.AddFossilizerEventChan.
+// Events are read from the web socket of the server, which is connected when
+// the first channel is added. The connection is re-established when it is
+// lost, but events sent in the meantime are missed.
+func (c *Client) AddFossilizerEventChan(eventChan chan *fossilizer.Event) {
+	c.eventsMutex.Lock()
+	defer c.eventsMutex.Unlock()
+
+	c.eventChans = append(c.eventChans, eventChan)
+
+	if c.ws != nil {
+		return
+	}
+
+	c.ws = jsonws.NewClient(c.client.WebSocketURL("/websocket"), &jsonws.ClientConfig{
+		ReconnectInterval: c.config.ReconnectInterval,
+	})
+
+	// Connect right away so that events following this call aren't missed.
+	// If the server isn't reachable, the client keeps trying in the
+	// background.
+	if err := c.ws.Connect(); err != nil {
+		monitoring.LogEntry().WithField("error", err).Warn("Could not connect to fossilizer web socket")
+	}
+
+	go c.ws.Start(c.handleMessage)
+}
+
+func (c *Client) handleMessage(msg *jsonws.RawMessage) {
+	if fossilizer.EventType(msg.Type) != fossilizer.DidFossilize {
+		return
+	}
+
+	var result fossilizer.Result
+	if err := json.Unmarshal(msg.Data, &result); err != nil {
+		monitoring.LogEntry().WithField("error", err).Warn("Could not decode fossilizer event")
+		return
+	}
+
+	event := &fossilizer.Event{
+		EventType: fossilizer.DidFossilize,
+		Data:      &result,
+	}
+
+	c.eventsMutex.Lock()
+	eventChans := c.eventChans
+	c.eventsMutex.Unlock()
+
+	for _, eventChan := range eventChans {
+		eventChan <- event
+	}
+}
+
+// Fossilize implements github.com/stratumn/go-core/fossilizer.Adapter.Fossilize.
+// The server expects a human-readable meta, so it should be valid UTF-8.
+func (c *Client) Fossilize(ctx context.Context, data []byte, meta []byte) error {
+	body := map[string]string{
+		"data": hex.EncodeToString(data),
+		"meta": string(meta),
+	}
+
+	return c.client.Do(ctx, http.MethodPost, "/fossils", nil, body, nil)
+}
@@ -0,0 +1,173 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fossilizerhttpclient
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stratumn/go-core/batchfossilizer"
+	batchevidences "github.com/stratumn/go-core/batchfossilizer/evidences"
+	"github.com/stratumn/go-core/dummyfossilizer"
+	dummyevidences "github.com/stratumn/go-core/dummyfossilizer/evidences"
+	"github.com/stratumn/go-core/fossilizer"
+	"github.com/stratumn/go-core/fossilizer/dummyqueue"
+	"github.com/stratumn/go-core/fossilizer/fossilizerhttp"
+	"github.com/stratumn/go-core/jsonhttp"
+	"github.com/stratumn/go-core/jsonws"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// remoteFossilizer is a client connected to a fossilizer server.
+type remoteFossilizer struct {
+	*Client
+	server     *fossilizerhttp.Server
+	httpServer *httptest.Server
+}
+
+func newRemoteFossilizer(t *testing.T) *remoteFossilizer {
+	a := dummyfossilizer.New(&dummyfossilizer.Config{})
+	s := fossilizerhttp.New(a, &fossilizerhttp.Config{
+		MinDataLen:              2,
+		MaxDataLen:              64,
+		FossilizerEventChanSize: 8,
+	}, &jsonhttp.Config{}, &jsonws.BasicConfig{}, &jsonws.BufferedConnConfig{
+		Size:         256,
+		WriteTimeout: 10 * time.Second,
+		PongTimeout:  70 * time.Second,
+		PingInterval: time.Minute,
+		MaxMsgSize:   1024,
+	})
+	go s.Start()
+
+	// Wait for the server to listen to fossilizer events.
+	for a.ListenersCount() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	httpServer := httptest.NewServer(s)
+
+	c, err := New(&Config{URL: httpServer.URL, ReconnectInterval: 10 * time.Millisecond})
+	require.NoError(t, err)
+
+	return &remoteFossilizer{Client: c, server: s, httpServer: httpServer}
+}
+
+func (r *remoteFossilizer) free() {
+	r.Close()
+	r.httpServer.Close()
+	r.server.Shutdown(context.Background())
+}
+
+func receiveEvent(t *testing.T, eventChan chan *fossilizer.Event) *fossilizer.Result {
+	select {
+	case e := <-eventChan:
+		assert.Equal(t, fossilizer.DidFossilize, e.EventType)
+		r, ok := e.Data.(*fossilizer.Result)
+		require.True(t, ok, "e.Data.(*fossilizer.Result)")
+		return r
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "no fossilizer event received")
+		return nil
+	}
+}
+
+func TestNew_invalidURL(t *testing.T) {
+	for _, u := range []string{"", "localhost:6000", "ftp://localhost"} {
+		_, err := New(&Config{URL: u})
+		assert.Error(t, err, u)
+	}
+}
+
+func TestClient_GetInfo(t *testing.T) {
+	r := newRemoteFossilizer(t)
+	defer r.free()
+
+	info, err := r.GetInfo(context.Background())
+	require.NoError(t, err)
+
+	m, ok := info.(map[string]interface{})
+	require.True(t, ok, "info.(map[string]interface{})")
+	assert.Equal(t, dummyevidences.Name, m["name"])
+}
+
+func TestClient_Fossilize(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("forwards events", func(t *testing.T) {
+		r := newRemoteFossilizer(t)
+		defer r.free()
+
+		eventChan := make(chan *fossilizer.Event, 1)
+		r.AddFossilizerEventChan(eventChan)
+
+		err := r.Fossilize(ctx, []byte("b4tm4n"), []byte("r0b1n"))
+		require.NoError(t, err)
+
+		res := receiveEvent(t, eventChan)
+		assert.Equal(t, []byte("b4tm4n"), res.Data)
+		assert.Equal(t, []byte("r0b1n"), res.Meta)
+		assert.Equal(t, dummyevidences.Name, res.Evidence.Backend)
+	})
+
+	t.Run("invalid data", func(t *testing.T) {
+		r := newRemoteFossilizer(t)
+		defer r.free()
+
+		err := r.Fossilize(ctx, make([]byte, 64), nil)
+		require.Error(t, err)
+
+		e, ok := err.(*types.Error)
+		require.True(t, ok, "err.(*types.Error)")
+		assert.Equal(t, errorcode.InvalidArgument, e.Code)
+		assert.Equal(t, fossilizerhttp.Component, e.Component)
+	})
+
+	t.Run("batch fossilizer", func(t *testing.T) {
+		r := newRemoteFossilizer(t)
+		defer r.free()
+
+		batchCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		batch := batchfossilizer.New(
+			batchCtx,
+			&batchfossilizer.Config{Interval: 10 * time.Millisecond},
+			r.Client,
+			dummyqueue.New(),
+		)
+
+		eventChan := make(chan *fossilizer.Event, 2)
+		batch.AddFossilizerEventChan(eventChan)
+
+		require.NoError(t, batch.Fossilize(ctx, []byte("b4tm4n"), []byte("r0b1n")))
+		require.NoError(t, batch.Fossilize(ctx, []byte("j0k3r"), []byte("h4rl3y")))
+
+		results := map[string]string{}
+		for i := 0; i < 2; i++ {
+			res := receiveEvent(t, eventChan)
+			results[string(res.Data)] = string(res.Meta)
+			assert.Equal(t, batchevidences.BatchFossilizerName, res.Evidence.Backend)
+			assert.Equal(t, dummyevidences.Name, res.Evidence.Provider)
+		}
+
+		assert.Equal(t, map[string]string{"b4tm4n": "r0b1n", "j0k3r": "h4rl3y"}, results)
+	})
+}
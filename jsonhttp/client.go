@@ -0,0 +1,194 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jsonhttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/types"
+)
+
+// Client sends requests to a JSON HTTP server and decodes its responses.
+// Errors rendered by the server are converted back to
+// github.com/stratumn/go-core/types.Error.
+type Client struct {
+	url        *url.URL
+	httpClient *http.Client
+	component  string
+}
+
+// NewClient creates a client for the server at the given base URL
+// (for instance http://localhost:5000).
+// The component is used for errors that don't come from the server.
+// If httpClient is nil, http.DefaultClient is used.
+func NewClient(baseURL string, httpClient *http.Client, component string) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, types.NewErrorf(errorcode.InvalidArgument, component, "invalid URL %q", baseURL)
+	}
+
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Client{url: u, httpClient: httpClient, component: component}, nil
+}
+
+// WebSocketURL returns the web socket URL of the given path of the server.
+func (c *Client) WebSocketURL(path string) string {
+	u := *c.url
+	u.Scheme = strings.Replace(u.Scheme, "http", "ws", 1)
+	u.Path += path
+	return u.String()
+}
+
+// Do sends a request with an optional JSON body and decodes the JSON
+// response in result (if not nil).
+func (c *Client) Do(ctx context.Context, method, path string, query url.Values, body, result interface{}) error {
+	u := *c.url
+	u.Path += path
+	u.RawQuery = query.Encode()
+
+	var reqBody io.Reader
+	if body != nil {
+		js, err := json.Marshal(body)
+		if err != nil {
+			return types.WrapError(err, errorcode.InvalidArgument, c.component, "json.Marshal")
+		}
+
+		reqBody = bytes.NewReader(js)
+	}
+
+	req, err := http.NewRequest(method, u.String(), reqBody)
+	if err != nil {
+		return types.WrapError(err, errorcode.InvalidArgument, c.component, "could not create http request")
+	}
+
+	req = req.WithContext(ctx)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return types.WrapError(err, errorcode.Unavailable, c.component, "could not do http request")
+	}
+
+	defer res.Body.Close()
+
+	resBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return types.WrapError(err, errorcode.Unavailable, c.component, "could not read http response")
+	}
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return NewErrFromResponse(res.StatusCode, resBody, c.component)
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(resBody, result); err != nil {
+		return types.WrapError(err, errorcode.Internal, c.component, "json.Unmarshal")
+	}
+
+	return nil
+}
+
+// remoteError is the JSON representation of a github.com/stratumn/go-core/types.Error.
+type remoteError struct {
+	Code     int             `json:"code"`
+	Category string          `json:"category"`
+	Message  string          `json:"message"`
+	Inner    json.RawMessage `json:"inner"`
+}
+
+// toError rebuilds the chain of wrapped errors.
+func (e *remoteError) toError() *types.Error {
+	err := &types.Error{Code: e.Code, Component: e.Category, Message: e.Message}
+
+	var inner remoteError
+	var innerMsg string
+	if json.Unmarshal(e.Inner, &inner) == nil && inner.Message != "" {
+		err.Wrapped = inner.toError()
+	} else if json.Unmarshal(e.Inner, &innerMsg) == nil && innerMsg != "" {
+		err.Wrapped = errors.New(innerMsg)
+	}
+
+	return err
+}
+
+// NewErrFromResponse converts an error rendered by a server (see
+// ErrHTTP.JSONMarshal) back to an error.
+// The error codes and messages of the server are kept when available,
+// otherwise the error code is derived from the HTTP status code.
+func NewErrFromResponse(status int, body []byte, component string) error {
+	var res struct {
+		Error json.RawMessage `json:"error"`
+	}
+
+	if err := json.Unmarshal(body, &res); err == nil {
+		var e remoteError
+		if err := json.Unmarshal(res.Error, &e); err == nil && e.Message != "" {
+			return e.toError()
+		}
+
+		var msg string
+		if err := json.Unmarshal(res.Error, &msg); err == nil && msg != "" {
+			return types.NewError(httpCodeToErrorCode(status), component, msg)
+		}
+	}
+
+	return types.NewError(httpCodeToErrorCode(status), component, http.StatusText(status))
+}
+
+// httpCodeToErrorCode returns the error code matching an HTTP status code
+// (see ErrorCodeToHTTPCode).
+func httpCodeToErrorCode(status int) int {
+	switch status {
+	case http.StatusBadRequest:
+		return errorcode.InvalidArgument
+	case http.StatusUnauthorized:
+		return errorcode.Unauthenticated
+	case http.StatusForbidden:
+		return errorcode.PermissionDenied
+	case http.StatusNotFound:
+		return errorcode.NotFound
+	case http.StatusConflict:
+		return errorcode.AlreadyExists
+	case http.StatusTooManyRequests:
+		return errorcode.ResourceExhausted
+	case http.StatusNotImplemented:
+		return errorcode.Unimplemented
+	case http.StatusServiceUnavailable:
+		return errorcode.Unavailable
+	case http.StatusGatewayTimeout:
+		return errorcode.DeadlineExceeded
+	case http.StatusInternalServerError:
+		return errorcode.Internal
+	default:
+		return errorcode.Unknown
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jsonhttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/testutil"
+	"github.com/stratumn/go-core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errTest = errors.New("test error")
+
+func TestNewClient_invalidURL(t *testing.T) {
+	for _, u := range []string{"", "localhost:5000", "ftp://localhost", "http://"} {
+		_, err := NewClient(u, nil, "test")
+		assert.Error(t, err, u)
+	}
+}
+
+func TestClient_Do(t *testing.T) {
+	s := New(&Config{})
+	s.Post("/echo", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) (interface{}, error) {
+		return map[string]string{"q": r.URL.Query().Get("q")}, nil
+	})
+	s.Get("/fail", func(http.ResponseWriter, *http.Request, httprouter.Params) (interface{}, error) {
+		return nil, NewErrHTTP(types.WrapError(errTest, errorcode.FailedPrecondition, "test", "could not do it"))
+	})
+
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	c, err := NewClient(server.URL+"/", nil, "client")
+	require.NoError(t, err)
+
+	t.Run("success", func(t *testing.T) {
+		var res map[string]string
+		err := c.Do(context.Background(), http.MethodPost, "/echo", url.Values{"q": []string{"hello"}}, map[string]int{"a": 1}, &res)
+		require.NoError(t, err)
+		assert.Equal(t, "hello", res["q"])
+	})
+
+	t.Run("error", func(t *testing.T) {
+		err := c.Do(context.Background(), http.MethodGet, "/fail", nil, nil, nil)
+		require.Error(t, err)
+		assert.Equal(t, errorcode.FailedPrecondition, err.(*types.Error).Code)
+		testutil.AssertWrappedErrorEqual(t, err, errTest)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		err := c.Do(context.Background(), http.MethodGet, "/missing", nil, nil, nil)
+		require.Error(t, err)
+		assert.Equal(t, errorcode.NotFound, err.(*types.Error).Code)
+	})
+
+	t.Run("web socket URL", func(t *testing.T) {
+		assert.Equal(t, "ws"+server.URL[len("http"):]+"/websocket", c.WebSocketURL("/websocket"))
+	})
+}
+
+func TestNewErrFromResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		code    int
+		message string
+	}{{
+		"structured error",
+		http.StatusConflict,
+		`{"status":409,"error":{"code":6,"category":"store","message":"could not create link","inner":"link already exists"}}`,
+		errorcode.AlreadyExists,
+		"store error 6: could not create link: link already exists",
+	}, {
+		"plain error",
+		http.StatusNotFound,
+		`{"status":404,"error":"Not Found"}`,
+		errorcode.NotFound,
+		"client error 5: Not Found",
+	}, {
+		"invalid body",
+		http.StatusServiceUnavailable,
+		`<html>`,
+		errorcode.Unavailable,
+		"client error 14: Service Unavailable",
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewErrFromResponse(tt.status, []byte(tt.body), "client").(*types.Error)
+			assert.Equal(t, tt.code, err.Code)
+			assert.Equal(t, tt.message, err.Error())
+		})
+	}
+}
@@ -68,6 +68,7 @@ type Basic struct {
 	upgradeHandle UpgradeHandle
 	msgAllocator  BasicMsgAllocator
 	connChans     []chan *BufferedConn
+	closeChans    []chan *BufferedConn
 	msgChans      []chan BasicConnMsg
 }
 
@@ -151,6 +152,13 @@ func (s *Basic) AddConnChannel(c chan *BufferedConn) {
 	s.connChans = append(s.connChans, c)
 }
 
+// AddCloseChannel adds a channel that will be sent connections once they are
+// closed. A connection is sent after all its messages were sent to the
+// message channels.
+func (s *Basic) AddCloseChannel(c chan *BufferedConn) {
+	s.closeChans = append(s.closeChans, c)
+}
+
 // AddMsgChannel adds a channel that will be sent messages received by
 // connections.
 func (s *Basic) AddMsgChannel(c chan BasicConnMsg) {
@@ -171,8 +179,13 @@ func (s *Basic) Handle(w http.ResponseWriter, r *http.Request) {
 
 	bufConn := NewBufferedConn(conn, s.bufConnConfig)
 
+	// Channels aren't sent anything once the hub has stopped, since their
+	// readers may have stopped too.
 	for _, c := range s.connChans {
-		c <- bufConn
+		select {
+		case c <- bufConn:
+		case <-s.doneChan:
+		}
 	}
 
 	s.Register(bufConn)
@@ -202,7 +215,10 @@ func (s *Basic) Handle(w http.ResponseWriter, r *http.Request) {
 		}
 
 		for _, c := range s.msgChans {
-			c <- connMsg
+			select {
+			case c <- connMsg:
+			case <-s.doneChan:
+			}
 		}
 	}
 
@@ -215,6 +231,13 @@ func (s *Basic) Handle(w http.ResponseWriter, r *http.Request) {
 		}).Warn("Failed to close web socket connection")
 	}
 
+	for _, c := range s.closeChans {
+		select {
+		case c <- bufConn:
+		case <-s.doneChan:
+		}
+	}
+
 	if err = <-errChan; err != nil {
 		monitoring.LogEntry().WithFields(log.Fields{
 			"error":      err,
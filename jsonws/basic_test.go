@@ -91,3 +91,66 @@ func TestBasicAddMsgChannel(t *testing.T) {
 		t.Errorf("no message sent to channel")
 	}
 }
+
+func TestBasicAddCloseChannel(t *testing.T) {
+	ws := NewBasic(&BasicConfig{
+		UpgradeHandle: testUpgradeHandle,
+		MsgAllocator:  testMsgAllocator,
+	}, &BufferedConnConfig{
+		PingInterval: time.Second,
+	})
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/ws", nil)
+	connChan := make(chan *BufferedConn, 1)
+	ws.AddConnChannel(connChan)
+	msgChan := make(chan BasicConnMsg, 1)
+	ws.AddMsgChannel(msgChan)
+	closeChan := make(chan *BufferedConn)
+	ws.AddCloseChannel(closeChan)
+
+	go ws.Start()
+	go ws.Handle(w, r)
+	defer ws.Stop()
+
+	select {
+	case got := <-closeChan:
+		if want := <-connChan; got != want {
+			t.Errorf("<-closeChan = %v want %v", got, want)
+		}
+		if got, want := len(msgChan), 1; got != want {
+			t.Errorf("len(msgChan) = %d want %d", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("no connection sent to channel")
+	}
+}
+
+func TestBasicStop(t *testing.T) {
+	ws := NewBasic(&BasicConfig{
+		UpgradeHandle: testUpgradeHandle,
+		MsgAllocator:  testMsgAllocator,
+	}, &BufferedConnConfig{
+		PingInterval: time.Second,
+	})
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/ws", nil)
+
+	// Nothing reads these channels, as if their reader had stopped.
+	ws.AddMsgChannel(make(chan BasicConnMsg))
+	ws.AddCloseChannel(make(chan *BufferedConn))
+
+	go ws.Start()
+	ws.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		ws.Handle(w, r)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("handler blocked after the server stopped")
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jsonws
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/stratumn/go-core/monitoring"
+)
+
+const (
+	// DefaultReconnectInterval is the default interval between two attempts
+	// to connect to a web socket server.
+	DefaultReconnectInterval = 5 * time.Second
+)
+
+// ErrClientStopped is returned when connecting a client that was stopped.
+var ErrClientStopped = errors.New("web socket client is stopped")
+
+// RawMessage is a message read by a client whose data hasn't been
+// unmarshalled yet.
+type RawMessage struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// ClientConfig contains options for a web socket client.
+type ClientConfig struct {
+	ReconnectInterval time.Duration     // Interval between connection attempts
+	Dialer            *websocket.Dialer // Optional custom dialer
+}
+
+// Client is a web socket client that reads JSON messages from a server and
+// reconnects when the connection is lost.
+// Messages sent by the server while the client is disconnected are lost.
+type Client struct {
+	url      string
+	config   *ClientConfig
+	dialer   *websocket.Dialer
+	mutex    sync.Mutex
+	conn     *websocket.Conn
+	stopped  bool
+	stopChan chan struct{}
+}
+
+// NewClient creates a web socket client for the given URL
+// (ws://host/path or wss://host/path).
+func NewClient(url string, config *ClientConfig) *Client {
+	c := *config
+	if c.ReconnectInterval <= 0 {
+		c.ReconnectInterval = DefaultReconnectInterval
+	}
+
+	dialer := c.Dialer
+	if dialer == nil {
+		dialer = websocket.DefaultDialer
+	}
+
+	return &Client{
+		url:      url,
+		config:   &c,
+		dialer:   dialer,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Connect connects to the server.
+// It is optional since Start connects if needed, but lets callers make sure
+// the connection is established before relying on it.
+func (c *Client) Connect() error {
+	conn, _, err := c.dialer.Dial(c.url, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.stopped {
+		conn.Close()
+		return ErrClientStopped
+	}
+
+	if c.conn != nil {
+		c.conn.Close()
+	}
+
+	c.conn = conn
+
+	return nil
+}
+
+// Start reads messages and passes them to the handler until the client is
+// stopped. It reconnects when the connection is lost.
+func (c *Client) Start(handle func(*RawMessage)) {
+	for {
+		conn, err := c.connection()
+		if err == ErrClientStopped {
+			return
+		}
+		if err != nil {
+			monitoring.LogEntry().WithFields(log.Fields{
+				"url":   c.url,
+				"error": err,
+			}).Warn("Could not connect to web socket, retrying...")
+
+			select {
+			case <-c.stopChan:
+				return
+			case <-time.After(c.config.ReconnectInterval):
+				continue
+			}
+		}
+
+		for {
+			var msg RawMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				break
+			}
+
+			handle(&msg)
+		}
+
+		c.closeConnection(conn)
+
+		select {
+		case <-c.stopChan:
+			return
+		default:
+			monitoring.LogEntry().WithField("url", c.url).Info("Web socket connection lost, reconnecting...")
+		}
+	}
+}
+
+// Stop closes the connection and stops reading messages.
+func (c *Client) Stop() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.stopped {
+		return
+	}
+
+	c.stopped = true
+	close(c.stopChan)
+
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+}
+
+// connection returns the current connection, connecting if needed.
+func (c *Client) connection() (*websocket.Conn, error) {
+	c.mutex.Lock()
+	conn, stopped := c.conn, c.stopped
+	c.mutex.Unlock()
+
+	if stopped {
+		return nil, ErrClientStopped
+	}
+
+	if conn != nil {
+		return conn, nil
+	}
+
+	if err := c.Connect(); err != nil {
+		return nil, err
+	}
+
+	return c.connection()
+}
+
+func (c *Client) closeConnection(conn *websocket.Conn) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	conn.Close()
+
+	if c.conn == conn {
+		c.conn = nil
+	}
+}
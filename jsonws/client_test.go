@@ -0,0 +1,119 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jsonws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// testServer sends a message to each new connection then closes it.
+func testServer(t *testing.T) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrader.Upgrade(): err: %s", err)
+			return
+		}
+		defer conn.Close()
+
+		if err := conn.WriteJSON(&Message{Type: "test", Data: "hello"}); err != nil {
+			t.Errorf("conn.WriteJSON(): err: %s", err)
+		}
+	}))
+}
+
+func TestClientReconnects(t *testing.T) {
+	server := testServer(t)
+	defer server.Close()
+
+	client := NewClient("ws"+strings.TrimPrefix(server.URL, "http"), &ClientConfig{
+		ReconnectInterval: 10 * time.Millisecond,
+	})
+	if err := client.Connect(); err != nil {
+		t.Fatalf("client.Connect(): err: %s", err)
+	}
+
+	msgs := make(chan *RawMessage)
+	done := make(chan struct{})
+	go func() {
+		client.Start(func(msg *RawMessage) { msgs <- msg })
+		close(done)
+	}()
+
+	// Each connection receives a single message, so receiving two of them
+	// means the client reconnected.
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-msgs:
+			if got, want := msg.Type, "test"; got != want {
+				t.Errorf("msg.Type = %q want %q", got, want)
+			}
+			if got, want := string(msg.Data), `"hello"`; got != want {
+				t.Errorf("msg.Data = %s want %s", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("message #%d not received", i)
+		}
+	}
+
+	go func() {
+		for range msgs {
+		}
+	}()
+
+	client.Stop()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("client did not stop")
+	}
+
+	if err := client.Connect(); err != ErrClientStopped {
+		t.Errorf("client.Connect(): err = %v want %v", err, ErrClientStopped)
+	}
+}
+
+func TestClientRetriesConnection(t *testing.T) {
+	client := NewClient("ws://127.0.0.1:1/websocket", &ClientConfig{
+		ReconnectInterval: 10 * time.Millisecond,
+	})
+
+	if err := client.Connect(); err == nil {
+		t.Error("client.Connect(): err = nil want error")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		client.Start(func(*RawMessage) {})
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	client.Stop()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("client did not stop")
+	}
+}
@@ -31,6 +31,7 @@ type Hub struct {
 	// We use channels within a select for operations since maps and the
 	// underlying web socket implementation are not concurrently safe.
 	stopChan      chan struct{}
+	doneChan      chan struct{}
 	regChan       chan Writer
 	unregChan     chan Writer
 	tagChan       chan connTag
@@ -45,9 +46,11 @@ type connTag struct {
 }
 
 // Used by the broadcast channel.
+// When untagged is true, the message is only sent to connections without tags.
 type msgTag struct {
-	msg interface{}
-	tag interface{}
+	msg      interface{}
+	tag      interface{}
+	untagged bool
 }
 
 // NewHub creates a new hub.
@@ -56,6 +59,7 @@ func NewHub() *Hub {
 		conns:         map[Writer]map[interface{}]struct{}{},
 		tags:          map[interface{}]map[Writer]struct{}{},
 		stopChan:      make(chan struct{}),
+		doneChan:      make(chan struct{}),
 		regChan:       make(chan Writer),
 		unregChan:     make(chan Writer),
 		tagChan:       make(chan connTag),
@@ -69,6 +73,7 @@ func (h *Hub) Start() {
 	for {
 		select {
 		case <-h.stopChan:
+			close(h.doneChan)
 			return
 		case c := <-h.regChan:
 			h.conns[c] = map[interface{}]struct{}{}
@@ -79,6 +84,10 @@ func (h *Hub) Start() {
 			}
 			delete(h.conns, c)
 		case t := <-h.tagChan:
+			// The connection may have been unregistered in the meantime.
+			if _, ok := h.conns[t.conn]; !ok {
+				break
+			}
 			// Add tag to connection.
 			h.conns[t.conn][t.tag] = struct{}{}
 			// Add connection to tag.
@@ -90,7 +99,13 @@ func (h *Hub) Start() {
 			delete(h.conns[t.conn], t.tag)
 			delete(h.tags[t.tag], t.conn)
 		case m := <-h.broadcastChan:
-			if m.tag == nil {
+			if m.untagged {
+				for c, tags := range h.conns {
+					if len(tags) == 0 {
+						writeMsg(c, m.msg)
+					}
+				}
+			} else if m.tag == nil {
 				for c := range h.conns {
 					writeMsg(c, m.msg)
 				}
@@ -104,35 +119,60 @@ func (h *Hub) Start() {
 }
 
 // Stop stops managing the client connections.
+// Operations made once the hub has stopped are ignored.
 func (h *Hub) Stop() {
 	h.stopChan <- struct{}{}
 }
 
 // Register adds a connection to the list.
 func (h *Hub) Register(conn Writer) {
-	h.regChan <- conn
+	select {
+	case h.regChan <- conn:
+	case <-h.doneChan:
+	}
 }
 
 // Unregister removes a connection from the list.
 func (h *Hub) Unregister(conn Writer) {
-	h.unregChan <- conn
+	select {
+	case h.unregChan <- conn:
+	case <-h.doneChan:
+	}
 }
 
 // Tag adds a tag to a connection.
 func (h *Hub) Tag(conn Writer, tag interface{}) {
-	h.tagChan <- connTag{conn: conn, tag: tag}
+	select {
+	case h.tagChan <- connTag{conn: conn, tag: tag}:
+	case <-h.doneChan:
+	}
 }
 
 // Untag remotes a tag from a connection.
 func (h *Hub) Untag(conn Writer, tag interface{}) {
-	h.untagChan <- connTag{conn: conn, tag: tag}
+	select {
+	case h.untagChan <- connTag{conn: conn, tag: tag}:
+	case <-h.doneChan:
+	}
 }
 
 // Broadcast broadcasts the JSON representation of a message. If tag is nil,
 // it broadcasts the message to every connection. Otherwise it broadcasts the
 // message only to connections that have that tag.
 func (h *Hub) Broadcast(msg interface{}, tag interface{}) {
-	h.broadcastChan <- msgTag{msg: msg, tag: tag}
+	select {
+	case h.broadcastChan <- msgTag{msg: msg, tag: tag}:
+	case <-h.doneChan:
+	}
+}
+
+// BroadcastUntagged broadcasts the JSON representation of a message to
+// connections that don't have any tag.
+func (h *Hub) BroadcastUntagged(msg interface{}) {
+	select {
+	case h.broadcastChan <- msgTag{msg: msg, untagged: true}:
+	case <-h.doneChan:
+	}
 }
 
 // Writes a message to a connection and logs errors.
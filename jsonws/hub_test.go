@@ -154,3 +154,57 @@ func TestHubUntag(t *testing.T) {
 		t.Errorf("c1.MockWriteJSON.LastCalledWith = %s\n want %s", gotJS, wantJS)
 	}
 }
+
+func TestHubBroadcastUntagged(t *testing.T) {
+	h := NewHub()
+	go h.Start()
+
+	c1 := &jsonwstesting.MockConn{}
+	c2 := &jsonwstesting.MockConn{}
+	c3 := &jsonwstesting.MockConn{}
+
+	h.Register(c1)
+	h.Register(c2)
+	h.Register(c3)
+
+	h.Tag(c1, "test")
+	h.Tag(c3, "test")
+	h.Untag(c3, "test")
+
+	m := map[string]string{"msg": "hello"}
+
+	h.BroadcastUntagged(m)
+	h.Stop()
+
+	if got, want := c1.MockWriteJSON.CalledCount, 0; got != want {
+		t.Errorf(`c1.MockWriteJSON.CalledCount = %d want %d`, got, want)
+	}
+	if got, want := c2.MockWriteJSON.CalledCount, 1; got != want {
+		t.Errorf(`c2.MockWriteJSON.CalledCount = %d want %d`, got, want)
+	}
+	if got, want := c3.MockWriteJSON.CalledCount, 1; got != want {
+		t.Errorf(`c3.MockWriteJSON.CalledCount = %d want %d`, got, want)
+	}
+}
+
+func TestHubTagUnregistered(t *testing.T) {
+	h := NewHub()
+	go h.Start()
+
+	c1 := &jsonwstesting.MockConn{}
+
+	h.Register(c1)
+	h.Unregister(c1)
+
+	// Tagging a connection that was unregistered should be ignored.
+	h.Tag(c1, "test")
+
+	m := map[string]string{"msg": "hello"}
+
+	h.Broadcast(m, "test")
+	h.Stop()
+
+	if got, want := c1.MockWriteJSON.CalledCount, 0; got != want {
+		t.Errorf(`c1.MockWriteJSON.CalledCount = %d want %d`, got, want)
+	}
+}
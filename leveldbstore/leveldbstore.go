@@ -69,3 +69,55 @@ func (a *LevelDBStore) DeleteValue(ctx context.Context, key []byte) ([]byte, err
 
 	return nil, nil
 }
+
+// IteratePrefix calls fn with every key-value pair whose key starts with the
+// given prefix, in key order. It stops at the first error returned by fn.
+func (a *LevelDBStore) IteratePrefix(ctx context.Context, prefix []byte, fn func(key, value []byte) error) error {
+	return a.IterateValues(ctx, prefix, store.PrefixEnd(prefix), fn)
+}
+
+// SetValues atomically sets several key-value pairs.
+func (a *LevelDBStore) SetValues(ctx context.Context, values map[string][]byte) error {
+	batch := a.kvDB.NewBatch()
+	for key, value := range values {
+		batch.Set([]byte(key), value)
+	}
+
+	batch.Write()
+	return nil
+}
+
+// IterateValues implements github.com/stratumn/go-core/store.KeyValueIterator.IterateValues.
+func (a *LevelDBStore) IterateValues(ctx context.Context, start, end []byte, fn func(key, value []byte) error) error {
+	it := a.kvDB.Iterator(start, end)
+	defer it.Close()
+
+	for ; it.Valid(); it.Next() {
+		if err := fn(copyBytes(it.Key()), copyBytes(it.Value())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteValues implements github.com/stratumn/go-core/store.KeyValueBatch.WriteValues.
+func (a *LevelDBStore) WriteValues(ctx context.Context, writes []store.KeyValueWrite) error {
+	batch := a.kvDB.NewBatch()
+	for _, w := range writes {
+		if w.Delete {
+			batch.Delete(w.Key)
+		} else {
+			batch.Set(w.Key, w.Value)
+		}
+	}
+
+	batch.Write()
+	return nil
+}
+
+func copyBytes(b []byte) []byte {
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
@@ -15,12 +15,15 @@
 package leveldbstore
 
 import (
+	"context"
 	"io/ioutil"
 	"os"
 	"testing"
 
 	"github.com/stratumn/go-core/store"
 	"github.com/stratumn/go-core/store/storetestcases"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestLevelDBStore(t *testing.T) {
@@ -44,3 +47,38 @@ func TestLevelDBStore(t *testing.T) {
 
 	factory.RunKeyValueStoreTests(t)
 }
+
+func TestLevelDBStore_IteratePrefix(t *testing.T) {
+	ctx := context.Background()
+	path, err := ioutil.TempDir("", "leveldbstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(path)
+
+	a, err := New(&Config{Path: path})
+	require.NoError(t, err)
+
+	err = a.SetValues(ctx, map[string][]byte{
+		"a":          []byte("0"),
+		"ab\xff":     []byte("1"),
+		"ab\xff\xff": []byte("2"),
+		"ac":         []byte("3"),
+		"b":          []byte("4"),
+	})
+	require.NoError(t, err)
+
+	var keys []string
+	err = a.IteratePrefix(ctx, []byte("ab"), func(key, value []byte) error {
+		keys = append(keys, string(key))
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ab\xff", "ab\xff\xff"}, keys)
+
+	keys = nil
+	err = a.IteratePrefix(ctx, nil, func(key, value []byte) error {
+		keys = append(keys, string(key))
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Len(t, keys, 5)
+}
@@ -0,0 +1,338 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrorstore
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+)
+
+// Types of journal entries.
+const (
+	linkEntry     = "link"
+	evidenceEntry = "evidence"
+
+	// confirmEntry records that the write of an entry succeeded on the
+	// primary.
+	confirmEntry = "confirm"
+
+	// dropEntry records that the write of an entry failed on the primary.
+	dropEntry = "drop"
+)
+
+// entry is a write that must be replicated.
+//
+// An entry is journaled before it is written to the primary, so that a
+// crash can't lose a write that is on the primary but not on the
+// secondaries. It is then resolved: confirmed if the write succeeded on the
+// primary or dropped otherwise. Only confirmed entries are replicated.
+type entry struct {
+	Seq       uint64                `json:"seq"`
+	Type      string                `json:"type"`
+	Link      *chainscript.Link     `json:"link,omitempty"`
+	LinkHash  chainscript.LinkHash  `json:"linkHash,omitempty"`
+	Evidence  *chainscript.Evidence `json:"evidence,omitempty"`
+	CreatedAt time.Time             `json:"createdAt"`
+
+	// resolved is closed once the entry is confirmed or dropped.
+	resolved chan struct{}
+	dropped  bool
+}
+
+func newEntry(e *entry) *entry {
+	e.resolved = make(chan struct{})
+	return e
+}
+
+// isResolved returns whether the entry was confirmed or dropped.
+func (e *entry) isResolved() bool {
+	select {
+	case <-e.resolved:
+		return true
+	default:
+		return false
+	}
+}
+
+// deadLetter is a write that could not be replicated to a secondary.
+type deadLetter struct {
+	Replica   string    `json:"replica"`
+	Error     string    `json:"error"`
+	Entry     *entry    `json:"entry"`
+	DroppedAt time.Time `json:"droppedAt"`
+}
+
+// journal durably saves the writes that haven't been replicated to all the
+// secondaries yet.
+//
+// Entries are appended to a newline-delimited JSON file, followed by a
+// record confirming or dropping them once the primary write is done. The
+// sequence number of the last entry each secondary applied is saved in a
+// separate offsets file. The journal is truncated once every secondary is
+// up to date.
+//
+// Writes that a secondary failed to apply too many times are moved to a
+// dead-letter file so that they can be replayed manually.
+type journal struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	lastSeq uint64
+	offsets map[string]uint64
+}
+
+// openJournal opens or creates a journal and returns the entries that
+// haven't been applied by every replica, in sequence order.
+// Entries that were never resolved (because the program stopped during the
+// primary write) are returned unresolved.
+func openJournal(path string, replicas []string) (*journal, []*entry, error) {
+	j := &journal{path: path, offsets: make(map[string]uint64)}
+
+	if err := j.loadOffsets(); err != nil {
+		return nil, nil, err
+	}
+
+	// Replicas that have been removed from the configuration must not
+	// prevent the journal from being truncated.
+	offsets := make(map[string]uint64, len(replicas))
+	for _, name := range replicas {
+		offsets[name] = j.offsets[name]
+		if offsets[name] > j.lastSeq {
+			j.lastSeq = offsets[name]
+		}
+	}
+
+	j.offsets = offsets
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, nil, types.WrapError(err, errorcode.Unavailable, store.Component, "could not open journal")
+	}
+
+	var entries []*entry
+	bySeq := make(map[uint64]*entry)
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for {
+		e := newEntry(&entry{})
+		if err := dec.Decode(e); err == io.EOF {
+			break
+		} else if err != nil {
+			f.Close()
+			return nil, nil, types.WrapError(err, errorcode.DataLoss, store.Component, "could not read journal")
+		}
+
+		switch e.Type {
+		case confirmEntry, dropEntry:
+			if pending, ok := bySeq[e.Seq]; ok && !pending.isResolved() {
+				pending.dropped = e.Type == dropEntry
+				close(pending.resolved)
+			}
+		default:
+			entries = append(entries, e)
+			bySeq[e.Seq] = e
+			if e.Seq > j.lastSeq {
+				j.lastSeq = e.Seq
+			}
+		}
+	}
+
+	j.file = f
+
+	return j, entries, nil
+}
+
+// offsetsPath returns the path of the file containing the offsets.
+func (j *journal) offsetsPath() string {
+	return j.path + ".offsets"
+}
+
+func (j *journal) loadOffsets() error {
+	data, err := ioutil.ReadFile(j.offsetsPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return types.WrapError(err, errorcode.Unavailable, store.Component, "could not read journal offsets")
+	}
+
+	if err := json.Unmarshal(data, &j.offsets); err != nil {
+		return types.WrapError(err, errorcode.DataLoss, store.Component, "could not read journal offsets")
+	}
+
+	return nil
+}
+
+// saveOffsets atomically replaces the offsets file.
+func (j *journal) saveOffsets() error {
+	data, err := json.Marshal(j.offsets)
+	if err != nil {
+		return types.WrapError(err, errorcode.Internal, store.Component, "could not save journal offsets")
+	}
+
+	tmp := j.offsetsPath() + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return types.WrapError(err, errorcode.Unavailable, store.Component, "could not save journal offsets")
+	}
+
+	if err := os.Rename(tmp, j.offsetsPath()); err != nil {
+		return types.WrapError(err, errorcode.Unavailable, store.Component, "could not save journal offsets")
+	}
+
+	return nil
+}
+
+// offset returns the sequence number of the last entry applied by a
+// replica.
+func (j *journal) offset(replica string) uint64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.offsets[replica]
+}
+
+// appendLocked assigns a sequence number to an unresolved entry and saves
+// it. The entry is on disk when appendLocked returns.
+// The journal lock must be held.
+func (j *journal) appendLocked(e *entry) error {
+	e.Seq = j.lastSeq + 1
+
+	if err := j.writeLocked(e); err != nil {
+		return err
+	}
+
+	j.lastSeq = e.Seq
+
+	return nil
+}
+
+// resolve confirms or drops an entry.
+// The entry is resolved in memory even if the record can't be saved: after
+// a restart, entries without a record are resolved by looking for the write
+// in the primary.
+func (j *journal) resolve(e *entry, confirmed bool) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	record := &entry{Seq: e.Seq, Type: confirmEntry}
+	if !confirmed {
+		record.Type = dropEntry
+	}
+
+	err := j.writeLocked(record)
+
+	e.dropped = !confirmed
+	close(e.resolved)
+
+	return err
+}
+
+// writeLocked appends a record to the journal file and syncs it.
+// The journal lock must be held.
+func (j *journal) writeLocked(e *entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not journal write")
+	}
+
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return types.WrapError(err, errorcode.Unavailable, store.Component, "could not journal write")
+	}
+
+	if err := j.file.Sync(); err != nil {
+		return types.WrapError(err, errorcode.Unavailable, store.Component, "could not journal write")
+	}
+
+	return nil
+}
+
+// deadLettersPath returns the path of the file containing the writes that
+// could not be replicated.
+func (j *journal) deadLettersPath() string {
+	return j.path + ".dead"
+}
+
+// deadLetter saves a write that a replica failed to apply too many times.
+func (j *journal) deadLetter(replica string, e *entry, cause error) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	data, err := json.Marshal(&deadLetter{
+		Replica:   replica,
+		Error:     cause.Error(),
+		Entry:     e,
+		DroppedAt: time.Now(),
+	})
+	if err != nil {
+		return types.WrapError(err, errorcode.Internal, store.Component, "could not save dead letter")
+	}
+
+	f, err := os.OpenFile(j.deadLettersPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return types.WrapError(err, errorcode.Unavailable, store.Component, "could not save dead letter")
+	}
+
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return types.WrapError(err, errorcode.Unavailable, store.Component, "could not save dead letter")
+	}
+
+	if err := f.Sync(); err != nil {
+		return types.WrapError(err, errorcode.Unavailable, store.Component, "could not save dead letter")
+	}
+
+	return nil
+}
+
+// ack records that a replica applied an entry.
+// The journal is truncated when all the replicas applied all the entries.
+func (j *journal) ack(replica string, seq uint64) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.offsets[replica] = seq
+	if err := j.saveOffsets(); err != nil {
+		return err
+	}
+
+	for _, offset := range j.offsets {
+		if offset < j.lastSeq {
+			return nil
+		}
+	}
+
+	if err := j.file.Truncate(0); err != nil {
+		return types.WrapError(err, errorcode.Unavailable, store.Component, "could not truncate journal")
+	}
+
+	return nil
+}
+
+func (j *journal) close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.file.Close()
+}
@@ -0,0 +1,117 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrorstore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stratumn/go-chainscript/chainscripttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "journal")
+
+	j, entries, err := openJournal(path, []string{"r1", "r2"})
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, j.appendLocked(&entry{Type: linkEntry, Link: chainscripttest.RandomLink(t)}))
+	}
+
+	require.NoError(t, j.ack("r1", 3))
+	require.NoError(t, j.ack("r2", 1))
+	require.NoError(t, j.close())
+
+	t.Run("reopen", func(t *testing.T) {
+		j, entries, err := openJournal(path, []string{"r1", "r2"})
+		require.NoError(t, err)
+		require.Len(t, entries, 3)
+		assert.Equal(t, uint64(1), entries[0].Seq)
+		assert.Equal(t, uint64(3), j.offset("r1"))
+		assert.Equal(t, uint64(1), j.offset("r2"))
+
+		require.NoError(t, j.ack("r2", 3))
+
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Zero(t, info.Size(), "journal should be truncated")
+
+		// Sequence numbers keep increasing after a truncation.
+		e := &entry{Type: linkEntry, Link: chainscripttest.RandomLink(t)}
+		require.NoError(t, j.appendLocked(e))
+		assert.Equal(t, uint64(4), e.Seq)
+		require.NoError(t, j.close())
+	})
+
+	t.Run("removed replica", func(t *testing.T) {
+		j, entries, err := openJournal(path, []string{"r1"})
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+
+		require.NoError(t, j.ack("r1", 4))
+		require.NoError(t, j.close())
+
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Zero(t, info.Size(), "journal should be truncated")
+	})
+}
+
+func TestJournal_resolve(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "journal")
+
+	j, _, err := openJournal(path, []string{"r1"})
+	require.NoError(t, err)
+
+	var written []*entry
+	for i := 0; i < 3; i++ {
+		e := newEntry(&entry{Type: linkEntry, Link: chainscripttest.RandomLink(t)})
+		require.NoError(t, j.appendLocked(e))
+		written = append(written, e)
+	}
+
+	require.NoError(t, j.resolve(written[0], true))
+	require.NoError(t, j.resolve(written[1], false))
+	assert.True(t, written[1].dropped)
+	require.NoError(t, j.close())
+
+	j, entries, err := openJournal(path, []string{"r1"})
+	require.NoError(t, err)
+	defer j.close()
+
+	require.Len(t, entries, 3)
+
+	assert.True(t, entries[0].isResolved())
+	assert.False(t, entries[0].dropped)
+
+	assert.True(t, entries[1].isResolved())
+	assert.True(t, entries[1].dropped)
+
+	assert.False(t, entries[2].isResolved(), "interrupted write should not be resolved")
+}
@@ -0,0 +1,86 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrorstore
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/stratumn/go-core/monitoring"
+)
+
+const (
+	replicaLabel = "replica"
+)
+
+var (
+	pendingWrites      *prometheus.GaugeVec
+	replicationLag     *prometheus.GaugeVec
+	replicationLatency *prometheus.HistogramVec
+	replicationErr     *prometheus.CounterVec
+	deadLetters        *prometheus.CounterVec
+)
+
+func init() {
+	pendingWrites = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: monitoring.Stratumn,
+			Subsystem: "mirrorstore",
+			Name:      "pending_writes",
+			Help:      "number of writes waiting to be replicated",
+		},
+		[]string{replicaLabel},
+	)
+
+	replicationLag = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: monitoring.Stratumn,
+			Subsystem: "mirrorstore",
+			Name:      "replication_lag_seconds",
+			Help:      "age of the oldest write waiting to be replicated",
+		},
+		[]string{replicaLabel},
+	)
+
+	replicationLatency = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: monitoring.Stratumn,
+			Subsystem: "mirrorstore",
+			Name:      "replication_latency_ms",
+			Help:      "delay between a write to the primary and its replication",
+			Buckets:   monitoring.DefaultLatencyBuckets,
+		},
+		[]string{replicaLabel},
+	)
+
+	replicationErr = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: monitoring.Stratumn,
+			Subsystem: "mirrorstore",
+			Name:      "replication_error",
+			Help:      "number of failed replication attempts",
+		},
+		[]string{replicaLabel},
+	)
+
+	deadLetters = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: monitoring.Stratumn,
+			Subsystem: "mirrorstore",
+			Name:      "dead_letters",
+			Help:      "number of writes that failed to replicate too many times",
+		},
+		[]string{replicaLabel},
+	)
+}
@@ -0,0 +1,494 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mirrorstore implements a store that writes to a primary store and
+// replicates the writes to secondary stores.
+//
+// It can be used for instance to keep a search engine (such as the
+// elasticsearchstore) in sync with the source of truth.
+// Secondaries only receive the writes made through the mirror store: use the
+// storemigration package to copy the existing data first.
+package mirrorstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+)
+
+const (
+	// Name is the name set in the store's information.
+	Name = "mirror"
+
+	// Description is the description set in the store's information.
+	Description = "Stratumn's Mirror Store"
+
+	// PrimaryName is the name of the primary store in the configuration.
+	PrimaryName = "primary"
+
+	// DefaultRetryInterval is the default interval between two attempts to
+	// replicate a write to a failing secondary.
+	DefaultRetryInterval = 5 * time.Second
+)
+
+// Consistency defines when writes to the secondaries are done.
+type Consistency string
+
+const (
+	// Sync writes to the secondaries before returning.
+	// A write fails if any of the secondaries fails, even though it has
+	// been done on the primary.
+	Sync Consistency = "sync"
+
+	// Async writes to the secondaries in the background.
+	// Writes are saved in a journal before being written to the primary, so
+	// they survive restarts, and are retried until they succeed or until
+	// the maximum number of attempts is reached.
+	Async Consistency = "async"
+)
+
+// Replica is a named secondary store.
+type Replica struct {
+	Name  string
+	Store store.Adapter
+}
+
+// Config contains configuration options for the store.
+type Config struct {
+	// Consistency of the writes to the secondaries. Defaults to Sync.
+	Consistency Consistency
+
+	// ReadFrom is the name of the store serving reads. Defaults to the
+	// primary. Reads from an async secondary may miss the latest writes.
+	ReadFrom string
+
+	// JournalPath is the path of the file where pending writes are saved.
+	// It is required for async consistency.
+	JournalPath string
+
+	// RetryInterval is the interval between two attempts to replicate a
+	// write to a failing secondary. Defaults to DefaultRetryInterval.
+	RetryInterval time.Duration
+
+	// MaxAttempts is the number of attempts after which an async write is
+	// moved to the dead letters file (the journal path with a .dead
+	// extension) instead of blocking the replication to the secondary.
+	// Zero retries writes until they succeed.
+	MaxAttempts int
+}
+
+// Info is the info returned by GetInfo.
+type Info struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Consistency Consistency `json:"consistency"`
+	Secondaries []string    `json:"secondaries"`
+	ReadFrom    string      `json:"readFrom"`
+	Primary     interface{} `json:"primary"`
+}
+
+// MirrorStore is the type that implements github.com/stratumn/go-core/store.Adapter.
+type MirrorStore struct {
+	primary     store.Adapter
+	secondaries []*Replica
+	reader      store.Adapter
+	config      *Config
+
+	journal     *journal
+	replicators []*replicator
+}
+
+// New creates a mirror store.
+// In async mode, the writes left in the journal by a previous run are
+// replicated in the background.
+func New(primary store.Adapter, secondaries []*Replica, config *Config) (*MirrorStore, error) {
+	c := *config
+	if c.Consistency == "" {
+		c.Consistency = Sync
+	}
+	if c.ReadFrom == "" {
+		c.ReadFrom = PrimaryName
+	}
+	if c.RetryInterval <= 0 {
+		c.RetryInterval = DefaultRetryInterval
+	}
+
+	a := &MirrorStore{
+		primary:     primary,
+		secondaries: secondaries,
+		config:      &c,
+	}
+
+	names := make(map[string]struct{}, len(secondaries))
+	for _, r := range secondaries {
+		if _, ok := names[r.Name]; ok || r.Name == "" || r.Name == PrimaryName {
+			return nil, types.NewErrorf(errorcode.InvalidArgument, store.Component, "invalid secondary name %q", r.Name)
+		}
+
+		names[r.Name] = struct{}{}
+
+		if r.Name == c.ReadFrom {
+			a.reader = r.Store
+		}
+	}
+
+	if c.ReadFrom == PrimaryName {
+		a.reader = primary
+	}
+
+	if a.reader == nil {
+		return nil, types.NewErrorf(errorcode.InvalidArgument, store.Component, "unknown store %q to read from", c.ReadFrom)
+	}
+
+	switch c.Consistency {
+	case Sync:
+	case Async:
+		if err := a.startReplicators(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, types.NewErrorf(errorcode.InvalidArgument, store.Component, "unknown consistency %q", c.Consistency)
+	}
+
+	return a, nil
+}
+
+func (a *MirrorStore) startReplicators() error {
+	if a.config.JournalPath == "" {
+		return types.NewError(errorcode.InvalidArgument, store.Component, "async consistency requires a journal")
+	}
+
+	names := make([]string, len(a.secondaries))
+	for i, r := range a.secondaries {
+		names[i] = r.Name
+	}
+
+	j, entries, err := openJournal(a.config.JournalPath, names)
+	if err != nil {
+		return err
+	}
+
+	a.journal = j
+
+	for _, e := range entries {
+		if e.isResolved() {
+			continue
+		}
+
+		if err := a.recover(e); err != nil {
+			j.close()
+			return err
+		}
+	}
+
+	for _, r := range a.secondaries {
+		rep := newReplicator(r, j, a.config, entries)
+		a.replicators = append(a.replicators, rep)
+		go rep.run()
+	}
+
+	return nil
+}
+
+// recover resolves an entry whose primary write was interrupted by looking
+// for the write in the primary.
+func (a *MirrorStore) recover(e *entry) error {
+	ctx := context.Background()
+	found := false
+
+	switch e.Type {
+	case linkEntry:
+		linkHash, err := e.Link.Hash()
+		if err != nil {
+			return types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not recover journal")
+		}
+
+		s, err := a.primary.GetSegment(ctx, linkHash)
+		if err != nil {
+			return types.WrapError(err, errorcode.Unavailable, store.Component, "could not recover journal")
+		}
+
+		found = s != nil
+	case evidenceEntry:
+		evidences, err := a.primary.GetEvidences(ctx, e.LinkHash)
+		if err != nil {
+			return types.WrapError(err, errorcode.Unavailable, store.Component, "could not recover journal")
+		}
+
+		for _, existing := range evidences {
+			if existing.Backend == e.Evidence.Backend && existing.Provider == e.Evidence.Provider {
+				found = true
+			}
+		}
+	}
+
+	return a.journal.resolve(e, found)
+}
+
+// Close stops the replication to async secondaries.
+// Writes that haven't been replicated yet stay in the journal and will be
+// replicated the next time the store is created.
+func (a *MirrorStore) Close() error {
+	if a.journal == nil {
+		return nil
+	}
+
+	for _, r := range a.replicators {
+		r.stop()
+	}
+
+	return a.journal.close()
+}
+
+// write writes to the primary and replicates the entries to the
+// secondaries if it succeeds.
+//
+// In async mode, the entries are journaled before the primary write and
+// confirmed or dropped depending on its result, so that a write can't reach
+// the primary without being replicated.
+func (a *MirrorStore) write(ctx context.Context, entries []*entry, writePrimary func() error) error {
+	now := time.Now()
+	for _, e := range entries {
+		e.CreatedAt = now
+	}
+
+	if a.config.Consistency == Async {
+		if err := a.journalEntries(entries); err != nil {
+			return err
+		}
+
+		err := writePrimary()
+
+		var journalErr error
+		for _, e := range entries {
+			if resolveErr := a.journal.resolve(e, err == nil); resolveErr != nil && journalErr == nil {
+				journalErr = resolveErr
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return journalErr
+	}
+
+	if err := writePrimary(); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if err := a.replicate(ctx, e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// journalEntries saves unresolved entries and queues them for replication.
+func (a *MirrorStore) journalEntries(entries []*entry) error {
+	n, err := a.appendEntries(entries)
+	if err != nil {
+		// Entries that are already journaled must be dropped so that they
+		// don't block the replication.
+		for _, e := range entries[:n] {
+			a.journal.resolve(e, false)
+		}
+	}
+
+	return err
+}
+
+// appendEntries appends entries to the journal and returns how many were
+// appended.
+func (a *MirrorStore) appendEntries(entries []*entry) (int, error) {
+	// The journal lock keeps the replicators' queues in sequence order.
+	a.journal.mu.Lock()
+	defer a.journal.mu.Unlock()
+
+	for i, e := range entries {
+		if err := a.journal.appendLocked(e); err != nil {
+			return i, err
+		}
+
+		for _, r := range a.replicators {
+			r.push(e)
+		}
+	}
+
+	return len(entries), nil
+}
+
+// replicate synchronously sends a write that succeeded on the primary to the
+// secondaries.
+func (a *MirrorStore) replicate(ctx context.Context, e *entry) error {
+	for _, r := range a.secondaries {
+		if err := applyEntry(ctx, r, e); err != nil {
+			replicationErr.With(prometheus.Labels{replicaLabel: r.Name}).Inc()
+			return types.WrapErrorf(err, errorcode.Unavailable, store.Component, "could not replicate write to %s", r.Name)
+		}
+
+		replicationLatency.With(prometheus.Labels{replicaLabel: r.Name}).Observe(
+			float64(time.Since(e.CreatedAt)) / float64(time.Millisecond),
+		)
+	}
+
+	return nil
+}
+
+/********** Store adapter implementation **********/
+
+// GetInfo implements github.com/stratumn/go-core/store.Adapter.GetInfo.
+func (a *MirrorStore) GetInfo(ctx context.Context) (interface{}, error) {
+	primaryInfo, err := a.primary.GetInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	secondaries := make([]string, len(a.secondaries))
+	for i, r := range a.secondaries {
+		secondaries[i] = r.Name
+	}
+
+	return &Info{
+		Name:        Name,
+		Description: Description,
+		Consistency: a.config.Consistency,
+		Secondaries: secondaries,
+		ReadFrom:    a.config.ReadFrom,
+		Primary:     primaryInfo,
+	}, nil
+}
+
+// AddStoreEventChannel implements
+// github.com/stratumn/go-core/store.Adapter.AddStoreEventChannel.
+// Events are sent by the primary.
+func (a *MirrorStore) AddStoreEventChannel(eventChan chan *store.Event) {
+	a.primary.AddStoreEventChannel(eventChan)
+}
+
+// NewBatch implements github.com/stratumn/go-core/store.Adapter.NewBatch.
+func (a *MirrorStore) NewBatch(ctx context.Context) (store.Batch, error) {
+	b, err := a.primary.NewBatch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Batch{Batch: b, store: a}, nil
+}
+
+/********** Store writer implementation **********/
+
+// CreateLink implements github.com/stratumn/go-core/store.LinkWriter.CreateLink.
+func (a *MirrorStore) CreateLink(ctx context.Context, link *chainscript.Link) (chainscript.LinkHash, error) {
+	var linkHash chainscript.LinkHash
+	err := a.write(ctx, []*entry{newEntry(&entry{Type: linkEntry, Link: link})}, func() (err error) {
+		linkHash, err = a.primary.CreateLink(ctx, link)
+		return err
+	})
+
+	return linkHash, err
+}
+
+// AddEvidence implements github.com/stratumn/go-core/store.EvidenceWriter.AddEvidence.
+func (a *MirrorStore) AddEvidence(ctx context.Context, linkHash chainscript.LinkHash, evidence *chainscript.Evidence) error {
+	e := newEntry(&entry{Type: evidenceEntry, LinkHash: linkHash, Evidence: evidence})
+
+	return a.write(ctx, []*entry{e}, func() error {
+		return a.primary.AddEvidence(ctx, linkHash, evidence)
+	})
+}
+
+/********** Store reader implementation **********/
+
+// GetSegment implements github.com/stratumn/go-core/store.SegmentReader.GetSegment.
+func (a *MirrorStore) GetSegment(ctx context.Context, linkHash chainscript.LinkHash) (*chainscript.Segment, error) {
+	return a.reader.GetSegment(ctx, linkHash)
+}
+
+// FindSegments implements github.com/stratumn/go-core/store.SegmentReader.FindSegments.
+func (a *MirrorStore) FindSegments(ctx context.Context, filter *store.SegmentFilter) (*types.PaginatedSegments, error) {
+	return a.reader.FindSegments(ctx, filter)
+}
+
+// GetMapIDs implements github.com/stratumn/go-core/store.SegmentReader.GetMapIDs.
+func (a *MirrorStore) GetMapIDs(ctx context.Context, filter *store.MapFilter) ([]string, error) {
+	return a.reader.GetMapIDs(ctx, filter)
+}
+
+// GetEvidences implements github.com/stratumn/go-core/store.EvidenceReader.GetEvidences.
+func (a *MirrorStore) GetEvidences(ctx context.Context, linkHash chainscript.LinkHash) (types.EvidenceSlice, error) {
+	return a.reader.GetEvidences(ctx, linkHash)
+}
+
+// IterateSegments implements github.com/stratumn/go-core/store.SegmentIterable.IterateSegments.
+func (a *MirrorStore) IterateSegments(ctx context.Context, filter *store.SegmentFilter) (store.SegmentIterator, error) {
+	return store.IterateSegments(ctx, a.reader, filter)
+}
+
+// GetAncestors implements github.com/stratumn/go-core/store.GraphReader.GetAncestors.
+func (a *MirrorStore) GetAncestors(ctx context.Context, linkHash chainscript.LinkHash, depth int) (types.SegmentSlice, error) {
+	return store.GetAncestors(ctx, a.reader, linkHash, depth)
+}
+
+// GetDescendants implements github.com/stratumn/go-core/store.GraphReader.GetDescendants.
+func (a *MirrorStore) GetDescendants(ctx context.Context, linkHash chainscript.LinkHash, depth int) (types.SegmentSlice, error) {
+	return store.GetDescendants(ctx, a.reader, linkHash, depth)
+}
+
+// GetMapHeads implements github.com/stratumn/go-core/store.GraphReader.GetMapHeads.
+func (a *MirrorStore) GetMapHeads(ctx context.Context, process, mapID string) (types.SegmentSlice, error) {
+	return store.GetMapHeads(ctx, a.reader, process, mapID)
+}
+
+/********** Batch implementation **********/
+
+// Batch replicates the links of a batch of the primary store when the batch
+// is written.
+type Batch struct {
+	store.Batch
+
+	store *MirrorStore
+	links []*chainscript.Link
+}
+
+// CreateLink implements github.com/stratumn/go-core/store.LinkWriter.CreateLink.
+func (b *Batch) CreateLink(ctx context.Context, link *chainscript.Link) (chainscript.LinkHash, error) {
+	linkHash, err := b.Batch.CreateLink(ctx, link)
+	if err != nil {
+		return linkHash, err
+	}
+
+	b.links = append(b.links, link)
+
+	return linkHash, nil
+}
+
+// Write implements github.com/stratumn/go-core/store.Batch.Write.
+func (b *Batch) Write(ctx context.Context) error {
+	entries := make([]*entry, len(b.links))
+	for i, link := range b.links {
+		entries[i] = newEntry(&entry{Type: linkEntry, Link: link})
+	}
+
+	return b.store.write(ctx, entries, func() error {
+		return b.Batch.Write(ctx)
+	})
+}
@@ -0,0 +1,370 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrorstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-chainscript/chainscripttest"
+	"github.com/stratumn/go-core/dummystore"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/store/storetestcases"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingStore is a dummy store whose writes can be made to fail.
+type failingStore struct {
+	*dummystore.DummyStore
+	failing int32
+}
+
+func newFailingStore() *failingStore {
+	return &failingStore{DummyStore: dummystore.New(&dummystore.Config{})}
+}
+
+func (s *failingStore) setFailing(failing bool) {
+	if failing {
+		atomic.StoreInt32(&s.failing, 1)
+	} else {
+		atomic.StoreInt32(&s.failing, 0)
+	}
+}
+
+func (s *failingStore) CreateLink(ctx context.Context, link *chainscript.Link) (chainscript.LinkHash, error) {
+	if atomic.LoadInt32(&s.failing) == 1 {
+		return nil, errors.New("unavailable")
+	}
+
+	return s.DummyStore.CreateLink(ctx, link)
+}
+
+func (s *failingStore) AddEvidence(ctx context.Context, linkHash chainscript.LinkHash, evidence *chainscript.Evidence) error {
+	if atomic.LoadInt32(&s.failing) == 1 {
+		return errors.New("unavailable")
+	}
+
+	return s.DummyStore.AddEvidence(ctx, linkHash, evidence)
+}
+
+func assertSegment(t *testing.T, a store.SegmentReader, linkHash chainscript.LinkHash, evidences int) {
+	s, err := a.GetSegment(context.Background(), linkHash)
+	require.NoError(t, err)
+	require.NotNil(t, s, "segment should be found")
+	assert.Len(t, s.Meta.Evidences, evidences)
+}
+
+func TestMirrorStore(t *testing.T) {
+	storetestcases.Factory{
+		New: func() (store.Adapter, error) {
+			return New(
+				dummystore.New(&dummystore.Config{}),
+				[]*Replica{{Name: "secondary", Store: dummystore.New(&dummystore.Config{})}},
+				&Config{},
+			)
+		},
+	}.RunStoreTests(t)
+}
+
+func TestNew_invalidConfig(t *testing.T) {
+	primary := dummystore.New(&dummystore.Config{})
+	secondary := &Replica{Name: "secondary", Store: dummystore.New(&dummystore.Config{})}
+
+	tests := []struct {
+		name        string
+		secondaries []*Replica
+		config      *Config
+	}{
+		{"duplicate secondary", []*Replica{secondary, secondary}, &Config{}},
+		{"reserved name", []*Replica{{Name: PrimaryName, Store: secondary.Store}}, &Config{}},
+		{"unknown reader", []*Replica{secondary}, &Config{ReadFrom: "unknown"}},
+		{"unknown consistency", []*Replica{secondary}, &Config{Consistency: "eventual"}},
+		{"async without journal", []*Replica{secondary}, &Config{Consistency: Async}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(primary, tt.secondaries, tt.config)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestMirrorStore_sync(t *testing.T) {
+	ctx := context.Background()
+
+	primary := dummystore.New(&dummystore.Config{})
+	s1 := newFailingStore()
+	s2 := dummystore.New(&dummystore.Config{})
+
+	a, err := New(primary, []*Replica{{Name: "s1", Store: s1}, {Name: "s2", Store: s2}}, &Config{})
+	require.NoError(t, err)
+
+	linkHash, err := a.CreateLink(ctx, chainscripttest.RandomLink(t))
+	require.NoError(t, err)
+	require.NoError(t, a.AddEvidence(ctx, linkHash, chainscripttest.RandomEvidence(t)))
+
+	for _, r := range []store.SegmentReader{primary, s1, s2} {
+		assertSegment(t, r, linkHash, 1)
+	}
+
+	t.Run("secondary failure", func(t *testing.T) {
+		s1.setFailing(true)
+		defer s1.setFailing(false)
+
+		linkHash, err := a.CreateLink(ctx, chainscripttest.RandomLink(t))
+		assert.Error(t, err)
+		assertSegment(t, primary, linkHash, 0)
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		b, err := a.NewBatch(ctx)
+		require.NoError(t, err)
+
+		linkHash, err := b.CreateLink(ctx, chainscripttest.NewLinkBuilder(t).WithRandomData().WithDegree(-1).Build())
+		require.NoError(t, err)
+		require.NoError(t, b.Write(ctx))
+
+		for _, r := range []store.SegmentReader{primary, s1, s2} {
+			assertSegment(t, r, linkHash, 0)
+		}
+	})
+}
+
+func TestMirrorStore_readFrom(t *testing.T) {
+	ctx := context.Background()
+
+	primary := dummystore.New(&dummystore.Config{})
+	secondary := dummystore.New(&dummystore.Config{})
+
+	a, err := New(primary, []*Replica{{Name: "secondary", Store: secondary}}, &Config{ReadFrom: "secondary"})
+	require.NoError(t, err)
+
+	// Links written directly to a store are only visible when reading from
+	// that store.
+	primaryHash, err := primary.CreateLink(ctx, chainscripttest.RandomLink(t))
+	require.NoError(t, err)
+	secondaryHash, err := secondary.CreateLink(ctx, chainscripttest.RandomLink(t))
+	require.NoError(t, err)
+
+	s, err := a.GetSegment(ctx, primaryHash)
+	require.NoError(t, err)
+	assert.Nil(t, s)
+
+	assertSegment(t, a, secondaryHash, 0)
+
+	info, err := a.GetInfo(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "secondary", info.(*Info).ReadFrom)
+}
+
+func TestMirrorStore_async(t *testing.T) {
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "mirrorstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	journalPath := filepath.Join(dir, "journal")
+	config := &Config{Consistency: Async, JournalPath: journalPath, RetryInterval: 10 * time.Millisecond}
+
+	primary := dummystore.New(&dummystore.Config{})
+	secondary := newFailingStore()
+	secondary.setFailing(true)
+
+	a, err := New(primary, []*Replica{{Name: "secondary", Store: secondary}}, config)
+	require.NoError(t, err)
+
+	parent := chainscripttest.RandomLink(t)
+	parentHash, err := a.CreateLink(ctx, parent)
+	require.NoError(t, err)
+	childHash, err := a.CreateLink(ctx, chainscripttest.NewLinkBuilder(t).WithRandomData().Branch(t, parent).Build())
+	require.NoError(t, err)
+	require.NoError(t, a.AddEvidence(ctx, parentHash, chainscripttest.RandomEvidence(t)))
+
+	assertSegment(t, primary, parentHash, 1)
+	s, err := secondary.GetSegment(ctx, parentHash)
+	require.NoError(t, err)
+	assert.Nil(t, s)
+
+	// Pending writes survive a restart.
+	require.NoError(t, a.Close())
+	secondary.setFailing(false)
+
+	a, err = New(primary, []*Replica{{Name: "secondary", Store: secondary}}, config)
+	require.NoError(t, err)
+	defer a.Close()
+
+	waitFor(t, func() bool {
+		s, _ := secondary.GetSegment(ctx, parentHash)
+		return s != nil && len(s.Meta.Evidences) == 1
+	})
+
+	assertSegment(t, secondary, childHash, 0)
+
+	waitFor(t, func() bool {
+		info, err := os.Stat(journalPath)
+		return err == nil && info.Size() == 0
+	})
+}
+
+func TestMirrorStore_asyncPrimaryFailure(t *testing.T) {
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "mirrorstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	journalPath := filepath.Join(dir, "journal")
+	config := &Config{Consistency: Async, JournalPath: journalPath, RetryInterval: 10 * time.Millisecond}
+
+	primary := newFailingStore()
+	secondary := dummystore.New(&dummystore.Config{})
+
+	a, err := New(primary, []*Replica{{Name: "secondary", Store: secondary}}, config)
+	require.NoError(t, err)
+	defer a.Close()
+
+	primary.setFailing(true)
+	failed := chainscripttest.RandomLink(t)
+	_, err = a.CreateLink(ctx, failed)
+	assert.Error(t, err)
+	primary.setFailing(false)
+
+	// The dropped write doesn't block the following ones.
+	linkHash, err := a.CreateLink(ctx, chainscripttest.RandomLink(t))
+	require.NoError(t, err)
+
+	waitFor(t, func() bool {
+		s, _ := secondary.GetSegment(ctx, linkHash)
+		return s != nil
+	})
+
+	failedHash, err := failed.Hash()
+	require.NoError(t, err)
+	s, err := secondary.GetSegment(ctx, failedHash)
+	require.NoError(t, err)
+	assert.Nil(t, s, "failed write should not be replicated")
+
+	waitFor(t, func() bool {
+		info, err := os.Stat(journalPath)
+		return err == nil && info.Size() == 0
+	})
+}
+
+func TestMirrorStore_asyncRecover(t *testing.T) {
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "mirrorstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	journalPath := filepath.Join(dir, "journal")
+	config := &Config{Consistency: Async, JournalPath: journalPath, RetryInterval: 10 * time.Millisecond}
+
+	primary := dummystore.New(&dummystore.Config{})
+	secondary := dummystore.New(&dummystore.Config{})
+
+	// Simulate writes interrupted after being journaled: only the first one
+	// reached the primary.
+	written := chainscripttest.RandomLink(t)
+	writtenHash, err := primary.CreateLink(ctx, written)
+	require.NoError(t, err)
+
+	lost := chainscripttest.RandomLink(t)
+	lostHash, err := lost.Hash()
+	require.NoError(t, err)
+
+	j, _, err := openJournal(journalPath, []string{"secondary"})
+	require.NoError(t, err)
+	require.NoError(t, j.appendLocked(newEntry(&entry{Type: linkEntry, Link: written})))
+	require.NoError(t, j.appendLocked(newEntry(&entry{Type: linkEntry, Link: lost})))
+	require.NoError(t, j.close())
+
+	a, err := New(primary, []*Replica{{Name: "secondary", Store: secondary}}, config)
+	require.NoError(t, err)
+	defer a.Close()
+
+	waitFor(t, func() bool {
+		info, err := os.Stat(journalPath)
+		return err == nil && info.Size() == 0
+	})
+
+	assertSegment(t, secondary, writtenHash, 0)
+
+	s, err := secondary.GetSegment(ctx, lostHash)
+	require.NoError(t, err)
+	assert.Nil(t, s, "write missing from the primary should not be replicated")
+}
+
+func TestMirrorStore_maxAttempts(t *testing.T) {
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "mirrorstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	journalPath := filepath.Join(dir, "journal")
+	config := &Config{
+		Consistency:   Async,
+		JournalPath:   journalPath,
+		RetryInterval: 10 * time.Millisecond,
+		MaxAttempts:   2,
+	}
+
+	primary := dummystore.New(&dummystore.Config{})
+	secondary := newFailingStore()
+	secondary.setFailing(true)
+
+	a, err := New(primary, []*Replica{{Name: "secondary", Store: secondary}}, config)
+	require.NoError(t, err)
+	defer a.Close()
+
+	linkHash, err := a.CreateLink(ctx, chainscripttest.RandomLink(t))
+	require.NoError(t, err)
+
+	waitFor(t, func() bool {
+		info, err := os.Stat(journalPath)
+		return err == nil && info.Size() == 0
+	})
+
+	data, err := ioutil.ReadFile(journalPath + ".dead")
+	require.NoError(t, err)
+
+	var dead deadLetter
+	require.NoError(t, json.Unmarshal(data, &dead))
+	assert.Equal(t, "secondary", dead.Replica)
+	assert.Equal(t, "unavailable", dead.Error)
+
+	deadHash, err := dead.Entry.Link.Hash()
+	require.NoError(t, err)
+	assert.Equal(t, linkHash, deadHash)
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	for i := 0; !cond(); i++ {
+		require.True(t, i < 100, "condition should be met")
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrorstore
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stratumn/go-core/monitoring"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+)
+
+// replicator applies the journal entries to a secondary in the background.
+type replicator struct {
+	replica       *Replica
+	journal       *journal
+	retryInterval time.Duration
+	maxAttempts   int
+
+	mu     sync.Mutex
+	queue  []*entry
+	notify chan struct{}
+	done   chan struct{}
+	closed chan struct{}
+}
+
+func newReplicator(replica *Replica, j *journal, config *Config, entries []*entry) *replicator {
+	r := &replicator{
+		replica:       replica,
+		journal:       j,
+		retryInterval: config.RetryInterval,
+		maxAttempts:   config.MaxAttempts,
+		notify:        make(chan struct{}, 1),
+		done:          make(chan struct{}),
+		closed:        make(chan struct{}),
+	}
+
+	offset := j.offset(replica.Name)
+	for _, e := range entries {
+		if e.Seq > offset {
+			r.queue = append(r.queue, e)
+		}
+	}
+
+	r.updateLag()
+
+	return r
+}
+
+// push adds an entry to the queue.
+func (r *replicator) push(e *entry) {
+	r.mu.Lock()
+	r.queue = append(r.queue, e)
+	r.mu.Unlock()
+
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+}
+
+// head returns the oldest entry of the queue.
+func (r *replicator) head() *entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.queue) == 0 {
+		return nil
+	}
+
+	return r.queue[0]
+}
+
+func (r *replicator) pop() {
+	r.mu.Lock()
+	r.queue = r.queue[1:]
+	r.mu.Unlock()
+}
+
+// updateLag updates the replication lag metrics.
+func (r *replicator) updateLag() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	labels := prometheus.Labels{replicaLabel: r.replica.Name}
+	pendingWrites.With(labels).Set(float64(len(r.queue)))
+
+	if len(r.queue) == 0 {
+		replicationLag.With(labels).Set(0)
+	} else {
+		replicationLag.With(labels).Set(time.Since(r.queue[0].CreatedAt).Seconds())
+	}
+}
+
+// run applies entries until the replicator is stopped.
+// Entries are applied in order: the replicator waits for the primary write
+// of the oldest entry to be resolved, and a failing entry is retried until it
+// succeeds or until it is moved to the dead letters after too many attempts.
+// Dropped entries are skipped.
+func (r *replicator) run() {
+	defer close(r.closed)
+
+	log := monitoring.LogEntry().WithField("replica", r.replica.Name)
+	labels := prometheus.Labels{replicaLabel: r.replica.Name}
+
+	// State of the oldest entry. The entry isn't applied again while its
+	// acknowledgement is retried.
+	attempts := 0
+	applied := false
+
+	for {
+		e := r.head()
+		if e == nil {
+			select {
+			case <-r.done:
+				return
+			case <-r.notify:
+				continue
+			}
+		}
+
+		select {
+		case <-r.done:
+			return
+		case <-e.resolved:
+		}
+
+		if !applied && !e.dropped {
+			err := applyEntry(context.Background(), r.replica, e)
+			if err == nil {
+				applied = true
+				replicationLatency.With(labels).Observe(float64(time.Since(e.CreatedAt)) / float64(time.Millisecond))
+			} else {
+				attempts++
+				replicationErr.With(labels).Inc()
+
+				if r.maxAttempts > 0 && attempts >= r.maxAttempts {
+					if dlErr := r.journal.deadLetter(r.replica.Name, e, err); dlErr != nil {
+						log.WithField("error", dlErr).Warn("Failed to save dead letter. Retrying...")
+					} else {
+						applied = true
+						deadLetters.With(labels).Inc()
+						log.WithField("seq", e.Seq).WithField("error", err).Error("Write failed to replicate too many times. Moved to dead letters...")
+					}
+				} else {
+					log.WithField("error", err).Warn("Failed to replicate write. Retrying...")
+				}
+			}
+		}
+
+		if applied || e.dropped {
+			err := r.journal.ack(r.replica.Name, e.Seq)
+			if err == nil {
+				attempts, applied = 0, false
+				r.pop()
+				r.updateLag()
+				continue
+			}
+
+			applied = true
+			log.WithField("error", err).Warn("Failed to acknowledge replicated write. Retrying...")
+		}
+
+		r.updateLag()
+
+		select {
+		case <-r.done:
+			return
+		case <-time.After(r.retryInterval):
+		}
+	}
+}
+
+// stop stops the replicator and waits for the current write to complete.
+// Pending entries stay in the journal.
+func (r *replicator) stop() {
+	close(r.done)
+	<-r.closed
+}
+
+// applyEntry writes an entry to a replica.
+// Entries can be applied more than once (for instance if the program stopped
+// before the entry was acknowledged), so writes that were already done
+// aren't considered failures.
+func applyEntry(ctx context.Context, replica *Replica, e *entry) error {
+	switch e.Type {
+	case linkEntry:
+		_, err := replica.Store.CreateLink(ctx, e.Link)
+		if err != nil {
+			if structErr, ok := err.(*types.Error); ok && structErr.Code == errorcode.AlreadyExists {
+				return nil
+			}
+		}
+
+		return err
+	case evidenceEntry:
+		err := replica.Store.AddEvidence(ctx, e.LinkHash, e.Evidence)
+		if err == nil {
+			return nil
+		}
+
+		evidences, getErr := replica.Store.GetEvidences(ctx, e.LinkHash)
+		if getErr != nil {
+			return err
+		}
+
+		for _, existing := range evidences {
+			if existing.Backend == e.Evidence.Backend && existing.Provider == e.Evidence.Provider {
+				return nil
+			}
+		}
+
+		return err
+	default:
+		return types.NewErrorf(errorcode.InvalidArgument, store.Component, "unknown journal entry type %s", e.Type)
+	}
+}
@@ -129,6 +129,62 @@ func (a *StoreAdapter) FindSegments(ctx context.Context, filter *store.SegmentFi
 	return
 }
 
+// IterateSegments instruments the creation of the iterator and delegates to
+// the underlying store (which may not support iterating natively).
+func (a *StoreAdapter) IterateSegments(ctx context.Context, filter *store.SegmentFilter) (it store.SegmentIterator, err error) {
+	tracker := newStoreRequestTracker("IterateSegments")
+	span, ctx := StartSpanIncomingRequest(ctx, fmt.Sprintf("%s/IterateSegments", a.name))
+	defer func() {
+		SetSpanStatusAndEnd(span, err)
+		tracker.End(err)
+	}()
+
+	it, err = store.IterateSegments(ctx, a.s, filter)
+	return
+}
+
+// GetAncestors instruments the call and delegates to the underlying store
+// (which may not support graph traversal natively).
+func (a *StoreAdapter) GetAncestors(ctx context.Context, linkHash chainscript.LinkHash, depth int) (segments types.SegmentSlice, err error) {
+	tracker := newStoreRequestTracker("GetAncestors")
+	span, ctx := StartSpanIncomingRequest(ctx, fmt.Sprintf("%s/GetAncestors", a.name))
+	defer func() {
+		SetSpanStatusAndEnd(span, err)
+		tracker.End(err)
+	}()
+
+	segments, err = store.GetAncestors(ctx, a.s, linkHash, depth)
+	return
+}
+
+// GetDescendants instruments the call and delegates to the underlying store
+// (which may not support graph traversal natively).
+func (a *StoreAdapter) GetDescendants(ctx context.Context, linkHash chainscript.LinkHash, depth int) (segments types.SegmentSlice, err error) {
+	tracker := newStoreRequestTracker("GetDescendants")
+	span, ctx := StartSpanIncomingRequest(ctx, fmt.Sprintf("%s/GetDescendants", a.name))
+	defer func() {
+		SetSpanStatusAndEnd(span, err)
+		tracker.End(err)
+	}()
+
+	segments, err = store.GetDescendants(ctx, a.s, linkHash, depth)
+	return
+}
+
+// GetMapHeads instruments the call and delegates to the underlying store
+// (which may not support graph traversal natively).
+func (a *StoreAdapter) GetMapHeads(ctx context.Context, process, mapID string) (segments types.SegmentSlice, err error) {
+	tracker := newStoreRequestTracker("GetMapHeads")
+	span, ctx := StartSpanIncomingRequest(ctx, fmt.Sprintf("%s/GetMapHeads", a.name))
+	defer func() {
+		SetSpanStatusAndEnd(span, err)
+		tracker.End(err)
+	}()
+
+	segments, err = store.GetMapHeads(ctx, a.s, process, mapID)
+	return
+}
+
 // GetMapIDs instruments the call and delegates to the underlying store.
 func (a *StoreAdapter) GetMapIDs(ctx context.Context, filter *store.MapFilter) (mids []string, err error) {
 	tracker := newStoreRequestTracker("GetMapIDs")
@@ -16,7 +16,9 @@ package postgresstore
 
 import (
 	"flag"
+	"fmt"
 	"os"
+	"text/tabwriter"
 	"time"
 
 	"github.com/stratumn/go-core/monitoring"
@@ -126,7 +128,7 @@ func dropDB(a *Store) {
 
 // RegisterFlags registers the flags used by InitializeWithFlags.
 func RegisterFlags() {
-	flag.BoolVar(&create, "create", false, "create tables and indexes then exit")
+	flag.BoolVar(&create, "create", false, "create tables and indexes (or apply pending migrations) then exit")
 	flag.BoolVar(&drop, "drop", false, "drop tables and indexes then exit")
 	flag.BoolVar(&uniqueMapEntry, "uniquemapentry", false, "enforce unicity of the first link in each process map")
 	flag.StringVar(&url, "url", DefaultURL, "URL of the PostgreSQL database (should be set via the POSTGRESSTORE_URL environment variable)")
@@ -140,3 +142,83 @@ func InitializeWithFlags(version, commit string) *Store {
 	config := &Config{URL: dbURL, Version: version, Commit: commit}
 	return Initialize(config, create, drop, uniqueMapEntry)
 }
+
+// RunCommandWithFlags runs the schema command given as first argument, if
+// any, then exits. It should be called after RegisterFlags and flag.Parse.
+//
+// The commands are:
+//
+//	migrate [-dryrun]	apply the pending schema migrations
+//	status			show the schema version and the migrations
+func RunCommandWithFlags(version, commit string) {
+	if flag.NArg() == 0 {
+		return
+	}
+
+	dbURL := util.OrStrings(os.Getenv("POSTGRESSTORE_URL"), url)
+	a, err := New(&Config{URL: dbURL, Version: version, Commit: commit})
+	if err != nil {
+		monitoring.LogEntry().WithField("error", err).Fatal("Failed to create PostgreSQL store")
+	}
+
+	switch flag.Arg(0) {
+	case "migrate":
+		migrateCmd(a, flag.Args()[1:])
+	case "status":
+		statusCmd(a)
+	default:
+		monitoring.LogEntry().Fatalf("Unknown command %q, expected migrate or status", flag.Arg(0))
+	}
+
+	os.Exit(0)
+}
+
+// migrateCmd applies the pending migrations.
+func migrateCmd(a *Store, args []string) {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dryRun := flags.Bool("dryrun", false, "check the pending migrations then roll them back")
+	flags.Parse(args)
+
+	migrations, err := a.Migrate(*dryRun)
+	for _, m := range migrations {
+		monitoring.LogEntry().
+			WithField("version", m.Version).
+			WithField("dryRun", *dryRun).
+			Infof("Applied migration: %s.", m.Description)
+	}
+	if err != nil {
+		monitoring.LogEntry().WithField("error", err).Fatal("Failed to migrate PostgreSQL schema.")
+	}
+
+	if len(migrations) == 0 {
+		monitoring.LogEntry().Info("PostgreSQL schema is up to date.")
+	} else if *dryRun {
+		monitoring.LogEntry().Info("Dry run: migrations were rolled back.")
+	}
+}
+
+// statusCmd prints the schema version and the status of the migrations.
+func statusCmd(a *Store) {
+	version, err := a.SchemaVersion()
+	if err != nil {
+		monitoring.LogEntry().WithField("error", err).Fatal("Failed to get PostgreSQL schema version.")
+	}
+
+	status, err := a.MigrationStatus()
+	if err != nil {
+		monitoring.LogEntry().WithField("error", err).Fatal("Failed to get PostgreSQL migrations.")
+	}
+
+	fmt.Printf("Schema version: %d (latest: %d)\n\n", version, LatestSchemaVersion())
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tSTATUS\tAPPLIED AT\tDESCRIPTION")
+	for _, m := range status {
+		if m.AppliedAt == nil {
+			fmt.Fprintf(w, "%d\tpending\t\t%s\n", m.Version, m.Description)
+		} else {
+			fmt.Fprintf(w, "%d\tapplied\t%s\t%s\n", m.Version, m.AppliedAt.Format(time.RFC3339), m.Description)
+		}
+	}
+	w.Flush()
+}
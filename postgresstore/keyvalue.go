@@ -23,6 +23,10 @@ import (
 	"github.com/stratumn/go-core/types"
 )
 
+// valuesChunkSize is the number of key-value pairs read at once when
+// iterating over a range of keys.
+const valuesChunkSize = 256
+
 // GetValue implements github.com/stratumn/go-core/store.KeyValueStore.GetValue.
 func (s *scopedStore) GetValue(ctx context.Context, key []byte) ([]byte, error) {
 	var data []byte
@@ -62,3 +66,110 @@ func (s *scopedStore) DeleteValue(ctx context.Context, key []byte) ([]byte, erro
 
 	return data, nil
 }
+
+// IterateValues implements github.com/stratumn/go-core/store.KeyValueIterator.IterateValues.
+// Pairs are read in chunks, and fn is only called once a chunk has been read.
+func (s *scopedStore) IterateValues(ctx context.Context, start, end []byte, fn func(key, value []byte) error) error {
+	for {
+		keys, values, err := s.getValues(ctx, start, end)
+		if err != nil {
+			return err
+		}
+
+		for i, key := range keys {
+			if err := fn(key, values[i]); err != nil {
+				return err
+			}
+		}
+
+		if len(keys) < valuesChunkSize {
+			return nil
+		}
+
+		// The smallest key after the last one read.
+		last := keys[len(keys)-1]
+		start = append(append(make([]byte, 0, len(last)+1), last...), 0)
+	}
+}
+
+func (s *scopedStore) getValues(ctx context.Context, start, end []byte) ([][]byte, [][]byte, error) {
+	if start == nil {
+		start = []byte{}
+	}
+
+	var rows *sql.Rows
+	var err error
+	if end == nil {
+		rows, err = s.stmts.IterateValuesFrom.QueryContext(ctx, start, valuesChunkSize)
+	} else {
+		rows, err = s.stmts.IterateValues.QueryContext(ctx, start, end, valuesChunkSize)
+	}
+	if err != nil {
+		return nil, nil, types.WrapError(err, errorcode.Unavailable, store.Component, "could not iterate values")
+	}
+
+	defer rows.Close()
+
+	var keys, values [][]byte
+	for rows.Next() {
+		var key, value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, nil, types.WrapError(err, errorcode.Internal, store.Component, "could not iterate values")
+		}
+
+		keys = append(keys, key)
+		values = append(values, value)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, types.WrapError(err, errorcode.Internal, store.Component, "could not iterate values")
+	}
+
+	return keys, values, nil
+}
+
+// WriteValues implements github.com/stratumn/go-core/store.KeyValueBatch.WriteValues.
+// The writes are applied in a transaction.
+func (s *scopedStore) WriteValues(ctx context.Context, writes []store.KeyValueWrite) error {
+	tx, err := s.txFactory.NewTx()
+	if err != nil {
+		return err
+	}
+
+	if err := s.writeValuesInTx(ctx, tx, writes); err != nil {
+		s.txFactory.RollbackTx(tx, err)
+		return err
+	}
+
+	return s.txFactory.CommitTx(tx)
+}
+
+func (s *scopedStore) writeValuesInTx(ctx context.Context, tx *sql.Tx, writes []store.KeyValueWrite) error {
+	saveValue, err := tx.Prepare(SQLSaveValue)
+	if err != nil {
+		return types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not write values")
+	}
+
+	deleteValue, err := tx.Prepare(SQLDeleteValue)
+	if err != nil {
+		return types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not write values")
+	}
+
+	for _, w := range writes {
+		if w.Delete {
+			_, err = deleteValue.ExecContext(ctx, w.Key)
+		} else {
+			value := w.Value
+			if value == nil {
+				value = []byte{}
+			}
+
+			_, err = saveValue.ExecContext(ctx, w.Key, value)
+		}
+		if err != nil {
+			return types.WrapError(err, errorcode.Unavailable, store.Component, "could not write values")
+		}
+	}
+
+	return nil
+}
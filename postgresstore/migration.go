@@ -0,0 +1,391 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresstore
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+)
+
+// Migration is a versioned change of the database schema.
+type Migration struct {
+	Version     int
+	Description string
+	Queries     []string
+}
+
+// migrations are applied in order. Their versions must be consecutive.
+//
+// Schemas created before migrations were introduced have no version, so
+// the queries of a migration must succeed when its changes are already
+// there (by using IF NOT EXISTS clauses).
+// Once released, a migration must never be modified: schema changes go in
+// a new migration.
+var migrations = []*Migration{
+	{
+		Version:     1,
+		Description: "create schemas, tables and indexes",
+		Queries: []string{
+			`CREATE SCHEMA IF NOT EXISTS store`,
+			`CREATE SCHEMA IF NOT EXISTS store_private`,
+			`
+				CREATE TABLE IF NOT EXISTS store.links (
+					id BIGSERIAL PRIMARY KEY,
+					link_hash bytea NOT NULL UNIQUE,
+					priority double precision NOT NULL,
+					map_id text NOT NULL,
+					prev_link_hash bytea DEFAULT NULL,
+					tags text[] DEFAULT NULL,
+					data jsonb NOT NULL,
+					process text NOT NULL,
+					step text NOT NULL,
+					link_data jsonb DEFAULT NULL,
+					created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+					updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+				)
+			`,
+			`
+				CREATE INDEX IF NOT EXISTS links_priority_created_at_idx
+				ON store.links (priority DESC, created_at DESC)
+			`,
+			`
+				CREATE INDEX IF NOT EXISTS links_priority_link_hash_idx
+				ON store.links (priority DESC, link_hash ASC)
+			`,
+			`
+				CREATE INDEX IF NOT EXISTS links_map_id_idx
+				ON store.links (map_id text_pattern_ops)
+			`,
+			`
+				CREATE INDEX IF NOT EXISTS links_map_id_priority_created_at_idx
+				ON store.links (map_id, priority DESC, created_at DESC)
+			`,
+			`
+				CREATE INDEX IF NOT EXISTS links_map_id_priority_link_hash_idx
+				ON store.links (map_id, priority DESC, link_hash ASC)
+			`,
+			`
+				CREATE INDEX IF NOT EXISTS links_prev_link_hash_priority_created_at_idx
+				ON store.links (prev_link_hash, priority DESC, created_at DESC)
+			`,
+			`
+				CREATE INDEX IF NOT EXISTS links_process_created_at_idx
+				ON store.links (process, created_at)
+			`,
+			`
+				CREATE INDEX IF NOT EXISTS links_tags_idx
+				ON store.links USING gin(tags)
+			`,
+			`
+				CREATE TABLE IF NOT EXISTS store_private.links_degree (
+					id BIGSERIAL PRIMARY KEY,
+					link_hash bytea references store.links(link_hash) UNIQUE,
+					out_degree integer
+				)
+			`,
+			`
+				CREATE TABLE IF NOT EXISTS store.evidences (
+					id BIGSERIAL PRIMARY KEY,
+					link_hash bytea references store.links(link_hash),
+					provider text NOT NULL,
+					data jsonb NOT NULL,
+					created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+					updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+					UNIQUE(link_hash, provider)
+				)
+			`,
+			`
+				CREATE INDEX IF NOT EXISTS evidences_link_hash_idx
+				ON store.evidences (link_hash)
+			`,
+			`
+				CREATE TABLE IF NOT EXISTS store.values (
+					id BIGSERIAL PRIMARY KEY,
+					key bytea NOT NULL UNIQUE,
+					value bytea NOT NULL,
+					created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+					updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+				)
+			`,
+			`
+				CREATE TABLE IF NOT EXISTS store_private.process_maps (
+					id BIGSERIAL PRIMARY KEY,
+					process text NOT NULL,
+					map_id text NOT NULL,
+					created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+					UNIQUE(process, map_id)
+				)
+			`,
+			`
+				CREATE TABLE IF NOT EXISTS store_private.refs (
+					id BIGSERIAL PRIMARY KEY,
+					link_hash bytea NOT NULL,
+					referenced_by bytea NOT NULL
+				)
+			`,
+			`
+				CREATE INDEX IF NOT EXISTS refs_link_hash_idx
+				ON store_private.refs (link_hash)
+			`,
+		},
+	},
+	{
+		Version:     2,
+		Description: "add link data column to links",
+		Queries: []string{
+			// The link data is stored as base64 in the data column so it's
+			// also stored as JSON to be queried. Links tables created before
+			// this column existed need to be upgraded.
+			`
+				ALTER TABLE store.links
+				ADD COLUMN IF NOT EXISTS link_data jsonb DEFAULT NULL
+			`,
+			// Links saved before the column existed are backfilled from the
+			// base64 data of their JSON. Data that isn't valid JSON is left
+			// NULL, like for new links, so it's never matched by data
+			// predicates.
+			`
+				CREATE OR REPLACE FUNCTION store_private.decode_link_data(data jsonb)
+				RETURNS jsonb AS $$
+				BEGIN
+					RETURN convert_from(decode(data->>'data', 'base64'), 'UTF8')::jsonb;
+				EXCEPTION WHEN others THEN
+					RETURN NULL;
+				END;
+				$$ LANGUAGE plpgsql IMMUTABLE
+			`,
+			`
+				UPDATE store.links
+				SET link_data = store_private.decode_link_data(data)
+				WHERE link_data IS NULL AND data->>'data' IS NOT NULL
+			`,
+			`DROP FUNCTION store_private.decode_link_data(jsonb)`,
+		},
+	},
+}
+
+// linkDataVersion is the version of the migration that backfills the link
+// data column. Data predicates can't be used on older schemas since links
+// may not have their data in that column.
+const linkDataVersion = 2
+
+const (
+	// migrationLockID identifies the advisory lock that prevents concurrent
+	// migrations.
+	migrationLockID = 0x73746f7265
+
+	sqlLockMigrations = `SELECT pg_advisory_xact_lock($1)`
+
+	sqlSchemaVersionExists = `
+		SELECT to_regclass('store_private.schema_version') IS NOT NULL
+	`
+
+	sqlGetSchemaVersion = `
+		SELECT COALESCE(MAX(version), 0) FROM store_private.schema_version
+	`
+	sqlGetAppliedMigrations = `
+		SELECT version, applied_at FROM store_private.schema_version
+	`
+	sqlSaveSchemaVersion = `
+		INSERT INTO store_private.schema_version (
+			version,
+			description
+		)
+		VALUES ($1, $2)
+	`
+)
+
+var sqlCreateSchemaVersion = []string{
+	`CREATE SCHEMA IF NOT EXISTS store_private`,
+	`
+		CREATE TABLE IF NOT EXISTS store_private.schema_version (
+			version integer PRIMARY KEY,
+			description text NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`,
+}
+
+// LatestSchemaVersion returns the version of the schema once all the
+// migrations are applied.
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// MigrationStatus tells whether a migration was applied.
+type MigrationStatus struct {
+	*Migration
+
+	// AppliedAt is nil if the migration is pending.
+	AppliedAt *time.Time
+}
+
+// SchemaVersion returns the version of the database schema, which is zero
+// if no migration was applied.
+func (a *Store) SchemaVersion() (int, error) {
+	exists, err := a.schemaVersionExists()
+	if err != nil || !exists {
+		return 0, err
+	}
+
+	var version int
+	if err := a.db.QueryRow(sqlGetSchemaVersion).Scan(&version); err != nil {
+		return 0, types.WrapError(err, errorcode.Unavailable, store.Component, "could not get schema version")
+	}
+
+	return version, nil
+}
+
+// MigrationStatus returns the status of all the migrations.
+func (a *Store) MigrationStatus() ([]*MigrationStatus, error) {
+	exists, err := a.schemaVersionExists()
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]time.Time)
+	if exists {
+		rows, err := a.db.Query(sqlGetAppliedMigrations)
+		if err != nil {
+			return nil, types.WrapError(err, errorcode.Unavailable, store.Component, "could not get migrations")
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			var version int
+			var appliedAt time.Time
+			if err := rows.Scan(&version, &appliedAt); err != nil {
+				return nil, types.WrapError(err, errorcode.Internal, store.Component, "could not get migrations")
+			}
+
+			applied[version] = appliedAt
+		}
+
+		if err := rows.Err(); err != nil {
+			return nil, types.WrapError(err, errorcode.Internal, store.Component, "could not get migrations")
+		}
+	}
+
+	status := make([]*MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		status[i] = &MigrationStatus{Migration: m}
+		if appliedAt, ok := applied[m.Version]; ok {
+			status[i].AppliedAt = &appliedAt
+		}
+	}
+
+	return status, nil
+}
+
+// Migrate applies the pending migrations and returns them.
+// Each migration is applied in its own transaction.
+//
+// In dry-run mode, the pending migrations are applied in a transaction that
+// is rolled back: they are checked against the database without changing it.
+func (a *Store) Migrate(dryRun bool) ([]*Migration, error) {
+	if dryRun {
+		tx, err := a.db.Begin()
+		if err != nil {
+			return nil, types.WrapError(err, errorcode.Unavailable, store.Component, "could not create tx")
+		}
+
+		defer tx.Rollback()
+
+		return migrateTx(tx, len(migrations))
+	}
+
+	var applied []*Migration
+	for {
+		tx, err := a.db.Begin()
+		if err != nil {
+			return applied, types.WrapError(err, errorcode.Unavailable, store.Component, "could not create tx")
+		}
+
+		m, err := migrateTx(tx, 1)
+		if err != nil {
+			tx.Rollback()
+			return applied, err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return applied, types.WrapError(err, errorcode.Unavailable, store.Component, "could not commit tx")
+		}
+
+		if len(m) == 0 {
+			return applied, nil
+		}
+
+		applied = append(applied, m...)
+	}
+}
+
+// migrateTx applies at most max pending migrations in a transaction.
+// Concurrent migrations wait for the transaction to end.
+func migrateTx(tx *sql.Tx, max int) ([]*Migration, error) {
+	if _, err := tx.Exec(sqlLockMigrations, migrationLockID); err != nil {
+		return nil, types.WrapError(err, errorcode.Unavailable, store.Component, "could not lock migrations")
+	}
+
+	for _, query := range sqlCreateSchemaVersion {
+		if _, err := tx.Exec(query); err != nil {
+			return nil, types.WrapError(err, errorcode.Unavailable, store.Component, "could not create schema version table")
+		}
+	}
+
+	var version int
+	if err := tx.QueryRow(sqlGetSchemaVersion).Scan(&version); err != nil {
+		return nil, types.WrapError(err, errorcode.Unavailable, store.Component, "could not get schema version")
+	}
+
+	var applied []*Migration
+	for _, m := range migrations {
+		if m.Version <= version {
+			continue
+		}
+		if len(applied) == max {
+			break
+		}
+
+		for _, query := range m.Queries {
+			if _, err := tx.Exec(query); err != nil {
+				return nil, types.WrapErrorf(err, errorcode.Internal, store.Component, "could not apply migration %d", m.Version)
+			}
+		}
+
+		if _, err := tx.Exec(sqlSaveSchemaVersion, m.Version, m.Description); err != nil {
+			return nil, types.WrapErrorf(err, errorcode.Internal, store.Component, "could not apply migration %d", m.Version)
+		}
+
+		applied = append(applied, m)
+	}
+
+	return applied, nil
+}
+
+// schemaVersionExists checks whether the schema version table was created.
+func (a *Store) schemaVersionExists() (bool, error) {
+	var exists bool
+	err := a.db.QueryRow(sqlSchemaVersionExists).Scan(&exists)
+	if err != nil {
+		return false, types.WrapError(err, errorcode.Unavailable, store.Component, "could not get schema version")
+	}
+
+	return exists, nil
+}
@@ -0,0 +1,343 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresstore_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-chainscript/chainscripttest"
+	"github.com/stratumn/go-core/postgresstore"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testURL = "postgres://postgres@localhost:5433/sdk_test?sslmode=disable"
+
+// legacySchema is the schema created by the store before migrations were
+// introduced, when the links table didn't have a link data column yet.
+// It must be kept identical to the statements of that release.
+var legacySchema = []string{
+	`CREATE SCHEMA IF NOT EXISTS store`,
+	`CREATE SCHEMA IF NOT EXISTS store_private`,
+	`
+		CREATE TABLE IF NOT EXISTS store.links (
+			id BIGSERIAL PRIMARY KEY,
+			link_hash bytea NOT NULL UNIQUE,
+			priority double precision NOT NULL,
+			map_id text NOT NULL,
+			prev_link_hash bytea DEFAULT NULL,
+			tags text[] DEFAULT NULL,
+			data jsonb NOT NULL,
+			process text NOT NULL,
+			step text NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`,
+	`
+		CREATE INDEX IF NOT EXISTS links_priority_created_at_idx
+		ON store.links (priority DESC, created_at DESC)
+	`,
+	`
+		CREATE INDEX IF NOT EXISTS links_map_id_idx
+		ON store.links (map_id text_pattern_ops)
+	`,
+	`
+		CREATE INDEX IF NOT EXISTS links_map_id_priority_created_at_idx
+		ON store.links (map_id, priority DESC, created_at DESC)
+	`,
+	`
+		CREATE INDEX IF NOT EXISTS links_prev_link_hash_priority_created_at_idx
+		ON store.links (prev_link_hash, priority DESC, created_at DESC)
+	`,
+	`
+		CREATE INDEX IF NOT EXISTS links_tags_idx
+		ON store.links USING gin(tags)
+	`,
+	`
+		CREATE TABLE IF NOT EXISTS store_private.links_degree (
+			id BIGSERIAL PRIMARY KEY,
+			link_hash bytea references store.links(link_hash) UNIQUE,
+			out_degree integer
+		)
+	`,
+	`
+		CREATE TABLE IF NOT EXISTS store.evidences (
+			id BIGSERIAL PRIMARY KEY,
+			link_hash bytea references store.links(link_hash),
+			provider text NOT NULL,
+			data jsonb NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(link_hash, provider)
+		)
+	`,
+	`
+		CREATE INDEX IF NOT EXISTS evidences_link_hash_idx
+		ON store.evidences (link_hash)
+	`,
+	`
+		CREATE TABLE IF NOT EXISTS store.values (
+			id BIGSERIAL PRIMARY KEY,
+			key bytea NOT NULL UNIQUE,
+			value bytea NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`,
+	`
+		CREATE TABLE IF NOT EXISTS store_private.process_maps (
+			id BIGSERIAL PRIMARY KEY,
+			process text NOT NULL,
+			map_id text NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(process, map_id)
+		)
+	`,
+	`
+		CREATE TABLE IF NOT EXISTS store_private.refs (
+			id BIGSERIAL PRIMARY KEY,
+			link_hash bytea NOT NULL,
+			referenced_by bytea NOT NULL
+		)
+	`,
+	`
+		CREATE INDEX IF NOT EXISTS refs_link_hash_idx
+		ON store_private.refs (link_hash)
+	`,
+}
+
+// newEmptyStore creates a store whose database has no schema.
+func newEmptyStore(t *testing.T) *postgresstore.Store {
+	a, err := postgresstore.New(&postgresstore.Config{URL: testURL})
+	require.NoError(t, err)
+	require.NoError(t, a.Drop())
+	return a
+}
+
+func tableExists(t *testing.T, name string) bool {
+	db, err := sql.Open("postgres", testURL)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var exists bool
+	err = db.QueryRow(`SELECT to_regclass($1) IS NOT NULL`, name).Scan(&exists)
+	require.NoError(t, err)
+	return exists
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	latest := postgresstore.LatestSchemaVersion()
+
+	t.Run("empty database", func(t *testing.T) {
+		a := newEmptyStore(t)
+		defer freeStore(a)
+
+		applied, err := a.Migrate(false)
+		require.NoError(t, err)
+		require.Len(t, applied, latest)
+		for i, m := range applied {
+			assert.Equal(t, i+1, m.Version)
+		}
+
+		version, err := a.SchemaVersion()
+		require.NoError(t, err)
+		assert.Equal(t, latest, version)
+
+		applied, err = a.Migrate(false)
+		require.NoError(t, err)
+		assert.Empty(t, applied, "migrations should only be applied once")
+
+		require.NoError(t, a.Prepare())
+		_, err = a.CreateLink(ctx, chainscripttest.NewLinkBuilder(t).WithRandomData().Build())
+		assert.NoError(t, err)
+	})
+
+	t.Run("unversioned schema", func(t *testing.T) {
+		a := newEmptyStore(t)
+		defer freeStore(a)
+
+		db, err := sql.Open("postgres", testURL)
+		require.NoError(t, err)
+		defer db.Close()
+
+		for _, query := range legacySchema {
+			_, err := db.Exec(query)
+			require.NoError(t, err)
+		}
+
+		_, err = db.Exec(`INSERT INTO store.values (key, value) VALUES ('legacy', 'value')`)
+		require.NoError(t, err)
+
+		version, err := a.SchemaVersion()
+		require.NoError(t, err)
+		assert.Equal(t, 0, version)
+
+		applied, err := a.Migrate(false)
+		require.NoError(t, err)
+		assert.Len(t, applied, latest)
+
+		version, err = a.SchemaVersion()
+		require.NoError(t, err)
+		assert.Equal(t, latest, version)
+
+		applied, err = a.Migrate(false)
+		require.NoError(t, err)
+		assert.Empty(t, applied, "migrations should only be applied once")
+
+		version, err = a.SchemaVersion()
+		require.NoError(t, err)
+		assert.Equal(t, latest, version)
+
+		require.NoError(t, a.Prepare())
+
+		value, err := a.GetValue(ctx, []byte("legacy"))
+		require.NoError(t, err)
+		assert.Equal(t, []byte("value"), value, "existing data should be kept")
+
+		// Creating links requires the link data column.
+		_, err = a.CreateLink(ctx, chainscripttest.NewLinkBuilder(t).WithRandomData().Build())
+		assert.NoError(t, err)
+	})
+
+	t.Run("backfills link data", func(t *testing.T) {
+		a := newEmptyStore(t)
+		defer freeStore(a)
+
+		db, err := sql.Open("postgres", testURL)
+		require.NoError(t, err)
+		defer db.Close()
+
+		for _, query := range legacySchema {
+			_, err := db.Exec(query)
+			require.NoError(t, err)
+		}
+
+		// Links saved before the link data column was added.
+		withJSON := chainscripttest.NewLinkBuilder(t).WithData(t, map[string]interface{}{"status": "paid"}).Build()
+		withoutJSON := chainscripttest.NewLinkBuilder(t).Build()
+		withoutJSON.Data = []byte("not json")
+		for _, link := range []*chainscript.Link{withJSON, withoutJSON} {
+			linkHash, err := link.Hash()
+			require.NoError(t, err)
+			data, err := json.Marshal(link)
+			require.NoError(t, err)
+
+			_, err = db.Exec(
+				`INSERT INTO store.links (link_hash, priority, map_id, data, process, step) VALUES ($1, $2, $3, $4, $5, $6)`,
+				linkHash, link.Meta.Priority, link.Meta.MapId, data, link.Meta.Process.Name, link.Meta.Step,
+			)
+			require.NoError(t, err)
+		}
+
+		_, err = db.Exec(`ALTER TABLE store.links ADD COLUMN link_data jsonb DEFAULT NULL`)
+		require.NoError(t, err)
+
+		filter := &store.SegmentFilter{
+			Pagination: store.Pagination{Limit: store.DefaultLimit},
+			Data:       []store.DataPredicate{{Path: "status", Op: store.DataEqual, Value: "paid"}},
+		}
+
+		// Data predicates are rejected until the link data is migrated.
+		require.NoError(t, a.Prepare())
+		_, err = a.FindSegments(ctx, filter)
+		testutil.AssertWrappedErrorEqual(t, err, store.ErrDataFilterNotSupported)
+
+		_, err = a.Migrate(false)
+		require.NoError(t, err)
+		require.NoError(t, a.Prepare())
+
+		segments, err := a.FindSegments(ctx, filter)
+		require.NoError(t, err)
+		require.Len(t, segments.Segments, 1)
+		chainscripttest.LinksEqual(t, withJSON, segments.Segments[0].Link)
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		a := newEmptyStore(t)
+		defer freeStore(a)
+
+		applied, err := a.Migrate(true)
+		require.NoError(t, err)
+		assert.Len(t, applied, latest)
+
+		version, err := a.SchemaVersion()
+		require.NoError(t, err)
+		assert.Equal(t, 0, version)
+		assert.False(t, tableExists(t, "store.links"), "dry run should not create tables")
+
+		applied, err = a.Migrate(false)
+		require.NoError(t, err)
+		assert.Len(t, applied, latest)
+	})
+
+	t.Run("concurrent migrations", func(t *testing.T) {
+		a := newEmptyStore(t)
+		defer freeStore(a)
+
+		b, err := postgresstore.New(&postgresstore.Config{URL: testURL})
+		require.NoError(t, err)
+		defer b.Close()
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		var total int
+
+		for _, s := range []*postgresstore.Store{a, b} {
+			wg.Add(1)
+			go func(s *postgresstore.Store) {
+				defer wg.Done()
+				applied, err := s.Migrate(false)
+				assert.NoError(t, err)
+
+				mu.Lock()
+				total += len(applied)
+				mu.Unlock()
+			}(s)
+		}
+
+		wg.Wait()
+		assert.Equal(t, latest, total, "each migration should be applied once")
+	})
+}
+
+func TestMigrationStatus(t *testing.T) {
+	a := newEmptyStore(t)
+	defer freeStore(a)
+
+	status, err := a.MigrationStatus()
+	require.NoError(t, err)
+	require.Len(t, status, postgresstore.LatestSchemaVersion())
+	for _, m := range status {
+		assert.Nil(t, m.AppliedAt, "migration %d should be pending", m.Version)
+	}
+
+	_, err = a.Migrate(false)
+	require.NoError(t, err)
+
+	status, err = a.MigrationStatus()
+	require.NoError(t, err)
+	for _, m := range status {
+		assert.NotNil(t, m.AppliedAt, "migration %d should be applied", m.Version)
+	}
+}
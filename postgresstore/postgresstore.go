@@ -21,7 +21,6 @@ import (
 	"context"
 	"database/sql"
 
-	"github.com/lib/pq"
 	"github.com/stratumn/go-chainscript"
 	"github.com/stratumn/go-core/monitoring/errorcode"
 	"github.com/stratumn/go-core/store"
@@ -72,6 +71,10 @@ type scopedStore struct {
 	stmts                 *stmts
 	txFactory             TxFactory
 	enforceUniqueMapEntry bool
+
+	// linkDataPending is set when the link data column hasn't been
+	// backfilled yet, in which case data predicates are rejected.
+	linkDataPending bool
 }
 
 func newScopedStore(stmts *stmts, txFactory TxFactory) *scopedStore {
@@ -142,6 +145,8 @@ func (a *Store) NewBatch(ctx context.Context) (store.Batch, error) {
 		return nil, err
 	}
 
+	b.linkDataPending = a.linkDataPending
+
 	a.batches[b] = tx
 	return b, nil
 }
@@ -183,20 +188,11 @@ func (a *Store) AddEvidence(ctx context.Context, linkHash chainscript.LinkHash,
 	return nil
 }
 
-// Create creates the database tables and indexes.
+// Create creates the database tables and indexes, or upgrades them by
+// applying the pending migrations.
 func (a *Store) Create() error {
-	for _, query := range sqlCreate {
-		if _, err := a.db.Exec(query); err != nil {
-			pqErr, ok := err.(*pq.Error)
-			if ok && pqErr != nil {
-				continue
-			}
-
-			return types.WrapError(err, errorcode.Unavailable, store.Component, "could not create tables")
-		}
-	}
-
-	return nil
+	_, err := a.Migrate(false)
+	return err
 }
 
 // Prepare prepares the database stmts.
@@ -208,7 +204,13 @@ func (a *Store) Prepare() error {
 		return err
 	}
 
+	version, err := a.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
 	a.scopedStore = newScopedStore(stmts, NewStandardTxFactory(a.db))
+	a.scopedStore.linkDataPending = version < linkDataVersion
 	return nil
 }
 
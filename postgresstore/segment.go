@@ -174,6 +174,12 @@ func (s *scopedStore) createLinkInTx(
 		prevLinkHash = []byte{}
 	}
 
+	// Data that isn't valid JSON can't be filtered on.
+	var linkData interface{}
+	if json.Valid(link.Data) {
+		linkData = string(link.Data)
+	}
+
 	// Create the link.
 	createLink, err := tx.Prepare(SQLCreateLink)
 	if err != nil {
@@ -190,6 +196,7 @@ func (s *scopedStore) createLinkInTx(
 		string(data),
 		link.Meta.Process.Name,
 		link.Meta.Step,
+		linkData,
 	)
 	if err != nil {
 		return types.WrapError(err, errorcode.Internal, store.Component, "could not create link")
@@ -267,6 +274,10 @@ func (s *scopedStore) GetSegment(ctx context.Context, linkHash chainscript.LinkH
 
 // FindSegments implements github.com/stratumn/go-core/store.SegmentReader.FindSegments.
 func (s *scopedStore) FindSegments(ctx context.Context, filter *store.SegmentFilter) (*types.PaginatedSegments, error) {
+	if err := s.checkDataFilter(filter); err != nil {
+		return nil, err
+	}
+
 	rows, err := s.stmts.FindSegmentsWithFilters(ctx, filter)
 	if err != nil {
 		return nil, err
@@ -274,9 +285,114 @@ func (s *scopedStore) FindSegments(ctx context.Context, filter *store.SegmentFil
 
 	defer rows.Close()
 	segments := &types.PaginatedSegments{Segments: make(types.SegmentSlice, 0, filter.Limit)}
-	err = scanLinkAndEvidences(rows, &segments.Segments, &segments.TotalCount)
+	if err = scanLinkAndEvidences(rows, &segments.Segments, &segments.TotalCount); err != nil {
+		return nil, err
+	}
+
+	segments.NextCursor = store.NextSegmentCursor(segments.Segments, filter.Limit)
 
-	return segments, err
+	return segments, nil
+}
+
+// checkDataFilter rejects data predicates when the link data of existing
+// links hasn't been migrated yet, since they would silently skip them.
+func (s *scopedStore) checkDataFilter(filter *store.SegmentFilter) error {
+	if len(filter.Data) > 0 && s.linkDataPending {
+		return types.WrapError(store.ErrDataFilterNotSupported, errorcode.FailedPrecondition, store.Component, "link data migration is pending")
+	}
+
+	return nil
+}
+
+// IterateSegments implements github.com/stratumn/go-core/store.SegmentIterable.IterateSegments.
+// Rows are read from the database as the iterator advances, so the iterator
+// holds a database connection until it is closed.
+func (s *scopedStore) IterateSegments(ctx context.Context, filter *store.SegmentFilter) (store.SegmentIterator, error) {
+	if err := s.checkDataFilter(filter); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.stmts.IterateSegmentsWithFilters(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &segmentIterator{rows: rows}, nil
+}
+
+// segmentIterator reads segments from rows returned by
+// IterateSegmentsWithFilters.
+// Since a segment spans several rows when it has multiple evidences, the
+// first row of the next segment has to be read before the current segment is
+// complete.
+type segmentIterator struct {
+	rows    *sql.Rows
+	current *chainscript.Segment
+	next    *chainscript.Segment
+	err     error
+}
+
+func (it *segmentIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	it.current, it.next = it.next, nil
+
+	for it.rows.Next() {
+		var (
+			linkHash     chainscript.LinkHash
+			linkData     string
+			evidenceData sql.NullString
+		)
+
+		if err := it.rows.Scan(&linkHash, &linkData, &evidenceData); err != nil {
+			return it.fail(types.WrapError(err, errorcode.Internal, store.Component, "could not scan rows"))
+		}
+
+		segment := it.current
+		if segment == nil || !bytes.Equal(segment.LinkHash(), linkHash) {
+			var err error
+			if segment, err = newSegment(linkData); err != nil {
+				return it.fail(err)
+			}
+		}
+
+		if err := addEvidence(segment, evidenceData); err != nil {
+			return it.fail(err)
+		}
+
+		if it.current == nil {
+			it.current = segment
+		} else if segment != it.current {
+			it.next = segment
+			return true
+		}
+	}
+
+	if err := it.rows.Err(); err != nil {
+		return it.fail(types.WrapError(err, errorcode.Internal, store.Component, "could not scan rows"))
+	}
+
+	return it.current != nil
+}
+
+func (it *segmentIterator) fail(err error) bool {
+	it.current, it.next, it.err = nil, nil, err
+	return false
+}
+
+func (it *segmentIterator) Segment() *chainscript.Segment {
+	return it.current
+}
+
+func (it *segmentIterator) Err() error {
+	return it.err
+}
+
+func (it *segmentIterator) Close() error {
+	it.current, it.next = nil, nil
+	return it.rows.Close()
 }
 
 func scanLinkAndEvidences(rows *sql.Rows, segments *types.SegmentSlice, totalCount *int) error {
@@ -287,9 +403,7 @@ func scanLinkAndEvidences(rows *sql.Rows, segments *types.SegmentSlice, totalCou
 		var (
 			linkHash     chainscript.LinkHash
 			linkData     string
-			link         *chainscript.Link
 			evidenceData sql.NullString
-			evidence     *chainscript.Evidence
 			err          error
 		)
 
@@ -304,34 +418,17 @@ func scanLinkAndEvidences(rows *sql.Rows, segments *types.SegmentSlice, totalCou
 		}
 
 		if !bytes.Equal(currentHash, linkHash) {
-			err = json.Unmarshal([]byte(linkData), &link)
-			if err != nil {
-				return types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not unmarshal link")
-			}
-
-			hash, err := link.Hash()
-			if err != nil {
-				return types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not hash link")
-			}
-			currentHash = hash
-
-			currentSegment, err = link.Segmentify()
+			currentSegment, err = newSegment(linkData)
 			if err != nil {
-				return types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not segmentify")
+				return err
 			}
 
+			currentHash = currentSegment.LinkHash()
 			*segments = append(*segments, currentSegment)
 		}
 
-		if evidenceData.Valid && len(evidenceData.String) > 0 {
-			err = json.Unmarshal([]byte(evidenceData.String), &evidence)
-			if err != nil {
-				return types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not unmarshal evidence")
-			}
-
-			if err := currentSegment.AddEvidence(evidence); err != nil {
-				return types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not add evidence")
-			}
+		if err = addEvidence(currentSegment, evidenceData); err != nil {
+			return err
 		}
 	}
 
@@ -343,6 +440,40 @@ func scanLinkAndEvidences(rows *sql.Rows, segments *types.SegmentSlice, totalCou
 	return nil
 }
 
+// newSegment creates a segment from a link stored in the database.
+func newSegment(linkData string) (*chainscript.Segment, error) {
+	var link *chainscript.Link
+	if err := json.Unmarshal([]byte(linkData), &link); err != nil {
+		return nil, types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not unmarshal link")
+	}
+
+	segment, err := link.Segmentify()
+	if err != nil {
+		return nil, types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not segmentify")
+	}
+
+	return segment, nil
+}
+
+// addEvidence adds an evidence stored in the database to a segment.
+// Nothing is done if the evidence is null (links without evidence).
+func addEvidence(segment *chainscript.Segment, evidenceData sql.NullString) error {
+	if !evidenceData.Valid || len(evidenceData.String) == 0 {
+		return nil
+	}
+
+	var evidence *chainscript.Evidence
+	if err := json.Unmarshal([]byte(evidenceData.String), &evidence); err != nil {
+		return types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not unmarshal evidence")
+	}
+
+	if err := segment.AddEvidence(evidence); err != nil {
+		return types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not add evidence")
+	}
+
+	return nil
+}
+
 // GetMapIDs implements github.com/stratumn/go-core/store.SegmentReader.GetMapIDs.
 func (s *scopedStore) GetMapIDs(ctx context.Context, filter *store.MapFilter) ([]string, error) {
 	rows, err := s.stmts.GetMapIDsWithFilters(ctx, filter)
@@ -17,6 +17,7 @@ package postgresstore
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"strings"
 
@@ -38,9 +39,10 @@ const (
 			tags,
 			data,
 			process,
-			step
+			step,
+			link_data
 		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 	`
 	SQLCreateLinkDegree = `
 		INSERT INTO store_private.links_degree (
@@ -80,6 +82,52 @@ const (
 		LEFT JOIN store.evidences e ON l.link_hash = e.link_hash
 		WHERE l.link_hash = $1
 	`
+	SQLGetAncestors = `
+		WITH RECURSIVE ` + sqlMapEdges + `, ancestors(link_hash, depth) AS (
+			SELECT e.parent, 1 FROM edges e
+			WHERE e.child = $1
+			UNION
+			SELECT e.parent, a.depth + 1 FROM ancestors a
+			JOIN edges e ON e.child = a.link_hash
+			WHERE $2 <= 0 OR a.depth < $2
+		)
+		SELECT l.link_hash, l.data, e.data FROM store.links l
+		JOIN start s ON l.map_id = s.map_id AND l.process = s.process
+		LEFT JOIN store.evidences e ON l.link_hash = e.link_hash
+		WHERE l.link_hash IN (SELECT link_hash FROM ancestors)
+		AND l.link_hash <> $1
+		ORDER BY l.priority DESC, l.link_hash ASC
+	`
+	SQLGetDescendants = `
+		WITH RECURSIVE ` + sqlMapEdges + `, descendants(link_hash, depth) AS (
+			SELECT e.child, 1 FROM edges e
+			WHERE e.parent = $1
+			UNION
+			SELECT e.child, d.depth + 1 FROM descendants d
+			JOIN edges e ON e.parent = d.link_hash
+			WHERE $2 <= 0 OR d.depth < $2
+		)
+		SELECT l.link_hash, l.data, e.data FROM store.links l
+		LEFT JOIN store.evidences e ON l.link_hash = e.link_hash
+		WHERE l.link_hash IN (SELECT link_hash FROM descendants)
+		AND l.link_hash <> $1
+		ORDER BY l.priority DESC, l.link_hash ASC
+	`
+	SQLGetMapHeads = `
+		WITH parents AS (
+			SELECT c.prev_link_hash AS link_hash FROM store.links c
+			WHERE c.process = $1 AND c.map_id = $2 AND c.prev_link_hash IS NOT NULL
+			UNION
+			SELECT r.link_hash FROM store_private.refs r
+			JOIN store.links c ON c.link_hash = r.referenced_by
+			WHERE c.process = $1 AND c.map_id = $2
+		)
+		SELECT l.link_hash, l.data, e.data FROM store.links l
+		LEFT JOIN store.evidences e ON l.link_hash = e.link_hash
+		WHERE l.process = $1 AND l.map_id = $2
+		AND l.link_hash NOT IN (SELECT link_hash FROM parents)
+		ORDER BY l.priority DESC, l.link_hash ASC
+	`
 	SQLSaveValue = `
 		INSERT INTO store.values (
 			key,
@@ -99,6 +147,18 @@ const (
 		WHERE key = $1
 		RETURNING value
 	`
+	SQLIterateValues = `
+		SELECT key, value FROM store.values
+		WHERE key >= $1 AND key < $2
+		ORDER BY key
+		LIMIT $3
+	`
+	SQLIterateValuesFrom = `
+		SELECT key, value FROM store.values
+		WHERE key >= $1
+		ORDER BY key
+		LIMIT $2
+	`
 	SQLGetEvidences = `
 		SELECT data FROM store.evidences
 		WHERE link_hash = $1
@@ -115,100 +175,25 @@ const (
 	`
 )
 
-var sqlCreate = []string{
-	`CREATE SCHEMA IF NOT EXISTS store`,
-	`CREATE SCHEMA IF NOT EXISTS store_private`,
-	`
-		CREATE TABLE IF NOT EXISTS store.links (
-			id BIGSERIAL PRIMARY KEY,
-			link_hash bytea NOT NULL UNIQUE,
-			priority double precision NOT NULL,
-			map_id text NOT NULL,
-			prev_link_hash bytea DEFAULT NULL,
-			tags text[] DEFAULT NULL,
-			data jsonb NOT NULL,
-			process text NOT NULL,
-			step text NOT NULL,
-			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
-		)
-	`,
-	`
-		CREATE INDEX IF NOT EXISTS links_priority_created_at_idx
-		ON store.links (priority DESC, created_at DESC)
-	`,
-	`
-		CREATE INDEX IF NOT EXISTS links_map_id_idx
-		ON store.links (map_id text_pattern_ops)
-	`,
-	`
-		CREATE INDEX IF NOT EXISTS links_map_id_priority_created_at_idx
-		ON store.links (map_id, priority DESC, created_at DESC)
-	`,
-	`
-		CREATE INDEX IF NOT EXISTS links_prev_link_hash_priority_created_at_idx
-		ON store.links (prev_link_hash, priority DESC, created_at DESC)
-	`,
-	`
-		CREATE INDEX IF NOT EXISTS links_tags_idx
-		ON store.links USING gin(tags)
-	`,
-	`
-		CREATE TABLE IF NOT EXISTS store_private.links_degree (
-			id BIGSERIAL PRIMARY KEY,
-			link_hash bytea references store.links(link_hash) UNIQUE,
-			out_degree integer
-		)
-	`,
-	`
-		CREATE TABLE IF NOT EXISTS store.evidences (
-			id BIGSERIAL PRIMARY KEY,
-			link_hash bytea references store.links(link_hash),
-			provider text NOT NULL,
-			data jsonb NOT NULL,
-			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-			UNIQUE(link_hash, provider)
-		)
-	`,
-	`
-		CREATE INDEX IF NOT EXISTS evidences_link_hash_idx
-		ON store.evidences (link_hash)
-	`,
-	`
-		CREATE TABLE IF NOT EXISTS store.values (
-			id BIGSERIAL PRIMARY KEY,
-			key bytea NOT NULL UNIQUE,
-			value bytea NOT NULL,
-			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
-		)
-	`,
-	`
-		CREATE TABLE IF NOT EXISTS store_private.process_maps (
-			id BIGSERIAL PRIMARY KEY,
-			process text NOT NULL,
-			map_id text NOT NULL,
-			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-			UNIQUE(process, map_id)
-		)
-	`,
-	`
-		CREATE TABLE IF NOT EXISTS store_private.refs (
-			id BIGSERIAL PRIMARY KEY,
-			link_hash bytea NOT NULL,
-			referenced_by bytea NOT NULL
-		)
-	`,
-	`
-		CREATE INDEX IF NOT EXISTS refs_link_hash_idx
-		ON store_private.refs (link_hash)
-	`,
-}
+// sqlMapEdges defines the edges of the graph of the map containing the link
+// $1 (from a child to its parent and to the links it references).
+// Edges may point to links of other maps, which need to be filtered out.
+const sqlMapEdges = `
+	start AS (
+		SELECT map_id, process FROM store.links
+		WHERE link_hash = $1
+	), edges(child, parent) AS (
+		SELECT c.link_hash, c.prev_link_hash FROM store.links c
+		JOIN start s ON c.map_id = s.map_id AND c.process = s.process
+		UNION
+		SELECT r.referenced_by, r.link_hash FROM store_private.refs r
+		JOIN store.links c ON c.link_hash = r.referenced_by
+		JOIN start s ON c.map_id = s.map_id AND c.process = s.process
+	)`
 
 var sqlDrop = []string{
-	"DROP SCHEMA store CASCADE",
-	"DROP SCHEMA store_private CASCADE",
+	"DROP SCHEMA IF EXISTS store CASCADE",
+	"DROP SCHEMA IF EXISTS store_private CASCADE",
 }
 
 // SQLPreparer prepares statements.
@@ -236,10 +221,15 @@ type stmts struct {
 	UpdateLinkDegree *sql.Stmt
 	AddRef           *sql.Stmt
 	GetReferencedBy  *sql.Stmt
+	GetAncestors     *sql.Stmt
+	GetDescendants   *sql.Stmt
+	GetMapHeads      *sql.Stmt
 
-	DeleteValue *sql.Stmt
-	GetValue    *sql.Stmt
-	SaveValue   *sql.Stmt
+	DeleteValue       *sql.Stmt
+	GetValue          *sql.Stmt
+	SaveValue         *sql.Stmt
+	IterateValues     *sql.Stmt
+	IterateValuesFrom *sql.Stmt
 
 	AddEvidence  *sql.Stmt
 	GetEvidences *sql.Stmt
@@ -269,10 +259,15 @@ func newStmts(db SQLPreparerQuerier) (*stmts, error) {
 	s.UpdateLinkDegree = prepare(SQLUpdateLinkDegree)
 	s.AddRef = prepare(SQLAddReference)
 	s.GetReferencedBy = prepare(SQLReferencedBy)
+	s.GetAncestors = prepare(SQLGetAncestors)
+	s.GetDescendants = prepare(SQLGetDescendants)
+	s.GetMapHeads = prepare(SQLGetMapHeads)
 
 	s.DeleteValue = prepare(SQLDeleteValue)
 	s.GetValue = prepare(SQLGetValue)
 	s.SaveValue = prepare(SQLSaveValue)
+	s.IterateValues = prepare(SQLIterateValues)
+	s.IterateValuesFrom = prepare(SQLIterateValuesFrom)
 
 	s.AddEvidence = prepare(SQLAddEvidence)
 	s.GetEvidences = prepare(SQLGetEvidences)
@@ -287,19 +282,9 @@ func newStmts(db SQLPreparerQuerier) (*stmts, error) {
 }
 
 // GetMapIDsWithFilters retrieves maps ids from the store given some filters.
+// Map IDs are sorted by most recent update, except when paginating with a
+// cursor which requires them to be sorted alphabetically.
 func (s *stmts) GetMapIDsWithFilters(ctx context.Context, filter *store.MapFilter) (*sql.Rows, error) {
-	sqlHead := `
-		SELECT l.map_id FROM store.links l
-	`
-	sqlTail := fmt.Sprintf(`
-		GROUP BY l.map_id
-		ORDER BY MAX(l.updated_at) DESC
-		OFFSET %d LIMIT %d
-	`,
-		filter.Pagination.Offset,
-		filter.Pagination.Limit,
-	)
-
 	filters := []string{}
 	values := []interface{}{}
 	cnt := 1
@@ -319,8 +304,31 @@ func (s *stmts) GetMapIDsWithFilters(ctx context.Context, filter *store.MapFilte
 	if filter.Process != "" {
 		filters = append(filters, fmt.Sprintf("process = $%d", cnt))
 		values = append(values, filter.Process)
+		cnt++
 	}
 
+	offset := filter.Pagination.Offset
+	ordering := "MAX(l.updated_at) DESC"
+	if filter.Cursor != "" {
+		filters = append(filters, fmt.Sprintf("map_id > $%d", cnt))
+		values = append(values, filter.Cursor)
+		offset = 0
+		ordering = "l.map_id ASC"
+	}
+
+	sqlHead := `
+		SELECT l.map_id FROM store.links l
+	`
+	sqlTail := fmt.Sprintf(`
+		GROUP BY l.map_id
+		ORDER BY %s
+		OFFSET %d LIMIT %d
+	`,
+		ordering,
+		offset,
+		filter.Pagination.Limit,
+	)
+
 	sqlBody := ""
 	if len(filters) > 0 {
 		sqlBody = "\nWHERE "
@@ -337,39 +345,19 @@ func (s *stmts) GetMapIDsWithFilters(ctx context.Context, filter *store.MapFilte
 	return rows, nil
 }
 
-func getOrderingWay(reverse bool) string {
+// getOrdering returns the ordering of the priority and link hash columns
+// matching github.com/stratumn/go-core/types.SegmentSlice.
+func getOrdering(reverse bool) (priority string, linkHash string) {
 	if reverse {
-		return "ASC"
+		return "ASC", "DESC"
 	}
-	return "DESC"
+	return "DESC", "ASC"
 }
 
-// FindSegments formats a read query and retrieves segments according to the filter.
-func (s *stmts) FindSegmentsWithFilters(ctx context.Context, filter *store.SegmentFilter) (*sql.Rows, error) {
-	// Method to count distinct over: https://www.sqlservercentral.com/Forums/FindPost1824788.aspx
-	sqlTotalCount := `DENSE_RANK() OVER (ORDER BY l.link_hash ASC) +
-	DENSE_RANK() OVER (ORDER BY l.link_hash DESC) - 1 AS total_count
-	`
-
-	sqlHead := fmt.Sprintf(`SELECT l.link_hash,
-	l.data,
-	e.data,
-	%s
-	FROM store.links l
-	LEFT JOIN store.evidences e ON l.link_hash = e.link_hash
-	`,
-		sqlTotalCount,
-	)
-
-	sqlTail := fmt.Sprintf(`
-		ORDER BY l.priority %[1]s, l.created_at %[1]s
-		OFFSET %[2]d LIMIT %[3]d
-		`,
-		getOrderingWay(filter.Reverse),
-		filter.Pagination.Offset,
-		filter.Pagination.Limit,
-	)
-
+// segmentFilters returns the SQL conditions (and their values) selecting the
+// links matching the filter.
+// The cursor and pagination are not taken into account.
+func segmentFilters(filter *store.SegmentFilter) ([]string, []interface{}) {
 	filters := []string{}
 	values := []interface{}{}
 	cnt := 1
@@ -392,6 +380,18 @@ func (s *stmts) FindSegmentsWithFilters(ctx context.Context, filter *store.Segme
 		cnt++
 	}
 
+	if len(filter.Processes) > 0 {
+		filters = append(filters, fmt.Sprintf("process = ANY($%d::text[])", cnt))
+		values = append(values, pq.Array(filter.Processes))
+		cnt++
+	}
+
+	if len(filter.Steps) > 0 {
+		filters = append(filters, fmt.Sprintf("step = ANY($%d::text[])", cnt))
+		values = append(values, pq.Array(filter.Steps))
+		cnt++
+	}
+
 	if filter.WithoutParent {
 		filters = append(filters, "prev_link_hash = '\\x'")
 	} else if len(filter.PrevLinkHash) > 0 {
@@ -417,11 +417,142 @@ func (s *stmts) FindSegmentsWithFilters(ctx context.Context, filter *store.Segme
 		cnt++
 	}
 
+	if len(filter.TagsAny) > 0 {
+		filters = append(filters, fmt.Sprintf("tags && $%d", cnt))
+		values = append(values, pq.Array(filter.TagsAny))
+		cnt++
+	}
+
 	if len(filter.Referencing) > 0 {
-		sqlHead += fmt.Sprintf(`INNER JOIN store_private.refs r 
-		ON l.link_hash = r.referenced_by AND r.link_hash = $%d
-		`, cnt)
+		filters = append(filters, fmt.Sprintf(`l.link_hash IN (
+			SELECT r.referenced_by FROM store_private.refs r
+			WHERE r.link_hash = $%d
+		)`, cnt))
 		values = append(values, filter.Referencing)
+		cnt++
+	}
+
+	// created_at doesn't have a time zone: it's the local time of the session
+	// when the link was inserted, so parameters need to be converted to it.
+	if filter.CreatedAfter != nil {
+		filters = append(filters, fmt.Sprintf("l.created_at >= $%d::timestamptz::timestamp", cnt))
+		values = append(values, *filter.CreatedAfter)
+		cnt++
+	}
+
+	if filter.CreatedBefore != nil {
+		filters = append(filters, fmt.Sprintf("l.created_at < $%d::timestamptz::timestamp", cnt))
+		values = append(values, *filter.CreatedBefore)
+	}
+
+	for i := range filter.Data {
+		var filterData string
+		filterData, values = dataFilter(&filter.Data[i], values)
+		filters = append(filters, filterData)
+	}
+
+	return filters, values
+}
+
+// dataFilter returns the SQL condition matching a link data predicate.
+// Invalid predicates don't match any link.
+func dataFilter(p *store.DataPredicate, values []interface{}) (string, []interface{}) {
+	if p.Validate() != nil {
+		return "FALSE", values
+	}
+
+	values = append(values, pq.Array(store.SplitDataPath(p.Path)))
+	path := fmt.Sprintf("l.link_data #> $%d::text[]", len(values))
+
+	switch p.Op {
+	case store.DataEqual:
+		values = append(values, jsonValue(p.Value))
+		return fmt.Sprintf("%s = $%d::jsonb", path, len(values)), values
+	case store.DataIn:
+		jsonValues := make([]string, len(p.Values))
+		for i, v := range p.Values {
+			jsonValues[i] = jsonValue(v)
+		}
+		values = append(values, pq.Array(jsonValues))
+		return fmt.Sprintf("%s = ANY($%d::jsonb[])", path, len(values)), values
+	case store.DataRange:
+		// Casting a value that isn't a number would fail so it has to be
+		// guarded by a CASE (AND doesn't guarantee evaluation order).
+		number := fmt.Sprintf("(%s #>> '{}')::double precision", path)
+		bounds := []string{}
+		for _, b := range []struct {
+			op    string
+			bound *float64
+		}{{">", p.Gt}, {">=", p.Gte}, {"<", p.Lt}, {"<=", p.Lte}} {
+			if b.bound != nil {
+				values = append(values, *b.bound)
+				bounds = append(bounds, fmt.Sprintf("%s %s $%d", number, b.op, len(values)))
+			}
+		}
+		return fmt.Sprintf(
+			"CASE WHEN jsonb_typeof(%s) = 'number' THEN %s ELSE FALSE END",
+			path,
+			strings.Join(bounds, " AND "),
+		), values
+	default:
+		return fmt.Sprintf("%s IS NOT NULL", path), values
+	}
+}
+
+// jsonValue encodes a valid predicate value to JSON.
+func jsonValue(v interface{}) string {
+	normalized, _ := store.NormalizeDataValue(v)
+	js, _ := json.Marshal(normalized)
+	return string(js)
+}
+
+// cursorFilter adds the SQL condition selecting the links that come after
+// the filter's cursor.
+func cursorFilter(filter *store.SegmentFilter, filters []string, values []interface{}) ([]string, []interface{}, error) {
+	cursor, err := store.ParseSegmentCursor(filter.Cursor)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	priorityCmp, linkHashCmp := "<", ">"
+	if filter.Reverse {
+		priorityCmp, linkHashCmp = ">", "<"
+	}
+
+	cnt := len(values) + 1
+	filters = append(filters, fmt.Sprintf(
+		"(l.priority %[1]s $%[2]d OR (l.priority = $%[2]d AND l.link_hash %[3]s $%[4]d))",
+		priorityCmp,
+		cnt,
+		linkHashCmp,
+		cnt+1,
+	))
+	values = append(values, cursor.Priority, []byte(cursor.LinkHash))
+
+	return filters, values, nil
+}
+
+// FindSegmentsWithFilters formats a read query and retrieves segments according to the filter.
+func (s *stmts) FindSegmentsWithFilters(ctx context.Context, filter *store.SegmentFilter) (*sql.Rows, error) {
+	filters, values := segmentFilters(filter)
+
+	// The total count ignores the cursor: it is the number of segments
+	// matching the filter.
+	sqlTotalCount := "SELECT COUNT(*) FROM store.links l"
+	if len(filters) > 0 {
+		sqlTotalCount += "\nWHERE " + strings.Join(filters, "\n AND ")
+	}
+
+	priorityOrder, linkHashOrder := getOrdering(filter.Reverse)
+
+	offset := filter.Pagination.Offset
+	if filter.Cursor != "" {
+		var err error
+		if filters, values, err = cursorFilter(filter, filters, values); err != nil {
+			return nil, err
+		}
+
+		offset = 0
 	}
 
 	sqlBody := ""
@@ -430,7 +561,26 @@ func (s *stmts) FindSegmentsWithFilters(ctx context.Context, filter *store.Segme
 		sqlBody += strings.Join(filters, "\n AND ")
 	}
 
-	query := sqlHead + sqlBody + sqlTail
+	// Pagination is applied to links before joining evidences, otherwise
+	// segments with multiple evidences would take several slots in a page.
+	query := fmt.Sprintf(`
+		SELECT p.link_hash, p.data, e.data, p.total_count FROM (
+			SELECT l.link_hash, l.priority, l.data, (%[1]s) AS total_count
+			FROM store.links l
+			%[2]s
+			ORDER BY l.priority %[3]s, l.link_hash %[4]s
+			OFFSET %[5]d LIMIT %[6]d
+		) p
+		LEFT JOIN store.evidences e ON p.link_hash = e.link_hash
+		ORDER BY p.priority %[3]s, p.link_hash %[4]s
+	`,
+		sqlTotalCount,
+		sqlBody,
+		priorityOrder,
+		linkHashOrder,
+		offset,
+		filter.Pagination.Limit,
+	)
 
 	rows, err := s.query(ctx, query, values...)
 	if err != nil {
@@ -439,3 +589,43 @@ func (s *stmts) FindSegmentsWithFilters(ctx context.Context, filter *store.Segme
 
 	return rows, nil
 }
+
+// IterateSegmentsWithFilters formats a read query that retrieves all the
+// segments matching the filter, without pagination nor total count.
+// Rows of a segment with multiple evidences are consecutive.
+func (s *stmts) IterateSegmentsWithFilters(ctx context.Context, filter *store.SegmentFilter) (*sql.Rows, error) {
+	filters, values := segmentFilters(filter)
+
+	if filter.Cursor != "" {
+		var err error
+		if filters, values, err = cursorFilter(filter, filters, values); err != nil {
+			return nil, err
+		}
+	}
+
+	sqlBody := ""
+	if len(filters) > 0 {
+		sqlBody = "\nWHERE "
+		sqlBody += strings.Join(filters, "\n AND ")
+	}
+
+	priorityOrder, linkHashOrder := getOrdering(filter.Reverse)
+
+	query := fmt.Sprintf(`
+		SELECT l.link_hash, l.data, e.data FROM store.links l
+		LEFT JOIN store.evidences e ON l.link_hash = e.link_hash
+		%[1]s
+		ORDER BY l.priority %[2]s, l.link_hash %[3]s
+	`,
+		sqlBody,
+		priorityOrder,
+		linkHashOrder,
+	)
+
+	rows, err := s.query(ctx, query, values...)
+	if err != nil {
+		return nil, types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not iterate segments")
+	}
+
+	return rows, nil
+}
@@ -101,6 +101,7 @@ type linkWrapper struct {
 	ID           []byte            `json:"id"`
 	Content      *chainscript.Link `json:"content"`
 	Priority     float64           `json:"priority"`
+	CreatedAt    time.Time         `json:"createdAt"`
 	UpdatedAt    time.Time         `json:"updatedAt"`
 	MapID        string            `json:"mapId"`
 	PrevLinkHash []byte            `json:"prevLinkHash"`
@@ -205,11 +206,13 @@ func (a *Store) CreateLink(ctx context.Context, link *chainscript.Link) (chainsc
 		return nil, types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not hash link")
 	}
 
+	now := time.Now().UTC()
 	w := linkWrapper{
 		ID:        linkHash,
 		Content:   link,
 		Priority:  link.Meta.Priority,
-		UpdatedAt: time.Now().UTC(),
+		CreatedAt: now,
+		UpdatedAt: now,
 		MapID:     link.Meta.MapId,
 		Tags:      link.Meta.Tags,
 		Process:   link.Meta.Process.Name,
@@ -220,7 +223,14 @@ func (a *Store) CreateLink(ctx context.Context, link *chainscript.Link) (chainsc
 		w.PrevLinkHash = prevLinkHash
 	}
 
-	if err := a.links.Get(linkHash).Replace(&w).Exec(a.session); err != nil {
+	// The creation time of a link that already exists must be kept.
+	replace := func(row rethink.Term) interface{} {
+		return rethink.Expr(&w).Merge(map[string]interface{}{
+			"createdAt": row.Field("createdAt").Default(w.CreatedAt),
+		})
+	}
+
+	if err := a.links.Get(linkHash).Replace(replace).Exec(a.session); err != nil {
 		return nil, types.WrapError(err, errorcode.Internal, store.Component, "could not create link")
 	}
 
@@ -268,9 +278,20 @@ func (a *Store) FindSegments(ctx context.Context, filter *store.SegmentFilter) (
 		return nil, types.WrapError(store.ErrReferencingNotSupported, errorcode.Unimplemented, store.Component, "could not find segments")
 	}
 
+	if len(filter.Data) > 0 {
+		return nil, types.WrapError(store.ErrDataFilterNotSupported, errorcode.Unimplemented, store.Component, "could not find segments")
+	}
+
 	var prevLinkHash []byte
 	q := a.links
 
+	// Segments are sorted by decreasing priority then link hash to match
+	// github.com/stratumn/go-core/types.SegmentSlice ordering.
+	// When no other index is more selective, an index providing this
+	// ordering is used so that pages are read from the cursor's position.
+	ordered := true
+	var orderPrefix []interface{}
+
 	if filter.WithoutParent || len(filter.PrevLinkHash) > 0 {
 		if len(filter.PrevLinkHash) > 0 {
 			prevLinkHash = filter.PrevLinkHash
@@ -287,6 +308,7 @@ func (a *Store) FindSegments(ctx context.Context, filter *store.SegmentFilter) (
 			LeftBound:  "closed",
 			RightBound: "closed",
 		})
+		ordered = false
 	}
 
 	if len(filter.LinkHashes) > 0 {
@@ -295,43 +317,73 @@ func (a *Store) FindSegments(ctx context.Context, filter *store.SegmentFilter) (
 			ids[i] = v
 		}
 		q = q.GetAll(ids...)
+		ordered = false
 	}
 
-	orderingFunction := rethink.Desc
-	if filter.Reverse {
-		orderingFunction = rethink.Asc
+	orderIndex := "segmentOrder"
+	if len(filter.MapIDs) == 1 {
+		orderIndex, orderPrefix = "mapIdSegmentOrder", []interface{}{filter.MapIDs[0]}
 	}
 
-	if mapIDs := filter.MapIDs; len(mapIDs) > 0 {
-		ids := make([]interface{}, len(mapIDs))
-		for i, v := range mapIDs {
-			ids[i] = v
-		}
-		q = q.Filter(func(row rethink.Term) interface{} {
-			return rethink.Expr(ids).Contains(row.Field("mapId"))
-		})
-	} else if prevLinkHash := filter.PrevLinkHash; len(prevLinkHash) > 0 || filter.WithoutParent {
-		q = q.OrderBy(rethink.OrderByOpts{Index: "prevLinkHashOrder"})
-	} else if linkHashes := filter.LinkHashes; len(linkHashes) > 0 {
-		q = q.OrderBy(rethink.Asc("id"))
+	// The total count ignores the cursor.
+	var countQuery rethink.Term
+	if ordered {
+		lower, upper := segmentOrderBounds(orderPrefix, nil, false)
+		countQuery = filterSegments(q.Between(lower, upper, rethink.BetweenOpts{
+			Index:      orderIndex,
+			LeftBound:  "closed",
+			RightBound: "closed",
+		}), filter).Count()
 	} else {
-		q = q.OrderBy(rethink.OrderByOpts{Index: orderingFunction("order")})
+		countQuery = filterSegments(q, filter).Count()
 	}
 
-	if process := filter.Process; len(process) > 0 {
-		q = q.Filter(rethink.Row.Field("process").Eq(process))
-	}
+	var cursor *store.SegmentCursor
+	offset := filter.Offset
+	if filter.Cursor != "" {
+		var err error
+		if cursor, err = store.ParseSegmentCursor(filter.Cursor); err != nil {
+			return nil, err
+		}
 
-	if step := filter.Step; len(step) > 0 {
-		q = q.Filter(rethink.Row.Field("step").Eq(step))
+		offset = 0
 	}
 
-	if tags := filter.Tags; len(tags) > 0 {
-		t := make([]interface{}, len(tags))
-		for i, v := range tags {
-			t[i] = v
+	if ordered {
+		lower, upper := segmentOrderBounds(orderPrefix, cursor, filter.Reverse)
+		opts := rethink.BetweenOpts{Index: orderIndex, LeftBound: "closed", RightBound: "closed"}
+		ordering := rethink.Asc
+		if cursor != nil && !filter.Reverse {
+			opts.LeftBound = "open"
 		}
-		q = q.Filter(rethink.Row.Field("tags").Contains(t...))
+		if filter.Reverse {
+			ordering = rethink.Desc
+			if cursor != nil {
+				opts.RightBound = "open"
+			}
+		}
+
+		q = q.Between(lower, upper, opts).OrderBy(rethink.OrderByOpts{Index: ordering(orderIndex)})
+		q = filterSegments(q, filter)
+	} else {
+		q = filterSegments(q, filter)
+
+		if cursor != nil {
+			q = q.Filter(func(row rethink.Term) interface{} {
+				priority, id := row.Field("priority"), row.Field("id")
+				if filter.Reverse {
+					return priority.Gt(cursor.Priority).Or(priority.Eq(cursor.Priority).And(id.Lt([]byte(cursor.LinkHash))))
+				}
+				return priority.Lt(cursor.Priority).Or(priority.Eq(cursor.Priority).And(id.Gt([]byte(cursor.LinkHash))))
+			})
+		}
+
+		// The candidate links are few enough to be sorted in memory.
+		priorityOrdering, idOrdering := rethink.Desc, rethink.Asc
+		if filter.Reverse {
+			priorityOrdering, idOrdering = rethink.Asc, rethink.Desc
+		}
+		q = q.OrderBy(priorityOrdering("priority"), idOrdering("id"))
 	}
 
 	q = q.OuterJoin(a.evidences, func(a, b rethink.Term) rethink.Term {
@@ -345,7 +397,7 @@ func (a *Store) FindSegments(ctx context.Context, filter *store.SegmentFilter) (
 		}
 	})
 
-	cur, err := q.Skip(filter.Offset).Limit(filter.Limit).Run(a.session)
+	cur, err := q.Skip(offset).Limit(filter.Limit).Run(a.session)
 	if err != nil {
 		return nil, types.WrapError(err, errorcode.Unavailable, store.Component, "could not find segments")
 	}
@@ -358,16 +410,14 @@ func (a *Store) FindSegments(ctx context.Context, filter *store.SegmentFilter) (
 		return nil, types.WrapError(err, errorcode.Unavailable, store.Component, "could not find segments")
 	}
 
-	// Non optimal way to count all segments
-	totalCountCur, err := q.Run(a.session)
+	totalCountCur, err := countQuery.Run(a.session)
 	if err != nil {
 		return nil, types.WrapError(err, errorcode.Unavailable, store.Component, "could not count segments")
 	}
 	defer totalCountCur.Close()
 
-	segIt := chainscript.Segment{}
-	for totalCountCur.Next(&segIt) {
-		segments.TotalCount++
+	if err := totalCountCur.One(&segments.TotalCount); err != nil {
+		return nil, types.WrapError(err, errorcode.Unavailable, store.Component, "could not count segments")
 	}
 
 	for _, s := range segments.Segments {
@@ -377,28 +427,122 @@ func (a *Store) FindSegments(ctx context.Context, filter *store.SegmentFilter) (
 		}
 	}
 
+	segments.NextCursor = store.NextSegmentCursor(segments.Segments, filter.Limit)
+
 	return segments, nil
 }
 
+// filterSegments filters links using the fields of the filter that aren't
+// handled by an index.
+func filterSegments(q rethink.Term, filter *store.SegmentFilter) rethink.Term {
+	if mapIDs := filter.MapIDs; len(mapIDs) > 0 {
+		ids := make([]interface{}, len(mapIDs))
+		for i, v := range mapIDs {
+			ids[i] = v
+		}
+		q = q.Filter(func(row rethink.Term) interface{} {
+			return rethink.Expr(ids).Contains(row.Field("mapId"))
+		})
+	}
+
+	if process := filter.Process; len(process) > 0 {
+		q = q.Filter(rethink.Row.Field("process").Eq(process))
+	}
+
+	if step := filter.Step; len(step) > 0 {
+		q = q.Filter(rethink.Row.Field("step").Eq(step))
+	}
+
+	if processes := filter.Processes; len(processes) > 0 {
+		q = q.Filter(func(row rethink.Term) interface{} {
+			return rethink.Expr(processes).Contains(row.Field("process"))
+		})
+	}
+
+	if steps := filter.Steps; len(steps) > 0 {
+		q = q.Filter(func(row rethink.Term) interface{} {
+			return rethink.Expr(steps).Contains(row.Field("step"))
+		})
+	}
+
+	if tags := filter.Tags; len(tags) > 0 {
+		t := make([]interface{}, len(tags))
+		for i, v := range tags {
+			t[i] = v
+		}
+		q = q.Filter(rethink.Row.Field("tags").Contains(t...))
+	}
+
+	if tagsAny := filter.TagsAny; len(tagsAny) > 0 {
+		q = q.Filter(func(row rethink.Term) interface{} {
+			return row.Field("tags").Contains(func(tag rethink.Term) interface{} {
+				return rethink.Expr(tagsAny).Contains(tag)
+			})
+		})
+	}
+
+	if createdAfter := filter.CreatedAfter; createdAfter != nil {
+		q = q.Filter(rethink.Row.Field("createdAt").Ge(*createdAfter))
+	}
+
+	if createdBefore := filter.CreatedBefore; createdBefore != nil {
+		q = q.Filter(rethink.Row.Field("createdAt").Lt(*createdBefore))
+	}
+
+	return q
+}
+
+// segmentOrderBounds returns the bounds of the links with the given index
+// prefix in the segmentOrder and mapIdSegmentOrder indexes, where a cursor
+// is the position of a link.
+// The indexes sort links by increasing opposite priority then link hash,
+// which is the ordering of segments.
+func segmentOrderBounds(prefix []interface{}, cursor *store.SegmentCursor, reverse bool) (lower, upper interface{}) {
+	key := func(priority, id interface{}) []interface{} {
+		return append(append([]interface{}{}, prefix...), priority, id)
+	}
+
+	lower, upper = key(rethink.MinVal, rethink.MinVal), key(rethink.MaxVal, rethink.MaxVal)
+	if cursor == nil {
+		return lower, upper
+	}
+
+	position := key(-cursor.Priority, []byte(cursor.LinkHash))
+	if reverse {
+		return lower, position
+	}
+
+	return position, upper
+}
+
 // GetMapIDs implements github.com/stratumn/go-core/store.SegmentReader.GetMapIDs.
 func (a *Store) GetMapIDs(ctx context.Context, filter *store.MapFilter) ([]string, error) {
+	// Map IDs are sorted alphabetically so the cursor is a lower bound.
+	var after interface{} = rethink.MinVal
+	leftBound := "closed"
+	if filter.Cursor != "" {
+		after = filter.Cursor
+		leftBound = "open"
+	}
+
 	q := a.links
 	if process := filter.Process; len(process) > 0 {
 
 		q = q.Between([]interface{}{
 			process,
-			rethink.MinVal,
+			after,
 		}, []interface{}{
 			process,
 			rethink.MaxVal,
 		}, rethink.BetweenOpts{
 			Index:      "processOrder",
-			LeftBound:  "closed",
+			LeftBound:  leftBound,
 			RightBound: "closed",
 		})
 	} else {
-		q = q.Between(rethink.MinVal, rethink.MaxVal, rethink.BetweenOpts{
-			Index: "mapId",
+		q = q.Between(after, rethink.MaxVal, rethink.BetweenOpts{
+			Index:     "mapId",
+			LeftBound: leftBound,
 		})
 	}
 
@@ -429,7 +573,12 @@ func (a *Store) GetMapIDs(ctx context.Context, filter *store.MapFilter) ([]strin
 		}
 	}
 
-	cur, err := q.Skip(filter.Pagination.Offset).Limit(filter.Limit).Run(a.session)
+	offset := filter.Pagination.Offset
+	if filter.Cursor != "" {
+		offset = 0
+	}
+
+	cur, err := q.Skip(offset).Limit(filter.Limit).Run(a.session)
 	if err != nil {
 		return nil, types.WrapError(err, errorcode.Unavailable, store.Component, "could not get map ids")
 	}
@@ -564,6 +713,42 @@ func (a *Store) DeleteValue(ctx context.Context, key []byte) ([]byte, error) {
 	return w.Value, nil
 }
 
+// IterateValues implements github.com/stratumn/go-core/store.KeyValueIterator.IterateValues.
+// Keys are saved as binary primary keys, which RethinkDB sorts bytewise.
+func (a *Store) IterateValues(ctx context.Context, start, end []byte, fn func(key, value []byte) error) error {
+	var lower, upper interface{} = rethink.MinVal, rethink.MaxVal
+	if start != nil {
+		lower = start
+	}
+	if end != nil {
+		upper = end
+	}
+
+	cur, err := a.values.
+		Between(lower, upper).
+		OrderBy(rethink.OrderByOpts{Index: "id"}).
+		Run(a.session)
+	if err != nil {
+		return types.WrapError(err, errorcode.Unavailable, store.Component, "could not iterate values")
+	}
+	defer cur.Close()
+
+	var w valueWrapper
+	for cur.Next(&w) {
+		if err := fn(w.ID, w.Value); err != nil {
+			return err
+		}
+
+		w = valueWrapper{}
+	}
+
+	if err := cur.Err(); err != nil {
+		return types.WrapError(err, errorcode.Unavailable, store.Component, "could not iterate values")
+	}
+
+	return nil
+}
+
 type rethinkBufferedBatch struct {
 	*bufferedbatch.Batch
 }
@@ -597,6 +782,21 @@ func (a *Store) Create() error {
 	if err != nil {
 		return types.WrapError(err, errorcode.Unavailable, store.Component, "could not create tables")
 	} else if exists {
+		// Databases created before the segment order indexes were
+		// introduced don't have them.
+		if err := a.createSegmentOrderIndexes(); err != nil {
+			return types.WrapError(err, errorcode.Unavailable, store.Component, "could not create indexes")
+		}
+
+		// Links created before their creation time was saved get their
+		// update time instead, which is the last time they were written.
+		err := a.links.Filter(rethink.Row.HasFields("createdAt").Not()).Update(func(row rethink.Term) interface{} {
+			return map[string]interface{}{"createdAt": row.Field("updatedAt")}
+		}).Exec(a.session)
+		if err != nil {
+			return types.WrapError(err, errorcode.Unavailable, store.Component, "could not backfill creation times")
+		}
+
 		return nil
 	}
 
@@ -631,6 +831,9 @@ func (a *Store) Create() error {
 		rethink.Row.Field("mapId"),
 	}))
 	exec(a.links.IndexWait("processOrder"))
+	if err == nil {
+		err = a.createSegmentOrderIndexes()
+	}
 
 	exec(a.db.TableCreate("evidences", tblOpts))
 	exec(a.evidences.Wait())
@@ -645,6 +848,43 @@ func (a *Store) Create() error {
 	return nil
 }
 
+// segmentOrderIndexes returns the indexes sorting links like segments: by
+// decreasing priority then by link hash.
+func segmentOrderIndexes() map[string][]interface{} {
+	return map[string][]interface{}{
+		"segmentOrder": {
+			rethink.Row.Field("priority").Mul(-1),
+			rethink.Row.Field("id"),
+		},
+		"mapIdSegmentOrder": {
+			rethink.Row.Field("mapId"),
+			rethink.Row.Field("priority").Mul(-1),
+			rethink.Row.Field("id"),
+		},
+	}
+}
+
+// createSegmentOrderIndexes creates the segment order indexes that don't
+// exist yet.
+func (a *Store) createSegmentOrderIndexes() error {
+	for name, fields := range segmentOrderIndexes() {
+		create := rethink.Branch(
+			a.links.IndexList().Contains(name),
+			nil,
+			a.links.IndexCreateFunc(name, fields),
+		)
+		if err := create.Exec(a.session); err != nil {
+			return err
+		}
+
+		if err := a.links.IndexWait(name).Exec(a.session); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Drop drops the database tables and indexes.
 func (a *Store) Drop() error {
 	var err error
@@ -0,0 +1,462 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package shardedstore implements a store that spreads links over several
+// underlying stores.
+//
+// Links are routed by map ID (or by process name) so a map always lives in a
+// single shard. Reads that can't be routed are sent to all the shards and
+// their results are merged.
+package shardedstore
+
+import (
+	"context"
+	"hash/fnv"
+	"sort"
+	"sync"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-core/bufferedbatch"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+)
+
+const (
+	// Name is the name set in the store's information.
+	Name = "sharded"
+
+	// Description is the description set in the store's information.
+	Description = "Stratumn's Sharded Store"
+)
+
+// Strategy defines how links are routed to the shards.
+type Strategy string
+
+const (
+	// ByMapID routes links by map ID.
+	ByMapID Strategy = "mapID"
+
+	// ByProcess routes links by process name.
+	// All the maps of a process live in the same shard, which is useful when
+	// a few high-volume processes need to be isolated from each other.
+	ByProcess Strategy = "process"
+)
+
+// firstMapIDCursor is the cursor used to read the first map IDs of the
+// shards. Without a cursor, some stores return the most recently updated maps
+// first, which can't be merged. Map IDs can't contain NUL bytes in every store,
+// so this cursor sorts before any map ID.
+const firstMapIDCursor = "\x01"
+
+// Config contains configuration options for the store.
+type Config struct {
+	// Strategy used to route links. Defaults to ByMapID.
+	Strategy Strategy
+}
+
+// Info is the info returned by GetInfo.
+type Info struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Strategy    Strategy      `json:"strategy"`
+	Shards      []interface{} `json:"shards"`
+}
+
+// ShardedStore is the type that implements github.com/stratumn/go-core/store.Adapter.
+//
+// The routing depends on the number and order of the shards: they must not
+// change once links have been written.
+// Options such as store.AdapterConfig.EnforceUniqueMapEntry should be set on
+// the shards directly, since a map never spans several shards.
+type ShardedStore struct {
+	shards []store.Adapter
+	config *Config
+}
+
+// New creates a sharded store.
+func New(shards []store.Adapter, config *Config) (*ShardedStore, error) {
+	c := *config
+	if c.Strategy == "" {
+		c.Strategy = ByMapID
+	}
+
+	switch c.Strategy {
+	case ByMapID, ByProcess:
+	default:
+		return nil, types.NewErrorf(errorcode.InvalidArgument, store.Component, "unknown sharding strategy %q", c.Strategy)
+	}
+
+	if len(shards) == 0 {
+		return nil, types.NewError(errorcode.InvalidArgument, store.Component, "at least one shard is required")
+	}
+
+	return &ShardedStore{shards: shards, config: &c}, nil
+}
+
+// shardIndex returns the index of the shard responsible for a routing key.
+func (a *ShardedStore) shardIndex(key []byte) int {
+	h := fnv.New32a()
+	_, _ = h.Write(key)
+	return int(h.Sum32() % uint32(len(a.shards)))
+}
+
+// routingKey returns the key used to route the given map of a process.
+func (a *ShardedStore) routingKey(process, mapID string) string {
+	if a.config.Strategy == ByProcess {
+		return process
+	}
+
+	return mapID
+}
+
+// shardFor returns the shard responsible for the given map of a process.
+func (a *ShardedStore) shardFor(process, mapID string) store.Adapter {
+	return a.shards[a.shardIndex([]byte(a.routingKey(process, mapID)))]
+}
+
+// shardsFor returns the shards responsible for the given routing keys.
+// If no key is given, all the shards are returned.
+func (a *ShardedStore) shardsFor(keys []string) []store.Adapter {
+	if len(keys) == 0 {
+		return a.shards
+	}
+
+	selected := make(map[int]struct{}, len(keys))
+	for _, key := range keys {
+		selected[a.shardIndex([]byte(key))] = struct{}{}
+	}
+
+	indexes := make([]int, 0, len(selected))
+	for i := range selected {
+		indexes = append(indexes, i)
+	}
+
+	sort.Ints(indexes)
+
+	shards := make([]store.Adapter, len(indexes))
+	for i, index := range indexes {
+		shards[i] = a.shards[index]
+	}
+
+	return shards
+}
+
+// segmentShards returns the shards that can contain segments matching the
+// filter.
+func (a *ShardedStore) segmentShards(filter *store.SegmentFilter) []store.Adapter {
+	if a.config.Strategy == ByProcess {
+		if filter.Process != "" {
+			return a.shardsFor([]string{filter.Process})
+		}
+
+		return a.shardsFor(filter.Processes)
+	}
+
+	return a.shardsFor(filter.MapIDs)
+}
+
+// fanOut calls fn concurrently on each of the given shards and returns the
+// first error.
+func fanOut(shards []store.Adapter, fn func(i int, shard store.Adapter) error) error {
+	errs := make([]error, len(shards))
+
+	var wg sync.WaitGroup
+	wg.Add(len(shards))
+
+	for i, shard := range shards {
+		go func(i int, shard store.Adapter) {
+			defer wg.Done()
+			errs[i] = fn(i, shard)
+		}(i, shard)
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// locate returns the shard containing the given link, or nil if none of them
+// does.
+func (a *ShardedStore) locate(ctx context.Context, linkHash chainscript.LinkHash) (store.Adapter, *chainscript.Segment, error) {
+	segments := make([]*chainscript.Segment, len(a.shards))
+	err := fanOut(a.shards, func(i int, shard store.Adapter) (err error) {
+		segments[i], err = shard.GetSegment(ctx, linkHash)
+		return
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i, segment := range segments {
+		if segment != nil {
+			return a.shards[i], segment, nil
+		}
+	}
+
+	return nil, nil, nil
+}
+
+/********** Store adapter implementation **********/
+
+// GetInfo implements github.com/stratumn/go-core/store.Adapter.GetInfo.
+func (a *ShardedStore) GetInfo(ctx context.Context) (interface{}, error) {
+	shards := make([]interface{}, len(a.shards))
+	err := fanOut(a.shards, func(i int, shard store.Adapter) (err error) {
+		shards[i], err = shard.GetInfo(ctx)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Info{
+		Name:        Name,
+		Description: Description,
+		Strategy:    a.config.Strategy,
+		Shards:      shards,
+	}, nil
+}
+
+// AddStoreEventChannel implements
+// github.com/stratumn/go-core/store.Adapter.AddStoreEventChannel.
+// The channel receives the events of all the shards.
+func (a *ShardedStore) AddStoreEventChannel(eventChan chan *store.Event) {
+	for _, shard := range a.shards {
+		shard.AddStoreEventChannel(eventChan)
+	}
+}
+
+// NewBatch implements github.com/stratumn/go-core/store.Adapter.NewBatch.
+// Links of the batch are routed to their shard when it is written, so a batch
+// isn't atomic across shards.
+func (a *ShardedStore) NewBatch(ctx context.Context) (store.Batch, error) {
+	return bufferedbatch.NewBatch(ctx, a), nil
+}
+
+/********** Store writer implementation **********/
+
+// CreateLink implements github.com/stratumn/go-core/store.LinkWriter.CreateLink.
+func (a *ShardedStore) CreateLink(ctx context.Context, link *chainscript.Link) (chainscript.LinkHash, error) {
+	return a.shardFor(link.Meta.Process.Name, link.Meta.MapId).CreateLink(ctx, link)
+}
+
+// AddEvidence implements github.com/stratumn/go-core/store.EvidenceWriter.AddEvidence.
+// The evidence is added to the shard containing the link. If the link can't
+// be found, the evidence is routed by link hash.
+func (a *ShardedStore) AddEvidence(ctx context.Context, linkHash chainscript.LinkHash, evidence *chainscript.Evidence) error {
+	shard, _, err := a.locate(ctx, linkHash)
+	if err != nil {
+		return err
+	}
+
+	if shard == nil {
+		shard = a.shards[a.shardIndex(linkHash)]
+	}
+
+	return shard.AddEvidence(ctx, linkHash, evidence)
+}
+
+/********** Store reader implementation **********/
+
+// GetSegment implements github.com/stratumn/go-core/store.SegmentReader.GetSegment.
+func (a *ShardedStore) GetSegment(ctx context.Context, linkHash chainscript.LinkHash) (*chainscript.Segment, error) {
+	_, segment, err := a.locate(ctx, linkHash)
+	return segment, err
+}
+
+// FindSegments implements github.com/stratumn/go-core/store.SegmentReader.FindSegments.
+// The shards that may contain matching segments are queried and their pages
+// are merged.
+// With an offset, each shard has to return offset+limit segments: prefer
+// cursors to paginate deep into the results.
+func (a *ShardedStore) FindSegments(ctx context.Context, filter *store.SegmentFilter) (*types.PaginatedSegments, error) {
+	shards := a.segmentShards(filter)
+
+	shardFilter := *filter
+	pagination := store.Pagination{Limit: filter.Limit}
+	if filter.Cursor == "" {
+		shardFilter.Offset = 0
+		shardFilter.Limit = filter.Offset + filter.Limit
+		pagination.Offset = filter.Offset
+	}
+
+	pages := make([]*types.PaginatedSegments, len(shards))
+	err := fanOut(shards, func(i int, shard store.Adapter) (err error) {
+		pages[i], err = shard.FindSegments(ctx, &shardFilter)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	merged := &types.PaginatedSegments{Segments: types.SegmentSlice{}}
+	for _, page := range pages {
+		merged.Segments = append(merged.Segments, page.Segments...)
+		merged.TotalCount += page.TotalCount
+	}
+
+	merged.Segments.Sort(filter.Reverse)
+
+	return pagination.PaginateSegments(merged), nil
+}
+
+// GetMapIDs implements github.com/stratumn/go-core/store.SegmentReader.GetMapIDs.
+// Map IDs are always sorted alphabetically: shards are always given a cursor
+// so that their map IDs can be merged. Like FindSegments, an offset requires
+// each shard to return offset+limit map IDs.
+func (a *ShardedStore) GetMapIDs(ctx context.Context, filter *store.MapFilter) ([]string, error) {
+	shards := a.shards
+	if a.config.Strategy == ByProcess && filter.Process != "" {
+		shards = a.shardsFor([]string{filter.Process})
+	}
+
+	shardFilter := *filter
+	if filter.Cursor == "" {
+		shardFilter.Cursor = firstMapIDCursor
+		shardFilter.Offset = 0
+		shardFilter.Limit = filter.Offset + filter.Limit
+	}
+
+	pages := make([][]string, len(shards))
+	err := fanOut(shards, func(i int, shard store.Adapter) (err error) {
+		pages[i], err = shard.GetMapIDs(ctx, &shardFilter)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// With the process strategy, a map ID can be used by processes living
+	// in different shards.
+	seen := make(map[string]struct{})
+	mapIDs := []string{}
+	for _, page := range pages {
+		for _, mapID := range page {
+			if _, ok := seen[mapID]; !ok {
+				seen[mapID] = struct{}{}
+				mapIDs = append(mapIDs, mapID)
+			}
+		}
+	}
+
+	sort.Strings(mapIDs)
+
+	return filter.Pagination.PaginateStrings(mapIDs), nil
+}
+
+// GetEvidences implements github.com/stratumn/go-core/store.EvidenceReader.GetEvidences.
+func (a *ShardedStore) GetEvidences(ctx context.Context, linkHash chainscript.LinkHash) (types.EvidenceSlice, error) {
+	shardEvidences := make([]types.EvidenceSlice, len(a.shards))
+	err := fanOut(a.shards, func(i int, shard store.Adapter) (err error) {
+		shardEvidences[i], err = shard.GetEvidences(ctx, linkHash)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var evidences types.EvidenceSlice
+	for _, e := range shardEvidences {
+		evidences = append(evidences, e...)
+	}
+
+	return evidences, nil
+}
+
+// IterateSegments implements github.com/stratumn/go-core/store.SegmentIterable.IterateSegments.
+// Segments are fetched page by page from all the shards that may contain
+// matching segments.
+func (a *ShardedStore) IterateSegments(ctx context.Context, filter *store.SegmentFilter) (store.SegmentIterator, error) {
+	if shards := a.segmentShards(filter); len(shards) == 1 {
+		return store.IterateSegments(ctx, shards[0], filter)
+	}
+
+	return store.NewPaginatedSegmentIterator(ctx, a, filter), nil
+}
+
+// GetAncestors implements github.com/stratumn/go-core/store.GraphReader.GetAncestors.
+// The graph of a map lives in a single shard.
+func (a *ShardedStore) GetAncestors(ctx context.Context, linkHash chainscript.LinkHash, depth int) (types.SegmentSlice, error) {
+	shard, _, err := a.locate(ctx, linkHash)
+	if err != nil || shard == nil {
+		return nil, err
+	}
+
+	return store.GetAncestors(ctx, shard, linkHash, depth)
+}
+
+// GetDescendants implements github.com/stratumn/go-core/store.GraphReader.GetDescendants.
+// The graph of a map lives in a single shard.
+func (a *ShardedStore) GetDescendants(ctx context.Context, linkHash chainscript.LinkHash, depth int) (types.SegmentSlice, error) {
+	shard, _, err := a.locate(ctx, linkHash)
+	if err != nil || shard == nil {
+		return nil, err
+	}
+
+	return store.GetDescendants(ctx, shard, linkHash, depth)
+}
+
+// GetMapHeads implements github.com/stratumn/go-core/store.GraphReader.GetMapHeads.
+func (a *ShardedStore) GetMapHeads(ctx context.Context, process, mapID string) (types.SegmentSlice, error) {
+	return store.GetMapHeads(ctx, a.shardFor(process, mapID), process, mapID)
+}
+
+/********** github.com/stratumn/go-core/store.KeyValueStore implementation **********/
+
+// keyValueShard returns the shard responsible for a key.
+func (a *ShardedStore) keyValueShard(key []byte) (store.KeyValueStore, error) {
+	kv, ok := a.shards[a.shardIndex(key)].(store.KeyValueStore)
+	if !ok {
+		return nil, types.NewError(errorcode.Unimplemented, store.Component, "shard is not a key-value store")
+	}
+
+	return kv, nil
+}
+
+// GetValue implements github.com/stratumn/go-core/store.KeyValueStore.GetValue.
+func (a *ShardedStore) GetValue(ctx context.Context, key []byte) ([]byte, error) {
+	kv, err := a.keyValueShard(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return kv.GetValue(ctx, key)
+}
+
+// SetValue implements github.com/stratumn/go-core/store.KeyValueStore.SetValue.
+func (a *ShardedStore) SetValue(ctx context.Context, key, value []byte) error {
+	kv, err := a.keyValueShard(key)
+	if err != nil {
+		return err
+	}
+
+	return kv.SetValue(ctx, key, value)
+}
+
+// DeleteValue implements github.com/stratumn/go-core/store.KeyValueStore.DeleteValue.
+func (a *ShardedStore) DeleteValue(ctx context.Context, key []byte) ([]byte, error) {
+	kv, err := a.keyValueShard(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return kv.DeleteValue(ctx, key)
+}
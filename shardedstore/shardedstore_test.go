@@ -0,0 +1,341 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shardedstore
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-chainscript/chainscripttest"
+	"github.com/stratumn/go-core/dummystore"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/store/storetestcases"
+	"github.com/stratumn/go-core/validation/validators"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newShards(n int) []store.Adapter {
+	shards := make([]store.Adapter, n)
+	for i := range shards {
+		shards[i] = dummystore.New(&dummystore.Config{})
+	}
+
+	return shards
+}
+
+func newStore(t *testing.T, strategy Strategy, shards []store.Adapter) *ShardedStore {
+	a, err := New(shards, &Config{Strategy: strategy})
+	require.NoError(t, err)
+	return a
+}
+
+func TestShardedStore(t *testing.T) {
+	for _, strategy := range []Strategy{ByMapID, ByProcess} {
+		strategy := strategy
+		t.Run(string(strategy), func(t *testing.T) {
+			factory := storetestcases.Factory{
+				New: func() (store.Adapter, error) {
+					return New(newShards(3), &Config{Strategy: strategy})
+				},
+				NewKeyValueStore: func() (store.KeyValueStore, error) {
+					return New(newShards(3), &Config{Strategy: strategy})
+				},
+			}
+
+			factory.RunStoreTests(t)
+			factory.RunKeyValueStoreTests(t)
+		})
+	}
+}
+
+func TestNew_invalidConfig(t *testing.T) {
+	_, err := New(nil, &Config{})
+	assert.Error(t, err, "no shards")
+
+	_, err = New(newShards(2), &Config{Strategy: "random"})
+	assert.Error(t, err, "unknown strategy")
+}
+
+func TestShardedStore_routing(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("by map ID", func(t *testing.T) {
+		shards := newShards(3)
+		a := newStore(t, ByMapID, shards)
+
+		for i := 0; i < 30; i++ {
+			mapID := fmt.Sprintf("map%d", i)
+			for j := 0; j < 3; j++ {
+				_, err := a.CreateLink(ctx, chainscripttest.NewLinkBuilder(t).WithRandomData().WithMapID(mapID).Build())
+				require.NoError(t, err)
+			}
+		}
+
+		for i, shard := range shards {
+			segments, err := shard.FindSegments(ctx, &store.SegmentFilter{Pagination: store.Pagination{Limit: store.MaxLimit}})
+			require.NoError(t, err)
+			assert.NotZero(t, segments.TotalCount, "shard %d is empty", i)
+
+			// A map must be stored in a single shard.
+			for _, s := range segments.Segments {
+				inMap, err := shard.FindSegments(ctx, &store.SegmentFilter{
+					Pagination: store.Pagination{Limit: store.MaxLimit},
+					MapIDs:     []string{s.Link.Meta.MapId},
+				})
+				require.NoError(t, err)
+				assert.Equal(t, 3, inMap.TotalCount, "map %s is split", s.Link.Meta.MapId)
+			}
+		}
+	})
+
+	t.Run("by process", func(t *testing.T) {
+		shards := newShards(3)
+		a := newStore(t, ByProcess, shards)
+
+		for i := 0; i < 10; i++ {
+			_, err := a.CreateLink(ctx, chainscripttest.NewLinkBuilder(t).WithRandomData().WithProcess("p").Build())
+			require.NoError(t, err)
+		}
+
+		counts := 0
+		for _, shard := range shards {
+			segments, err := shard.FindSegments(ctx, &store.SegmentFilter{Pagination: store.Pagination{Limit: store.MaxLimit}})
+			require.NoError(t, err)
+			if segments.TotalCount > 0 {
+				counts++
+				assert.Equal(t, 10, segments.TotalCount)
+			}
+		}
+
+		assert.Equal(t, 1, counts, "process should live in a single shard")
+	})
+}
+
+func TestShardedStore_pagination(t *testing.T) {
+	ctx := context.Background()
+	a := newStore(t, ByMapID, newShards(3))
+	reference := dummystore.New(&dummystore.Config{})
+
+	for i := 0; i < 40; i++ {
+		l := chainscripttest.NewLinkBuilder(t).
+			WithRandomData().
+			WithMapID(fmt.Sprintf("map%d", i%13)).
+			WithPriority(float64(i % 4)).
+			Build()
+
+		_, err := a.CreateLink(ctx, l)
+		require.NoError(t, err)
+		_, err = reference.CreateLink(ctx, l)
+		require.NoError(t, err)
+	}
+
+	for _, reverse := range []bool{false, true} {
+		t.Run(fmt.Sprintf("offset reverse=%t", reverse), func(t *testing.T) {
+			for offset := 0; offset < 45; offset += 7 {
+				filter := &store.SegmentFilter{
+					Pagination: store.Pagination{Offset: offset, Limit: 7},
+					Reverse:    reverse,
+				}
+
+				got, err := a.FindSegments(ctx, filter)
+				require.NoError(t, err)
+				want, err := reference.FindSegments(ctx, filter)
+				require.NoError(t, err)
+
+				assert.Equal(t, want.TotalCount, got.TotalCount)
+				assertSameSegments(t, want.Segments, got.Segments)
+			}
+		})
+
+		t.Run(fmt.Sprintf("cursor reverse=%t", reverse), func(t *testing.T) {
+			filter := &store.SegmentFilter{
+				Pagination: store.Pagination{Limit: 6},
+				Reverse:    reverse,
+			}
+
+			var all []*chainscript.Segment
+			for {
+				page, err := a.FindSegments(ctx, filter)
+				require.NoError(t, err)
+				all = append(all, page.Segments...)
+
+				if page.NextCursor == "" {
+					break
+				}
+
+				filter.Cursor = page.NextCursor
+			}
+
+			want, err := reference.FindSegments(ctx, &store.SegmentFilter{
+				Pagination: store.Pagination{Limit: store.MaxLimit},
+				Reverse:    reverse,
+			})
+			require.NoError(t, err)
+			assertSameSegments(t, want.Segments, all)
+		})
+	}
+
+	t.Run("map IDs", func(t *testing.T) {
+		for offset := 0; offset < 15; offset += 4 {
+			filter := &store.MapFilter{Pagination: store.Pagination{Offset: offset, Limit: 4}}
+
+			got, err := a.GetMapIDs(ctx, filter)
+			require.NoError(t, err)
+			want, err := reference.GetMapIDs(ctx, filter)
+			require.NoError(t, err)
+			assert.Equal(t, want, got)
+
+			if len(want) > 0 {
+				filter.Cursor = want[len(want)-1]
+
+				got, err = a.GetMapIDs(ctx, filter)
+				require.NoError(t, err)
+				want, err = reference.GetMapIDs(ctx, filter)
+				require.NoError(t, err)
+				assert.Equal(t, want, got)
+			}
+		}
+	})
+}
+
+func TestShardedStore_mapIDsAcrossShards(t *testing.T) {
+	ctx := context.Background()
+	a := newStore(t, ByProcess, newShards(4))
+
+	for _, process := range []string{"p1", "p2", "p3", "p4", "p5"} {
+		_, err := a.CreateLink(ctx, chainscripttest.NewLinkBuilder(t).WithProcess(process).WithMapID("shared").Build())
+		require.NoError(t, err)
+	}
+
+	mapIDs, err := a.GetMapIDs(ctx, &store.MapFilter{Pagination: store.Pagination{Limit: 10}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"shared"}, mapIDs)
+
+	mapIDs, err = a.GetMapIDs(ctx, &store.MapFilter{Pagination: store.Pagination{Limit: 10}, Process: "p3"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"shared"}, mapIDs)
+}
+
+// recentMapsStore returns the most recently updated maps first when there is
+// no cursor, like the postgres store.
+type recentMapsStore struct {
+	store.Adapter
+	mapIDs []string
+}
+
+func (a *recentMapsStore) CreateLink(ctx context.Context, link *chainscript.Link) (chainscript.LinkHash, error) {
+	a.mapIDs = append([]string{link.Meta.MapId}, a.mapIDs...)
+	return a.Adapter.CreateLink(ctx, link)
+}
+
+func (a *recentMapsStore) GetMapIDs(ctx context.Context, filter *store.MapFilter) ([]string, error) {
+	if filter.Cursor != "" {
+		return a.Adapter.GetMapIDs(ctx, filter)
+	}
+
+	return filter.Pagination.PaginateStrings(a.mapIDs), nil
+}
+
+func TestShardedStore_mapIDsRecentFirst(t *testing.T) {
+	ctx := context.Background()
+	shards := []store.Adapter{
+		&recentMapsStore{Adapter: dummystore.New(&dummystore.Config{})},
+		&recentMapsStore{Adapter: dummystore.New(&dummystore.Config{})},
+	}
+	a := newStore(t, ByMapID, shards)
+
+	var mapIDs []string
+	for i := 0; i < 10; i++ {
+		mapID := fmt.Sprintf("map%d", i)
+		mapIDs = append(mapIDs, mapID)
+
+		_, err := a.CreateLink(ctx, chainscripttest.NewLinkBuilder(t).WithMapID(mapID).Build())
+		require.NoError(t, err)
+	}
+
+	got, err := a.GetMapIDs(ctx, &store.MapFilter{Pagination: store.Pagination{Limit: 3}})
+	require.NoError(t, err)
+	assert.Equal(t, mapIDs[:3], got)
+
+	got, err = a.GetMapIDs(ctx, &store.MapFilter{Pagination: store.Pagination{Offset: 3, Limit: 3}})
+	require.NoError(t, err)
+	assert.Equal(t, mapIDs[3:6], got)
+}
+
+func TestShardedStore_crossShardReferences(t *testing.T) {
+	ctx := context.Background()
+	a := newStore(t, ByMapID, newShards(3))
+
+	// Find two maps routed to different shards.
+	other := "other0"
+	for i := 1; a.shardIndex([]byte(other)) == a.shardIndex([]byte("map")); i++ {
+		other = fmt.Sprintf("other%d", i)
+	}
+
+	ref := chainscripttest.NewLinkBuilder(t).WithProcess("p").WithMapID(other).Build()
+	refHash, err := a.CreateLink(ctx, ref)
+	require.NoError(t, err)
+
+	parent := chainscripttest.NewLinkBuilder(t).WithProcess("p").WithMapID("map").WithDegree(1).Build()
+	_, err = a.CreateLink(ctx, parent)
+	require.NoError(t, err)
+
+	child := chainscripttest.NewLinkBuilder(t).
+		WithProcess("p").
+		WithMapID("map").
+		WithParent(t, parent).
+		WithRef(t, ref).
+		Build()
+
+	v := validators.NewRefsValidator()
+	require.NoError(t, v.Validate(ctx, a, child))
+
+	_, err = a.CreateLink(ctx, child)
+	require.NoError(t, err)
+
+	// The parent's out degree is reached.
+	sibling := chainscripttest.NewLinkBuilder(t).
+		WithProcess("p").
+		WithMapID("map").
+		WithParent(t, parent).
+		Build()
+	assert.Error(t, v.Validate(ctx, a, sibling))
+
+	// Evidences are added to the shard containing the link.
+	require.NoError(t, a.AddEvidence(ctx, refHash, chainscripttest.RandomEvidence(t)))
+
+	s, err := a.GetSegment(ctx, refHash)
+	require.NoError(t, err)
+	require.NotNil(t, s)
+	assert.Len(t, s.Meta.Evidences, 1)
+
+	referencing, err := a.FindSegments(ctx, &store.SegmentFilter{
+		Pagination:  store.Pagination{Limit: 10},
+		Referencing: refHash,
+	})
+	require.NoError(t, err)
+	require.Len(t, referencing.Segments, 1)
+	chainscripttest.LinksEqual(t, child, referencing.Segments[0].Link)
+}
+
+func assertSameSegments(t *testing.T, want, got []*chainscript.Segment) {
+	require.Len(t, got, len(want))
+	for i := range want {
+		assert.Equal(t, want[i].LinkHash(), got[i].LinkHash(), "segment %d", i)
+	}
+}
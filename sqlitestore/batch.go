@@ -0,0 +1,212 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-core/monitoring"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+)
+
+// Batch is the type that implements github.com/stratumn/go-core/store.Batch.
+//
+// SQLite has a single writer, so a batch can't keep a transaction open
+// between calls without blocking every other write (or forever if the batch
+// is never written). Instead, each call runs in a short transaction where the
+// links of the batch are created again, which lets the batch read its own
+// links and check them exactly like the store does. The transaction is only
+// committed by Write, so either all the links are written or none of them.
+type Batch struct {
+	lock sync.Mutex
+	done bool
+	err  error
+
+	db                    *sql.DB
+	enforceUniqueMapEntry bool
+	links                 []*chainscript.Link
+}
+
+func newBatch(db *sql.DB, enforceUniqueMapEntry bool) *Batch {
+	return &Batch{
+		db:                    db,
+		enforceUniqueMapEntry: enforceUniqueMapEntry,
+	}
+}
+
+// inTx runs fn in a transaction where the links of the batch have been
+// created. The transaction is committed if commit is true and rolled back
+// otherwise.
+// The batch lock must be held.
+func (b *Batch) inTx(ctx context.Context, commit bool, fn func(*scopedStore) error) error {
+	// The transaction must outlive the request's context.
+	tx, err := b.db.Begin()
+	if err != nil {
+		return types.WrapError(err, errorcode.Unavailable, store.Component, "could not create batch tx")
+	}
+
+	s := newScopedStore(tx, newSingletonTxFactory(tx), b.enforceUniqueMapEntry)
+
+	for _, link := range b.links {
+		if _, err := s.CreateLink(ctx, link); err != nil {
+			rollback(ctx, tx)
+			return err
+		}
+	}
+
+	if err := fn(s); err != nil {
+		rollback(ctx, tx)
+		return err
+	}
+
+	if !commit {
+		rollback(ctx, tx)
+		return nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return types.WrapError(err, errorcode.Internal, store.Component, "could not commit batch tx")
+	}
+
+	return nil
+}
+
+// read runs fn in a transaction where the links of the batch have been
+// created, or directly on the database if the batch is empty.
+func (b *Batch) read(ctx context.Context, fn func(*scopedStore) error) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if len(b.links) == 0 {
+		return fn(newScopedStore(b.db, newStandardTxFactory(b.db), b.enforceUniqueMapEntry))
+	}
+
+	return b.inTx(ctx, false, fn)
+}
+
+// CreateLink implements github.com/stratumn/go-core/store.LinkWriter.CreateLink.
+// A link that can't be created fails the whole batch.
+func (b *Batch) CreateLink(ctx context.Context, link *chainscript.Link) (chainscript.LinkHash, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.done {
+		return nil, store.ErrBatchFailed
+	}
+
+	var lh chainscript.LinkHash
+	err := b.inTx(ctx, false, func(s *scopedStore) (err error) {
+		lh, err = s.CreateLink(ctx, link)
+		return err
+	})
+	if err != nil {
+		b.done = true
+		b.err = err
+		return lh, err
+	}
+
+	b.links = append(b.links, link)
+
+	return lh, nil
+}
+
+// GetSegment implements github.com/stratumn/go-core/store.SegmentReader.GetSegment.
+func (b *Batch) GetSegment(ctx context.Context, linkHash chainscript.LinkHash) (segment *chainscript.Segment, err error) {
+	err = b.read(ctx, func(s *scopedStore) error {
+		segment, err = s.GetSegment(ctx, linkHash)
+		return err
+	})
+
+	return segment, err
+}
+
+// FindSegments implements github.com/stratumn/go-core/store.SegmentReader.FindSegments.
+func (b *Batch) FindSegments(ctx context.Context, filter *store.SegmentFilter) (segments *types.PaginatedSegments, err error) {
+	err = b.read(ctx, func(s *scopedStore) error {
+		segments, err = s.FindSegments(ctx, filter)
+		return err
+	})
+
+	return segments, err
+}
+
+// GetMapIDs implements github.com/stratumn/go-core/store.SegmentReader.GetMapIDs.
+func (b *Batch) GetMapIDs(ctx context.Context, filter *store.MapFilter) (mapIDs []string, err error) {
+	err = b.read(ctx, func(s *scopedStore) error {
+		mapIDs, err = s.GetMapIDs(ctx, filter)
+		return err
+	})
+
+	return mapIDs, err
+}
+
+// Write implements github.com/stratumn/go-core/store.Batch.Write.
+// The links are created again in a single transaction, so the batch fails if
+// a concurrent write made one of them invalid.
+func (b *Batch) Write(ctx context.Context) (err error) {
+	span, _ := monitoring.StartSpanOutgoingRequest(ctx, "sqlitestore/batch/Write")
+	defer func() {
+		monitoring.SetSpanStatusAndEnd(span, err)
+	}()
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.done {
+		if b.err != nil {
+			return b.err
+		}
+
+		return store.ErrBatchFailed
+	}
+
+	b.done = true
+
+	if len(b.links) == 0 {
+		return nil
+	}
+
+	return b.inTx(ctx, true, func(*scopedStore) error { return nil })
+}
+
+// isDone returns whether the batch has been written, has failed or has been
+// aborted.
+func (b *Batch) isDone() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.done
+}
+
+// abort prevents the batch from being used if it hasn't been written yet.
+func (b *Batch) abort() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.done = true
+}
+
+func rollback(ctx context.Context, tx *sql.Tx) {
+	if err := tx.Rollback(); err != nil {
+		monitoring.TxLogEntry(ctx).
+			WithError(err).
+			Warn("Error during transaction rollback")
+	}
+}
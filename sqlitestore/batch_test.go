@@ -0,0 +1,129 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlitestore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-chainscript/chainscripttest"
+	"github.com/stratumn/go-core/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatch_Write(t *testing.T) {
+	ctx := context.Background()
+	a, err := createStore()
+	require.NoError(t, err)
+	defer freeStore(a)
+
+	t.Run("failed link rolls back the batch", func(t *testing.T) {
+		b, err := a.NewBatch(ctx)
+		require.NoError(t, err)
+
+		lh, err := b.CreateLink(ctx, chainscripttest.RandomLink(t))
+		require.NoError(t, err)
+
+		orphan := chainscripttest.NewLinkBuilder(t).
+			WithRandomData().
+			WithParent(t, chainscripttest.RandomLink(t)).
+			Build()
+		_, err = b.CreateLink(ctx, orphan)
+		require.Error(t, err)
+
+		assert.Error(t, b.Write(ctx))
+
+		found, err := a.GetSegment(ctx, lh)
+		require.NoError(t, err)
+		assert.Nil(t, found)
+	})
+
+	t.Run("out degree is checked in the batch", func(t *testing.T) {
+		parent := chainscripttest.NewLinkBuilder(t).
+			WithRandomData().
+			WithDegree(1).
+			Build()
+		_, err := a.CreateLink(ctx, parent)
+		require.NoError(t, err)
+
+		b, err := a.NewBatch(ctx)
+		require.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			child := chainscripttest.NewLinkBuilder(t).
+				WithRandomData().
+				WithParent(t, parent).
+				WithProcess(parent.Meta.Process.Name).
+				WithMapID(parent.Meta.MapId).
+				Build()
+			_, err = b.CreateLink(ctx, child)
+		}
+
+		testutil.AssertWrappedErrorEqual(t, err, chainscript.ErrOutDegree)
+	})
+
+	t.Run("concurrent batches are independent", func(t *testing.T) {
+		b1, err := a.NewBatch(ctx)
+		require.NoError(t, err)
+
+		lh1, err := b1.CreateLink(ctx, chainscripttest.RandomLink(t))
+		require.NoError(t, err)
+
+		b2, err := a.NewBatch(ctx)
+		require.NoError(t, err)
+
+		done := make(chan error, 1)
+		var lh2 chainscript.LinkHash
+		go func() {
+			var err error
+			if lh2, err = b2.CreateLink(ctx, chainscripttest.RandomLink(t)); err != nil {
+				done <- err
+				return
+			}
+
+			done <- b2.Write(ctx)
+		}()
+
+		require.NoError(t, b1.Write(ctx))
+		require.NoError(t, <-done)
+
+		for _, lh := range []chainscript.LinkHash{lh1, lh2} {
+			found, err := a.GetSegment(ctx, lh)
+			require.NoError(t, err)
+			assert.NotNil(t, found)
+		}
+	})
+
+	t.Run("unwritten batch doesn't block writes", func(t *testing.T) {
+		b, err := a.NewBatch(ctx)
+		require.NoError(t, err)
+
+		lh, err := b.CreateLink(ctx, chainscripttest.RandomLink(t))
+		require.NoError(t, err)
+
+		found, err := b.GetSegment(ctx, lh)
+		require.NoError(t, err)
+		assert.NotNil(t, found, "batch should read its own links")
+
+		_, err = a.CreateLink(ctx, chainscripttest.RandomLink(t))
+		require.NoError(t, err)
+
+		found, err = a.GetSegment(ctx, lh)
+		require.NoError(t, err)
+		assert.Nil(t, found)
+	})
+}
@@ -0,0 +1,85 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlitestore
+
+import (
+	"flag"
+	"os"
+	"time"
+
+	"github.com/stratumn/go-core/monitoring"
+	"github.com/stratumn/go-core/store"
+)
+
+var (
+	create         bool
+	drop           bool
+	uniqueMapEntry bool
+	path           string
+	busyTimeout    time.Duration
+)
+
+// Initialize a sqlite store adapter.
+func Initialize(config *Config, create, drop, uniqueMapEntry bool) *Store {
+	a, err := New(config)
+	if err != nil {
+		monitoring.LogEntry().WithField("error", err).Fatal("Failed to create SQLite store")
+	}
+
+	if drop {
+		if err := a.Drop(); err != nil {
+			monitoring.LogEntry().WithField("error", err).Fatal("Failed to drop SQLite tables and indexes.")
+		}
+
+		monitoring.LogEntry().Info("Dropped tables and indexes.")
+		os.Exit(0)
+	}
+
+	// Ensure the DB tables are created.
+	if err := a.Create(); err != nil {
+		monitoring.LogEntry().WithField("error", err).Fatal("Failed to create SQLite tables and indexes.")
+	}
+
+	monitoring.LogEntry().Info("Created tables and indexes.")
+
+	if create {
+		os.Exit(0)
+	}
+
+	if uniqueMapEntry {
+		err = store.AdapterConfig(a).EnforceUniqueMapEntry()
+		if err != nil {
+			monitoring.LogEntry().WithField("uniqueMapEntry", err.Error()).Fatal("Unable to configure unique map entry.")
+		}
+	}
+
+	return a
+}
+
+// RegisterFlags registers the flags used by InitializeWithFlags.
+func RegisterFlags() {
+	flag.BoolVar(&create, "create", false, "create tables and indexes then exit")
+	flag.BoolVar(&drop, "drop", false, "drop tables and indexes then exit")
+	flag.BoolVar(&uniqueMapEntry, "uniquemapentry", false, "enforce unicity of the first link in each process map")
+	flag.StringVar(&path, "path", DefaultPath, "path of the SQLite database file")
+	flag.DurationVar(&busyTimeout, "busytimeout", DefaultBusyTimeout, "time a write waits for the database to be unlocked")
+}
+
+// InitializeWithFlags should be called after RegisterFlags and flag.Parse to initialize
+// a sqlite adapter using flag values.
+func InitializeWithFlags(version, commit string) *Store {
+	config := &Config{Path: path, BusyTimeout: busyTimeout, Version: version, Commit: commit}
+	return Initialize(config, create, drop, uniqueMapEntry)
+}
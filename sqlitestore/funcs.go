@@ -0,0 +1,55 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlitestore
+
+import (
+	"encoding/json"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stratumn/go-core/store"
+)
+
+// registerFuncs registers the SQL functions used by the store's queries on a
+// new connection.
+func registerFuncs(conn *sqlite3.SQLiteConn) error {
+	return conn.RegisterFunc("data_match", dataMatch, true)
+}
+
+// dataMatch checks if the JSON data of a link matches a JSON-encoded
+// github.com/stratumn/go-core/store.DataPredicate.
+// Links without JSON data don't match any predicate.
+func dataMatch(linkData interface{}, predicate string) bool {
+	var js []byte
+	switch d := linkData.(type) {
+	case string:
+		js = []byte(d)
+	case []byte:
+		js = d
+	default:
+		return false
+	}
+
+	var p store.DataPredicate
+	if err := json.Unmarshal([]byte(predicate), &p); err != nil {
+		return false
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(js, &data); err != nil {
+		return false
+	}
+
+	return p.Match(data)
+}
@@ -0,0 +1,54 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlitestore
+
+import (
+	"context"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+)
+
+// GetAncestors implements github.com/stratumn/go-core/store.GraphReader.GetAncestors.
+func (s *scopedStore) GetAncestors(ctx context.Context, linkHash chainscript.LinkHash, depth int) (types.SegmentSlice, error) {
+	return s.getGraphSegments(ctx, SQLGetAncestors, "could not get ancestors", []byte(linkHash), depth)
+}
+
+// GetDescendants implements github.com/stratumn/go-core/store.GraphReader.GetDescendants.
+func (s *scopedStore) GetDescendants(ctx context.Context, linkHash chainscript.LinkHash, depth int) (types.SegmentSlice, error) {
+	return s.getGraphSegments(ctx, SQLGetDescendants, "could not get descendants", []byte(linkHash), depth)
+}
+
+// GetMapHeads implements github.com/stratumn/go-core/store.GraphReader.GetMapHeads.
+func (s *scopedStore) GetMapHeads(ctx context.Context, process, mapID string) (types.SegmentSlice, error) {
+	return s.getGraphSegments(ctx, SQLGetMapHeads, "could not get map heads", process, mapID)
+}
+
+func (s *scopedStore) getGraphSegments(ctx context.Context, query string, errMsg string, args ...interface{}) (types.SegmentSlice, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, types.WrapError(err, errorcode.Unavailable, store.Component, errMsg)
+	}
+
+	defer rows.Close()
+	var segments types.SegmentSlice
+	if err = scanLinkAndEvidences(rows, &segments, nil); err != nil {
+		return nil, err
+	}
+
+	return segments, nil
+}
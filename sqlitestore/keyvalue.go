@@ -0,0 +1,172 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+)
+
+// valuesChunkSize is the number of key-value pairs read at once when
+// iterating over a range of keys.
+const valuesChunkSize = 256
+
+// GetValue implements github.com/stratumn/go-core/store.KeyValueStore.GetValue.
+func (s *scopedStore) GetValue(ctx context.Context, key []byte) ([]byte, error) {
+	var data []byte
+
+	if err := s.db.QueryRowContext(ctx, SQLGetValue, key).Scan(&data); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, types.WrapError(err, errorcode.Internal, store.Component, "could not get value")
+	}
+
+	return data, nil
+}
+
+// SetValue implements github.com/stratumn/go-core/store.KeyValueStore.SetValue.
+func (s *scopedStore) SetValue(ctx context.Context, key []byte, value []byte) error {
+	if value == nil {
+		value = []byte{}
+	}
+
+	_, err := s.db.ExecContext(ctx, SQLSaveValue, key, value)
+	if err != nil {
+		return types.WrapError(err, errorcode.Unavailable, store.Component, "could not set value")
+	}
+
+	return nil
+}
+
+// DeleteValue implements github.com/stratumn/go-core/store.KeyValueStore.DeleteValue.
+// The value is read and deleted in a transaction.
+func (s *scopedStore) DeleteValue(ctx context.Context, key []byte) ([]byte, error) {
+	tx, err := s.txFactory.NewTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	if err = tx.QueryRowContext(ctx, SQLGetValue, key).Scan(&data); err != nil {
+		s.txFactory.RollbackTx(tx, err)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, types.WrapError(err, errorcode.Internal, store.Component, "could not delete value")
+	}
+
+	if _, err = tx.ExecContext(ctx, SQLDeleteValue, key); err != nil {
+		s.txFactory.RollbackTx(tx, err)
+		return nil, types.WrapError(err, errorcode.Internal, store.Component, "could not delete value")
+	}
+
+	return data, s.txFactory.CommitTx(tx)
+}
+
+// IterateValues implements github.com/stratumn/go-core/store.KeyValueIterator.IterateValues.
+// Pairs are read in chunks, and fn is only called once a chunk has been read.
+func (s *scopedStore) IterateValues(ctx context.Context, start, end []byte, fn func(key, value []byte) error) error {
+	for {
+		keys, values, err := s.getValues(ctx, start, end)
+		if err != nil {
+			return err
+		}
+
+		for i, key := range keys {
+			if err := fn(key, values[i]); err != nil {
+				return err
+			}
+		}
+
+		if len(keys) < valuesChunkSize {
+			return nil
+		}
+
+		// The smallest key after the last one read.
+		last := keys[len(keys)-1]
+		start = append(append(make([]byte, 0, len(last)+1), last...), 0)
+	}
+}
+
+func (s *scopedStore) getValues(ctx context.Context, start, end []byte) ([][]byte, [][]byte, error) {
+	if start == nil {
+		start = []byte{}
+	}
+
+	var rows *sql.Rows
+	var err error
+	if end == nil {
+		rows, err = s.db.QueryContext(ctx, SQLIterateValuesFrom, start, valuesChunkSize)
+	} else {
+		rows, err = s.db.QueryContext(ctx, SQLIterateValues, start, end, valuesChunkSize)
+	}
+	if err != nil {
+		return nil, nil, types.WrapError(err, errorcode.Unavailable, store.Component, "could not iterate values")
+	}
+
+	defer rows.Close()
+
+	var keys, values [][]byte
+	for rows.Next() {
+		var key, value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, nil, types.WrapError(err, errorcode.Internal, store.Component, "could not iterate values")
+		}
+
+		keys = append(keys, key)
+		values = append(values, value)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, types.WrapError(err, errorcode.Internal, store.Component, "could not iterate values")
+	}
+
+	return keys, values, nil
+}
+
+// WriteValues implements github.com/stratumn/go-core/store.KeyValueBatch.WriteValues.
+// The writes are applied in a transaction.
+func (s *scopedStore) WriteValues(ctx context.Context, writes []store.KeyValueWrite) error {
+	tx, err := s.txFactory.NewTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, w := range writes {
+		if w.Delete {
+			_, err = tx.ExecContext(ctx, SQLDeleteValue, w.Key)
+		} else {
+			value := w.Value
+			if value == nil {
+				value = []byte{}
+			}
+
+			_, err = tx.ExecContext(ctx, SQLSaveValue, w.Key, value)
+		}
+		if err != nil {
+			s.txFactory.RollbackTx(tx, err)
+			return types.WrapError(err, errorcode.Unavailable, store.Component, "could not write values")
+		}
+	}
+
+	return s.txFactory.CommitTx(tx)
+}
@@ -0,0 +1,409 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlitestore
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+	"github.com/stratumn/go-core/validation/validators"
+)
+
+// CreateLink implements github.com/stratumn/go-core/store.Adapter.CreateLink.
+// The link is inserted and its parent's degree is updated in a transaction.
+func (s *scopedStore) CreateLink(ctx context.Context, link *chainscript.Link) (chainscript.LinkHash, error) {
+	linkHash, err := link.Hash()
+	if err != nil {
+		return linkHash, types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not hash link")
+	}
+
+	data, err := json.Marshal(link)
+	if err != nil {
+		return linkHash, types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not marshal link")
+	}
+
+	tx, err := s.txFactory.NewTx(ctx)
+	if err != nil {
+		return linkHash, err
+	}
+
+	if len(link.PrevLinkHash()) > 0 {
+		if err = s.addChild(ctx, tx, link.PrevLinkHash()); err != nil {
+			s.txFactory.RollbackTx(tx, err)
+			return linkHash, err
+		}
+	}
+
+	if err = s.createLinkInTx(ctx, tx, linkHash, data, link); err != nil {
+		s.txFactory.RollbackTx(tx, err)
+		return linkHash, err
+	}
+
+	return linkHash, s.txFactory.CommitTx(tx)
+}
+
+// addChild checks that the given parent exists and accepts one more child,
+// then increments its current degree.
+func (s *scopedStore) addChild(ctx context.Context, tx *sql.Tx, parentHash chainscript.LinkHash) error {
+	var parentData string
+	err := tx.QueryRowContext(ctx, SQLGetLink, []byte(parentHash)).Scan(&parentData)
+	if err == sql.ErrNoRows {
+		return validators.ErrParentNotFound
+	}
+	if err != nil {
+		return types.WrapError(err, errorcode.Internal, store.Component, "could not get parent")
+	}
+
+	parent, err := newSegment(parentData)
+	if err != nil {
+		return err
+	}
+
+	parentDegree := parent.Link.Meta.OutDegree
+	if parentDegree < 0 {
+		return nil
+	}
+
+	currentDegree := 0
+	err = tx.QueryRowContext(ctx, SQLGetLinkDegree, []byte(parentHash)).Scan(&currentDegree)
+	if err != nil && err != sql.ErrNoRows {
+		return types.WrapError(err, errorcode.Internal, store.Component, "could not get link degree")
+	}
+
+	if int(parentDegree) <= currentDegree {
+		return types.WrapError(chainscript.ErrOutDegree, errorcode.FailedPrecondition, store.Component, "could not create link")
+	}
+
+	_, err = tx.ExecContext(ctx, SQLIncrementLinkDegree, []byte(parentHash))
+	if err != nil {
+		return types.WrapError(err, errorcode.Internal, store.Component, "could not increment link degree")
+	}
+
+	return nil
+}
+
+// createLinkInTx inserts the given link in a transaction context.
+// If the link already exists it will return an error.
+func (s *scopedStore) createLinkInTx(
+	ctx context.Context,
+	tx *sql.Tx,
+	linkHash chainscript.LinkHash,
+	data []byte,
+	link *chainscript.Link,
+) error {
+	var prevLinkHash interface{}
+	if len(link.PrevLinkHash()) > 0 {
+		prevLinkHash = []byte(link.PrevLinkHash())
+	}
+
+	// Data that isn't valid JSON can't be filtered on.
+	var linkData interface{}
+	if json.Valid(link.Data) {
+		linkData = string(link.Data)
+	}
+
+	res, err := tx.ExecContext(
+		ctx,
+		SQLCreateLink,
+		[]byte(linkHash),
+		link.Meta.Priority,
+		link.Meta.MapId,
+		prevLinkHash,
+		string(data),
+		link.Meta.Process.Name,
+		link.Meta.Step,
+		linkData,
+		time.Now().UnixNano(),
+	)
+	if err != nil {
+		return types.WrapError(err, errorcode.Internal, store.Component, "could not create link")
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return types.WrapError(err, errorcode.Internal, store.Component, "could not check created link")
+	}
+
+	if rowsAffected == 0 {
+		return types.WrapError(store.ErrLinkAlreadyExists, errorcode.AlreadyExists, store.Component, "could not create link")
+	}
+
+	for _, tag := range link.Meta.Tags {
+		_, err = tx.ExecContext(ctx, SQLAddTag, []byte(linkHash), tag)
+		if err != nil {
+			return types.WrapError(err, errorcode.Internal, store.Component, "could not add tag")
+		}
+	}
+
+	for _, ref := range link.Meta.Refs {
+		_, err = tx.ExecContext(ctx, SQLAddReference, ref.LinkHash, []byte(linkHash))
+		if err != nil {
+			return types.WrapError(err, errorcode.Internal, store.Component, "could not add reference")
+		}
+	}
+
+	if s.enforceUniqueMapEntry && prevLinkHash == nil {
+		_, err = tx.ExecContext(ctx, SQLInitMap, link.Meta.Process.Name, link.Meta.MapId)
+		if err != nil {
+			return types.WrapError(store.ErrUniqueMapEntry, errorcode.FailedPrecondition, store.Component, "could not initialize map")
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, SQLCreateLinkDegree, []byte(linkHash))
+	if err != nil {
+		return types.WrapError(err, errorcode.Internal, store.Component, "could not update link degree")
+	}
+
+	return nil
+}
+
+// GetSegment implements github.com/stratumn/go-core/store.SegmentReader.GetSegment.
+func (s *scopedStore) GetSegment(ctx context.Context, linkHash chainscript.LinkHash) (*chainscript.Segment, error) {
+	var segments = make(types.SegmentSlice, 0, 1)
+
+	rows, err := s.db.QueryContext(ctx, SQLGetSegment, []byte(linkHash))
+	if err != nil {
+		return nil, types.WrapError(err, errorcode.Unavailable, store.Component, "could not get segment")
+	}
+
+	defer rows.Close()
+	if err = scanLinkAndEvidences(rows, &segments, nil); err != nil {
+		return nil, err
+	}
+
+	if len(segments) == 0 {
+		return nil, nil
+	}
+
+	return segments[0], nil
+}
+
+// FindSegments implements github.com/stratumn/go-core/store.SegmentReader.FindSegments.
+func (s *scopedStore) FindSegments(ctx context.Context, filter *store.SegmentFilter) (*types.PaginatedSegments, error) {
+	rows, err := findSegmentsWithFilters(ctx, s.db, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	segments := &types.PaginatedSegments{Segments: make(types.SegmentSlice, 0, filter.Limit)}
+	if err = scanLinkAndEvidences(rows, &segments.Segments, &segments.TotalCount); err != nil {
+		return nil, err
+	}
+
+	segments.NextCursor = store.NextSegmentCursor(segments.Segments, filter.Limit)
+
+	return segments, nil
+}
+
+// IterateSegments implements github.com/stratumn/go-core/store.SegmentIterable.IterateSegments.
+// Rows are read from the database as the iterator advances, so the iterator
+// holds a database connection until it is closed.
+func (s *scopedStore) IterateSegments(ctx context.Context, filter *store.SegmentFilter) (store.SegmentIterator, error) {
+	rows, err := iterateSegmentsWithFilters(ctx, s.db, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &segmentIterator{rows: rows}, nil
+}
+
+// segmentIterator reads segments from rows returned by
+// iterateSegmentsWithFilters.
+// Since a segment spans several rows when it has multiple evidences, the
+// first row of the next segment has to be read before the current segment is
+// complete.
+type segmentIterator struct {
+	rows    *sql.Rows
+	current *chainscript.Segment
+	next    *chainscript.Segment
+	err     error
+}
+
+func (it *segmentIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	it.current, it.next = it.next, nil
+
+	for it.rows.Next() {
+		var (
+			linkHash     chainscript.LinkHash
+			linkData     string
+			evidenceData sql.NullString
+		)
+
+		if err := it.rows.Scan(&linkHash, &linkData, &evidenceData); err != nil {
+			return it.fail(types.WrapError(err, errorcode.Internal, store.Component, "could not scan rows"))
+		}
+
+		segment := it.current
+		if segment == nil || !bytes.Equal(segment.LinkHash(), linkHash) {
+			var err error
+			if segment, err = newSegment(linkData); err != nil {
+				return it.fail(err)
+			}
+		}
+
+		if err := addEvidence(segment, evidenceData); err != nil {
+			return it.fail(err)
+		}
+
+		if it.current == nil {
+			it.current = segment
+		} else if segment != it.current {
+			it.next = segment
+			return true
+		}
+	}
+
+	if err := it.rows.Err(); err != nil {
+		return it.fail(types.WrapError(err, errorcode.Internal, store.Component, "could not scan rows"))
+	}
+
+	return it.current != nil
+}
+
+func (it *segmentIterator) fail(err error) bool {
+	it.current, it.next, it.err = nil, nil, err
+	return false
+}
+
+func (it *segmentIterator) Segment() *chainscript.Segment {
+	return it.current
+}
+
+func (it *segmentIterator) Err() error {
+	return it.err
+}
+
+func (it *segmentIterator) Close() error {
+	it.current, it.next = nil, nil
+	return it.rows.Close()
+}
+
+func scanLinkAndEvidences(rows *sql.Rows, segments *types.SegmentSlice, totalCount *int) error {
+	var currentSegment *chainscript.Segment
+	var currentHash chainscript.LinkHash
+
+	for rows.Next() {
+		var (
+			linkHash     chainscript.LinkHash
+			linkData     string
+			evidenceData sql.NullString
+			err          error
+		)
+
+		if totalCount == nil {
+			if err := rows.Scan(&linkHash, &linkData, &evidenceData); err != nil {
+				return types.WrapError(err, errorcode.Internal, store.Component, "could not scan rows")
+			}
+		} else {
+			if err := rows.Scan(&linkHash, &linkData, &evidenceData, totalCount); err != nil {
+				return types.WrapError(err, errorcode.Internal, store.Component, "could not scan rows")
+			}
+		}
+
+		if !bytes.Equal(currentHash, linkHash) {
+			currentSegment, err = newSegment(linkData)
+			if err != nil {
+				return err
+			}
+
+			currentHash = currentSegment.LinkHash()
+			*segments = append(*segments, currentSegment)
+		}
+
+		if err = addEvidence(currentSegment, evidenceData); err != nil {
+			return err
+		}
+	}
+
+	err := rows.Err()
+	if err != nil {
+		return types.WrapError(err, errorcode.Internal, store.Component, "could not scan rows")
+	}
+
+	return nil
+}
+
+// newSegment creates a segment from a link stored in the database.
+func newSegment(linkData string) (*chainscript.Segment, error) {
+	var link *chainscript.Link
+	if err := json.Unmarshal([]byte(linkData), &link); err != nil {
+		return nil, types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not unmarshal link")
+	}
+
+	segment, err := link.Segmentify()
+	if err != nil {
+		return nil, types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not segmentify")
+	}
+
+	return segment, nil
+}
+
+// addEvidence adds an evidence stored in the database to a segment.
+// Nothing is done if the evidence is null (links without evidence).
+func addEvidence(segment *chainscript.Segment, evidenceData sql.NullString) error {
+	if !evidenceData.Valid || len(evidenceData.String) == 0 {
+		return nil
+	}
+
+	var evidence *chainscript.Evidence
+	if err := json.Unmarshal([]byte(evidenceData.String), &evidence); err != nil {
+		return types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not unmarshal evidence")
+	}
+
+	if err := segment.AddEvidence(evidence); err != nil {
+		return types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not add evidence")
+	}
+
+	return nil
+}
+
+// GetMapIDs implements github.com/stratumn/go-core/store.SegmentReader.GetMapIDs.
+func (s *scopedStore) GetMapIDs(ctx context.Context, filter *store.MapFilter) ([]string, error) {
+	rows, err := getMapIDsWithFilters(ctx, s.db, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	mapIDs := make([]string, 0, filter.Pagination.Limit)
+
+	for rows.Next() {
+		var mapID string
+		if err = rows.Scan(&mapID); err != nil {
+			return nil, types.WrapError(err, errorcode.Internal, store.Component, "could not get map ids")
+		}
+
+		mapIDs = append(mapIDs, mapID)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, types.WrapError(err, errorcode.Internal, store.Component, "could not get map ids")
+	}
+
+	return mapIDs, nil
+}
@@ -0,0 +1,269 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sqlitestore implements a store that saves all the segments in a
+// single SQLite database file.
+//
+// It doesn't need a database server, which makes it a good fit for edge
+// deployments and CI. It requires cgo.
+//
+// SQLite only supports one writer at a time. Writes wait for the database to
+// be unlocked (up to BusyTimeout), and a batch holds the write lock from its
+// first link until it is written.
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+
+	"go.elastic.co/apm/module/apmsql"
+)
+
+const (
+	// Name is the name set in the store's information.
+	Name = "sqlite"
+
+	// Description is the description set in the store's information.
+	Description = "Stratumn's SQLite Store"
+
+	// DefaultPath is the default path of the database file.
+	DefaultPath = "/var/stratumn/sqlitestore/store.db"
+
+	// DefaultBusyTimeout is the default time a write waits for the database
+	// to be unlocked.
+	DefaultBusyTimeout = 5 * time.Second
+)
+
+// driverName is the name of the SQLite driver with the store's SQL
+// functions.
+const driverName = "sqlitestore"
+
+func init() {
+	apmsql.Register(driverName, &sqlite3.SQLiteDriver{ConnectHook: registerFuncs})
+}
+
+// Config contains configuration options for the store.
+type Config struct {
+	// A version string that will be set in the store's information.
+	Version string
+
+	// A git commit hash that will be set in the store's information.
+	Commit string
+
+	// The path of the database file. It is created if it doesn't exist.
+	Path string
+
+	// The time a write waits for the database to be unlocked.
+	// Defaults to DefaultBusyTimeout.
+	BusyTimeout time.Duration
+}
+
+// Info is the info returned by GetInfo.
+type Info struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+	Commit      string `json:"commit"`
+}
+
+// scopedStore implements store read and write operations on a database
+// abstraction.
+// It allows scoping to a transaction for batches.
+type scopedStore struct {
+	db                    sqlQuerier
+	txFactory             txFactory
+	enforceUniqueMapEntry bool
+}
+
+func newScopedStore(db sqlQuerier, txFactory txFactory, enforceUniqueMapEntry bool) *scopedStore {
+	return &scopedStore{
+		db:                    db,
+		txFactory:             txFactory,
+		enforceUniqueMapEntry: enforceUniqueMapEntry,
+	}
+}
+
+// Store is the type that implements github.com/stratumn/go-core/store.Adapter.
+type Store struct {
+	config     *Config
+	eventChans []chan *store.Event
+	db         *sql.DB
+
+	*scopedStore
+
+	batchesMutex sync.Mutex
+	batches      map[*Batch]struct{}
+}
+
+// New creates an instance of a Store.
+func New(config *Config) (*Store, error) {
+	busyTimeout := config.BusyTimeout
+	if busyTimeout <= 0 {
+		busyTimeout = DefaultBusyTimeout
+	}
+
+	// Transactions are only used for writes, so they immediately take the
+	// write lock. Otherwise they could fail instead of waiting when another
+	// connection writes first.
+	dsn := fmt.Sprintf(
+		"%s?_busy_timeout=%d&_foreign_keys=1&_journal_mode=WAL&_txlock=immediate",
+		config.Path,
+		busyTimeout/time.Millisecond,
+	)
+
+	db, err := apmsql.Open(driverName, dsn)
+	if err != nil {
+		return nil, types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not create sqlitestore")
+	}
+
+	return &Store{
+		config:      config,
+		db:          db,
+		scopedStore: newScopedStore(db, newStandardTxFactory(db), false),
+		batches:     make(map[*Batch]struct{}),
+	}, nil
+}
+
+// EnforceUniqueMapEntry makes sure each process map contains a single link
+// without parent.
+func (a *Store) EnforceUniqueMapEntry() error {
+	a.scopedStore.enforceUniqueMapEntry = true
+	return nil
+}
+
+// GetInfo implements github.com/stratumn/go-core/store.Adapter.GetInfo.
+func (a *Store) GetInfo(ctx context.Context) (interface{}, error) {
+	return &Info{
+		Name:        Name,
+		Description: Description,
+		Version:     a.config.Version,
+		Commit:      a.config.Commit,
+	}, nil
+}
+
+// NewBatch implements github.com/stratumn/go-core/store.Adapter.NewBatch.
+// Batches don't lock the database between calls: concurrent batches and
+// writes only wait (up to the busy timeout) for each other's transactions.
+func (a *Store) NewBatch(ctx context.Context) (store.Batch, error) {
+	b := newBatch(a.db, a.scopedStore.enforceUniqueMapEntry)
+
+	a.batchesMutex.Lock()
+	defer a.batchesMutex.Unlock()
+
+	// Batches are only tracked to be aborted when the store is closed.
+	for started := range a.batches {
+		if started.isDone() {
+			delete(a.batches, started)
+		}
+	}
+
+	a.batches[b] = struct{}{}
+
+	return b, nil
+}
+
+// AddStoreEventChannel implements github.com/stratumn/go-core/store.Adapter.AddStoreEventChannel
+func (a *Store) AddStoreEventChannel(eventChan chan *store.Event) {
+	a.eventChans = append(a.eventChans, eventChan)
+}
+
+// CreateLink implements github.com/stratumn/go-core/store.LinkWriter.CreateLink.
+func (a *Store) CreateLink(ctx context.Context, link *chainscript.Link) (chainscript.LinkHash, error) {
+	linkHash, err := a.scopedStore.CreateLink(ctx, link)
+	if err != nil {
+		return nil, err
+	}
+
+	linkEvent := store.NewSavedLinks(link)
+
+	for _, c := range a.eventChans {
+		c <- linkEvent
+	}
+
+	return linkHash, nil
+}
+
+// AddEvidence implements github.com/stratumn/go-core/store.EvidenceWriter.AddEvidence.
+func (a *Store) AddEvidence(ctx context.Context, linkHash chainscript.LinkHash, evidence *chainscript.Evidence) error {
+	err := a.scopedStore.AddEvidence(ctx, linkHash, evidence)
+	if err != nil {
+		return err
+	}
+
+	evidenceEvent := store.NewSavedEvidences()
+	evidenceEvent.AddSavedEvidence(linkHash, evidence)
+
+	for _, c := range a.eventChans {
+		c <- evidenceEvent
+	}
+
+	return nil
+}
+
+// Create creates the database tables and indexes.
+// It can safely be called on an existing database.
+func (a *Store) Create() error {
+	for _, query := range sqlCreate {
+		if _, err := a.db.Exec(query); err != nil {
+			return types.WrapError(err, errorcode.Unavailable, store.Component, "could not create tables")
+		}
+	}
+
+	return nil
+}
+
+// Drop drops the database tables and indexes. It also aborts started batches.
+func (a *Store) Drop() error {
+	a.abortBatches()
+
+	for _, query := range sqlDrop {
+		if _, err := a.db.Exec(query); err != nil {
+			return types.WrapError(err, errorcode.Unavailable, store.Component, "could not drop tables")
+		}
+	}
+
+	return nil
+}
+
+// Close aborts started batches and closes the database.
+func (a *Store) Close() error {
+	a.abortBatches()
+
+	err := a.db.Close()
+	if err != nil {
+		return types.WrapError(err, errorcode.Unavailable, store.Component, "could not close DB")
+	}
+
+	return nil
+}
+
+// abortBatches aborts the batches that haven't been written yet.
+func (a *Store) abortBatches() {
+	a.batchesMutex.Lock()
+	defer a.batchesMutex.Unlock()
+
+	for b := range a.batches {
+		b.abort()
+		delete(a.batches, b)
+	}
+}
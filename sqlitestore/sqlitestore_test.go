@@ -0,0 +1,115 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlitestore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/store/storetestcases"
+	"github.com/stratumn/go-core/tmpop/tmpoptestcases"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	factory := storetestcases.Factory{
+		New:               createAdapter,
+		NewKeyValueStore:  createKeyValueStore,
+		Free:              freeAdapter,
+		FreeKeyValueStore: freeKeyValueStore,
+	}
+
+	factory.RunStoreTests(t)
+	factory.RunKeyValueStoreTests(t)
+}
+
+func TestSQLiteTMPop(t *testing.T) {
+	tmpoptestcases.Factory{
+		New:  createAdapterTMPop,
+		Free: freeAdapterTMPop,
+	}.RunTests(t)
+}
+
+// createStore creates a store in its own temporary directory.
+func createStore() (*Store, error) {
+	dir, err := ioutil.TempDir("", "sqlitestore")
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := New(&Config{
+		Path: filepath.Join(dir, "store.db"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := a.Create(); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func createAdapter() (store.Adapter, error) {
+	return createStore()
+}
+
+func createKeyValueStore() (store.KeyValueStore, error) {
+	return createStore()
+}
+
+func freeStore(s *Store) {
+	if err := s.Close(); err != nil {
+		panic(err)
+	}
+
+	os.RemoveAll(filepath.Dir(s.config.Path))
+}
+
+func freeAdapter(s store.Adapter) {
+	freeStore(s.(*Store))
+}
+
+func freeKeyValueStore(s store.KeyValueStore) {
+	freeStore(s.(*Store))
+}
+
+func createAdapterTMPop() (store.Adapter, store.KeyValueStore, error) {
+	a, err := createStore()
+	return a, a, err
+}
+
+func freeAdapterTMPop(a store.Adapter, _ store.KeyValueStore) {
+	freeAdapter(a)
+}
+
+func TestCreate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sqlitestore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	config := &Config{Path: filepath.Join(dir, "store.db")}
+
+	// Creating the tables of an existing database should not fail.
+	for i := 0; i < 2; i++ {
+		a, err := New(config)
+		require.NoError(t, err)
+		require.NoError(t, a.Create())
+		require.NoError(t, a.Close())
+	}
+}
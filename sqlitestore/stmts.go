@@ -0,0 +1,595 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+)
+
+// Plain SQL statements.
+// Parameters are numbered so they can be used several times.
+const (
+	SQLCreateLink = `
+		INSERT OR IGNORE INTO links (
+			link_hash,
+			priority,
+			map_id,
+			prev_link_hash,
+			data,
+			process,
+			step,
+			link_data,
+			created_at
+		)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9)
+	`
+	SQLAddTag = `
+		INSERT OR IGNORE INTO link_tags (
+			link_hash,
+			tag
+		)
+		VALUES (?1, ?2)
+	`
+	SQLCreateLinkDegree = `
+		INSERT INTO links_degree (
+			link_hash,
+			out_degree
+		)
+		VALUES (?1, 0)
+	`
+	SQLGetLinkDegree = `
+		SELECT out_degree FROM links_degree
+		WHERE link_hash = ?1
+	`
+	SQLIncrementLinkDegree = `
+		UPDATE links_degree SET out_degree = out_degree + 1
+		WHERE link_hash = ?1
+	`
+	SQLInitMap = `
+		INSERT INTO process_maps (
+			process,
+			map_id
+		)
+		VALUES (?1, ?2)
+	`
+	SQLAddReference = `
+		INSERT INTO refs (
+			link_hash,
+			referenced_by
+		)
+		VALUES (?1, ?2)
+	`
+	SQLGetLink = `
+		SELECT data FROM links
+		WHERE link_hash = ?1
+	`
+	SQLGetSegment = `
+		SELECT l.link_hash, l.data, e.data FROM links l
+		LEFT JOIN evidences e ON l.link_hash = e.link_hash
+		WHERE l.link_hash = ?1
+	`
+	SQLGetAncestors = `
+		WITH RECURSIVE ` + sqlMapEdges + `, ancestors(link_hash, depth) AS (
+			SELECT e.parent, 1 FROM edges e
+			WHERE e.child = ?1
+			UNION
+			SELECT e.parent, a.depth + 1 FROM ancestors a
+			JOIN edges e ON e.child = a.link_hash
+			WHERE ?2 <= 0 OR a.depth < ?2
+		)
+		SELECT l.link_hash, l.data, e.data FROM links l
+		JOIN start s ON l.map_id = s.map_id AND l.process = s.process
+		LEFT JOIN evidences e ON l.link_hash = e.link_hash
+		WHERE l.link_hash IN (SELECT link_hash FROM ancestors)
+		AND l.link_hash <> ?1
+		ORDER BY l.priority DESC, l.link_hash ASC
+	`
+	SQLGetDescendants = `
+		WITH RECURSIVE ` + sqlMapEdges + `, descendants(link_hash, depth) AS (
+			SELECT e.child, 1 FROM edges e
+			WHERE e.parent = ?1
+			UNION
+			SELECT e.child, d.depth + 1 FROM descendants d
+			JOIN edges e ON e.parent = d.link_hash
+			WHERE ?2 <= 0 OR d.depth < ?2
+		)
+		SELECT l.link_hash, l.data, e.data FROM links l
+		LEFT JOIN evidences e ON l.link_hash = e.link_hash
+		WHERE l.link_hash IN (SELECT link_hash FROM descendants)
+		AND l.link_hash <> ?1
+		ORDER BY l.priority DESC, l.link_hash ASC
+	`
+	SQLGetMapHeads = `
+		WITH parents AS (
+			SELECT c.prev_link_hash AS link_hash FROM links c
+			WHERE c.process = ?1 AND c.map_id = ?2 AND c.prev_link_hash IS NOT NULL
+			UNION
+			SELECT r.link_hash FROM refs r
+			JOIN links c ON c.link_hash = r.referenced_by
+			WHERE c.process = ?1 AND c.map_id = ?2
+		)
+		SELECT l.link_hash, l.data, e.data FROM links l
+		LEFT JOIN evidences e ON l.link_hash = e.link_hash
+		WHERE l.process = ?1 AND l.map_id = ?2
+		AND l.link_hash NOT IN (SELECT link_hash FROM parents)
+		ORDER BY l.priority DESC, l.link_hash ASC
+	`
+	SQLSaveValue = `
+		INSERT OR REPLACE INTO key_values (
+			key,
+			value
+		)
+		VALUES (?1, ?2)
+	`
+	SQLGetValue = `
+		SELECT value FROM key_values
+		WHERE key = ?1
+	`
+	SQLDeleteValue = `
+		DELETE FROM key_values
+		WHERE key = ?1
+	`
+	SQLIterateValues = `
+		SELECT key, value FROM key_values
+		WHERE key >= ?1 AND key < ?2
+		ORDER BY key
+		LIMIT ?3
+	`
+	SQLIterateValuesFrom = `
+		SELECT key, value FROM key_values
+		WHERE key >= ?1
+		ORDER BY key
+		LIMIT ?2
+	`
+	SQLGetEvidences = `
+		SELECT data FROM evidences
+		WHERE link_hash = ?1
+	`
+	SQLAddEvidence = `
+		INSERT OR IGNORE INTO evidences (
+			link_hash,
+			provider,
+			data
+		)
+		VALUES (?1, ?2, ?3)
+	`
+)
+
+// sqlMapEdges defines the edges of the graph of the map containing the link
+// ?1 (from a child to its parent and to the links it references).
+// Edges may point to links of other maps, which need to be filtered out.
+const sqlMapEdges = `
+	start AS (
+		SELECT map_id, process FROM links
+		WHERE link_hash = ?1
+	), edges(child, parent) AS (
+		SELECT c.link_hash, c.prev_link_hash FROM links c
+		JOIN start s ON c.map_id = s.map_id AND c.process = s.process
+		WHERE c.prev_link_hash IS NOT NULL
+		UNION
+		SELECT r.referenced_by, r.link_hash FROM refs r
+		JOIN links c ON c.link_hash = r.referenced_by
+		JOIN start s ON c.map_id = s.map_id AND c.process = s.process
+	)`
+
+// Links without parent have a NULL prev_link_hash. The creation time is
+// stored in nanoseconds since the Unix epoch.
+var sqlCreate = []string{
+	`
+		CREATE TABLE IF NOT EXISTS links (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			link_hash BLOB NOT NULL UNIQUE,
+			priority REAL NOT NULL,
+			map_id TEXT NOT NULL,
+			prev_link_hash BLOB DEFAULT NULL,
+			data TEXT NOT NULL,
+			process TEXT NOT NULL,
+			step TEXT NOT NULL,
+			link_data TEXT DEFAULT NULL,
+			created_at INTEGER NOT NULL
+		)
+	`,
+	`
+		CREATE INDEX IF NOT EXISTS links_priority_link_hash_idx
+		ON links (priority DESC, link_hash ASC)
+	`,
+	`
+		CREATE INDEX IF NOT EXISTS links_map_id_priority_link_hash_idx
+		ON links (map_id, priority DESC, link_hash ASC)
+	`,
+	`
+		CREATE INDEX IF NOT EXISTS links_prev_link_hash_idx
+		ON links (prev_link_hash)
+	`,
+	`
+		CREATE INDEX IF NOT EXISTS links_process_map_id_idx
+		ON links (process, map_id)
+	`,
+	`
+		CREATE INDEX IF NOT EXISTS links_created_at_idx
+		ON links (created_at)
+	`,
+	`
+		CREATE TABLE IF NOT EXISTS link_tags (
+			link_hash BLOB NOT NULL REFERENCES links(link_hash),
+			tag TEXT NOT NULL,
+			PRIMARY KEY (link_hash, tag)
+		)
+	`,
+	`
+		CREATE INDEX IF NOT EXISTS link_tags_tag_idx
+		ON link_tags (tag)
+	`,
+	`
+		CREATE TABLE IF NOT EXISTS links_degree (
+			link_hash BLOB PRIMARY KEY REFERENCES links(link_hash),
+			out_degree INTEGER NOT NULL
+		)
+	`,
+	`
+		CREATE TABLE IF NOT EXISTS evidences (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			link_hash BLOB NOT NULL REFERENCES links(link_hash),
+			provider TEXT NOT NULL,
+			data TEXT NOT NULL,
+			UNIQUE(link_hash, provider)
+		)
+	`,
+	`
+		CREATE TABLE IF NOT EXISTS key_values (
+			key BLOB PRIMARY KEY,
+			value BLOB NOT NULL
+		)
+	`,
+	`
+		CREATE TABLE IF NOT EXISTS process_maps (
+			process TEXT NOT NULL,
+			map_id TEXT NOT NULL,
+			PRIMARY KEY (process, map_id)
+		)
+	`,
+	`
+		CREATE TABLE IF NOT EXISTS refs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			link_hash BLOB NOT NULL,
+			referenced_by BLOB NOT NULL
+		)
+	`,
+	`
+		CREATE INDEX IF NOT EXISTS refs_link_hash_idx
+		ON refs (link_hash)
+	`,
+	`
+		CREATE INDEX IF NOT EXISTS refs_referenced_by_idx
+		ON refs (referenced_by)
+	`,
+}
+
+// Tables referencing links need to be dropped first.
+var sqlDrop = []string{
+	"DROP TABLE IF EXISTS refs",
+	"DROP TABLE IF EXISTS process_maps",
+	"DROP TABLE IF EXISTS key_values",
+	"DROP TABLE IF EXISTS evidences",
+	"DROP TABLE IF EXISTS links_degree",
+	"DROP TABLE IF EXISTS link_tags",
+	"DROP TABLE IF EXISTS links",
+}
+
+// params builds the numbered parameters of a query.
+type params struct {
+	values []interface{}
+}
+
+// add adds a parameter and returns its placeholder.
+func (p *params) add(value interface{}) string {
+	p.values = append(p.values, value)
+	return fmt.Sprintf("?%d", len(p.values))
+}
+
+// list adds parameters and returns a comma-separated list of their
+// placeholders.
+func (p *params) list(values ...interface{}) string {
+	placeholders := make([]string, len(values))
+	for i, v := range values {
+		placeholders[i] = p.add(v)
+	}
+
+	return strings.Join(placeholders, ", ")
+}
+
+// stringValues converts strings to parameter values.
+func stringValues(s []string) []interface{} {
+	values := make([]interface{}, len(s))
+	for i, v := range s {
+		values[i] = v
+	}
+
+	return values
+}
+
+// whereClause joins SQL conditions in a WHERE clause.
+func whereClause(filters []string) string {
+	if len(filters) == 0 {
+		return ""
+	}
+
+	return "\nWHERE " + strings.Join(filters, "\n AND ")
+}
+
+// getMapIDsWithFilters retrieves maps ids from the store given some filters.
+// Map IDs are sorted alphabetically.
+func getMapIDsWithFilters(ctx context.Context, db sqlQuerier, filter *store.MapFilter) (*sql.Rows, error) {
+	filters := []string{}
+	p := &params{}
+
+	// LIKE is case-insensitive in SQLite, so prefixes and suffixes are
+	// compared with substrings.
+	if filter.Prefix != "" {
+		prefix := p.add(filter.Prefix)
+		filters = append(filters, fmt.Sprintf("substr(l.map_id, 1, length(%[1]s)) = %[1]s", prefix))
+	}
+
+	if filter.Suffix != "" {
+		suffix := p.add(filter.Suffix)
+		filters = append(filters, fmt.Sprintf(
+			"length(l.map_id) >= length(%[1]s) AND substr(l.map_id, -length(%[1]s)) = %[1]s",
+			suffix,
+		))
+	}
+
+	if filter.Process != "" {
+		filters = append(filters, "l.process = "+p.add(filter.Process))
+	}
+
+	offset := filter.Pagination.Offset
+	if filter.Cursor != "" {
+		filters = append(filters, "l.map_id > "+p.add(filter.Cursor))
+		offset = 0
+	}
+
+	query := fmt.Sprintf(`
+		SELECT l.map_id FROM links l
+		%s
+		GROUP BY l.map_id
+		ORDER BY l.map_id ASC
+		LIMIT %d OFFSET %d
+	`,
+		whereClause(filters),
+		filter.Pagination.Limit,
+		offset,
+	)
+
+	rows, err := db.QueryContext(ctx, query, p.values...)
+	if err != nil {
+		return nil, types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not get map ids")
+	}
+
+	return rows, nil
+}
+
+// getOrdering returns the ordering of the priority and link hash columns
+// matching github.com/stratumn/go-core/types.SegmentSlice.
+func getOrdering(reverse bool) (priority string, linkHash string) {
+	if reverse {
+		return "ASC", "DESC"
+	}
+	return "DESC", "ASC"
+}
+
+// segmentFilters returns the SQL conditions selecting the links matching the
+// filter, and adds their parameters.
+// The cursor and pagination are not taken into account.
+func segmentFilters(filter *store.SegmentFilter, p *params) []string {
+	filters := []string{}
+
+	if len(filter.MapIDs) > 0 {
+		filters = append(filters, fmt.Sprintf("l.map_id IN (%s)", p.list(stringValues(filter.MapIDs)...)))
+	}
+
+	if filter.Process != "" {
+		filters = append(filters, "l.process = "+p.add(filter.Process))
+	}
+
+	if filter.Step != "" {
+		filters = append(filters, "l.step = "+p.add(filter.Step))
+	}
+
+	if len(filter.Processes) > 0 {
+		filters = append(filters, fmt.Sprintf("l.process IN (%s)", p.list(stringValues(filter.Processes)...)))
+	}
+
+	if len(filter.Steps) > 0 {
+		filters = append(filters, fmt.Sprintf("l.step IN (%s)", p.list(stringValues(filter.Steps)...)))
+	}
+
+	if filter.WithoutParent {
+		filters = append(filters, "l.prev_link_hash IS NULL")
+	} else if len(filter.PrevLinkHash) > 0 {
+		filters = append(filters, "l.prev_link_hash = "+p.add([]byte(filter.PrevLinkHash)))
+	}
+
+	if len(filter.LinkHashes) > 0 {
+		linkHashes := make([]interface{}, len(filter.LinkHashes))
+		for i, lh := range filter.LinkHashes {
+			linkHashes[i] = []byte(lh)
+		}
+
+		filters = append(filters, fmt.Sprintf("l.link_hash IN (%s)", p.list(linkHashes...)))
+	}
+
+	for _, tag := range filter.Tags {
+		filters = append(filters, fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM link_tags t
+			WHERE t.link_hash = l.link_hash AND t.tag = %s
+		)`, p.add(tag)))
+	}
+
+	if len(filter.TagsAny) > 0 {
+		filters = append(filters, fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM link_tags t
+			WHERE t.link_hash = l.link_hash AND t.tag IN (%s)
+		)`, p.list(stringValues(filter.TagsAny)...)))
+	}
+
+	if len(filter.Referencing) > 0 {
+		filters = append(filters, fmt.Sprintf(`l.link_hash IN (
+			SELECT r.referenced_by FROM refs r
+			WHERE r.link_hash = %s
+		)`, p.add([]byte(filter.Referencing))))
+	}
+
+	if filter.CreatedAfter != nil {
+		filters = append(filters, "l.created_at >= "+p.add(filter.CreatedAfter.UnixNano()))
+	}
+
+	if filter.CreatedBefore != nil {
+		filters = append(filters, "l.created_at < "+p.add(filter.CreatedBefore.UnixNano()))
+	}
+
+	// Predicates are evaluated by the data_match function, which has the
+	// same semantics as github.com/stratumn/go-core/store.DataPredicate.Match.
+	for i := range filter.Data {
+		predicate, err := json.Marshal(&filter.Data[i])
+		if err != nil {
+			filters = append(filters, "0")
+			continue
+		}
+
+		filters = append(filters, fmt.Sprintf("data_match(l.link_data, %s)", p.add(string(predicate))))
+	}
+
+	return filters
+}
+
+// cursorFilter adds the SQL condition selecting the links that come after
+// the filter's cursor.
+func cursorFilter(filter *store.SegmentFilter, filters []string, p *params) ([]string, error) {
+	cursor, err := store.ParseSegmentCursor(filter.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	priorityCmp, linkHashCmp := "<", ">"
+	if filter.Reverse {
+		priorityCmp, linkHashCmp = ">", "<"
+	}
+
+	priority := p.add(cursor.Priority)
+	linkHash := p.add([]byte(cursor.LinkHash))
+	filters = append(filters, fmt.Sprintf(
+		"(l.priority %[1]s %[2]s OR (l.priority = %[2]s AND l.link_hash %[3]s %[4]s))",
+		priorityCmp,
+		priority,
+		linkHashCmp,
+		linkHash,
+	))
+
+	return filters, nil
+}
+
+// findSegmentsWithFilters formats a read query and retrieves segments
+// according to the filter.
+func findSegmentsWithFilters(ctx context.Context, db sqlQuerier, filter *store.SegmentFilter) (*sql.Rows, error) {
+	p := &params{}
+	filters := segmentFilters(filter, p)
+
+	// The total count ignores the cursor: it is the number of segments
+	// matching the filter.
+	sqlTotalCount := "SELECT COUNT(*) FROM links l" + whereClause(filters)
+
+	priorityOrder, linkHashOrder := getOrdering(filter.Reverse)
+
+	offset := filter.Pagination.Offset
+	if filter.Cursor != "" {
+		var err error
+		if filters, err = cursorFilter(filter, filters, p); err != nil {
+			return nil, err
+		}
+
+		offset = 0
+	}
+
+	// Pagination is applied to links before joining evidences, otherwise
+	// segments with multiple evidences would take several slots in a page.
+	query := fmt.Sprintf(`
+		SELECT p.link_hash, p.data, e.data, p.total_count FROM (
+			SELECT l.link_hash, l.priority, l.data, (%[1]s) AS total_count
+			FROM links l
+			%[2]s
+			ORDER BY l.priority %[3]s, l.link_hash %[4]s
+			LIMIT %[6]d OFFSET %[5]d
+		) p
+		LEFT JOIN evidences e ON p.link_hash = e.link_hash
+		ORDER BY p.priority %[3]s, p.link_hash %[4]s
+	`,
+		sqlTotalCount,
+		whereClause(filters),
+		priorityOrder,
+		linkHashOrder,
+		offset,
+		filter.Pagination.Limit,
+	)
+
+	rows, err := db.QueryContext(ctx, query, p.values...)
+	if err != nil {
+		return nil, types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not find segments")
+	}
+
+	return rows, nil
+}
+
+// iterateSegmentsWithFilters formats a read query that retrieves all the
+// segments matching the filter, without pagination nor total count.
+// Rows of a segment with multiple evidences are consecutive.
+func iterateSegmentsWithFilters(ctx context.Context, db sqlQuerier, filter *store.SegmentFilter) (*sql.Rows, error) {
+	p := &params{}
+	filters := segmentFilters(filter, p)
+
+	if filter.Cursor != "" {
+		var err error
+		if filters, err = cursorFilter(filter, filters, p); err != nil {
+			return nil, err
+		}
+	}
+
+	priorityOrder, linkHashOrder := getOrdering(filter.Reverse)
+
+	query := fmt.Sprintf(`
+		SELECT l.link_hash, l.data, e.data FROM links l
+		LEFT JOIN evidences e ON l.link_hash = e.link_hash
+		%[1]s
+		ORDER BY l.priority %[2]s, l.link_hash %[3]s
+	`,
+		whereClause(filters),
+		priorityOrder,
+		linkHashOrder,
+	)
+
+	rows, err := db.QueryContext(ctx, query, p.values...)
+	if err != nil {
+		return nil, types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not iterate segments")
+	}
+
+	return rows, nil
+}
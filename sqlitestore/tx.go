@@ -0,0 +1,93 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/stratumn/go-core/monitoring"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+)
+
+// sqlQuerier executes queries on a database or in a transaction.
+type sqlQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// txFactory creates the transactions used to write to the database.
+type txFactory interface {
+	NewTx(ctx context.Context) (*sql.Tx, error)
+	CommitTx(tx *sql.Tx) error
+	RollbackTx(tx *sql.Tx, err error)
+}
+
+// standardTxFactory creates a new transaction for each write.
+type standardTxFactory struct {
+	db *sql.DB
+}
+
+func newStandardTxFactory(db *sql.DB) txFactory {
+	return &standardTxFactory{db: db}
+}
+
+func (f *standardTxFactory) NewTx(ctx context.Context) (*sql.Tx, error) {
+	tx, err := f.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, types.WrapError(err, errorcode.Unavailable, store.Component, "could not create tx")
+	}
+
+	return tx, nil
+}
+
+func (f *standardTxFactory) CommitTx(tx *sql.Tx) error {
+	err := tx.Commit()
+	if err != nil {
+		return types.WrapError(err, errorcode.Internal, store.Component, "could not commit tx")
+	}
+
+	return nil
+}
+
+func (f *standardTxFactory) RollbackTx(tx *sql.Tx, _ error) {
+	err := tx.Rollback()
+	if err != nil {
+		monitoring.LogEntry().Warnf("Error during transaction rollback: %s", err.Error())
+	}
+}
+
+// singletonTxFactory runs all the writes of a batch in the same transaction.
+// The batch rolls its transaction back when a write fails.
+type singletonTxFactory struct {
+	tx *sql.Tx
+}
+
+func newSingletonTxFactory(tx *sql.Tx) txFactory {
+	return &singletonTxFactory{tx: tx}
+}
+
+func (f *singletonTxFactory) NewTx(context.Context) (*sql.Tx, error) {
+	return f.tx, nil
+}
+
+func (f *singletonTxFactory) CommitTx(*sql.Tx) error {
+	return nil
+}
+
+func (f *singletonTxFactory) RollbackTx(*sql.Tx, error) {}
@@ -0,0 +1,157 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/types"
+)
+
+// SegmentCursor is a position in the segments ordering.
+// Segments are ordered by decreasing priority, then by increasing link hash
+// (see github.com/stratumn/go-core/types.SegmentSlice).
+// Since links are immutable, a cursor stays valid when links are added to
+// the store: pages that follow it contain neither duplicates nor gaps.
+type SegmentCursor struct {
+	Priority float64
+	LinkHash chainscript.LinkHash
+}
+
+// NewSegmentCursor creates a cursor positioned on the given segment.
+func NewSegmentCursor(segment *chainscript.Segment) *SegmentCursor {
+	return &SegmentCursor{
+		Priority: segment.Link.Meta.Priority,
+		LinkHash: segment.LinkHash(),
+	}
+}
+
+// ParseSegmentCursor decodes a cursor returned by a previous call to
+// FindSegments.
+func ParseSegmentCursor(cursor string) (*SegmentCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, types.WrapError(ErrInvalidCursor, errorcode.InvalidArgument, Component, "could not decode cursor")
+	}
+
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 2 {
+		return nil, types.WrapError(ErrInvalidCursor, errorcode.InvalidArgument, Component, "could not decode cursor")
+	}
+
+	priority, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return nil, types.WrapError(ErrInvalidCursor, errorcode.InvalidArgument, Component, "could not decode cursor priority")
+	}
+
+	linkHash, err := chainscript.NewLinkHashFromString(parts[1])
+	if err != nil || len(linkHash) == 0 {
+		return nil, types.WrapError(ErrInvalidCursor, errorcode.InvalidArgument, Component, "could not decode cursor link hash")
+	}
+
+	return &SegmentCursor{Priority: priority, LinkHash: linkHash}, nil
+}
+
+// String encodes the cursor to an opaque string.
+func (c *SegmentCursor) String() string {
+	raw := strconv.FormatFloat(c.Priority, 'g', -1, 64) + ":" + c.LinkHash.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// After returns true if the segment is strictly after the cursor.
+// If reverse is true, the reversed ordering is used.
+func (c *SegmentCursor) After(segment *chainscript.Segment, reverse bool) bool {
+	cmp := c.Compare(NewSegmentCursor(segment))
+	if reverse {
+		return cmp > 0
+	}
+
+	return cmp < 0
+}
+
+// Compare returns a negative integer if the cursor comes before the other
+// one in the segments ordering, a positive integer if it comes after and zero
+// if they are equal.
+func (c *SegmentCursor) Compare(other *SegmentCursor) int {
+	if c.Priority > other.Priority {
+		return -1
+	}
+
+	if c.Priority < other.Priority {
+		return 1
+	}
+
+	return bytes.Compare(c.LinkHash, other.LinkHash)
+}
+
+// NextSegmentCursor returns the cursor that should be used to fetch the page
+// following the given one.
+// It returns an empty string when the page isn't full since there are no
+// more segments to fetch.
+func NextSegmentCursor(segments types.SegmentSlice, limit int) string {
+	if limit <= 0 || len(segments) < limit {
+		return ""
+	}
+
+	return NewSegmentCursor(segments[len(segments)-1]).String()
+}
+
+// PaginateSegments paginates a list of segments sorted according to the
+// filter's ordering.
+// Contrary to Pagination.PaginateSegments it takes the cursor into account,
+// which requires knowing the ordering of the segments.
+func (filter *SegmentFilter) PaginateSegments(a *types.PaginatedSegments) (*types.PaginatedSegments, error) {
+	if filter.Cursor == "" {
+		return filter.Pagination.PaginateSegments(a), nil
+	}
+
+	segments, err := filter.SkipToCursor(a.Segments)
+	if err != nil {
+		return nil, err
+	}
+
+	p := Pagination{Limit: filter.Limit}
+	return p.PaginateSegments(&types.PaginatedSegments{
+		Segments:   segments,
+		TotalCount: a.TotalCount,
+	}), nil
+}
+
+// SkipToCursor returns the segments that come after the filter's cursor.
+// The segments must be sorted according to the filter's ordering.
+// If the filter doesn't have a cursor, all the segments are returned.
+func (filter *SegmentFilter) SkipToCursor(segments types.SegmentSlice) (types.SegmentSlice, error) {
+	if filter.Cursor == "" {
+		return segments, nil
+	}
+
+	cursor, err := ParseSegmentCursor(filter.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, s := range segments {
+		if cursor.After(s, filter.Reverse) {
+			return segments[i:], nil
+		}
+	}
+
+	return segments[len(segments):], nil
+}
@@ -0,0 +1,173 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store_test
+
+import (
+	"encoding/base64"
+	"sort"
+	"testing"
+
+	"github.com/stratumn/go-chainscript/chainscripttest"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/testutil"
+	"github.com/stratumn/go-core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sortedTestingSegments(t *testing.T, count int, reverse bool) *types.PaginatedSegments {
+	segments := make(types.SegmentSlice, 0, count)
+	for i := 0; i < count; i++ {
+		segment := chainscripttest.NewLinkBuilder(t).
+			WithRandomData().
+			WithPriority(float64(i % 7)).
+			Segmentify(t)
+		segments = append(segments, segment)
+	}
+
+	segments.Sort(reverse)
+	return &types.PaginatedSegments{Segments: segments, TotalCount: count}
+}
+
+func TestSegmentCursor(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		c := &store.SegmentCursor{
+			Priority: 4.2,
+			LinkHash: chainscripttest.RandomHash(),
+		}
+
+		parsed, err := store.ParseSegmentCursor(c.String())
+		require.NoError(t, err)
+		assert.Equal(t, c, parsed)
+	})
+
+	t.Run("invalid cursors", func(t *testing.T) {
+		invalid := []string{
+			"not base64!",
+			base64.RawURLEncoding.EncodeToString([]byte("no separator")),
+			base64.RawURLEncoding.EncodeToString([]byte("high:" + chainscripttest.RandomHash().String())),
+			base64.RawURLEncoding.EncodeToString([]byte("4.2:not hex")),
+			base64.RawURLEncoding.EncodeToString([]byte("4.2:")),
+		}
+
+		for _, cursor := range invalid {
+			_, err := store.ParseSegmentCursor(cursor)
+			require.Error(t, err, cursor)
+			testutil.AssertWrappedErrorEqual(t, err, store.ErrInvalidCursor)
+		}
+	})
+
+	t.Run("segments after cursor", func(t *testing.T) {
+		segments := sortedTestingSegments(t, sliceSize, false).Segments
+		c := store.NewSegmentCursor(segments[sliceSize/2])
+
+		assert.False(t, c.After(segments[sliceSize/2], false))
+		assert.False(t, c.After(segments[sliceSize/2], true))
+		assert.True(t, c.After(segments[sliceSize/2+1], false))
+		assert.False(t, c.After(segments[sliceSize/2+1], true))
+		assert.False(t, c.After(segments[sliceSize/2-1], false))
+		assert.True(t, c.After(segments[sliceSize/2-1], true))
+	})
+}
+
+func TestNextSegmentCursor(t *testing.T) {
+	segments := sortedTestingSegments(t, sliceSize, false).Segments
+
+	assert.Empty(t, store.NextSegmentCursor(segments, 0))
+	assert.Empty(t, store.NextSegmentCursor(segments, sliceSize+1))
+	assert.Equal(t,
+		store.NewSegmentCursor(segments[sliceSize-1]).String(),
+		store.NextSegmentCursor(segments, sliceSize),
+	)
+}
+
+func TestSegmentFilter_PaginateSegments(t *testing.T) {
+	pageSize := 7
+
+	for _, reverse := range []bool{false, true} {
+		all := sortedTestingSegments(t, sliceSize, reverse)
+
+		filter := &store.SegmentFilter{
+			Pagination: store.Pagination{
+				Limit:  pageSize,
+				Offset: 3,
+			},
+			Reverse: reverse,
+		}
+
+		var got types.SegmentSlice
+		for {
+			page, err := filter.PaginateSegments(all)
+			require.NoError(t, err)
+			assert.Equal(t, sliceSize, page.TotalCount)
+
+			got = append(got, page.Segments...)
+			if page.NextCursor == "" {
+				break
+			}
+
+			filter.Cursor = page.NextCursor
+		}
+
+		// The offset only applies to the first page.
+		assert.Equal(t, all.Segments[3:], got, "reverse: %v", reverse)
+	}
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		filter := &store.SegmentFilter{
+			Pagination: store.Pagination{
+				Limit:  pageSize,
+				Cursor: "not a cursor",
+			},
+		}
+
+		_, err := filter.PaginateSegments(paginatedSegments)
+		testutil.AssertWrappedErrorEqual(t, err, store.ErrInvalidCursor)
+	})
+
+	t.Run("cursor of a missing segment", func(t *testing.T) {
+		all := sortedTestingSegments(t, sliceSize, false)
+		missing := all.Segments[10]
+		filter := &store.SegmentFilter{
+			Pagination: store.Pagination{
+				Limit:  pageSize,
+				Cursor: store.NewSegmentCursor(missing).String(),
+			},
+		}
+
+		remaining := &types.PaginatedSegments{
+			Segments:   append(append(types.SegmentSlice{}, all.Segments[:10]...), all.Segments[11:]...),
+			TotalCount: sliceSize - 1,
+		}
+
+		page, err := filter.PaginateSegments(remaining)
+		require.NoError(t, err)
+		assert.Equal(t, all.Segments[11:11+pageSize], page.Segments)
+	})
+}
+
+func TestPagination_PaginateStrings_Cursor(t *testing.T) {
+	sorted := append([]string{}, stringSlice...)
+	sort.Strings(sorted)
+
+	p := &store.Pagination{Limit: 10, Offset: 42, Cursor: sorted[20]}
+	assert.Equal(t, sorted[21:31], p.PaginateStrings(sorted))
+
+	p.Cursor = sorted[sliceSize-1]
+	assert.Equal(t, []string{}, p.PaginateStrings(sorted))
+
+	p.Cursor = ""
+	assert.Equal(t, sorted[42:52], p.PaginateStrings(sorted))
+}
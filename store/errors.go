@@ -25,4 +25,7 @@ var (
 	ErrUniqueMapEntry          = errors.New("unique map entry is set and map already has an initial link")
 	ErrReferencingNotSupported = errors.New("filtering on referencing segments is not supported by the current implementation")
 	ErrBatchFailed             = errors.New("cannot add to batch: failures have been detected")
+	ErrInvalidCursor           = errors.New("cursor is invalid or was not generated by this store")
+	ErrInvalidDataPredicate    = errors.New("link data predicate is invalid")
+	ErrDataFilterNotSupported  = errors.New("filtering on link data is not supported by the current implementation")
 )
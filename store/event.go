@@ -36,6 +36,10 @@ const (
 type Event struct {
 	EventType EventType
 	Data      interface{}
+
+	// Sequence is the position of the event in the store's event log.
+	// It is zero when the store doesn't keep an event log.
+	Sequence uint64 `json:",omitempty"`
 }
 
 // NewSavedLinks creates a new event to notify links were saved.
@@ -73,11 +77,50 @@ func (event *Event) AddSavedEvidence(linkHash chainscript.LinkHash, e *chainscri
 	event.Data = evidencesData
 }
 
+// Filter returns a copy of the event that only contains the links matching
+// the given function, or the evidences of those links. The links of the
+// evidences must be given since evidence events don't contain them.
+// It returns nil if nothing matches. Events of other types are returned
+// unchanged.
+func (event *Event) Filter(match func(*chainscript.Link) bool, links map[string]*chainscript.Link) *Event {
+	switch data := event.Data.(type) {
+	case []*chainscript.Link:
+		var matched []*chainscript.Link
+		for _, link := range data {
+			if match(link) {
+				matched = append(matched, link)
+			}
+		}
+
+		if len(matched) == 0 {
+			return nil
+		}
+
+		return &Event{EventType: event.EventType, Data: matched, Sequence: event.Sequence}
+	case map[string]*chainscript.Evidence:
+		matched := map[string]*chainscript.Evidence{}
+		for linkHash, evidence := range data {
+			if link, ok := links[linkHash]; ok && match(link) {
+				matched[linkHash] = evidence
+			}
+		}
+
+		if len(matched) == 0 {
+			return nil
+		}
+
+		return &Event{EventType: event.EventType, Data: matched, Sequence: event.Sequence}
+	default:
+		return event
+	}
+}
+
 // UnmarshalJSON does custom deserialization to correctly type the Data field.
 func (event *Event) UnmarshalJSON(b []byte) error {
 	partial := struct {
 		EventType EventType
 		Data      json.RawMessage
+		Sequence  uint64
 	}{}
 
 	if err := json.Unmarshal(b, &partial); err != nil {
@@ -103,6 +146,7 @@ func (event *Event) UnmarshalJSON(b []byte) error {
 	*event = Event{
 		EventType: partial.EventType,
 		Data:      data,
+		Sequence:  partial.Sequence,
 	}
 
 	return nil
@@ -0,0 +1,116 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stratumn/go-chainscript/chainscripttest"
+	"github.com/stratumn/go-core/dummystore"
+	"github.com/stratumn/go-core/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testEventExporter struct {
+	eventsLock sync.RWMutex
+	events     []*store.Event
+
+	// blocked, if set, makes Push wait until it is closed.
+	blocked chan struct{}
+}
+
+func (e *testEventExporter) Push(_ context.Context, event *store.Event) error {
+	if e.blocked != nil {
+		<-e.blocked
+	}
+
+	e.eventsLock.Lock()
+	defer e.eventsLock.Unlock()
+
+	e.events = append(e.events, event)
+	return nil
+}
+
+func (e *testEventExporter) eventsCount() int {
+	e.eventsLock.RLock()
+	defer e.eventsLock.RUnlock()
+
+	return len(e.events)
+}
+
+// listenedStore signals when an event channel is added.
+type listenedStore struct {
+	*dummystore.DummyStore
+	listened chan struct{}
+}
+
+func (s *listenedStore) AddStoreEventChannel(c chan *store.Event) {
+	s.DummyStore.AddStoreEventChannel(c)
+	close(s.listened)
+}
+
+func TestRunExporter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	exporter := &testEventExporter{}
+	endChan := make(chan struct{})
+	a := &listenedStore{DummyStore: dummystore.New(&dummystore.Config{}), listened: make(chan struct{})}
+	go func() {
+		store.RunExporter(ctx, a, exporter)
+		endChan <- struct{}{}
+	}()
+
+	<-a.listened
+
+	_, err := a.CreateLink(ctx, chainscripttest.RandomLink(t))
+	require.NoError(t, err)
+
+	for exporter.eventsCount() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	require.Len(t, exporter.events, 1)
+	assert.Equal(t, store.SavedLinks, exporter.events[0].EventType)
+
+	cancel()
+	<-endChan
+}
+
+func TestRunExporter_SlowExporter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	exporter := &testEventExporter{blocked: make(chan struct{})}
+	a := &listenedStore{DummyStore: dummystore.New(&dummystore.Config{}), listened: make(chan struct{})}
+	go store.RunExporter(ctx, a, exporter)
+
+	<-a.listened
+
+	// Writes must not wait for the exporter.
+	for i := 0; i < 10; i++ {
+		_, err := a.CreateLink(ctx, chainscripttest.RandomLink(t))
+		require.NoError(t, err)
+	}
+
+	close(exporter.blocked)
+
+	for exporter.eventsCount() < 10 {
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,235 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"context"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-core/types"
+)
+
+// GraphReader is the interface implemented by stores that can traverse the
+// graph of the links of a map natively.
+//
+// The graph of a map is a DAG: there is an edge from a link to its parent
+// and to each link of the same map it references. References to other maps
+// are ignored.
+//
+// Results are sorted like the results of FindSegments and don't include the
+// starting link. A depth lower or equal to zero means no limit.
+// Traversing from a link that doesn't exist returns no segments.
+type GraphReader interface {
+	// GetAncestors returns the links that can be reached from the given link
+	// by following at most depth edges.
+	GetAncestors(ctx context.Context, linkHash chainscript.LinkHash, depth int) (types.SegmentSlice, error)
+
+	// GetDescendants returns the links from which the given link can be
+	// reached by following at most depth edges.
+	GetDescendants(ctx context.Context, linkHash chainscript.LinkHash, depth int) (types.SegmentSlice, error)
+
+	// GetMapHeads returns the links of a map that have no children and aren't
+	// referenced by other links of the map.
+	GetMapHeads(ctx context.Context, process, mapID string) (types.SegmentSlice, error)
+}
+
+// GetAncestors returns the ancestors of a link (see GraphReader).
+// It uses the reader's native implementation if it has one, otherwise it
+// loads the whole map of the link.
+func GetAncestors(ctx context.Context, reader SegmentReader, linkHash chainscript.LinkHash, depth int) (types.SegmentSlice, error) {
+	if graphReader, ok := reader.(GraphReader); ok {
+		return graphReader.GetAncestors(ctx, linkHash, depth)
+	}
+
+	graph, err := loadLinkMapGraph(ctx, reader, linkHash)
+	if err != nil || graph == nil {
+		return nil, err
+	}
+
+	return graph.Ancestors(linkHash, depth), nil
+}
+
+// GetDescendants returns the descendants of a link (see GraphReader).
+// It uses the reader's native implementation if it has one, otherwise it
+// loads the whole map of the link.
+func GetDescendants(ctx context.Context, reader SegmentReader, linkHash chainscript.LinkHash, depth int) (types.SegmentSlice, error) {
+	if graphReader, ok := reader.(GraphReader); ok {
+		return graphReader.GetDescendants(ctx, linkHash, depth)
+	}
+
+	graph, err := loadLinkMapGraph(ctx, reader, linkHash)
+	if err != nil || graph == nil {
+		return nil, err
+	}
+
+	return graph.Descendants(linkHash, depth), nil
+}
+
+// GetMapHeads returns the heads of a map (see GraphReader).
+// It uses the reader's native implementation if it has one, otherwise it
+// loads the whole map.
+func GetMapHeads(ctx context.Context, reader SegmentReader, process, mapID string) (types.SegmentSlice, error) {
+	if graphReader, ok := reader.(GraphReader); ok {
+		return graphReader.GetMapHeads(ctx, process, mapID)
+	}
+
+	graph, err := LoadMapGraph(ctx, reader, process, mapID)
+	if err != nil {
+		return nil, err
+	}
+
+	return graph.Heads(), nil
+}
+
+// LoadMapGraph loads all the segments of a map and builds its graph.
+func LoadMapGraph(ctx context.Context, reader SegmentReader, process, mapID string) (*MapGraph, error) {
+	it, err := IterateSegments(ctx, reader, &SegmentFilter{
+		Process: process,
+		MapIDs:  []string{mapID},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var segments types.SegmentSlice
+	for it.Next() {
+		segments = append(segments, it.Segment())
+	}
+
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	return NewMapGraph(segments), nil
+}
+
+// loadLinkMapGraph loads the graph of the map containing the given link.
+// It returns nil if the link doesn't exist.
+func loadLinkMapGraph(ctx context.Context, reader SegmentReader, linkHash chainscript.LinkHash) (*MapGraph, error) {
+	segment, err := reader.GetSegment(ctx, linkHash)
+	if err != nil || segment == nil {
+		return nil, err
+	}
+
+	return LoadMapGraph(ctx, reader, segment.Link.Meta.Process.Name, segment.Link.Meta.MapId)
+}
+
+// MapGraph is the graph of the links of a map (see GraphReader).
+type MapGraph struct {
+	segments map[string]*chainscript.Segment
+	parents  map[string][]string
+	children map[string][]string
+}
+
+// NewMapGraph creates the graph of the given segments, which should all
+// belong to the same map.
+func NewMapGraph(segments types.SegmentSlice) *MapGraph {
+	g := &MapGraph{
+		segments: make(map[string]*chainscript.Segment, len(segments)),
+		parents:  make(map[string][]string),
+		children: make(map[string][]string),
+	}
+
+	for _, segment := range segments {
+		g.segments[segment.LinkHash().String()] = segment
+	}
+
+	for lh, segment := range g.segments {
+		targets := make(map[string]struct{})
+		if prevLinkHash := segment.Link.PrevLinkHash(); len(prevLinkHash) > 0 {
+			targets[prevLinkHash.String()] = struct{}{}
+		}
+		for _, ref := range segment.Link.Meta.Refs {
+			targets[chainscript.LinkHash(ref.LinkHash).String()] = struct{}{}
+		}
+
+		for target := range targets {
+			if _, ok := g.segments[target]; ok {
+				g.parents[lh] = append(g.parents[lh], target)
+				g.children[target] = append(g.children[target], lh)
+			}
+		}
+	}
+
+	return g
+}
+
+// Segments returns all the segments of the graph.
+func (g *MapGraph) Segments() types.SegmentSlice {
+	segments := make(types.SegmentSlice, 0, len(g.segments))
+	for _, segment := range g.segments {
+		segments = append(segments, segment)
+	}
+
+	segments.Sort(false)
+	return segments
+}
+
+// Ancestors returns the ancestors of a link (see GraphReader).
+func (g *MapGraph) Ancestors(linkHash chainscript.LinkHash, depth int) types.SegmentSlice {
+	return g.traverse(linkHash.String(), g.parents, depth)
+}
+
+// Descendants returns the descendants of a link (see GraphReader).
+func (g *MapGraph) Descendants(linkHash chainscript.LinkHash, depth int) types.SegmentSlice {
+	return g.traverse(linkHash.String(), g.children, depth)
+}
+
+// Heads returns the links that have no children nor are referenced (see
+// GraphReader).
+func (g *MapGraph) Heads() types.SegmentSlice {
+	var heads types.SegmentSlice
+	for lh, segment := range g.segments {
+		if len(g.children[lh]) == 0 {
+			heads = append(heads, segment)
+		}
+	}
+
+	heads.Sort(false)
+	return heads
+}
+
+// traverse does a breadth-first traversal of the graph following the given
+// edges.
+func (g *MapGraph) traverse(start string, edges map[string][]string, depth int) types.SegmentSlice {
+	if _, ok := g.segments[start]; !ok {
+		return nil
+	}
+
+	visited := map[string]struct{}{start: {}}
+	frontier := []string{start}
+	var segments types.SegmentSlice
+
+	for level := 0; len(frontier) > 0 && (depth <= 0 || level < depth); level++ {
+		var next []string
+		for _, lh := range frontier {
+			for _, target := range edges[lh] {
+				if _, ok := visited[target]; ok {
+					continue
+				}
+
+				visited[target] = struct{}{}
+				segments = append(segments, g.segments[target])
+				next = append(next, target)
+			}
+		}
+
+		frontier = next
+	}
+
+	segments.Sort(false)
+	return segments
+}
@@ -0,0 +1,151 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-chainscript/chainscripttest"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/store/storetesting"
+	"github.com/stratumn/go-core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testMapGraph creates a map where D has B as parent and references C and a
+// link of another map:
+//
+//	  A
+//	 / \
+//	B   C
+//	 \ /
+//	  D
+func testMapGraph(t *testing.T) (types.SegmentSlice, map[string]*chainscript.Segment) {
+	other := chainscripttest.NewLinkBuilder(t).WithRandomData().Build()
+	a := chainscripttest.NewLinkBuilder(t).WithRandomData().WithoutParent().Build()
+	b := chainscripttest.NewLinkBuilder(t).WithRandomData().Branch(t, a).Build()
+	c := chainscripttest.NewLinkBuilder(t).WithRandomData().Branch(t, a).Build()
+	d := chainscripttest.NewLinkBuilder(t).WithRandomData().Branch(t, b).WithRef(t, c).WithRef(t, other).Build()
+
+	named := make(map[string]*chainscript.Segment)
+	var segments types.SegmentSlice
+	for name, l := range map[string]*chainscript.Link{"A": a, "B": b, "C": c, "D": d} {
+		s, err := l.Segmentify()
+		require.NoError(t, err)
+		named[name] = s
+		segments = append(segments, s)
+	}
+
+	return segments, named
+}
+
+func sortedSegments(segments ...*chainscript.Segment) types.SegmentSlice {
+	sorted := types.SegmentSlice(segments)
+	sorted.Sort(false)
+	return sorted
+}
+
+func TestMapGraph(t *testing.T) {
+	segments, s := testMapGraph(t)
+	g := store.NewMapGraph(segments)
+
+	assert.Equal(t, sortedSegments(segments...), g.Segments())
+	assert.Equal(t, sortedSegments(s["D"]), g.Heads())
+
+	assert.Equal(t, sortedSegments(s["A"], s["B"], s["C"]), g.Ancestors(s["D"].LinkHash(), 0))
+	assert.Equal(t, sortedSegments(s["B"], s["C"]), g.Ancestors(s["D"].LinkHash(), 1))
+	assert.Empty(t, g.Ancestors(s["A"].LinkHash(), 0))
+
+	assert.Equal(t, sortedSegments(s["B"], s["C"], s["D"]), g.Descendants(s["A"].LinkHash(), 0))
+	assert.Equal(t, sortedSegments(s["B"], s["C"]), g.Descendants(s["A"].LinkHash(), 1))
+	assert.Equal(t, sortedSegments(s["D"]), g.Descendants(s["C"].LinkHash(), -1))
+
+	assert.Empty(t, g.Descendants(chainscripttest.RandomHash(), 0))
+}
+
+type graphAdapter struct {
+	*storetesting.MockAdapter
+}
+
+func (a graphAdapter) GetAncestors(context.Context, chainscript.LinkHash, int) (types.SegmentSlice, error) {
+	return types.SegmentSlice{}, nil
+}
+
+func (a graphAdapter) GetDescendants(context.Context, chainscript.LinkHash, int) (types.SegmentSlice, error) {
+	return types.SegmentSlice{}, nil
+}
+
+func (a graphAdapter) GetMapHeads(context.Context, string, string) (types.SegmentSlice, error) {
+	return types.SegmentSlice{}, nil
+}
+
+func TestGraphReader(t *testing.T) {
+	segments, s := testMapGraph(t)
+	ctx := context.Background()
+
+	t.Run("uses native implementation", func(t *testing.T) {
+		a := graphAdapter{&storetesting.MockAdapter{}}
+
+		ancestors, err := store.GetAncestors(ctx, a, s["D"].LinkHash(), 0)
+		require.NoError(t, err)
+		assert.Empty(t, ancestors)
+
+		heads, err := store.GetMapHeads(ctx, a, "p", "m")
+		require.NoError(t, err)
+		assert.Empty(t, heads)
+
+		assert.Zero(t, a.MockGetSegment.CalledCount)
+		assert.Zero(t, a.MockFindSegments.CalledCount)
+	})
+
+	t.Run("falls back to loading the map", func(t *testing.T) {
+		a := &storetesting.MockAdapter{}
+		a.MockGetSegment.Fn = func(linkHash chainscript.LinkHash) (*chainscript.Segment, error) {
+			for _, segment := range segments {
+				if segment.LinkHash().String() == linkHash.String() {
+					return segment, nil
+				}
+			}
+			return nil, nil
+		}
+		a.MockFindSegments.Fn = func(filter *store.SegmentFilter) (*types.PaginatedSegments, error) {
+			assert.Equal(t, s["A"].Link.Meta.Process.Name, filter.Process)
+			assert.Equal(t, []string{s["A"].Link.Meta.MapId}, filter.MapIDs)
+			return filter.PaginateSegments(&types.PaginatedSegments{
+				Segments:   sortedSegments(segments...),
+				TotalCount: len(segments),
+			})
+		}
+
+		ancestors, err := store.GetAncestors(ctx, a, s["D"].LinkHash(), 1)
+		require.NoError(t, err)
+		assert.Equal(t, sortedSegments(s["B"], s["C"]), ancestors)
+
+		descendants, err := store.GetDescendants(ctx, a, s["B"].LinkHash(), 0)
+		require.NoError(t, err)
+		assert.Equal(t, sortedSegments(s["D"]), descendants)
+
+		heads, err := store.GetMapHeads(ctx, a, s["A"].Link.Meta.Process.Name, s["A"].Link.Meta.MapId)
+		require.NoError(t, err)
+		assert.Equal(t, sortedSegments(s["D"]), heads)
+
+		missing, err := store.GetDescendants(ctx, a, chainscripttest.RandomHash(), 0)
+		require.NoError(t, err)
+		assert.Empty(t, missing)
+	})
+}
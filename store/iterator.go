@@ -0,0 +1,162 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"context"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-core/types"
+)
+
+// SegmentIterator streams segments one at a time.
+// It should be used instead of FindSegments to go through large result sets
+// with bounded memory:
+//
+//	it, err := store.IterateSegments(ctx, adapter, filter)
+//	if err != nil {
+//		return err
+//	}
+//	defer it.Close()
+//
+//	for it.Next() {
+//		process(it.Segment())
+//	}
+//
+//	return it.Err()
+type SegmentIterator interface {
+	// Next moves to the next segment.
+	// It returns false when there are no more segments or when an error
+	// occurred (in which case Err returns it).
+	Next() bool
+
+	// Segment returns the current segment.
+	Segment() *chainscript.Segment
+
+	// Err returns the error that stopped the iteration, if any.
+	Err() error
+
+	// Close releases the resources held by the iterator.
+	// It must be called even if the iteration didn't complete.
+	Close() error
+}
+
+// SegmentIterable is the interface implemented by stores that can stream
+// segments natively.
+// Some stores can't offer this feature, so you should use IterateSegments
+// which falls back to paginating through FindSegments.
+type SegmentIterable interface {
+	// IterateSegments returns an iterator over all the segments matching the
+	// filter, in the order defined by the filter.
+	// If the filter has a cursor, the iteration starts after it.
+	// The offset and limit are ignored.
+	IterateSegments(ctx context.Context, filter *SegmentFilter) (SegmentIterator, error)
+}
+
+// IterateSegments returns an iterator over all the segments matching the
+// filter (see SegmentIterable).
+// It uses the reader's native implementation if it has one, otherwise it
+// fetches pages of MaxLimit segments with FindSegments.
+func IterateSegments(ctx context.Context, reader SegmentReader, filter *SegmentFilter) (SegmentIterator, error) {
+	if iterable, ok := reader.(SegmentIterable); ok {
+		return iterable.IterateSegments(ctx, filter)
+	}
+
+	return NewPaginatedSegmentIterator(ctx, reader, filter), nil
+}
+
+// PaginatedSegmentIterator implements SegmentIterator on top of
+// FindSegments using cursor pagination.
+// Only one page of segments is kept in memory.
+type PaginatedSegmentIterator struct {
+	ctx    context.Context
+	reader SegmentReader
+	filter SegmentFilter
+
+	page    types.SegmentSlice
+	current int
+	done    bool
+	err     error
+}
+
+// NewPaginatedSegmentIterator creates an iterator that fetches the segments
+// matching the filter page by page.
+func NewPaginatedSegmentIterator(ctx context.Context, reader SegmentReader, filter *SegmentFilter) *PaginatedSegmentIterator {
+	f := *filter
+	f.Pagination = Pagination{
+		Limit:  MaxLimit,
+		Cursor: filter.Cursor,
+	}
+
+	return &PaginatedSegmentIterator{
+		ctx:     ctx,
+		reader:  reader,
+		filter:  f,
+		current: -1,
+	}
+}
+
+// Next implements github.com/stratumn/go-core/store.SegmentIterator.Next.
+func (it *PaginatedSegmentIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	if it.current+1 < len(it.page) {
+		it.current++
+		return true
+	}
+
+	if it.done {
+		it.page = nil
+		return false
+	}
+
+	if it.err = it.ctx.Err(); it.err != nil {
+		return false
+	}
+
+	segments, err := it.reader.FindSegments(it.ctx, &it.filter)
+	if err != nil {
+		it.err = err
+		return false
+	}
+
+	it.page, it.current = segments.Segments, 0
+	it.filter.Cursor = segments.NextCursor
+	it.done = segments.NextCursor == ""
+
+	return len(it.page) > 0
+}
+
+// Segment implements github.com/stratumn/go-core/store.SegmentIterator.Segment.
+func (it *PaginatedSegmentIterator) Segment() *chainscript.Segment {
+	if it.current < 0 || it.current >= len(it.page) {
+		return nil
+	}
+
+	return it.page[it.current]
+}
+
+// Err implements github.com/stratumn/go-core/store.SegmentIterator.Err.
+func (it *PaginatedSegmentIterator) Err() error {
+	return it.err
+}
+
+// Close implements github.com/stratumn/go-core/store.SegmentIterator.Close.
+func (it *PaginatedSegmentIterator) Close() error {
+	it.page, it.done = nil, true
+	return nil
+}
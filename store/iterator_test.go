@@ -0,0 +1,139 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/store/storetesting"
+	"github.com/stratumn/go-core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type iterableAdapter struct {
+	*storetesting.MockAdapter
+	it store.SegmentIterator
+}
+
+func (a *iterableAdapter) IterateSegments(context.Context, *store.SegmentFilter) (store.SegmentIterator, error) {
+	return a.it, nil
+}
+
+func TestIterateSegments(t *testing.T) {
+	t.Run("uses native implementation", func(t *testing.T) {
+		native := store.NewPaginatedSegmentIterator(context.Background(), &storetesting.MockAdapter{}, &store.SegmentFilter{})
+		a := &iterableAdapter{MockAdapter: &storetesting.MockAdapter{}, it: native}
+
+		it, err := store.IterateSegments(context.Background(), a, &store.SegmentFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, native, it)
+	})
+
+	t.Run("falls back to pagination", func(t *testing.T) {
+		it, err := store.IterateSegments(context.Background(), &storetesting.MockAdapter{}, &store.SegmentFilter{})
+		require.NoError(t, err)
+		assert.IsType(t, &store.PaginatedSegmentIterator{}, it)
+	})
+}
+
+func TestPaginatedSegmentIterator(t *testing.T) {
+	count := 2*store.MaxLimit + 13
+
+	for _, reverse := range []bool{false, true} {
+		all := sortedTestingSegments(t, count, reverse)
+		a := &storetesting.MockAdapter{}
+		a.MockFindSegments.Fn = func(filter *store.SegmentFilter) (*types.PaginatedSegments, error) {
+			assert.Equal(t, store.MaxLimit, filter.Limit)
+			assert.Zero(t, filter.Offset)
+			assert.Equal(t, reverse, filter.Reverse)
+			return filter.PaginateSegments(all)
+		}
+
+		it := store.NewPaginatedSegmentIterator(
+			context.Background(),
+			a,
+			&store.SegmentFilter{
+				Pagination: store.Pagination{Offset: 3, Limit: 5},
+				Reverse:    reverse,
+			},
+		)
+
+		var got types.SegmentSlice
+		for it.Next() {
+			got = append(got, it.Segment())
+		}
+
+		require.NoError(t, it.Err())
+		require.NoError(t, it.Close())
+		assert.Equal(t, all.Segments, got, "reverse: %v", reverse)
+		assert.Equal(t, 3, a.MockFindSegments.CalledCount)
+		assert.False(t, it.Next())
+		assert.Nil(t, it.Segment())
+	}
+
+	t.Run("starts after the cursor", func(t *testing.T) {
+		all := sortedTestingSegments(t, sliceSize, false)
+		a := &storetesting.MockAdapter{}
+		a.MockFindSegments.Fn = func(filter *store.SegmentFilter) (*types.PaginatedSegments, error) {
+			return filter.PaginateSegments(all)
+		}
+
+		it := store.NewPaginatedSegmentIterator(
+			context.Background(),
+			a,
+			&store.SegmentFilter{
+				Pagination: store.Pagination{
+					Cursor: store.NewSegmentCursor(all.Segments[41]).String(),
+				},
+			},
+		)
+		defer it.Close()
+
+		require.True(t, it.Next())
+		assert.Equal(t, all.Segments[42], it.Segment())
+	})
+
+	t.Run("stops on error", func(t *testing.T) {
+		a := &storetesting.MockAdapter{}
+		a.MockFindSegments.Fn = func(filter *store.SegmentFilter) (*types.PaginatedSegments, error) {
+			return nil, errors.New("no")
+		}
+
+		it := store.NewPaginatedSegmentIterator(context.Background(), a, &store.SegmentFilter{})
+		defer it.Close()
+
+		assert.False(t, it.Next())
+		assert.EqualError(t, it.Err(), "no")
+		assert.False(t, it.Next())
+		assert.Equal(t, 1, a.MockFindSegments.CalledCount)
+	})
+
+	t.Run("stops when context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		a := &storetesting.MockAdapter{}
+		it := store.NewPaginatedSegmentIterator(ctx, a, &store.SegmentFilter{})
+		defer it.Close()
+
+		assert.False(t, it.Next())
+		assert.Equal(t, context.Canceled, it.Err())
+		assert.Zero(t, a.MockFindSegments.CalledCount)
+	})
+}
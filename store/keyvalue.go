@@ -0,0 +1,42 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"context"
+)
+
+// PrefixEnd returns the smallest key that is greater than all the keys
+// starting with the given prefix, or nil if there is no such key (when the
+// prefix is empty or only contains 0xff bytes).
+func PrefixEnd(prefix []byte) []byte {
+	end := make([]byte, len(prefix))
+	copy(end, prefix)
+
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+
+	return nil
+}
+
+// IterateValuesWithPrefix calls fn for every key starting with the given
+// prefix, in ascending bytewise order.
+func IterateValuesWithPrefix(ctx context.Context, it KeyValueIterator, prefix []byte, fn func(key, value []byte) error) error {
+	return it.IterateValues(ctx, prefix, PrefixEnd(prefix), fn)
+}
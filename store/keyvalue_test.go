@@ -0,0 +1,92 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stratumn/go-core/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mapKeyValueStore is a minimal in-memory key-value iterator.
+type mapKeyValueStore struct {
+	values map[string][]byte
+}
+
+func (kv *mapKeyValueStore) IterateValues(ctx context.Context, start, end []byte, fn func(key, value []byte) error) error {
+	var keys []string
+	for k := range kv.values {
+		if k >= string(start) && (end == nil || k < string(end)) {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if err := fn([]byte(k), kv.values[k]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func TestPrefixEnd(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix []byte
+		end    []byte
+	}{
+		{"empty", nil, nil},
+		{"simple", []byte("abc"), []byte("abd")},
+		{"trailing 0xff", []byte{0x01, 0xff, 0xff}, []byte{0x02}},
+		{"only 0xff", []byte{0xff, 0xff}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.end, store.PrefixEnd(tt.prefix))
+		})
+	}
+
+	t.Run("does not modify prefix", func(t *testing.T) {
+		prefix := []byte("abc")
+		store.PrefixEnd(prefix)
+		assert.Equal(t, []byte("abc"), prefix)
+	})
+}
+
+func TestIterateValuesWithPrefix(t *testing.T) {
+	kv := &mapKeyValueStore{values: map[string][]byte{
+		"a":    []byte("0"),
+		"ab":   []byte("1"),
+		"ab\n": []byte("2"),
+		"abc":  []byte("3"),
+		"ac":   []byte("4"),
+	}}
+
+	var keys []string
+	err := store.IterateValuesWithPrefix(context.Background(), kv, []byte("ab"), func(key, value []byte) error {
+		keys = append(keys, string(key))
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ab", "ab\n", "abc"}, keys)
+}
@@ -0,0 +1,215 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"sort"
+
+	"github.com/stratumn/go-chainscript"
+)
+
+// LinkDependencies returns the hashes of the links a link depends on: its
+// parent and the links it references.
+func LinkDependencies(link *chainscript.Link) []chainscript.LinkHash {
+	var deps []chainscript.LinkHash
+
+	if prev := link.PrevLinkHash(); len(prev) > 0 {
+		deps = append(deps, prev)
+	}
+
+	for _, ref := range link.Meta.Refs {
+		deps = append(deps, chainscript.LinkHash(ref.LinkHash))
+	}
+
+	return deps
+}
+
+// TopologicalSorter receives segments in any order and emits them after the
+// segments they depend on (see LinkDependencies).
+// Segments are emitted as soon as possible, so only segments waiting for a
+// dependency are kept in memory, along with the hashes of the emitted
+// segments unless a lookup function is given.
+type TopologicalSorter struct {
+	emit    func(*chainscript.Segment) error
+	lookup  func(chainscript.LinkHash) (bool, error)
+	emitted map[string]struct{}
+	pending map[string]*sortedSegment
+	waiting map[string][]*sortedSegment
+}
+
+// sortedSegment is a segment waiting for some of its dependencies to be
+// emitted.
+type sortedSegment struct {
+	segment *chainscript.Segment
+	missing int
+	emitted bool
+}
+
+// NewTopologicalSorter creates a sorter that calls emit for every segment.
+func NewTopologicalSorter(emit func(*chainscript.Segment) error) *TopologicalSorter {
+	return &TopologicalSorter{
+		emit:    emit,
+		emitted: make(map[string]struct{}),
+		pending: make(map[string]*sortedSegment),
+		waiting: make(map[string][]*sortedSegment),
+	}
+}
+
+// NewTopologicalSorterWithLookup creates a sorter that calls emit for every
+// segment and calls lookup to know whether a dependency has already been
+// emitted (for instance by looking for it in the store the segments are
+// written to), instead of remembering every emitted segment.
+// Segments added again after being emitted are emitted again.
+func NewTopologicalSorterWithLookup(
+	emit func(*chainscript.Segment) error,
+	lookup func(chainscript.LinkHash) (bool, error),
+) *TopologicalSorter {
+	s := NewTopologicalSorter(emit)
+	s.lookup = lookup
+	s.emitted = nil
+	return s
+}
+
+// isEmitted returns whether the segment with the given link hash has already
+// been emitted.
+func (s *TopologicalSorter) isEmitted(linkHash chainscript.LinkHash) (bool, error) {
+	if s.lookup == nil {
+		_, ok := s.emitted[linkHash.String()]
+		return ok, nil
+	}
+
+	if _, ok := s.pending[linkHash.String()]; ok {
+		return false, nil
+	}
+
+	return s.lookup(linkHash)
+}
+
+// Add adds a segment. It is emitted right away if its dependencies have
+// already been emitted, along with the segments that were only waiting for
+// it.
+func (s *TopologicalSorter) Add(segment *chainscript.Segment) error {
+	linkHash := segment.LinkHash().String()
+	if _, ok := s.emitted[linkHash]; ok {
+		return nil
+	}
+
+	if _, ok := s.pending[linkHash]; ok {
+		return nil
+	}
+
+	p := &sortedSegment{segment: segment}
+
+	for _, dep := range LinkDependencies(segment.Link) {
+		depStr := dep.String()
+		if depStr == linkHash {
+			continue
+		}
+
+		emitted, err := s.isEmitted(dep)
+		if err != nil {
+			return err
+		}
+		if emitted {
+			continue
+		}
+
+		s.waiting[depStr] = append(s.waiting[depStr], p)
+		p.missing++
+	}
+
+	if p.missing > 0 {
+		s.pending[linkHash] = p
+		return nil
+	}
+
+	return s.release(p)
+}
+
+// Pending returns the number of segments waiting for a dependency.
+func (s *TopologicalSorter) Pending() int {
+	return len(s.pending)
+}
+
+// IsPending returns whether the segment with the given link hash is waiting
+// for a dependency.
+func (s *TopologicalSorter) IsPending(linkHash chainscript.LinkHash) bool {
+	_, ok := s.pending[linkHash.String()]
+	return ok
+}
+
+// release emits a segment and the segments that were only waiting for it.
+func (s *TopologicalSorter) release(p *sortedSegment) error {
+	queue := []*sortedSegment{p}
+
+	for len(queue) > 0 {
+		p, queue = queue[0], queue[1:]
+		if p.emitted {
+			continue
+		}
+
+		if err := s.emit(p.segment); err != nil {
+			return err
+		}
+
+		p.emitted = true
+		linkHash := p.segment.LinkHash().String()
+		if s.lookup == nil {
+			s.emitted[linkHash] = struct{}{}
+		}
+		delete(s.pending, linkHash)
+
+		for _, child := range s.waiting[linkHash] {
+			child.missing--
+			if child.missing == 0 {
+				queue = append(queue, child)
+			}
+		}
+
+		delete(s.waiting, linkHash)
+	}
+
+	return nil
+}
+
+// Flush emits the segments that are still waiting.
+// It must be called once all the segments have been added: dependencies
+// that haven't been added by then are considered absent, so segments stop
+// waiting for them.
+func (s *TopologicalSorter) Flush() error {
+	absent := make([]string, 0, len(s.waiting))
+	for linkHash := range s.waiting {
+		if _, ok := s.pending[linkHash]; !ok {
+			absent = append(absent, linkHash)
+		}
+	}
+
+	sort.Strings(absent)
+
+	for _, linkHash := range absent {
+		for _, p := range s.waiting[linkHash] {
+			p.missing--
+			if p.missing == 0 {
+				if err := s.release(p); err != nil {
+					return err
+				}
+			}
+		}
+
+		delete(s.waiting, linkHash)
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store_test
+
+import (
+	"testing"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-chainscript/chainscripttest"
+	"github.com/stratumn/go-core/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopologicalSorter(t *testing.T) {
+	root := chainscripttest.NewLinkBuilder(t).WithRandomData().Segmentify(t)
+	child := chainscripttest.NewLinkBuilder(t).WithRandomData().Branch(t, root.Link).Segmentify(t)
+	grandChild := chainscripttest.NewLinkBuilder(t).WithRandomData().Branch(t, child.Link).WithRef(t, root.Link).Segmentify(t)
+	orphan := chainscripttest.NewLinkBuilder(t).WithRandomData().WithParentHash(chainscripttest.RandomHash()).Segmentify(t)
+	referencing := chainscripttest.NewLinkBuilder(t).WithRandomData().WithRef(t, orphan.Link).Segmentify(t)
+
+	var emitted []*chainscript.Segment
+	sorter := store.NewTopologicalSorter(func(s *chainscript.Segment) error {
+		emitted = append(emitted, s)
+		return nil
+	})
+
+	for _, s := range []*chainscript.Segment{referencing, grandChild, orphan, child, root, root} {
+		require.NoError(t, sorter.Add(s))
+	}
+
+	// The orphan's parent isn't known yet.
+	assert.Equal(t, []*chainscript.Segment{root, child, grandChild}, emitted)
+	assert.Equal(t, 2, sorter.Pending())
+
+	require.NoError(t, sorter.Flush())
+	assert.Equal(t, []*chainscript.Segment{root, child, grandChild, orphan, referencing}, emitted)
+	assert.Equal(t, 0, sorter.Pending())
+}
+
+func TestTopologicalSorter_lookup(t *testing.T) {
+	root := chainscripttest.NewLinkBuilder(t).WithRandomData().Segmentify(t)
+	child := chainscripttest.NewLinkBuilder(t).WithRandomData().Branch(t, root.Link).Segmentify(t)
+	grandChild := chainscripttest.NewLinkBuilder(t).WithRandomData().Branch(t, child.Link).Segmentify(t)
+
+	// The root has been emitted by a previous sorter.
+	written := map[string]bool{root.LinkHash().String(): true}
+
+	var emitted []*chainscript.Segment
+	sorter := store.NewTopologicalSorterWithLookup(
+		func(s *chainscript.Segment) error {
+			emitted = append(emitted, s)
+			written[s.LinkHash().String()] = true
+			return nil
+		},
+		func(linkHash chainscript.LinkHash) (bool, error) {
+			return written[linkHash.String()], nil
+		},
+	)
+
+	require.NoError(t, sorter.Add(grandChild))
+	assert.True(t, sorter.IsPending(grandChild.LinkHash()))
+
+	require.NoError(t, sorter.Add(child))
+	assert.Equal(t, []*chainscript.Segment{child, grandChild}, emitted)
+	assert.False(t, sorter.IsPending(grandChild.LinkHash()))
+	assert.Equal(t, 0, sorter.Pending())
+}
+
+func TestLinkDependencies(t *testing.T) {
+	parent := chainscripttest.RandomLink(t)
+	ref := chainscripttest.RandomLink(t)
+	link := chainscripttest.NewLinkBuilder(t).WithParent(t, parent).WithRef(t, ref).Build()
+
+	parentHash, _ := parent.Hash()
+	refHash, _ := ref.Hash()
+
+	assert.Equal(t, []chainscript.LinkHash{parentHash, refHash}, store.LinkDependencies(link))
+	assert.Nil(t, store.LinkDependencies(parent))
+}
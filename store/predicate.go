@@ -0,0 +1,201 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/types"
+)
+
+// DataOperator is the operator of a link data predicate.
+type DataOperator string
+
+// Operators supported by link data predicates.
+const (
+	// DataEqual matches when the value at the path equals Value.
+	DataEqual DataOperator = "eq"
+
+	// DataIn matches when the value at the path equals one of Values.
+	DataIn DataOperator = "in"
+
+	// DataRange matches when the value at the path is a number within the
+	// bounds (Gt, Gte, Lt, Lte) that are set.
+	DataRange DataOperator = "range"
+
+	// DataExists matches when the path exists (even if its value is null).
+	DataExists DataOperator = "exists"
+)
+
+// DataPredicate is a condition on the JSON data of a link.
+//
+// The path is a dot-separated list of object keys (or array indices), for
+// instance "order.items.0.sku". Values are JSON scalars: strings, numbers and
+// booleans. Objects and arrays can't be compared, but their existence can be
+// tested.
+type DataPredicate struct {
+	Path   string        `json:"path"`
+	Op     DataOperator  `json:"op"`
+	Value  interface{}   `json:"value,omitempty"`
+	Values []interface{} `json:"values,omitempty"`
+	Gt     *float64      `json:"gt,omitempty"`
+	Gte    *float64      `json:"gte,omitempty"`
+	Lt     *float64      `json:"lt,omitempty"`
+	Lte    *float64      `json:"lte,omitempty"`
+}
+
+// SplitDataPath splits a data predicate path into its components.
+func SplitDataPath(path string) []string {
+	return strings.Split(path, ".")
+}
+
+// NormalizeDataValue converts a predicate value to the type it would have
+// after being decoded from JSON (string, float64 or bool).
+func NormalizeDataValue(v interface{}) (interface{}, error) {
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.String:
+		return value.String(), nil
+	case reflect.Bool:
+		return value.Bool(), nil
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), nil
+	default:
+		return nil, types.WrapErrorf(ErrInvalidDataPredicate, errorcode.InvalidArgument, Component, "unsupported value %v", v)
+	}
+}
+
+// Validate checks that the predicate is well-formed.
+func (p *DataPredicate) Validate() error {
+	for _, key := range SplitDataPath(p.Path) {
+		if key == "" {
+			return types.WrapErrorf(ErrInvalidDataPredicate, errorcode.InvalidArgument, Component, "invalid path %q", p.Path)
+		}
+	}
+
+	switch p.Op {
+	case DataEqual:
+		_, err := NormalizeDataValue(p.Value)
+		return err
+	case DataIn:
+		if len(p.Values) == 0 {
+			return types.WrapError(ErrInvalidDataPredicate, errorcode.InvalidArgument, Component, "in requires values")
+		}
+		for _, v := range p.Values {
+			if _, err := NormalizeDataValue(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	case DataRange:
+		if p.Gt == nil && p.Gte == nil && p.Lt == nil && p.Lte == nil {
+			return types.WrapError(ErrInvalidDataPredicate, errorcode.InvalidArgument, Component, "range requires at least one bound")
+		}
+		return nil
+	case DataExists:
+		return nil
+	default:
+		return types.WrapErrorf(ErrInvalidDataPredicate, errorcode.InvalidArgument, Component, "unknown operator %q", p.Op)
+	}
+}
+
+// Match checks if the predicate matches decoded JSON data.
+// Invalid predicates never match.
+func (p *DataPredicate) Match(data interface{}) bool {
+	if p.Validate() != nil {
+		return false
+	}
+
+	value, ok := lookupDataPath(data, SplitDataPath(p.Path))
+	if !ok {
+		return false
+	}
+
+	switch p.Op {
+	case DataEqual:
+		expected, _ := NormalizeDataValue(p.Value)
+		return value == expected
+	case DataIn:
+		for _, v := range p.Values {
+			if expected, _ := NormalizeDataValue(v); value == expected {
+				return true
+			}
+		}
+		return false
+	case DataRange:
+		n, ok := value.(float64)
+		if !ok {
+			return false
+		}
+		return (p.Gt == nil || n > *p.Gt) &&
+			(p.Gte == nil || n >= *p.Gte) &&
+			(p.Lt == nil || n < *p.Lt) &&
+			(p.Lte == nil || n <= *p.Lte)
+	default:
+		return true
+	}
+}
+
+// lookupDataPath returns the value at the given path of decoded JSON data.
+func lookupDataPath(data interface{}, keys []string) (interface{}, bool) {
+	for _, key := range keys {
+		switch node := data.(type) {
+		case map[string]interface{}:
+			child, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			data = child
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			data = node[i]
+		default:
+			return nil, false
+		}
+	}
+
+	return data, true
+}
+
+// matchLinkData checks if the link data matches all the predicates.
+func matchLinkData(link *chainscript.Link, predicates []DataPredicate) bool {
+	if len(predicates) == 0 {
+		return true
+	}
+
+	var data interface{}
+	if err := link.StructurizeData(&data); err != nil {
+		return false
+	}
+
+	for i := range predicates {
+		if !predicates[i].Match(data) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,126 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-chainscript/chainscripttest"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func float(f float64) *float64 {
+	return &f
+}
+
+func TestDataPredicate_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		predicate store.DataPredicate
+		valid     bool
+	}{
+		{"eq", store.DataPredicate{Path: "a.b", Op: store.DataEqual, Value: "x"}, true},
+		{"eq int", store.DataPredicate{Path: "a", Op: store.DataEqual, Value: 3}, true},
+		{"eq object", store.DataPredicate{Path: "a", Op: store.DataEqual, Value: map[string]interface{}{}}, false},
+		{"eq nil", store.DataPredicate{Path: "a", Op: store.DataEqual}, false},
+		{"in", store.DataPredicate{Path: "a", Op: store.DataIn, Values: []interface{}{"x", 2.5, true}}, true},
+		{"in empty", store.DataPredicate{Path: "a", Op: store.DataIn}, false},
+		{"in array", store.DataPredicate{Path: "a", Op: store.DataIn, Values: []interface{}{[]string{"x"}}}, false},
+		{"range", store.DataPredicate{Path: "a", Op: store.DataRange, Lt: float(3)}, true},
+		{"range unbounded", store.DataPredicate{Path: "a", Op: store.DataRange}, false},
+		{"exists", store.DataPredicate{Path: "a.0", Op: store.DataExists}, true},
+		{"empty path", store.DataPredicate{Op: store.DataExists}, false},
+		{"empty key", store.DataPredicate{Path: "a..b", Op: store.DataExists}, false},
+		{"unknown op", store.DataPredicate{Path: "a", Op: "like", Value: "x"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.predicate.Validate()
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				testutil.AssertWrappedErrorEqual(t, err, store.ErrInvalidDataPredicate)
+			}
+		})
+	}
+}
+
+func TestDataPredicate_Match(t *testing.T) {
+	var data interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"status": "paid",
+		"amount": 120,
+		"express": false,
+		"note": null,
+		"items": [{"sku": "a1"}, {"sku": "b2"}]
+	}`), &data))
+
+	tests := []struct {
+		name      string
+		predicate store.DataPredicate
+		match     bool
+	}{
+		{"eq string", store.DataPredicate{Path: "status", Op: store.DataEqual, Value: "paid"}, true},
+		{"eq other string", store.DataPredicate{Path: "status", Op: store.DataEqual, Value: "new"}, false},
+		{"eq int", store.DataPredicate{Path: "amount", Op: store.DataEqual, Value: 120}, true},
+		{"eq number as string", store.DataPredicate{Path: "amount", Op: store.DataEqual, Value: "120"}, false},
+		{"eq bool", store.DataPredicate{Path: "express", Op: store.DataEqual, Value: false}, true},
+		{"eq array element", store.DataPredicate{Path: "items.1.sku", Op: store.DataEqual, Value: "b2"}, true},
+		{"eq object", store.DataPredicate{Path: "items", Op: store.DataEqual, Value: "a1"}, false},
+		{"eq missing", store.DataPredicate{Path: "customer.name", Op: store.DataEqual, Value: "x"}, false},
+		{"in", store.DataPredicate{Path: "status", Op: store.DataIn, Values: []interface{}{"new", "paid"}}, true},
+		{"not in", store.DataPredicate{Path: "status", Op: store.DataIn, Values: []interface{}{"new", 3}}, false},
+		{"range", store.DataPredicate{Path: "amount", Op: store.DataRange, Gte: float(120), Lt: float(200)}, true},
+		{"range exclusive", store.DataPredicate{Path: "amount", Op: store.DataRange, Gt: float(120)}, false},
+		{"range inclusive", store.DataPredicate{Path: "amount", Op: store.DataRange, Lte: float(120)}, true},
+		{"range string", store.DataPredicate{Path: "status", Op: store.DataRange, Gt: float(0)}, false},
+		{"exists", store.DataPredicate{Path: "items.0", Op: store.DataExists}, true},
+		{"exists null", store.DataPredicate{Path: "note", Op: store.DataExists}, true},
+		{"exists out of range", store.DataPredicate{Path: "items.2", Op: store.DataExists}, false},
+		{"exists under scalar", store.DataPredicate{Path: "status.length", Op: store.DataExists}, false},
+		{"invalid", store.DataPredicate{Path: "status", Op: store.DataRange}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.match, tt.predicate.Match(data))
+		})
+	}
+}
+
+func TestSegmentFilter_MatchLink_Data(t *testing.T) {
+	link := chainscripttest.NewLinkBuilder(t).
+		WithData(t, map[string]interface{}{"status": "paid", "amount": 120}).
+		Build()
+
+	filter := store.SegmentFilter{Data: []store.DataPredicate{
+		{Path: "status", Op: store.DataEqual, Value: "paid"},
+		{Path: "amount", Op: store.DataRange, Gte: float(100)},
+	}}
+	assert.True(t, filter.MatchLink(link))
+
+	filter.Data = append(filter.Data, store.DataPredicate{Path: "customer", Op: store.DataExists})
+	assert.False(t, filter.MatchLink(link))
+
+	raw := &chainscript.Link{Meta: link.Meta, Data: []byte("not json")}
+	assert.False(t, store.SegmentFilter{Data: filter.Data[:1]}.MatchLink(raw))
+	assert.True(t, store.SegmentFilter{}.MatchLink(raw))
+}
@@ -18,7 +18,9 @@ package store
 import (
 	"bytes"
 	"context"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/stratumn/go-chainscript"
 	"github.com/stratumn/go-core/types"
@@ -138,6 +140,44 @@ type KeyValueStore interface {
 	KeyValueWriter
 }
 
+// KeyValueIterator is the interface for iterating over ranges of keys.
+// Some key-value stores will implement this interface, but not all.
+type KeyValueIterator interface {
+	// Calls fn for every key in [start, end) in ascending bytewise order.
+	// A nil end means there is no upper bound. Iteration stops at the first
+	// error returned by fn, and that error is returned.
+	// The keys and values given to fn can be retained by the caller, but fn
+	// must not write to the store.
+	IterateValues(ctx context.Context, start, end []byte, fn func(key, value []byte) error) error
+}
+
+// KeyValueWrite is a single write in a KeyValueBatch.
+type KeyValueWrite struct {
+	Key   []byte
+	Value []byte
+
+	// Delete removes the key instead of setting its value.
+	Delete bool
+}
+
+// KeyValueBatch is the interface for writing several key-value pairs
+// atomically.
+// Some key-value stores will implement this interface, but not all: stores
+// that can't apply several writes atomically must not implement it.
+type KeyValueBatch interface {
+	// Applies all the writes in order, or none of them if an error occurs.
+	WriteValues(ctx context.Context, writes []KeyValueWrite) error
+}
+
+// EventReader is the interface for reading past events of a store.
+// Stores that keep an event log implement this interface, but not all do.
+type EventReader interface {
+	// Returns at most limit events whose sequence number is greater than
+	// or equal to from, in order. Returns an OutOfRange error if some of
+	// these events were pruned from the log.
+	ReadEvents(ctx context.Context, from uint64, limit int) ([]*Event, error)
+}
+
 // Pagination contains pagination options.
 type Pagination struct {
 	// Index of the first entry.
@@ -145,6 +185,15 @@ type Pagination struct {
 
 	// Maximum number of entries.
 	Limit int `json:"limit" url:"limit"`
+
+	// Position after which entries should be returned.
+	// For segments, it is the NextCursor returned by a previous call to
+	// FindSegments.
+	// For map IDs, it is the last map ID returned by a previous call to
+	// GetMapIDs: map IDs are then sorted alphabetically (without a cursor,
+	// some stores return the most recently updated maps first).
+	// When a cursor is given, the offset is ignored.
+	Cursor string `json:"cursor,omitempty" url:"cursor"`
 }
 
 // SegmentFilter contains filtering options for segments.
@@ -157,9 +206,15 @@ type SegmentFilter struct {
 	// Process name the segments must have.
 	Process string `json:"process" url:"process"`
 
+	// Process names the segments may have (any of them).
+	Processes []string `json:"processes,omitempty" url:"processes,brackets"`
+
 	// Step the segments must have.
 	Step string `json:"step" url:"step"`
 
+	// Steps the segments may have (any of them).
+	Steps []string `json:"steps,omitempty" url:"steps,brackets"`
+
 	// If true, selects only segments that don't have a parent.
 	WithoutParent bool `json:"withoutParent" url:"withoutParent"`
 
@@ -175,6 +230,22 @@ type SegmentFilter struct {
 	// A slice of tags the segments must all contain.
 	Tags []string `json:"tags" url:"tags,brackets"`
 
+	// A slice of tags the segments must contain at least one of.
+	TagsAny []string `json:"tagsAny,omitempty" url:"tagsAny,brackets"`
+
+	// Predicates the link data must all match.
+	Data []DataPredicate `json:"data,omitempty" url:"-"`
+
+	// If set, selects only segments that were added to the store at or
+	// after this time.
+	// Some stores don't know the creation time of links added by previous
+	// versions: such links never match creation time filters.
+	CreatedAfter *time.Time `json:"createdAfter,omitempty" url:"createdAfter,omitempty"`
+
+	// If set, selects only segments that were added to the store strictly
+	// before this time.
+	CreatedBefore *time.Time `json:"createdBefore,omitempty" url:"createdBefore,omitempty"`
+
 	// Flag to reverse segment ordering.
 	Reverse bool `json:"reverse" url:"reverse"`
 }
@@ -194,17 +265,25 @@ type MapFilter struct {
 }
 
 // PaginateStrings paginates a list of strings.
+// When a cursor is set, the list must be sorted.
 func (p *Pagination) PaginateStrings(a []string) []string {
+	offset := p.Offset
+	if p.Cursor != "" {
+		offset = sort.Search(len(a), func(i int) bool { return a[i] > p.Cursor })
+	}
+
 	l := len(a)
-	if p.Offset >= l {
+	if offset >= l {
 		return []string{}
 	}
 
-	end := min(l, p.Offset+p.Limit)
-	return a[p.Offset:end]
+	end := min(l, offset+p.Limit)
+	return a[offset:end]
 }
 
 // PaginateSegments paginate a list of segments.
+// It ignores the cursor: use SegmentFilter.PaginateSegments instead if you
+// need it.
 func (p *Pagination) PaginateSegments(a *types.PaginatedSegments) *types.PaginatedSegments {
 	l := len(a.Segments)
 	if p.Offset >= l {
@@ -215,9 +294,11 @@ func (p *Pagination) PaginateSegments(a *types.PaginatedSegments) *types.Paginat
 	}
 
 	end := min(l, p.Offset+p.Limit)
+	page := a.Segments[p.Offset:end]
 	return &types.PaginatedSegments{
-		Segments:   a.Segments[p.Offset:end],
+		Segments:   page,
 		TotalCount: a.TotalCount,
+		NextCursor: NextSegmentCursor(page, p.Limit),
 	}
 }
 
@@ -238,6 +319,22 @@ func (filter SegmentFilter) Match(segment *chainscript.Segment) bool {
 	return filter.MatchLink(segment.Link)
 }
 
+// MatchCreatedAt checks if the time at which a segment was added to the
+// store matches the filter's time range.
+// Links don't contain their creation time so stores need to call this in
+// addition to Match.
+func (filter SegmentFilter) MatchCreatedAt(createdAt time.Time) bool {
+	if filter.CreatedAfter != nil && createdAt.Before(*filter.CreatedAfter) {
+		return false
+	}
+
+	if filter.CreatedBefore != nil && !createdAt.Before(*filter.CreatedBefore) {
+		return false
+	}
+
+	return true
+}
+
 // MatchLink checks if link matches with filter.
 func (filter SegmentFilter) MatchLink(link *chainscript.Link) bool {
 	if link == nil {
@@ -287,6 +384,14 @@ func (filter SegmentFilter) MatchLink(link *chainscript.Link) bool {
 		return false
 	}
 
+	if len(filter.Processes) > 0 && !containsString(filter.Processes, link.Meta.Process.Name) {
+		return false
+	}
+
+	if len(filter.Steps) > 0 && !containsString(filter.Steps, link.Meta.Step) {
+		return false
+	}
+
 	if len(filter.MapIDs) > 0 {
 		var match = false
 		mapID := link.Meta.MapId
@@ -307,6 +412,20 @@ func (filter SegmentFilter) MatchLink(link *chainscript.Link) bool {
 		}
 	}
 
+	if len(filter.TagsAny) > 0 {
+		var match = false
+		tags := link.TagMap()
+		for _, tag := range filter.TagsAny {
+			if _, ok := tags[tag]; ok {
+				match = true
+				break
+			}
+		}
+		if !match {
+			return false
+		}
+	}
+
 	if len(filter.Referencing) > 0 {
 		var match = false
 		for _, r := range link.Meta.Refs {
@@ -320,7 +439,7 @@ func (filter SegmentFilter) MatchLink(link *chainscript.Link) bool {
 		}
 	}
 
-	return true
+	return matchLinkData(link, filter.Data)
 }
 
 // Match checks if segment matches with filter.
@@ -349,3 +468,13 @@ func (filter MapFilter) MatchLink(link *chainscript.Link) bool {
 
 	return true
 }
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -17,6 +17,7 @@ package store_test
 import (
 	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/stratumn/go-chainscript"
 	"github.com/stratumn/go-chainscript/chainscripttest"
@@ -80,12 +81,15 @@ func TestSegmentFilter_Match(t *testing.T) {
 		Pagination    store.Pagination
 		MapIDs        []string
 		Process       string
+		Processes     []string
 		Step          string
+		Steps         []string
 		WithoutParent bool
 		PrevLinkHash  chainscript.LinkHash
 		LinkHashes    []chainscript.LinkHash
 		Referencing   chainscript.LinkHash
 		Tags          []string
+		TagsAny       []string
 	}
 
 	type args struct {
@@ -224,6 +228,60 @@ func TestSegmentFilter_Match(t *testing.T) {
 			args:   args{segment: testSegment},
 			want:   true,
 		},
+		{
+			name:   "Any tag",
+			fields: fields{TagsAny: []string{"Hello", "Bar"}},
+			args:   args{segment: testSegment},
+			want:   true,
+		},
+		{
+			name:   "Any tag no match",
+			fields: fields{TagsAny: []string{"Hello", "Baz"}},
+			args:   args{segment: testSegment},
+			want:   false,
+		},
+		{
+			name:   "All tags and any tag",
+			fields: fields{Tags: []string{"Foo"}, TagsAny: []string{"Bar", "Baz"}},
+			args:   args{segment: testSegment},
+			want:   true,
+		},
+		{
+			name:   "All tags no match and any tag",
+			fields: fields{Tags: []string{"Foo", "Baz"}, TagsAny: []string{"Bar"}},
+			args:   args{segment: testSegment},
+			want:   false,
+		},
+		{
+			name:   "Steps",
+			fields: fields{Steps: []string{"sign", "vote"}},
+			args:   args{segment: testSegment},
+			want:   true,
+		},
+		{
+			name:   "Steps no match",
+			fields: fields{Steps: []string{"sign", "approve"}},
+			args:   args{segment: testSegment},
+			want:   false,
+		},
+		{
+			name:   "Processes",
+			fields: fields{Processes: []string{"AProcess", "TheProcess"}},
+			args:   args{segment: testSegment},
+			want:   true,
+		},
+		{
+			name:   "Processes no match",
+			fields: fields{Processes: []string{"AProcess", "AnotherProcess"}},
+			args:   args{segment: testSegment},
+			want:   false,
+		},
+		{
+			name:   "Process and processes",
+			fields: fields{Process: "AProcess", Processes: []string{"AProcess", "TheProcess"}},
+			args:   args{segment: testSegment},
+			want:   false,
+		},
 		{
 			name:   "Referencing",
 			fields: fields{Referencing: []byte{42, 42}},
@@ -243,12 +301,15 @@ func TestSegmentFilter_Match(t *testing.T) {
 				Pagination:    tt.fields.Pagination,
 				MapIDs:        tt.fields.MapIDs,
 				Process:       tt.fields.Process,
+				Processes:     tt.fields.Processes,
 				Step:          tt.fields.Step,
+				Steps:         tt.fields.Steps,
 				LinkHashes:    tt.fields.LinkHashes,
 				WithoutParent: tt.fields.WithoutParent,
 				PrevLinkHash:  tt.fields.PrevLinkHash,
 				Referencing:   tt.fields.Referencing,
 				Tags:          tt.fields.Tags,
+				TagsAny:       tt.fields.TagsAny,
 			}
 
 			got := filter.Match(tt.args.segment)
@@ -257,6 +318,39 @@ func TestSegmentFilter_Match(t *testing.T) {
 	}
 }
 
+func TestSegmentFilter_MatchCreatedAt(t *testing.T) {
+	now := time.Now()
+	before := now.Add(-time.Hour)
+	after := now.Add(time.Hour)
+
+	tests := []struct {
+		name          string
+		createdAfter  *time.Time
+		createdBefore *time.Time
+		want          bool
+	}{
+		{"No time range", nil, nil, true},
+		{"Created after", &before, nil, true},
+		{"Created after no match", &after, nil, false},
+		{"Created after is inclusive", &now, nil, true},
+		{"Created before", nil, &after, true},
+		{"Created before no match", nil, &before, false},
+		{"Created before is exclusive", nil, &now, false},
+		{"Time range", &before, &after, true},
+		{"Time range no match", &after, &before, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := store.SegmentFilter{
+				CreatedAfter:  tt.createdAfter,
+				CreatedBefore: tt.createdBefore,
+			}
+
+			assert.Equal(t, tt.want, filter.MatchCreatedAt(now))
+		})
+	}
+}
+
 func TestMapFilter_Match(t *testing.T) {
 	type fields struct {
 		Pagination store.Pagination
@@ -580,4 +674,53 @@ func TestEvents(t *testing.T) {
 		deserialized := evidences[linkHash.String()]
 		assert.EqualValues(t, evidence, deserialized, "Invalid evidence")
 	})
+
+	t.Run("Filter", func(t *testing.T) {
+		l1 := chainscripttest.NewLinkBuilder(t).WithMapID("m1").Build()
+		l2 := chainscripttest.NewLinkBuilder(t).WithMapID("m2").Build()
+		lh1, _ := l1.Hash()
+		lh2, _ := l2.Hash()
+		matchM1 := func(l *chainscript.Link) bool { return l.Meta.MapId == "m1" }
+
+		e := store.NewSavedLinks(l1, l2)
+		e.Sequence = 3
+		filtered := e.Filter(matchM1, nil)
+		require.NotNil(t, filtered)
+		assert.Equal(t, []*chainscript.Link{l1}, filtered.Data)
+		assert.EqualValues(t, 3, filtered.Sequence)
+		assert.Len(t, e.Data, 2, "Event should not be modified")
+
+		assert.Nil(t, store.NewSavedLinks(l2).Filter(matchM1, nil))
+
+		e = store.NewSavedEvidences()
+		e.AddSavedEvidence(lh1, chainscripttest.RandomEvidence(t))
+		e.AddSavedEvidence(lh2, chainscripttest.RandomEvidence(t))
+		links := map[string]*chainscript.Link{lh1.String(): l1, lh2.String(): l2}
+		filtered = e.Filter(matchM1, links)
+		require.NotNil(t, filtered)
+		assert.Len(t, filtered.Data, 1)
+		assert.Contains(t, filtered.Data, lh1.String())
+
+		assert.Nil(t, e.Filter(matchM1, nil), "Evidences of unknown links should not match")
+
+		other := &store.Event{EventType: "Other"}
+		assert.Equal(t, other, other.Filter(matchM1, nil))
+	})
+
+	t.Run("Sequence serialization", func(t *testing.T) {
+		e := store.NewSavedLinks()
+
+		b, err := json.Marshal(e)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(b), "Sequence", "Sequence should be omitted")
+
+		e.Sequence = 42
+		b, err = json.Marshal(e)
+		assert.NoError(t, err)
+
+		var e2 store.Event
+		err = json.Unmarshal(b, &e2)
+		assert.NoError(t, err)
+		assert.EqualValues(t, 42, e2.Sequence, "Invalid sequence")
+	})
 }
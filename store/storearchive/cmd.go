@@ -0,0 +1,110 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storearchive
+
+import (
+	"context"
+	"encoding/hex"
+	"flag"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/stratumn/go-core/monitoring"
+	"github.com/stratumn/go-core/store"
+)
+
+var (
+	exportPath string
+	exportKeys string
+	importPath string
+)
+
+// RegisterFlags registers the flags used by RunWithFlags.
+func RegisterFlags() {
+	flag.StringVar(&exportPath, "export", "", "Export the store to an archive file (- for stdout) and exit")
+	flag.StringVar(&exportKeys, "export_keys", "", "Comma-separated list of hex-encoded keys of the key-value pairs to export (all the pairs are exported when empty if the store can list them)")
+	flag.StringVar(&importPath, "import", "", "Import an archive file (- for stdin) before starting")
+}
+
+// RunWithFlags should be called after RegisterFlags and flag.Parse, before
+// starting the store server.
+// If an export was requested, it exports the store and exits.
+// If an import was requested, it imports the archive and returns so that the
+// store can start serving the imported data.
+func RunWithFlags(a store.Adapter) {
+	ctx := context.Background()
+
+	if exportPath != "" {
+		var keys [][]byte
+		for _, k := range strings.Split(exportKeys, ",") {
+			if k == "" {
+				continue
+			}
+
+			key, err := hex.DecodeString(k)
+			if err != nil {
+				monitoring.LogEntry().WithField("key", k).Fatal("Invalid export key")
+			}
+
+			keys = append(keys, key)
+		}
+
+		var w io.WriteCloser = os.Stdout
+		if exportPath != "-" {
+			f, err := os.Create(exportPath)
+			if err != nil {
+				monitoring.LogEntry().WithField("error", err).Fatal("Failed to create archive")
+			}
+
+			w = f
+		}
+
+		stats, err := Export(ctx, a, w, &ExportOptions{Keys: keys})
+		if err != nil {
+			monitoring.LogEntry().WithField("error", err).Fatal("Failed to export store")
+		}
+
+		if err := w.Close(); err != nil {
+			monitoring.LogEntry().WithField("error", err).Fatal("Failed to close archive")
+		}
+
+		monitoring.LogEntry().WithField("stats", stats).Info("Exported store")
+		os.Exit(0)
+	}
+
+	if importPath != "" {
+		var r io.ReadCloser = os.Stdin
+		if importPath != "-" {
+			f, err := os.Open(importPath)
+			if err != nil {
+				monitoring.LogEntry().WithField("error", err).Fatal("Failed to open archive")
+			}
+
+			r = f
+		}
+
+		stats, err := Import(ctx, a, r)
+		if err != nil {
+			monitoring.LogEntry().WithField("error", err).Fatal("Failed to import archive")
+		}
+
+		if err := r.Close(); err != nil {
+			monitoring.LogEntry().WithField("error", err).Warn("Failed to close archive")
+		}
+
+		monitoring.LogEntry().WithField("stats", stats).Info("Imported archive")
+	}
+}
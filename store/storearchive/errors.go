@@ -0,0 +1,29 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storearchive
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Errors returned when reading or importing an archive.
+var (
+	ErrMissingHeader        = errors.New("archive doesn't start with a header")
+	ErrUnsupportedVersion   = errors.New("archive version is not supported")
+	ErrInvalidRecord        = errors.New("archive record is invalid")
+	ErrLinkHashMismatch     = errors.New("link doesn't match its hash")
+	ErrInvalidOrder         = errors.New("link appears after a link that depends on it")
+	ErrKeyValueNotSupported = errors.New("store doesn't support key-value pairs")
+)
@@ -0,0 +1,136 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storearchive
+
+import (
+	"context"
+	"io"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+)
+
+// ExportOptions configures an export.
+type ExportOptions struct {
+	// Filter restricts the exported segments (all segments are exported by
+	// default). Its pagination is ignored.
+	Filter *store.SegmentFilter
+
+	// Keys of the key-value pairs to export.
+	// They are only exported if the store implements store.KeyValueReader.
+	// Without keys, all the key-value pairs are exported if the store
+	// implements store.KeyValueIterator.
+	Keys [][]byte
+}
+
+// Export writes the segments of a store and its key-value pairs (or only
+// the requested ones) to an archive.
+// Segments are streamed from the store, but segments whose parent or
+// references haven't been exported yet are kept in memory until they can be
+// written.
+func Export(ctx context.Context, a store.SegmentReader, w io.Writer, opts *ExportOptions) (*Stats, error) {
+	if opts == nil {
+		opts = &ExportOptions{}
+	}
+
+	filter := &store.SegmentFilter{}
+	if opts.Filter != nil {
+		filter = opts.Filter
+	}
+
+	aw, err := NewWriter(w)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{}
+	sorter := store.NewTopologicalSorter(func(segment *chainscript.Segment) error {
+		stats.Links++
+		stats.Evidences += len(segment.Meta.Evidences)
+		return aw.WriteSegment(segment)
+	})
+
+	it, err := store.IterateSegments(ctx, a, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	for it.Next() {
+		if err := sorter.Add(it.Segment()); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := sorter.Flush(); err != nil {
+		return nil, err
+	}
+
+	if len(opts.Keys) == 0 {
+		if err := exportAllValues(ctx, a, aw, stats); err != nil {
+			return nil, err
+		}
+
+		return stats, nil
+	}
+
+	kv, ok := a.(store.KeyValueReader)
+	if !ok {
+		return nil, types.WrapError(ErrKeyValueNotSupported, errorcode.Unimplemented, store.Component, "could not export values")
+	}
+
+	for _, key := range opts.Keys {
+		value, err := kv.GetValue(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+
+		if value == nil {
+			continue
+		}
+
+		if err := aw.WriteValue(key, value); err != nil {
+			return nil, err
+		}
+
+		stats.Values++
+	}
+
+	return stats, nil
+}
+
+// exportAllValues writes all the key-value pairs of a store to an archive.
+// Nothing is written if the store can't list its key-value pairs.
+func exportAllValues(ctx context.Context, a store.SegmentReader, aw *Writer, stats *Stats) error {
+	it, ok := a.(store.KeyValueIterator)
+	if !ok {
+		return nil
+	}
+
+	return it.IterateValues(ctx, nil, nil, func(key, value []byte) error {
+		if err := aw.WriteValue(key, value); err != nil {
+			return err
+		}
+
+		stats.Values++
+		return nil
+	})
+}
@@ -0,0 +1,122 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storearchive
+
+import (
+	"context"
+	"io"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+)
+
+// Import reads an archive and adds its content to a store.
+// The hash of every link is verified, and the import fails if a link
+// appears after a link that depends on it. Evidences the store already has
+// are skipped, so an archive can be imported again.
+// Key-value records can only be imported in a store that implements
+// store.KeyValueWriter.
+func Import(ctx context.Context, a store.Adapter, r io.Reader) (*Stats, error) {
+	ar, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{}
+
+	// Links that have been imported, and links that have been depended on
+	// before being imported.
+	imported := make(map[string]struct{})
+	expected := make(map[string]struct{})
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, types.WrapError(err, errorcode.Cancelled, store.Component, "import interrupted")
+		}
+
+		record, err := ar.Next()
+		if err == io.EOF {
+			return stats, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch record.Type {
+		case RecordLink:
+			if _, ok := expected[record.LinkHash]; ok {
+				return nil, types.WrapErrorf(ErrInvalidOrder, errorcode.InvalidArgument, store.Component, "could not import link %s", record.LinkHash)
+			}
+
+			for _, dep := range store.LinkDependencies(record.Link) {
+				if _, ok := imported[dep.String()]; !ok {
+					expected[dep.String()] = struct{}{}
+				}
+			}
+
+			if _, err := a.CreateLink(ctx, record.Link); err != nil {
+				return nil, err
+			}
+
+			imported[record.LinkHash] = struct{}{}
+			stats.Links++
+		case RecordEvidence:
+			linkHash, err := chainscript.NewLinkHashFromString(record.LinkHash)
+			if err != nil {
+				return nil, types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not parse link hash")
+			}
+
+			existing, err := a.GetEvidences(ctx, linkHash)
+			if err != nil {
+				return nil, err
+			}
+
+			if hasEvidence(existing, record.Evidence) {
+				continue
+			}
+
+			if err := a.AddEvidence(ctx, linkHash, record.Evidence); err != nil {
+				return nil, err
+			}
+
+			stats.Evidences++
+		case RecordValue:
+			kv, ok := a.(store.KeyValueWriter)
+			if !ok {
+				return nil, types.WrapError(ErrKeyValueNotSupported, errorcode.Unimplemented, store.Component, "could not import values")
+			}
+
+			if err := kv.SetValue(ctx, record.Key, record.Value); err != nil {
+				return nil, err
+			}
+
+			stats.Values++
+		}
+	}
+}
+
+// hasEvidence returns true if the evidences contain an evidence from the same
+// backend and provider.
+func hasEvidence(evidences types.EvidenceSlice, e *chainscript.Evidence) bool {
+	for _, existing := range evidences {
+		if existing.Backend == e.Backend && existing.Provider == e.Provider {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,206 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package storearchive exports the content of a store to a portable archive
+// and imports it back into any store.
+//
+// An archive is a stream of newline-delimited JSON records.
+// The first record is a header containing the version of the archive format.
+// It is followed by link records, each of them directly followed by the
+// evidences of the link, and finally by key-value records.
+//
+// Links are always written after their parent and after the links they
+// reference (when those are part of the archive), so that an archive can be
+// imported in a store that checks the existence of parents.
+package storearchive
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+)
+
+// Version of the archive format.
+const Version = "1.0.0"
+
+// RecordType is the type of an archive record.
+type RecordType string
+
+// Types of archive records.
+const (
+	RecordHeader   RecordType = "header"
+	RecordLink     RecordType = "link"
+	RecordEvidence RecordType = "evidence"
+	RecordValue    RecordType = "value"
+)
+
+// Record is a single line of an archive.
+type Record struct {
+	Type RecordType `json:"type"`
+
+	// Version is only set in the header.
+	Version string `json:"version,omitempty"`
+
+	// LinkHash is the hex-encoded hash of the link, set in link and evidence
+	// records.
+	LinkHash string                `json:"linkHash,omitempty"`
+	Link     *chainscript.Link     `json:"link,omitempty"`
+	Evidence *chainscript.Evidence `json:"evidence,omitempty"`
+
+	// Key and Value are set in key-value records.
+	Key   []byte `json:"key,omitempty"`
+	Value []byte `json:"value,omitempty"`
+}
+
+// Stats counts the records written to or read from an archive.
+type Stats struct {
+	Links     int `json:"links"`
+	Evidences int `json:"evidences"`
+	Values    int `json:"values"`
+}
+
+// Writer writes records to an archive.
+type Writer struct {
+	enc *json.Encoder
+}
+
+// NewWriter creates an archive writer and writes the header.
+func NewWriter(w io.Writer) (*Writer, error) {
+	aw := &Writer{enc: json.NewEncoder(w)}
+	if err := aw.write(&Record{Type: RecordHeader, Version: Version}); err != nil {
+		return nil, err
+	}
+
+	return aw, nil
+}
+
+// WriteSegment writes the link of a segment followed by its evidences.
+func (w *Writer) WriteSegment(segment *chainscript.Segment) error {
+	linkHash := segment.LinkHash().String()
+
+	err := w.write(&Record{Type: RecordLink, LinkHash: linkHash, Link: segment.Link})
+	if err != nil {
+		return err
+	}
+
+	for _, e := range segment.Meta.Evidences {
+		err := w.write(&Record{Type: RecordEvidence, LinkHash: linkHash, Evidence: e})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteValue writes a key-value pair.
+func (w *Writer) WriteValue(key, value []byte) error {
+	return w.write(&Record{Type: RecordValue, Key: key, Value: value})
+}
+
+func (w *Writer) write(r *Record) error {
+	if err := w.enc.Encode(r); err != nil {
+		return types.WrapError(err, errorcode.Unknown, store.Component, "could not write archive record")
+	}
+
+	return nil
+}
+
+// Reader reads records from an archive.
+type Reader struct {
+	dec *json.Decoder
+}
+
+// NewReader creates an archive reader.
+// It reads the header and fails if the archive format isn't supported.
+func NewReader(r io.Reader) (*Reader, error) {
+	ar := &Reader{dec: json.NewDecoder(r)}
+
+	header, err := ar.read()
+	if err == io.EOF {
+		return nil, types.WrapError(ErrMissingHeader, errorcode.InvalidArgument, store.Component, "could not read archive")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if header.Type != RecordHeader {
+		return nil, types.WrapError(ErrMissingHeader, errorcode.InvalidArgument, store.Component, "could not read archive")
+	}
+
+	if header.Version != Version {
+		return nil, types.WrapErrorf(ErrUnsupportedVersion, errorcode.InvalidArgument, store.Component, "could not read archive version %s", header.Version)
+	}
+
+	return ar, nil
+}
+
+// Next returns the next record of the archive, or io.EOF at the end of the
+// archive.
+// The hash of link records is verified.
+func (r *Reader) Next() (*Record, error) {
+	record, err := r.read()
+	if err != nil {
+		return nil, err
+	}
+
+	switch record.Type {
+	case RecordLink:
+		if record.Link == nil {
+			return nil, types.WrapError(ErrInvalidRecord, errorcode.InvalidArgument, store.Component, "link record without link")
+		}
+
+		linkHash, err := record.Link.Hash()
+		if err != nil {
+			return nil, types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not hash link")
+		}
+
+		if linkHash.String() != record.LinkHash {
+			return nil, types.WrapErrorf(ErrLinkHashMismatch, errorcode.DataLoss, store.Component, "link %s hashes to %s", record.LinkHash, linkHash.String())
+		}
+	case RecordEvidence:
+		if record.Evidence == nil {
+			return nil, types.WrapError(ErrInvalidRecord, errorcode.InvalidArgument, store.Component, "evidence record without evidence")
+		}
+
+		if record.LinkHash == "" {
+			return nil, types.WrapError(ErrInvalidRecord, errorcode.InvalidArgument, store.Component, "evidence record without link hash")
+		}
+	case RecordValue:
+		if len(record.Key) == 0 {
+			return nil, types.WrapError(ErrInvalidRecord, errorcode.InvalidArgument, store.Component, "value record without key")
+		}
+	default:
+		return nil, types.WrapErrorf(ErrInvalidRecord, errorcode.InvalidArgument, store.Component, "unknown record type %s", record.Type)
+	}
+
+	return record, nil
+}
+
+func (r *Reader) read() (*Record, error) {
+	var record Record
+	if err := r.dec.Decode(&record); err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+
+		return nil, types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not read archive record")
+	}
+
+	return &record, nil
+}
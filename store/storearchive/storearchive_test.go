@@ -0,0 +1,265 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storearchive_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-chainscript/chainscripttest"
+	"github.com/stratumn/go-core/dummystore"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/store/storearchive"
+	"github.com/stratumn/go-core/testutil"
+	"github.com/stratumn/go-core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createLink(t *testing.T, a store.Adapter, l *chainscript.Link) chainscript.LinkHash {
+	lh, err := a.CreateLink(context.Background(), l)
+	require.NoError(t, err)
+	return lh
+}
+
+// populate creates a map where children have a higher priority than their
+// parents, so they are returned first by the store.
+func populate(t *testing.T, a store.Adapter) []*chainscript.Link {
+	ctx := context.Background()
+
+	root := chainscripttest.NewLinkBuilder(t).WithRandomData().WithPriority(1).Build()
+	child := chainscripttest.NewLinkBuilder(t).WithRandomData().Branch(t, root).WithPriority(2).Build()
+	grandChild := chainscripttest.NewLinkBuilder(t).WithRandomData().Branch(t, child).WithPriority(3).WithRef(t, root).Build()
+	other := chainscripttest.NewLinkBuilder(t).WithRandomData().WithPriority(4).WithRef(t, grandChild).Build()
+
+	links := []*chainscript.Link{root, child, grandChild, other}
+	for _, l := range links {
+		createLink(t, a, l)
+	}
+
+	rootHash, _ := root.Hash()
+	require.NoError(t, a.AddEvidence(ctx, rootHash, chainscripttest.RandomEvidence(t)))
+	require.NoError(t, a.AddEvidence(ctx, rootHash, chainscripttest.RandomEvidence(t)))
+
+	return links
+}
+
+func readRecords(t *testing.T, archive []byte) []*storearchive.Record {
+	r, err := storearchive.NewReader(bytes.NewReader(archive))
+	require.NoError(t, err)
+
+	var records []*storearchive.Record
+	for {
+		record, err := r.Next()
+		if err != nil {
+			break
+		}
+
+		records = append(records, record)
+	}
+
+	return records
+}
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+
+	src := dummystore.New(&dummystore.Config{})
+	links := populate(t, src)
+	require.NoError(t, src.SetValue(ctx, []byte("k1"), []byte("v1")))
+
+	var archive bytes.Buffer
+	stats, err := storearchive.Export(ctx, src, &archive, &storearchive.ExportOptions{
+		Keys: [][]byte{[]byte("k1"), []byte("missing")},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &storearchive.Stats{Links: 4, Evidences: 2, Values: 1}, stats)
+
+	t.Run("parents are written first", func(t *testing.T) {
+		var order []string
+		for _, r := range readRecords(t, archive.Bytes()) {
+			if r.Type == storearchive.RecordLink {
+				order = append(order, r.LinkHash)
+			}
+		}
+
+		require.Len(t, order, len(links))
+		for i, l := range links {
+			lh, _ := l.Hash()
+			assert.Equal(t, lh.String(), order[i])
+		}
+	})
+
+	t.Run("import restores the store", func(t *testing.T) {
+		dst := dummystore.New(&dummystore.Config{})
+		imported, err := storearchive.Import(ctx, dst, bytes.NewReader(archive.Bytes()))
+		require.NoError(t, err)
+		assert.Equal(t, stats, imported)
+
+		for _, l := range links {
+			lh, _ := l.Hash()
+			expected, err := src.GetSegment(ctx, lh)
+			require.NoError(t, err)
+
+			got, err := dst.GetSegment(ctx, lh)
+			require.NoError(t, err)
+			require.NotNil(t, got)
+
+			chainscripttest.SegmentsEqual(t, expected, got)
+			assert.Len(t, got.Meta.Evidences, len(expected.Meta.Evidences))
+		}
+
+		value, err := dst.GetValue(ctx, []byte("k1"))
+		require.NoError(t, err)
+		assert.Equal(t, []byte("v1"), value)
+	})
+}
+
+func TestExport_filter(t *testing.T) {
+	ctx := context.Background()
+
+	a := dummystore.New(&dummystore.Config{})
+	links := populate(t, a)
+
+	var archive bytes.Buffer
+	stats, err := storearchive.Export(ctx, a, &archive, &storearchive.ExportOptions{
+		Filter: &store.SegmentFilter{Process: links[3].Meta.Process.Name},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Links)
+}
+
+func TestExport_allValues(t *testing.T) {
+	ctx := context.Background()
+
+	a := dummystore.New(&dummystore.Config{})
+	require.NoError(t, a.SetValue(ctx, []byte("k1"), []byte("v1")))
+	require.NoError(t, a.SetValue(ctx, []byte("k2"), []byte("v2")))
+
+	var archive bytes.Buffer
+	stats, err := storearchive.Export(ctx, a, &archive, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Values)
+
+	var keys []string
+	for _, r := range readRecords(t, archive.Bytes()) {
+		if r.Type == storearchive.RecordValue {
+			keys = append(keys, string(r.Key))
+		}
+	}
+	assert.Equal(t, []string{"k1", "k2"}, keys)
+}
+
+func TestImport_twice(t *testing.T) {
+	ctx := context.Background()
+
+	src := dummystore.New(&dummystore.Config{})
+	links := populate(t, src)
+
+	var archive bytes.Buffer
+	_, err := storearchive.Export(ctx, src, &archive, nil)
+	require.NoError(t, err)
+
+	dst := dummystore.New(&dummystore.Config{})
+	_, err = storearchive.Import(ctx, dst, bytes.NewReader(archive.Bytes()))
+	require.NoError(t, err)
+
+	stats, err := storearchive.Import(ctx, dst, bytes.NewReader(archive.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, 0, stats.Evidences, "existing evidences should be skipped")
+
+	rootHash, _ := links[0].Hash()
+	evidences, err := dst.GetEvidences(ctx, rootHash)
+	require.NoError(t, err)
+	assert.Len(t, evidences, 2)
+}
+
+func TestImport_errors(t *testing.T) {
+	ctx := context.Background()
+
+	link := chainscripttest.RandomLink(t)
+	linkHash, _ := link.Hash()
+	child := chainscripttest.NewLinkBuilder(t).WithRandomData().Branch(t, link).Build()
+	childHash, _ := child.Hash()
+
+	archive := func(records ...*storearchive.Record) string {
+		var lines []string
+		for _, r := range records {
+			js, err := json.Marshal(r)
+			require.NoError(t, err)
+			lines = append(lines, string(js))
+		}
+
+		return strings.Join(lines, "\n")
+	}
+
+	header := &storearchive.Record{Type: storearchive.RecordHeader, Version: storearchive.Version}
+
+	tests := []struct {
+		name    string
+		archive string
+		err     error
+		code    int
+	}{{
+		"missing header",
+		archive(&storearchive.Record{Type: storearchive.RecordLink, LinkHash: linkHash.String(), Link: link}),
+		storearchive.ErrMissingHeader,
+		errorcode.InvalidArgument,
+	}, {
+		"empty archive",
+		"",
+		storearchive.ErrMissingHeader,
+		errorcode.InvalidArgument,
+	}, {
+		"unsupported version",
+		archive(&storearchive.Record{Type: storearchive.RecordHeader, Version: "0.1.0"}),
+		storearchive.ErrUnsupportedVersion,
+		errorcode.InvalidArgument,
+	}, {
+		"link hash mismatch",
+		archive(header, &storearchive.Record{Type: storearchive.RecordLink, LinkHash: childHash.String(), Link: link}),
+		storearchive.ErrLinkHashMismatch,
+		errorcode.DataLoss,
+	}, {
+		"child before parent",
+		archive(
+			header,
+			&storearchive.Record{Type: storearchive.RecordLink, LinkHash: childHash.String(), Link: child},
+			&storearchive.Record{Type: storearchive.RecordLink, LinkHash: linkHash.String(), Link: link},
+		),
+		storearchive.ErrInvalidOrder,
+		errorcode.InvalidArgument,
+	}, {
+		"unknown record",
+		archive(header, &storearchive.Record{Type: "unknown"}),
+		storearchive.ErrInvalidRecord,
+		errorcode.InvalidArgument,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := dummystore.New(&dummystore.Config{})
+			_, err := storearchive.Import(ctx, a, strings.NewReader(tt.archive))
+			require.Error(t, err)
+			testutil.AssertWrappedErrorEqual(t, err, tt.err)
+			assert.Equal(t, tt.code, err.(*types.Error).Code)
+		})
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storegrpc
+
+import (
+	"flag"
+
+	"github.com/stratumn/go-core/monitoring"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/store/storehttp"
+)
+
+var (
+	addr                string
+	storeEventsChanSize int
+	subscriberChanSize  int
+	certFile            string
+	keyFile             string
+)
+
+// RegisterFlags registers the flags used by RunWithFlags.
+func RegisterFlags() {
+	flag.StringVar(&addr, "grpc", "", "gRPC address (the gRPC server is disabled when empty, use "+DefaultAddress+" for instance)")
+	flag.IntVar(&storeEventsChanSize, "grpc_store_events_chan_size", DefaultStoreEventsChanSize, "Size of the gRPC server store events channel")
+	flag.IntVar(&subscriberChanSize, "grpc_subscriber_chan_size", DefaultSubscriberChanSize, "Number of store events buffered for each gRPC subscriber")
+	flag.StringVar(&certFile, "grpc_tls_cert", "", "gRPC TLS certificate file")
+	flag.StringVar(&keyFile, "grpc_tls_key", "", "gRPC TLS private key file")
+}
+
+// RunWithFlags should be called after RegisterFlags and flag.Parse.
+// When a gRPC address is configured, it starts a gRPC server in the
+// background, so that it can run next to the storehttp server. The gRPC
+// server is shut down when the storehttp server receives an exit signal.
+func RunWithFlags(a store.Adapter) {
+	if addr == "" {
+		return
+	}
+
+	s, err := New(a, &Config{
+		Address:             addr,
+		StoreEventsChanSize: storeEventsChanSize,
+		SubscriberChanSize:  subscriberChanSize,
+		CertFile:            certFile,
+		KeyFile:             keyFile,
+	})
+	if err != nil {
+		monitoring.LogEntry().WithField("error", err).Fatal("Failed to create gRPC server")
+	}
+
+	storehttp.OnShutdown(s.Shutdown)
+
+	go func() {
+		monitoring.LogEntry().WithField("grpc", addr).Info("Listening")
+		if err := s.ListenAndServe(); err != nil {
+			monitoring.LogEntry().WithField("error", err).Fatal("gRPC server stopped")
+		}
+	}()
+}
@@ -0,0 +1,174 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storegrpc
+
+import (
+	"encoding/json"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newStatusErr converts an error to a gRPC status error.
+// Store error codes are the gRPC status codes.
+func newStatusErr(err error) error {
+	if e, ok := err.(*types.Error); ok {
+		return status.Error(codes.Code(e.Code), e.Error())
+	}
+
+	return status.Error(codes.Unknown, err.Error())
+}
+
+func newPagination(p *Pagination) (*store.Pagination, error) {
+	pagination := &store.Pagination{Limit: store.DefaultLimit}
+	if p == nil {
+		return pagination, nil
+	}
+
+	if p.Offset < 0 {
+		return nil, types.NewError(errorcode.InvalidArgument, store.Component, "offset must be a positive integer")
+	}
+
+	if p.Limit < 0 || p.Limit > store.MaxLimit {
+		return nil, types.NewErrorf(errorcode.InvalidArgument, store.Component, "limit must be a positive integer less than or equal to %d", store.MaxLimit)
+	}
+
+	pagination.Offset = int(p.Offset)
+	pagination.Cursor = p.Cursor
+	if p.Limit > 0 {
+		pagination.Limit = int(p.Limit)
+	}
+
+	return pagination, nil
+}
+
+func newSegmentFilter(f *SegmentFilter) (*store.SegmentFilter, error) {
+	pagination, err := newPagination(f.Pagination)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := &store.SegmentFilter{
+		Pagination:    *pagination,
+		MapIDs:        f.MapIds,
+		Process:       f.Process,
+		Processes:     f.Processes,
+		Step:          f.Step,
+		Steps:         f.Steps,
+		WithoutParent: f.WithoutParent,
+		PrevLinkHash:  f.PrevLinkHash,
+		Referencing:   f.Referencing,
+		Tags:          f.Tags,
+		TagsAny:       f.TagsAny,
+		Reverse:       f.Reverse,
+	}
+
+	if len(filter.Cursor) > 0 {
+		if _, err := store.ParseSegmentCursor(filter.Cursor); err != nil {
+			return nil, types.WrapError(store.ErrInvalidCursor, errorcode.InvalidArgument, store.Component, "cursor must be the nextCursor returned by a previous request")
+		}
+	}
+
+	for _, lh := range f.LinkHashes {
+		filter.LinkHashes = append(filter.LinkHashes, chainscript.LinkHash(lh))
+	}
+
+	if f.CreatedAfter != nil {
+		createdAfter, err := ptypes.Timestamp(f.CreatedAfter)
+		if err != nil {
+			return nil, types.WrapError(err, errorcode.InvalidArgument, store.Component, "invalid createdAfter")
+		}
+
+		filter.CreatedAfter = &createdAfter
+	}
+
+	if f.CreatedBefore != nil {
+		createdBefore, err := ptypes.Timestamp(f.CreatedBefore)
+		if err != nil {
+			return nil, types.WrapError(err, errorcode.InvalidArgument, store.Component, "invalid createdBefore")
+		}
+
+		filter.CreatedBefore = &createdBefore
+	}
+
+	if len(f.Data) > 0 {
+		if err := json.Unmarshal(f.Data, &filter.Data); err != nil {
+			return nil, types.WrapError(err, errorcode.InvalidArgument, store.Component, "data must be a JSON array of predicates")
+		}
+
+		for i := range filter.Data {
+			if err := filter.Data[i].Validate(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return filter, nil
+}
+
+func newMapFilter(f *MapFilter) (*store.MapFilter, error) {
+	pagination, err := newPagination(f.Pagination)
+	if err != nil {
+		return nil, err
+	}
+
+	return &store.MapFilter{
+		Pagination: *pagination,
+		Process:    f.Process,
+		Prefix:     f.Prefix,
+		Suffix:     f.Suffix,
+	}, nil
+}
+
+// newStoreEvent converts a store event.
+// It returns nil for unknown event types.
+func newStoreEvent(e *store.Event) *StoreEvent {
+	switch e.EventType {
+	case store.SavedLinks:
+		links, ok := e.Data.([]*chainscript.Link)
+		if !ok {
+			return nil
+		}
+
+		return &StoreEvent{Type: StoreEvent_SAVED_LINKS, Links: links}
+	case store.SavedEvidences:
+		evidences, ok := e.Data.(map[string]*chainscript.Evidence)
+		if !ok {
+			return nil
+		}
+
+		event := &StoreEvent{Type: StoreEvent_SAVED_EVIDENCES}
+		for lh, evidence := range evidences {
+			linkHash, err := chainscript.NewLinkHashFromString(lh)
+			if err != nil {
+				continue
+			}
+
+			event.Evidences = append(event.Evidences, &SavedEvidence{
+				LinkHash: linkHash,
+				Evidence: evidence,
+			})
+		}
+
+		return event
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,390 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package storegrpc is used to create a gRPC server from a store adapter.
+//
+// The service is defined in storegrpc.proto and uses the chainscript protobuf
+// messages directly. Compared to storehttp, it avoids the JSON encoding
+// overhead for bulk ingestion and streams typed store events to subscribers.
+package storegrpc
+
+//go:generate protoc -I . -I $GOPATH/src --go_out=plugins=grpc:. storegrpc.proto
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"sync"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-core/monitoring"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// DefaultAddress is the default address of the server.
+	DefaultAddress = ":5001"
+
+	// DefaultStoreEventsChanSize is the default size of the store events channel.
+	DefaultStoreEventsChanSize = 256
+
+	// DefaultSubscriberChanSize is the default number of events buffered for
+	// each subscriber.
+	DefaultSubscriberChanSize = 256
+)
+
+// Config contains configuration options for the server.
+type Config struct {
+	// The address of the server.
+	Address string
+
+	// The size of the store event channel.
+	StoreEventsChanSize int
+
+	// The number of events buffered for each subscriber.
+	// A subscriber that falls further behind is disconnected.
+	SubscriberChanSize int
+
+	// Optional TLS certificate and private key files.
+	CertFile string
+	KeyFile  string
+}
+
+// Server is a gRPC server for stores.
+// It implements StoreServer.
+type Server struct {
+	adapter         store.Adapter
+	config          *Config
+	server          *grpc.Server
+	storeEventsChan chan *store.Event
+
+	subsMutex   sync.Mutex
+	subscribers map[*subscriber]struct{}
+}
+
+// subscriber receives the events of a Subscribe stream.
+// The done channel is closed when the subscription ends on the server side,
+// in which case err explains why (nil on shutdown).
+type subscriber struct {
+	events chan *StoreEvent
+	done   chan struct{}
+	err    error
+}
+
+// New creates an instance of a server.
+func New(a store.Adapter, config *Config) (*Server, error) {
+	var opts []grpc.ServerOption
+	if config.CertFile != "" || config.KeyFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not load TLS credentials")
+		}
+
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	s := &Server{
+		adapter:         a,
+		config:          config,
+		server:          grpc.NewServer(opts...),
+		storeEventsChan: make(chan *store.Event, config.StoreEventsChanSize),
+		subscribers:     make(map[*subscriber]struct{}),
+	}
+
+	RegisterStoreServer(s.server, s)
+
+	return s, nil
+}
+
+// ListenAndServe starts the server on the configured address.
+func (s *Server) ListenAndServe() error {
+	lis, err := net.Listen("tcp", s.config.Address)
+	if err != nil {
+		return types.WrapError(err, errorcode.Unavailable, store.Component, "net.Listen")
+	}
+
+	return s.Serve(lis)
+}
+
+// Serve starts the server on the given listener.
+func (s *Server) Serve(lis net.Listener) (err error) {
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+
+	go func() {
+		s.Start()
+		wg.Done()
+	}()
+
+	go func() {
+		err = s.server.Serve(lis)
+		wg.Done()
+	}()
+
+	wg.Wait()
+
+	return err
+}
+
+// Shutdown stops the server.
+// Pending requests are given until the context is done to complete.
+func (s *Server) Shutdown(ctx context.Context) error {
+	close(s.storeEventsChan)
+
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		return ctx.Err()
+	}
+}
+
+// Start starts the main loop. You do not need to call this if you call
+// ListenAndServe() or Serve().
+func (s *Server) Start() {
+	s.adapter.AddStoreEventChannel(s.storeEventsChan)
+	s.loop()
+}
+
+// Forwards store events to subscribers.
+func (s *Server) loop() {
+	for event := range s.storeEventsChan {
+		e := newStoreEvent(event)
+		if e == nil {
+			continue
+		}
+
+		s.subsMutex.Lock()
+		for sub := range s.subscribers {
+			select {
+			case sub.events <- e:
+			default:
+				// Don't block the store because of a slow subscriber.
+				s.endSubscription(sub, status.Error(codes.ResourceExhausted, "subscriber is too slow to receive store events"))
+			}
+		}
+		s.subsMutex.Unlock()
+	}
+
+	s.subsMutex.Lock()
+	for sub := range s.subscribers {
+		s.endSubscription(sub, nil)
+	}
+	s.subsMutex.Unlock()
+}
+
+func (s *Server) subscribe() *subscriber {
+	sub := &subscriber{
+		events: make(chan *StoreEvent, s.config.SubscriberChanSize),
+		done:   make(chan struct{}),
+	}
+
+	s.subsMutex.Lock()
+	s.subscribers[sub] = struct{}{}
+	s.subsMutex.Unlock()
+
+	return sub
+}
+
+func (s *Server) unsubscribe(sub *subscriber) {
+	s.subsMutex.Lock()
+	delete(s.subscribers, sub)
+	s.subsMutex.Unlock()
+}
+
+// endSubscription must be called with the subscribers mutex held.
+func (s *Server) endSubscription(sub *subscriber, err error) {
+	sub.err = err
+	close(sub.done)
+	delete(s.subscribers, sub)
+}
+
+// GetInfo implements StoreServer.GetInfo.
+func (s *Server) GetInfo(ctx context.Context, _ *InfoRequest) (*Info, error) {
+	span, ctx := monitoring.StartSpanIncomingRequest(ctx, "storegrpc/GetInfo")
+	defer span.End()
+
+	adapterInfo, err := s.adapter.GetInfo(ctx)
+	if err != nil {
+		monitoring.SetSpanStatus(span, err)
+		return nil, newStatusErr(err)
+	}
+
+	js, err := json.Marshal(adapterInfo)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &Info{Adapter: js}, nil
+}
+
+// CreateLink implements StoreServer.CreateLink.
+func (s *Server) CreateLink(ctx context.Context, link *chainscript.Link) (*LinkHash, error) {
+	span, ctx := monitoring.StartSpanIncomingRequest(ctx, "storegrpc/CreateLink")
+	defer span.End()
+
+	linkHash, err := s.adapter.CreateLink(ctx, link)
+	if err != nil {
+		monitoring.SetSpanStatus(span, err)
+		return nil, newStatusErr(err)
+	}
+
+	return &LinkHash{LinkHash: linkHash}, nil
+}
+
+// CreateLinks implements StoreServer.CreateLinks.
+func (s *Server) CreateLinks(ctx context.Context, links *Links) (*LinkHashes, error) {
+	span, ctx := monitoring.StartSpanIncomingRequest(ctx, "storegrpc/CreateLinks")
+	defer span.End()
+
+	batch, err := s.adapter.NewBatch(ctx)
+	if err != nil {
+		monitoring.SetSpanStatus(span, err)
+		return nil, newStatusErr(err)
+	}
+
+	res := &LinkHashes{}
+	for _, link := range links.Links {
+		linkHash, err := batch.CreateLink(ctx, link)
+		if err != nil {
+			monitoring.SetSpanStatus(span, err)
+			return nil, newStatusErr(err)
+		}
+
+		res.LinkHashes = append(res.LinkHashes, linkHash)
+	}
+
+	if err = batch.Write(ctx); err != nil {
+		monitoring.SetSpanStatus(span, err)
+		return nil, newStatusErr(err)
+	}
+
+	return res, nil
+}
+
+// AddEvidence implements StoreServer.AddEvidence.
+func (s *Server) AddEvidence(ctx context.Context, req *AddEvidenceRequest) (*AddEvidenceResponse, error) {
+	span, ctx := monitoring.StartSpanIncomingRequest(ctx, "storegrpc/AddEvidence")
+	defer span.End()
+
+	if req.Evidence == nil {
+		err := types.NewError(errorcode.InvalidArgument, store.Component, "evidence required")
+		monitoring.SetSpanStatus(span, err)
+		return nil, newStatusErr(err)
+	}
+
+	if err := s.adapter.AddEvidence(ctx, req.LinkHash, req.Evidence); err != nil {
+		monitoring.SetSpanStatus(span, err)
+		return nil, newStatusErr(err)
+	}
+
+	return &AddEvidenceResponse{}, nil
+}
+
+// GetSegment implements StoreServer.GetSegment.
+func (s *Server) GetSegment(ctx context.Context, req *LinkHash) (*chainscript.Segment, error) {
+	span, ctx := monitoring.StartSpanIncomingRequest(ctx, "storegrpc/GetSegment")
+	defer span.End()
+
+	seg, err := s.adapter.GetSegment(ctx, req.LinkHash)
+	if err != nil {
+		monitoring.SetSpanStatus(span, err)
+		return nil, newStatusErr(err)
+	}
+	if seg == nil {
+		span.Context.SetTag(monitoring.ErrorCodeLabel, errorcode.Text(errorcode.NotFound))
+		return nil, status.Error(codes.NotFound, "segment not found")
+	}
+
+	return seg, nil
+}
+
+// FindSegments implements StoreServer.FindSegments.
+func (s *Server) FindSegments(ctx context.Context, req *SegmentFilter) (*PaginatedSegments, error) {
+	span, ctx := monitoring.StartSpanIncomingRequest(ctx, "storegrpc/FindSegments")
+	defer span.End()
+
+	filter, err := newSegmentFilter(req)
+	if err != nil {
+		monitoring.SetSpanStatus(span, err)
+		return nil, newStatusErr(err)
+	}
+
+	segments, err := s.adapter.FindSegments(ctx, filter)
+	if err != nil {
+		monitoring.SetSpanStatus(span, err)
+		return nil, newStatusErr(err)
+	}
+
+	return &PaginatedSegments{
+		Segments:   segments.Segments,
+		TotalCount: int32(segments.TotalCount),
+		NextCursor: segments.NextCursor,
+	}, nil
+}
+
+// GetMapIDs implements StoreServer.GetMapIDs.
+func (s *Server) GetMapIDs(ctx context.Context, req *MapFilter) (*MapIDs, error) {
+	span, ctx := monitoring.StartSpanIncomingRequest(ctx, "storegrpc/GetMapIDs")
+	defer span.End()
+
+	filter, err := newMapFilter(req)
+	if err != nil {
+		monitoring.SetSpanStatus(span, err)
+		return nil, newStatusErr(err)
+	}
+
+	mapIDs, err := s.adapter.GetMapIDs(ctx, filter)
+	if err != nil {
+		monitoring.SetSpanStatus(span, err)
+		return nil, newStatusErr(err)
+	}
+
+	return &MapIDs{MapIds: mapIDs}, nil
+}
+
+// Subscribe implements StoreServer.Subscribe.
+// Events are streamed until the client cancels the call or the server shuts
+// down. Subscribers that can't keep up are disconnected with a
+// RESOURCE_EXHAUSTED status.
+func (s *Server) Subscribe(_ *SubscribeRequest, stream Store_SubscribeServer) error {
+	sub := s.subscribe()
+	defer s.unsubscribe(sub)
+
+	for {
+		select {
+		case e := <-sub.events:
+			if err := stream.Send(e); err != nil {
+				return err
+			}
+		case <-sub.done:
+			return sub.err
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,1282 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: storegrpc.proto
+
+package storegrpc
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	timestamp "github.com/golang/protobuf/ptypes/timestamp"
+	chainscript "github.com/stratumn/go-chainscript"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type StoreEvent_EventType int32
+
+const (
+	StoreEvent_SAVED_LINKS     StoreEvent_EventType = 0
+	StoreEvent_SAVED_EVIDENCES StoreEvent_EventType = 1
+)
+
+var StoreEvent_EventType_name = map[int32]string{
+	0: "SAVED_LINKS",
+	1: "SAVED_EVIDENCES",
+}
+
+var StoreEvent_EventType_value = map[string]int32{
+	"SAVED_LINKS":     0,
+	"SAVED_EVIDENCES": 1,
+}
+
+func (x StoreEvent_EventType) String() string {
+	return proto.EnumName(StoreEvent_EventType_name, int32(x))
+}
+
+func (StoreEvent_EventType) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_362232ae99eeaebb, []int{14, 0}
+}
+
+type InfoRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *InfoRequest) Reset()         { *m = InfoRequest{} }
+func (m *InfoRequest) String() string { return proto.CompactTextString(m) }
+func (*InfoRequest) ProtoMessage()    {}
+func (*InfoRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_362232ae99eeaebb, []int{0}
+}
+func (m *InfoRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_InfoRequest.Unmarshal(m, b)
+}
+func (m *InfoRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_InfoRequest.Marshal(b, m, deterministic)
+}
+func (m *InfoRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_InfoRequest.Merge(m, src)
+}
+func (m *InfoRequest) XXX_Size() int {
+	return xxx_messageInfo_InfoRequest.Size(m)
+}
+func (m *InfoRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_InfoRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_InfoRequest proto.InternalMessageInfo
+
+type Info struct {
+	// JSON-encoded information returned by the store adapter.
+	Adapter              []byte   `protobuf:"bytes,1,opt,name=adapter,proto3" json:"adapter,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Info) Reset()         { *m = Info{} }
+func (m *Info) String() string { return proto.CompactTextString(m) }
+func (*Info) ProtoMessage()    {}
+func (*Info) Descriptor() ([]byte, []int) {
+	return fileDescriptor_362232ae99eeaebb, []int{1}
+}
+func (m *Info) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Info.Unmarshal(m, b)
+}
+func (m *Info) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Info.Marshal(b, m, deterministic)
+}
+func (m *Info) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Info.Merge(m, src)
+}
+func (m *Info) XXX_Size() int {
+	return xxx_messageInfo_Info.Size(m)
+}
+func (m *Info) XXX_DiscardUnknown() {
+	xxx_messageInfo_Info.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Info proto.InternalMessageInfo
+
+func (m *Info) GetAdapter() []byte {
+	if m != nil {
+		return m.Adapter
+	}
+	return nil
+}
+
+type LinkHash struct {
+	LinkHash             []byte   `protobuf:"bytes,1,opt,name=link_hash,json=linkHash,proto3" json:"link_hash,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LinkHash) Reset()         { *m = LinkHash{} }
+func (m *LinkHash) String() string { return proto.CompactTextString(m) }
+func (*LinkHash) ProtoMessage()    {}
+func (*LinkHash) Descriptor() ([]byte, []int) {
+	return fileDescriptor_362232ae99eeaebb, []int{2}
+}
+func (m *LinkHash) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LinkHash.Unmarshal(m, b)
+}
+func (m *LinkHash) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LinkHash.Marshal(b, m, deterministic)
+}
+func (m *LinkHash) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LinkHash.Merge(m, src)
+}
+func (m *LinkHash) XXX_Size() int {
+	return xxx_messageInfo_LinkHash.Size(m)
+}
+func (m *LinkHash) XXX_DiscardUnknown() {
+	xxx_messageInfo_LinkHash.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LinkHash proto.InternalMessageInfo
+
+func (m *LinkHash) GetLinkHash() []byte {
+	if m != nil {
+		return m.LinkHash
+	}
+	return nil
+}
+
+type Links struct {
+	Links                []*chainscript.Link `protobuf:"bytes,1,rep,name=links,proto3" json:"links,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}            `json:"-"`
+	XXX_unrecognized     []byte              `json:"-"`
+	XXX_sizecache        int32               `json:"-"`
+}
+
+func (m *Links) Reset()         { *m = Links{} }
+func (m *Links) String() string { return proto.CompactTextString(m) }
+func (*Links) ProtoMessage()    {}
+func (*Links) Descriptor() ([]byte, []int) {
+	return fileDescriptor_362232ae99eeaebb, []int{3}
+}
+func (m *Links) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Links.Unmarshal(m, b)
+}
+func (m *Links) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Links.Marshal(b, m, deterministic)
+}
+func (m *Links) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Links.Merge(m, src)
+}
+func (m *Links) XXX_Size() int {
+	return xxx_messageInfo_Links.Size(m)
+}
+func (m *Links) XXX_DiscardUnknown() {
+	xxx_messageInfo_Links.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Links proto.InternalMessageInfo
+
+func (m *Links) GetLinks() []*chainscript.Link {
+	if m != nil {
+		return m.Links
+	}
+	return nil
+}
+
+type LinkHashes struct {
+	LinkHashes           [][]byte `protobuf:"bytes,1,rep,name=link_hashes,json=linkHashes,proto3" json:"link_hashes,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LinkHashes) Reset()         { *m = LinkHashes{} }
+func (m *LinkHashes) String() string { return proto.CompactTextString(m) }
+func (*LinkHashes) ProtoMessage()    {}
+func (*LinkHashes) Descriptor() ([]byte, []int) {
+	return fileDescriptor_362232ae99eeaebb, []int{4}
+}
+func (m *LinkHashes) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LinkHashes.Unmarshal(m, b)
+}
+func (m *LinkHashes) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LinkHashes.Marshal(b, m, deterministic)
+}
+func (m *LinkHashes) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LinkHashes.Merge(m, src)
+}
+func (m *LinkHashes) XXX_Size() int {
+	return xxx_messageInfo_LinkHashes.Size(m)
+}
+func (m *LinkHashes) XXX_DiscardUnknown() {
+	xxx_messageInfo_LinkHashes.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LinkHashes proto.InternalMessageInfo
+
+func (m *LinkHashes) GetLinkHashes() [][]byte {
+	if m != nil {
+		return m.LinkHashes
+	}
+	return nil
+}
+
+type AddEvidenceRequest struct {
+	LinkHash             []byte                `protobuf:"bytes,1,opt,name=link_hash,json=linkHash,proto3" json:"link_hash,omitempty"`
+	Evidence             *chainscript.Evidence `protobuf:"bytes,2,opt,name=evidence,proto3" json:"evidence,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}              `json:"-"`
+	XXX_unrecognized     []byte                `json:"-"`
+	XXX_sizecache        int32                 `json:"-"`
+}
+
+func (m *AddEvidenceRequest) Reset()         { *m = AddEvidenceRequest{} }
+func (m *AddEvidenceRequest) String() string { return proto.CompactTextString(m) }
+func (*AddEvidenceRequest) ProtoMessage()    {}
+func (*AddEvidenceRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_362232ae99eeaebb, []int{5}
+}
+func (m *AddEvidenceRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AddEvidenceRequest.Unmarshal(m, b)
+}
+func (m *AddEvidenceRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AddEvidenceRequest.Marshal(b, m, deterministic)
+}
+func (m *AddEvidenceRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AddEvidenceRequest.Merge(m, src)
+}
+func (m *AddEvidenceRequest) XXX_Size() int {
+	return xxx_messageInfo_AddEvidenceRequest.Size(m)
+}
+func (m *AddEvidenceRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_AddEvidenceRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AddEvidenceRequest proto.InternalMessageInfo
+
+func (m *AddEvidenceRequest) GetLinkHash() []byte {
+	if m != nil {
+		return m.LinkHash
+	}
+	return nil
+}
+
+func (m *AddEvidenceRequest) GetEvidence() *chainscript.Evidence {
+	if m != nil {
+		return m.Evidence
+	}
+	return nil
+}
+
+type AddEvidenceResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AddEvidenceResponse) Reset()         { *m = AddEvidenceResponse{} }
+func (m *AddEvidenceResponse) String() string { return proto.CompactTextString(m) }
+func (*AddEvidenceResponse) ProtoMessage()    {}
+func (*AddEvidenceResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_362232ae99eeaebb, []int{6}
+}
+func (m *AddEvidenceResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AddEvidenceResponse.Unmarshal(m, b)
+}
+func (m *AddEvidenceResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AddEvidenceResponse.Marshal(b, m, deterministic)
+}
+func (m *AddEvidenceResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AddEvidenceResponse.Merge(m, src)
+}
+func (m *AddEvidenceResponse) XXX_Size() int {
+	return xxx_messageInfo_AddEvidenceResponse.Size(m)
+}
+func (m *AddEvidenceResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_AddEvidenceResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AddEvidenceResponse proto.InternalMessageInfo
+
+type Pagination struct {
+	// Index of the first entry.
+	Offset int32 `protobuf:"varint,1,opt,name=offset,proto3" json:"offset,omitempty"`
+	// Maximum number of entries (defaults to 20, at most 200).
+	Limit int32 `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	// Position after which entries should be returned.
+	// When a cursor is given, the offset is ignored.
+	Cursor               string   `protobuf:"bytes,3,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Pagination) Reset()         { *m = Pagination{} }
+func (m *Pagination) String() string { return proto.CompactTextString(m) }
+func (*Pagination) ProtoMessage()    {}
+func (*Pagination) Descriptor() ([]byte, []int) {
+	return fileDescriptor_362232ae99eeaebb, []int{7}
+}
+func (m *Pagination) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Pagination.Unmarshal(m, b)
+}
+func (m *Pagination) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Pagination.Marshal(b, m, deterministic)
+}
+func (m *Pagination) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Pagination.Merge(m, src)
+}
+func (m *Pagination) XXX_Size() int {
+	return xxx_messageInfo_Pagination.Size(m)
+}
+func (m *Pagination) XXX_DiscardUnknown() {
+	xxx_messageInfo_Pagination.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Pagination proto.InternalMessageInfo
+
+func (m *Pagination) GetOffset() int32 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
+func (m *Pagination) GetLimit() int32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+func (m *Pagination) GetCursor() string {
+	if m != nil {
+		return m.Cursor
+	}
+	return ""
+}
+
+type SegmentFilter struct {
+	Pagination    *Pagination `protobuf:"bytes,1,opt,name=pagination,proto3" json:"pagination,omitempty"`
+	MapIds        []string    `protobuf:"bytes,2,rep,name=map_ids,json=mapIds,proto3" json:"map_ids,omitempty"`
+	Process       string      `protobuf:"bytes,3,opt,name=process,proto3" json:"process,omitempty"`
+	Processes     []string    `protobuf:"bytes,4,rep,name=processes,proto3" json:"processes,omitempty"`
+	Step          string      `protobuf:"bytes,5,opt,name=step,proto3" json:"step,omitempty"`
+	Steps         []string    `protobuf:"bytes,6,rep,name=steps,proto3" json:"steps,omitempty"`
+	WithoutParent bool        `protobuf:"varint,7,opt,name=without_parent,json=withoutParent,proto3" json:"without_parent,omitempty"`
+	PrevLinkHash  []byte      `protobuf:"bytes,8,opt,name=prev_link_hash,json=prevLinkHash,proto3" json:"prev_link_hash,omitempty"`
+	LinkHashes    [][]byte    `protobuf:"bytes,9,rep,name=link_hashes,json=linkHashes,proto3" json:"link_hashes,omitempty"`
+	Referencing   []byte      `protobuf:"bytes,10,opt,name=referencing,proto3" json:"referencing,omitempty"`
+	Tags          []string    `protobuf:"bytes,11,rep,name=tags,proto3" json:"tags,omitempty"`
+	TagsAny       []string    `protobuf:"bytes,12,rep,name=tags_any,json=tagsAny,proto3" json:"tags_any,omitempty"`
+	// JSON-encoded array of predicates on the link data
+	// (see github.com/stratumn/go-core/store.DataPredicate).
+	Data                 []byte               `protobuf:"bytes,13,opt,name=data,proto3" json:"data,omitempty"`
+	CreatedAfter         *timestamp.Timestamp `protobuf:"bytes,14,opt,name=created_after,json=createdAfter,proto3" json:"created_after,omitempty"`
+	CreatedBefore        *timestamp.Timestamp `protobuf:"bytes,15,opt,name=created_before,json=createdBefore,proto3" json:"created_before,omitempty"`
+	Reverse              bool                 `protobuf:"varint,16,opt,name=reverse,proto3" json:"reverse,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *SegmentFilter) Reset()         { *m = SegmentFilter{} }
+func (m *SegmentFilter) String() string { return proto.CompactTextString(m) }
+func (*SegmentFilter) ProtoMessage()    {}
+func (*SegmentFilter) Descriptor() ([]byte, []int) {
+	return fileDescriptor_362232ae99eeaebb, []int{8}
+}
+func (m *SegmentFilter) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SegmentFilter.Unmarshal(m, b)
+}
+func (m *SegmentFilter) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SegmentFilter.Marshal(b, m, deterministic)
+}
+func (m *SegmentFilter) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SegmentFilter.Merge(m, src)
+}
+func (m *SegmentFilter) XXX_Size() int {
+	return xxx_messageInfo_SegmentFilter.Size(m)
+}
+func (m *SegmentFilter) XXX_DiscardUnknown() {
+	xxx_messageInfo_SegmentFilter.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SegmentFilter proto.InternalMessageInfo
+
+func (m *SegmentFilter) GetPagination() *Pagination {
+	if m != nil {
+		return m.Pagination
+	}
+	return nil
+}
+
+func (m *SegmentFilter) GetMapIds() []string {
+	if m != nil {
+		return m.MapIds
+	}
+	return nil
+}
+
+func (m *SegmentFilter) GetProcess() string {
+	if m != nil {
+		return m.Process
+	}
+	return ""
+}
+
+func (m *SegmentFilter) GetProcesses() []string {
+	if m != nil {
+		return m.Processes
+	}
+	return nil
+}
+
+func (m *SegmentFilter) GetStep() string {
+	if m != nil {
+		return m.Step
+	}
+	return ""
+}
+
+func (m *SegmentFilter) GetSteps() []string {
+	if m != nil {
+		return m.Steps
+	}
+	return nil
+}
+
+func (m *SegmentFilter) GetWithoutParent() bool {
+	if m != nil {
+		return m.WithoutParent
+	}
+	return false
+}
+
+func (m *SegmentFilter) GetPrevLinkHash() []byte {
+	if m != nil {
+		return m.PrevLinkHash
+	}
+	return nil
+}
+
+func (m *SegmentFilter) GetLinkHashes() [][]byte {
+	if m != nil {
+		return m.LinkHashes
+	}
+	return nil
+}
+
+func (m *SegmentFilter) GetReferencing() []byte {
+	if m != nil {
+		return m.Referencing
+	}
+	return nil
+}
+
+func (m *SegmentFilter) GetTags() []string {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
+func (m *SegmentFilter) GetTagsAny() []string {
+	if m != nil {
+		return m.TagsAny
+	}
+	return nil
+}
+
+func (m *SegmentFilter) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *SegmentFilter) GetCreatedAfter() *timestamp.Timestamp {
+	if m != nil {
+		return m.CreatedAfter
+	}
+	return nil
+}
+
+func (m *SegmentFilter) GetCreatedBefore() *timestamp.Timestamp {
+	if m != nil {
+		return m.CreatedBefore
+	}
+	return nil
+}
+
+func (m *SegmentFilter) GetReverse() bool {
+	if m != nil {
+		return m.Reverse
+	}
+	return false
+}
+
+type PaginatedSegments struct {
+	Segments             []*chainscript.Segment `protobuf:"bytes,1,rep,name=segments,proto3" json:"segments,omitempty"`
+	TotalCount           int32                  `protobuf:"varint,2,opt,name=total_count,json=totalCount,proto3" json:"total_count,omitempty"`
+	NextCursor           string                 `protobuf:"bytes,3,opt,name=next_cursor,json=nextCursor,proto3" json:"next_cursor,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}               `json:"-"`
+	XXX_unrecognized     []byte                 `json:"-"`
+	XXX_sizecache        int32                  `json:"-"`
+}
+
+func (m *PaginatedSegments) Reset()         { *m = PaginatedSegments{} }
+func (m *PaginatedSegments) String() string { return proto.CompactTextString(m) }
+func (*PaginatedSegments) ProtoMessage()    {}
+func (*PaginatedSegments) Descriptor() ([]byte, []int) {
+	return fileDescriptor_362232ae99eeaebb, []int{9}
+}
+func (m *PaginatedSegments) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PaginatedSegments.Unmarshal(m, b)
+}
+func (m *PaginatedSegments) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PaginatedSegments.Marshal(b, m, deterministic)
+}
+func (m *PaginatedSegments) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PaginatedSegments.Merge(m, src)
+}
+func (m *PaginatedSegments) XXX_Size() int {
+	return xxx_messageInfo_PaginatedSegments.Size(m)
+}
+func (m *PaginatedSegments) XXX_DiscardUnknown() {
+	xxx_messageInfo_PaginatedSegments.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PaginatedSegments proto.InternalMessageInfo
+
+func (m *PaginatedSegments) GetSegments() []*chainscript.Segment {
+	if m != nil {
+		return m.Segments
+	}
+	return nil
+}
+
+func (m *PaginatedSegments) GetTotalCount() int32 {
+	if m != nil {
+		return m.TotalCount
+	}
+	return 0
+}
+
+func (m *PaginatedSegments) GetNextCursor() string {
+	if m != nil {
+		return m.NextCursor
+	}
+	return ""
+}
+
+type MapFilter struct {
+	Pagination           *Pagination `protobuf:"bytes,1,opt,name=pagination,proto3" json:"pagination,omitempty"`
+	Process              string      `protobuf:"bytes,2,opt,name=process,proto3" json:"process,omitempty"`
+	Prefix               string      `protobuf:"bytes,3,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	Suffix               string      `protobuf:"bytes,4,opt,name=suffix,proto3" json:"suffix,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}    `json:"-"`
+	XXX_unrecognized     []byte      `json:"-"`
+	XXX_sizecache        int32       `json:"-"`
+}
+
+func (m *MapFilter) Reset()         { *m = MapFilter{} }
+func (m *MapFilter) String() string { return proto.CompactTextString(m) }
+func (*MapFilter) ProtoMessage()    {}
+func (*MapFilter) Descriptor() ([]byte, []int) {
+	return fileDescriptor_362232ae99eeaebb, []int{10}
+}
+func (m *MapFilter) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_MapFilter.Unmarshal(m, b)
+}
+func (m *MapFilter) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_MapFilter.Marshal(b, m, deterministic)
+}
+func (m *MapFilter) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MapFilter.Merge(m, src)
+}
+func (m *MapFilter) XXX_Size() int {
+	return xxx_messageInfo_MapFilter.Size(m)
+}
+func (m *MapFilter) XXX_DiscardUnknown() {
+	xxx_messageInfo_MapFilter.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MapFilter proto.InternalMessageInfo
+
+func (m *MapFilter) GetPagination() *Pagination {
+	if m != nil {
+		return m.Pagination
+	}
+	return nil
+}
+
+func (m *MapFilter) GetProcess() string {
+	if m != nil {
+		return m.Process
+	}
+	return ""
+}
+
+func (m *MapFilter) GetPrefix() string {
+	if m != nil {
+		return m.Prefix
+	}
+	return ""
+}
+
+func (m *MapFilter) GetSuffix() string {
+	if m != nil {
+		return m.Suffix
+	}
+	return ""
+}
+
+type MapIDs struct {
+	MapIds               []string `protobuf:"bytes,1,rep,name=map_ids,json=mapIds,proto3" json:"map_ids,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *MapIDs) Reset()         { *m = MapIDs{} }
+func (m *MapIDs) String() string { return proto.CompactTextString(m) }
+func (*MapIDs) ProtoMessage()    {}
+func (*MapIDs) Descriptor() ([]byte, []int) {
+	return fileDescriptor_362232ae99eeaebb, []int{11}
+}
+func (m *MapIDs) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_MapIDs.Unmarshal(m, b)
+}
+func (m *MapIDs) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_MapIDs.Marshal(b, m, deterministic)
+}
+func (m *MapIDs) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MapIDs.Merge(m, src)
+}
+func (m *MapIDs) XXX_Size() int {
+	return xxx_messageInfo_MapIDs.Size(m)
+}
+func (m *MapIDs) XXX_DiscardUnknown() {
+	xxx_messageInfo_MapIDs.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MapIDs proto.InternalMessageInfo
+
+func (m *MapIDs) GetMapIds() []string {
+	if m != nil {
+		return m.MapIds
+	}
+	return nil
+}
+
+type SubscribeRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SubscribeRequest) Reset()         { *m = SubscribeRequest{} }
+func (m *SubscribeRequest) String() string { return proto.CompactTextString(m) }
+func (*SubscribeRequest) ProtoMessage()    {}
+func (*SubscribeRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_362232ae99eeaebb, []int{12}
+}
+func (m *SubscribeRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SubscribeRequest.Unmarshal(m, b)
+}
+func (m *SubscribeRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SubscribeRequest.Marshal(b, m, deterministic)
+}
+func (m *SubscribeRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SubscribeRequest.Merge(m, src)
+}
+func (m *SubscribeRequest) XXX_Size() int {
+	return xxx_messageInfo_SubscribeRequest.Size(m)
+}
+func (m *SubscribeRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SubscribeRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SubscribeRequest proto.InternalMessageInfo
+
+type SavedEvidence struct {
+	LinkHash             []byte                `protobuf:"bytes,1,opt,name=link_hash,json=linkHash,proto3" json:"link_hash,omitempty"`
+	Evidence             *chainscript.Evidence `protobuf:"bytes,2,opt,name=evidence,proto3" json:"evidence,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}              `json:"-"`
+	XXX_unrecognized     []byte                `json:"-"`
+	XXX_sizecache        int32                 `json:"-"`
+}
+
+func (m *SavedEvidence) Reset()         { *m = SavedEvidence{} }
+func (m *SavedEvidence) String() string { return proto.CompactTextString(m) }
+func (*SavedEvidence) ProtoMessage()    {}
+func (*SavedEvidence) Descriptor() ([]byte, []int) {
+	return fileDescriptor_362232ae99eeaebb, []int{13}
+}
+func (m *SavedEvidence) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SavedEvidence.Unmarshal(m, b)
+}
+func (m *SavedEvidence) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SavedEvidence.Marshal(b, m, deterministic)
+}
+func (m *SavedEvidence) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SavedEvidence.Merge(m, src)
+}
+func (m *SavedEvidence) XXX_Size() int {
+	return xxx_messageInfo_SavedEvidence.Size(m)
+}
+func (m *SavedEvidence) XXX_DiscardUnknown() {
+	xxx_messageInfo_SavedEvidence.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SavedEvidence proto.InternalMessageInfo
+
+func (m *SavedEvidence) GetLinkHash() []byte {
+	if m != nil {
+		return m.LinkHash
+	}
+	return nil
+}
+
+func (m *SavedEvidence) GetEvidence() *chainscript.Evidence {
+	if m != nil {
+		return m.Evidence
+	}
+	return nil
+}
+
+type StoreEvent struct {
+	Type StoreEvent_EventType `protobuf:"varint,1,opt,name=type,proto3,enum=stratumn.core.storegrpc.StoreEvent_EventType" json:"type,omitempty"`
+	// Set for SAVED_LINKS events.
+	Links []*chainscript.Link `protobuf:"bytes,2,rep,name=links,proto3" json:"links,omitempty"`
+	// Set for SAVED_EVIDENCES events.
+	Evidences            []*SavedEvidence `protobuf:"bytes,3,rep,name=evidences,proto3" json:"evidences,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}         `json:"-"`
+	XXX_unrecognized     []byte           `json:"-"`
+	XXX_sizecache        int32            `json:"-"`
+}
+
+func (m *StoreEvent) Reset()         { *m = StoreEvent{} }
+func (m *StoreEvent) String() string { return proto.CompactTextString(m) }
+func (*StoreEvent) ProtoMessage()    {}
+func (*StoreEvent) Descriptor() ([]byte, []int) {
+	return fileDescriptor_362232ae99eeaebb, []int{14}
+}
+func (m *StoreEvent) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StoreEvent.Unmarshal(m, b)
+}
+func (m *StoreEvent) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StoreEvent.Marshal(b, m, deterministic)
+}
+func (m *StoreEvent) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StoreEvent.Merge(m, src)
+}
+func (m *StoreEvent) XXX_Size() int {
+	return xxx_messageInfo_StoreEvent.Size(m)
+}
+func (m *StoreEvent) XXX_DiscardUnknown() {
+	xxx_messageInfo_StoreEvent.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StoreEvent proto.InternalMessageInfo
+
+func (m *StoreEvent) GetType() StoreEvent_EventType {
+	if m != nil {
+		return m.Type
+	}
+	return StoreEvent_SAVED_LINKS
+}
+
+func (m *StoreEvent) GetLinks() []*chainscript.Link {
+	if m != nil {
+		return m.Links
+	}
+	return nil
+}
+
+func (m *StoreEvent) GetEvidences() []*SavedEvidence {
+	if m != nil {
+		return m.Evidences
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterEnum("stratumn.core.storegrpc.StoreEvent_EventType", StoreEvent_EventType_name, StoreEvent_EventType_value)
+	proto.RegisterType((*InfoRequest)(nil), "stratumn.core.storegrpc.InfoRequest")
+	proto.RegisterType((*Info)(nil), "stratumn.core.storegrpc.Info")
+	proto.RegisterType((*LinkHash)(nil), "stratumn.core.storegrpc.LinkHash")
+	proto.RegisterType((*Links)(nil), "stratumn.core.storegrpc.Links")
+	proto.RegisterType((*LinkHashes)(nil), "stratumn.core.storegrpc.LinkHashes")
+	proto.RegisterType((*AddEvidenceRequest)(nil), "stratumn.core.storegrpc.AddEvidenceRequest")
+	proto.RegisterType((*AddEvidenceResponse)(nil), "stratumn.core.storegrpc.AddEvidenceResponse")
+	proto.RegisterType((*Pagination)(nil), "stratumn.core.storegrpc.Pagination")
+	proto.RegisterType((*SegmentFilter)(nil), "stratumn.core.storegrpc.SegmentFilter")
+	proto.RegisterType((*PaginatedSegments)(nil), "stratumn.core.storegrpc.PaginatedSegments")
+	proto.RegisterType((*MapFilter)(nil), "stratumn.core.storegrpc.MapFilter")
+	proto.RegisterType((*MapIDs)(nil), "stratumn.core.storegrpc.MapIDs")
+	proto.RegisterType((*SubscribeRequest)(nil), "stratumn.core.storegrpc.SubscribeRequest")
+	proto.RegisterType((*SavedEvidence)(nil), "stratumn.core.storegrpc.SavedEvidence")
+	proto.RegisterType((*StoreEvent)(nil), "stratumn.core.storegrpc.StoreEvent")
+}
+
+func init() { proto.RegisterFile("storegrpc.proto", fileDescriptor_362232ae99eeaebb) }
+
+var fileDescriptor_362232ae99eeaebb = []byte{
+	// 983 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x55, 0xdd, 0x72, 0xdb, 0x44,
+	0x14, 0x46, 0x89, 0x1d, 0x5b, 0x47, 0xb6, 0x13, 0xb6, 0xd0, 0x0a, 0x41, 0x1b, 0x57, 0x2d, 0x60,
+	0x7e, 0x22, 0x17, 0xc3, 0x4d, 0xb9, 0x61, 0x5c, 0xc7, 0x0d, 0x9e, 0xb6, 0x99, 0x8c, 0xdc, 0xe9,
+	0x05, 0x33, 0x8c, 0x67, 0x2d, 0x1f, 0xdb, 0x9a, 0xda, 0x92, 0xd8, 0x5d, 0x99, 0xe6, 0x2d, 0xb8,
+	0xe6, 0x69, 0xb8, 0xe3, 0x9d, 0xb8, 0x62, 0x76, 0xf5, 0x9b, 0x50, 0x3b, 0xbd, 0xa0, 0x37, 0xf6,
+	0x9e, 0xa3, 0xef, 0xfc, 0xec, 0xee, 0xf9, 0xbe, 0x85, 0x43, 0x2e, 0x42, 0x86, 0x0b, 0x16, 0x79,
+	0x4e, 0xc4, 0x42, 0x11, 0x92, 0x3b, 0x5c, 0x30, 0x2a, 0xe2, 0x75, 0xe0, 0x78, 0x21, 0x43, 0x27,
+	0xff, 0x6c, 0xfd, 0xb0, 0xf0, 0xc5, 0x32, 0x9e, 0x3a, 0x5e, 0xb8, 0xee, 0x66, 0x98, 0xee, 0x22,
+	0x3c, 0xf1, 0x96, 0xd4, 0x0f, 0xb8, 0xc7, 0xfc, 0x48, 0x74, 0x4b, 0xeb, 0x24, 0x9d, 0x75, 0xbc,
+	0x08, 0xc3, 0xc5, 0x0a, 0xbb, 0xca, 0x9a, 0xc6, 0xf3, 0xae, 0xf0, 0xd7, 0xc8, 0x05, 0x5d, 0x47,
+	0x09, 0xc0, 0x6e, 0x82, 0x31, 0x0a, 0xe6, 0xa1, 0x8b, 0xbf, 0xc5, 0xc8, 0x85, 0xdd, 0x86, 0x8a,
+	0x34, 0x89, 0x09, 0x35, 0x3a, 0xa3, 0x91, 0x40, 0x66, 0x6a, 0x6d, 0xad, 0xd3, 0x70, 0x33, 0xd3,
+	0xfe, 0x12, 0xea, 0xcf, 0xfd, 0xe0, 0xf5, 0xcf, 0x94, 0x2f, 0xc9, 0xa7, 0xa0, 0xaf, 0xfc, 0xe0,
+	0xf5, 0x64, 0x49, 0xf9, 0x32, 0xc5, 0xd5, 0x57, 0xe9, 0x47, 0xfb, 0x31, 0x54, 0x25, 0x90, 0x93,
+	0x47, 0x50, 0x95, 0x4e, 0x6e, 0x6a, 0xed, 0xfd, 0x8e, 0xd1, 0xb3, 0x9c, 0x62, 0x8b, 0xa5, 0x7e,
+	0x25, 0xd6, 0x4d, 0x80, 0xf6, 0x09, 0x40, 0x56, 0x03, 0x39, 0x39, 0x06, 0x23, 0xaf, 0x82, 0x49,
+	0x96, 0x86, 0x0b, 0xab, 0x1c, 0x60, 0xaf, 0x81, 0xf4, 0x67, 0xb3, 0xe1, 0xc6, 0x9f, 0x61, 0xe0,
+	0x61, 0xba, 0x95, 0x9d, 0xcd, 0x91, 0x1f, 0xa1, 0x8e, 0x29, 0xde, 0xdc, 0x6b, 0x6b, 0x1d, 0xa3,
+	0x77, 0xef, 0xed, 0x6d, 0xe5, 0x59, 0x73, 0xbc, 0xfd, 0x31, 0xdc, 0xba, 0x52, 0x8e, 0x47, 0x61,
+	0xc0, 0xd1, 0x76, 0x01, 0x2e, 0xe8, 0xc2, 0x0f, 0xa8, 0xf0, 0xc3, 0x80, 0xdc, 0x86, 0x83, 0x70,
+	0x3e, 0xe7, 0x28, 0x54, 0xe9, 0xaa, 0x9b, 0x5a, 0xe4, 0x23, 0x79, 0x18, 0x6b, 0x5f, 0xa8, 0xaa,
+	0x55, 0x37, 0x31, 0x24, 0xda, 0x8b, 0x19, 0x0f, 0x99, 0xb9, 0xdf, 0xd6, 0x3a, 0xba, 0x9b, 0x5a,
+	0xf6, 0x5f, 0x15, 0x68, 0x8e, 0x71, 0xb1, 0xc6, 0x40, 0x3c, 0xf5, 0x57, 0x02, 0x19, 0x19, 0x00,
+	0x44, 0x79, 0x15, 0x95, 0xdb, 0xe8, 0x3d, 0x70, 0xb6, 0x0c, 0x8d, 0x53, 0x34, 0xe4, 0x96, 0xc2,
+	0xc8, 0x1d, 0xa8, 0xad, 0x69, 0x34, 0xf1, 0x67, 0xdc, 0xdc, 0x6b, 0xef, 0xcb, 0x7a, 0x6b, 0x1a,
+	0x8d, 0x66, 0x5c, 0x5e, 0x7b, 0xc4, 0x42, 0x0f, 0x39, 0x4f, 0x1b, 0xc9, 0x4c, 0xf2, 0x19, 0xe8,
+	0xe9, 0x12, 0xb9, 0x59, 0x51, 0x41, 0x85, 0x83, 0x10, 0xa8, 0x70, 0x81, 0x91, 0x59, 0x55, 0x41,
+	0x6a, 0x2d, 0x77, 0x2a, 0xff, 0xb9, 0x79, 0xa0, 0xd0, 0x89, 0x41, 0x3e, 0x87, 0xd6, 0xef, 0xbe,
+	0x58, 0x86, 0xb1, 0x98, 0x44, 0x94, 0x61, 0x20, 0xcc, 0x5a, 0x5b, 0xeb, 0xd4, 0xdd, 0x66, 0xea,
+	0xbd, 0x50, 0x4e, 0xf2, 0x10, 0x5a, 0x11, 0xc3, 0xcd, 0xa4, 0xb8, 0xc1, 0xba, 0xba, 0xc1, 0x86,
+	0xf4, 0xe6, 0xf3, 0x77, 0x6d, 0x32, 0xf4, 0xeb, 0x93, 0x41, 0xda, 0x60, 0x30, 0x9c, 0x23, 0xc3,
+	0xc0, 0xf3, 0x83, 0x85, 0x09, 0x2a, 0x47, 0xd9, 0x25, 0x3b, 0x17, 0x74, 0xc1, 0x4d, 0x43, 0x35,
+	0xa9, 0xd6, 0xe4, 0x13, 0xa8, 0xcb, 0xff, 0x09, 0x0d, 0x2e, 0xcd, 0x86, 0xf2, 0xd7, 0xa4, 0xdd,
+	0x0f, 0x2e, 0x25, 0x7c, 0x46, 0x05, 0x35, 0x9b, 0x2a, 0x93, 0x5a, 0x93, 0x9f, 0xa0, 0xe9, 0x31,
+	0xa4, 0x02, 0x67, 0x13, 0x3a, 0x97, 0x8c, 0x69, 0xa9, 0x5b, 0xb1, 0x9c, 0x84, 0x7b, 0x4e, 0xc6,
+	0x3d, 0xe7, 0x65, 0xc6, 0x3d, 0xb7, 0x91, 0x06, 0xf4, 0x25, 0x9e, 0xf4, 0xa1, 0x95, 0x25, 0x98,
+	0xe2, 0x3c, 0x64, 0x68, 0x1e, 0xde, 0x98, 0x21, 0x2b, 0xf9, 0x44, 0x05, 0xc8, 0x8b, 0x63, 0xb8,
+	0x41, 0xc6, 0xd1, 0x3c, 0x52, 0xe7, 0x99, 0x99, 0xf6, 0x1f, 0x1a, 0x7c, 0x98, 0x8e, 0x01, 0xce,
+	0xd2, 0x59, 0xe2, 0xe4, 0x31, 0xd4, 0x79, 0xba, 0x4e, 0x69, 0x79, 0xf7, 0xed, 0xf3, 0x9f, 0x46,
+	0xb8, 0x39, 0x5c, 0x1e, 0xba, 0x08, 0x05, 0x5d, 0x4d, 0xbc, 0x30, 0x0e, 0xb2, 0x39, 0x06, 0xe5,
+	0x1a, 0x48, 0x8f, 0x04, 0x04, 0xf8, 0x46, 0x4c, 0xae, 0x4c, 0x34, 0x48, 0xd7, 0x20, 0x99, 0xea,
+	0x3f, 0x35, 0xd0, 0x5f, 0xd0, 0xe8, 0xff, 0x9c, 0xe8, 0xd2, 0xe0, 0xee, 0x5d, 0x1d, 0xdc, 0xdb,
+	0x70, 0x10, 0x31, 0x9c, 0xfb, 0x6f, 0x32, 0x6a, 0x25, 0x96, 0xf4, 0xf3, 0x78, 0x2e, 0xfd, 0x95,
+	0xc4, 0x9f, 0x58, 0xf6, 0x7d, 0x38, 0x78, 0x41, 0xa3, 0xd1, 0x29, 0x2f, 0xb3, 0x44, 0x2b, 0xb3,
+	0xc4, 0x26, 0x70, 0x34, 0x8e, 0xa7, 0xf2, 0x80, 0xa6, 0x99, 0xda, 0xd8, 0x4b, 0x68, 0x8e, 0xe9,
+	0x06, 0x73, 0x59, 0x78, 0x7f, 0xf2, 0xf3, 0x8f, 0x06, 0x30, 0x96, 0xe7, 0x31, 0xdc, 0x48, 0xa6,
+	0xf4, 0xa1, 0x22, 0x2e, 0x23, 0x54, 0x25, 0x5a, 0xbd, 0x93, 0xad, 0x07, 0x57, 0x84, 0x38, 0xea,
+	0xf7, 0xe5, 0x65, 0x84, 0xae, 0x0a, 0x2d, 0x04, 0x7a, 0xef, 0x1d, 0x05, 0x9a, 0x9c, 0x82, 0x9e,
+	0xf5, 0x23, 0x95, 0x42, 0x46, 0x7d, 0xb1, 0xbd, 0x72, 0xf9, 0x5c, 0xdc, 0x22, 0xd0, 0xfe, 0x0e,
+	0xf4, 0xbc, 0x15, 0x72, 0x08, 0xc6, 0xb8, 0xff, 0x6a, 0x78, 0x3a, 0x79, 0x3e, 0x3a, 0x7f, 0x36,
+	0x3e, 0xfa, 0x80, 0xdc, 0x82, 0xc3, 0xc4, 0x31, 0x7c, 0x35, 0x3a, 0x1d, 0x9e, 0x0f, 0x86, 0xe3,
+	0x23, 0xad, 0xf7, 0x77, 0x15, 0xaa, 0x6a, 0x27, 0xe4, 0x1c, 0x6a, 0x67, 0x28, 0xd4, 0x63, 0xf5,
+	0x70, 0x6b, 0xe9, 0xd2, 0xd3, 0x66, 0xdd, 0xdd, 0x89, 0x22, 0xcf, 0x00, 0x06, 0x8a, 0x52, 0x72,
+	0x9f, 0x64, 0xc7, 0x19, 0x58, 0xf7, 0xb7, 0x26, 0xca, 0x85, 0xc9, 0x05, 0xa3, 0x48, 0xc6, 0xc9,
+	0xbd, 0x9d, 0x11, 0xdc, 0x7a, 0x70, 0x63, 0x46, 0xe4, 0x64, 0x09, 0x46, 0xe9, 0xd9, 0x21, 0xdf,
+	0x6c, 0x8d, 0xf9, 0xef, 0x5b, 0x68, 0x7d, 0xfb, 0x6e, 0xe0, 0xe4, 0x25, 0x23, 0xe7, 0x00, 0x67,
+	0x28, 0x52, 0xe6, 0x93, 0x9b, 0xb7, 0x6b, 0xed, 0xd6, 0x0e, 0x32, 0x85, 0xc6, 0x53, 0x3f, 0x28,
+	0xc4, 0x67, 0xc7, 0xa8, 0x94, 0xdf, 0x3a, 0xeb, 0xeb, 0x9b, 0x54, 0xa0, 0x24, 0x68, 0x17, 0xa0,
+	0x9f, 0xa1, 0x48, 0x99, 0x6b, 0x6f, 0x0d, 0xcc, 0x65, 0xc7, 0x3a, 0xde, 0x85, 0x91, 0x49, 0x7e,
+	0x05, 0x3d, 0x67, 0x39, 0xf9, 0x6a, 0x7b, 0xcb, 0xd7, 0x94, 0x60, 0xc7, 0x65, 0x16, 0x14, 0x7c,
+	0xa4, 0x3d, 0x31, 0x7e, 0xd1, 0xf3, 0x2f, 0xd3, 0x03, 0xa5, 0xf0, 0xdf, 0xff, 0x3b, 0x00, 0x09,
+	0x18, 0xb8, 0x58, 0x0f, 0x0a, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// StoreClient is the client API for Store service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type StoreClient interface {
+	// GetInfo returns information about the store adapter.
+	GetInfo(ctx context.Context, in *InfoRequest, opts ...grpc.CallOption) (*Info, error)
+	// CreateLink saves a link and returns its hash.
+	CreateLink(ctx context.Context, in *chainscript.Link, opts ...grpc.CallOption) (*LinkHash, error)
+	// CreateLinks atomically saves a collection of links and returns their
+	// hashes. If any of the links is invalid, the whole batch is dropped.
+	CreateLinks(ctx context.Context, in *Links, opts ...grpc.CallOption) (*LinkHashes, error)
+	// AddEvidence adds an evidence to a link.
+	AddEvidence(ctx context.Context, in *AddEvidenceRequest, opts ...grpc.CallOption) (*AddEvidenceResponse, error)
+	// GetSegment returns the segment of a link.
+	// It fails with a NOT_FOUND status if the link doesn't exist.
+	GetSegment(ctx context.Context, in *LinkHash, opts ...grpc.CallOption) (*chainscript.Segment, error)
+	// FindSegments returns the segments matching a filter.
+	FindSegments(ctx context.Context, in *SegmentFilter, opts ...grpc.CallOption) (*PaginatedSegments, error)
+	// GetMapIDs returns the map IDs matching a filter.
+	GetMapIDs(ctx context.Context, in *MapFilter, opts ...grpc.CallOption) (*MapIDs, error)
+	// Subscribe streams the events of the store.
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (Store_SubscribeClient, error)
+}
+
+type storeClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewStoreClient(cc *grpc.ClientConn) StoreClient {
+	return &storeClient{cc}
+}
+
+func (c *storeClient) GetInfo(ctx context.Context, in *InfoRequest, opts ...grpc.CallOption) (*Info, error) {
+	out := new(Info)
+	err := c.cc.Invoke(ctx, "/stratumn.core.storegrpc.Store/GetInfo", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeClient) CreateLink(ctx context.Context, in *chainscript.Link, opts ...grpc.CallOption) (*LinkHash, error) {
+	out := new(LinkHash)
+	err := c.cc.Invoke(ctx, "/stratumn.core.storegrpc.Store/CreateLink", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeClient) CreateLinks(ctx context.Context, in *Links, opts ...grpc.CallOption) (*LinkHashes, error) {
+	out := new(LinkHashes)
+	err := c.cc.Invoke(ctx, "/stratumn.core.storegrpc.Store/CreateLinks", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeClient) AddEvidence(ctx context.Context, in *AddEvidenceRequest, opts ...grpc.CallOption) (*AddEvidenceResponse, error) {
+	out := new(AddEvidenceResponse)
+	err := c.cc.Invoke(ctx, "/stratumn.core.storegrpc.Store/AddEvidence", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeClient) GetSegment(ctx context.Context, in *LinkHash, opts ...grpc.CallOption) (*chainscript.Segment, error) {
+	out := new(chainscript.Segment)
+	err := c.cc.Invoke(ctx, "/stratumn.core.storegrpc.Store/GetSegment", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeClient) FindSegments(ctx context.Context, in *SegmentFilter, opts ...grpc.CallOption) (*PaginatedSegments, error) {
+	out := new(PaginatedSegments)
+	err := c.cc.Invoke(ctx, "/stratumn.core.storegrpc.Store/FindSegments", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeClient) GetMapIDs(ctx context.Context, in *MapFilter, opts ...grpc.CallOption) (*MapIDs, error) {
+	out := new(MapIDs)
+	err := c.cc.Invoke(ctx, "/stratumn.core.storegrpc.Store/GetMapIDs", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (Store_SubscribeClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Store_serviceDesc.Streams[0], "/stratumn.core.storegrpc.Store/Subscribe", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &storeSubscribeClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Store_SubscribeClient interface {
+	Recv() (*StoreEvent, error)
+	grpc.ClientStream
+}
+
+type storeSubscribeClient struct {
+	grpc.ClientStream
+}
+
+func (x *storeSubscribeClient) Recv() (*StoreEvent, error) {
+	m := new(StoreEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// StoreServer is the server API for Store service.
+type StoreServer interface {
+	// GetInfo returns information about the store adapter.
+	GetInfo(context.Context, *InfoRequest) (*Info, error)
+	// CreateLink saves a link and returns its hash.
+	CreateLink(context.Context, *chainscript.Link) (*LinkHash, error)
+	// CreateLinks atomically saves a collection of links and returns their
+	// hashes. If any of the links is invalid, the whole batch is dropped.
+	CreateLinks(context.Context, *Links) (*LinkHashes, error)
+	// AddEvidence adds an evidence to a link.
+	AddEvidence(context.Context, *AddEvidenceRequest) (*AddEvidenceResponse, error)
+	// GetSegment returns the segment of a link.
+	// It fails with a NOT_FOUND status if the link doesn't exist.
+	GetSegment(context.Context, *LinkHash) (*chainscript.Segment, error)
+	// FindSegments returns the segments matching a filter.
+	FindSegments(context.Context, *SegmentFilter) (*PaginatedSegments, error)
+	// GetMapIDs returns the map IDs matching a filter.
+	GetMapIDs(context.Context, *MapFilter) (*MapIDs, error)
+	// Subscribe streams the events of the store.
+	Subscribe(*SubscribeRequest, Store_SubscribeServer) error
+}
+
+// UnimplementedStoreServer can be embedded to have forward compatible implementations.
+type UnimplementedStoreServer struct {
+}
+
+func (*UnimplementedStoreServer) GetInfo(ctx context.Context, req *InfoRequest) (*Info, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetInfo not implemented")
+}
+func (*UnimplementedStoreServer) CreateLink(ctx context.Context, req *chainscript.Link) (*LinkHash, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateLink not implemented")
+}
+func (*UnimplementedStoreServer) CreateLinks(ctx context.Context, req *Links) (*LinkHashes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateLinks not implemented")
+}
+func (*UnimplementedStoreServer) AddEvidence(ctx context.Context, req *AddEvidenceRequest) (*AddEvidenceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddEvidence not implemented")
+}
+func (*UnimplementedStoreServer) GetSegment(ctx context.Context, req *LinkHash) (*chainscript.Segment, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSegment not implemented")
+}
+func (*UnimplementedStoreServer) FindSegments(ctx context.Context, req *SegmentFilter) (*PaginatedSegments, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FindSegments not implemented")
+}
+func (*UnimplementedStoreServer) GetMapIDs(ctx context.Context, req *MapFilter) (*MapIDs, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMapIDs not implemented")
+}
+func (*UnimplementedStoreServer) Subscribe(req *SubscribeRequest, srv Store_SubscribeServer) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+
+func RegisterStoreServer(s *grpc.Server, srv StoreServer) {
+	s.RegisterService(&_Store_serviceDesc, srv)
+}
+
+func _Store_GetInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreServer).GetInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/stratumn.core.storegrpc.Store/GetInfo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreServer).GetInfo(ctx, req.(*InfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Store_CreateLink_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(chainscript.Link)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreServer).CreateLink(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/stratumn.core.storegrpc.Store/CreateLink",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreServer).CreateLink(ctx, req.(*chainscript.Link))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Store_CreateLinks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Links)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreServer).CreateLinks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/stratumn.core.storegrpc.Store/CreateLinks",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreServer).CreateLinks(ctx, req.(*Links))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Store_AddEvidence_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddEvidenceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreServer).AddEvidence(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/stratumn.core.storegrpc.Store/AddEvidence",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreServer).AddEvidence(ctx, req.(*AddEvidenceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Store_GetSegment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LinkHash)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreServer).GetSegment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/stratumn.core.storegrpc.Store/GetSegment",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreServer).GetSegment(ctx, req.(*LinkHash))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Store_FindSegments_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SegmentFilter)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreServer).FindSegments(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/stratumn.core.storegrpc.Store/FindSegments",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreServer).FindSegments(ctx, req.(*SegmentFilter))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Store_GetMapIDs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MapFilter)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreServer).GetMapIDs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/stratumn.core.storegrpc.Store/GetMapIDs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreServer).GetMapIDs(ctx, req.(*MapFilter))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Store_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StoreServer).Subscribe(m, &storeSubscribeServer{stream})
+}
+
+type Store_SubscribeServer interface {
+	Send(*StoreEvent) error
+	grpc.ServerStream
+}
+
+type storeSubscribeServer struct {
+	grpc.ServerStream
+}
+
+func (x *storeSubscribeServer) Send(m *StoreEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _Store_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "stratumn.core.storegrpc.Store",
+	HandlerType: (*StoreServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetInfo",
+			Handler:    _Store_GetInfo_Handler,
+		},
+		{
+			MethodName: "CreateLink",
+			Handler:    _Store_CreateLink_Handler,
+		},
+		{
+			MethodName: "CreateLinks",
+			Handler:    _Store_CreateLinks_Handler,
+		},
+		{
+			MethodName: "AddEvidence",
+			Handler:    _Store_AddEvidence_Handler,
+		},
+		{
+			MethodName: "GetSegment",
+			Handler:    _Store_GetSegment_Handler,
+		},
+		{
+			MethodName: "FindSegments",
+			Handler:    _Store_FindSegments_Handler,
+		},
+		{
+			MethodName: "GetMapIDs",
+			Handler:    _Store_GetMapIDs_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Subscribe",
+			Handler:       _Store_Subscribe_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "storegrpc.proto",
+}
@@ -0,0 +1,370 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storegrpc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-chainscript/chainscripttest"
+	"github.com/stratumn/go-core/dummystore"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/store/storetesting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type testServer struct {
+	*Server
+	client StoreClient
+	conn   *grpc.ClientConn
+}
+
+func newTestServer(t *testing.T) *testServer {
+	a := storetesting.NewStartedStore(dummystore.New(&dummystore.Config{Version: "1.0.0"}))
+
+	s, err := New(a, &Config{StoreEventsChanSize: 8, SubscriberChanSize: 8})
+	require.NoError(t, err)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go s.Serve(lis)
+	<-a.Started
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+
+	return &testServer{Server: s, client: NewStoreClient(conn), conn: conn}
+}
+
+func (s *testServer) close() {
+	s.conn.Close()
+	s.Shutdown(context.Background())
+}
+
+// waitSubscribers waits until the server has registered n subscribers.
+func (s *testServer) waitSubscribers(n int) {
+	for {
+		s.subsMutex.Lock()
+		count := len(s.subscribers)
+		s.subsMutex.Unlock()
+
+		if count == n {
+			return
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func assertStatusCode(t *testing.T, err error, code codes.Code) {
+	require.Error(t, err)
+	assert.Equal(t, code, status.Code(err), err.Error())
+}
+
+func TestGetInfo(t *testing.T) {
+	s := newTestServer(t)
+	defer s.close()
+
+	info, err := s.client.GetInfo(context.Background(), &InfoRequest{})
+	require.NoError(t, err)
+
+	var adapterInfo dummystore.Info
+	require.NoError(t, json.Unmarshal(info.Adapter, &adapterInfo))
+	assert.Equal(t, dummystore.Name, adapterInfo.Name)
+	assert.Equal(t, "1.0.0", adapterInfo.Version)
+}
+
+func TestCreateLink(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	defer s.close()
+
+	t.Run("saves the link", func(t *testing.T) {
+		link := chainscripttest.RandomLink(t)
+		res, err := s.client.CreateLink(ctx, link)
+		require.NoError(t, err)
+
+		linkHash, _ := link.Hash()
+		assert.Equal(t, []byte(linkHash), res.LinkHash)
+
+		seg, err := s.client.GetSegment(ctx, res)
+		require.NoError(t, err)
+		chainscripttest.LinksEqual(t, link, seg.Link)
+	})
+
+	t.Run("forwards store error codes", func(t *testing.T) {
+		parent := chainscripttest.NewLinkBuilder(t).WithDegree(0).Build()
+		_, err := s.client.CreateLink(ctx, parent)
+		require.NoError(t, err)
+
+		child := chainscripttest.NewLinkBuilder(t).Branch(t, parent).Build()
+		_, err = s.client.CreateLink(ctx, child)
+		assertStatusCode(t, err, codes.FailedPrecondition)
+	})
+}
+
+func TestCreateLinks(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	defer s.close()
+
+	t.Run("saves all links", func(t *testing.T) {
+		l1 := chainscripttest.NewLinkBuilder(t).WithMapID("batch").Build()
+		l2 := chainscripttest.NewLinkBuilder(t).WithMapID("batch").Build()
+
+		res, err := s.client.CreateLinks(ctx, &Links{Links: []*chainscript.Link{l1, l2}})
+		require.NoError(t, err)
+		require.Len(t, res.LinkHashes, 2)
+
+		lh1, _ := l1.Hash()
+		assert.Equal(t, []byte(lh1), res.LinkHashes[0])
+
+		segments, err := s.client.FindSegments(ctx, &SegmentFilter{MapIds: []string{"batch"}})
+		require.NoError(t, err)
+		assert.Len(t, segments.Segments, 2)
+		assert.Equal(t, int32(2), segments.TotalCount)
+	})
+
+	t.Run("forwards batch errors", func(t *testing.T) {
+		parent := chainscripttest.NewLinkBuilder(t).WithDegree(0).Build()
+		_, err := s.client.CreateLink(ctx, parent)
+		require.NoError(t, err)
+
+		invalid := chainscripttest.NewLinkBuilder(t).Branch(t, parent).Build()
+
+		_, err = s.client.CreateLinks(ctx, &Links{Links: []*chainscript.Link{invalid}})
+		assertStatusCode(t, err, codes.FailedPrecondition)
+	})
+}
+
+func TestAddEvidence(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	defer s.close()
+
+	res, err := s.client.CreateLink(ctx, chainscripttest.RandomLink(t))
+	require.NoError(t, err)
+
+	t.Run("adds the evidence", func(t *testing.T) {
+		e := chainscripttest.RandomEvidence(t)
+		_, err := s.client.AddEvidence(ctx, &AddEvidenceRequest{LinkHash: res.LinkHash, Evidence: e})
+		require.NoError(t, err)
+
+		seg, err := s.client.GetSegment(ctx, res)
+		require.NoError(t, err)
+		require.Len(t, seg.Meta.Evidences, 1)
+		assert.Equal(t, e.Provider, seg.Meta.Evidences[0].Provider)
+		assert.Equal(t, e.Proof, seg.Meta.Evidences[0].Proof)
+	})
+
+	t.Run("missing evidence", func(t *testing.T) {
+		_, err := s.client.AddEvidence(ctx, &AddEvidenceRequest{LinkHash: res.LinkHash})
+		assertStatusCode(t, err, codes.InvalidArgument)
+	})
+}
+
+func TestGetSegment_notFound(t *testing.T) {
+	s := newTestServer(t)
+	defer s.close()
+
+	_, err := s.client.GetSegment(context.Background(), &LinkHash{LinkHash: chainscripttest.RandomHash()})
+	assertStatusCode(t, err, codes.NotFound)
+}
+
+func TestFindSegments(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	defer s.close()
+
+	before := time.Now()
+
+	var links []*chainscript.Link
+	for i := 0; i < 4; i++ {
+		link := chainscripttest.NewLinkBuilder(t).
+			WithProcess("p").
+			WithMapID("m").
+			WithTags("all").
+			WithData(t, map[string]interface{}{"index": i}).
+			Build()
+		_, err := s.client.CreateLink(ctx, link)
+		require.NoError(t, err)
+		links = append(links, link)
+	}
+
+	other := chainscripttest.NewLinkBuilder(t).WithProcess("other").WithRef(t, links[0]).Build()
+	_, err := s.client.CreateLink(ctx, other)
+	require.NoError(t, err)
+
+	t.Run("process and tags", func(t *testing.T) {
+		res, err := s.client.FindSegments(ctx, &SegmentFilter{Process: "p", Tags: []string{"all"}})
+		require.NoError(t, err)
+		assert.Len(t, res.Segments, 4)
+		assert.Equal(t, int32(4), res.TotalCount)
+	})
+
+	t.Run("pagination", func(t *testing.T) {
+		res, err := s.client.FindSegments(ctx, &SegmentFilter{
+			Pagination: &Pagination{Limit: 3},
+			Process:    "p",
+		})
+		require.NoError(t, err)
+		assert.Len(t, res.Segments, 3)
+		require.NotEmpty(t, res.NextCursor)
+
+		res, err = s.client.FindSegments(ctx, &SegmentFilter{
+			Pagination: &Pagination{Limit: 3, Cursor: res.NextCursor},
+			Process:    "p",
+		})
+		require.NoError(t, err)
+		assert.Len(t, res.Segments, 1)
+	})
+
+	t.Run("referencing", func(t *testing.T) {
+		lh, _ := links[0].Hash()
+		res, err := s.client.FindSegments(ctx, &SegmentFilter{Referencing: lh})
+		require.NoError(t, err)
+		require.Len(t, res.Segments, 1)
+		chainscripttest.LinksEqual(t, other, res.Segments[0].Link)
+	})
+
+	t.Run("data predicates", func(t *testing.T) {
+		data, _ := json.Marshal([]store.DataPredicate{{Path: "index", Op: store.DataIn, Values: []interface{}{1, 3}}})
+		res, err := s.client.FindSegments(ctx, &SegmentFilter{Process: "p", Data: data})
+		require.NoError(t, err)
+		assert.Len(t, res.Segments, 2)
+	})
+
+	t.Run("creation time", func(t *testing.T) {
+		createdBefore, _ := ptypes.TimestampProto(before)
+		res, err := s.client.FindSegments(ctx, &SegmentFilter{CreatedBefore: createdBefore})
+		require.NoError(t, err)
+		assert.Empty(t, res.Segments)
+
+		createdAfter, _ := ptypes.TimestampProto(before)
+		res, err = s.client.FindSegments(ctx, &SegmentFilter{CreatedAfter: createdAfter})
+		require.NoError(t, err)
+		assert.Len(t, res.Segments, 5)
+	})
+
+	t.Run("invalid filters", func(t *testing.T) {
+		for _, f := range []*SegmentFilter{
+			{Pagination: &Pagination{Limit: store.MaxLimit + 1}},
+			{Pagination: &Pagination{Offset: -1}},
+			{Pagination: &Pagination{Cursor: "not a cursor"}},
+			{Data: []byte("{")},
+			{Data: []byte(`[{"path":"index","op":"unknown"}]`)},
+		} {
+			_, err := s.client.FindSegments(ctx, f)
+			assertStatusCode(t, err, codes.InvalidArgument)
+		}
+	})
+}
+
+func TestGetMapIDs(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	defer s.close()
+
+	for _, mapID := range []string{"order-1", "order-2", "invoice-1"} {
+		link := chainscripttest.NewLinkBuilder(t).WithProcess("p").WithMapID(mapID).Build()
+		_, err := s.client.CreateLink(ctx, link)
+		require.NoError(t, err)
+	}
+
+	res, err := s.client.GetMapIDs(ctx, &MapFilter{Process: "p"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"order-1", "order-2", "invoice-1"}, res.MapIds)
+
+	res, err = s.client.GetMapIDs(ctx, &MapFilter{Process: "p", Prefix: "order-"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"order-1", "order-2"}, res.MapIds)
+
+	_, err = s.client.GetMapIDs(ctx, &MapFilter{Pagination: &Pagination{Limit: -1}})
+	assertStatusCode(t, err, codes.InvalidArgument)
+}
+
+func TestSubscribe(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	defer s.close()
+
+	stream, err := s.client.Subscribe(ctx, &SubscribeRequest{})
+	require.NoError(t, err)
+	s.waitSubscribers(1)
+
+	link := chainscripttest.RandomLink(t)
+	res, err := s.client.CreateLink(ctx, link)
+	require.NoError(t, err)
+
+	e, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, StoreEvent_SAVED_LINKS, e.Type)
+	require.Len(t, e.Links, 1)
+	chainscripttest.LinksEqual(t, link, e.Links[0])
+
+	evidence := chainscripttest.RandomEvidence(t)
+	_, err = s.client.AddEvidence(ctx, &AddEvidenceRequest{LinkHash: res.LinkHash, Evidence: evidence})
+	require.NoError(t, err)
+
+	e, err = stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, StoreEvent_SAVED_EVIDENCES, e.Type)
+	require.Len(t, e.Evidences, 1)
+	assert.Equal(t, res.LinkHash, e.Evidences[0].LinkHash)
+	assert.Equal(t, evidence.Provider, e.Evidences[0].Evidence.Provider)
+}
+
+func TestSubscribe_shutdown(t *testing.T) {
+	s := newTestServer(t)
+	defer s.conn.Close()
+
+	stream, err := s.client.Subscribe(context.Background(), &SubscribeRequest{})
+	require.NoError(t, err)
+	s.waitSubscribers(1)
+
+	require.NoError(t, s.Shutdown(context.Background()))
+
+	_, err = stream.Recv()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestSubscribe_slowSubscriber(t *testing.T) {
+	s := newTestServer(t)
+	defer s.close()
+
+	sub := s.subscribe()
+
+	for i := 0; i < cap(sub.events); i++ {
+		sub.events <- &StoreEvent{}
+	}
+
+	_, err := s.client.CreateLink(context.Background(), chainscripttest.RandomLink(t))
+	require.NoError(t, err)
+
+	<-sub.done
+	assert.Equal(t, codes.ResourceExhausted, status.Code(sub.err))
+	s.waitSubscribers(0)
+}
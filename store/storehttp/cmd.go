@@ -20,6 +20,7 @@ import (
 	"os"
 	"os/signal"
 	"runtime"
+	"sync"
 	"syscall"
 	"time"
 
@@ -46,8 +47,21 @@ var (
 	maxHeaderBytes      int
 	shutdownTimeout     time.Duration
 	enableCORS          bool
+
+	shutdownHooksMutex sync.Mutex
+	shutdownHooks      []func(context.Context) error
 )
 
+// OnShutdown registers a function called when the server launched by Run
+// receives an exit signal, for instance to stop a server running next to it.
+// It is given the same shutdown timeout as the storehttp server.
+func OnShutdown(hook func(context.Context) error) {
+	shutdownHooksMutex.Lock()
+	defer shutdownHooksMutex.Unlock()
+
+	shutdownHooks = append(shutdownHooks, hook)
+}
+
 // Run launches a storehttp server.
 func Run(
 	a store.Adapter,
@@ -75,6 +89,13 @@ func Run(
 		monitoring.LogEntry().Info("Cleaning up")
 		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 		defer cancel()
+		shutdownHooksMutex.Lock()
+		for _, hook := range shutdownHooks {
+			if err := hook(ctx); err != nil {
+				monitoring.LogEntry().WithField("error", err).Warn("Failed to run shutdown hook")
+			}
+		}
+		shutdownHooksMutex.Unlock()
 		if err := h.Shutdown(ctx); err != nil {
 			monitoring.LogEntry().WithField("error", err).Fatal("Failed to shutdown server")
 		}
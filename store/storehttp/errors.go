@@ -39,6 +39,14 @@ func newErrLimit(msg string) jsonhttp.ErrHTTP {
 	return jsonhttp.NewErrHTTP(types.NewError(errorcode.InvalidArgument, store.Component, msg))
 }
 
+func newErrCursor(msg string) jsonhttp.ErrHTTP {
+	if msg == "" {
+		msg = "cursor must be the nextCursor returned by a previous request"
+	}
+
+	return jsonhttp.NewErrHTTP(types.WrapError(store.ErrInvalidCursor, errorcode.InvalidArgument, store.Component, msg))
+}
+
 func newErrWithoutParent(msg string) jsonhttp.ErrHTTP {
 	if msg == "" {
 		msg = "withoutParent should be a boolean"
@@ -47,6 +55,14 @@ func newErrWithoutParent(msg string) jsonhttp.ErrHTTP {
 	return jsonhttp.NewErrHTTP(types.NewError(errorcode.InvalidArgument, store.Component, msg))
 }
 
+func newErrReverse(msg string) jsonhttp.ErrHTTP {
+	if msg == "" {
+		msg = "reverse should be a boolean"
+	}
+
+	return jsonhttp.NewErrHTTP(types.NewError(errorcode.InvalidArgument, store.Component, msg))
+}
+
 func newErrPrevLinkHash(msg string) jsonhttp.ErrHTTP {
 	if msg == "" {
 		msg = "prevLinkHash must be a 64 byte long hexadecimal string"
@@ -70,3 +86,83 @@ func newErrReferencing(msg string) jsonhttp.ErrHTTP {
 
 	return jsonhttp.NewErrHTTP(types.NewError(errorcode.InvalidArgument, store.Component, msg))
 }
+
+func newErrCreatedAfter(msg string) jsonhttp.ErrHTTP {
+	if msg == "" {
+		msg = "createdAfter must be an RFC 3339 timestamp"
+	}
+
+	return jsonhttp.NewErrHTTP(types.NewError(errorcode.InvalidArgument, store.Component, msg))
+}
+
+func newErrCreatedBefore(msg string) jsonhttp.ErrHTTP {
+	if msg == "" {
+		msg = "createdBefore must be an RFC 3339 timestamp"
+	}
+
+	return jsonhttp.NewErrHTTP(types.NewError(errorcode.InvalidArgument, store.Component, msg))
+}
+
+func newErrData(msg string) jsonhttp.ErrHTTP {
+	if msg == "" {
+		msg = "data must be a JSON array of link data predicates"
+	}
+
+	return jsonhttp.NewErrHTTP(types.NewError(errorcode.InvalidArgument, store.Component, msg))
+}
+
+func newErrProcess(msg string) jsonhttp.ErrHTTP {
+	if msg == "" {
+		msg = "process is required"
+	}
+
+	return jsonhttp.NewErrHTTP(types.NewError(errorcode.InvalidArgument, store.Component, msg))
+}
+
+func newErrFrom(msg string) jsonhttp.ErrHTTP {
+	if msg == "" {
+		msg = "from must be a link hash"
+	}
+
+	return jsonhttp.NewErrHTTP(types.NewError(errorcode.InvalidArgument, store.Component, msg))
+}
+
+func newErrHeads(msg string) jsonhttp.ErrHTTP {
+	if msg == "" {
+		msg = "heads should be a boolean and can't be combined with from"
+	}
+
+	return jsonhttp.NewErrHTTP(types.NewError(errorcode.InvalidArgument, store.Component, msg))
+}
+
+func newErrDirection(msg string) jsonhttp.ErrHTTP {
+	if msg == "" {
+		msg = "direction must be ancestors or descendants"
+	}
+
+	return jsonhttp.NewErrHTTP(types.NewError(errorcode.InvalidArgument, store.Component, msg))
+}
+
+func newErrDepth(msg string) jsonhttp.ErrHTTP {
+	if msg == "" {
+		msg = "depth must be a positive integer"
+	}
+
+	return jsonhttp.NewErrHTTP(types.NewError(errorcode.InvalidArgument, store.Component, msg))
+}
+
+func newErrSequence(msg string) jsonhttp.ErrHTTP {
+	if msg == "" {
+		msg = "from must be a positive integer"
+	}
+
+	return jsonhttp.NewErrHTTP(types.NewError(errorcode.InvalidArgument, store.Component, msg))
+}
+
+func newErrEventLog(msg string) jsonhttp.ErrHTTP {
+	if msg == "" {
+		msg = "the store doesn't keep an event log"
+	}
+
+	return jsonhttp.NewErrHTTP(types.NewError(errorcode.Unimplemented, store.Component, msg))
+}
@@ -34,16 +34,54 @@
 //	GET /segments/:linkHash
 //		Renders a segment.
 //
-//	GET /segments?[offset=offset]&[limit=limit]&[mapIds[]=id1]&[mapIds[]=id2]&[prevLinkHash=prevLinkHash]&[tags[]=tag1]&[tags[]=tag2]
+//	GET /segments/:linkHash/verify
+//		Verifies the evidences of a segment and renders a report telling
+//		whether each of them proves the existence of the link
+//		(see github.com/stratumn/go-core/store/storeverify).
+//
+//	GET /segments?[offset=offset]&[limit=limit]&[cursor=cursor]&[mapIds[]=id1]&[mapIds[]=id2]&[prevLinkHash=prevLinkHash]&[tags[]=tag1]&[tags[]=tag2]&[tagsAny[]=tag3]&[tagsAny[]=tag4]&[process=p]&[processes[]=p1]&[processes[]=p2]&[step=s]&[steps[]=s1]&[steps[]=s2]&[createdAfter=time]&[createdBefore=time]&[data=predicates]&[reverse=true]
 //		Finds and renders segments.
+//		The cursor is the nextCursor returned with the previous page.
+//		Segments must have all the tags, one of the tagsAny, one of the
+//		processes and one of the steps (when given).
+//		Times are RFC 3339 timestamps bounding when segments were added to
+//		the store (the lower bound is inclusive, the upper one exclusive).
+//		Data is a JSON array of predicates on the link data
+//		(see github.com/stratumn/go-core/store.DataPredicate).
 //
-//	GET /maps?[offset=offset]&[limit=limit]
+//	GET /maps?[offset=offset]&[limit=limit]&[cursor=cursor]&[process=process]&[prefix=prefix]&[suffix=suffix]
 //		Finds and renders map IDs.
+//		The cursor is the last map ID of the previous page.
+//
+//	GET /maps/:id/graph?process=process&[from=linkHash]&[heads=true]&[direction=ancestors|descendants]&[depth=depth]
+//		Renders the graph of a map.
+//		Without from, renders all the segments of the map and the link hashes
+//		of its heads, or only the heads if heads is true. Otherwise renders
+//		the ancestors or descendants of the given link, up to the given depth
+//		(no limit by default).
+//
+//	GET /events?[from=sequence]&[limit=limit]
+//		Renders the events saved in the store's event log, starting at the
+//		given sequence number (the oldest event by default).
+//		Only available if the store keeps an event log.
 //
 //	GET /websocket
 //		A web socket that broadcasts messages from the store:
-//			{ "type": "SavedLink", "data": [link] }
-//			{ "type": "SavedEvidence", "data": [evidence] }
+//			{ "type": "SavedLinks", "data": [link], "sequence": sequence }
+//			{ "type": "SavedEvidences", "data": { linkHash: evidence }, "sequence": sequence }
+//		The sequence is only set if the store keeps an event log.
+//		Clients can subscribe to the events matching a segment filter (map
+//		IDs, process, tags...), in which case they only receive those:
+//			{ "type": "subscribe", "data": { "id": id, "filter": filter } }
+//			{ "type": "unsubscribe", "data": { "id": id } }
+//		Subscriptions are acknowledged with "subscribed" and "unsubscribed"
+//		messages. Invalid messages are answered with an "error" message.
+//		Clients without subscriptions receive every event.
+//		If the store keeps an event log, clients can receive the events
+//		they missed, starting at the given sequence number, before the new
+//		ones:
+//			{ "type": "resume", "data": { "from": sequence } }
+//		Resuming is acknowledged with a "resumed" message.
 package storehttp
 
 import (
@@ -59,6 +97,7 @@ import (
 	"github.com/stratumn/go-core/monitoring"
 	"github.com/stratumn/go-core/monitoring/errorcode"
 	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/store/storeverify"
 	"github.com/stratumn/go-core/types"
 )
 
@@ -76,6 +115,14 @@ type Server struct {
 	adapter         store.Adapter
 	ws              *jsonws.Basic
 	storeEventsChan chan *store.Event
+	wsMsgChan       chan jsonws.BasicConnMsg
+	wsCloseChan     chan *jsonws.BufferedConn
+	wsResumedChan   chan *wsResumed
+
+	// Web socket subscribers and the sequence number of the last event
+	// broadcast are only accessed by the main loop.
+	subscribers map[*jsonws.BufferedConn]*wsSubscriber
+	sequence    uint64
 }
 
 // Config contains configuration options for the server.
@@ -84,6 +131,13 @@ type Config struct {
 	StoreEventsChanSize int
 }
 
+// MapGraph is the graph returned by the map graph route.
+// Heads are only set when the whole map or only its heads are returned.
+type MapGraph struct {
+	Segments types.SegmentSlice `json:"segments"`
+	Heads    []string           `json:"heads,omitempty"`
+}
+
 // Info is the info returned by the root route.
 type Info struct {
 	Adapter interface{} `json:"adapter"`
@@ -102,15 +156,25 @@ func New(
 		adapter:         a,
 		ws:              jsonws.NewBasic(basicConfig, bufConnConfig),
 		storeEventsChan: make(chan *store.Event, config.StoreEventsChanSize),
+		wsMsgChan:       make(chan jsonws.BasicConnMsg),
+		wsCloseChan:     make(chan *jsonws.BufferedConn),
+		wsResumedChan:   make(chan *wsResumed),
+		subscribers:     map[*jsonws.BufferedConn]*wsSubscriber{},
 	}
 
+	s.ws.AddMsgChannel(s.wsMsgChan)
+	s.ws.AddCloseChannel(s.wsCloseChan)
+
 	s.Get("/", s.root)
 	s.Post("/links", s.createLink)
 	s.Post("/batch/links", s.batchCreateLink)
 	s.Post("/evidences/:linkHash", s.addEvidence)
 	s.Get("/segments/:linkHash", s.getSegment)
+	s.Get("/segments/:linkHash/verify", s.verifySegment)
 	s.Get("/segments", s.findSegments)
 	s.Get("/maps", s.getMapIDs)
+	s.Get("/maps/:id/graph", s.getMapGraph)
+	s.Get("/events", s.getEvents)
 	s.GetRaw("/websocket", s.getWebSocket)
 
 	return &s
@@ -166,11 +230,22 @@ func (s *Server) Start() {
 
 // Web socket loop.
 func (s *Server) loop() {
-	for event := range s.storeEventsChan {
-		s.ws.Broadcast(&jsonws.Message{
-			Type: string(event.EventType),
-			Data: event.Data,
-		}, nil)
+	for {
+		select {
+		case event, ok := <-s.storeEventsChan:
+			if !ok {
+				s.stopResumes()
+				return
+			}
+			s.broadcast(event)
+		case msg := <-s.wsMsgChan:
+			s.handleWebSocketMsg(msg)
+		case resumed := <-s.wsResumedChan:
+			s.resumed(resumed)
+		case conn := <-s.wsCloseChan:
+			s.stopResume(s.subscribers[conn])
+			delete(s.subscribers, conn)
+		}
 	}
 }
 
@@ -307,6 +382,30 @@ func (s *Server) getSegment(w http.ResponseWriter, r *http.Request, p httprouter
 	return seg, nil
 }
 
+func (s *Server) verifySegment(w http.ResponseWriter, r *http.Request, p httprouter.Params) (interface{}, error) {
+	span, ctx := monitoring.StartSpanIncomingRequest(r.Context(), "storehttp/verifySegment")
+	defer span.End()
+
+	linkHash, err := chainscript.NewLinkHashFromString(p.ByName("linkHash"))
+	if err != nil {
+		span.Context.SetTag(monitoring.ErrorCodeLabel, errorcode.Text(errorcode.InvalidArgument))
+		span.Context.SetTag(monitoring.ErrorLabel, err.Error())
+		return nil, jsonhttp.NewErrHTTP(types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not parse link hash"))
+	}
+
+	seg, err := s.adapter.GetSegment(ctx, linkHash)
+	if err != nil {
+		monitoring.SetSpanStatus(span, err)
+		return nil, jsonhttp.NewErrHTTP(err)
+	}
+	if seg == nil {
+		span.Context.SetTag(monitoring.ErrorCodeLabel, errorcode.Text(errorcode.NotFound))
+		return nil, jsonhttp.NewErrNotFound()
+	}
+
+	return storeverify.VerifySegment(ctx, seg), nil
+}
+
 func (s *Server) findSegments(w http.ResponseWriter, r *http.Request, _ httprouter.Params) (interface{}, error) {
 	span, ctx := monitoring.StartSpanIncomingRequest(r.Context(), "storehttp/findSegments")
 	defer span.End()
@@ -345,6 +444,107 @@ func (s *Server) getMapIDs(w http.ResponseWriter, r *http.Request, _ httprouter.
 	return slice, nil
 }
 
+func (s *Server) getEvents(w http.ResponseWriter, r *http.Request, _ httprouter.Params) (interface{}, error) {
+	span, ctx := monitoring.StartSpanIncomingRequest(r.Context(), "storehttp/getEvents")
+	defer span.End()
+
+	reader, ok := s.adapter.(store.EventReader)
+	if !ok {
+		err := newErrEventLog("")
+		monitoring.SetSpanStatus(span, err)
+		return nil, err
+	}
+
+	from, limit, e := parseEventsQuery(r)
+	if e != nil {
+		monitoring.SetSpanStatus(span, e)
+		return nil, e
+	}
+
+	events, err := reader.ReadEvents(ctx, from, limit)
+	if err != nil {
+		monitoring.SetSpanStatus(span, err)
+		return nil, jsonhttp.NewErrHTTP(err)
+	}
+
+	if events == nil {
+		events = []*store.Event{}
+	}
+
+	return events, nil
+}
+
+func (s *Server) getMapGraph(w http.ResponseWriter, r *http.Request, p httprouter.Params) (interface{}, error) {
+	span, ctx := monitoring.StartSpanIncomingRequest(r.Context(), "storehttp/getMapGraph")
+	defer span.End()
+
+	query, e := parseGraphQuery(r)
+	if e != nil {
+		monitoring.SetSpanStatus(span, e)
+		return nil, e
+	}
+
+	mapID := p.ByName("id")
+
+	if query.Heads {
+		heads, err := store.GetMapHeads(ctx, s.adapter, query.Process, mapID)
+		if err != nil {
+			monitoring.SetSpanStatus(span, err)
+			return nil, jsonhttp.NewErrHTTP(err)
+		}
+
+		res := &MapGraph{Segments: types.SegmentSlice{}, Heads: []string{}}
+		for _, head := range heads {
+			res.Segments = append(res.Segments, head)
+			res.Heads = append(res.Heads, head.LinkHash().String())
+		}
+
+		return res, nil
+	}
+
+	if query.From == nil {
+		graph, err := store.LoadMapGraph(ctx, s.adapter, query.Process, mapID)
+		if err != nil {
+			monitoring.SetSpanStatus(span, err)
+			return nil, jsonhttp.NewErrHTTP(err)
+		}
+
+		res := &MapGraph{Segments: graph.Segments(), Heads: []string{}}
+		for _, head := range graph.Heads() {
+			res.Heads = append(res.Heads, head.LinkHash().String())
+		}
+
+		return res, nil
+	}
+
+	from, err := s.adapter.GetSegment(ctx, query.From)
+	if err != nil {
+		monitoring.SetSpanStatus(span, err)
+		return nil, jsonhttp.NewErrHTTP(err)
+	}
+	if from == nil || from.Link.Meta.MapId != mapID || from.Link.Meta.Process.Name != query.Process {
+		span.Context.SetTag(monitoring.ErrorCodeLabel, errorcode.Text(errorcode.NotFound))
+		return nil, jsonhttp.NewErrNotFound()
+	}
+
+	var segments types.SegmentSlice
+	if query.Direction == graphAncestors {
+		segments, err = store.GetAncestors(ctx, s.adapter, query.From, query.Depth)
+	} else {
+		segments, err = store.GetDescendants(ctx, s.adapter, query.From, query.Depth)
+	}
+	if err != nil {
+		monitoring.SetSpanStatus(span, err)
+		return nil, jsonhttp.NewErrHTTP(err)
+	}
+
+	if segments == nil {
+		segments = types.SegmentSlice{}
+	}
+
+	return &MapGraph{Segments: segments}, nil
+}
+
 func (s *Server) getWebSocket(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	s.ws.Handle(w, r)
 }
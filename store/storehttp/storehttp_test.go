@@ -20,16 +20,19 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 
 	"github.com/stratumn/go-chainscript"
 	"github.com/stratumn/go-chainscript/chainscripttest"
+	blockchainevidences "github.com/stratumn/go-core/blockchainfossilizer/evidences"
 	"github.com/stratumn/go-core/jsonhttp"
 	"github.com/stratumn/go-core/jsonws"
 	"github.com/stratumn/go-core/jsonws/jsonwstesting"
 	"github.com/stratumn/go-core/store"
 	"github.com/stratumn/go-core/store/storetesting"
+	"github.com/stratumn/go-core/store/storeverify"
 	"github.com/stratumn/go-core/testutil"
 	"github.com/stratumn/go-core/types"
 	"github.com/stretchr/testify/assert"
@@ -273,6 +276,64 @@ func TestGetSegment_err(t *testing.T) {
 	assert.Equal(t, 1, a.MockGetSegment.CalledCount)
 }
 
+func TestVerifySegment(t *testing.T) {
+	s, a := createServer()
+	s1, err := chainscripttest.RandomLink(t).Segmentify()
+	require.NoError(t, err)
+	valid, err := blockchainevidences.New(s1.LinkHash(), []byte{42}).Evidence("btc")
+	require.NoError(t, err)
+	invalid, err := blockchainevidences.New(chainscripttest.RandomHash(), []byte{42}).Evidence("ltc")
+	require.NoError(t, err)
+	require.NoError(t, s1.AddEvidence(valid))
+	require.NoError(t, s1.AddEvidence(invalid))
+	a.MockGetSegment.Fn = func(chainscript.LinkHash) (*chainscript.Segment, error) { return s1, nil }
+
+	var report storeverify.Report
+	w, err := testutil.RequestJSON(s.ServeHTTP, "GET", "/segments/"+s1.LinkHash().String()+"/verify", nil, &report)
+	require.NoError(t, err, "testutil.RequestJSON()")
+
+	assert.Equal(t, s1.LinkHash(), a.MockGetSegment.LastCalledWith)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, storeverify.Report{
+		LinkHash: s1.LinkHash().String(),
+		Valid:    false,
+		Evidences: []*storeverify.EvidenceReport{{
+			Backend:  blockchainevidences.BlockchainFossilizerName,
+			Provider: "btc",
+			Valid:    true,
+		}, {
+			Backend:  blockchainevidences.BlockchainFossilizerName,
+			Provider: "ltc",
+			Valid:    false,
+			Error:    storeverify.ErrInvalidProof.Error(),
+		}},
+	}, report)
+}
+
+func TestVerifySegment_notFound(t *testing.T) {
+	s, a := createServer()
+	unknownHash := chainscripttest.RandomHash()
+
+	var body map[string]interface{}
+	w, err := testutil.RequestJSON(s.ServeHTTP, "GET", "/segments/"+unknownHash.String()+"/verify", nil, &body)
+	require.NoError(t, err, "testutil.RequestJSON()")
+
+	assert.Equal(t, unknownHash, a.MockGetSegment.LastCalledWith)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "Not Found", body["error"])
+}
+
+func TestVerifySegment_invalidLinkHash(t *testing.T) {
+	s, a := createServer()
+
+	var body map[string]interface{}
+	w, err := testutil.RequestJSON(s.ServeHTTP, "GET", "/segments/notahash/verify", nil, &body)
+	require.NoError(t, err, "testutil.RequestJSON()")
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, 0, a.MockGetSegment.CalledCount)
+}
+
 func TestFindSegments(t *testing.T) {
 	s, a := createServer()
 	s1 := &types.PaginatedSegments{}
@@ -486,13 +547,161 @@ func TestFindSegments_invalidLinkHashes(t *testing.T) {
 	assert.Equal(t, 0, a.MockFindSegments.CalledCount)
 }
 
+func TestFindSegments_cursor(t *testing.T) {
+	s, a := createServer()
+	s1 := &types.PaginatedSegments{NextCursor: "next"}
+	a.MockFindSegments.Fn = func(*store.SegmentFilter) (*types.PaginatedSegments, error) { return s1, nil }
+
+	cursor := store.NewSegmentCursor(chainscripttest.RandomSegment(t)).String()
+	s2 := &types.PaginatedSegments{}
+	w, err := testutil.RequestJSON(s.ServeHTTP, "GET", "/segments?limit=2&cursor="+cursor, nil, &s2)
+	require.NoError(t, err, "testutil.RequestJSON()")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "next", s2.NextCursor)
+	assert.Equal(t, 1, a.MockFindSegments.CalledCount)
+	assert.Equal(t, cursor, a.MockFindSegments.LastCalledWith.Cursor)
+}
+
+func TestFindSegments_invalidCursor(t *testing.T) {
+	s, a := createServer()
+
+	var body map[string]interface{}
+	w, err := testutil.RequestJSON(s.ServeHTTP, "GET", "/segments?cursor=3", nil, &body)
+	require.NoError(t, err, "testutil.RequestJSON()")
+
+	assert.Equal(t, newErrCursor("").Status(), w.Code)
+	assert.Equal(t, "cursor must be the nextCursor returned by a previous request", body["error"].(map[string]interface{})["message"])
+	assert.Zero(t, a.MockFindSegments.CalledCount)
+}
+
+func TestFindSegments_anyOf(t *testing.T) {
+	s, a := createServer()
+
+	var s2 types.PaginatedSegments
+	w, err := testutil.RequestJSON(s.ServeHTTP, "GET", "/segments?processes[]=p1&processes%5B%5D=p2&steps[]=sign&steps[]=approve&tagsAny[]=one&tagsAny%5B%5D=two", nil, &s2)
+	require.NoError(t, err, "testutil.RequestJSON()")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, a.MockFindSegments.CalledCount)
+
+	f := a.MockFindSegments.LastCalledWith
+	assert.Equal(t, []string{"p1", "p2"}, f.Processes)
+	assert.Equal(t, []string{"sign", "approve"}, f.Steps)
+	assert.Equal(t, []string{"one", "two"}, f.TagsAny)
+	assert.Empty(t, f.Tags)
+}
+
+func TestFindSegments_stepAndReverse(t *testing.T) {
+	s, a := createServer()
+
+	var s2 types.PaginatedSegments
+	w, err := testutil.RequestJSON(s.ServeHTTP, "GET", "/segments?step=sign&reverse=true", nil, &s2)
+	require.NoError(t, err, "testutil.RequestJSON()")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, a.MockFindSegments.CalledCount)
+
+	f := a.MockFindSegments.LastCalledWith
+	assert.Equal(t, "sign", f.Step)
+	assert.True(t, f.Reverse)
+}
+
+func TestFindSegments_invalidReverse(t *testing.T) {
+	s, a := createServer()
+
+	var body map[string]interface{}
+	w, err := testutil.RequestJSON(s.ServeHTTP, "GET", "/segments?reverse=backwards", nil, &body)
+	require.NoError(t, err, "testutil.RequestJSON()")
+
+	assert.Equal(t, newErrReverse("").Status(), w.Code)
+	assert.Equal(t, "reverse should be a boolean", body["error"].(map[string]interface{})["message"])
+	assert.Zero(t, a.MockFindSegments.CalledCount)
+}
+
+func TestFindSegments_createdAt(t *testing.T) {
+	s, a := createServer()
+
+	var s2 types.PaginatedSegments
+	w, err := testutil.RequestJSON(s.ServeHTTP, "GET", "/segments?createdAfter=2018-06-01T00:00:00Z&createdBefore=2018-06-08T12:30:00.5%2B02:00", nil, &s2)
+	require.NoError(t, err, "testutil.RequestJSON()")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, a.MockFindSegments.CalledCount)
+
+	f := a.MockFindSegments.LastCalledWith
+	require.NotNil(t, f.CreatedAfter)
+	require.NotNil(t, f.CreatedBefore)
+	assert.True(t, time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC).Equal(*f.CreatedAfter))
+	assert.True(t, time.Date(2018, 6, 8, 10, 30, 0, 5e8, time.UTC).Equal(*f.CreatedBefore))
+}
+
+func TestFindSegments_invalidCreatedAfter(t *testing.T) {
+	s, a := createServer()
+
+	var body map[string]interface{}
+	w, err := testutil.RequestJSON(s.ServeHTTP, "GET", "/segments?createdAfter=yesterday", nil, &body)
+	require.NoError(t, err, "testutil.RequestJSON()")
+
+	assert.Equal(t, newErrCreatedAfter("").Status(), w.Code)
+	assert.Equal(t, "createdAfter must be an RFC 3339 timestamp", body["error"].(map[string]interface{})["message"])
+	assert.Zero(t, a.MockFindSegments.CalledCount)
+}
+
+func TestFindSegments_invalidCreatedBefore(t *testing.T) {
+	s, a := createServer()
+
+	var body map[string]interface{}
+	w, err := testutil.RequestJSON(s.ServeHTTP, "GET", "/segments?createdBefore=2018-06-01", nil, &body)
+	require.NoError(t, err, "testutil.RequestJSON()")
+
+	assert.Equal(t, newErrCreatedBefore("").Status(), w.Code)
+	assert.Equal(t, "createdBefore must be an RFC 3339 timestamp", body["error"].(map[string]interface{})["message"])
+	assert.Zero(t, a.MockFindSegments.CalledCount)
+}
+
+func TestFindSegments_data(t *testing.T) {
+	s, a := createServer()
+
+	data := url.QueryEscape(`[{"path":"order.status","op":"eq","value":"paid"},{"path":"order.amount","op":"range","gte":100}]`)
+	var s2 types.PaginatedSegments
+	w, err := testutil.RequestJSON(s.ServeHTTP, "GET", "/segments?data="+data, nil, &s2)
+	require.NoError(t, err, "testutil.RequestJSON()")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, a.MockFindSegments.CalledCount)
+
+	gte := 100.0
+	assert.Equal(t, []store.DataPredicate{
+		{Path: "order.status", Op: store.DataEqual, Value: "paid"},
+		{Path: "order.amount", Op: store.DataRange, Gte: &gte},
+	}, a.MockFindSegments.LastCalledWith.Data)
+}
+
+func TestFindSegments_invalidData(t *testing.T) {
+	for _, data := range []string{
+		`{"path":"a","op":"exists"}`,
+		`[{"path":"a","op":"like","value":"x"}]`,
+		`[{"path":"a","op":"range"}]`,
+	} {
+		s, a := createServer()
+
+		var body map[string]interface{}
+		w, err := testutil.RequestJSON(s.ServeHTTP, "GET", "/segments?data="+url.QueryEscape(data), nil, &body)
+		require.NoError(t, err, "testutil.RequestJSON()")
+
+		assert.Equal(t, newErrData("").Status(), w.Code, data)
+		assert.Zero(t, a.MockFindSegments.CalledCount, data)
+	}
+}
+
 func TestGetMapIDs(t *testing.T) {
 	s, a := createServer()
 	s1 := []string{"one", "two", "three"}
 	a.MockGetMapIDs.Fn = func(*store.MapFilter) ([]string, error) { return s1, nil }
 
 	var s2 []string
-	w, err := testutil.RequestJSON(s.ServeHTTP, "GET", "/maps?offset=20&limit=10", nil, &s2)
+	w, err := testutil.RequestJSON(s.ServeHTTP, "GET", "/maps?offset=20&limit=10&cursor=map42", nil, &s2)
 	require.NoError(t, err, "testutil.RequestJSON()")
 
 	assert.Equal(t, http.StatusOK, w.Code)
@@ -502,6 +711,23 @@ func TestGetMapIDs(t *testing.T) {
 	p := a.MockGetMapIDs.LastCalledWith
 	assert.Equal(t, 20, p.Offset)
 	assert.Equal(t, 10, p.Limit)
+	assert.Equal(t, "map42", p.Cursor)
+}
+
+func TestGetMapIDs_filter(t *testing.T) {
+	s, a := createServer()
+
+	var s2 []string
+	w, err := testutil.RequestJSON(s.ServeHTTP, "GET", "/maps?process=p1&prefix=map-&suffix=-42", nil, &s2)
+	require.NoError(t, err, "testutil.RequestJSON()")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, a.MockGetMapIDs.CalledCount)
+
+	f := a.MockGetMapIDs.LastCalledWith
+	assert.Equal(t, "p1", f.Process)
+	assert.Equal(t, "map-", f.Prefix)
+	assert.Equal(t, "-42", f.Suffix)
 }
 
 func TestGetMapIDs_err(t *testing.T) {
@@ -630,3 +856,195 @@ func TestGetSocket(t *testing.T) {
 		require.Fail(t, "saved segment not broadcasted")
 	}
 }
+
+func mockMapGraph(t *testing.T, a *storetesting.MockAdapter) (root, child *chainscript.Segment) {
+	rootLink := chainscripttest.NewLinkBuilder(t).WithRandomData().WithoutParent().Build()
+	childLink := chainscripttest.NewLinkBuilder(t).WithRandomData().Branch(t, rootLink).Build()
+	root, _ = rootLink.Segmentify()
+	child, _ = childLink.Segmentify()
+
+	segments := types.SegmentSlice{root, child}
+	segments.Sort(false)
+
+	a.MockGetSegment.Fn = func(linkHash chainscript.LinkHash) (*chainscript.Segment, error) {
+		for _, s := range segments {
+			if s.LinkHash().String() == linkHash.String() {
+				return s, nil
+			}
+		}
+		return nil, nil
+	}
+	a.MockFindSegments.Fn = func(filter *store.SegmentFilter) (*types.PaginatedSegments, error) {
+		return filter.PaginateSegments(&types.PaginatedSegments{Segments: segments, TotalCount: 2})
+	}
+
+	return root, child
+}
+
+// eventLogAdapter is a mock adapter that keeps an event log.
+type eventLogAdapter struct {
+	*storetesting.MockAdapter
+	events []*store.Event
+	from   uint64
+	limit  int
+}
+
+func (a *eventLogAdapter) ReadEvents(ctx context.Context, from uint64, limit int) ([]*store.Event, error) {
+	a.from, a.limit = from, limit
+	return a.events, nil
+}
+
+func TestGetEvents(t *testing.T) {
+	link := chainscripttest.RandomLink(t)
+	e := store.NewSavedLinks(link)
+	e.Sequence = 3
+
+	a := &eventLogAdapter{MockAdapter: &storetesting.MockAdapter{}, events: []*store.Event{e}}
+	s := newTestServer(a)
+
+	var events []*store.Event
+	w, err := testutil.RequestJSON(s.ServeHTTP, "GET", "/events?from=3&limit=10", nil, &events)
+	require.NoError(t, err, "testutil.RequestJSON()")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, uint64(3), a.from)
+	assert.Equal(t, 10, a.limit)
+	require.Len(t, events, 1)
+	assert.Equal(t, uint64(3), events[0].Sequence)
+	chainscripttest.LinksEqual(t, link, events[0].Data.([]*chainscript.Link)[0])
+}
+
+func TestGetEvents_empty(t *testing.T) {
+	a := &eventLogAdapter{MockAdapter: &storetesting.MockAdapter{}}
+	s := newTestServer(a)
+
+	var events []*store.Event
+	w, err := testutil.RequestJSON(s.ServeHTTP, "GET", "/events", nil, &events)
+	require.NoError(t, err, "testutil.RequestJSON()")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, uint64(0), a.from)
+	assert.Equal(t, store.DefaultLimit, a.limit)
+	assert.NotNil(t, events)
+	assert.Empty(t, events)
+}
+
+func TestGetEvents_noEventLog(t *testing.T) {
+	s, _ := createServer()
+
+	var body map[string]interface{}
+	w, err := testutil.RequestJSON(s.ServeHTTP, "GET", "/events", nil, &body)
+	require.NoError(t, err, "testutil.RequestJSON()")
+
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+}
+
+func TestGetEvents_invalidFrom(t *testing.T) {
+	a := &eventLogAdapter{MockAdapter: &storetesting.MockAdapter{}}
+	s := newTestServer(a)
+
+	var body map[string]interface{}
+	w, err := testutil.RequestJSON(s.ServeHTTP, "GET", "/events?from=-1", nil, &body)
+	require.NoError(t, err, "testutil.RequestJSON()")
+
+	assert.Equal(t, newErrSequence("").Status(), w.Code)
+	assert.Equal(t, "from must be a positive integer", body["error"].(map[string]interface{})["message"])
+}
+
+func TestGetMapGraph(t *testing.T) {
+	s, a := createServer()
+	root, child := mockMapGraph(t, a)
+	meta := root.Link.Meta
+
+	var graph MapGraph
+	w, err := testutil.RequestJSON(s.ServeHTTP, "GET", fmt.Sprintf("/maps/%s/graph?process=%s", meta.MapId, meta.Process.Name), nil, &graph)
+	require.NoError(t, err, "testutil.RequestJSON()")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, graph.Segments, 2)
+	assert.Equal(t, []string{child.LinkHash().String()}, graph.Heads)
+
+	f := a.MockFindSegments.LastCalledWith
+	assert.Equal(t, meta.Process.Name, f.Process)
+	assert.Equal(t, []string{meta.MapId}, f.MapIDs)
+}
+
+func TestGetMapGraph_heads(t *testing.T) {
+	s, a := createServer()
+	root, child := mockMapGraph(t, a)
+	meta := root.Link.Meta
+
+	var graph MapGraph
+	w, err := testutil.RequestJSON(s.ServeHTTP, "GET", fmt.Sprintf("/maps/%s/graph?process=%s&heads=true", meta.MapId, meta.Process.Name), nil, &graph)
+	require.NoError(t, err, "testutil.RequestJSON()")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, graph.Segments, 1)
+	assert.Equal(t, child.LinkHash(), graph.Segments[0].LinkHash())
+	assert.Equal(t, []string{child.LinkHash().String()}, graph.Heads)
+}
+
+func TestGetMapGraph_from(t *testing.T) {
+	s, a := createServer()
+	root, child := mockMapGraph(t, a)
+	meta := root.Link.Meta
+
+	var ancestors MapGraph
+	w, err := testutil.RequestJSON(s.ServeHTTP, "GET", fmt.Sprintf("/maps/%s/graph?process=%s&from=%s&direction=ancestors&depth=3", meta.MapId, meta.Process.Name, child.LinkHash().String()), nil, &ancestors)
+	require.NoError(t, err, "testutil.RequestJSON()")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, ancestors.Segments, 1)
+	assert.Equal(t, root.LinkHash(), ancestors.Segments[0].LinkHash())
+	assert.Nil(t, ancestors.Heads)
+
+	var descendants MapGraph
+	w, err = testutil.RequestJSON(s.ServeHTTP, "GET", fmt.Sprintf("/maps/%s/graph?process=%s&from=%s", meta.MapId, meta.Process.Name, child.LinkHash().String()), nil, &descendants)
+	require.NoError(t, err, "testutil.RequestJSON()")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotNil(t, descendants.Segments)
+	assert.Empty(t, descendants.Segments)
+}
+
+func TestGetMapGraph_notFound(t *testing.T) {
+	s, a := createServer()
+	root, _ := mockMapGraph(t, a)
+	meta := root.Link.Meta
+
+	for _, path := range []string{
+		fmt.Sprintf("/maps/%s/graph?process=%s&from=%s", meta.MapId, meta.Process.Name, chainscripttest.RandomHash().String()),
+		fmt.Sprintf("/maps/%s/graph?process=%s&from=%s", "other", meta.Process.Name, root.LinkHash().String()),
+	} {
+		var body map[string]interface{}
+		w, err := testutil.RequestJSON(s.ServeHTTP, "GET", path, nil, &body)
+		require.NoError(t, err, "testutil.RequestJSON()")
+		assert.Equal(t, http.StatusNotFound, w.Code, path)
+	}
+}
+
+func TestGetMapGraph_invalidQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		message string
+	}{
+		{"", "process is required"},
+		{"process=p&from=nope", "from must be a link hash"},
+		{"process=p&direction=up", "direction must be ancestors or descendants"},
+		{"process=p&depth=-1", "depth must be a positive integer"},
+		{"process=p&heads=maybe", "heads should be a boolean and can't be combined with from"},
+		{"process=p&heads=true&from=" + chainscripttest.RandomHash().String(), "heads should be a boolean and can't be combined with from"},
+	}
+
+	for _, tt := range tests {
+		s, a := createServer()
+
+		var body map[string]interface{}
+		w, err := testutil.RequestJSON(s.ServeHTTP, "GET", "/maps/m/graph?"+tt.query, nil, &body)
+		require.NoError(t, err, "testutil.RequestJSON()")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, tt.query)
+		assert.Equal(t, tt.message, body["error"].(map[string]interface{})["message"], tt.query)
+		assert.Zero(t, a.MockFindSegments.CalledCount, tt.query)
+	}
+}
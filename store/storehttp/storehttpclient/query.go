@@ -0,0 +1,99 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storehttpclient
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+)
+
+func setQuery(query url.Values, key, value string) {
+	if value != "" {
+		query.Set(key, value)
+	}
+}
+
+func addQuery(query url.Values, key string, values []string) {
+	for _, v := range values {
+		query.Add(key, v)
+	}
+}
+
+func paginationQuery(p *store.Pagination) url.Values {
+	query := url.Values{}
+	query.Set("offset", strconv.Itoa(p.Offset))
+	query.Set("limit", strconv.Itoa(p.Limit))
+	setQuery(query, "cursor", p.Cursor)
+
+	return query
+}
+
+func linkHashesQuery(query url.Values, key string, linkHashes ...chainscript.LinkHash) {
+	for _, lh := range linkHashes {
+		if len(lh) > 0 {
+			query.Add(key, lh.String())
+		}
+	}
+}
+
+// segmentQuery encodes a segment filter to the query expected by the server.
+func segmentQuery(filter *store.SegmentFilter) (url.Values, error) {
+	query := paginationQuery(&filter.Pagination)
+
+	addQuery(query, "mapIds[]", filter.MapIDs)
+	setQuery(query, "process", filter.Process)
+	addQuery(query, "processes[]", filter.Processes)
+	setQuery(query, "step", filter.Step)
+	addQuery(query, "steps[]", filter.Steps)
+	addQuery(query, "tags[]", filter.Tags)
+	addQuery(query, "tagsAny[]", filter.TagsAny)
+	linkHashesQuery(query, "prevLinkHash", filter.PrevLinkHash)
+	linkHashesQuery(query, "linkHashes[]", filter.LinkHashes...)
+	linkHashesQuery(query, "referencing", filter.Referencing)
+
+	if filter.WithoutParent {
+		query.Set("withoutParent", "true")
+	}
+
+	if filter.Reverse {
+		query.Set("reverse", "true")
+	}
+
+	if filter.CreatedAfter != nil {
+		query.Set("createdAfter", filter.CreatedAfter.Format(time.RFC3339Nano))
+	}
+
+	if filter.CreatedBefore != nil {
+		query.Set("createdBefore", filter.CreatedBefore.Format(time.RFC3339Nano))
+	}
+
+	if len(filter.Data) > 0 {
+		data, err := json.Marshal(filter.Data)
+		if err != nil {
+			return nil, types.WrapError(err, errorcode.InvalidArgument, store.Component, "json.Marshal")
+		}
+
+		query.Set("data", string(data))
+	}
+
+	return query, nil
+}
@@ -0,0 +1,343 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package storehttpclient implements a store adapter that talks to a remote
+// store served by github.com/stratumn/go-core/store/storehttp.
+//
+// It can be used wherever a local store adapter is, for instance to run the
+// storemigration tool or a validation wrapper against a remote store.
+package storehttpclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-core/bufferedbatch"
+	"github.com/stratumn/go-core/jsonhttp"
+	"github.com/stratumn/go-core/jsonws"
+	"github.com/stratumn/go-core/monitoring"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/store/storehttp"
+	"github.com/stratumn/go-core/types"
+)
+
+// Config contains configuration options for the client.
+type Config struct {
+	// URL of the store server, for instance http://localhost:5000.
+	URL string
+
+	// HTTPClient is an optional custom HTTP client.
+	HTTPClient *http.Client
+
+	// ReconnectInterval is the interval between two attempts to connect to
+	// the web socket of the server.
+	// Defaults to github.com/stratumn/go-core/jsonws.DefaultReconnectInterval.
+	ReconnectInterval time.Duration
+}
+
+// Client is the type that implements github.com/stratumn/go-core/store.Adapter.
+type Client struct {
+	client *jsonhttp.Client
+	config *Config
+
+	eventsMutex sync.Mutex
+	eventChans  []chan *store.Event
+	ws          *jsonws.Client
+}
+
+// New creates a client for the store served at the configured URL.
+func New(config *Config) (*Client, error) {
+	client, err := jsonhttp.NewClient(config.URL, config.HTTPClient, store.Component)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{client: client, config: config}, nil
+}
+
+// Close closes the web socket connection used to receive store events.
+func (c *Client) Close() error {
+	c.eventsMutex.Lock()
+	defer c.eventsMutex.Unlock()
+
+	if c.ws != nil {
+		c.ws.Stop()
+		c.ws = nil
+	}
+
+	return nil
+}
+
+/********** Store adapter implementation **********/
+
+// GetInfo implements github.com/stratumn/go-core/store.Adapter.GetInfo.
+// It returns the information of the remote store.
+func (c *Client) GetInfo(ctx context.Context) (interface{}, error) {
+	var info storehttp.Info
+	if err := c.client.Do(ctx, http.MethodGet, "/", nil, nil, &info); err != nil {
+		return nil, err
+	}
+
+	return info.Adapter, nil
+}
+
+// AddStoreEventChannel implements
+// github.com/stratumn/go-core/store.Adapter.AddStoreEventChannel.
+// Events are read from the web socket of the server, which is connected when
+// the first channel is added. The connection is re-established when it is
+// lost, but events sent in the meantime are missed.
+func (c *Client) AddStoreEventChannel(eventChan chan *store.Event) {
+	c.eventsMutex.Lock()
+	defer c.eventsMutex.Unlock()
+
+	c.eventChans = append(c.eventChans, eventChan)
+
+	if c.ws != nil {
+		return
+	}
+
+	c.ws = jsonws.NewClient(c.client.WebSocketURL("/websocket"), &jsonws.ClientConfig{
+		ReconnectInterval: c.config.ReconnectInterval,
+	})
+
+	// Connect right away so that events following this call aren't missed.
+	// If the server isn't reachable, the client keeps trying in the
+	// background.
+	if err := c.ws.Connect(); err != nil {
+		monitoring.LogEntry().WithField("error", err).Warn("Could not connect to store web socket")
+	}
+
+	go c.ws.Start(c.handleMessage)
+}
+
+func (c *Client) handleMessage(msg *jsonws.RawMessage) {
+	event := &store.Event{EventType: store.EventType(msg.Type)}
+
+	switch event.EventType {
+	case store.SavedLinks:
+		var links []*chainscript.Link
+		if err := json.Unmarshal(msg.Data, &links); err != nil {
+			monitoring.LogEntry().WithField("error", err).Warn("Could not decode store event")
+			return
+		}
+		event.Data = links
+	case store.SavedEvidences:
+		var evidences map[string]*chainscript.Evidence
+		if err := json.Unmarshal(msg.Data, &evidences); err != nil {
+			monitoring.LogEntry().WithField("error", err).Warn("Could not decode store event")
+			return
+		}
+		event.Data = evidences
+	default:
+		return
+	}
+
+	c.eventsMutex.Lock()
+	eventChans := c.eventChans
+	c.eventsMutex.Unlock()
+
+	for _, eventChan := range eventChans {
+		eventChan <- event
+	}
+}
+
+// NewBatch implements github.com/stratumn/go-core/store.Adapter.NewBatch.
+// Links are sent to the server when the batch is written.
+func (c *Client) NewBatch(ctx context.Context) (store.Batch, error) {
+	return &Batch{Batch: bufferedbatch.NewBatch(ctx, c), client: c}, nil
+}
+
+/********** Store writer implementation **********/
+
+// CreateLink implements github.com/stratumn/go-core/store.LinkWriter.CreateLink.
+func (c *Client) CreateLink(ctx context.Context, link *chainscript.Link) (chainscript.LinkHash, error) {
+	var segment chainscript.Segment
+	if err := c.client.Do(ctx, http.MethodPost, "/links", nil, link, &segment); err != nil {
+		return nil, err
+	}
+
+	return segment.LinkHash(), nil
+}
+
+// AddEvidence implements github.com/stratumn/go-core/store.EvidenceWriter.AddEvidence.
+func (c *Client) AddEvidence(ctx context.Context, linkHash chainscript.LinkHash, evidence *chainscript.Evidence) error {
+	return c.client.Do(ctx, http.MethodPost, "/evidences/"+linkHash.String(), nil, evidence, nil)
+}
+
+/********** Store reader implementation **********/
+
+// GetSegment implements github.com/stratumn/go-core/store.SegmentReader.GetSegment.
+func (c *Client) GetSegment(ctx context.Context, linkHash chainscript.LinkHash) (*chainscript.Segment, error) {
+	var segment chainscript.Segment
+	err := c.client.Do(ctx, http.MethodGet, "/segments/"+linkHash.String(), nil, nil, &segment)
+	if e, ok := err.(*types.Error); ok && e.Code == errorcode.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &segment, nil
+}
+
+// FindSegments implements github.com/stratumn/go-core/store.SegmentReader.FindSegments.
+func (c *Client) FindSegments(ctx context.Context, filter *store.SegmentFilter) (*types.PaginatedSegments, error) {
+	query, err := segmentQuery(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments types.PaginatedSegments
+	if err := c.client.Do(ctx, http.MethodGet, "/segments", query, nil, &segments); err != nil {
+		return nil, err
+	}
+
+	if segments.Segments == nil {
+		segments.Segments = types.SegmentSlice{}
+	}
+
+	return &segments, nil
+}
+
+// GetMapIDs implements github.com/stratumn/go-core/store.SegmentReader.GetMapIDs.
+func (c *Client) GetMapIDs(ctx context.Context, filter *store.MapFilter) ([]string, error) {
+	query := paginationQuery(&filter.Pagination)
+	setQuery(query, "process", filter.Process)
+	setQuery(query, "prefix", filter.Prefix)
+	setQuery(query, "suffix", filter.Suffix)
+
+	mapIDs := []string{}
+	if err := c.client.Do(ctx, http.MethodGet, "/maps", query, nil, &mapIDs); err != nil {
+		return nil, err
+	}
+
+	return mapIDs, nil
+}
+
+// GetEvidences implements github.com/stratumn/go-core/store.EvidenceReader.GetEvidences.
+// Evidences are read from the segment since the server doesn't expose them
+// separately.
+func (c *Client) GetEvidences(ctx context.Context, linkHash chainscript.LinkHash) (types.EvidenceSlice, error) {
+	segment, err := c.GetSegment(ctx, linkHash)
+	if err != nil || segment == nil {
+		return nil, err
+	}
+
+	return types.EvidenceSlice(segment.Meta.Evidences), nil
+}
+
+// GetAncestors implements github.com/stratumn/go-core/store.GraphReader.GetAncestors.
+func (c *Client) GetAncestors(ctx context.Context, linkHash chainscript.LinkHash, depth int) (types.SegmentSlice, error) {
+	return c.getGraph(ctx, linkHash, "ancestors", depth)
+}
+
+// GetDescendants implements github.com/stratumn/go-core/store.GraphReader.GetDescendants.
+func (c *Client) GetDescendants(ctx context.Context, linkHash chainscript.LinkHash, depth int) (types.SegmentSlice, error) {
+	return c.getGraph(ctx, linkHash, "descendants", depth)
+}
+
+// GetMapHeads implements github.com/stratumn/go-core/store.GraphReader.GetMapHeads.
+// Servers that don't support the heads parameter return the whole graph, so
+// segments are still filtered.
+func (c *Client) GetMapHeads(ctx context.Context, process, mapID string) (types.SegmentSlice, error) {
+	query := url.Values{}
+	query.Set("process", process)
+	query.Set("heads", "true")
+
+	var graph storehttp.MapGraph
+	if err := c.client.Do(ctx, http.MethodGet, "/maps/"+url.PathEscape(mapID)+"/graph", query, nil, &graph); err != nil {
+		return nil, err
+	}
+
+	heads := make(map[string]struct{}, len(graph.Heads))
+	for _, head := range graph.Heads {
+		heads[head] = struct{}{}
+	}
+
+	segments := types.SegmentSlice{}
+	for _, segment := range graph.Segments {
+		if _, ok := heads[segment.LinkHash().String()]; ok {
+			segments = append(segments, segment)
+		}
+	}
+
+	return segments, nil
+}
+
+// ReadEvents implements github.com/stratumn/go-core/store.EventReader.ReadEvents.
+// It returns an Unimplemented error if the server doesn't keep an event log.
+func (c *Client) ReadEvents(ctx context.Context, from uint64, limit int) ([]*store.Event, error) {
+	query := url.Values{}
+	query.Set("from", strconv.FormatUint(from, 10))
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	var events []*store.Event
+	if err := c.client.Do(ctx, http.MethodGet, "/events", query, nil, &events); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// getGraph gets the ancestors or descendants of a link.
+// The map graph route needs the map of the link, so the link is fetched
+// first.
+func (c *Client) getGraph(ctx context.Context, linkHash chainscript.LinkHash, direction string, depth int) (types.SegmentSlice, error) {
+	from, err := c.GetSegment(ctx, linkHash)
+	if err != nil || from == nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("process", from.Link.Meta.Process.Name)
+	query.Set("from", linkHash.String())
+	query.Set("direction", direction)
+	query.Set("depth", strconv.Itoa(depth))
+
+	var graph storehttp.MapGraph
+	path := "/maps/" + url.PathEscape(from.Link.Meta.MapId) + "/graph"
+	if err := c.client.Do(ctx, http.MethodGet, path, query, nil, &graph); err != nil {
+		return nil, err
+	}
+
+	return graph.Segments, nil
+}
+
+/********** Batch implementation **********/
+
+// Batch buffers links and sends them to the server in a single request when
+// it is written.
+type Batch struct {
+	*bufferedbatch.Batch
+
+	client *Client
+}
+
+// Write implements github.com/stratumn/go-core/store.Batch.Write.
+func (b *Batch) Write(ctx context.Context) error {
+	if len(b.Links) == 0 {
+		return nil
+	}
+
+	return b.client.client.Do(ctx, http.MethodPost, "/batch/links", nil, b.Links, nil)
+}
@@ -0,0 +1,211 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storehttpclient
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-chainscript/chainscripttest"
+	"github.com/stratumn/go-core/dummystore"
+	"github.com/stratumn/go-core/eventlog"
+	"github.com/stratumn/go-core/jsonhttp"
+	"github.com/stratumn/go-core/jsonws"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/store/storehttp"
+	"github.com/stratumn/go-core/store/storetestcases"
+	"github.com/stratumn/go-core/store/storetesting"
+	"github.com/stratumn/go-core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// remoteStore is a client connected to a store server.
+type remoteStore struct {
+	*Client
+	server     *storehttp.Server
+	httpServer *httptest.Server
+}
+
+// startedEventLog is a started store that keeps an event log.
+type startedEventLog struct {
+	*storetesting.StartedStore
+	store.EventReader
+}
+
+func newRemoteStore(a store.Adapter) (*remoteStore, error) {
+	started := storetesting.NewStartedStore(a)
+
+	var adapter store.Adapter = started
+	if reader, ok := a.(store.EventReader); ok {
+		adapter = &startedEventLog{StartedStore: started, EventReader: reader}
+	}
+
+	s := storehttp.New(adapter, &storehttp.Config{StoreEventsChanSize: 8}, &jsonhttp.Config{}, &jsonws.BasicConfig{}, &jsonws.BufferedConnConfig{
+		Size:         256,
+		WriteTimeout: 10 * time.Second,
+		PongTimeout:  70 * time.Second,
+		PingInterval: time.Minute,
+		MaxMsgSize:   1024,
+	})
+	go s.Start()
+	<-started.Started
+
+	httpServer := httptest.NewServer(s)
+
+	c, err := New(&Config{URL: httpServer.URL, ReconnectInterval: 10 * time.Millisecond})
+	if err != nil {
+		return nil, err
+	}
+
+	return &remoteStore{Client: c, server: s, httpServer: httpServer}, nil
+}
+
+func (r *remoteStore) free() {
+	r.Close()
+	r.httpServer.Close()
+	r.server.Shutdown(context.Background())
+}
+
+func TestClient(t *testing.T) {
+	factory := storetestcases.Factory{
+		New: func() (store.Adapter, error) {
+			return newRemoteStore(dummystore.New(&dummystore.Config{}))
+		},
+		Free: func(a store.Adapter) {
+			a.(*remoteStore).free()
+		},
+	}
+
+	factory.RunStoreTests(t)
+}
+
+func TestNew_invalidURL(t *testing.T) {
+	for _, u := range []string{"", "localhost:5000", "ftp://localhost"} {
+		_, err := New(&Config{URL: u})
+		assert.Error(t, err, u)
+	}
+}
+
+func TestClient_errors(t *testing.T) {
+	ctx := context.Background()
+	r, err := newRemoteStore(dummystore.New(&dummystore.Config{}))
+	require.NoError(t, err)
+	defer r.free()
+
+	t.Run("missing segment", func(t *testing.T) {
+		s, err := r.GetSegment(ctx, chainscripttest.RandomHash())
+		assert.NoError(t, err)
+		assert.Nil(t, s)
+
+		e, err := r.GetEvidences(ctx, chainscripttest.RandomHash())
+		assert.NoError(t, err)
+		assert.Empty(t, e)
+	})
+
+	t.Run("invalid filter", func(t *testing.T) {
+		_, err := r.FindSegments(ctx, &store.SegmentFilter{
+			Pagination: store.Pagination{Limit: store.MaxLimit + 1},
+		})
+		require.Error(t, err)
+		assert.Equal(t, errorcode.InvalidArgument, err.(*types.Error).Code)
+	})
+
+	t.Run("server unavailable", func(t *testing.T) {
+		c, err := New(&Config{URL: "http://127.0.0.1:1"})
+		require.NoError(t, err)
+
+		_, err = c.GetInfo(ctx)
+		require.Error(t, err)
+		assert.Equal(t, errorcode.Unavailable, err.(*types.Error).Code)
+	})
+}
+
+func TestClient_graph(t *testing.T) {
+	ctx := context.Background()
+	r, err := newRemoteStore(dummystore.New(&dummystore.Config{}))
+	require.NoError(t, err)
+	defer r.free()
+
+	root := chainscripttest.NewLinkBuilder(t).WithRandomData().WithProcess("p").WithMapID("m").WithoutParent().Build()
+	child := chainscripttest.NewLinkBuilder(t).WithRandomData().Branch(t, root).Build()
+
+	rootHash, err := r.CreateLink(ctx, root)
+	require.NoError(t, err)
+	childHash, err := r.CreateLink(ctx, child)
+	require.NoError(t, err)
+
+	heads, err := r.GetMapHeads(ctx, "p", "m")
+	require.NoError(t, err)
+	require.Len(t, heads, 1)
+	assert.Equal(t, childHash, heads[0].LinkHash())
+
+	ancestors, err := r.GetAncestors(ctx, childHash, 0)
+	require.NoError(t, err)
+	require.Len(t, ancestors, 1)
+	assert.Equal(t, rootHash, ancestors[0].LinkHash())
+
+	descendants, err := r.GetDescendants(ctx, rootHash, 1)
+	require.NoError(t, err)
+	require.Len(t, descendants, 1)
+	assert.Equal(t, childHash, descendants[0].LinkHash())
+}
+
+func TestClient_ReadEvents(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("no event log", func(t *testing.T) {
+		r, err := newRemoteStore(dummystore.New(&dummystore.Config{}))
+		require.NoError(t, err)
+		defer r.free()
+
+		_, err = r.ReadEvents(ctx, 0, 0)
+		require.Error(t, err)
+		assert.Equal(t, errorcode.Unimplemented, err.(*types.Error).Code)
+	})
+
+	t.Run("event log", func(t *testing.T) {
+		a := dummystore.New(&dummystore.Config{})
+		l, err := eventlog.Wrap(a, a, &eventlog.Config{})
+		require.NoError(t, err)
+
+		r, err := newRemoteStore(l)
+		require.NoError(t, err)
+		defer r.free()
+
+		events := make(chan *store.Event, 2)
+		r.AddStoreEventChannel(events)
+
+		var links []*chainscript.Link
+		for i := 0; i < 2; i++ {
+			link := chainscripttest.RandomLink(t)
+			_, err := r.CreateLink(ctx, link)
+			require.NoError(t, err)
+			links = append(links, link)
+			<-events
+		}
+
+		read, err := r.ReadEvents(ctx, 2, 10)
+		require.NoError(t, err)
+		require.Len(t, read, 1)
+		assert.Equal(t, uint64(2), read[0].Sequence)
+		assert.Equal(t, store.SavedLinks, read[0].EventType)
+		chainscripttest.LinksEqual(t, links[1], read[0].Data.([]*chainscript.Link)[0])
+	})
+}
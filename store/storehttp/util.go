@@ -15,8 +15,10 @@
 package storehttp
 
 import (
+	"encoding/json"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/stratumn/go-chainscript"
 	"github.com/stratumn/go-core/store"
@@ -33,17 +35,35 @@ func parseSegmentFilter(r *http.Request) (*store.SegmentFilter, error) {
 		mapIDs           = append(q["mapIds[]"], q["mapIds%5B%5D"]...)
 		linkHashesStr    = append(q["linkHashes[]"], q["linkHashes%5B%5D"]...)
 		process          = q.Get("process")
+		processes        = append(q["processes[]"], q["processes%5B%5D"]...)
+		step             = q.Get("step")
+		steps            = append(q["steps[]"], q["steps%5B%5D"]...)
 		withoutParentStr = q.Get("withoutParent")
 		prevLinkHashStr  = q.Get("prevLinkHash")
 		referencingStr   = q.Get("referencing")
+		createdAfterStr  = q.Get("createdAfter")
+		createdBeforeStr = q.Get("createdBefore")
+		dataStr          = q.Get("data")
 		tags             = append(q["tags[]"], q["tags%5B%5D"]...)
+		tagsAny          = append(q["tagsAny[]"], q["tagsAny%5B%5D"]...)
+		reverseStr       = q.Get("reverse")
 	)
 
 	filter := &store.SegmentFilter{
 		Pagination: *pagination,
 		MapIDs:     mapIDs,
 		Process:    process,
+		Processes:  processes,
+		Step:       step,
+		Steps:      steps,
 		Tags:       tags,
+		TagsAny:    tagsAny,
+	}
+
+	if len(filter.Cursor) > 0 {
+		if _, err := store.ParseSegmentCursor(filter.Cursor); err != nil {
+			return nil, newErrCursor("")
+		}
 	}
 
 	if len(withoutParentStr) > 0 {
@@ -53,6 +73,13 @@ func parseSegmentFilter(r *http.Request) (*store.SegmentFilter, error) {
 		}
 	}
 
+	if len(reverseStr) > 0 {
+		filter.Reverse, err = strconv.ParseBool(reverseStr)
+		if err != nil {
+			return nil, newErrReverse("")
+		}
+	}
+
 	if len(prevLinkHashStr) > 0 {
 		filter.PrevLinkHash, err = chainscript.NewLinkHashFromString(prevLinkHashStr)
 		if err != nil {
@@ -78,6 +105,36 @@ func parseSegmentFilter(r *http.Request) (*store.SegmentFilter, error) {
 		}
 	}
 
+	if len(createdAfterStr) > 0 {
+		createdAfter, err := time.Parse(time.RFC3339Nano, createdAfterStr)
+		if err != nil {
+			return nil, newErrCreatedAfter("")
+		}
+
+		filter.CreatedAfter = &createdAfter
+	}
+
+	if len(createdBeforeStr) > 0 {
+		createdBefore, err := time.Parse(time.RFC3339Nano, createdBeforeStr)
+		if err != nil {
+			return nil, newErrCreatedBefore("")
+		}
+
+		filter.CreatedBefore = &createdBefore
+	}
+
+	if len(dataStr) > 0 {
+		if err := json.Unmarshal([]byte(dataStr), &filter.Data); err != nil {
+			return nil, newErrData("")
+		}
+
+		for i := range filter.Data {
+			if err := filter.Data[i].Validate(); err != nil {
+				return nil, newErrData(err.Error())
+			}
+		}
+	}
+
 	return filter, nil
 }
 
@@ -87,14 +144,99 @@ func parseMapFilter(r *http.Request) (*store.MapFilter, error) {
 		return nil, err
 	}
 
-	var process = r.URL.Query().Get("process")
+	q := r.URL.Query()
 
 	return &store.MapFilter{
 		Pagination: *pagination,
-		Process:    process,
+		Process:    q.Get("process"),
+		Prefix:     q.Get("prefix"),
+		Suffix:     q.Get("suffix"),
 	}, nil
 }
 
+const (
+	graphAncestors   = "ancestors"
+	graphDescendants = "descendants"
+)
+
+type graphQuery struct {
+	Process   string
+	From      chainscript.LinkHash
+	Heads     bool
+	Direction string
+	Depth     int
+}
+
+func parseGraphQuery(r *http.Request) (*graphQuery, error) {
+	var (
+		err      error
+		q        = r.URL.Query()
+		fromStr  = q.Get("from")
+		headsStr = q.Get("heads")
+		depth    = q.Get("depth")
+		query    = &graphQuery{
+			Process:   q.Get("process"),
+			Direction: q.Get("direction"),
+		}
+	)
+
+	if query.Process == "" {
+		return nil, newErrProcess("")
+	}
+
+	if len(fromStr) > 0 {
+		query.From, err = chainscript.NewLinkHashFromString(fromStr)
+		if err != nil {
+			return nil, newErrFrom("")
+		}
+	}
+
+	if len(headsStr) > 0 {
+		query.Heads, err = strconv.ParseBool(headsStr)
+		if err != nil || query.Heads && query.From != nil {
+			return nil, newErrHeads("")
+		}
+	}
+
+	switch query.Direction {
+	case "":
+		query.Direction = graphDescendants
+	case graphAncestors, graphDescendants:
+	default:
+		return nil, newErrDirection("")
+	}
+
+	if depth != "" {
+		if query.Depth, err = strconv.Atoi(depth); err != nil || query.Depth < 0 {
+			return nil, newErrDepth("")
+		}
+	}
+
+	return query, nil
+}
+
+func parseEventsQuery(r *http.Request) (uint64, int, error) {
+	var (
+		err     error
+		from    uint64
+		q       = r.URL.Query()
+		fromStr = q.Get("from")
+	)
+
+	if fromStr != "" {
+		if from, err = strconv.ParseUint(fromStr, 10, 64); err != nil {
+			return 0, 0, newErrSequence("")
+		}
+	}
+
+	pagination, err := parsePagination(r)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return from, pagination.Limit, nil
+}
+
 func parsePagination(r *http.Request) (*store.Pagination, error) {
 	var err error
 
@@ -122,5 +264,6 @@ func parsePagination(r *http.Request) (*store.Pagination, error) {
 	return &store.Pagination{
 		Offset: offset,
 		Limit:  limit,
+		Cursor: q.Get("cursor"),
 	}, nil
 }
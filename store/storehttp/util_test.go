@@ -19,18 +19,21 @@ import (
 
 	"github.com/stratumn/go-core/jsonhttp"
 	"github.com/stratumn/go-core/jsonws"
+	"github.com/stratumn/go-core/store"
 	"github.com/stratumn/go-core/store/storetesting"
 )
 
 func createServer() (*Server, *storetesting.MockAdapter) {
 	a := &storetesting.MockAdapter{}
-	s := New(a, &Config{}, &jsonhttp.Config{}, &jsonws.BasicConfig{}, &jsonws.BufferedConnConfig{
+	return newTestServer(a), a
+}
+
+func newTestServer(a store.Adapter) *Server {
+	return New(a, &Config{}, &jsonhttp.Config{}, &jsonws.BasicConfig{}, &jsonws.BufferedConnConfig{
 		Size:         256,
 		WriteTimeout: 10 * time.Second,
 		PongTimeout:  70 * time.Second,
 		PingInterval: time.Minute,
 		MaxMsgSize:   1024,
 	})
-
-	return s, a
 }
@@ -0,0 +1,442 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storehttp
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-core/jsonws"
+	"github.com/stratumn/go-core/monitoring"
+	"github.com/stratumn/go-core/store"
+)
+
+// Types of the messages web socket clients send to filter or resume the
+// events they receive, and of the messages the server replies with.
+const (
+	// SubscribeMsg adds or replaces a subscription of the connection.
+	SubscribeMsg = "subscribe"
+
+	// UnsubscribeMsg removes a subscription of the connection.
+	UnsubscribeMsg = "unsubscribe"
+
+	// SubscribedMsg acknowledges a subscribe message.
+	SubscribedMsg = "subscribed"
+
+	// UnsubscribedMsg acknowledges an unsubscribe message.
+	UnsubscribedMsg = "unsubscribed"
+
+	// ResumeMsg asks for the events the connection missed.
+	ResumeMsg = "resume"
+
+	// ResumedMsg acknowledges a resume message.
+	ResumedMsg = "resumed"
+
+	// ErrorMsg is sent when a message from the client is invalid.
+	ErrorMsg = "error"
+)
+
+// resumePageSize is the number of events read at once from the event log
+// when a connection resumes.
+const resumePageSize = 100
+
+// Subscription is the data of subscribe and unsubscribe messages.
+// The filter is ignored when unsubscribing, and pagination is always ignored.
+// Without a filter, the subscription matches every event.
+type Subscription struct {
+	ID     string               `json:"id"`
+	Filter *store.SegmentFilter `json:"filter,omitempty"`
+}
+
+// Resume is the data of resume messages.
+// Events are sent starting at the given sequence number, or at the oldest
+// event of the log if it is zero.
+type Resume struct {
+	From uint64 `json:"from"`
+}
+
+// wsRequest is a message sent by a web socket client.
+type wsRequest struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// wsEvent is a store event from an event log sent to web socket clients.
+type wsEvent struct {
+	Type     string      `json:"type"`
+	Data     interface{} `json:"data"`
+	Sequence uint64      `json:"sequence"`
+}
+
+// newWSEvent creates the message sent to web socket clients for an event.
+// Events that don't come from an event log are sent as plain messages.
+func newWSEvent(event *store.Event) interface{} {
+	if event.Sequence == 0 {
+		return &jsonws.Message{
+			Type: string(event.EventType),
+			Data: event.Data,
+		}
+	}
+
+	return &wsEvent{
+		Type:     string(event.EventType),
+		Data:     event.Data,
+		Sequence: event.Sequence,
+	}
+}
+
+// wsSubscriber contains the subscriptions of a web socket connection.
+// Connections that have subscriptions or are resuming are tagged with their
+// subscriber in the hub, so that only the events they should receive are
+// sent to them. Other connections receive every event.
+type wsSubscriber struct {
+	filters map[string]*store.SegmentFilter
+
+	// resumedUntil is the sequence number of the last event sent when the
+	// connection resumed. Older events must not be sent again.
+	resumedUntil uint64
+
+	// cancelResume stops the replay of missed events. It is only set while
+	// the connection is resuming, during which new events from the log are
+	// held back since the replay sends them in order.
+	cancelResume context.CancelFunc
+}
+
+// wsResumed is sent to the main loop by the replay of missed events once it
+// reached the end of the event log.
+type wsResumed struct {
+	ctx        context.Context
+	conn       *jsonws.BufferedConn
+	subscriber *wsSubscriber
+	reader     store.EventReader
+
+	// last is the sequence number of the last event read, and next the
+	// sequence number the replay would have read next.
+	last uint64
+	next uint64
+	err  error
+}
+
+// snapshot copies the subscriptions of the subscriber.
+func (s *wsSubscriber) snapshot() *wsSubscriber {
+	filters := make(map[string]*store.SegmentFilter, len(s.filters))
+	for id, filter := range s.filters {
+		filters[id] = filter
+	}
+
+	return &wsSubscriber{filters: filters}
+}
+
+// matchLink returns whether a link matches one of the subscriptions.
+// Without subscriptions, every link matches.
+func (s *wsSubscriber) matchLink(link *chainscript.Link) bool {
+	if len(s.filters) == 0 {
+		return true
+	}
+
+	for _, filter := range s.filters {
+		if filter.MatchLink(link) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// filterEvent returns the part of an event the subscriber should receive, or
+// nil if it shouldn't receive anything. Evidences are matched using the given
+// links.
+func (s *wsSubscriber) filterEvent(event *store.Event, links map[string]*chainscript.Link) interface{} {
+	if filtered := event.Filter(s.matchLink, links); filtered != nil {
+		return newWSEvent(filtered)
+	}
+
+	return nil
+}
+
+// broadcast sends a store event to the web socket connections that should
+// receive it.
+func (s *Server) broadcast(event *store.Event) {
+	if event.Sequence > s.sequence {
+		s.sequence = event.Sequence
+	}
+
+	s.ws.BroadcastUntagged(newWSEvent(event))
+
+	if len(s.subscribers) == 0 {
+		return
+	}
+
+	links := s.eventLinks(event)
+
+	for conn, subscriber := range s.subscribers {
+		resuming := subscriber.cancelResume != nil
+		if event.Sequence == 0 || !resuming && event.Sequence > subscriber.resumedUntil {
+			if msg := subscriber.filterEvent(event, links); msg != nil {
+				s.ws.Broadcast(msg, subscriber)
+			}
+		}
+
+		s.release(conn, subscriber)
+	}
+}
+
+// subscriber returns the subscriber of a connection.
+// The connection is tagged when it doesn't have one yet.
+func (s *Server) subscriber(conn *jsonws.BufferedConn) *wsSubscriber {
+	subscriber, ok := s.subscribers[conn]
+	if !ok {
+		subscriber = &wsSubscriber{filters: map[string]*store.SegmentFilter{}}
+		s.subscribers[conn] = subscriber
+		s.ws.Tag(conn, subscriber)
+	}
+
+	return subscriber
+}
+
+// release untags a connection that doesn't have subscriptions and isn't
+// resuming anymore, so that it receives every event again.
+func (s *Server) release(conn *jsonws.BufferedConn, subscriber *wsSubscriber) {
+	resuming := subscriber.cancelResume != nil
+	if len(subscriber.filters) == 0 && !resuming && subscriber.resumedUntil <= s.sequence {
+		s.ws.Untag(conn, subscriber)
+		delete(s.subscribers, conn)
+	}
+}
+
+// eventLinks loads the links of the evidences of an event.
+// Evidence events don't contain the links, so they are loaded once for all
+// the subscribers.
+func (s *Server) eventLinks(event *store.Event) map[string]*chainscript.Link {
+	if evidences, ok := event.Data.(map[string]*chainscript.Evidence); ok {
+		return s.evidenceLinks(evidences)
+	}
+
+	return nil
+}
+
+// evidenceLinks loads the links of saved evidences.
+func (s *Server) evidenceLinks(evidences map[string]*chainscript.Evidence) map[string]*chainscript.Link {
+	ctx := context.Background()
+	links := make(map[string]*chainscript.Link, len(evidences))
+
+	for linkHashStr := range evidences {
+		linkHash, err := chainscript.NewLinkHashFromString(linkHashStr)
+		if err != nil {
+			continue
+		}
+
+		segment, err := s.adapter.GetSegment(ctx, linkHash)
+		if err != nil {
+			monitoring.LogEntry().WithField("error", err).Warn("Could not load link of saved evidence")
+			continue
+		}
+
+		if segment != nil {
+			links[linkHashStr] = segment.Link
+		}
+	}
+
+	return links
+}
+
+// handleWebSocketMsg handles subscribe, unsubscribe and resume messages.
+// Other messages are ignored.
+func (s *Server) handleWebSocketMsg(msg jsonws.BasicConnMsg) {
+	js, err := json.Marshal(msg.Msg)
+	if err != nil {
+		return
+	}
+
+	var req wsRequest
+	if err := json.Unmarshal(js, &req); err != nil {
+		return
+	}
+
+	switch req.Type {
+	case SubscribeMsg:
+		s.subscribe(msg.Conn, req.Data)
+	case UnsubscribeMsg:
+		s.unsubscribe(msg.Conn, req.Data)
+	case ResumeMsg:
+		s.resume(msg.Conn, req.Data)
+	}
+}
+
+func (s *Server) subscribe(conn *jsonws.BufferedConn, data json.RawMessage) {
+	var sub Subscription
+	if err := json.Unmarshal(data, &sub); err != nil {
+		writeWebSocketError(conn, "subscription must be an object with an id and a filter")
+		return
+	}
+
+	if sub.ID == "" {
+		writeWebSocketError(conn, "subscription id required")
+		return
+	}
+
+	if sub.Filter == nil {
+		sub.Filter = &store.SegmentFilter{}
+	}
+
+	for i := range sub.Filter.Data {
+		if err := sub.Filter.Data[i].Validate(); err != nil {
+			writeWebSocketError(conn, err.Error())
+			return
+		}
+	}
+
+	s.subscriber(conn).filters[sub.ID] = sub.Filter
+
+	writeWebSocketMsg(conn, &jsonws.Message{
+		Type: SubscribedMsg,
+		Data: &Subscription{ID: sub.ID},
+	})
+}
+
+func (s *Server) unsubscribe(conn *jsonws.BufferedConn, data json.RawMessage) {
+	var sub Subscription
+	if err := json.Unmarshal(data, &sub); err != nil {
+		writeWebSocketError(conn, "subscription must be an object with an id")
+		return
+	}
+
+	if subscriber, ok := s.subscribers[conn]; ok {
+		delete(subscriber.filters, sub.ID)
+		s.release(conn, subscriber)
+	}
+
+	writeWebSocketMsg(conn, &jsonws.Message{
+		Type: UnsubscribedMsg,
+		Data: &Subscription{ID: sub.ID},
+	})
+}
+
+// resume sends the events a connection missed using the event log.
+// The log is read in its own goroutine so that other connections aren't
+// blocked. Past events are broadcast through the hub so that they are
+// delivered after the events that were already broadcast to the connection.
+// Newer events are held back until the replay catches up with the log.
+func (s *Server) resume(conn *jsonws.BufferedConn, data json.RawMessage) {
+	reader, ok := s.adapter.(store.EventReader)
+	if !ok {
+		writeWebSocketError(conn, "the store doesn't keep an event log")
+		return
+	}
+
+	var r Resume
+	if err := json.Unmarshal(data, &r); err != nil {
+		writeWebSocketError(conn, "resume must be an object with a from sequence number")
+		return
+	}
+
+	subscriber := s.subscriber(conn)
+	if subscriber.cancelResume != nil {
+		writeWebSocketError(conn, "the connection is already resuming")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	subscriber.cancelResume = cancel
+
+	resumed := &wsResumed{ctx: ctx, conn: conn, subscriber: subscriber, reader: reader, next: r.From}
+	go s.replay(resumed, subscriber.snapshot(), &r)
+}
+
+// replay broadcasts the events of the log starting at the given sequence
+// number to a resuming connection, then reports to the main loop.
+// The acknowledgement, if any, is sent before the first event.
+// Events are filtered with the subscriptions the connection had when the
+// replay started.
+func (s *Server) replay(resumed *wsResumed, filters *wsSubscriber, ack *Resume) {
+	for {
+		events, err := resumed.reader.ReadEvents(resumed.ctx, resumed.next, resumePageSize)
+		if err != nil {
+			writeWebSocketError(resumed.conn, err.Error())
+			resumed.err = err
+			break
+		}
+
+		if ack != nil {
+			s.ws.Broadcast(&jsonws.Message{Type: ResumedMsg, Data: ack}, resumed.subscriber)
+			ack = nil
+		}
+
+		if len(events) == 0 {
+			break
+		}
+
+		for _, event := range events {
+			if msg := filters.filterEvent(event, s.eventLinks(event)); msg != nil {
+				s.ws.Broadcast(msg, resumed.subscriber)
+			}
+		}
+
+		resumed.last = events[len(events)-1].Sequence
+		resumed.next = resumed.last + 1
+	}
+
+	select {
+	case s.wsResumedChan <- resumed:
+	case <-resumed.ctx.Done():
+	}
+}
+
+// resumed handles the end of a replay of missed events. The replay continues
+// if events were broadcast by the main loop after the replay read the end of
+// the log, otherwise the connection receives new events again.
+func (s *Server) resumed(resumed *wsResumed) {
+	subscriber, ok := s.subscribers[resumed.conn]
+	if !ok || subscriber != resumed.subscriber {
+		return
+	}
+
+	if resumed.last > subscriber.resumedUntil {
+		subscriber.resumedUntil = resumed.last
+	}
+
+	if resumed.err == nil && resumed.next <= s.sequence && resumed.last < s.sequence {
+		go s.replay(resumed, subscriber.snapshot(), nil)
+		return
+	}
+
+	s.stopResume(subscriber)
+	s.release(resumed.conn, subscriber)
+}
+
+// stopResume stops the replay of missed events of a subscriber.
+func (s *Server) stopResume(subscriber *wsSubscriber) {
+	if subscriber != nil && subscriber.cancelResume != nil {
+		subscriber.cancelResume()
+		subscriber.cancelResume = nil
+	}
+}
+
+// stopResumes stops the replays of missed events of all the subscribers.
+func (s *Server) stopResumes() {
+	for _, subscriber := range s.subscribers {
+		s.stopResume(subscriber)
+	}
+}
+func writeWebSocketError(conn *jsonws.BufferedConn, msg string) {
+	writeWebSocketMsg(conn, &jsonws.Message{Type: ErrorMsg, Data: msg})
+}
+
+func writeWebSocketMsg(conn *jsonws.BufferedConn, msg *jsonws.Message) {
+	if err := conn.WriteJSON(msg); err != nil {
+		monitoring.LogEntry().WithField("error", err).Warn("Failed to write web socket message")
+	}
+}
@@ -0,0 +1,349 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storehttp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-chainscript/chainscripttest"
+	"github.com/stratumn/go-core/dummystore"
+	"github.com/stratumn/go-core/eventlog"
+	"github.com/stratumn/go-core/jsonhttp"
+	"github.com/stratumn/go-core/jsonws"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/store/storetesting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startedEventLog signals when the server has started listening to the
+// events of an event log.
+type startedEventLog struct {
+	*storetesting.StartedStore
+	store.EventReader
+}
+
+type wsMsg struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+type wsLinksMsg struct {
+	Type     string              `json:"type"`
+	Data     []*chainscript.Link `json:"data"`
+	Sequence uint64              `json:"sequence"`
+}
+
+type wsEvidencesMsg struct {
+	Type string                           `json:"type"`
+	Data map[string]*chainscript.Evidence `json:"data"`
+}
+
+func dialWebSocket(t *testing.T, url string) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(url, "http")+"/websocket", nil)
+	require.NoError(t, err)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	return conn
+}
+
+func sendWebSocketMsg(t *testing.T, conn *websocket.Conn, msgType string, sub *Subscription, wantType string) *wsMsg {
+	require.NoError(t, conn.WriteJSON(&jsonws.Message{Type: msgType, Data: sub}))
+
+	var msg wsMsg
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, wantType, msg.Type)
+
+	return &msg
+}
+
+func readLinks(t *testing.T, conn *websocket.Conn) []*chainscript.Link {
+	return readLinksMsg(t, conn).Data
+}
+
+func readLinksMsg(t *testing.T, conn *websocket.Conn) *wsLinksMsg {
+	var msg wsLinksMsg
+	require.NoError(t, conn.ReadJSON(&msg))
+	require.Equal(t, string(store.SavedLinks), msg.Type)
+	return &msg
+}
+
+func sendResume(t *testing.T, conn *websocket.Conn, from uint64, wantType string) {
+	require.NoError(t, conn.WriteJSON(&jsonws.Message{Type: ResumeMsg, Data: &Resume{From: from}}))
+
+	var msg wsMsg
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, wantType, msg.Type)
+}
+
+func readEvidences(t *testing.T, conn *websocket.Conn) map[string]*chainscript.Evidence {
+	var msg wsEvidencesMsg
+	require.NoError(t, conn.ReadJSON(&msg))
+	require.Equal(t, string(store.SavedEvidences), msg.Type)
+	return msg.Data
+}
+
+func TestWebSocket_subscriptions(t *testing.T) {
+	ctx := context.Background()
+	a := dummystore.New(&dummystore.Config{})
+	started := storetesting.NewStartedStore(a)
+
+	s := New(started, &Config{StoreEventsChanSize: 8}, &jsonhttp.Config{}, &jsonws.BasicConfig{}, &jsonws.BufferedConnConfig{
+		Size:         256,
+		WriteTimeout: 10 * time.Second,
+		PongTimeout:  70 * time.Second,
+		PingInterval: time.Minute,
+		MaxMsgSize:   1024,
+	})
+	go s.Start()
+	<-started.Started
+
+	httpServer := httptest.NewServer(s)
+	defer httpServer.Close()
+
+	filtered := dialWebSocket(t, httpServer.URL)
+	defer filtered.Close()
+
+	all := dialWebSocket(t, httpServer.URL)
+	defer all.Close()
+
+	// Acknowledgements guarantee that the connections are registered.
+	sendWebSocketMsg(t, filtered, SubscribeMsg, &Subscription{
+		ID:     "m1",
+		Filter: &store.SegmentFilter{MapIDs: []string{"m1"}, Tags: []string{"important"}},
+	}, SubscribedMsg)
+	sendWebSocketMsg(t, all, UnsubscribeMsg, &Subscription{ID: "unknown"}, UnsubscribedMsg)
+
+	l1 := chainscripttest.NewLinkBuilder(t).WithMapID("m2").WithTags("important").Build()
+	l2 := chainscripttest.NewLinkBuilder(t).WithMapID("m1").WithTags("other").Build()
+	l3 := chainscripttest.NewLinkBuilder(t).WithMapID("m1").WithTags("important").Build()
+	lh1, _ := a.CreateLink(ctx, l1)
+	_, _ = a.CreateLink(ctx, l2)
+	lh3, _ := a.CreateLink(ctx, l3)
+
+	t.Run("filters links", func(t *testing.T) {
+		links := readLinks(t, filtered)
+		require.Len(t, links, 1)
+		chainscripttest.LinksEqual(t, l3, links[0])
+	})
+
+	t.Run("broadcasts links to connections without subscriptions", func(t *testing.T) {
+		for _, want := range []*chainscript.Link{l1, l2, l3} {
+			links := readLinks(t, all)
+			require.Len(t, links, 1)
+			chainscripttest.LinksEqual(t, want, links[0])
+		}
+	})
+
+	t.Run("filters evidences", func(t *testing.T) {
+		require.NoError(t, a.AddEvidence(ctx, lh1, chainscripttest.RandomEvidence(t)))
+		require.NoError(t, a.AddEvidence(ctx, lh3, chainscripttest.RandomEvidence(t)))
+
+		evidences := readEvidences(t, filtered)
+		assert.Len(t, evidences, 1)
+		assert.Contains(t, evidences, lh3.String())
+
+		assert.Contains(t, readEvidences(t, all), lh1.String())
+		assert.Contains(t, readEvidences(t, all), lh3.String())
+	})
+
+	t.Run("unsubscribe", func(t *testing.T) {
+		sendWebSocketMsg(t, filtered, UnsubscribeMsg, &Subscription{ID: "m1"}, UnsubscribedMsg)
+
+		l4 := chainscripttest.NewLinkBuilder(t).WithMapID("m2").Build()
+		_, _ = a.CreateLink(ctx, l4)
+
+		links := readLinks(t, filtered)
+		require.Len(t, links, 1)
+		chainscripttest.LinksEqual(t, l4, links[0])
+	})
+
+	t.Run("invalid subscriptions", func(t *testing.T) {
+		msg := sendWebSocketMsg(t, filtered, SubscribeMsg, &Subscription{}, ErrorMsg)
+		assert.Contains(t, string(msg.Data), "id")
+
+		sendWebSocketMsg(t, filtered, SubscribeMsg, &Subscription{
+			ID: "invalid",
+			Filter: &store.SegmentFilter{Data: []store.DataPredicate{{
+				Path: "a",
+				Op:   "unknown",
+			}}},
+		}, ErrorMsg)
+	})
+
+	t.Run("resume without event log", func(t *testing.T) {
+		sendResume(t, filtered, 1, ErrorMsg)
+	})
+}
+
+func TestWebSocket_resume(t *testing.T) {
+	ctx := context.Background()
+	a := dummystore.New(&dummystore.Config{})
+	l, err := eventlog.Wrap(a, a, &eventlog.Config{})
+	require.NoError(t, err)
+
+	// Events saved before the server starts are only in the log.
+	past := []*chainscript.Link{
+		chainscripttest.NewLinkBuilder(t).WithMapID("m1").Build(),
+		chainscripttest.NewLinkBuilder(t).WithMapID("m2").Build(),
+		chainscripttest.NewLinkBuilder(t).WithMapID("m1").Build(),
+	}
+	for _, link := range past {
+		_, err := l.CreateLink(ctx, link)
+		require.NoError(t, err)
+	}
+	for l.Sequence() < uint64(len(past)) {
+		time.Sleep(time.Millisecond)
+	}
+
+	started := &startedEventLog{StartedStore: storetesting.NewStartedStore(l), EventReader: l}
+	s := newTestServer(started)
+	go s.Start()
+	<-started.Started
+
+	httpServer := httptest.NewServer(s)
+	defer httpServer.Close()
+
+	t.Run("sends missed events then new events", func(t *testing.T) {
+		conn := dialWebSocket(t, httpServer.URL)
+		defer conn.Close()
+
+		sendResume(t, conn, 2, ResumedMsg)
+
+		for i, want := range past[1:] {
+			msg := readLinksMsg(t, conn)
+			assert.Equal(t, uint64(i+2), msg.Sequence)
+			require.Len(t, msg.Data, 1)
+			chainscripttest.LinksEqual(t, want, msg.Data[0])
+		}
+
+		for i := 0; i < 2; i++ {
+			link := chainscripttest.RandomLink(t)
+			_, err := l.CreateLink(ctx, link)
+			require.NoError(t, err)
+
+			msg := readLinksMsg(t, conn)
+			assert.Equal(t, l.Sequence(), msg.Sequence)
+			require.Len(t, msg.Data, 1)
+			chainscripttest.LinksEqual(t, link, msg.Data[0])
+		}
+	})
+
+	t.Run("applies subscriptions", func(t *testing.T) {
+		conn := dialWebSocket(t, httpServer.URL)
+		defer conn.Close()
+
+		sendWebSocketMsg(t, conn, SubscribeMsg, &Subscription{
+			ID:     "m2",
+			Filter: &store.SegmentFilter{MapIDs: []string{"m2"}},
+		}, SubscribedMsg)
+		sendResume(t, conn, 0, ResumedMsg)
+
+		link := chainscripttest.NewLinkBuilder(t).WithMapID("m2").Build()
+		_, err := l.CreateLink(ctx, link)
+		require.NoError(t, err)
+
+		msg := readLinksMsg(t, conn)
+		assert.Equal(t, uint64(2), msg.Sequence)
+		chainscripttest.LinksEqual(t, past[1], msg.Data[0])
+
+		msg = readLinksMsg(t, conn)
+		assert.Equal(t, l.Sequence(), msg.Sequence)
+		chainscripttest.LinksEqual(t, link, msg.Data[0])
+	})
+
+}
+
+// gatedEventLog holds the first read of the event log until it is released.
+type gatedEventLog struct {
+	*startedEventLog
+	reading chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (l *gatedEventLog) ReadEvents(ctx context.Context, from uint64, limit int) ([]*store.Event, error) {
+	events, err := l.startedEventLog.ReadEvents(ctx, from, limit)
+	l.once.Do(func() {
+		close(l.reading)
+		<-l.release
+	})
+
+	return events, err
+}
+
+func TestWebSocket_resumeHoldsNewEvents(t *testing.T) {
+	ctx := context.Background()
+	a := dummystore.New(&dummystore.Config{})
+	l, err := eventlog.Wrap(a, a, &eventlog.Config{})
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err := l.CreateLink(ctx, chainscripttest.RandomLink(t))
+		require.NoError(t, err)
+	}
+	for l.Sequence() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	gated := &gatedEventLog{
+		startedEventLog: &startedEventLog{StartedStore: storetesting.NewStartedStore(l), EventReader: l},
+		reading:         make(chan struct{}),
+		release:         make(chan struct{}),
+	}
+	s := newTestServer(gated)
+	go s.Start()
+	<-gated.Started
+
+	httpServer := httptest.NewServer(s)
+	defer httpServer.Close()
+
+	conn := dialWebSocket(t, httpServer.URL)
+	defer conn.Close()
+	other := dialWebSocket(t, httpServer.URL)
+	defer other.Close()
+
+	require.NoError(t, conn.WriteJSON(&jsonws.Message{Type: ResumeMsg, Data: &Resume{From: 1}}))
+	<-gated.reading
+
+	// Events saved while the connection is resuming are broadcast to other
+	// connections but held back until the replay catches up.
+	for i := 0; i < 2; i++ {
+		_, err := l.CreateLink(ctx, chainscripttest.RandomLink(t))
+		require.NoError(t, err)
+		assert.Equal(t, uint64(i+3), readLinksMsg(t, other).Sequence)
+	}
+
+	close(gated.release)
+
+	var ack wsMsg
+	require.NoError(t, conn.ReadJSON(&ack))
+	assert.Equal(t, ResumedMsg, ack.Type)
+
+	for seq := uint64(1); seq <= 4; seq++ {
+		assert.Equal(t, seq, readLinksMsg(t, conn).Sequence)
+	}
+
+	_, err = l.CreateLink(ctx, chainscripttest.RandomLink(t))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), readLinksMsg(t, conn).Sequence)
+}
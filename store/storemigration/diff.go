@@ -0,0 +1,115 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storemigration
+
+import (
+	"context"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-core/store"
+)
+
+// Diff lists the differences between the source and target stores.
+type Diff struct {
+	SourceLinks int `json:"sourceLinks"`
+	TargetLinks int `json:"targetLinks"`
+
+	// Links of the source store missing in the target store.
+	MissingLinks []string `json:"missingLinks"`
+
+	// Links of the target store that miss some evidences of the source
+	// store.
+	MissingEvidences []string `json:"missingEvidences"`
+
+	// Links of the target store that aren't in the source store.
+	ExtraLinks []string `json:"extraLinks"`
+}
+
+// Consistent returns true if the target store contains exactly the links of
+// the source store and all their evidences.
+func (d *Diff) Consistent() bool {
+	return len(d.MissingLinks) == 0 && len(d.MissingEvidences) == 0 && len(d.ExtraLinks) == 0
+}
+
+// Diff compares the source and target stores.
+// Evidences the target store has in addition to the source store's aren't
+// considered differences.
+func (m *Migrator) Diff(ctx context.Context) (*Diff, error) {
+	d := &Diff{}
+
+	err := forEachSegment(ctx, m.source, func(segment *chainscript.Segment) error {
+		d.SourceLinks++
+
+		linkHash := segment.LinkHash()
+		existing, err := m.target.GetSegment(ctx, linkHash)
+		if err != nil {
+			return err
+		}
+
+		if existing == nil {
+			d.MissingLinks = append(d.MissingLinks, linkHash.String())
+			return nil
+		}
+
+		for _, e := range segment.Meta.Evidences {
+			if !hasEvidence(existing, e) {
+				d.MissingEvidences = append(d.MissingEvidences, linkHash.String())
+				break
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = forEachSegment(ctx, m.target, func(segment *chainscript.Segment) error {
+		d.TargetLinks++
+
+		linkHash := segment.LinkHash()
+		existing, err := m.source.GetSegment(ctx, linkHash)
+		if err != nil {
+			return err
+		}
+
+		if existing == nil {
+			d.ExtraLinks = append(d.ExtraLinks, linkHash.String())
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func forEachSegment(ctx context.Context, a store.SegmentReader, fn func(*chainscript.Segment) error) error {
+	it, err := store.IterateSegments(ctx, a, &store.SegmentFilter{})
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	for it.Next() {
+		if err := fn(it.Segment()); err != nil {
+			return err
+		}
+	}
+
+	return it.Err()
+}
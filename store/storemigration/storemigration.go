@@ -0,0 +1,385 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package storemigration copies the content of a store to another store.
+//
+// Segments are copied in topological order (parents and referenced links
+// first), so the target store can check the existence of parents. The source
+// store is read in reverse order since parents usually have a lower priority
+// than their children, which keeps few segments waiting for their parent.
+// Copying is idempotent: links that are already in the target are skipped
+// and only their missing evidences are added.
+//
+// When the target store implements store.KeyValueStore, the progress of the
+// copy is saved in it so that an interrupted migration can be resumed.
+//
+// Key-value pairs are copied too when the source store implements
+// store.KeyValueIterator. The copy fails if the source store has key-value
+// pairs that the target store can't save.
+package storemigration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-core/monitoring"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+)
+
+const (
+	// DefaultCheckpointKey is the default key under which the progress of a
+	// migration is saved in the target store.
+	DefaultCheckpointKey = "storemigration:checkpoint"
+
+	// DefaultEventsChanSize is the default size of the channel receiving the
+	// source events.
+	DefaultEventsChanSize = 256
+)
+
+// Config contains configuration options for a migration.
+type Config struct {
+	// CheckpointKey is the key under which the progress of the migration is
+	// saved in the target store. Defaults to DefaultCheckpointKey.
+	CheckpointKey string
+
+	// Tail makes Run keep applying the events of the source store to the
+	// target store after the copy, until its context is cancelled.
+	Tail bool
+
+	// EventsChanSize is the size of the channel receiving the source
+	// events. Defaults to DefaultEventsChanSize.
+	EventsChanSize int
+}
+
+// Checkpoint is the progress of a copy saved in the target store.
+// Every segment before the cursor (in the reverse segments ordering) has been
+// copied.
+type Checkpoint struct {
+	Cursor string `json:"cursor"`
+}
+
+// Stats counts what has been written to the target store.
+type Stats struct {
+	// Links created in the target store.
+	Links int `json:"links"`
+
+	// Evidences added to the target store.
+	Evidences int `json:"evidences"`
+
+	// Key-value pairs set in the target store.
+	Values int `json:"values"`
+
+	// Links skipped because they were already in the target store.
+	Skipped int `json:"skipped"`
+
+	// Source events applied to the target store while tailing.
+	Events int `json:"events"`
+}
+
+// Migrator copies a source store to a target store.
+type Migrator struct {
+	source store.Adapter
+	target store.Adapter
+	config *Config
+
+	stats Stats
+}
+
+// New creates a migrator from the source store to the target store.
+func New(source, target store.Adapter, config *Config) *Migrator {
+	c := *config
+	if c.CheckpointKey == "" {
+		c.CheckpointKey = DefaultCheckpointKey
+	}
+	if c.EventsChanSize <= 0 {
+		c.EventsChanSize = DefaultEventsChanSize
+	}
+
+	return &Migrator{
+		source: source,
+		target: target,
+		config: &c,
+	}
+}
+
+// Stats returns what has been written to the target store so far.
+func (m *Migrator) Stats() Stats {
+	return m.stats
+}
+
+// Run copies the source store to the target store.
+// If tailing is enabled, the source events are recorded during the copy and
+// applied once it's done. New events are then applied until the context is
+// cancelled, which lets clients switch to the target store without losing
+// any data.
+func (m *Migrator) Run(ctx context.Context) error {
+	var events *eventQueue
+	if m.config.Tail {
+		events = m.listen()
+	}
+
+	if err := m.Copy(ctx); err != nil {
+		return err
+	}
+
+	if events == nil {
+		return nil
+	}
+
+	return m.tail(ctx, events)
+}
+
+// Copy copies all the segments and key-value pairs of the source store to
+// the target store.
+// It resumes from the saved checkpoint if there is one, and removes it once
+// the copy is done.
+func (m *Migrator) Copy(ctx context.Context) error {
+	if err := m.copyValues(ctx); err != nil {
+		return err
+	}
+
+	checkpoint, err := m.loadCheckpoint(ctx)
+	if err != nil {
+		return err
+	}
+
+	sorter := store.NewTopologicalSorterWithLookup(
+		func(segment *chainscript.Segment) error {
+			return m.copySegment(ctx, segment)
+		},
+		func(linkHash chainscript.LinkHash) (bool, error) {
+			segment, err := m.target.GetSegment(ctx, linkHash)
+			return segment != nil, err
+		},
+	)
+
+	filter := &store.SegmentFilter{
+		Pagination: store.Pagination{
+			Limit:  store.MaxLimit,
+			Cursor: checkpoint.Cursor,
+		},
+		Reverse: true,
+	}
+
+	// The segments waiting for a dependency, in the order they were read.
+	var pending []*pendingSegment
+	previous := checkpoint.Cursor
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return types.WrapError(err, errorcode.Cancelled, store.Component, "migration interrupted")
+		}
+
+		segments, err := m.source.FindSegments(ctx, filter)
+		if err != nil {
+			return err
+		}
+
+		for _, segment := range segments.Segments {
+			if err := sorter.Add(segment); err != nil {
+				return err
+			}
+
+			if sorter.IsPending(segment.LinkHash()) {
+				pending = append(pending, &pendingSegment{linkHash: segment.LinkHash(), previous: previous})
+			}
+
+			previous = store.NewSegmentCursor(segment).String()
+		}
+
+		if segments.NextCursor == "" {
+			break
+		}
+
+		filter.Cursor = segments.NextCursor
+
+		// Segments waiting for a dependency would be lost if we resumed
+		// after them, so the copy resumes before the oldest one.
+		pending = stillPending(sorter, pending)
+		resume := previous
+		if len(pending) > 0 {
+			resume = pending[0].previous
+		}
+
+		if resume != checkpoint.Cursor {
+			checkpoint.Cursor = resume
+			if err := m.saveCheckpoint(ctx, checkpoint); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := sorter.Flush(); err != nil {
+		return err
+	}
+
+	return m.deleteCheckpoint(ctx)
+}
+
+// pendingSegment is a segment waiting for a dependency during a copy.
+type pendingSegment struct {
+	linkHash chainscript.LinkHash
+
+	// previous is the cursor of the segment read before it.
+	previous string
+}
+
+// stillPending removes the segments that have been emitted by the sorter.
+func stillPending(sorter *store.TopologicalSorter, pending []*pendingSegment) []*pendingSegment {
+	remaining := pending[:0]
+	for _, p := range pending {
+		if sorter.IsPending(p.linkHash) {
+			remaining = append(remaining, p)
+		}
+	}
+
+	return remaining
+}
+
+// copySegment copies a segment to the target store, unless it's already
+// there, and adds the evidences the target store doesn't have.
+func (m *Migrator) copySegment(ctx context.Context, segment *chainscript.Segment) error {
+	linkHash := segment.LinkHash()
+
+	existing, err := m.target.GetSegment(ctx, linkHash)
+	if err != nil {
+		return err
+	}
+
+	if existing == nil {
+		if _, err := m.target.CreateLink(ctx, segment.Link); err != nil {
+			return err
+		}
+
+		m.stats.Links++
+	} else {
+		m.stats.Skipped++
+	}
+
+	for _, e := range segment.Meta.Evidences {
+		if existing != nil && hasEvidence(existing, e) {
+			continue
+		}
+
+		if err := m.target.AddEvidence(ctx, linkHash, e); err != nil {
+			return err
+		}
+
+		m.stats.Evidences++
+	}
+
+	return nil
+}
+
+// copyValues copies the key-value pairs of the source store that the target
+// store doesn't have, except the checkpoint of a previous migration.
+// Key-value pairs can only be listed if the source store implements
+// store.KeyValueIterator.
+func (m *Migrator) copyValues(ctx context.Context) error {
+	source, ok := m.source.(store.KeyValueIterator)
+	if !ok {
+		if _, ok := m.source.(store.KeyValueStore); ok {
+			monitoring.LogEntry().Warn("Source store can't list its key-value pairs: they won't be copied")
+		}
+
+		return nil
+	}
+
+	target, _ := m.target.(store.KeyValueStore)
+
+	return source.IterateValues(ctx, nil, nil, func(key, value []byte) error {
+		if string(key) == m.config.CheckpointKey {
+			return nil
+		}
+
+		if target == nil {
+			return types.NewError(errorcode.FailedPrecondition, store.Component, "target store can't save the key-value pairs of the source store")
+		}
+
+		existing, err := target.GetValue(ctx, key)
+		if err != nil {
+			return err
+		}
+		if existing != nil && bytes.Equal(existing, value) {
+			return nil
+		}
+
+		if err := target.SetValue(ctx, key, value); err != nil {
+			return err
+		}
+
+		m.stats.Values++
+		return nil
+	})
+}
+
+// hasEvidence returns true if the segment already has an evidence from the
+// same backend and provider.
+func hasEvidence(segment *chainscript.Segment, e *chainscript.Evidence) bool {
+	for _, existing := range segment.Meta.Evidences {
+		if existing.Backend == e.Backend && existing.Provider == e.Provider {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *Migrator) loadCheckpoint(ctx context.Context) (*Checkpoint, error) {
+	checkpoint := &Checkpoint{}
+
+	kv, ok := m.target.(store.KeyValueStore)
+	if !ok {
+		return checkpoint, nil
+	}
+
+	value, err := kv.GetValue(ctx, []byte(m.config.CheckpointKey))
+	if err != nil || value == nil {
+		return checkpoint, err
+	}
+
+	if err := json.Unmarshal(value, checkpoint); err != nil {
+		return nil, types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not load migration checkpoint")
+	}
+
+	return checkpoint, nil
+}
+
+func (m *Migrator) saveCheckpoint(ctx context.Context, checkpoint *Checkpoint) error {
+	kv, ok := m.target.(store.KeyValueStore)
+	if !ok {
+		return nil
+	}
+
+	value, err := json.Marshal(checkpoint)
+	if err != nil {
+		return types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not save migration checkpoint")
+	}
+
+	return kv.SetValue(ctx, []byte(m.config.CheckpointKey), value)
+}
+
+func (m *Migrator) deleteCheckpoint(ctx context.Context) error {
+	kv, ok := m.target.(store.KeyValueStore)
+	if !ok {
+		return nil
+	}
+
+	_, err := kv.DeleteValue(ctx, []byte(m.config.CheckpointKey))
+	return err
+}
@@ -0,0 +1,317 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storemigration_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-chainscript/chainscripttest"
+	"github.com/stratumn/go-core/dummystore"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/store/storemigration"
+	"github.com/stratumn/go-core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// targetStore checks that links are created after their parent and can
+// fail after a given number of links.
+type targetStore struct {
+	*dummystore.DummyStore
+
+	t           *testing.T
+	failAfter   int
+	created     int
+	checkpoints []string
+}
+
+func newTargetStore(t *testing.T) *targetStore {
+	return &targetStore{
+		DummyStore: dummystore.New(&dummystore.Config{}),
+		t:          t,
+		failAfter:  -1,
+	}
+}
+
+func (s *targetStore) CreateLink(ctx context.Context, link *chainscript.Link) (chainscript.LinkHash, error) {
+	if s.created == s.failAfter {
+		return nil, errors.New("target unavailable")
+	}
+
+	if prev := link.PrevLinkHash(); len(prev) > 0 {
+		parent, err := s.GetSegment(ctx, prev)
+		require.NoError(s.t, err)
+		assert.NotNil(s.t, parent, "parent should be created first")
+	}
+
+	s.created++
+	return s.DummyStore.CreateLink(ctx, link)
+}
+
+func (s *targetStore) SetValue(ctx context.Context, key, value []byte) error {
+	if string(key) == storemigration.DefaultCheckpointKey {
+		s.checkpoints = append(s.checkpoints, string(value))
+	}
+
+	return s.DummyStore.SetValue(ctx, key, value)
+}
+
+// populate creates a map where children have a higher priority than their
+// parents, so they are returned first by the source store.
+func populate(t *testing.T, a *dummystore.DummyStore) []*chainscript.Link {
+	ctx := context.Background()
+
+	root := chainscripttest.NewLinkBuilder(t).WithRandomData().WithPriority(1).Build()
+	child := chainscripttest.NewLinkBuilder(t).WithRandomData().Branch(t, root).WithPriority(2).Build()
+	grandChild := chainscripttest.NewLinkBuilder(t).WithRandomData().Branch(t, child).WithPriority(3).Build()
+
+	links := []*chainscript.Link{root, child, grandChild}
+	for _, l := range links {
+		_, err := a.CreateLink(ctx, l)
+		require.NoError(t, err)
+	}
+
+	rootHash, _ := root.Hash()
+	require.NoError(t, a.AddEvidence(ctx, rootHash, chainscripttest.RandomEvidence(t)))
+
+	return links
+}
+
+func TestMigrator_Copy(t *testing.T) {
+	ctx := context.Background()
+
+	source := dummystore.New(&dummystore.Config{})
+	links := populate(t, source)
+	target := newTargetStore(t)
+
+	m := storemigration.New(source, target, &storemigration.Config{})
+	require.NoError(t, m.Copy(ctx))
+	assert.Equal(t, storemigration.Stats{Links: 3, Evidences: 1}, m.Stats())
+
+	for _, l := range links {
+		lh, _ := l.Hash()
+		expected, _ := source.GetSegment(ctx, lh)
+		got, err := target.GetSegment(ctx, lh)
+		require.NoError(t, err)
+		require.NotNil(t, got)
+		chainscripttest.SegmentsEqual(t, expected, got)
+		assert.Len(t, got.Meta.Evidences, len(expected.Meta.Evidences))
+	}
+
+	t.Run("copying again is a no-op", func(t *testing.T) {
+		m := storemigration.New(source, target, &storemigration.Config{})
+		require.NoError(t, m.Copy(ctx))
+		assert.Equal(t, storemigration.Stats{Skipped: 3}, m.Stats())
+	})
+
+	t.Run("missing evidences are added", func(t *testing.T) {
+		lh, _ := links[2].Hash()
+		require.NoError(t, source.AddEvidence(ctx, lh, chainscripttest.RandomEvidence(t)))
+
+		m := storemigration.New(source, target, &storemigration.Config{})
+		require.NoError(t, m.Copy(ctx))
+		assert.Equal(t, storemigration.Stats{Skipped: 3, Evidences: 1}, m.Stats())
+	})
+}
+
+func TestMigrator_Copy_values(t *testing.T) {
+	ctx := context.Background()
+
+	source := dummystore.New(&dummystore.Config{})
+	populate(t, source)
+	require.NoError(t, source.SetValue(ctx, []byte("k1"), []byte("v1")))
+	require.NoError(t, source.SetValue(ctx, []byte("k2"), []byte("v2")))
+
+	t.Run("values are copied", func(t *testing.T) {
+		target := newTargetStore(t)
+
+		m := storemigration.New(source, target, &storemigration.Config{})
+		require.NoError(t, m.Copy(ctx))
+		assert.Equal(t, 2, m.Stats().Values)
+
+		value, err := target.GetValue(ctx, []byte("k2"))
+		require.NoError(t, err)
+		assert.Equal(t, []byte("v2"), value)
+
+		m = storemigration.New(source, target, &storemigration.Config{})
+		require.NoError(t, m.Copy(ctx))
+		assert.Equal(t, 0, m.Stats().Values)
+	})
+
+	t.Run("target without key-value store", func(t *testing.T) {
+		target := struct{ store.Adapter }{newTargetStore(t)}
+
+		m := storemigration.New(source, target, &storemigration.Config{})
+		err := m.Copy(ctx)
+		require.Error(t, err)
+		assert.Equal(t, errorcode.FailedPrecondition, err.(*types.Error).Code)
+	})
+}
+
+func TestMigrator_Copy_resume(t *testing.T) {
+	ctx := context.Background()
+
+	source := dummystore.New(&dummystore.Config{})
+	for i := 0; i < 250; i++ {
+		_, err := source.CreateLink(ctx, chainscripttest.RandomLink(t))
+		require.NoError(t, err)
+	}
+
+	target := newTargetStore(t)
+	target.failAfter = 220
+
+	config := &storemigration.Config{CheckpointKey: "checkpoint"}
+	m := storemigration.New(source, target, config)
+	require.Error(t, m.Copy(ctx))
+
+	checkpoint, err := target.GetValue(ctx, []byte("checkpoint"))
+	require.NoError(t, err)
+	assert.NotNil(t, checkpoint)
+
+	// The copy resumes after the first page.
+	target.failAfter = -1
+	m = storemigration.New(source, target, config)
+	require.NoError(t, m.Copy(ctx))
+	assert.Equal(t, storemigration.Stats{Links: 30, Skipped: 20}, m.Stats())
+
+	checkpoint, err = target.GetValue(ctx, []byte("checkpoint"))
+	require.NoError(t, err)
+	assert.Nil(t, checkpoint)
+
+	diff, err := m.Diff(ctx)
+	require.NoError(t, err)
+	assert.True(t, diff.Consistent())
+	assert.Equal(t, 250, diff.TargetLinks)
+}
+
+func TestMigrator_Copy_resumeChain(t *testing.T) {
+	ctx := context.Background()
+
+	// Children have a higher priority than their parent, so parents are read
+	// first and the copy can be checkpointed after every page.
+	source := dummystore.New(&dummystore.Config{})
+	var parent *chainscript.Link
+	for i := 0; i < 450; i++ {
+		b := chainscripttest.NewLinkBuilder(t).WithRandomData().WithPriority(float64(i + 1))
+		if parent != nil {
+			b = b.Branch(t, parent)
+		}
+
+		link := b.Build()
+		_, err := source.CreateLink(ctx, link)
+		require.NoError(t, err)
+		parent = link
+	}
+
+	target := newTargetStore(t)
+	target.failAfter = 300
+
+	m := storemigration.New(source, target, &storemigration.Config{})
+	require.Error(t, m.Copy(ctx))
+	require.Len(t, target.checkpoints, 1, "checkpoint should be saved after the first page")
+
+	target.failAfter = -1
+	m = storemigration.New(source, target, &storemigration.Config{})
+	require.NoError(t, m.Copy(ctx))
+	assert.Equal(t, storemigration.Stats{Links: 150, Skipped: 100}, m.Stats())
+	assert.Len(t, target.checkpoints, 2)
+
+	diff, err := m.Diff(ctx)
+	require.NoError(t, err)
+	assert.True(t, diff.Consistent())
+}
+
+func TestMigrator_Run_tail(t *testing.T) {
+	source := dummystore.New(&dummystore.Config{})
+	links := populate(t, source)
+	target := newTargetStore(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m := storemigration.New(source, target, &storemigration.Config{Tail: true})
+
+	done := make(chan error)
+	go func() { done <- m.Run(ctx) }()
+
+	// Wait for the copy to start tailing.
+	lastHash, _ := links[2].Hash()
+	waitForSegment(t, target, lastHash, 0)
+
+	link := chainscripttest.RandomLink(t)
+	linkHash, err := source.CreateLink(context.Background(), link)
+	require.NoError(t, err)
+
+	evidence := chainscripttest.RandomEvidence(t)
+	require.NoError(t, source.AddEvidence(context.Background(), linkHash, evidence))
+
+	waitForSegment(t, target, linkHash, 1)
+
+	cancel()
+	require.NoError(t, <-done)
+
+	diff, err := m.Diff(context.Background())
+	require.NoError(t, err)
+	assert.True(t, diff.Consistent())
+	assert.Equal(t, 4, diff.SourceLinks)
+}
+
+func TestMigrator_Diff(t *testing.T) {
+	ctx := context.Background()
+
+	source := dummystore.New(&dummystore.Config{})
+	links := populate(t, source)
+	target := dummystore.New(&dummystore.Config{})
+
+	rootHash, _ := links[0].Hash()
+	_, err := target.CreateLink(ctx, links[0])
+	require.NoError(t, err)
+
+	extra := chainscripttest.RandomLink(t)
+	extraHash, err := target.CreateLink(ctx, extra)
+	require.NoError(t, err)
+
+	m := storemigration.New(source, target, &storemigration.Config{})
+	diff, err := m.Diff(ctx)
+	require.NoError(t, err)
+
+	childHash, _ := links[1].Hash()
+	grandChildHash, _ := links[2].Hash()
+
+	assert.False(t, diff.Consistent())
+	assert.Equal(t, 3, diff.SourceLinks)
+	assert.Equal(t, 2, diff.TargetLinks)
+	assert.ElementsMatch(t, []string{childHash.String(), grandChildHash.String()}, diff.MissingLinks)
+	assert.Equal(t, []string{rootHash.String()}, diff.MissingEvidences)
+	assert.Equal(t, []string{extraHash.String()}, diff.ExtraLinks)
+}
+
+func waitForSegment(t *testing.T, a *targetStore, linkHash chainscript.LinkHash, evidences int) {
+	for i := 0; ; i++ {
+		s, err := a.GetSegment(context.Background(), linkHash)
+		require.NoError(t, err)
+		if s != nil && len(s.Meta.Evidences) == evidences {
+			return
+		}
+
+		require.True(t, i < 100, "segment should be copied to the target")
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storemigration
+
+import (
+	"context"
+	"sync"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+)
+
+// eventQueue buffers the source events while they can't be applied.
+// Stores block when their event channels are full, so events must be
+// consumed even during the copy.
+type eventQueue struct {
+	mu     sync.Mutex
+	events []*store.Event
+	ready  chan struct{}
+}
+
+// listen starts recording the events of the source store.
+// Stores can't remove event channels, so the events keep being consumed
+// until the program stops.
+func (m *Migrator) listen() *eventQueue {
+	q := &eventQueue{ready: make(chan struct{}, 1)}
+
+	c := make(chan *store.Event, m.config.EventsChanSize)
+	m.source.AddStoreEventChannel(c)
+
+	go func() {
+		for e := range c {
+			q.push(e)
+		}
+	}()
+
+	return q
+}
+
+func (q *eventQueue) push(e *store.Event) {
+	q.mu.Lock()
+	q.events = append(q.events, e)
+	q.mu.Unlock()
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (q *eventQueue) pop() []*store.Event {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	events := q.events
+	q.events = nil
+
+	return events
+}
+
+// tail applies the recorded events until the context is cancelled.
+func (m *Migrator) tail(ctx context.Context, q *eventQueue) error {
+	for {
+		for _, e := range q.pop() {
+			if err := m.applyEvent(ctx, e); err != nil {
+				return err
+			}
+
+			m.stats.Events++
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-q.ready:
+		}
+	}
+}
+
+func (m *Migrator) applyEvent(ctx context.Context, e *store.Event) error {
+	switch e.EventType {
+	case store.SavedLinks:
+		for _, link := range e.Data.([]*chainscript.Link) {
+			segment, err := link.Segmentify()
+			if err != nil {
+				return types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not segmentify")
+			}
+
+			if err := m.copySegment(ctx, segment); err != nil {
+				return err
+			}
+		}
+	case store.SavedEvidences:
+		for lh := range e.Data.(map[string]*chainscript.Evidence) {
+			linkHash, err := chainscript.NewLinkHashFromString(lh)
+			if err != nil {
+				return types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not parse link hash")
+			}
+
+			// Copying the whole segment also works if the evidence event is
+			// received before the link event.
+			segment, err := m.source.GetSegment(ctx, linkHash)
+			if err != nil {
+				return err
+			}
+
+			if segment == nil {
+				continue
+			}
+
+			if err := m.copySegment(ctx, segment); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
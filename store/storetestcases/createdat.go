@@ -0,0 +1,120 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storetestcases
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stratumn/go-chainscript/chainscripttest"
+	"github.com/stratumn/go-core/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFindSegmentsCreatedAt tests that segments can be filtered by the time
+// their link was stored.
+func (f Factory) TestFindSegmentsCreatedAt(t *testing.T) {
+	a := f.initAdapter(t)
+	defer f.freeAdapter(a)
+
+	process := chainscripttest.RandomString(8)
+	batchSize := 3
+
+	createBatch := func() map[string]struct{} {
+		batch := make(map[string]struct{}, batchSize)
+		for i := 0; i < batchSize; i++ {
+			l := chainscripttest.NewLinkBuilder(t).
+				WithRandomData().
+				WithProcess(process).
+				Build()
+			lh, err := a.CreateLink(context.Background(), l)
+			require.NoError(t, err)
+			batch[lh.String()] = struct{}{}
+		}
+		return batch
+	}
+
+	// Leave some time between batches to absorb clock precision.
+	pause := func() time.Time {
+		time.Sleep(50 * time.Millisecond)
+		now := time.Now()
+		time.Sleep(50 * time.Millisecond)
+		return now
+	}
+
+	first := createBatch()
+	t1 := pause()
+	second := createBatch()
+	t2 := pause()
+	third := createBatch()
+
+	union := func(batches ...map[string]struct{}) map[string]struct{} {
+		u := make(map[string]struct{})
+		for _, b := range batches {
+			for lh := range b {
+				u[lh] = struct{}{}
+			}
+		}
+		return u
+	}
+
+	tests := []struct {
+		name   string
+		after  *time.Time
+		before *time.Time
+		want   map[string]struct{}
+	}{
+		{"Created after should exclude older segments", &t1, nil, union(second, third)},
+		{"Created before should exclude newer segments", nil, &t1, first},
+		{"Created between should return a time range", &t1, &t2, second},
+		{"Empty time range should return nothing", &t2, &t1, nil},
+	}
+
+	for _, tt := range tests {
+		filter := &store.SegmentFilter{
+			Pagination:    store.Pagination{Limit: 3 * batchSize},
+			Process:       process,
+			CreatedAfter:  tt.after,
+			CreatedBefore: tt.before,
+		}
+
+		t.Run(tt.name, func(t *testing.T) {
+			segments, err := a.FindSegments(context.Background(), filter)
+			require.NoError(t, err)
+			assert.Equal(t, len(tt.want), segments.TotalCount)
+			require.Len(t, segments.Segments, len(tt.want))
+			for _, s := range segments.Segments {
+				assert.Contains(t, tt.want, s.LinkHash().String())
+			}
+		})
+
+		t.Run(tt.name+" when iterating", func(t *testing.T) {
+			it, err := store.IterateSegments(context.Background(), a, filter)
+			require.NoError(t, err)
+			defer it.Close()
+
+			count := 0
+			for it.Next() {
+				assert.Contains(t, tt.want, it.Segment().LinkHash().String())
+				count++
+			}
+
+			require.NoError(t, it.Err())
+			assert.Equal(t, len(tt.want), count)
+		})
+	}
+}
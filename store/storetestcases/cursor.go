@@ -0,0 +1,197 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storetestcases
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-chainscript/chainscripttest"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCursorPagination tests that cursors allow walking through all the
+// results of a query while links are being added.
+func (f Factory) TestCursorPagination(t *testing.T) {
+	a := f.initAdapter(t)
+	defer f.freeAdapter(a)
+
+	process := chainscripttest.RandomString(8)
+	testPageSize := 4
+	linksCount := 25
+
+	existing := make(map[string]struct{}, linksCount)
+	for i := 0; i < linksCount; i++ {
+		l := chainscripttest.NewLinkBuilder(t).
+			WithRandomData().
+			WithProcess(process).
+			WithPriority(float64(i % 5)).
+			Build()
+		lh, err := a.CreateLink(context.Background(), l)
+		require.NoError(t, err)
+		existing[lh.String()] = struct{}{}
+	}
+
+	walk := func(t *testing.T, reverse bool) {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		// Keep inserting links while we're walking through the pages.
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < linksCount && ctx.Err() == nil; i++ {
+				l := chainscripttest.NewLinkBuilder(t).
+					WithRandomData().
+					WithProcess(process).
+					WithPriority(float64(i % 7)).
+					Build()
+				if _, err := a.CreateLink(ctx, l); err != nil {
+					return
+				}
+			}
+		}()
+		defer func() {
+			cancel()
+			<-done
+		}()
+
+		filter := &store.SegmentFilter{
+			Pagination: store.Pagination{Limit: testPageSize},
+			Process:    process,
+			Reverse:    reverse,
+		}
+
+		seen := make(map[string]struct{})
+		var last *chainscript.Segment
+		for pages := 0; ; pages++ {
+			segments, err := a.FindSegments(context.Background(), filter)
+			require.NoError(t, err)
+
+			for _, s := range segments.Segments {
+				lh := s.LinkHash().String()
+				_, ok := seen[lh]
+				require.False(t, ok, "segment %s returned twice", lh)
+				seen[lh] = struct{}{}
+
+				if last != nil {
+					assert.True(t, store.NewSegmentCursor(last).After(s, reverse), "segments must be ordered")
+				}
+				last = s
+			}
+
+			if segments.NextCursor == "" {
+				break
+			}
+
+			filter.Cursor = segments.NextCursor
+
+			// Stop adding links after a few pages to make sure the walk
+			// terminates.
+			if pages == 3 {
+				cancel()
+				<-done
+			}
+		}
+
+		for lh := range existing {
+			_, ok := seen[lh]
+			assert.True(t, ok, "segment %s was not returned", lh)
+		}
+	}
+
+	t.Run("Walking through pages should return all segments", func(t *testing.T) {
+		walk(t, false)
+	})
+
+	t.Run("Walking through reversed pages should return all segments", func(t *testing.T) {
+		walk(t, true)
+	})
+
+	t.Run("Offset is ignored when a cursor is set", func(t *testing.T) {
+		ctx := context.Background()
+		filter := &store.SegmentFilter{
+			Pagination: store.Pagination{Limit: testPageSize},
+			Process:    process,
+		}
+		first, err := a.FindSegments(ctx, filter)
+		require.NoError(t, err)
+		require.NotEmpty(t, first.NextCursor)
+
+		filter.Cursor = first.NextCursor
+		second, err := a.FindSegments(ctx, filter)
+		require.NoError(t, err)
+
+		filter.Offset = 2
+		withOffset, err := a.FindSegments(ctx, filter)
+		require.NoError(t, err)
+
+		assert.Equal(t, second.Segments, withOffset.Segments)
+	})
+
+	t.Run("Invalid cursor should be rejected", func(t *testing.T) {
+		_, err := a.FindSegments(context.Background(), &store.SegmentFilter{
+			Pagination: store.Pagination{Limit: testPageSize, Cursor: "not-a-cursor"},
+		})
+		require.Error(t, err)
+		testutil.AssertWrappedErrorEqual(t, err, store.ErrInvalidCursor)
+	})
+}
+
+// TestMapIDsCursorPagination tests that cursors allow walking through all
+// the map IDs.
+func (f Factory) TestMapIDsCursorPagination(t *testing.T) {
+	a := f.initAdapter(t)
+	defer f.freeAdapter(a)
+
+	mapsCount := 11
+	expected := make([]string, 0, mapsCount)
+	for i := 0; i < mapsCount; i++ {
+		mapID := fmt.Sprintf("map-%s", chainscripttest.RandomString(8))
+		l := chainscripttest.NewLinkBuilder(t).
+			WithRandomData().
+			WithMapID(mapID).
+			Build()
+		_, err := a.CreateLink(context.Background(), l)
+		require.NoError(t, err)
+		expected = append(expected, mapID)
+	}
+	sort.Strings(expected)
+
+	filter := &store.MapFilter{
+		Pagination: store.Pagination{Limit: 3, Offset: 1},
+		Prefix:     "map-",
+	}
+
+	var got []string
+	for {
+		mapIDs, err := a.GetMapIDs(context.Background(), filter)
+		require.NoError(t, err)
+		if len(mapIDs) == 0 {
+			break
+		}
+
+		got = append(got, mapIDs...)
+		filter.Cursor = mapIDs[len(mapIDs)-1]
+	}
+
+	// The offset only applies to the first page.
+	assert.Equal(t, expected[1:], got)
+}
@@ -0,0 +1,154 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storetestcases
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stratumn/go-chainscript/chainscripttest"
+	"github.com/stratumn/go-core/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFindSegmentsData tests that segments can be filtered on their link
+// data.
+func (f Factory) TestFindSegmentsData(t *testing.T) {
+	a := f.initAdapter(t)
+	defer f.freeAdapter(a)
+
+	process := chainscripttest.RandomString(8)
+	orders := map[string]interface{}{
+		"new": map[string]interface{}{
+			"status": "new",
+			"amount": 20,
+			"items":  []interface{}{map[string]interface{}{"sku": "a1"}},
+		},
+		"paid": map[string]interface{}{
+			"status":  "paid",
+			"amount":  120,
+			"express": true,
+			"items":   []interface{}{map[string]interface{}{"sku": "a1"}, map[string]interface{}{"sku": "b2"}},
+		},
+		"shipped": map[string]interface{}{
+			"status":  "shipped",
+			"amount":  "unknown",
+			"express": false,
+			"carrier": nil,
+		},
+		"scalar": "paid",
+	}
+
+	linkHashes := make(map[string]string, len(orders))
+	for name, data := range orders {
+		l := chainscripttest.NewLinkBuilder(t).
+			WithProcess(process).
+			WithData(t, data).
+			Build()
+		lh, err := a.CreateLink(context.Background(), l)
+		require.NoError(t, err)
+		linkHashes[lh.String()] = name
+	}
+
+	// Links of another process that shouldn't be returned.
+	createRandomLink(t, a, nil)
+
+	gte, lt := 20.0, 120.0
+
+	tests := []struct {
+		name      string
+		predicate store.DataPredicate
+		want      []string
+	}{{
+		"Equal string",
+		store.DataPredicate{Path: "status", Op: store.DataEqual, Value: "paid"},
+		[]string{"paid"},
+	}, {
+		"Equal number",
+		store.DataPredicate{Path: "amount", Op: store.DataEqual, Value: 120},
+		[]string{"paid"},
+	}, {
+		"Equal boolean",
+		store.DataPredicate{Path: "express", Op: store.DataEqual, Value: false},
+		[]string{"shipped"},
+	}, {
+		"Equal array element",
+		store.DataPredicate{Path: "items.1.sku", Op: store.DataEqual, Value: "b2"},
+		[]string{"paid"},
+	}, {
+		"In",
+		store.DataPredicate{Path: "status", Op: store.DataIn, Values: []interface{}{"new", "shipped", 120}},
+		[]string{"new", "shipped"},
+	}, {
+		"Range",
+		store.DataPredicate{Path: "amount", Op: store.DataRange, Gte: &gte, Lt: &lt},
+		[]string{"new"},
+	}, {
+		"Range ignores other types",
+		store.DataPredicate{Path: "amount", Op: store.DataRange, Gte: &gte},
+		[]string{"new", "paid"},
+	}, {
+		"Exists",
+		store.DataPredicate{Path: "express", Op: store.DataExists},
+		[]string{"paid", "shipped"},
+	}, {
+		"Exists with null value",
+		store.DataPredicate{Path: "carrier", Op: store.DataExists},
+		[]string{"shipped"},
+	}, {
+		"Exists object",
+		store.DataPredicate{Path: "items.0", Op: store.DataExists},
+		[]string{"new", "paid"},
+	}, {
+		"Missing path",
+		store.DataPredicate{Path: "customer.name", Op: store.DataExists},
+		nil,
+	}}
+
+	for _, tt := range tests {
+		filter := &store.SegmentFilter{
+			Pagination: store.Pagination{Limit: store.DefaultLimit},
+			Process:    process,
+			Data:       []store.DataPredicate{tt.predicate},
+		}
+
+		t.Run(tt.name, func(t *testing.T) {
+			segments, err := a.FindSegments(context.Background(), filter)
+			require.NoError(t, err)
+			assert.Equal(t, len(tt.want), segments.TotalCount)
+
+			var got []string
+			for _, s := range segments.Segments {
+				got = append(got, linkHashes[s.LinkHash().String()])
+			}
+			assert.ElementsMatch(t, tt.want, got)
+		})
+	}
+
+	t.Run("Predicates should all match", func(t *testing.T) {
+		segments, err := a.FindSegments(context.Background(), &store.SegmentFilter{
+			Pagination: store.Pagination{Limit: store.DefaultLimit},
+			Process:    process,
+			Data: []store.DataPredicate{
+				{Path: "items.0.sku", Op: store.DataEqual, Value: "a1"},
+				{Path: "express", Op: store.DataExists},
+			},
+		})
+		require.NoError(t, err)
+		require.Len(t, segments.Segments, 1)
+		assert.Equal(t, "paid", linkHashes[segments.Segments[0].LinkHash().String()])
+	})
+}
@@ -241,6 +241,63 @@ func (f Factory) TestFindSegments(t *testing.T) {
 		verifyResultsCount(t, err, slice, 1)
 	})
 
+	t.Run("Supports filtering on any of several tags", func(t *testing.T) {
+		ctx := context.Background()
+		slice, err := a.FindSegments(ctx, &store.SegmentFilter{
+			Pagination: store.Pagination{
+				Limit: segmentsTotalCount,
+			},
+			TagsAny: []string{"tag1", "tag2"},
+		})
+		verifyResultsCount(t, err, slice, 4)
+	})
+
+	t.Run("Supports filtering on all tags and any tags at the same time", func(t *testing.T) {
+		ctx := context.Background()
+		slice, err := a.FindSegments(ctx, &store.SegmentFilter{
+			Pagination: store.Pagination{
+				Limit: segmentsTotalCount,
+			},
+			Tags:    []string{"tag2"},
+			TagsAny: []string{"tag1", "tag42"},
+		})
+		verifyResultsCount(t, err, slice, 1)
+	})
+
+	t.Run("Supports filtering on any of several steps", func(t *testing.T) {
+		ctx := context.Background()
+		slice, err := a.FindSegments(ctx, &store.SegmentFilter{
+			Pagination: store.Pagination{
+				Limit: segmentsTotalCount,
+			},
+			Steps: []string{"propose", "blablabla"},
+		})
+		verifyResultsCount(t, err, slice, 2)
+	})
+
+	t.Run("Supports filtering on any of several processes", func(t *testing.T) {
+		ctx := context.Background()
+		slice, err := a.FindSegments(ctx, &store.SegmentFilter{
+			Pagination: store.Pagination{
+				Limit: segmentsTotalCount,
+			},
+			Processes: []string{"Foo", "blablabla"},
+		})
+		verifyResultsCount(t, err, slice, 2)
+	})
+
+	t.Run("Supports filtering on several steps and processes at the same time", func(t *testing.T) {
+		ctx := context.Background()
+		slice, err := a.FindSegments(ctx, &store.SegmentFilter{
+			Pagination: store.Pagination{
+				Limit: segmentsTotalCount,
+			},
+			Processes: []string{"Foo", "blablabla"},
+			Steps:     []string{"propose", "blablabla"},
+		})
+		verifyResultsCount(t, err, slice, 1)
+	})
+
 	t.Run("Supports filtering on map ID", func(t *testing.T) {
 		ctx := context.Background()
 		slice, err := a.FindSegments(ctx, &store.SegmentFilter{
@@ -0,0 +1,183 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storetestcases
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-chainscript/chainscripttest"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGraph tests traversing the graph of a map.
+// If the adapter implements store.GraphReader, both its native
+// implementation and the generic one are tested.
+func (f Factory) TestGraph(t *testing.T) {
+	a := f.initAdapter(t)
+	defer f.freeAdapter(a)
+
+	process := chainscripttest.RandomString(8)
+	mapID := chainscripttest.RandomString(8)
+
+	create := func(build func(*chainscripttest.LinkBuilder) *chainscripttest.LinkBuilder) *chainscript.Link {
+		l := build(chainscripttest.NewLinkBuilder(t).
+			WithRandomData().
+			WithProcess(process).
+			WithMapID(mapID)).
+			Build()
+		_, err := a.CreateLink(context.Background(), l)
+		require.NoError(t, err)
+		return l
+	}
+
+	// Links of another map shouldn't be part of the graph, even when they
+	// are referenced.
+	other := create(func(b *chainscripttest.LinkBuilder) *chainscripttest.LinkBuilder {
+		return b.WithMapID(chainscripttest.RandomString(8))
+	})
+
+	//   A
+	//  / \
+	// B   C
+	//  \ / \
+	//   D   F
+	//   |
+	//   E
+	// D has B as parent and references C.
+	linkA := create(func(b *chainscripttest.LinkBuilder) *chainscripttest.LinkBuilder { return b.WithoutParent() })
+	linkB := create(func(b *chainscripttest.LinkBuilder) *chainscripttest.LinkBuilder { return b.WithParent(t, linkA) })
+	linkC := create(func(b *chainscripttest.LinkBuilder) *chainscripttest.LinkBuilder { return b.WithParent(t, linkA) })
+	linkD := create(func(b *chainscripttest.LinkBuilder) *chainscripttest.LinkBuilder {
+		return b.WithParent(t, linkB).WithRef(t, linkC).WithRef(t, other)
+	})
+	linkE := create(func(b *chainscripttest.LinkBuilder) *chainscripttest.LinkBuilder { return b.WithParent(t, linkD) })
+	linkF := create(func(b *chainscripttest.LinkBuilder) *chainscripttest.LinkBuilder { return b.WithParent(t, linkC) })
+
+	names := make(map[string]string)
+	for name, l := range map[string]*chainscript.Link{"A": linkA, "B": linkB, "C": linkC, "D": linkD, "E": linkE, "F": linkF, "other": other} {
+		lh, err := l.Hash()
+		require.NoError(t, err)
+		names[lh.String()] = name
+	}
+
+	hash := func(l *chainscript.Link) chainscript.LinkHash {
+		lh, _ := l.Hash()
+		return lh
+	}
+
+	readers := map[string]store.GraphReader{"generic": genericGraphReader{a}}
+	if graphReader, ok := a.(store.GraphReader); ok {
+		readers["native"] = graphReader
+	}
+
+	verify := func(t *testing.T, want []string, segments types.SegmentSlice, err error) {
+		require.NoError(t, err)
+
+		got := []string{}
+		for _, s := range segments {
+			got = append(got, names[s.LinkHash().String()])
+		}
+		assert.ElementsMatch(t, want, got)
+
+		if len(segments) > 0 {
+			sorted := append(types.SegmentSlice{}, segments...)
+			sorted.Sort(false)
+			assert.Equal(t, sorted, segments, "segments should be sorted")
+		}
+	}
+
+	for name, r := range readers {
+		t.Run(fmt.Sprintf("Ancestors (%s)", name), func(t *testing.T) {
+			ctx := context.Background()
+
+			segments, err := r.GetAncestors(ctx, hash(linkE), 0)
+			verify(t, []string{"A", "B", "C", "D"}, segments, err)
+
+			segments, err = r.GetAncestors(ctx, hash(linkE), 1)
+			verify(t, []string{"D"}, segments, err)
+
+			segments, err = r.GetAncestors(ctx, hash(linkE), 2)
+			verify(t, []string{"B", "C", "D"}, segments, err)
+
+			segments, err = r.GetAncestors(ctx, hash(linkA), 0)
+			verify(t, []string{}, segments, err)
+		})
+
+		t.Run(fmt.Sprintf("Descendants (%s)", name), func(t *testing.T) {
+			ctx := context.Background()
+
+			segments, err := r.GetDescendants(ctx, hash(linkA), 0)
+			verify(t, []string{"B", "C", "D", "E", "F"}, segments, err)
+
+			segments, err = r.GetDescendants(ctx, hash(linkA), 1)
+			verify(t, []string{"B", "C"}, segments, err)
+
+			segments, err = r.GetDescendants(ctx, hash(linkC), 0)
+			verify(t, []string{"D", "E", "F"}, segments, err)
+
+			segments, err = r.GetDescendants(ctx, hash(other), 0)
+			verify(t, []string{}, segments, err)
+		})
+
+		t.Run(fmt.Sprintf("Map heads (%s)", name), func(t *testing.T) {
+			ctx := context.Background()
+
+			segments, err := r.GetMapHeads(ctx, process, mapID)
+			verify(t, []string{"E", "F"}, segments, err)
+
+			segments, err = r.GetMapHeads(ctx, chainscripttest.RandomString(8), mapID)
+			verify(t, []string{}, segments, err)
+		})
+
+		t.Run(fmt.Sprintf("Unknown link (%s)", name), func(t *testing.T) {
+			ctx := context.Background()
+
+			segments, err := r.GetAncestors(ctx, chainscripttest.RandomHash(), 0)
+			verify(t, []string{}, segments, err)
+
+			segments, err = r.GetDescendants(ctx, chainscripttest.RandomHash(), 0)
+			verify(t, []string{}, segments, err)
+		})
+	}
+}
+
+// genericGraphReader uses the generic graph implementation even if the
+// adapter has a native one.
+type genericGraphReader struct {
+	a store.Adapter
+}
+
+// segmentReader hides the optional interfaces of the adapter.
+type segmentReader struct {
+	store.SegmentReader
+}
+
+func (r genericGraphReader) GetAncestors(ctx context.Context, linkHash chainscript.LinkHash, depth int) (types.SegmentSlice, error) {
+	return store.GetAncestors(ctx, segmentReader{r.a}, linkHash, depth)
+}
+
+func (r genericGraphReader) GetDescendants(ctx context.Context, linkHash chainscript.LinkHash, depth int) (types.SegmentSlice, error) {
+	return store.GetDescendants(ctx, segmentReader{r.a}, linkHash, depth)
+}
+
+func (r genericGraphReader) GetMapHeads(ctx context.Context, process, mapID string) (types.SegmentSlice, error) {
+	return store.GetMapHeads(ctx, segmentReader{r.a}, process, mapID)
+}
@@ -0,0 +1,154 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storetestcases
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-chainscript/chainscripttest"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSegmentIterator tests that iterating over segments returns the same
+// results as finding segments.
+// If the adapter implements store.SegmentIterable, both its native
+// implementation and the generic one are tested.
+func (f Factory) TestSegmentIterator(t *testing.T) {
+	a := f.initAdapter(t)
+	defer f.freeAdapter(a)
+
+	process := chainscripttest.RandomString(8)
+	linksCount := 30
+
+	for i := 0; i < linksCount; i++ {
+		l := chainscripttest.NewLinkBuilder(t).
+			WithRandomData().
+			WithProcess(process).
+			WithPriority(float64(i % 4)).
+			Build()
+		lh, err := a.CreateLink(context.Background(), l)
+		require.NoError(t, err)
+
+		// Segments with several evidences should be returned only once.
+		for j := 0; j < i%3; j++ {
+			e, _ := chainscript.NewEvidence("1.0.0", fmt.Sprintf("backend%d", j), "1", []byte{byte(j)})
+			require.NoError(t, a.AddEvidence(context.Background(), lh, e))
+		}
+	}
+
+	// Links of another process that shouldn't be returned.
+	for i := 0; i < 5; i++ {
+		createRandomLink(t, a, nil)
+	}
+
+	iterators := map[string]func(*store.SegmentFilter) (store.SegmentIterator, error){
+		"generic": func(filter *store.SegmentFilter) (store.SegmentIterator, error) {
+			return store.NewPaginatedSegmentIterator(context.Background(), a, filter), nil
+		},
+	}
+	if iterable, ok := a.(store.SegmentIterable); ok {
+		iterators["native"] = func(filter *store.SegmentFilter) (store.SegmentIterator, error) {
+			return iterable.IterateSegments(context.Background(), filter)
+		}
+	}
+
+	iterate := func(t *testing.T, newIterator func(*store.SegmentFilter) (store.SegmentIterator, error), filter *store.SegmentFilter) []*chainscript.Segment {
+		it, err := newIterator(filter)
+		require.NoError(t, err)
+		defer func() { assert.NoError(t, it.Close()) }()
+
+		var segments []*chainscript.Segment
+		for it.Next() {
+			segments = append(segments, it.Segment())
+		}
+
+		require.NoError(t, it.Err())
+		return segments
+	}
+
+	find := func(t *testing.T, filter *store.SegmentFilter) []*chainscript.Segment {
+		f := *filter
+		f.Pagination = store.Pagination{Limit: linksCount, Cursor: filter.Cursor}
+		segments, err := a.FindSegments(context.Background(), &f)
+		require.NoError(t, err)
+		return segments.Segments
+	}
+
+	verify := func(t *testing.T, want, got []*chainscript.Segment) {
+		require.Len(t, got, len(want))
+		for i := range want {
+			assert.Equal(t, want[i].LinkHash(), got[i].LinkHash(), "segment #%d", i)
+			assert.Len(t, got[i].Meta.Evidences, len(want[i].Meta.Evidences), "segment #%d evidences", i)
+		}
+	}
+
+	for name, newIterator := range iterators {
+		t.Run(fmt.Sprintf("Iterating (%s) should return all segments", name), func(t *testing.T) {
+			filter := &store.SegmentFilter{
+				Pagination: store.Pagination{Offset: 2, Limit: 3},
+				Process:    process,
+			}
+			got := iterate(t, newIterator, filter)
+			assert.Len(t, got, linksCount)
+			verify(t, find(t, filter), got)
+		})
+
+		t.Run(fmt.Sprintf("Iterating (%s) should support reverse ordering", name), func(t *testing.T) {
+			filter := &store.SegmentFilter{Process: process, Reverse: true}
+			verify(t, find(t, filter), iterate(t, newIterator, filter))
+		})
+
+		t.Run(fmt.Sprintf("Iterating (%s) should start after the cursor", name), func(t *testing.T) {
+			first, err := a.FindSegments(context.Background(), &store.SegmentFilter{
+				Pagination: store.Pagination{Limit: 10},
+				Process:    process,
+			})
+			require.NoError(t, err)
+			require.NotEmpty(t, first.NextCursor)
+
+			filter := &store.SegmentFilter{
+				Pagination: store.Pagination{Cursor: first.NextCursor},
+				Process:    process,
+			}
+			got := iterate(t, newIterator, filter)
+			assert.Len(t, got, linksCount-10)
+			verify(t, find(t, filter), got)
+		})
+
+		t.Run(fmt.Sprintf("Iterating (%s) should return nothing when no segment matches", name), func(t *testing.T) {
+			filter := &store.SegmentFilter{Process: chainscripttest.RandomString(12)}
+			assert.Empty(t, iterate(t, newIterator, filter))
+		})
+
+		t.Run(fmt.Sprintf("Iterating (%s) should reject invalid cursors", name), func(t *testing.T) {
+			it, err := newIterator(&store.SegmentFilter{
+				Pagination: store.Pagination{Cursor: "not-a-cursor"},
+			})
+			if err == nil {
+				defer it.Close()
+				assert.False(t, it.Next())
+				err = it.Err()
+			}
+
+			testutil.AssertWrappedErrorEqual(t, err, store.ErrInvalidCursor)
+		})
+	}
+}
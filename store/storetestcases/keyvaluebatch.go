@@ -0,0 +1,99 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storetestcases
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stratumn/go-core/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestKeyValueBatch runs all tests for the store.KeyValueBatch interface.
+// Stores that don't implement this interface are skipped.
+func (f Factory) TestKeyValueBatch(t *testing.T) {
+	a := f.initKeyValueStore(t)
+	defer f.freeKeyValueStore(a)
+
+	b, ok := a.(store.KeyValueBatch)
+	if !ok {
+		t.Skip("tested store doesn't support key-value batches")
+	}
+
+	t.Run("WriteValues", func(t *testing.T) {
+		ctx := context.Background()
+		prefix := randomPrefix(t)
+		k1, k2, k3 := withPrefix(prefix, 1), withPrefix(prefix, 2), withPrefix(prefix, 3)
+
+		require.NoError(t, a.SetValue(ctx, k1, []byte("v1")), "a.SetValue()")
+		require.NoError(t, a.SetValue(ctx, k2, []byte("v2")), "a.SetValue()")
+
+		err := b.WriteValues(ctx, []store.KeyValueWrite{
+			{Key: k1, Value: []byte("updated")},
+			{Key: k2, Delete: true},
+			{Key: k3, Value: []byte("v3")},
+		})
+		require.NoError(t, err, "b.WriteValues()")
+
+		v, err := a.GetValue(ctx, k1)
+		assert.NoError(t, err, "a.GetValue()")
+		assert.Equal(t, []byte("updated"), v)
+
+		v, err = a.GetValue(ctx, k2)
+		assert.NoError(t, err, "a.GetValue()")
+		assert.Nil(t, v, "Deleted value should not be found")
+
+		v, err = a.GetValue(ctx, k3)
+		assert.NoError(t, err, "a.GetValue()")
+		assert.Equal(t, []byte("v3"), v)
+	})
+
+	t.Run("WriteValues in order", func(t *testing.T) {
+		ctx := context.Background()
+		prefix := randomPrefix(t)
+		k1, k2 := withPrefix(prefix, 1), withPrefix(prefix, 2)
+
+		err := b.WriteValues(ctx, []store.KeyValueWrite{
+			{Key: k1, Value: []byte("v1")},
+			{Key: k1, Value: []byte("updated")},
+			{Key: k2, Value: []byte("v2")},
+			{Key: k2, Delete: true},
+		})
+		require.NoError(t, err, "b.WriteValues()")
+
+		v, err := a.GetValue(ctx, k1)
+		assert.NoError(t, err, "a.GetValue()")
+		assert.Equal(t, []byte("updated"), v)
+
+		v, err = a.GetValue(ctx, k2)
+		assert.NoError(t, err, "a.GetValue()")
+		assert.Nil(t, v, "Deleted value should not be found")
+	})
+
+	t.Run("WriteValues empty", func(t *testing.T) {
+		err := b.WriteValues(context.Background(), nil)
+		assert.NoError(t, err, "b.WriteValues()")
+	})
+
+	t.Run("WriteValues delete not found", func(t *testing.T) {
+		ctx := context.Background()
+		k := withPrefix(randomPrefix(t), 1)
+
+		err := b.WriteValues(ctx, []store.KeyValueWrite{{Key: k, Delete: true}})
+		assert.NoError(t, err, "b.WriteValues()")
+	})
+}
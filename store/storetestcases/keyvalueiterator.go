@@ -0,0 +1,167 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storetestcases
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/stratumn/go-core/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// randomPrefix returns a key prefix that isolates a test from the keys
+// written by other tests.
+func randomPrefix(t *testing.T) []byte {
+	prefix := make([]byte, 16)
+	_, err := rand.Read(prefix)
+	require.NoError(t, err)
+	return prefix
+}
+
+func withPrefix(prefix []byte, suffix ...byte) []byte {
+	key := make([]byte, 0, len(prefix)+len(suffix))
+	key = append(key, prefix...)
+	return append(key, suffix...)
+}
+
+type keyValue struct {
+	Key   []byte
+	Value []byte
+}
+
+func collectValues(t *testing.T, it store.KeyValueIterator, start, end []byte) []keyValue {
+	var kvs []keyValue
+	err := it.IterateValues(context.Background(), start, end, func(key, value []byte) error {
+		kvs = append(kvs, keyValue{Key: key, Value: value})
+		return nil
+	})
+	require.NoError(t, err, "it.IterateValues()")
+	return kvs
+}
+
+// TestKeyValueIterator runs all tests for the store.KeyValueIterator
+// interface.
+// Stores that don't implement this interface are skipped.
+func (f Factory) TestKeyValueIterator(t *testing.T) {
+	a := f.initKeyValueStore(t)
+	defer f.freeKeyValueStore(a)
+
+	it, ok := a.(store.KeyValueIterator)
+	if !ok {
+		t.Skip("tested store doesn't support key-value iteration")
+	}
+
+	ctx := context.Background()
+	prefix := randomPrefix(t)
+	values := []keyValue{
+		{withPrefix(prefix, 0x00), []byte("v0")},
+		{withPrefix(prefix, 0x01), []byte("v1")},
+		{withPrefix(prefix, 0x01, 0x00), []byte("v2")},
+		{withPrefix(prefix, 0x02), []byte("v3")},
+		{withPrefix(prefix, 0xff), []byte("v4")},
+	}
+
+	// Insert in reverse order to make sure the store sorts the keys.
+	for i := len(values) - 1; i >= 0; i-- {
+		require.NoError(t, a.SetValue(ctx, values[i].Key, values[i].Value), "a.SetValue()")
+	}
+
+	t.Run("prefix", func(t *testing.T) {
+		var kvs []keyValue
+		err := store.IterateValuesWithPrefix(ctx, it, prefix, func(key, value []byte) error {
+			kvs = append(kvs, keyValue{Key: key, Value: value})
+			return nil
+		})
+		require.NoError(t, err, "store.IterateValuesWithPrefix()")
+		assert.Equal(t, values, kvs)
+	})
+
+	t.Run("range", func(t *testing.T) {
+		kvs := collectValues(t, it, withPrefix(prefix, 0x01), withPrefix(prefix, 0x02))
+		assert.Equal(t, values[1:3], kvs)
+	})
+
+	t.Run("empty range", func(t *testing.T) {
+		kvs := collectValues(t, it, withPrefix(prefix, 0x03), withPrefix(prefix, 0x04))
+		assert.Empty(t, kvs)
+	})
+
+	t.Run("no upper bound", func(t *testing.T) {
+		errStop := errors.New("stop")
+
+		var kvs []keyValue
+		err := it.IterateValues(ctx, withPrefix(prefix, 0x02), nil, func(key, value []byte) error {
+			kvs = append(kvs, keyValue{Key: key, Value: value})
+			if len(kvs) == 2 {
+				return errStop
+			}
+			return nil
+		})
+		assert.Equal(t, errStop, err, "it.IterateValues()")
+		assert.Equal(t, values[3:], kvs)
+	})
+
+	t.Run("stops on error", func(t *testing.T) {
+		errStop := errors.New("stop")
+
+		calls := 0
+		err := store.IterateValuesWithPrefix(ctx, it, prefix, func(key, value []byte) error {
+			calls++
+			return errStop
+		})
+		assert.Equal(t, errStop, err, "store.IterateValuesWithPrefix()")
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("many values", func(t *testing.T) {
+		p := randomPrefix(t)
+		var want []keyValue
+		for i := 0; i < 600; i++ {
+			kv := keyValue{withPrefix(p, byte(i>>8), byte(i)), []byte{byte(i)}}
+			require.NoError(t, a.SetValue(ctx, kv.Key, kv.Value), "a.SetValue()")
+			want = append(want, kv)
+		}
+
+		// The callback can read the store while iterating.
+		var kvs []keyValue
+		err := store.IterateValuesWithPrefix(ctx, it, p, func(key, value []byte) error {
+			stored, err := a.GetValue(ctx, key)
+			if err != nil {
+				return err
+			}
+
+			kvs = append(kvs, keyValue{Key: key, Value: stored})
+			return nil
+		})
+		require.NoError(t, err, "store.IterateValuesWithPrefix()")
+		assert.Equal(t, want, kvs)
+	})
+
+	t.Run("skips deleted values", func(t *testing.T) {
+		p := randomPrefix(t)
+		require.NoError(t, a.SetValue(ctx, withPrefix(p, 0x00), []byte("v0")), "a.SetValue()")
+		require.NoError(t, a.SetValue(ctx, withPrefix(p, 0x01), []byte("v1")), "a.SetValue()")
+
+		_, err := a.DeleteValue(ctx, withPrefix(p, 0x00))
+		require.NoError(t, err, "a.DeleteValue()")
+
+		kvs := collectValues(t, it, p, store.PrefixEnd(p))
+		assert.Equal(t, []keyValue{{withPrefix(p, 0x01), []byte("v1")}}, kvs)
+	})
+}
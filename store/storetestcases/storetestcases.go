@@ -44,6 +44,8 @@ type Factory struct {
 // RunKeyValueStoreTests runs all the tests for the key value store interface.
 func (f Factory) RunKeyValueStoreTests(t *testing.T) {
 	t.Run("TestKeyValueStore", f.TestKeyValueStore)
+	t.Run("TestKeyValueIterator", f.TestKeyValueIterator)
+	t.Run("TestKeyValueBatch", f.TestKeyValueBatch)
 }
 
 // RunStoreTests runs all the tests for the store adapter interface.
@@ -53,6 +55,12 @@ func (f Factory) RunStoreTests(t *testing.T) {
 	t.Run("Test adapter config", f.TestAdapterConfig)
 	t.Run("Test finding segments", f.TestFindSegments)
 	t.Run("Test getting map IDs", f.TestGetMapIDs)
+	t.Run("Test cursor pagination", f.TestCursorPagination)
+	t.Run("Test map IDs cursor pagination", f.TestMapIDsCursorPagination)
+	t.Run("Test segment iterator", f.TestSegmentIterator)
+	t.Run("Test finding segments by creation time", f.TestFindSegmentsCreatedAt)
+	t.Run("Test finding segments by link data", f.TestFindSegmentsData)
+	t.Run("Test map graph", f.TestGraph)
 	t.Run("Test getting segments", f.TestGetSegment)
 	t.Run("Test creating links", f.TestCreateLink)
 	t.Run("Test batch implementation", f.TestBatch)
@@ -0,0 +1,40 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storetesting
+
+import (
+	"github.com/stratumn/go-core/store"
+)
+
+// StartedStore wraps a store and signals when a server has started
+// listening to its events, so that tests don't miss the first events.
+type StartedStore struct {
+	store.Adapter
+
+	// Started is closed when a store event channel is added.
+	Started chan struct{}
+}
+
+// NewStartedStore wraps a store.
+func NewStartedStore(a store.Adapter) *StartedStore {
+	return &StartedStore{Adapter: a, Started: make(chan struct{})}
+}
+
+// AddStoreEventChannel implements
+// github.com/stratumn/go-core/store.Adapter.AddStoreEventChannel.
+func (s *StartedStore) AddStoreEventChannel(c chan *store.Event) {
+	s.Adapter.AddStoreEventChannel(c)
+	close(s.Started)
+}
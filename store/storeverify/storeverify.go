@@ -0,0 +1,147 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package storeverify verifies the evidences of segments.
+//
+// Each evidence backend has a verifier that checks that an evidence proves
+// the existence of a link hash. Verifiers for the dummy, batch and blockchain
+// fossilizers are registered by default; other backends register theirs with
+// Register. For instance importing github.com/stratumn/go-core/tmpop/evidences
+// registers the verifier of Tendermint evidences.
+//
+// Evidences that can't be fully checked (because no verifier is registered
+// for their backend or because part of their proof can't be verified) are
+// reported as unverified, and are not valid.
+package storeverify
+
+import (
+	"bytes"
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/stratumn/go-chainscript"
+)
+
+// Errors returned by verifiers.
+var (
+	ErrUnknownBackend   = errors.New("no verifier for evidence backend")
+	ErrInvalidLinkHash  = errors.New("link hash doesn't match the link")
+	ErrInvalidProof     = errors.New("proof doesn't match the link hash")
+	ErrInvalidRootProof = errors.New("proof doesn't match the merkle root")
+
+	// ErrUnverifiedRootProof is returned when the merkle path of a batch
+	// evidence is valid but the proof of its merkle root can't be checked.
+	ErrUnverifiedRootProof = errors.New("proof of the merkle root can't be verified")
+)
+
+// Verifier checks that an evidence proves the existence of a link hash.
+// It returns an error explaining why the evidence is invalid.
+type Verifier func(ctx context.Context, linkHash chainscript.LinkHash, evidence *chainscript.Evidence) error
+
+var (
+	verifiersMutex sync.RWMutex
+	verifiers      = map[string]Verifier{}
+)
+
+// Register sets the verifier of the evidences of the given backend.
+func Register(backend string, v Verifier) {
+	verifiersMutex.Lock()
+	defer verifiersMutex.Unlock()
+
+	verifiers[backend] = v
+}
+
+// EvidenceReport is the result of the verification of an evidence.
+// Unverified is set when the evidence couldn't be fully checked, in which
+// case it isn't valid either.
+type EvidenceReport struct {
+	Backend    string `json:"backend"`
+	Provider   string `json:"provider"`
+	Valid      bool   `json:"valid"`
+	Unverified bool   `json:"unverified,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// IsUnverified returns whether an error returned by a verifier means that
+// the evidence couldn't be checked rather than that it is invalid.
+func IsUnverified(err error) bool {
+	cause := errors.Cause(err)
+	return cause == ErrUnknownBackend || cause == ErrUnverifiedRootProof
+}
+
+// Report is the result of the verification of a segment.
+// A segment is valid if its link hash matches its link and all its
+// evidences are valid.
+type Report struct {
+	LinkHash  string            `json:"linkHash"`
+	Valid     bool              `json:"valid"`
+	Error     string            `json:"error,omitempty"`
+	Evidences []*EvidenceReport `json:"evidences"`
+}
+
+// VerifyEvidence verifies an evidence of the given link hash.
+func VerifyEvidence(ctx context.Context, linkHash chainscript.LinkHash, evidence *chainscript.Evidence) error {
+	verifiersMutex.RLock()
+	v, ok := verifiers[evidence.Backend]
+	verifiersMutex.RUnlock()
+
+	if !ok {
+		return errors.Wrap(ErrUnknownBackend, evidence.Backend)
+	}
+
+	return v(ctx, linkHash, evidence)
+}
+
+// VerifySegment verifies the link hash and all the evidences of a segment.
+// Evidences are verified against the hash of the link, not the one stored
+// in the segment.
+func VerifySegment(ctx context.Context, segment *chainscript.Segment) *Report {
+	report := &Report{
+		LinkHash:  segment.LinkHash().String(),
+		Valid:     true,
+		Evidences: []*EvidenceReport{},
+	}
+
+	linkHash, err := segment.Link.Hash()
+	if err != nil {
+		report.Valid = false
+		report.Error = err.Error()
+		return report
+	}
+
+	if !bytes.Equal(linkHash, segment.LinkHash()) {
+		report.Valid = false
+		report.Error = ErrInvalidLinkHash.Error()
+	}
+
+	for _, evidence := range segment.Meta.Evidences {
+		r := &EvidenceReport{
+			Backend:  evidence.Backend,
+			Provider: evidence.Provider,
+			Valid:    true,
+		}
+
+		if err := VerifyEvidence(ctx, linkHash, evidence); err != nil {
+			r.Valid = false
+			r.Unverified = IsUnverified(err)
+			r.Error = err.Error()
+			report.Valid = false
+		}
+
+		report.Evidences = append(report.Evidences, r)
+	}
+
+	return report
+}
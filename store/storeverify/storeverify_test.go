@@ -0,0 +1,236 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storeverify_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-chainscript/chainscripttest"
+	batchevidences "github.com/stratumn/go-core/batchfossilizer/evidences"
+	blockchainevidences "github.com/stratumn/go-core/blockchainfossilizer/evidences"
+	dummyevidences "github.com/stratumn/go-core/dummyfossilizer/evidences"
+	"github.com/stratumn/go-core/store/storeverify"
+	"github.com/stratumn/merkle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSegment(t *testing.T) *chainscript.Segment {
+	segment, err := chainscripttest.RandomLink(t).Segmentify()
+	require.NoError(t, err)
+
+	return segment
+}
+
+// batchEvidence creates a batch evidence of the given link hash, whose merkle
+// root is proven by the given proof.
+func batchEvidence(t *testing.T, linkHash chainscript.LinkHash, rootProof func(root []byte) []byte) *chainscript.Evidence {
+	tree, err := merkle.NewStaticTree([][]byte{
+		chainscripttest.RandomHash(),
+		linkHash,
+		chainscripttest.RandomHash(),
+	})
+	require.NoError(t, err)
+
+	proof := &batchevidences.BatchProof{
+		Timestamp: 42,
+		Root:      tree.Root(),
+		Path:      tree.Path(1),
+		Proof:     rootProof(tree.Root()),
+	}
+
+	e, err := proof.Evidence("btc")
+	require.NoError(t, err)
+
+	return e
+}
+
+func blockchainProof(t *testing.T, data []byte) []byte {
+	e, err := blockchainevidences.New(data, []byte{42}).Evidence("btc")
+	require.NoError(t, err)
+
+	return e.Proof
+}
+
+func TestVerifyEvidence(t *testing.T) {
+	ctx := context.Background()
+	linkHash := chainscripttest.RandomHash()
+
+	dummy, err := (&dummyevidences.DummyProof{Timestamp: 42}).Evidence("dummy")
+	require.NoError(t, err)
+
+	blockchain, err := blockchainevidences.New(linkHash, []byte{42}).Evidence("btc")
+	require.NoError(t, err)
+
+	otherBlockchain, err := blockchainevidences.New(chainscripttest.RandomHash(), []byte{42}).Evidence("btc")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		evidence *chainscript.Evidence
+		err      error
+	}{{
+		"dummy",
+		dummy,
+		nil,
+	}, {
+		"blockchain",
+		blockchain,
+		nil,
+	}, {
+		"blockchain-other-link",
+		otherBlockchain,
+		storeverify.ErrInvalidProof,
+	}, {
+		"batch",
+		batchEvidence(t, linkHash, func(root []byte) []byte { return blockchainProof(t, root) }),
+		nil,
+	}, {
+		"batch-unknown-root-proof",
+		batchEvidence(t, linkHash, func([]byte) []byte { return []byte(`{"timestamp":42}`) }),
+		storeverify.ErrUnverifiedRootProof,
+	}, {
+		"batch-other-link",
+		batchEvidence(t, chainscripttest.RandomHash(), func(root []byte) []byte { return blockchainProof(t, root) }),
+		storeverify.ErrInvalidProof,
+	}, {
+		"batch-other-root",
+		batchEvidence(t, linkHash, func([]byte) []byte { return blockchainProof(t, chainscripttest.RandomHash()) }),
+		storeverify.ErrInvalidRootProof,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := storeverify.VerifyEvidence(ctx, linkHash, tt.evidence)
+			if tt.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err.Error())
+			}
+		})
+	}
+
+	t.Run("unknown-backend", func(t *testing.T) {
+		err := storeverify.VerifyEvidence(ctx, linkHash, &chainscript.Evidence{Backend: "unknown", Provider: "p"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), storeverify.ErrUnknownBackend.Error())
+	})
+
+	t.Run("invalid-proof", func(t *testing.T) {
+		e := *blockchain
+		e.Proof = []byte("not json")
+		assert.Error(t, storeverify.VerifyEvidence(ctx, linkHash, &e))
+	})
+}
+
+func TestRegister(t *testing.T) {
+	ctx := context.Background()
+	called := false
+	storeverify.Register("test", func(_ context.Context, linkHash chainscript.LinkHash, _ *chainscript.Evidence) error {
+		called = true
+		return nil
+	})
+
+	err := storeverify.VerifyEvidence(ctx, chainscripttest.RandomHash(), &chainscript.Evidence{Backend: "test", Provider: "p"})
+	assert.NoError(t, err)
+	assert.True(t, called)
+}
+
+func TestVerifySegment(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("no evidences", func(t *testing.T) {
+		segment := newSegment(t)
+
+		report := storeverify.VerifySegment(ctx, segment)
+		assert.True(t, report.Valid)
+		assert.Empty(t, report.Error)
+		assert.Equal(t, segment.LinkHash().String(), report.LinkHash)
+		assert.Empty(t, report.Evidences)
+	})
+
+	t.Run("valid evidences", func(t *testing.T) {
+		segment := newSegment(t)
+		dummy, err := (&dummyevidences.DummyProof{Timestamp: 42}).Evidence("dummy")
+		require.NoError(t, err)
+		blockchain, err := blockchainevidences.New(segment.LinkHash(), []byte{42}).Evidence("btc")
+		require.NoError(t, err)
+		require.NoError(t, segment.AddEvidence(dummy))
+		require.NoError(t, segment.AddEvidence(blockchain))
+
+		report := storeverify.VerifySegment(ctx, segment)
+		assert.True(t, report.Valid)
+		require.Len(t, report.Evidences, 2)
+		assert.Equal(t, &storeverify.EvidenceReport{
+			Backend:  dummyevidences.Name,
+			Provider: "dummy",
+			Valid:    true,
+		}, report.Evidences[0])
+		assert.Equal(t, &storeverify.EvidenceReport{
+			Backend:  blockchainevidences.BlockchainFossilizerName,
+			Provider: "btc",
+			Valid:    true,
+		}, report.Evidences[1])
+	})
+
+	t.Run("invalid evidence", func(t *testing.T) {
+		segment := newSegment(t)
+		blockchain, err := blockchainevidences.New(chainscripttest.RandomHash(), []byte{42}).Evidence("btc")
+		require.NoError(t, err)
+		require.NoError(t, segment.AddEvidence(blockchain))
+
+		report := storeverify.VerifySegment(ctx, segment)
+		assert.False(t, report.Valid)
+		assert.Empty(t, report.Error)
+		require.Len(t, report.Evidences, 1)
+		assert.False(t, report.Evidences[0].Valid)
+		assert.Equal(t, storeverify.ErrInvalidProof.Error(), report.Evidences[0].Error)
+	})
+
+	t.Run("unverified evidences", func(t *testing.T) {
+		segment := newSegment(t)
+		batch := batchEvidence(t, segment.LinkHash(), func([]byte) []byte { return []byte(`{"timestamp":42}`) })
+		require.NoError(t, segment.AddEvidence(batch))
+		require.NoError(t, segment.AddEvidence(&chainscript.Evidence{Version: "1.0.0", Backend: "unknown", Provider: "p", Proof: []byte{42}}))
+
+		report := storeverify.VerifySegment(ctx, segment)
+		assert.False(t, report.Valid)
+		require.Len(t, report.Evidences, 2)
+
+		for _, r := range report.Evidences {
+			assert.False(t, r.Valid)
+			assert.True(t, r.Unverified)
+		}
+
+		assert.Equal(t, storeverify.ErrUnverifiedRootProof.Error(), report.Evidences[0].Error)
+	})
+
+	t.Run("tampered link", func(t *testing.T) {
+		segment := newSegment(t)
+		blockchain, err := blockchainevidences.New(segment.LinkHash(), []byte{42}).Evidence("btc")
+		require.NoError(t, err)
+		require.NoError(t, segment.AddEvidence(blockchain))
+
+		segment.Link.Meta.Action = "tampered"
+
+		report := storeverify.VerifySegment(ctx, segment)
+		assert.False(t, report.Valid)
+		assert.Equal(t, storeverify.ErrInvalidLinkHash.Error(), report.Error)
+		require.Len(t, report.Evidences, 1)
+		assert.False(t, report.Evidences[0].Valid)
+	})
+}
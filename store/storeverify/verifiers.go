@@ -0,0 +1,87 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storeverify
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/stratumn/go-chainscript"
+	batchevidences "github.com/stratumn/go-core/batchfossilizer/evidences"
+	blockchainevidences "github.com/stratumn/go-core/blockchainfossilizer/evidences"
+	dummyevidences "github.com/stratumn/go-core/dummyfossilizer/evidences"
+)
+
+func init() {
+	Register(dummyevidences.Name, verifyDummyEvidence)
+	Register(batchevidences.BatchFossilizerName, verifyBatchEvidence)
+	Register(blockchainevidences.BlockchainFossilizerName, verifyBlockchainEvidence)
+}
+
+// verifyDummyEvidence only checks that the evidence is well-formed since
+// dummy proofs don't prove anything.
+func verifyDummyEvidence(_ context.Context, linkHash chainscript.LinkHash, evidence *chainscript.Evidence) error {
+	proof, err := dummyevidences.UnmarshalProof(evidence)
+	if err != nil {
+		return err
+	}
+
+	if !proof.Verify(linkHash) {
+		return ErrInvalidProof
+	}
+
+	return nil
+}
+
+// verifyBatchEvidence checks the merkle path from the link hash to the
+// merkle root and the proof of the merkle root.
+// Only blockchain proofs of the merkle root can be checked: other proofs
+// don't say which fossilizer produced them, so the evidence is unverified.
+func verifyBatchEvidence(_ context.Context, linkHash chainscript.LinkHash, evidence *chainscript.Evidence) error {
+	proof, err := batchevidences.UnmarshalProof(evidence)
+	if err != nil {
+		return err
+	}
+
+	if !proof.Verify(linkHash) {
+		return ErrInvalidProof
+	}
+
+	var rootProof blockchainevidences.BlockchainProof
+	if err := json.Unmarshal(proof.Proof, &rootProof); err != nil || len(rootProof.TransactionID) == 0 {
+		return ErrUnverifiedRootProof
+	}
+
+	if !rootProof.Verify(proof.Root) {
+		return ErrInvalidRootProof
+	}
+
+	return nil
+}
+
+// verifyBlockchainEvidence checks that the data stored on the blockchain is
+// the link hash.
+func verifyBlockchainEvidence(_ context.Context, linkHash chainscript.LinkHash, evidence *chainscript.Evidence) error {
+	proof, err := blockchainevidences.UnmarshalProof(evidence)
+	if err != nil {
+		return err
+	}
+
+	if !proof.Verify(linkHash) {
+		return ErrInvalidProof
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storewebhook
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"time"
+
+	"github.com/stratumn/go-core/monitoring"
+	"github.com/stratumn/go-core/store"
+)
+
+var (
+	subscribersPath  string
+	outboxPath       string
+	timeout          time.Duration
+	maxRetryInterval time.Duration
+	maxAttempts      int
+	maxPending       int
+)
+
+// RegisterFlags registers the flags used by RunWithFlags.
+func RegisterFlags() {
+	flag.StringVar(&subscribersPath, "webhooks", "", "Path to a JSON file containing the webhook subscribers notified of store events (webhooks are disabled when empty)")
+	flag.StringVar(&outboxPath, "webhooks_outbox", "", "Directory where pending webhook deliveries are saved (they are only kept in memory when empty)")
+	flag.DurationVar(&timeout, "webhooks_timeout", DefaultTimeout, "Timeout of webhook requests")
+	flag.DurationVar(&maxRetryInterval, "webhooks_max_retry_interval", DefaultMaxRetryInterval, "Maximum delay between two attempts of a webhook delivery")
+	flag.IntVar(&maxAttempts, "webhooks_max_attempts", 0, "Number of attempts after which a webhook delivery is dropped (0 retries until it succeeds)")
+	flag.IntVar(&maxPending, "webhooks_max_pending", DefaultMaxPending, "Maximum number of pending deliveries of a subscriber kept in memory when there is no outbox")
+}
+
+// RunWithFlags should be called after RegisterFlags and flag.Parse.
+// When a webhook subscribers file is configured, it exports the events of the
+// store to the subscribers in the background.
+func RunWithFlags(a store.Adapter) {
+	if subscribersPath == "" {
+		return
+	}
+
+	data, err := ioutil.ReadFile(subscribersPath)
+	if err != nil {
+		monitoring.LogEntry().WithField("error", err).Fatal("Failed to read webhook subscribers")
+	}
+
+	var subscribers []*Subscriber
+	if err := json.Unmarshal(data, &subscribers); err != nil {
+		monitoring.LogEntry().WithField("error", err).Fatal("Failed to read webhook subscribers")
+	}
+
+	e, err := New(a, &Config{
+		Subscribers:      subscribers,
+		OutboxPath:       outboxPath,
+		Timeout:          timeout,
+		MaxRetryInterval: maxRetryInterval,
+		MaxAttempts:      maxAttempts,
+		MaxPending:       maxPending,
+	})
+	if err != nil {
+		monitoring.LogEntry().WithField("error", err).Fatal("Failed to create webhook exporter")
+	}
+
+	go store.RunExporter(context.Background(), a, e)
+}
@@ -0,0 +1,201 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storewebhook
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stratumn/go-core/monitoring"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+)
+
+// deliverer sends the deliveries of an outbox to a subscriber in the
+// background.
+type deliverer struct {
+	subscriber *Subscriber
+	outbox     *outbox
+	config     *Config
+
+	notify chan struct{}
+	done   chan struct{}
+	closed chan struct{}
+}
+
+func newDeliverer(subscriber *Subscriber, o *outbox, config *Config) *deliverer {
+	d := &deliverer{
+		subscriber: subscriber,
+		outbox:     o,
+		config:     config,
+		notify:     make(chan struct{}, 1),
+		done:       make(chan struct{}),
+		closed:     make(chan struct{}),
+	}
+
+	d.updatePending()
+
+	return d
+}
+
+// push saves a delivery in the outbox.
+// The delivery is dropped if the outbox is kept in memory and is full.
+func (d *deliverer) push(eventType store.EventType, payload []byte) error {
+	err := d.outbox.push(&delivery{
+		EventType: eventType,
+		Payload:   payload,
+		CreatedAt: time.Now(),
+	})
+	if err == errOutboxFull {
+		deliveries.With(prometheus.Labels{subscriberLabel: d.subscriber.Name, statusLabel: statusDropped}).Inc()
+		monitoring.LogEntry().WithField("subscriber", d.subscriber.Name).Warn("Webhook outbox is full. Dropping delivery...")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	d.updatePending()
+
+	select {
+	case d.notify <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func (d *deliverer) updatePending() {
+	pendingDeliveries.With(prometheus.Labels{subscriberLabel: d.subscriber.Name}).Set(float64(d.outbox.len()))
+}
+
+// retryInterval returns the delay before the next attempt of a delivery.
+func (d *deliverer) retryInterval(attempts int) time.Duration {
+	interval := d.config.MinRetryInterval
+	for i := 1; i < attempts && interval < d.config.MaxRetryInterval; i++ {
+		interval *= 2
+	}
+
+	if interval > d.config.MaxRetryInterval {
+		interval = d.config.MaxRetryInterval
+	}
+
+	return interval
+}
+
+// run sends deliveries until the deliverer is stopped.
+// A failing delivery is retried before the next ones, so that subscribers
+// receive events in order.
+func (d *deliverer) run() {
+	defer close(d.closed)
+
+	labels := prometheus.Labels{subscriberLabel: d.subscriber.Name}
+	log := monitoring.LogEntry().WithField("subscriber", d.subscriber.Name)
+
+	for {
+		del := d.outbox.head()
+		if del == nil {
+			select {
+			case <-d.done:
+				return
+			case <-d.notify:
+				continue
+			}
+		}
+
+		err := d.send(del)
+		if err == nil {
+			deliveryLatency.With(labels).Observe(float64(time.Since(del.CreatedAt)) / float64(time.Millisecond))
+			deliveries.With(prometheus.Labels{subscriberLabel: d.subscriber.Name, statusLabel: statusSuccess}).Inc()
+			d.pop(del)
+			continue
+		}
+
+		del.Attempts++
+
+		if d.config.MaxAttempts > 0 && del.Attempts >= d.config.MaxAttempts {
+			deliveries.With(prometheus.Labels{subscriberLabel: d.subscriber.Name, statusLabel: statusDropped}).Inc()
+			log.WithField("delivery", del.ID).WithField("error", err).Error("Webhook delivery failed too many times. Dropping...")
+			d.pop(del)
+			continue
+		}
+
+		deliveries.With(prometheus.Labels{subscriberLabel: d.subscriber.Name, statusLabel: statusFailure}).Inc()
+		log.WithField("delivery", del.ID).WithField("error", err).Warn("Webhook delivery failed. Retrying...")
+
+		if err := d.outbox.update(del); err != nil {
+			log.WithField("error", err).Warn("Could not save webhook delivery")
+		}
+
+		select {
+		case <-d.done:
+			return
+		case <-time.After(d.retryInterval(del.Attempts)):
+		}
+	}
+}
+
+// pop removes a delivery from the outbox.
+// The delivery is only removed from the disk if possible, in which case it
+// will be sent again after a restart.
+func (d *deliverer) pop(del *delivery) {
+	if err := d.outbox.pop(); err != nil {
+		monitoring.LogEntry().WithField("subscriber", d.subscriber.Name).WithField("error", err).Warn("Could not remove webhook delivery")
+	}
+
+	d.updatePending()
+}
+
+// send posts a delivery to the subscriber.
+func (d *deliverer) send(del *delivery) error {
+	ctx, cancel := context.WithTimeout(context.Background(), d.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodPost, d.subscriber.URL, bytes.NewReader(del.Payload))
+	if err != nil {
+		return types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not create webhook request")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(del.EventType))
+	req.Header.Set(DeliveryHeader, del.ID)
+	req.Header.Set(SignatureHeader, Sign(d.subscriber.Secret, del.Payload))
+
+	res, err := d.config.HTTPClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return types.WrapError(err, errorcode.Unavailable, store.Component, "could not send webhook request")
+	}
+
+	defer res.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return types.NewErrorf(errorcode.Unavailable, store.Component, "webhook responded with status %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+// stop stops the deliverer and waits for the current request to complete.
+func (d *deliverer) stop() {
+	close(d.done)
+	<-d.closed
+}
@@ -0,0 +1,69 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storewebhook
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/stratumn/go-core/monitoring"
+)
+
+const (
+	subscriberLabel = "subscriber"
+	statusLabel     = "status"
+
+	statusSuccess = "success"
+	statusFailure = "failure"
+	statusDropped = "dropped"
+)
+
+var (
+	deliveries        *prometheus.CounterVec
+	pendingDeliveries *prometheus.GaugeVec
+	deliveryLatency   *prometheus.HistogramVec
+)
+
+func init() {
+	deliveries = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: monitoring.Stratumn,
+			Subsystem: "webhook",
+			Name:      "delivery",
+			Help:      "number of webhook delivery attempts",
+		},
+		[]string{subscriberLabel, statusLabel},
+	)
+
+	pendingDeliveries = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: monitoring.Stratumn,
+			Subsystem: "webhook",
+			Name:      "pending_deliveries",
+			Help:      "number of events waiting to be delivered",
+		},
+		[]string{subscriberLabel},
+	)
+
+	deliveryLatency = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: monitoring.Stratumn,
+			Subsystem: "webhook",
+			Name:      "delivery_latency_ms",
+			Help:      "delay between an event and its successful delivery",
+			Buckets:   monitoring.DefaultLatencyBuckets,
+		},
+		[]string{subscriberLabel},
+	)
+}
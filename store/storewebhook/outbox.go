@@ -0,0 +1,211 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storewebhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+)
+
+// delivery is a payload waiting to be sent to a subscriber.
+type delivery struct {
+	Seq       uint64          `json:"seq"`
+	ID        string          `json:"id"`
+	EventType store.EventType `json:"eventType"`
+	Payload   json.RawMessage `json:"payload"`
+	Attempts  int             `json:"attempts"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// outbox is the queue of the deliveries of a subscriber.
+//
+// When it has a directory, each delivery is saved in its own file, named
+// after its sequence number, until it is removed from the queue. Otherwise
+// the length of the queue is bounded.
+type outbox struct {
+	dir    string
+	maxLen int
+
+	mu      sync.Mutex
+	lastSeq uint64
+	queue   []*delivery
+}
+
+// errOutboxFull is returned when pushing to a full in-memory outbox.
+var errOutboxFull = types.NewError(errorcode.ResourceExhausted, store.Component, "webhook outbox is full")
+
+// openOutbox opens or creates an outbox and loads its pending deliveries.
+// The outbox is only kept in memory if the directory is empty, in which case
+// it holds at most maxLen deliveries.
+func openOutbox(dir string, maxLen int) (*outbox, error) {
+	o := &outbox{dir: dir}
+	if dir == "" {
+		o.maxLen = maxLen
+		return o, nil
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, types.WrapError(err, errorcode.Unavailable, store.Component, "could not create webhook outbox")
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, types.WrapError(err, errorcode.Unavailable, store.Component, "could not read webhook outbox")
+	}
+
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, types.WrapError(err, errorcode.Unavailable, store.Component, "could not read webhook outbox")
+		}
+
+		var d delivery
+		if err := json.Unmarshal(data, &d); err != nil {
+			return nil, types.WrapErrorf(err, errorcode.DataLoss, store.Component, "could not read webhook delivery %s", f.Name())
+		}
+
+		o.queue = append(o.queue, &d)
+		if d.Seq > o.lastSeq {
+			o.lastSeq = d.Seq
+		}
+	}
+
+	sort.Slice(o.queue, func(i, j int) bool { return o.queue[i].Seq < o.queue[j].Seq })
+
+	return o, nil
+}
+
+func (o *outbox) path(d *delivery) string {
+	return filepath.Join(o.dir, fmt.Sprintf("%020d.json", d.Seq))
+}
+
+// push assigns a sequence number and an identifier to a delivery and adds it
+// to the queue. The delivery is on disk when push returns.
+// It returns errOutboxFull if the queue is bounded and full.
+func (o *outbox) push(d *delivery) error {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return types.WrapError(err, errorcode.Internal, store.Component, "could not create webhook delivery")
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.maxLen > 0 && len(o.queue) >= o.maxLen {
+		return errOutboxFull
+	}
+
+	d.Seq = o.lastSeq + 1
+	d.ID = hex.EncodeToString(id)
+
+	if err := o.save(d); err != nil {
+		return err
+	}
+
+	o.lastSeq = d.Seq
+	o.queue = append(o.queue, d)
+
+	return nil
+}
+
+// save writes a delivery to disk.
+// The file is atomically replaced so that a crash can't corrupt it.
+func (o *outbox) save(d *delivery) error {
+	if o.dir == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		return types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not save webhook delivery")
+	}
+
+	tmp := o.path(d) + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return types.WrapError(err, errorcode.Unavailable, store.Component, "could not save webhook delivery")
+	}
+
+	if err := os.Rename(tmp, o.path(d)); err != nil {
+		return types.WrapError(err, errorcode.Unavailable, store.Component, "could not save webhook delivery")
+	}
+
+	return nil
+}
+
+// update saves the new state of a delivery.
+func (o *outbox) update(d *delivery) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.save(d)
+}
+
+// head returns the oldest delivery of the queue.
+func (o *outbox) head() *delivery {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(o.queue) == 0 {
+		return nil
+	}
+
+	return o.queue[0]
+}
+
+// pop removes the oldest delivery of the queue.
+func (o *outbox) pop() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(o.queue) == 0 {
+		return nil
+	}
+
+	d := o.queue[0]
+	if o.dir != "" {
+		if err := os.Remove(o.path(d)); err != nil && !os.IsNotExist(err) {
+			return types.WrapError(err, errorcode.Unavailable, store.Component, "could not remove webhook delivery")
+		}
+	}
+
+	o.queue = o.queue[1:]
+
+	return nil
+}
+
+// len returns the number of pending deliveries.
+func (o *outbox) len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return len(o.queue)
+}
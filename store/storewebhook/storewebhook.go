@@ -0,0 +1,300 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package storewebhook exports store events to HTTP webhooks.
+//
+// Each subscriber receives the events matching its filter as JSON POST
+// requests. Payloads are signed with HMAC-SHA256 using the subscriber's
+// secret so that subscribers can verify that they come from the store.
+//
+// Deliveries are saved in an outbox until they succeed, so they survive
+// restarts. Failed deliveries are retried with an exponential backoff, and
+// are sent to each subscriber in the order of the events.
+package storewebhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-core/monitoring"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+)
+
+// Headers set on webhook requests.
+const (
+	// SignatureHeader contains the HMAC-SHA256 signature of the payload.
+	SignatureHeader = "X-Stratumn-Signature"
+
+	// EventHeader contains the type of the event.
+	EventHeader = "X-Stratumn-Event"
+
+	// DeliveryHeader contains a unique identifier of the delivery.
+	// A delivery can be received more than once, for instance if the store
+	// stopped before it was acknowledged.
+	DeliveryHeader = "X-Stratumn-Delivery"
+)
+
+const (
+	// DefaultTimeout is the default timeout of webhook requests.
+	DefaultTimeout = 10 * time.Second
+
+	// DefaultMinRetryInterval is the default delay before the first retry of
+	// a failed delivery.
+	DefaultMinRetryInterval = time.Second
+
+	// DefaultMaxRetryInterval is the default maximum delay between two
+	// attempts of a delivery.
+	DefaultMaxRetryInterval = 10 * time.Minute
+
+	// DefaultMaxPending is the default maximum number of pending deliveries
+	// of a subscriber kept in memory.
+	DefaultMaxPending = 10000
+)
+
+// signaturePrefix is the prefix of signatures, which contains the name of the
+// hash function.
+const signaturePrefix = "sha256="
+
+// Subscriber is an HTTP endpoint notified of store events.
+type Subscriber struct {
+	// Name identifies the subscriber in metrics and in the outbox.
+	// It must be unique.
+	Name string `json:"name"`
+
+	// URL is the address the events are posted to.
+	URL string `json:"url"`
+
+	// Secret is the key used to sign the payloads.
+	Secret string `json:"secret"`
+
+	// Filter restricts the links (or the evidences of the links) sent to
+	// the subscriber. Pagination is ignored. Without a filter, every event
+	// is sent.
+	Filter *store.SegmentFilter `json:"filter,omitempty"`
+}
+
+// Config contains configuration options for the exporter.
+type Config struct {
+	// Subscribers receiving the events.
+	Subscribers []*Subscriber
+
+	// OutboxPath is the directory where pending deliveries are saved.
+	// Without it, pending deliveries are only kept in memory and are lost
+	// when the program stops.
+	OutboxPath string
+
+	// Timeout of webhook requests. Defaults to DefaultTimeout.
+	Timeout time.Duration
+
+	// MinRetryInterval is the delay before the first retry of a failed
+	// delivery. It doubles after each attempt.
+	// Defaults to DefaultMinRetryInterval.
+	MinRetryInterval time.Duration
+
+	// MaxRetryInterval is the maximum delay between two attempts of a
+	// delivery. Defaults to DefaultMaxRetryInterval.
+	MaxRetryInterval time.Duration
+
+	// MaxAttempts is the number of attempts after which a delivery is
+	// dropped. Zero retries deliveries until they succeed.
+	MaxAttempts int
+
+	// MaxPending is the maximum number of pending deliveries of a subscriber
+	// when they are only kept in memory. New deliveries are dropped once it
+	// is reached. Defaults to DefaultMaxPending.
+	MaxPending int
+
+	// HTTPClient sends the requests. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// Exporter implements store.EventExporter by posting events to webhooks.
+type Exporter struct {
+	reader     store.SegmentReader
+	deliverers []*deliverer
+}
+
+// New creates an exporter and starts delivering the pending events of the
+// outbox. The reader is used to load the links of saved evidences, which are
+// needed to filter evidence events.
+func New(reader store.SegmentReader, config *Config) (*Exporter, error) {
+	c := *config
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultTimeout
+	}
+	if c.MinRetryInterval <= 0 {
+		c.MinRetryInterval = DefaultMinRetryInterval
+	}
+	if c.MaxRetryInterval <= 0 {
+		c.MaxRetryInterval = DefaultMaxRetryInterval
+	}
+	if c.MaxPending <= 0 {
+		c.MaxPending = DefaultMaxPending
+	}
+	if c.HTTPClient == nil {
+		c.HTTPClient = http.DefaultClient
+	}
+
+	if err := validateSubscribers(c.Subscribers); err != nil {
+		return nil, err
+	}
+
+	e := &Exporter{reader: reader}
+
+	for _, sub := range c.Subscribers {
+		dir := ""
+		if c.OutboxPath != "" {
+			dir = filepath.Join(c.OutboxPath, sub.Name)
+		}
+
+		o, err := openOutbox(dir, c.MaxPending)
+		if err != nil {
+			e.Close()
+			return nil, err
+		}
+
+		d := newDeliverer(sub, o, &c)
+		e.deliverers = append(e.deliverers, d)
+		go d.run()
+	}
+
+	return e, nil
+}
+
+func validateSubscribers(subscribers []*Subscriber) error {
+	names := make(map[string]struct{}, len(subscribers))
+
+	for _, sub := range subscribers {
+		if sub.Name == "" || strings.ContainsAny(sub.Name, `/\.`) {
+			return types.NewErrorf(errorcode.InvalidArgument, store.Component, "invalid webhook subscriber name %q", sub.Name)
+		}
+
+		if _, ok := names[sub.Name]; ok {
+			return types.NewErrorf(errorcode.InvalidArgument, store.Component, "duplicate webhook subscriber %s", sub.Name)
+		}
+		names[sub.Name] = struct{}{}
+
+		u, err := url.Parse(sub.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return types.NewErrorf(errorcode.InvalidArgument, store.Component, "invalid URL for webhook subscriber %s", sub.Name)
+		}
+
+		if sub.Filter != nil {
+			for i := range sub.Filter.Data {
+				if err := sub.Filter.Data[i].Validate(); err != nil {
+					return types.WrapErrorf(err, errorcode.InvalidArgument, store.Component, "invalid filter for webhook subscriber %s", sub.Name)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Push implements github.com/stratumn/go-core/store.EventExporter.Push.
+// It saves a delivery in the outbox of every subscriber interested in the
+// event. The event is sent in the background.
+func (e *Exporter) Push(ctx context.Context, event *store.Event) error {
+	links := e.eventLinks(ctx, event)
+
+	for _, d := range e.deliverers {
+		filtered := event
+		if filter := d.subscriber.Filter; filter != nil {
+			if filtered = event.Filter(filter.MatchLink, links); filtered == nil {
+				continue
+			}
+		}
+
+		payload, err := json.Marshal(filtered)
+		if err != nil {
+			return types.WrapError(err, errorcode.InvalidArgument, store.Component, "could not marshal event")
+		}
+
+		if err := d.push(filtered.EventType, payload); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// eventLinks loads the links of the evidences of an event if a subscriber
+// needs them.
+func (e *Exporter) eventLinks(ctx context.Context, event *store.Event) map[string]*chainscript.Link {
+	evidences, ok := event.Data.(map[string]*chainscript.Evidence)
+	if !ok {
+		return nil
+	}
+
+	filtered := false
+	for _, d := range e.deliverers {
+		filtered = filtered || d.subscriber.Filter != nil
+	}
+
+	if !filtered {
+		return nil
+	}
+
+	links := make(map[string]*chainscript.Link, len(evidences))
+	for linkHashStr := range evidences {
+		linkHash, err := chainscript.NewLinkHashFromString(linkHashStr)
+		if err != nil {
+			continue
+		}
+
+		segment, err := e.reader.GetSegment(ctx, linkHash)
+		if err != nil {
+			monitoring.LogEntry().WithField("error", err).Warn("Could not load link of saved evidence")
+			continue
+		}
+
+		if segment != nil {
+			links[linkHashStr] = segment.Link
+		}
+	}
+
+	return links
+}
+
+// Close stops the deliveries and waits for the current requests to
+// complete. Pending deliveries stay in the outbox.
+func (e *Exporter) Close() {
+	for _, d := range e.deliverers {
+		d.stop()
+	}
+}
+
+// Sign returns the signature of a payload, as set in the SignatureHeader.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature of a payload in constant time.
+// Subscribers should use it to make sure requests come from the store.
+func Verify(secret string, payload []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, payload)), []byte(signature))
+}
@@ -0,0 +1,345 @@
+// Copyright 2016-2018 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storewebhook
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stratumn/go-chainscript"
+	"github.com/stratumn/go-chainscript/chainscripttest"
+	"github.com/stratumn/go-core/dummystore"
+	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store"
+	"github.com/stratumn/go-core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// request is a webhook request received by a test server.
+type request struct {
+	path     string
+	header   http.Header
+	body     []byte
+	received *store.Event
+}
+
+// webhookServer records the requests it receives. The status of the first
+// responses can be configured.
+type webhookServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []*request
+	statuses []int
+}
+
+func newWebhookServer(t *testing.T, statuses ...int) *webhookServer {
+	s := &webhookServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if len(s.statuses) > 0 {
+			status := s.statuses[0]
+			s.statuses = s.statuses[1:]
+			if status != http.StatusOK {
+				w.WriteHeader(status)
+				return
+			}
+		}
+
+		var event store.Event
+		require.NoError(t, json.Unmarshal(body, &event))
+
+		s.requests = append(s.requests, &request{
+			path:     r.URL.Path,
+			header:   r.Header,
+			body:     body,
+			received: &event,
+		})
+	}))
+
+	return s
+}
+
+// waitRequests waits until the server received the given number of requests
+// and returns them.
+func (s *webhookServer) waitRequests(t *testing.T, count int) []*request {
+	timeout := time.After(5 * time.Second)
+
+	for {
+		s.mu.Lock()
+		requests := s.requests
+		s.mu.Unlock()
+
+		if len(requests) >= count {
+			return requests
+		}
+
+		select {
+		case <-timeout:
+			require.Fail(t, "webhook requests not received")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func (s *webhookServer) requestsByPath() map[string][]*request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := map[string][]*request{}
+	for _, r := range s.requests {
+		res[r.path] = append(res[r.path], r)
+	}
+
+	return res
+}
+
+func TestNew_invalidConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		subscribers []*Subscriber
+	}{{
+		"missing name",
+		[]*Subscriber{{URL: "http://localhost"}},
+	}, {
+		"invalid name",
+		[]*Subscriber{{Name: "../etc", URL: "http://localhost"}},
+	}, {
+		"duplicate name",
+		[]*Subscriber{{Name: "a", URL: "http://localhost"}, {Name: "a", URL: "http://localhost"}},
+	}, {
+		"invalid URL",
+		[]*Subscriber{{Name: "a", URL: "localhost"}},
+	}, {
+		"invalid filter",
+		[]*Subscriber{{Name: "a", URL: "http://localhost", Filter: &store.SegmentFilter{
+			Data: []store.DataPredicate{{Path: "a", Op: "unknown"}},
+		}}},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(dummystore.New(&dummystore.Config{}), &Config{Subscribers: tt.subscribers})
+			require.Error(t, err)
+			assert.Equal(t, errorcode.InvalidArgument, err.(*types.Error).Code)
+		})
+	}
+}
+
+func TestExporter_Push(t *testing.T) {
+	ctx := context.Background()
+	server := newWebhookServer(t)
+	defer server.Close()
+
+	a := dummystore.New(&dummystore.Config{})
+	e, err := New(a, &Config{Subscribers: []*Subscriber{{
+		Name:   "all",
+		URL:    server.URL + "/all",
+		Secret: "secret1",
+	}, {
+		Name:   "m1",
+		URL:    server.URL + "/m1",
+		Secret: "secret2",
+		Filter: &store.SegmentFilter{MapIDs: []string{"m1"}},
+	}}})
+	require.NoError(t, err)
+	defer e.Close()
+
+	l1 := chainscripttest.NewLinkBuilder(t).WithMapID("m1").Build()
+	l2 := chainscripttest.NewLinkBuilder(t).WithMapID("m2").Build()
+	lh1, _ := a.CreateLink(ctx, l1)
+	lh2, _ := a.CreateLink(ctx, l2)
+
+	require.NoError(t, e.Push(ctx, store.NewSavedLinks(l1, l2)))
+	require.NoError(t, e.Push(ctx, store.NewSavedLinks(l2)))
+
+	evidences := store.NewSavedEvidences()
+	evidences.AddSavedEvidence(lh1, chainscripttest.RandomEvidence(t))
+	evidences.AddSavedEvidence(lh2, chainscripttest.RandomEvidence(t))
+	require.NoError(t, e.Push(ctx, evidences))
+
+	server.waitRequests(t, 5)
+	requests := server.requestsByPath()
+
+	t.Run("sends every event without filter", func(t *testing.T) {
+		all := requests["/all"]
+		require.Len(t, all, 3)
+		assert.Len(t, all[0].received.Data, 2)
+		assert.Len(t, all[1].received.Data, 1)
+		assert.Len(t, all[2].received.Data, 2)
+	})
+
+	t.Run("filters events", func(t *testing.T) {
+		m1 := requests["/m1"]
+		require.Len(t, m1, 2)
+
+		links := m1[0].received.Data.([]*chainscript.Link)
+		require.Len(t, links, 1)
+		chainscripttest.LinksEqual(t, l1, links[0])
+
+		assert.Len(t, m1[1].received.Data, 1)
+		assert.Contains(t, m1[1].received.Data, lh1.String())
+	})
+
+	t.Run("sets headers", func(t *testing.T) {
+		r := requests["/m1"][0]
+		assert.Equal(t, "application/json", r.header.Get("Content-Type"))
+		assert.Equal(t, string(store.SavedLinks), r.header.Get(EventHeader))
+		assert.NotEmpty(t, r.header.Get(DeliveryHeader))
+		assert.NotEqual(t, r.header.Get(DeliveryHeader), requests["/m1"][1].header.Get(DeliveryHeader))
+	})
+
+	t.Run("signs payloads", func(t *testing.T) {
+		r := requests["/m1"][0]
+		assert.True(t, Verify("secret2", r.body, r.header.Get(SignatureHeader)))
+		assert.False(t, Verify("secret1", r.body, r.header.Get(SignatureHeader)))
+	})
+}
+
+func TestExporter_retry(t *testing.T) {
+	ctx := context.Background()
+	server := newWebhookServer(t, http.StatusInternalServerError, http.StatusServiceUnavailable)
+	defer server.Close()
+
+	e, err := New(dummystore.New(&dummystore.Config{}), &Config{
+		Subscribers:      []*Subscriber{{Name: "sub", URL: server.URL}},
+		MinRetryInterval: time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer e.Close()
+
+	l1 := chainscripttest.RandomLink(t)
+	l2 := chainscripttest.RandomLink(t)
+	require.NoError(t, e.Push(ctx, store.NewSavedLinks(l1)))
+	require.NoError(t, e.Push(ctx, store.NewSavedLinks(l2)))
+
+	requests := server.waitRequests(t, 2)
+	require.Len(t, requests, 2)
+	chainscripttest.LinksEqual(t, l1, requests[0].received.Data.([]*chainscript.Link)[0])
+	chainscripttest.LinksEqual(t, l2, requests[1].received.Data.([]*chainscript.Link)[0])
+}
+
+func TestExporter_maxAttempts(t *testing.T) {
+	ctx := context.Background()
+	server := newWebhookServer(t, http.StatusInternalServerError, http.StatusInternalServerError)
+	defer server.Close()
+
+	e, err := New(dummystore.New(&dummystore.Config{}), &Config{
+		Subscribers:      []*Subscriber{{Name: "sub", URL: server.URL}},
+		MinRetryInterval: time.Millisecond,
+		MaxAttempts:      2,
+	})
+	require.NoError(t, err)
+	defer e.Close()
+
+	require.NoError(t, e.Push(ctx, store.NewSavedLinks(chainscripttest.RandomLink(t))))
+
+	l := chainscripttest.RandomLink(t)
+	require.NoError(t, e.Push(ctx, store.NewSavedLinks(l)))
+
+	requests := server.waitRequests(t, 1)
+	require.Len(t, requests, 1)
+	chainscripttest.LinksEqual(t, l, requests[0].received.Data.([]*chainscript.Link)[0])
+}
+
+func TestExporter_maxPending(t *testing.T) {
+	ctx := context.Background()
+	server := newWebhookServer(t, http.StatusInternalServerError)
+	defer server.Close()
+
+	e, err := New(dummystore.New(&dummystore.Config{}), &Config{
+		Subscribers:      []*Subscriber{{Name: "sub", URL: server.URL}},
+		MinRetryInterval: time.Hour,
+		MaxPending:       2,
+	})
+	require.NoError(t, err)
+	defer e.Close()
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, e.Push(ctx, store.NewSavedLinks(chainscripttest.RandomLink(t))))
+	}
+
+	assert.Equal(t, 2, e.deliverers[0].outbox.len())
+}
+
+func TestExporter_outbox(t *testing.T) {
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "storewebhook")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	failing := newWebhookServer(t, http.StatusInternalServerError)
+	defer failing.Close()
+
+	e, err := New(dummystore.New(&dummystore.Config{}), &Config{
+		Subscribers:      []*Subscriber{{Name: "sub", URL: failing.URL}},
+		OutboxPath:       dir,
+		MinRetryInterval: time.Hour,
+	})
+	require.NoError(t, err)
+
+	link := chainscripttest.RandomLink(t)
+	require.NoError(t, e.Push(ctx, store.NewSavedLinks(link)))
+	e.Close()
+
+	files, err := ioutil.ReadDir(dir + "/sub")
+	require.NoError(t, err)
+	assert.Len(t, files, 1, "pending delivery should be saved")
+
+	server := newWebhookServer(t)
+	defer server.Close()
+
+	e, err = New(dummystore.New(&dummystore.Config{}), &Config{
+		Subscribers: []*Subscriber{{Name: "sub", URL: server.URL}},
+		OutboxPath:  dir,
+	})
+	require.NoError(t, err)
+	defer e.Close()
+
+	requests := server.waitRequests(t, 1)
+	chainscripttest.LinksEqual(t, link, requests[0].received.Data.([]*chainscript.Link)[0])
+
+	for d := e.deliverers[0]; d.outbox.len() > 0; {
+		time.Sleep(time.Millisecond)
+	}
+
+	files, err = ioutil.ReadDir(dir + "/sub")
+	require.NoError(t, err)
+	assert.Empty(t, files, "delivery should be removed from the outbox")
+}
+
+func TestSign(t *testing.T) {
+	payload := []byte(`{"EventType":"SavedLinks"}`)
+	signature := Sign("secret", payload)
+
+	assert.Regexp(t, "^sha256=[0-9a-f]{64}$", signature)
+	assert.True(t, Verify("secret", payload, signature))
+	assert.False(t, Verify("secret", []byte(`{}`), signature))
+	assert.False(t, Verify("other", payload, signature))
+}
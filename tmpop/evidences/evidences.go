@@ -19,12 +19,14 @@ package evidences
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 
 	json "github.com/gibson042/canonicaljson-go"
 	"github.com/pkg/errors"
 	"github.com/stratumn/go-chainscript"
 	"github.com/stratumn/go-core/monitoring/errorcode"
+	"github.com/stratumn/go-core/store/storeverify"
 	"github.com/stratumn/go-core/types"
 	mktypes "github.com/stratumn/merkle/types"
 	"github.com/tendermint/go-crypto"
@@ -50,6 +52,10 @@ var (
 	ErrUnknownVersion = errors.New("unknown evidence version")
 )
 
+func init() {
+	storeverify.Register(TMPopName, verifyEvidence)
+}
+
 // TendermintVote is a signed vote by one of the Tendermint validator nodes.
 type TendermintVote struct {
 	PubKey *crypto.PubKey `json:"pubKey"`
@@ -89,6 +95,10 @@ func (p *TendermintProof) Verify(linkHash interface{}) bool {
 		return false
 	}
 
+	if p.Header == nil || p.NextHeader == nil {
+		return false
+	}
+
 	// We first verify that the app hash is correct
 
 	hash := sha256.New()
@@ -242,3 +252,18 @@ func UnmarshalProof(e *chainscript.Evidence) (*TendermintProof, error) {
 		return nil, types.WrapError(ErrUnknownVersion, errorcode.InvalidArgument, TMPopName, "could not unmarshal proof")
 	}
 }
+
+// verifyEvidence implements
+// github.com/stratumn/go-core/store/storeverify.Verifier.
+func verifyEvidence(_ context.Context, linkHash chainscript.LinkHash, evidence *chainscript.Evidence) error {
+	proof, err := UnmarshalProof(evidence)
+	if err != nil {
+		return err
+	}
+
+	if !proof.Verify(linkHash) {
+		return storeverify.ErrInvalidProof
+	}
+
+	return nil
+}
@@ -15,6 +15,7 @@
 package evidences_test
 
 import (
+	"context"
 	"crypto/sha256"
 	"math/rand"
 	"testing"
@@ -22,6 +23,7 @@ import (
 
 	"github.com/stratumn/go-chainscript"
 	"github.com/stratumn/go-chainscript/chainscripttest"
+	"github.com/stratumn/go-core/store/storeverify"
 	"github.com/stratumn/go-core/testutil"
 	"github.com/stratumn/go-core/tmpop/evidences"
 	"github.com/stratumn/merkle"
@@ -213,6 +215,25 @@ func TestTendermintProof(t *testing.T) {
 			linkHash, e := CreateTendermintProof(t, 1)
 			assert.True(t, e.Verify(linkHash), "Proof should be valid")
 		},
+	}, {
+		"storeverify",
+		func(t *testing.T) {
+			linkHash, p := CreateTendermintProof(t, 3)
+			e, err := p.Evidence("testChain")
+			require.NoError(t, err)
+
+			assert.NoError(t, storeverify.VerifyEvidence(context.Background(), linkHash, e))
+
+			err = storeverify.VerifyEvidence(context.Background(), chainscripttest.RandomHash(), e)
+			assert.EqualError(t, err, storeverify.ErrInvalidProof.Error())
+		},
+	}, {
+		"missing-header",
+		func(t *testing.T) {
+			linkHash, e := CreateTendermintProof(t, 1)
+			e.Header = nil
+			assert.False(t, e.Verify(linkHash), "Proof should not be correct if header is missing")
+		},
 	}, {
 		"validations-hash",
 		func(t *testing.T) {
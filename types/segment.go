@@ -36,9 +36,12 @@ type Link struct {
 type SegmentSlice []*chainscript.Segment
 
 // PaginatedSegments is a slice of segments along with the total results count.
+// When more results might be available, NextCursor can be used to fetch the
+// next page.
 type PaginatedSegments struct {
 	Segments   SegmentSlice `json:"segments"`
 	TotalCount int          `json:"totalCount"`
+	NextCursor string       `json:"nextCursor,omitempty"`
 }
 
 // Len implements sort.Interface.Len.
@@ -105,6 +105,26 @@ func (a *StoreWithConfigFile) CreateLink(ctx context.Context, link *chainscript.
 	return a.Adapter.CreateLink(ctx, link)
 }
 
+// IterateSegments delegates the call to the underlying store.
+func (a *StoreWithConfigFile) IterateSegments(ctx context.Context, filter *store.SegmentFilter) (store.SegmentIterator, error) {
+	return store.IterateSegments(ctx, a.Adapter, filter)
+}
+
+// GetAncestors delegates the call to the underlying store.
+func (a *StoreWithConfigFile) GetAncestors(ctx context.Context, linkHash chainscript.LinkHash, depth int) (types.SegmentSlice, error) {
+	return store.GetAncestors(ctx, a.Adapter, linkHash, depth)
+}
+
+// GetDescendants delegates the call to the underlying store.
+func (a *StoreWithConfigFile) GetDescendants(ctx context.Context, linkHash chainscript.LinkHash, depth int) (types.SegmentSlice, error) {
+	return store.GetDescendants(ctx, a.Adapter, linkHash, depth)
+}
+
+// GetMapHeads delegates the call to the underlying store.
+func (a *StoreWithConfigFile) GetMapHeads(ctx context.Context, process, mapID string) (types.SegmentSlice, error) {
+	return store.GetMapHeads(ctx, a.Adapter, process, mapID)
+}
+
 func (a *StoreWithConfigFile) validateCustom(ctx context.Context, link *chainscript.Link) error {
 	a.lock.RLock()
 	defer a.lock.RUnlock()
@@ -24,7 +24,9 @@ import (
 	"github.com/stratumn/go-chainscript"
 	"github.com/stratumn/go-chainscript/chainscripttest"
 	"github.com/stratumn/go-core/dummystore"
+	"github.com/stratumn/go-core/store"
 	"github.com/stratumn/go-core/testutil"
+	"github.com/stratumn/go-core/types"
 	"github.com/stratumn/go-core/validation"
 	"github.com/stratumn/go-core/validation/validationtesting"
 	"github.com/stratumn/go-core/validation/validators"
@@ -220,3 +222,89 @@ func TestStoreWithConfigFile(t *testing.T) {
 		})
 	})
 }
+
+// iterableStore records calls to the native iterator of a store.
+type iterableStore struct {
+	*dummystore.DummyStore
+	iterated bool
+}
+
+func (s *iterableStore) IterateSegments(ctx context.Context, filter *store.SegmentFilter) (store.SegmentIterator, error) {
+	s.iterated = true
+	return s.DummyStore.IterateSegments(ctx, filter)
+}
+
+func TestStoreWithConfigFile_SegmentIterable(t *testing.T) {
+	ctx := context.Background()
+	a := &iterableStore{DummyStore: dummystore.New(nil)}
+
+	va, err := validation.WrapStoreWithConfigFile(a, &validation.Config{})
+	require.NoError(t, err)
+
+	iterable, ok := va.(store.SegmentIterable)
+	require.True(t, ok, "wrapped store should implement store.SegmentIterable")
+
+	linkHash, err := va.CreateLink(ctx, chainscripttest.NewLinkBuilder(t).WithRandomData().Build())
+	require.NoError(t, err)
+
+	it, err := iterable.IterateSegments(ctx, &store.SegmentFilter{Pagination: store.Pagination{Limit: store.DefaultLimit}})
+	require.NoError(t, err)
+	defer it.Close()
+
+	require.True(t, it.Next())
+	assert.Equal(t, linkHash, it.Segment().LinkHash())
+	assert.False(t, it.Next())
+	assert.NoError(t, it.Err())
+	assert.True(t, a.iterated, "native iterator should be used")
+}
+
+// graphStore records calls to the native graph queries of a store.
+type graphStore struct {
+	*dummystore.DummyStore
+	calls []string
+}
+
+func (s *graphStore) GetAncestors(ctx context.Context, linkHash chainscript.LinkHash, depth int) (types.SegmentSlice, error) {
+	s.calls = append(s.calls, "GetAncestors")
+	return s.DummyStore.GetAncestors(ctx, linkHash, depth)
+}
+
+func (s *graphStore) GetDescendants(ctx context.Context, linkHash chainscript.LinkHash, depth int) (types.SegmentSlice, error) {
+	s.calls = append(s.calls, "GetDescendants")
+	return s.DummyStore.GetDescendants(ctx, linkHash, depth)
+}
+
+func (s *graphStore) GetMapHeads(ctx context.Context, process, mapID string) (types.SegmentSlice, error) {
+	s.calls = append(s.calls, "GetMapHeads")
+	return s.DummyStore.GetMapHeads(ctx, process, mapID)
+}
+
+func TestStoreWithConfigFile_GraphReader(t *testing.T) {
+	ctx := context.Background()
+	a := &graphStore{DummyStore: dummystore.New(nil)}
+
+	va, err := validation.WrapStoreWithConfigFile(a, &validation.Config{})
+	require.NoError(t, err)
+
+	graph, ok := va.(store.GraphReader)
+	require.True(t, ok, "wrapped store should implement store.GraphReader")
+
+	link := chainscripttest.NewLinkBuilder(t).WithRandomData().Build()
+	linkHash, err := va.CreateLink(ctx, link)
+	require.NoError(t, err)
+
+	ancestors, err := graph.GetAncestors(ctx, linkHash, 0)
+	require.NoError(t, err)
+	assert.Empty(t, ancestors)
+
+	descendants, err := graph.GetDescendants(ctx, linkHash, 0)
+	require.NoError(t, err)
+	assert.Empty(t, descendants)
+
+	heads, err := graph.GetMapHeads(ctx, link.Meta.Process.Name, link.Meta.MapId)
+	require.NoError(t, err)
+	require.Len(t, heads, 1)
+	assert.Equal(t, linkHash, heads[0].LinkHash())
+
+	assert.Equal(t, []string{"GetAncestors", "GetDescendants", "GetMapHeads"}, a.calls)
+}